@@ -0,0 +1,78 @@
+package batchquery
+
+import (
+	"fmt"
+	"math/big"
+)
+
+// The precision of an Amount's Units, which is enough to hold any uint256 exactly
+const amountPrecision uint = 256
+
+// An integer amount in both its raw on-chain form and in whole units, such as a balance in wei and in ether,
+// for reporting and display without every consumer scaling the raw values (and risking getting the units wrong)
+type Amount struct {
+	// The raw amount, such as a balance in wei (nil = the amount couldn't be read)
+	Raw *big.Int
+
+	// The amount in whole units, such as a balance in ether (nil = the amount couldn't be read)
+	Units *big.Float
+
+	// The number of decimals the raw amount has, such as 18 for ether or a token's decimals
+	Decimals uint8
+}
+
+// Creates a new Amount from a raw amount with the provided number of decimals; use WadDecimals for balances in wei
+func NewAmount(raw *big.Int, decimals uint8) Amount {
+	amount := Amount{
+		Decimals: decimals,
+	}
+	if raw == nil {
+		return amount
+	}
+	amount.Raw = raw
+	amount.Units = new(big.Float).SetPrec(amountPrecision).SetRat(ScaleToRat(raw, decimals))
+	return amount
+}
+
+// Creates Amounts for a list of raw amounts with the same number of decimals, such as token balances from a BalanceBatcher.
+// Raw amounts that are nil, such as balances that couldn't be read, produce Amounts without values.
+func NewAmounts(raws []*big.Int, decimals uint8) []Amount {
+	amounts := make([]Amount, len(raws))
+	for i, raw := range raws {
+		amounts[i] = NewAmount(raw, decimals)
+	}
+	return amounts
+}
+
+// Creates Amounts in ether for a list of balances in wei, such as from BalanceBatcher.GetEthBalances
+func NewEthAmounts(balances []*big.Int) []Amount {
+	return NewAmounts(balances, WadDecimals)
+}
+
+// Formats the amount in whole units with all of its decimals, such as "1.500000000000000000", or "<nil>" if it has no value
+func (a Amount) String() string {
+	if a.Raw == nil {
+		return "<nil>"
+	}
+	return FormatScaled(a.Raw, a.Decimals)
+}
+
+// Gets the call's return value as an Amount with the provided number of decimals, for calls that return a single integer such as a balance or supply.
+// The call must have succeeded, and must have been added with its contract's ABI.
+func (r CallResult) Amount(decimals uint8) (Amount, error) {
+	if !r.Success {
+		return Amount{}, r.Err
+	}
+	values, err := r.unpackValues()
+	if err != nil {
+		return Amount{}, err
+	}
+	if len(values) == 0 {
+		return Amount{}, fmt.Errorf("error unpacking response for contract %s: method %s has no outputs", r.Target.Hex(), r.Method)
+	}
+	raw, err := toBigInt(values[0])
+	if err != nil {
+		return Amount{}, fmt.Errorf("error unpacking response for contract %s, method %s: %w", r.Target.Hex(), r.Method, wrapUnpackError(err))
+	}
+	return NewAmount(raw, decimals), nil
+}
@@ -0,0 +1,51 @@
+package batchquery
+
+import (
+	"errors"
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+func TestAmounts(t *testing.T) {
+	wei, _ := new(big.Int).SetString("1500000000000000000", 10)
+	amounts := NewEthAmounts([]*big.Int{wei, nil})
+	if amounts[0].Raw.Cmp(wei) != 0 || amounts[0].String() != "1.500000000000000000" {
+		t.Fatalf("unexpected amount %s", amounts[0])
+	}
+	if units, _ := amounts[0].Units.Float64(); units != 1.5 {
+		t.Fatalf("expected 1.5 ether, got %f", units)
+	}
+	if amounts[1].Raw != nil || amounts[1].Units != nil || amounts[1].String() != "<nil>" {
+		t.Fatal("expected a missing balance to have no value")
+	}
+}
+
+func TestCallResultAmount(t *testing.T) {
+	mc, _ := newTestMultiCaller(t)
+	account := common.HexToAddress("0x0102")
+	mc.AddCall(testTokenAddress, &testTokenAbi, new(*big.Int), "balanceOf", account).WithKey("balance")
+	mc.AddCall(testTokenAddress, &testTokenAbi, new(string), "symbol").WithKey("symbol")
+	results, err := mc.FlexibleCallResults(true, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	result, _ := results.Get("balance")
+	amount, err := result.Amount(2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := new(big.Float).SetPrec(amountPrecision).SetRat(new(big.Rat).SetFrac(expectedBalance(account, 0), big.NewInt(100)))
+	if amount.Raw.Cmp(expectedBalance(account, 0)) != 0 || amount.Units.Cmp(expected) != 0 || amount.Decimals != 2 {
+		t.Fatalf("unexpected amount %+v", amount)
+	}
+
+	// Results that aren't integers can't be amounts
+	result, _ = results.Get("symbol")
+	_, err = result.Amount(2)
+	if !errors.Is(err, ErrUnpackFailed) {
+		t.Fatalf("expected a string not to be an amount, got %v", err)
+	}
+}
@@ -0,0 +1,230 @@
+package batchquery
+
+import (
+	"fmt"
+	"math/big"
+	"time"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+// A record of exactly which data a batch returned and where it came from, so audited systems can prove what a decision was based on.
+// The requests and responses are hashed with a canonical encoding (the ABI encoding of Multicall3's Call[] and Result[] arrays),
+// so the hashes can be recomputed by anyone holding the record, or from within a contract.
+type Attestation struct {
+	// The number of the block the batch ran at
+	BlockNumber *big.Int `json:"blockNumber"`
+
+	// The hash of the block the batch ran at
+	BlockHash common.Hash `json:"blockHash"`
+
+	// The MultiCaller's EndpointName
+	Endpoint string `json:"endpoint"`
+
+	// The address of the multicall contract the batch ran through
+	ContractAddress common.Address `json:"contractAddress"`
+
+	// The address the batch ran from (zero = the client's default)
+	From common.Address `json:"from"`
+
+	// Whether the batch required every call to succeed
+	RequireSuccess bool `json:"requireSuccess"`
+
+	// The calls in the batch, in the order they were added
+	Requests []AttestedRequest `json:"requests"`
+
+	// The raw response of each call, in the same order as the requests
+	Responses []AttestedResponse `json:"responses"`
+
+	// The Keccak-256 hash of the canonical encoding of the requests
+	RequestHash common.Hash `json:"requestHash"`
+
+	// The Keccak-256 hash of the canonical encoding of the responses
+	ResponseHash common.Hash `json:"responseHash"`
+
+	// The time the batch finished, according to the local clock
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+// A single call within an attested batch
+type AttestedRequest struct {
+	// The contract address of the call's target
+	Target common.Address `json:"target"`
+
+	// The packed call data
+	CallData hexutil.Bytes `json:"callData"`
+}
+
+// The raw response of a single call within an attested batch
+type AttestedResponse struct {
+	// Whether or not the call worked
+	Success bool `json:"success"`
+
+	// The raw return data of the call
+	ReturnData hexutil.Bytes `json:"returnData"`
+}
+
+// Canonical encoding types
+var attestedRequestsType, _ = abi.NewType("tuple[]", "", []abi.ArgumentMarshaling{
+	{Name: "target", Type: "address"},
+	{Name: "callData", Type: "bytes"},
+})
+var attestedResponsesType, _ = abi.NewType("tuple[]", "", []abi.ArgumentMarshaling{
+	{Name: "success", Type: "bool"},
+	{Name: "returnData", Type: "bytes"},
+})
+
+// Invokes all of the previously batched up contract calls with the same semantics as FlexibleCall(), and produces an attestation of the run.
+// The client must implement ITrustedHeaderReader, since the block's hash is read from its header. If opts doesn't specify a block, the batch
+// is pinned to the latest one; unless opts targets a block hash, the header is read again after the batch to make sure the block wasn't
+// replaced by a reorg while it ran. Batches against the pending block can't be attested, since it has no hash.
+func (mc *MultiCaller) FlexibleCallWithAttestation(requireSuccess bool, opts *bind.CallOpts) ([]bool, *Attestation, error) {
+	headerReader, ok := mc.client.(ITrustedHeaderReader)
+	if !ok {
+		return nil, nil, fmt.Errorf("attesting a batch requires a client that can read block headers")
+	}
+	options := mc.newCallOptions(opts)
+	if options.pending {
+		return nil, nil, fmt.Errorf("calls against the pending block can't be attested")
+	}
+
+	// Pin the batch to a block
+	var header *types.Header
+	var err error
+	if options.blockHash != nil {
+		header, err = headerReader.HeaderByHash(options.ctx, *options.blockHash)
+	} else {
+		header, err = headerReader.HeaderByNumber(options.ctx, options.blockNumber)
+	}
+	if err != nil {
+		return nil, nil, fmt.Errorf("error getting header of the block to attest: %w", wrapClientError(err))
+	}
+	runOptions := *options
+	if runOptions.blockHash == nil {
+		runOptions.blockNumber = header.Number
+	}
+
+	calls := mc.calls
+	successes, responses, err := mc.flexibleCallWithResponses(requireSuccess, &runOptions)
+	if err != nil {
+		return nil, nil, err
+	}
+	attestation, err := newAttestation(calls, responses, header)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// Make sure the responses came from the block that was attested
+	if options.blockHash == nil {
+		current, err := headerReader.HeaderByNumber(options.ctx, header.Number)
+		if err != nil {
+			return nil, nil, fmt.Errorf("error checking header of the attested block: %w", wrapClientError(err))
+		}
+		if current.Hash() != attestation.BlockHash {
+			return nil, nil, fmt.Errorf("block %s was replaced by a reorg while the batch ran", header.Number.String())
+		}
+	}
+	attestation.Endpoint = mc.EndpointName
+	attestation.ContractAddress = mc.contractAddress
+	attestation.From = options.from
+	attestation.RequireSuccess = requireSuccess
+	return successes, attestation, nil
+}
+
+// Creates an attestation of a batch's calls and responses at a block.
+// The responses are copied, since they may be stored in the MultiCaller's reusable buffer.
+func newAttestation(calls []*Call, responses []CallResponse, header *types.Header) (*Attestation, error) {
+	attestation := &Attestation{
+		BlockNumber: new(big.Int).Set(header.Number),
+		BlockHash:   header.Hash(),
+		Requests:    make([]AttestedRequest, len(calls)),
+		Responses:   make([]AttestedResponse, len(responses)),
+		CreatedAt:   time.Now().UTC(),
+	}
+	for i, call := range calls {
+		attestation.Requests[i] = AttestedRequest{
+			Target:   call.Target,
+			CallData: common.CopyBytes(call.CallData),
+		}
+	}
+	for i, response := range responses {
+		attestation.Responses[i] = AttestedResponse{
+			Success:    response.Status,
+			ReturnData: common.CopyBytes(response.ReturnData),
+		}
+	}
+
+	var err error
+	attestation.RequestHash, attestation.ResponseHash, err = attestation.computeHashes()
+	if err != nil {
+		return nil, err
+	}
+	return attestation, nil
+}
+
+// Checks that the attestation's hashes match its requests and responses, such as after loading it from storage
+func (a *Attestation) Verify() error {
+	requestHash, responseHash, err := a.computeHashes()
+	if err != nil {
+		return err
+	}
+	if requestHash != a.RequestHash {
+		return fmt.Errorf("request hash %s doesn't match the requests, which hash to %s", a.RequestHash.Hex(), requestHash.Hex())
+	}
+	if responseHash != a.ResponseHash {
+		return fmt.Errorf("response hash %s doesn't match the responses, which hash to %s", a.ResponseHash.Hex(), responseHash.Hex())
+	}
+	return nil
+}
+
+// Computes the hashes of the canonical encodings of the attestation's requests and responses
+func (a *Attestation) computeHashes() (common.Hash, common.Hash, error) {
+	requestHash, err := hashCanonicalRequests(a.Requests)
+	if err != nil {
+		return common.Hash{}, common.Hash{}, err
+	}
+	responseHash, err := hashCanonicalResponses(a.Responses)
+	if err != nil {
+		return common.Hash{}, common.Hash{}, err
+	}
+	return requestHash, responseHash, nil
+}
+
+// Hashes the canonical encoding of a set of requests, which is the ABI encoding of Multicall3's Call[] array
+func hashCanonicalRequests(requests []AttestedRequest) (common.Hash, error) {
+	type request struct {
+		Target   common.Address
+		CallData []byte
+	}
+	encodable := make([]request, len(requests))
+	for i, r := range requests {
+		encodable[i] = request{r.Target, r.CallData}
+	}
+	encoded, err := abi.Arguments{{Type: attestedRequestsType}}.Pack(encodable)
+	if err != nil {
+		return common.Hash{}, fmt.Errorf("error encoding attested requests: %w", err)
+	}
+	return crypto.Keccak256Hash(encoded), nil
+}
+
+// Hashes the canonical encoding of a set of responses, which is the ABI encoding of Multicall3's Result[] array
+func hashCanonicalResponses(responses []AttestedResponse) (common.Hash, error) {
+	type response struct {
+		Success    bool
+		ReturnData []byte
+	}
+	encodable := make([]response, len(responses))
+	for i, r := range responses {
+		encodable[i] = response{r.Success, r.ReturnData}
+	}
+	encoded, err := abi.Arguments{{Type: attestedResponsesType}}.Pack(encodable)
+	if err != nil {
+		return common.Hash{}, fmt.Errorf("error encoding attested responses: %w", err)
+	}
+	return crypto.Keccak256Hash(encoded), nil
+}
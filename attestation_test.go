@@ -0,0 +1,120 @@
+package batchquery
+
+import (
+	"context"
+	"math/big"
+	"strings"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+// A mock client that also serves block headers, where the latest block is 100.
+// If reorg is set, every header read returns a different version of the block.
+type mockHeaderClient struct {
+	*mockClient
+
+	// Whether each header read should see a reorged block
+	reorg bool
+
+	// The number of headers that were read
+	reads int64
+}
+
+func (m *mockHeaderClient) HeaderByNumber(ctx context.Context, number *big.Int) (*types.Header, error) {
+	if number == nil {
+		number = big.NewInt(100)
+	}
+	header := &types.Header{Number: new(big.Int).Set(number)}
+	if m.reorg {
+		header.Extra = big.NewInt(m.reads).Bytes()
+	}
+	m.reads++
+	return header, nil
+}
+
+func (m *mockHeaderClient) HeaderByHash(ctx context.Context, hash common.Hash) (*types.Header, error) {
+	return m.HeaderByNumber(ctx, nil)
+}
+
+// Creates a MultiCaller backed by a mock client that serves headers
+func newTestAttestingMultiCaller(t *testing.T) (*MultiCaller, *mockHeaderClient) {
+	client := &mockHeaderClient{mockClient: &mockClient{}}
+	mc, err := NewMultiCaller(client, testMulticallAddress)
+	if err != nil {
+		t.Fatal(err)
+	}
+	mc.EndpointName = "primary"
+	return mc, client
+}
+
+func TestFlexibleCallWithAttestation(t *testing.T) {
+	mc, _ := newTestAttestingMultiCaller(t)
+	accounts := []common.Address{common.HexToAddress("0x0102"), common.HexToAddress("0x0203")}
+	balances := make([]*big.Int, len(accounts))
+	for i, account := range accounts {
+		mc.AddCall(testTokenAddress, &testTokenAbi, &balances[i], "balanceOf", account)
+	}
+	mc.AddCall(testTokenAddress, &testTokenAbi, nil, "boom")
+
+	successes, attestation, err := mc.FlexibleCallWithAttestation(false, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(successes) != 3 || !successes[0] || !successes[1] || successes[2] {
+		t.Fatalf("unexpected successes: %v", successes)
+	}
+	for i, account := range accounts {
+		if balances[i].Cmp(expectedBalance(account, 100)) != 0 {
+			t.Fatalf("expected balance %d to be read at block 100, got %s", i, balances[i])
+		}
+	}
+
+	expectedHash := (&types.Header{Number: big.NewInt(100)}).Hash()
+	if attestation.BlockNumber.Int64() != 100 || attestation.BlockHash != expectedHash || attestation.Endpoint != "primary" {
+		t.Fatalf("unexpected attestation: %+v", attestation)
+	}
+	if attestation.ContractAddress != testMulticallAddress || attestation.RequireSuccess {
+		t.Fatalf("unexpected attestation: %+v", attestation)
+	}
+	if len(attestation.Requests) != 3 || attestation.Requests[2].Target != testTokenAddress || len(attestation.Responses) != 3 || attestation.Responses[2].Success {
+		t.Fatalf("unexpected attested calls: %+v", attestation)
+	}
+	if new(big.Int).SetBytes(attestation.Responses[0].ReturnData).Cmp(balances[0]) != 0 {
+		t.Fatalf("expected the raw response to hold the balance, got %x", attestation.Responses[0].ReturnData)
+	}
+	err = attestation.Verify()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Tampering with a response is detected
+	attestation.Responses[1].ReturnData[31]++
+	err = attestation.Verify()
+	if err == nil || !strings.Contains(err.Error(), "response hash") {
+		t.Fatalf("expected the tampered response to be detected, got %v", err)
+	}
+}
+
+func TestAttestationDetectsReorgs(t *testing.T) {
+	mc, client := newTestAttestingMultiCaller(t)
+	client.reorg = true
+	var balance *big.Int
+	mc.AddCall(testTokenAddress, &testTokenAbi, &balance, "balanceOf", common.Address{})
+
+	_, _, err := mc.FlexibleCallWithAttestation(true, nil)
+	if err == nil || !strings.Contains(err.Error(), "reorg") {
+		t.Fatalf("expected the reorg to fail the attestation, got %v", err)
+	}
+}
+
+func TestAttestationRequiresHeaders(t *testing.T) {
+	mc, _ := newTestMultiCaller(t)
+	mc.AddCall(testTokenAddress, &testTokenAbi, nil, "balanceOf", common.Address{})
+
+	_, _, err := mc.FlexibleCallWithAttestation(true, nil)
+	if err == nil {
+		t.Fatal("expected a client without headers to be rejected")
+	}
+}
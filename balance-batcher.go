@@ -2,16 +2,17 @@ package batchquery
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"math/big"
 	"strings"
 	"sync"
+	"time"
 
 	"github.com/ethereum/go-ethereum"
 	"github.com/ethereum/go-ethereum/accounts/abi"
 	"github.com/ethereum/go-ethereum/accounts/abi/bind"
 	"github.com/ethereum/go-ethereum/common"
-	"golang.org/x/sync/errgroup"
 )
 
 const (
@@ -32,11 +33,24 @@ type BalanceBatcher struct {
 	// The number of calls to run simultaneously, if the list of addresses is too large for a single call
 	ThreadLimit int
 
+	// The context that queries run in when their options don't provide one (nil = context.Background())
+	BaseContext context.Context
+
+	// The deadline for each call to the balance contract, so every call has one even when the caller's context doesn't (0 = no deadline beyond the one in the context)
+	DefaultTimeout time.Duration
+
 	// The Execution client binding
 	client IContractCaller
 
 	// Address of the balance batcher contract
 	contractAddress common.Address
+
+	// The executor that runs the batcher's calls (nil = no shared executor; see Executor)
+	Executor *Executor
+
+	// An optional non-RPC source of balances, such as an Etherscan-compatible API, used if the client can't be reached.
+	// It's only used for queries against the latest block without a sender, since that's all such sources can serve (nil = no fallback).
+	Fallback IFallbackProvider
 }
 
 // Creates a new BalanceBatcher instance
@@ -63,61 +77,125 @@ func NewBalanceBatcher(client IContractCaller, address common.Address, balanceBa
 }
 
 // Retrieves the ETH balance for a list of addresses. The order of the resulting array corresponds to the order of the provided addresses.
+// If opts sets Pending, the balances are read from the pending block; the client must implement IPendingContractCaller.
+// If the client can't be reached and the batcher has a Fallback, the balances of the latest block are read from it instead.
 func (b *BalanceBatcher) GetEthBalances(addresses []common.Address, opts *bind.CallOpts) ([]*big.Int, error) {
+	options := newCallOptionsWithBase(opts, b.BaseContext)
+	balances, err := b.getEthBalances(addresses, options)
+	if err == nil || b.Fallback == nil || !canUseFallback(err, options) {
+		return balances, err
+	}
+	balances, fallbackErr := b.Fallback.GetEthBalances(options.ctx, addresses)
+	if fallbackErr != nil {
+		return nil, errors.Join(err, fmt.Errorf("error getting balances from fallback provider: %w", fallbackErr))
+	}
+	if len(balances) != len(addresses) {
+		return nil, fmt.Errorf("fallback provider returned %d balances which mismatches the %d addresses", len(balances), len(addresses))
+	}
+	return balances, nil
+}
+
+// Retrieves the ETH balance for a list of addresses like GetEthBalances, but returns them keyed by address.
+// Repeated addresses are only queried once.
+func (b *BalanceBatcher) GetEthBalancesMap(addresses []common.Address, opts *bind.CallOpts) (map[common.Address]*big.Int, error) {
+	unique := make([]common.Address, 0, len(addresses))
+	seen := make(map[common.Address]bool, len(addresses))
+	for _, address := range addresses {
+		if !seen[address] {
+			seen[address] = true
+			unique = append(unique, address)
+		}
+	}
+	balances, err := b.GetEthBalances(unique, opts)
+	if err != nil {
+		return nil, err
+	}
+	balanceMap := make(map[common.Address]*big.Int, len(unique))
+	for i, address := range unique {
+		balanceMap[address] = balances[i]
+	}
+	return balanceMap, nil
+}
+
+// Implementation of GetEthBalances that only uses the client
+func (b *BalanceBatcher) getEthBalances(addresses []common.Address, options *callOptions) ([]*big.Int, error) {
+	tokens := []common.Address{
+		{}, // Empty token for ETH balance
+	}
+	balances, err := b.getBalances(addresses, tokens, options)
+	if err != nil {
+		return nil, err
+	}
+	ethBalances := make([]*big.Int, len(addresses))
+	for i := range balances {
+		ethBalances[i] = balances[i][0]
+	}
+	return ethBalances, nil
+}
+
+// Retrieves the balances of a list of ERC20 tokens for a list of addresses. The result has an entry for each address in the order they were provided,
+// holding the address's balance of each token in the order they were provided. The zero address can be used as a token to get the ETH balance.
+// Each call reads up to BalanceBatchSize balances, so the number of addresses per call shrinks as more tokens are queried.
+func (b *BalanceBatcher) GetTokenBalances(addresses []common.Address, tokens []common.Address, opts *bind.CallOpts) ([][]*big.Int, error) {
+	if len(tokens) == 0 {
+		return nil, fmt.Errorf("no tokens were provided")
+	}
+	return b.getBalances(addresses, tokens, newCallOptionsWithBase(opts, b.BaseContext))
+}
+
+// Gets the balance of each token for each address, split into calls of up to BalanceBatchSize balances
+func (b *BalanceBatcher) getBalances(addresses []common.Address, tokens []common.Address, options *callOptions) ([][]*big.Int, error) {
 	count := len(addresses)
-	balances := make([]*big.Int, count)
-	var wg errgroup.Group
-	wg.SetLimit(b.ThreadLimit)
-
-	// Run the getters in batches
-	for i := 0; i < count; i += b.BalanceBatchSize {
-		i := i
-		max := i + b.BalanceBatchSize
+	balances := make([][]*big.Int, count)
+	batchSize := b.BalanceBatchSize / len(tokens)
+	if batchSize < 1 {
+		batchSize = 1
+	}
+
+	// A failure in any batch cancels the rest of them
+	err := b.Executor.run(options.ctx, b.ThreadLimit, "eth_call", (count+batchSize-1)/batchSize, nil, func(ctx context.Context, batch int) error {
+		i := batch * batchSize
+		max := i + batchSize
 		if max > count {
 			max = count
 		}
+		subAddresses := addresses[i:max]
+		callData, err := balanceBatcherAbi.Pack("balances", subAddresses, tokens)
+		if err != nil {
+			return fmt.Errorf("error creating calldata for balances: %w", err)
+		}
 
-		wg.Go(func() error {
-			subAddresses := addresses[i:max]
-			tokens := []common.Address{
-				{}, // Empty token for ETH balance
-			}
-			callData, err := balanceBatcherAbi.Pack("balances", subAddresses, tokens)
-			if err != nil {
-				return fmt.Errorf("error creating calldata for balances: %w", err)
-			}
+		// Get the balances
+		callCtx, cancel := withRequestTimeout(ctx, b.DefaultTimeout)
+		defer cancel()
+		response, err := options.callContract(callCtx, b.client, ethereum.CallMsg{From: options.from, To: &b.contractAddress, Data: callData})
+		if err != nil {
+			return fmt.Errorf("error calling balances: %w", wrapClientError(err))
+		}
 
-			// Get the balances
-			var blockNumber *big.Int
-			if opts != nil {
-				blockNumber = opts.BlockNumber
-			}
-			response, err := b.client.CallContract(context.Background(), ethereum.CallMsg{To: &b.contractAddress, Data: callData}, blockNumber)
-			if err != nil {
-				return fmt.Errorf("error calling balances: %w", err)
-			}
+		// Sanity checking and verification
+		var subBalances []*big.Int
+		err = balanceBatcherAbi.UnpackIntoInterface(&subBalances, "balances", response)
+		if err != nil {
+			return fmt.Errorf("error unpacking balances response: %w", wrapUnpackError(err))
+		}
+		if len(subBalances) != len(subAddresses)*len(tokens) {
+			return fmt.Errorf("received %d balances which mismatches query batch size %d", len(subBalances), len(subAddresses)*len(tokens))
+		}
 
-			// Sanity checking and verification
-			var subBalances []*big.Int
-			err = balanceBatcherAbi.UnpackIntoInterface(&subBalances, "balances", response)
-			if err != nil {
-				return fmt.Errorf("error unpacking balances response: %w", err)
-			}
-			if len(subBalances) != len(subAddresses) {
-				return fmt.Errorf("received %d balances which mismatches query batch size %d", len(subBalances), len(subAddresses))
-			}
-			for j, balance := range subBalances {
+		// The balances are ordered by address, then by token
+		for j, address := range subAddresses {
+			addressBalances := subBalances[j*len(tokens) : (j+1)*len(tokens)]
+			for k, balance := range addressBalances {
 				if balance == nil {
-					return fmt.Errorf("received nil balance for address %s", subAddresses[j].String())
+					return fmt.Errorf("received nil balance of token %s for address %s", tokens[k].Hex(), address.Hex())
 				}
-				balances[i+j] = balance
 			}
+			balances[i+j] = addressBalances
+		}
 
-			return nil
-		})
-	}
-
-	err := wg.Wait()
+		return nil
+	})
 	if err != nil {
 		return nil, fmt.Errorf("error getting balances: %w", err)
 	}
@@ -0,0 +1,94 @@
+package batchquery
+
+import (
+	"context"
+	"errors"
+	"math/big"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+)
+
+func TestGetEthBalancesHonorsPending(t *testing.T) {
+	// The mock client can't run calls against the pending block, so a pending query has to fail rather than silently reading the latest block
+	batcher, err := NewBalanceBatcher(&mockClient{}, testTokenAddress, 10, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = batcher.GetEthBalances([]common.Address{testTokenAddress}, &bind.CallOpts{Pending: true})
+	if err == nil || !strings.Contains(err.Error(), "pending") {
+		t.Fatalf("expected the pending query to be routed to the pending block, got %v", err)
+	}
+}
+
+func TestGetEthBalancesMap(t *testing.T) {
+	var calls atomic.Int32
+	client := &mockBalanceCheckerClient{}
+	batcher, err := NewBalanceBatcher(countingContractCaller{client, &calls}, testTokenAddress, 2, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	first := common.HexToAddress("0x01")
+	second := common.HexToAddress("0x02")
+	third := common.HexToAddress("0x03")
+	balances, err := batcher.GetEthBalancesMap([]common.Address{first, second, first, second, third}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(balances) != 3 {
+		t.Fatalf("expected 3 balances, got %d", len(balances))
+	}
+	for _, address := range []common.Address{first, second, third} {
+		if balances[address].Cmp(mockBalance(address, common.Address{})) != 0 {
+			t.Fatalf("unexpected balance %s for %s", balances[address], address.Hex())
+		}
+	}
+
+	// The repeated addresses aren't queried again, so the 3 unique ones fit in 2 calls
+	if calls.Load() != 2 {
+		t.Fatalf("expected 2 calls, got %d", calls.Load())
+	}
+}
+
+// A client that counts the calls to the client it wraps
+type countingContractCaller struct {
+	client IContractCaller
+	calls  *atomic.Int32
+}
+
+func (c countingContractCaller) CallContract(ctx context.Context, msg ethereum.CallMsg, blockNumber *big.Int) ([]byte, error) {
+	c.calls.Add(1)
+	return c.client.CallContract(ctx, msg, blockNumber)
+}
+
+func TestGetEthBalancesAppliesDefaultTimeout(t *testing.T) {
+	// The client never responds, so the query can only finish through the batcher's default deadline
+	client := &mockClient{hook: func(ctx context.Context, msg ethereum.CallMsg) error {
+		<-ctx.Done()
+		return ctx.Err()
+	}}
+	batcher, err := NewBalanceBatcher(client, testTokenAddress, 10, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	batcher.DefaultTimeout = 10 * time.Millisecond
+	_, err = batcher.GetEthBalances([]common.Address{testTokenAddress}, nil)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected the default timeout to cancel the call, got %v", err)
+	}
+
+	// A cancelled base context stops queries whose options don't have their own context
+	baseContext, cancel := context.WithCancel(context.Background())
+	cancel()
+	batcher.BaseContext = baseContext
+	batcher.DefaultTimeout = 0
+	_, err = batcher.GetEthBalances([]common.Address{testTokenAddress}, nil)
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected the base context to cancel the query, got %v", err)
+	}
+}
@@ -0,0 +1,143 @@
+package batchquery
+
+import (
+	"context"
+	"runtime/metrics"
+	"time"
+)
+
+const (
+	// The runtime metrics for the total size and number of heap allocations made by the process
+	allocatedBytesMetric string = "/gc/heap/allocs:bytes"
+	allocationsMetric    string = "/gc/heap/allocs:objects"
+)
+
+// A stage of running a batch
+type batchStage int
+
+const (
+	packStage batchStage = iota
+	executeStage
+	unpackStage
+)
+
+// The cost of a single run of a MultiCaller's batch, broken down by stage, for tracking the overhead of the package and spotting regressions
+type BatchStats struct {
+	// The number of calls in the batch
+	Calls int
+
+	// The number of calls that failed or reverted
+	Failed int
+
+	// The total size of the calls' packed call data, in bytes
+	CallDataBytes int
+
+	// The total size of the calls' return data, in bytes
+	ReturnDataBytes int
+
+	// How long it took to pack the call data of the calls
+	PackDuration time.Duration
+
+	// How long it took to run the calls, including the time spent in the client and reading from the cache
+	ExecuteDuration time.Duration
+
+	// How long it took to unpack the responses into the calls' outputs
+	UnpackDuration time.Duration
+
+	// The number of heap allocations made while the batch ran.
+	// This is read from the Go runtime's process-wide counters, so it includes allocations made by other goroutines at the same time
+	// (such as the client's), and is only exact when nothing else is running.
+	Allocations uint64
+
+	// The total size of the heap allocations made while the batch ran, in bytes, with the same caveats as Allocations
+	AllocatedBytes uint64
+
+	// The error the batch failed with (nil = success)
+	Err error
+}
+
+// Measures the stats of a batch as it runs
+type batchStatsTracker struct {
+	// The hooks to report the stats to
+	hooks *Hooks
+
+	// The stats measured so far
+	stats BatchStats
+
+	// The time the current stage started
+	stageStart time.Time
+
+	// The allocation counters when the batch started
+	samples []metrics.Sample
+}
+
+// Starts measuring the stats of a batch, if a hook is listening for them (nil = stats aren't collected)
+func (mc *MultiCaller) startBatchStats(callCount int) *batchStatsTracker {
+	if mc.Hooks == nil || mc.Hooks.OnBatchStats == nil {
+		return nil
+	}
+	tracker := &batchStatsTracker{
+		hooks: mc.Hooks,
+		stats: BatchStats{
+			Calls: callCount,
+		},
+		samples: []metrics.Sample{
+			{Name: allocatedBytesMetric},
+			{Name: allocationsMetric},
+		},
+	}
+	metrics.Read(tracker.samples)
+	tracker.stageStart = time.Now()
+	return tracker
+}
+
+// Ends the current stage, recording how long it took and starting the next one
+func (t *batchStatsTracker) endStage(stage batchStage) {
+	if t == nil {
+		return
+	}
+	now := time.Now()
+	duration := now.Sub(t.stageStart)
+	t.stageStart = now
+	switch stage {
+	case packStage:
+		t.stats.PackDuration = duration
+	case executeStage:
+		t.stats.ExecuteDuration = duration
+	case unpackStage:
+		t.stats.UnpackDuration = duration
+	}
+}
+
+// Finishes measuring the batch and reports its stats to the hook.
+// The responses may be nil if the batch failed before its calls were run.
+func (t *batchStatsTracker) finish(ctx context.Context, calls []*Call, responses []CallResponse, err error) {
+	if t == nil {
+		return
+	}
+	startBytes := readUint64Sample(t.samples[0])
+	startObjects := readUint64Sample(t.samples[1])
+	metrics.Read(t.samples)
+	t.stats.AllocatedBytes = readUint64Sample(t.samples[0]) - startBytes
+	t.stats.Allocations = readUint64Sample(t.samples[1]) - startObjects
+
+	for _, call := range calls {
+		t.stats.CallDataBytes += len(call.CallData)
+	}
+	for _, response := range responses {
+		if !response.Status {
+			t.stats.Failed++
+		}
+		t.stats.ReturnDataBytes += len(response.ReturnData)
+	}
+	t.stats.Err = err
+	t.hooks.OnBatchStats(ctx, t.stats)
+}
+
+// Reads the value of a runtime metric sample, or 0 if the runtime doesn't support it
+func readUint64Sample(sample metrics.Sample) uint64 {
+	if sample.Value.Kind() != metrics.KindUint64 {
+		return 0
+	}
+	return sample.Value.Uint64()
+}
@@ -0,0 +1,324 @@
+package batchquery
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"time"
+
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+)
+
+// Batch is an immutable snapshot of a MultiCaller's pending calls, with the call data for each one already packed.
+// It can be executed repeatedly, such as at multiple blocks or against multiple endpoints, without re-adding every call.
+// Executing a batch unpacks the responses into the outputs that were provided when the calls were originally added,
+// so the outputs should be read (or copied) between executions.
+type Batch struct {
+	// The packed calls in the batch
+	calls []*Call
+
+	// The details of the run captured by Record(), if there was one
+	recording *batchRecording
+}
+
+// The details of a recorded batch run, used to replay it against other endpoints
+type batchRecording struct {
+	// The address of the multicall contract the batch was run against
+	contractAddress common.Address
+
+	// The block the batch was run at
+	blockNumber *big.Int
+
+	// Whether or not the batch required every call to succeed
+	requireSuccess bool
+
+	// The address the batch was run from (zero = the client's default)
+	from common.Address
+
+	// The raw responses from the run
+	responses []CallResponse
+}
+
+// The results of replaying a recorded batch against another endpoint
+type ReplayResult struct {
+	// The raw response of each call from the replay
+	Responses []CallResponse
+
+	// The indices of the calls whose status or return data differ from the recorded run
+	Mismatches []int
+}
+
+// The JSON representation of a batch
+type batchJson struct {
+	Calls     []batchCallJson     `json:"calls"`
+	Recording *batchRecordingJson `json:"recording,omitempty"`
+}
+
+// The JSON representation of a recorded batch run
+type batchRecordingJson struct {
+	ContractAddress common.Address     `json:"contractAddress"`
+	BlockNumber     *hexutil.Big       `json:"blockNumber"`
+	RequireSuccess  bool               `json:"requireSuccess"`
+	From            *common.Address    `json:"from,omitempty"`
+	Responses       []callResponseJson `json:"responses"`
+}
+
+// The JSON representation of a single recorded response
+type callResponseJson struct {
+	Status     bool          `json:"success"`
+	ReturnData hexutil.Bytes `json:"returnData"`
+}
+
+// The JSON representation of a single call within a batch
+type batchCallJson struct {
+	Target      common.Address `json:"target"`
+	CallData    hexutil.Bytes  `json:"callData"`
+	Method      string         `json:"method,omitempty"`
+	ReturnSize  int            `json:"returnSize,omitempty"`
+	Priority    int            `json:"priority,omitempty"`
+	Timeout     time.Duration  `json:"timeout,omitempty"`
+	GasEstimate uint64         `json:"gasEstimate,omitempty"`
+	BlockNumber *hexutil.Big   `json:"blockNumber,omitempty"`
+	Group       string         `json:"group,omitempty"`
+}
+
+// Creates a copy of the MultiCaller with the same client and settings, and a copy of its pending call list.
+// The copied calls still unpack into the same outputs as the originals.
+func (mc *MultiCaller) Clone() *MultiCaller {
+	return mc.withCalls(copyCalls(mc.calls))
+}
+
+// Packs the MultiCaller's pending calls and captures them in an immutable Batch.
+// The pending calls are left in place, so the MultiCaller can still run them normally.
+func (mc *MultiCaller) Snapshot() (*Batch, error) {
+	calls := copyCalls(mc.calls)
+	err := packCalls(calls)
+	if err != nil {
+		return nil, err
+	}
+
+	// The call data is fixed once the snapshot is taken
+	for _, call := range calls {
+		call.PackFunc = nil
+	}
+	return &Batch{
+		calls: calls,
+	}, nil
+}
+
+// Gets the number of calls in the batch
+func (b *Batch) Len() int {
+	return len(b.calls)
+}
+
+// Gets a copy of the calls in the batch
+func (b *Batch) Calls() []Call {
+	calls := make([]Call, len(b.calls))
+	for i, call := range b.calls {
+		calls[i] = *call
+	}
+	return calls
+}
+
+// Serializes the batch's calls, including their targets, call data, method labels, and chunking hints
+func (b *Batch) MarshalJSON() ([]byte, error) {
+	batch := batchJson{
+		Calls: make([]batchCallJson, len(b.calls)),
+	}
+	for i, call := range b.calls {
+		batch.Calls[i] = batchCallJson{
+			Target:      call.Target,
+			CallData:    call.CallData,
+			Method:      call.Method,
+			ReturnSize:  call.ReturnSize,
+			Priority:    call.Priority,
+			Timeout:     call.Timeout,
+			GasEstimate: call.GasEstimate,
+			BlockNumber: (*hexutil.Big)(call.BlockNumber),
+			Group:       call.Group,
+		}
+	}
+	if b.recording != nil {
+		batch.Recording = &batchRecordingJson{
+			ContractAddress: b.recording.contractAddress,
+			BlockNumber:     (*hexutil.Big)(b.recording.blockNumber),
+			RequireSuccess:  b.recording.requireSuccess,
+			Responses:       make([]callResponseJson, len(b.recording.responses)),
+		}
+		if b.recording.from != (common.Address{}) {
+			from := b.recording.from
+			batch.Recording.From = &from
+		}
+		for i, response := range b.recording.responses {
+			batch.Recording.Responses[i] = callResponseJson{
+				Status:     response.Status,
+				ReturnData: response.ReturnData,
+			}
+		}
+	}
+	return json.Marshal(batch)
+}
+
+// Deserializes a batch that was previously serialized with MarshalJSON.
+// The outputs of the original calls can't be serialized, so executing a deserialized batch only provides the raw responses.
+func (b *Batch) UnmarshalJSON(data []byte) error {
+	var batch batchJson
+	err := json.Unmarshal(data, &batch)
+	if err != nil {
+		return err
+	}
+
+	b.calls = make([]*Call, len(batch.Calls))
+	for i, call := range batch.Calls {
+		b.calls[i] = &Call{
+			Target:      call.Target,
+			CallData:    call.CallData,
+			Method:      call.Method,
+			ReturnSize:  call.ReturnSize,
+			Priority:    call.Priority,
+			Timeout:     call.Timeout,
+			GasEstimate: call.GasEstimate,
+			BlockNumber: call.BlockNumber.ToInt(),
+			Group:       call.Group,
+		}
+	}
+
+	b.recording = nil
+	if batch.Recording != nil {
+		if batch.Recording.BlockNumber == nil {
+			return fmt.Errorf("recorded batch is missing its block number")
+		}
+		if len(batch.Recording.Responses) != len(batch.Calls) {
+			return fmt.Errorf("recorded batch has %d responses which mismatches its %d calls", len(batch.Recording.Responses), len(batch.Calls))
+		}
+		b.recording = &batchRecording{
+			contractAddress: batch.Recording.ContractAddress,
+			blockNumber:     batch.Recording.BlockNumber.ToInt(),
+			requireSuccess:  batch.Recording.RequireSuccess,
+			responses:       make([]CallResponse, len(batch.Recording.Responses)),
+		}
+		if batch.Recording.From != nil {
+			b.recording.from = *batch.Recording.From
+		}
+		for i, response := range batch.Recording.Responses {
+			b.recording.responses[i] = CallResponse{
+				Status:     response.Status,
+				ReturnData: response.ReturnData,
+			}
+		}
+	}
+	return nil
+}
+
+// Runs the batch using the client and settings of the provided MultiCaller, with the same semantics as MultiCaller.FlexibleCall().
+// The MultiCaller's own list of pending calls is not affected.
+func (b *Batch) Execute(caller *MultiCaller, requireSuccess bool, opts *bind.CallOpts) ([]bool, error) {
+	return caller.withCalls(b.calls).FlexibleCall(requireSuccess, opts)
+}
+
+// Runs the batch using the client and settings of the provided MultiCaller, returning the raw response of each call without unpacking it.
+// If the MultiCaller has a Verifier, the responses are verified against proofs first.
+// The MultiCaller's own list of pending calls is not affected.
+func (b *Batch) ExecuteRaw(caller *MultiCaller, requireSuccess bool, opts *bind.CallOpts) ([]CallResponse, error) {
+	runner := caller.withCalls(b.calls)
+	return runner.executeVerified(b.calls, requireSuccess, caller.newCallOptions(opts))
+}
+
+// Runs the batch like ExecuteRaw(), and returns a copy of the batch that records the run's block, multicall contract, sender, and raw responses.
+// If opts doesn't specify a block number, the batch is run against the latest block. Runs against the pending block can't be recorded.
+// The recording is included when the batch is serialized, so it can be replayed against other endpoints later with ReplayAgainst().
+func (b *Batch) Record(caller *MultiCaller, requireSuccess bool, opts *bind.CallOpts) (*Batch, error) {
+	runOpts, err := pinCallOpts(caller, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	responses, err := b.ExecuteRaw(caller, requireSuccess, runOpts)
+	if err != nil {
+		return nil, err
+	}
+	return &Batch{
+		calls: b.calls,
+		recording: &batchRecording{
+			contractAddress: caller.contractAddress,
+			blockNumber:     runOpts.BlockNumber,
+			requireSuccess:  requireSuccess,
+			from:            runOpts.From,
+			responses:       responses,
+		},
+	}, nil
+}
+
+// Gets the block number of the batch's recorded run, or nil if it hasn't been recorded
+func (b *Batch) RecordedBlockNumber() *big.Int {
+	if b.recording == nil {
+		return nil
+	}
+	return new(big.Int).Set(b.recording.blockNumber)
+}
+
+// Gets the raw responses from the batch's recorded run, or nil if it hasn't been recorded
+func (b *Batch) RecordedResponses() []CallResponse {
+	if b.recording == nil {
+		return nil
+	}
+	return append([]CallResponse{}, b.recording.responses...)
+}
+
+// Re-runs a recorded batch against another endpoint, using the same multicall contract and block as the recorded run,
+// and reports any calls whose results differ from the recording. This is useful for debugging discrepancies between providers.
+func (b *Batch) ReplayAgainst(client IContractCaller) (*ReplayResult, error) {
+	if b.recording == nil {
+		return nil, fmt.Errorf("batch has not been recorded")
+	}
+	caller, err := NewMultiCaller(client, b.recording.contractAddress)
+	if err != nil {
+		return nil, err
+	}
+	return b.ReplayWith(caller)
+}
+
+// Re-runs a recorded batch at the same block and from the same sender as the recorded run, using the client and settings of the provided MultiCaller,
+// and reports any calls whose results differ from the recording.
+func (b *Batch) ReplayWith(caller *MultiCaller) (*ReplayResult, error) {
+	if b.recording == nil {
+		return nil, fmt.Errorf("batch has not been recorded")
+	}
+	opts := &bind.CallOpts{
+		BlockNumber: new(big.Int).Set(b.recording.blockNumber),
+		From:        b.recording.from,
+	}
+	responses, err := b.ExecuteRaw(caller, b.recording.requireSuccess, opts)
+	if err != nil {
+		return nil, fmt.Errorf("error replaying batch at block %s: %w", b.recording.blockNumber.String(), err)
+	}
+
+	result := &ReplayResult{
+		Responses:  responses,
+		Mismatches: []int{},
+	}
+	for i, response := range responses {
+		if !responsesMatch(response, b.recording.responses[i]) {
+			result.Mismatches = append(result.Mismatches, i)
+		}
+	}
+	return result, nil
+}
+
+// Checks whether two raw call responses are identical
+func responsesMatch(first CallResponse, second CallResponse) bool {
+	return first.Status == second.Status && bytes.Equal(first.ReturnData, second.ReturnData)
+}
+
+// Creates a shallow copy of each call in a list
+func copyCalls(calls []*Call) []*Call {
+	copies := make([]*Call, len(calls))
+	for i, call := range calls {
+		copied := *call
+		copies[i] = &copied
+	}
+	return copies
+}
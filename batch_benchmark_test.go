@@ -0,0 +1,127 @@
+package batchquery
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// The batch sizes that the benchmark suite runs at
+var benchmarkBatchSizes = []int{1000, 10000, 100000}
+
+// A client that answers every multicall with the same prebuilt response, so the benchmarks measure the package rather than the mock
+type staticResponseClient struct {
+	response []byte
+}
+
+func (c *staticResponseClient) CallContract(ctx context.Context, msg ethereum.CallMsg, blockNumber *big.Int) ([]byte, error) {
+	return c.response, nil
+}
+
+// Creates a MultiCaller with a batch of balanceOf calls, and a client that answers them with a balance for each
+func newBenchmarkBatch(b *testing.B, count int) (*MultiCaller, []*Call, []*big.Int) {
+	balances := make([]*big.Int, count)
+	results := make([]aggregateResult, count)
+	client := &staticResponseClient{}
+	mc, err := NewMultiCaller(client, testMulticallAddress)
+	if err != nil {
+		b.Fatal(err)
+	}
+	for i := range balances {
+		account := common.BigToAddress(big.NewInt(int64(i + 1)))
+		mc.AddCall(testTokenAddress, &testTokenAbi, &balances[i], "balanceOf", account)
+		results[i] = aggregateResult{
+			Success:    true,
+			ReturnData: common.LeftPadBytes(account.Bytes(), wordSize),
+		}
+	}
+	client.response, err = multicallAbi.Methods["tryAggregate"].Outputs.Pack(results)
+	if err != nil {
+		b.Fatal(err)
+	}
+	calls := mc.calls
+	err = packCalls(calls)
+	if err != nil {
+		b.Fatal(err)
+	}
+	return mc, calls, balances
+}
+
+// Runs a benchmark at each of the suite's batch sizes
+func runBatchBenchmarks(b *testing.B, run func(b *testing.B, count int)) {
+	for _, count := range benchmarkBatchSizes {
+		b.Run(fmt.Sprintf("%d", count), func(b *testing.B) {
+			run(b, count)
+		})
+	}
+}
+
+func BenchmarkBatchPack(b *testing.B) {
+	runBatchBenchmarks(b, func(b *testing.B, count int) {
+		_, calls, _ := newBenchmarkBatch(b, count)
+		b.ReportAllocs()
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			err := packCalls(calls)
+			if err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
+
+func BenchmarkBatchExecute(b *testing.B) {
+	runBatchBenchmarks(b, func(b *testing.B, count int) {
+		mc, calls, _ := newBenchmarkBatch(b, count)
+		opts := newCallOptions(nil)
+		b.ReportAllocs()
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			_, err := mc.executeVerified(calls, true, opts)
+			if err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
+
+func BenchmarkBatchUnpack(b *testing.B) {
+	runBatchBenchmarks(b, func(b *testing.B, count int) {
+		mc, calls, _ := newBenchmarkBatch(b, count)
+		responses, err := mc.executeVerified(calls, true, newCallOptions(nil))
+		if err != nil {
+			b.Fatal(err)
+		}
+		b.ReportAllocs()
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			_, err := mc.unpackResponses(calls, responses)
+			if err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
+
+func BenchmarkBatchFlexibleCall(b *testing.B) {
+	runBatchBenchmarks(b, func(b *testing.B, count int) {
+		mc, calls, balances := newBenchmarkBatch(b, count)
+		b.ReportAllocs()
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			mc.calls = append(mc.calls[:0], calls...)
+			_, err := mc.FlexibleCall(true, nil)
+			if err != nil {
+				b.Fatal(err)
+			}
+		}
+		b.StopTimer()
+		if balances[count-1].Cmp(big.NewInt(int64(count))) != 0 {
+			b.Fatalf("unexpected balance %s", balances[count-1])
+		}
+	})
+}
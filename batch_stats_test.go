@@ -0,0 +1,75 @@
+package batchquery
+
+import (
+	"context"
+	"errors"
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+func TestBatchStats(t *testing.T) {
+	mc, _ := newTestMultiCaller(t)
+	var reported []BatchStats
+	mc.Hooks = &Hooks{
+		OnBatchStats: func(ctx context.Context, stats BatchStats) {
+			reported = append(reported, stats)
+		},
+	}
+
+	balances := make([]*big.Int, 20)
+	for i := range balances {
+		mc.AddCall(testTokenAddress, &testTokenAbi, &balances[i], "balanceOf", common.BigToAddress(big.NewInt(int64(i+1))))
+	}
+	mc.AddCall(testTokenAddress, &testTokenAbi, nil, "boom")
+	_, err := mc.FlexibleCall(false, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(reported) != 1 {
+		t.Fatalf("expected 1 report, got %d", len(reported))
+	}
+	stats := reported[0]
+	if stats.Calls != 21 || stats.Failed != 1 || stats.Err != nil {
+		t.Fatalf("unexpected stats %+v", stats)
+	}
+
+	// Each balanceOf call is a selector and an address, and returns a single word
+	if stats.CallDataBytes != 20*(4+wordSize)+4 || stats.ReturnDataBytes < 20*wordSize {
+		t.Fatalf("unexpected sizes %d and %d", stats.CallDataBytes, stats.ReturnDataBytes)
+	}
+	if stats.Allocations == 0 || stats.AllocatedBytes == 0 {
+		t.Fatalf("expected allocations to be counted, got %d (%d bytes)", stats.Allocations, stats.AllocatedBytes)
+	}
+	if stats.PackDuration <= 0 || stats.ExecuteDuration <= 0 || stats.UnpackDuration <= 0 {
+		t.Fatalf("expected every stage to be timed, got %+v", stats)
+	}
+}
+
+func TestBatchStatsReportFailures(t *testing.T) {
+	mc, client := newTestMultiCaller(t)
+	var reported []BatchStats
+	mc.Hooks = &Hooks{
+		OnBatchStats: func(ctx context.Context, stats BatchStats) {
+			reported = append(reported, stats)
+		},
+	}
+
+	// Empty batches aren't reported
+	_, err := mc.FlexibleCall(true, nil)
+	if err != nil || len(reported) != 0 {
+		t.Fatalf("expected an empty batch not to be reported, got %d reports and error %v", len(reported), err)
+	}
+
+	client.err = errors.New("connection refused")
+	var balance *big.Int
+	mc.AddCall(testTokenAddress, &testTokenAbi, &balance, "balanceOf", common.HexToAddress("0x01"))
+	_, err = mc.FlexibleCall(true, nil)
+	if err == nil {
+		t.Fatal("expected the batch to fail")
+	}
+	if len(reported) != 1 || !errors.Is(reported[0].Err, client.err) || reported[0].ReturnDataBytes != 0 {
+		t.Fatalf("unexpected reports %+v", reported)
+	}
+}
@@ -0,0 +1,122 @@
+package batchquery
+
+import (
+	"encoding/json"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+)
+
+func TestBatchJsonRoundTrip(t *testing.T) {
+	mc, _ := newTestMultiCaller(t)
+	var balance *big.Int
+	var list []*big.Int
+	mc.AddCall(testTokenAddress, &testTokenAbi, &balance, "balanceOf", common.HexToAddress("0x0102")).WithPriority(2).WithGasEstimate(30_000).InGroup("balances")
+	mc.AddCall(testTokenAddress, &testTokenAbi, &list, "list", big.NewInt(3)).WithReturnSize(192).WithTimeout(250 * time.Millisecond).AtBlock(big.NewInt(42))
+	batch, err := mc.Snapshot()
+	if err != nil {
+		t.Fatal(err)
+	}
+	recorded, err := batch.Record(mc, false, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := json.Marshal(recorded)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var decoded Batch
+	err = json.Unmarshal(data, &decoded)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	original := recorded.Calls()
+	calls := decoded.Calls()
+	if len(calls) != len(original) {
+		t.Fatalf("expected %d calls, got %d", len(original), len(calls))
+	}
+	for i := range calls {
+		if calls[i].Target != original[i].Target || string(calls[i].CallData) != string(original[i].CallData) || calls[i].Method != original[i].Method ||
+			calls[i].ReturnSize != original[i].ReturnSize || calls[i].Priority != original[i].Priority ||
+			calls[i].Timeout != original[i].Timeout || calls[i].GasEstimate != original[i].GasEstimate ||
+			compareBlocks(calls[i].BlockNumber, original[i].BlockNumber) != 0 || calls[i].Group != original[i].Group {
+			t.Fatalf("call %d changed in the round trip: expected %+v, got %+v", i, original[i], calls[i])
+		}
+	}
+	if decoded.RecordedBlockNumber().Cmp(recorded.RecordedBlockNumber()) != 0 {
+		t.Fatalf("expected recorded block %s, got %s", recorded.RecordedBlockNumber(), decoded.RecordedBlockNumber())
+	}
+	responses := decoded.RecordedResponses()
+	for i, response := range recorded.RecordedResponses() {
+		if !responsesMatch(response, responses[i]) {
+			t.Fatalf("recorded response %d changed in the round trip", i)
+		}
+	}
+
+	// The decoded batch replays cleanly against the same endpoint
+	result, err := decoded.ReplayWith(mc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(result.Mismatches) != 0 {
+		t.Fatalf("expected no mismatches, got %v", result.Mismatches)
+	}
+}
+
+func TestBatchRecordKeepsSender(t *testing.T) {
+	mc, _ := newTestMultiCaller(t)
+	var sender common.Address
+	mc.AddCall(testTokenAddress, &testTokenAbi, &sender, "whoami")
+	batch, err := mc.Snapshot()
+	if err != nil {
+		t.Fatal(err)
+	}
+	from := common.HexToAddress("0x3333333333333333333333333333333333333333")
+	recorded, err := batch.Record(mc, true, &bind.CallOpts{From: from})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := json.Marshal(recorded)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var decoded Batch
+	err = json.Unmarshal(data, &decoded)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Replaying from the multicall contract instead of the recorded sender would change whoami's response
+	result, err := decoded.ReplayWith(mc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(result.Mismatches) != 0 {
+		t.Fatalf("expected the replay to run from the recorded sender, got mismatches %v", result.Mismatches)
+	}
+}
+
+func TestPinnedRunsRejectPendingBlock(t *testing.T) {
+	mc, _ := newTestMultiCaller(t)
+	var balance *big.Int
+	mc.AddCall(testTokenAddress, &testTokenAbi, &balance, "balanceOf", common.HexToAddress("0x05"))
+	batch, err := mc.Snapshot()
+	if err != nil {
+		t.Fatal(err)
+	}
+	opts := &bind.CallOpts{Pending: true}
+	_, err = batch.Record(mc, true, opts)
+	if err == nil {
+		t.Fatal("expected recording a pending run to fail")
+	}
+	_, err = batch.CompareAcross([]*MultiCaller{mc, mc}, true, opts)
+	if err == nil {
+		t.Fatal("expected comparing pending runs to fail")
+	}
+}
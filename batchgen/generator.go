@@ -0,0 +1,360 @@
+// Package batchgen generates typed batch bindings from a contract's ABI.
+// For each view function, the bindings have an AddX method that adds a call to a MultiCaller's batch with compile-time checked arguments and output,
+// and a QueryX method that runs the call on its own. Run it through go:generate with the batchgen command in cmd/batchgen.
+package batchgen
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"go/format"
+	"go/token"
+	"sort"
+	"strconv"
+	"strings"
+	"text/template"
+	"unicode"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+)
+
+// The names used by the generated methods themselves, which the arguments of a function can't use
+var reservedNames = map[string]bool{
+	"b":      true,
+	"mc":     true,
+	"opts":   true,
+	"output": true,
+	"call":   true,
+}
+
+// The settings for generating bindings
+type Config struct {
+	// The name of the package the bindings are generated in
+	Package string
+
+	// The name of the contract, which the generated types are named after (such as "Token" for TokenBatch)
+	Type string
+
+	// The ABI of the contract, in JSON
+	Abi []byte
+}
+
+// The data the bindings template is rendered with
+type bindingData struct {
+	// The name of the package
+	Package string
+
+	// The name of the contract
+	Type string
+
+	// The name of the variable holding the parsed ABI
+	AbiVar string
+
+	// The ABI as a quoted Go string
+	AbiJson string
+
+	// The standard library packages the bindings import
+	StdImports []string
+
+	// The other packages the bindings import
+	Imports []string
+
+	// The structs for the tuples the functions take or return, in the order they were found
+	Structs []structData
+
+	// The view functions of the contract, sorted by name
+	Methods []methodData
+}
+
+// A view function in the bindings
+type methodData struct {
+	// The Go name of the function
+	Name string
+
+	// The name of the function in the ABI, which is unique even if the function is overloaded
+	AbiName string
+
+	// The signature of the function
+	Signature string
+
+	// The arguments of the function
+	Inputs []argData
+
+	// The return values of the function
+	Outputs []argData
+
+	// The Go type of the output
+	OutputType string
+
+	// Whether the function has several return values, which are unpacked into the fields of a struct
+	Multi bool
+}
+
+// A struct for a tuple
+type structData struct {
+	// The Go name of the struct
+	Name string
+
+	// The fields of the struct
+	Fields []argData
+}
+
+// An argument or return value
+type argData struct {
+	// The Go name of the value
+	Name string
+
+	// The Go type of the value
+	Type string
+}
+
+// Generates the source code of typed batch bindings for the view functions in a contract's ABI
+func Generate(config Config) ([]byte, error) {
+	if !token.IsIdentifier(config.Package) {
+		return nil, fmt.Errorf("invalid package name '%s'", config.Package)
+	}
+	if !token.IsIdentifier(config.Type) || !token.IsExported(config.Type) {
+		return nil, fmt.Errorf("invalid type name '%s'; it must be an exported identifier", config.Type)
+	}
+	contractAbi, err := abi.JSON(bytes.NewReader(config.Abi))
+	if err != nil {
+		return nil, fmt.Errorf("error parsing ABI: %w", err)
+	}
+	var compact bytes.Buffer
+	err = json.Compact(&compact, config.Abi)
+	if err != nil {
+		return nil, fmt.Errorf("error compacting ABI: %w", err)
+	}
+
+	data := bindingData{
+		Package: config.Package,
+		Type:    config.Type,
+		AbiVar:  lowerFirst(config.Type) + "Abi",
+		AbiJson: strconv.Quote(compact.String()),
+	}
+	names := make([]string, 0, len(contractAbi.Methods))
+	for name, method := range contractAbi.Methods {
+		if method.IsConstant() && len(method.Outputs) > 0 {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	mapper := &typeMapper{
+		prefix:  config.Type,
+		structs: map[string]bool{},
+	}
+	for _, name := range names {
+		method := contractAbi.Methods[name]
+		methodData := methodData{
+			Name:      abi.ToCamelCase(name),
+			AbiName:   name,
+			Signature: method.Sig,
+			Multi:     len(method.Outputs) > 1,
+		}
+		for i, input := range method.Inputs {
+			argName := argName(input.Name, i)
+			methodData.Inputs = append(methodData.Inputs, argData{
+				Name: argName,
+				Type: mapper.goType(input.Type, methodData.Name+abi.ToCamelCase(argName)),
+			})
+		}
+		for i, output := range method.Outputs {
+			fieldName := abi.ToCamelCase(output.Name)
+			if fieldName == "" {
+				fieldName = fmt.Sprintf("Value%d", i)
+			}
+			structName := methodData.Name + "Output"
+			if methodData.Multi {
+				structName = methodData.Name + fieldName
+			}
+			methodData.Outputs = append(methodData.Outputs, argData{
+				Name: fieldName,
+				Type: mapper.goType(output.Type, structName),
+			})
+		}
+		if methodData.Multi {
+			methodData.OutputType = config.Type + methodData.Name + "Output"
+		} else {
+			methodData.OutputType = methodData.Outputs[0].Type
+		}
+		data.Methods = append(data.Methods, methodData)
+	}
+	data.Structs = mapper.order
+	data.StdImports, data.Imports = getImports(mapper.usesBig, len(data.Methods) > 0)
+
+	var source bytes.Buffer
+	err = bindingTemplate.Execute(&source, data)
+	if err != nil {
+		return nil, fmt.Errorf("error rendering bindings: %w", err)
+	}
+	formatted, err := format.Source(source.Bytes())
+	if err != nil {
+		return nil, fmt.Errorf("error formatting bindings: %w", err)
+	}
+	return formatted, nil
+}
+
+// Gets the Go name of a function argument, renaming it if it's unnamed or would clash with a keyword or one of the generated names
+func argName(name string, index int) string {
+	if name == "" {
+		return fmt.Sprintf("arg%d", index)
+	}
+	name = lowerFirst(abi.ToCamelCase(name))
+	if token.IsKeyword(name) || reservedNames[name] {
+		return name + "Arg"
+	}
+	return name
+}
+
+// Gets the standard library packages and the other packages the bindings need to import
+func getImports(usesBig bool, hasMethods bool) ([]string, []string) {
+	stdImports := []string{"strings"}
+	imports := []string{"github.com/ethereum/go-ethereum/accounts/abi", "github.com/ethereum/go-ethereum/common"}
+	if !hasMethods {
+		return stdImports, imports
+	}
+	if usesBig {
+		stdImports = []string{"math/big", "strings"}
+	}
+	imports = []string{
+		"github.com/ethereum/go-ethereum/accounts/abi",
+		"github.com/ethereum/go-ethereum/accounts/abi/bind",
+		"github.com/ethereum/go-ethereum/common",
+		"github.com/rocket-pool/batch-query",
+	}
+	return stdImports, imports
+}
+
+// Maps ABI types to Go types, creating named structs for tuples
+type typeMapper struct {
+	// The prefix of the struct names, which is the name of the contract
+	prefix string
+
+	// The names of the structs that have been created
+	structs map[string]bool
+
+	// The structs that have been created, in order
+	order []structData
+
+	// Whether any of the types use big.Int
+	usesBig bool
+}
+
+// Gets the Go type for an ABI type.
+// Tuples are given a struct named after the tuple's internal type in the ABI (such as VaultKey for "struct Vault.Key"), or after the contract and the provided fallback name if it has none.
+func (m *typeMapper) goType(typ abi.Type, fallbackName string) string {
+	switch typ.T {
+	case abi.SliceTy:
+		return "[]" + m.goType(*typ.Elem, fallbackName)
+	case abi.ArrayTy:
+		return fmt.Sprintf("[%d]%s", typ.Size, m.goType(*typ.Elem, fallbackName))
+	case abi.TupleTy:
+		name := m.prefix + fallbackName
+		if typ.TupleRawName != "" {
+			name = abi.ToCamelCase(typ.TupleRawName)
+		}
+		if m.structs[name] {
+			return name
+		}
+		m.structs[name] = true
+		structData := structData{
+			Name: name,
+		}
+		for i, elem := range typ.TupleElems {
+			fieldName := abi.ToCamelCase(typ.TupleRawNames[i])
+			structData.Fields = append(structData.Fields, argData{
+				Name: fieldName,
+				Type: m.goType(*elem, fallbackName+fieldName),
+			})
+		}
+		m.order = append(m.order, structData)
+		return name
+	default:
+		goType := typ.GetType().String()
+		if strings.Contains(goType, "big.") {
+			m.usesBig = true
+		}
+		return goType
+	}
+}
+
+// Lowercases the first letter of a name
+func lowerFirst(name string) string {
+	if name == "" {
+		return name
+	}
+	runes := []rune(name)
+	runes[0] = unicode.ToLower(runes[0])
+	return string(runes)
+}
+
+// The template for the bindings
+var bindingTemplate = template.Must(template.New("bindings").Parse(`// Code generated by batchgen. DO NOT EDIT.
+
+package {{.Package}}
+
+import (
+{{range .StdImports}}	"{{.}}"
+{{end}}
+{{range .Imports}}{{if eq . "github.com/rocket-pool/batch-query"}}	batchquery "{{.}}"
+{{else}}	"{{.}}"
+{{end}}{{end}})
+
+// The ABI of the {{.Type}} contract
+const {{.Type}}AbiJson = {{.AbiJson}}
+
+// The parsed ABI of the {{.Type}} contract
+var {{.AbiVar}} = func() abi.ABI {
+	parsed, err := abi.JSON(strings.NewReader({{.Type}}AbiJson))
+	if err != nil {
+		panic(err)
+	}
+	return parsed
+}()
+
+// {{.Type}}Batch adds calls to the view functions of a {{.Type}} contract to a MultiCaller's batch
+type {{.Type}}Batch struct {
+	// The address of the contract
+	Address common.Address
+}
+
+// Creates a new {{.Type}}Batch for the contract at the provided address
+func New{{.Type}}Batch(address common.Address) *{{.Type}}Batch {
+	return &{{.Type}}Batch{
+		Address: address,
+	}
+}
+{{range .Structs}}
+// {{.Name}} holds the values of a tuple in the {{$.Type}} contract's ABI
+type {{.Name}} struct {
+{{range .Fields}}	{{.Name}} {{.Type}}
+{{end}}}
+{{end}}{{range .Methods}}{{if .Multi}}
+// The return values of {{.Signature}}
+type {{.OutputType}} struct {
+{{range .Outputs}}	{{.Name}} {{.Type}}
+{{end}}}
+{{end}}
+// Adds a call to {{.Signature}} to the batch, which unpacks its result into output
+func (b *{{$.Type}}Batch) Add{{.Name}}(mc *batchquery.MultiCaller, output *{{.OutputType}}{{range .Inputs}}, {{.Name}} {{.Type}}{{end}}) *batchquery.Call {
+	call := mc.AddCall(b.Address, &{{$.AbiVar}}, output, "{{.AbiName}}"{{range .Inputs}}, {{.Name}}{{end}})
+	call.UnpackFunc = func(data []byte) error {
+		values, err := {{$.AbiVar}}.Unpack("{{.AbiName}}", data)
+		if err != nil {
+			return err
+		}
+{{if .Multi}}{{range $i, $output := .Outputs}}		output.{{$output.Name}} = *abi.ConvertType(values[{{$i}}], new({{$output.Type}})).(*{{$output.Type}})
+{{end}}{{else}}		*output = *abi.ConvertType(values[0], new({{.OutputType}})).(*{{.OutputType}})
+{{end}}		return nil
+	}
+	return call
+}
+
+// Runs {{.Signature}} on its own with the client and settings of the provided MultiCaller, and returns its result
+func (b *{{$.Type}}Batch) Query{{.Name}}(mc *batchquery.MultiCaller, opts *bind.CallOpts{{range .Inputs}}, {{.Name}} {{.Type}}{{end}}) ({{.OutputType}}, error) {
+	return batchquery.QueryCall(mc, opts, func(mc *batchquery.MultiCaller, output *{{.OutputType}}) *batchquery.Call {
+		return b.Add{{.Name}}(mc, output{{range .Inputs}}, {{.Name}}{{end}})
+	})
+}
+{{end}}`))
@@ -0,0 +1,86 @@
+package batchgen
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestGeneratedBindingsAreUpToDate(t *testing.T) {
+	dir := filepath.Join("internal", "testtoken")
+	abiJson, err := os.ReadFile(filepath.Join(dir, "token.abi.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected, err := os.ReadFile(filepath.Join(dir, "token-batch.go"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	source, err := Generate(Config{
+		Package: "testtoken",
+		Type:    "Token",
+		Abi:     abiJson,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(source, expected) {
+		t.Fatal("the generated test bindings are out of date; run go generate ./batchgen/...")
+	}
+}
+
+func TestGenerateNamesTuplesAndArguments(t *testing.T) {
+	abiJson := `[
+		{"inputs":[{"name":"range","type":"uint256"},{"name":"","type":"address"},{"components":[{"name":"id","type":"uint64"}],"internalType":"struct Vault.Key","name":"key","type":"tuple"}],"name":"lookup","outputs":[{"components":[{"name":"owner","type":"address"},{"components":[{"name":"id","type":"uint64"}],"internalType":"struct Vault.Key[]","name":"keys","type":"tuple[]"}],"internalType":"struct Vault.Entry","name":"","type":"tuple"}],"stateMutability":"view","type":"function"},
+		{"inputs":[],"name":"deposit","outputs":[],"stateMutability":"payable","type":"function"}
+	]`
+	source, err := Generate(Config{
+		Package: "vault",
+		Type:    "Vault",
+		Abi:     []byte(abiJson),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	code := string(source)
+	for _, expected := range []string{
+		"type VaultKey struct",
+		"type VaultEntry struct",
+		"Keys  []VaultKey",
+		"func (b *VaultBatch) AddLookup(mc *batchquery.MultiCaller, output *VaultEntry, rangeArg *big.Int, arg1 common.Address, key VaultKey) *batchquery.Call",
+		"func (b *VaultBatch) QueryLookup(mc *batchquery.MultiCaller, opts *bind.CallOpts, rangeArg *big.Int, arg1 common.Address, key VaultKey) (VaultEntry, error)",
+	} {
+		if !strings.Contains(code, expected) {
+			t.Fatalf("expected the bindings to contain %q:\n%s", expected, code)
+		}
+	}
+	if strings.Contains(code, "Deposit") {
+		t.Fatal("expected functions that change state to be skipped")
+	}
+}
+
+func TestGenerateRejectsInvalidConfig(t *testing.T) {
+	_, err := Generate(Config{Package: "token", Type: "token", Abi: []byte("[]")})
+	if err == nil {
+		t.Fatal("expected an unexported type name to be rejected")
+	}
+	_, err = Generate(Config{Package: "token-bindings", Type: "Token", Abi: []byte("[]")})
+	if err == nil {
+		t.Fatal("expected an invalid package name to be rejected")
+	}
+	_, err = Generate(Config{Package: "token", Type: "Token", Abi: []byte("{")})
+	if err == nil {
+		t.Fatal("expected an invalid ABI to be rejected")
+	}
+
+	// An ABI without view functions still produces code that compiles
+	source, err := Generate(Config{Package: "token", Type: "Token", Abi: []byte("[]")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(source), "batchquery") {
+		t.Fatalf("expected no batch-query import without any view functions:\n%s", source)
+	}
+}
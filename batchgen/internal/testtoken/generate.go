@@ -0,0 +1,4 @@
+// Package testtoken holds bindings generated by batchgen for a test token contract, which check that the generated code compiles and runs
+package testtoken
+
+//go:generate go run ../../../cmd/batchgen -abi token.abi.json -type Token -out token-batch.go
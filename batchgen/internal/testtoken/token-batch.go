@@ -0,0 +1,137 @@
+// Code generated by batchgen. DO NOT EDIT.
+
+package testtoken
+
+import (
+	"math/big"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	batchquery "github.com/rocket-pool/batch-query"
+)
+
+// The ABI of the Token contract
+const TokenAbiJson = "[{\"inputs\":[{\"name\":\"account\",\"type\":\"address\"}],\"name\":\"balanceOf\",\"outputs\":[{\"name\":\"\",\"type\":\"uint256\"}],\"stateMutability\":\"view\",\"type\":\"function\"},{\"inputs\":[],\"name\":\"decimals\",\"outputs\":[{\"name\":\"\",\"type\":\"uint8\"}],\"stateMutability\":\"view\",\"type\":\"function\"},{\"inputs\":[],\"name\":\"getReserves\",\"outputs\":[{\"name\":\"reserve0\",\"type\":\"uint112\"},{\"name\":\"reserve1\",\"type\":\"uint112\"},{\"name\":\"\",\"type\":\"uint32\"}],\"stateMutability\":\"view\",\"type\":\"function\"},{\"inputs\":[{\"name\":\"owner\",\"type\":\"address\"},{\"name\":\"type\",\"type\":\"uint8\"}],\"name\":\"position\",\"outputs\":[{\"components\":[{\"name\":\"amount\",\"type\":\"uint256\"},{\"name\":\"label\",\"type\":\"string\"}],\"name\":\"\",\"type\":\"tuple\"}],\"stateMutability\":\"view\",\"type\":\"function\"},{\"inputs\":[{\"name\":\"to\",\"type\":\"address\"},{\"name\":\"amount\",\"type\":\"uint256\"}],\"name\":\"transfer\",\"outputs\":[{\"name\":\"\",\"type\":\"bool\"}],\"stateMutability\":\"nonpayable\",\"type\":\"function\"}]"
+
+// The parsed ABI of the Token contract
+var tokenAbi = func() abi.ABI {
+	parsed, err := abi.JSON(strings.NewReader(TokenAbiJson))
+	if err != nil {
+		panic(err)
+	}
+	return parsed
+}()
+
+// TokenBatch adds calls to the view functions of a Token contract to a MultiCaller's batch
+type TokenBatch struct {
+	// The address of the contract
+	Address common.Address
+}
+
+// Creates a new TokenBatch for the contract at the provided address
+func NewTokenBatch(address common.Address) *TokenBatch {
+	return &TokenBatch{
+		Address: address,
+	}
+}
+
+// TokenPositionOutput holds the values of a tuple in the Token contract's ABI
+type TokenPositionOutput struct {
+	Amount *big.Int
+	Label  string
+}
+
+// Adds a call to balanceOf(address) to the batch, which unpacks its result into output
+func (b *TokenBatch) AddBalanceOf(mc *batchquery.MultiCaller, output **big.Int, account common.Address) *batchquery.Call {
+	call := mc.AddCall(b.Address, &tokenAbi, output, "balanceOf", account)
+	call.UnpackFunc = func(data []byte) error {
+		values, err := tokenAbi.Unpack("balanceOf", data)
+		if err != nil {
+			return err
+		}
+		*output = *abi.ConvertType(values[0], new(*big.Int)).(**big.Int)
+		return nil
+	}
+	return call
+}
+
+// Runs balanceOf(address) on its own with the client and settings of the provided MultiCaller, and returns its result
+func (b *TokenBatch) QueryBalanceOf(mc *batchquery.MultiCaller, opts *bind.CallOpts, account common.Address) (*big.Int, error) {
+	return batchquery.QueryCall(mc, opts, func(mc *batchquery.MultiCaller, output **big.Int) *batchquery.Call {
+		return b.AddBalanceOf(mc, output, account)
+	})
+}
+
+// Adds a call to decimals() to the batch, which unpacks its result into output
+func (b *TokenBatch) AddDecimals(mc *batchquery.MultiCaller, output *uint8) *batchquery.Call {
+	call := mc.AddCall(b.Address, &tokenAbi, output, "decimals")
+	call.UnpackFunc = func(data []byte) error {
+		values, err := tokenAbi.Unpack("decimals", data)
+		if err != nil {
+			return err
+		}
+		*output = *abi.ConvertType(values[0], new(uint8)).(*uint8)
+		return nil
+	}
+	return call
+}
+
+// Runs decimals() on its own with the client and settings of the provided MultiCaller, and returns its result
+func (b *TokenBatch) QueryDecimals(mc *batchquery.MultiCaller, opts *bind.CallOpts) (uint8, error) {
+	return batchquery.QueryCall(mc, opts, func(mc *batchquery.MultiCaller, output *uint8) *batchquery.Call {
+		return b.AddDecimals(mc, output)
+	})
+}
+
+// The return values of getReserves()
+type TokenGetReservesOutput struct {
+	Reserve0 *big.Int
+	Reserve1 *big.Int
+	Value2   uint32
+}
+
+// Adds a call to getReserves() to the batch, which unpacks its result into output
+func (b *TokenBatch) AddGetReserves(mc *batchquery.MultiCaller, output *TokenGetReservesOutput) *batchquery.Call {
+	call := mc.AddCall(b.Address, &tokenAbi, output, "getReserves")
+	call.UnpackFunc = func(data []byte) error {
+		values, err := tokenAbi.Unpack("getReserves", data)
+		if err != nil {
+			return err
+		}
+		output.Reserve0 = *abi.ConvertType(values[0], new(*big.Int)).(**big.Int)
+		output.Reserve1 = *abi.ConvertType(values[1], new(*big.Int)).(**big.Int)
+		output.Value2 = *abi.ConvertType(values[2], new(uint32)).(*uint32)
+		return nil
+	}
+	return call
+}
+
+// Runs getReserves() on its own with the client and settings of the provided MultiCaller, and returns its result
+func (b *TokenBatch) QueryGetReserves(mc *batchquery.MultiCaller, opts *bind.CallOpts) (TokenGetReservesOutput, error) {
+	return batchquery.QueryCall(mc, opts, func(mc *batchquery.MultiCaller, output *TokenGetReservesOutput) *batchquery.Call {
+		return b.AddGetReserves(mc, output)
+	})
+}
+
+// Adds a call to position(address,uint8) to the batch, which unpacks its result into output
+func (b *TokenBatch) AddPosition(mc *batchquery.MultiCaller, output *TokenPositionOutput, owner common.Address, typeArg uint8) *batchquery.Call {
+	call := mc.AddCall(b.Address, &tokenAbi, output, "position", owner, typeArg)
+	call.UnpackFunc = func(data []byte) error {
+		values, err := tokenAbi.Unpack("position", data)
+		if err != nil {
+			return err
+		}
+		*output = *abi.ConvertType(values[0], new(TokenPositionOutput)).(*TokenPositionOutput)
+		return nil
+	}
+	return call
+}
+
+// Runs position(address,uint8) on its own with the client and settings of the provided MultiCaller, and returns its result
+func (b *TokenBatch) QueryPosition(mc *batchquery.MultiCaller, opts *bind.CallOpts, owner common.Address, typeArg uint8) (TokenPositionOutput, error) {
+	return batchquery.QueryCall(mc, opts, func(mc *batchquery.MultiCaller, output *TokenPositionOutput) *batchquery.Call {
+		return b.AddPosition(mc, output, owner, typeArg)
+	})
+}
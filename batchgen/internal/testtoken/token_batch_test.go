@@ -0,0 +1,137 @@
+package testtoken
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+	"strings"
+	"testing"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	batchquery "github.com/rocket-pool/batch-query"
+)
+
+const testMulticallAbiString = `[{"inputs":[{"name":"requireSuccess","type":"bool"},{"components":[{"name":"target","type":"address"},{"name":"callData","type":"bytes"}],"name":"calls","type":"tuple[]"}],"name":"tryAggregate","outputs":[{"components":[{"name":"success","type":"bool"},{"name":"returnData","type":"bytes"}],"name":"returnData","type":"tuple[]"}],"stateMutability":"nonpayable","type":"function"}]`
+
+var (
+	testMulticallAddress = common.HexToAddress("0x1111111111111111111111111111111111111111")
+	testTokenAddress     = common.HexToAddress("0x2222222222222222222222222222222222222222")
+	testMulticallAbi     = mustParseAbi(testMulticallAbiString)
+)
+
+// Parses an ABI, panicking on failure
+func mustParseAbi(abiString string) abi.ABI {
+	parsed, err := abi.JSON(strings.NewReader(abiString))
+	if err != nil {
+		panic(err)
+	}
+	return parsed
+}
+
+// A client that emulates Multicall2's tryAggregate function and the test token.
+// A balance is the account's address as a number, and a position's amount is its type.
+type mockClient struct{}
+
+// Runs a call against the test token
+func (m *mockClient) runTokenCall(data []byte) []byte {
+	method, err := tokenAbi.MethodById(data[:4])
+	if err != nil {
+		panic(err)
+	}
+	args, err := method.Inputs.Unpack(data[4:])
+	if err != nil {
+		panic(err)
+	}
+	var out []byte
+	switch method.Name {
+	case "balanceOf":
+		out, err = method.Outputs.Pack(args[0].(common.Address).Big())
+	case "decimals":
+		out, err = method.Outputs.Pack(uint8(18))
+	case "getReserves":
+		out, err = method.Outputs.Pack(big.NewInt(100), big.NewInt(200), uint32(300))
+	case "position":
+		position := struct {
+			Amount *big.Int
+			Label  string
+		}{big.NewInt(int64(args[1].(uint8))), fmt.Sprintf("position of %s", args[0].(common.Address).Hex())}
+		out, err = method.Outputs.Pack(position)
+	}
+	if err != nil {
+		panic(err)
+	}
+	return out
+}
+
+func (m *mockClient) CallContract(ctx context.Context, msg ethereum.CallMsg, blockNumber *big.Int) ([]byte, error) {
+	method := testMulticallAbi.Methods["tryAggregate"]
+	args, err := method.Inputs.Unpack(msg.Data[4:])
+	if err != nil {
+		return nil, err
+	}
+	var calls []struct {
+		Target   common.Address
+		CallData []byte
+	}
+	abi.ConvertType(args[1], &calls)
+
+	type result struct {
+		Success    bool
+		ReturnData []byte
+	}
+	results := make([]result, len(calls))
+	for i, call := range calls {
+		results[i] = result{true, m.runTokenCall(call.CallData)}
+	}
+	return method.Outputs.Pack(results)
+}
+
+func TestGeneratedBindings(t *testing.T) {
+	mc, err := batchquery.NewMultiCaller(&mockClient{}, testMulticallAddress)
+	if err != nil {
+		t.Fatal(err)
+	}
+	token := NewTokenBatch(testTokenAddress)
+	account := common.HexToAddress("0x05")
+
+	var balance *big.Int
+	var decimals uint8
+	var reserves TokenGetReservesOutput
+	var position TokenPositionOutput
+	token.AddBalanceOf(mc, &balance, account)
+	token.AddDecimals(mc, &decimals)
+	token.AddGetReserves(mc, &reserves)
+	token.AddPosition(mc, &position, account, 7)
+	_, err = mc.FlexibleCall(true, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if balance.Cmp(account.Big()) != 0 || decimals != 18 {
+		t.Fatalf("unexpected balance %s and decimals %d", balance, decimals)
+	}
+	if reserves.Reserve0.Int64() != 100 || reserves.Reserve1.Int64() != 200 || reserves.Value2 != 300 {
+		t.Fatalf("unexpected reserves %+v", reserves)
+	}
+	if position.Amount.Int64() != 7 || position.Label != "position of "+account.Hex() {
+		t.Fatalf("unexpected position %+v", position)
+	}
+
+	// Queries run on their own, without touching the batch
+	token.AddDecimals(mc, &decimals)
+	queried, err := token.QueryPosition(mc, nil, account, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if queried.Amount.Int64() != 3 {
+		t.Fatalf("unexpected queried position %+v", queried)
+	}
+	successes, err := mc.FlexibleCall(true, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(successes) != 1 {
+		t.Fatalf("expected the query not to affect the pending batch, got %d results", len(successes))
+	}
+}
@@ -0,0 +1,110 @@
+package batchquery
+
+import (
+	"bytes"
+	"encoding/gob"
+	"encoding/json"
+	"fmt"
+
+	"github.com/ethereum/go-ethereum/common/hexutil"
+)
+
+// A serialization format for the cached results that the caches store outside of memory.
+// Each result holds a call's status and the raw return data its outputs are decoded from, rather than the decoded outputs themselves:
+// the caches sit below unpacking and are keyed by call data, so the same entry serves every caller of that call whatever Go types
+// they unpack into, and a cache hit goes through the same unpacking (and unpack errors) as a fresh response.
+type ICacheCodec interface {
+	// Encodes a result for storage
+	Encode(response CallResponse) ([]byte, error)
+
+	// Decodes a stored result
+	Decode(value []byte) (CallResponse, error)
+}
+
+// A cached result in the self-describing codecs
+type cacheCodecEntry struct {
+	Status     bool          `json:"status"`
+	ReturnData hexutil.Bytes `json:"returnData"`
+}
+
+// The most compact codec, which stores a status byte followed by the raw ABI-encoded return data. This is the default.
+type RawCacheCodec struct{}
+
+// Encodes a result as a status byte followed by its return data
+func (RawCacheCodec) Encode(response CallResponse) ([]byte, error) {
+	value := make([]byte, 1, 1+len(response.ReturnData))
+	if response.Status {
+		value[0] = 1
+	}
+	return append(value, response.ReturnData...), nil
+}
+
+// Decodes a status byte followed by the return data.
+// The return data is copied, since some caches only keep the value valid for a short time.
+func (RawCacheCodec) Decode(value []byte) (CallResponse, error) {
+	if len(value) == 0 {
+		return CallResponse{}, fmt.Errorf("cached result is empty")
+	}
+	return CallResponse{
+		Status:     value[0] == 1,
+		ReturnData: append([]byte{}, value[1:]...),
+	}, nil
+}
+
+// A codec that stores results with Go's gob encoding, for caches only read by Go services
+type GobCacheCodec struct{}
+
+// Encodes a result with gob
+func (GobCacheCodec) Encode(response CallResponse) ([]byte, error) {
+	var buffer bytes.Buffer
+	err := gob.NewEncoder(&buffer).Encode(cacheCodecEntry{
+		Status:     response.Status,
+		ReturnData: response.ReturnData,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error encoding cached result with gob: %w", err)
+	}
+	return buffer.Bytes(), nil
+}
+
+// Decodes a gob-encoded result
+func (GobCacheCodec) Decode(value []byte) (CallResponse, error) {
+	var entry cacheCodecEntry
+	err := gob.NewDecoder(bytes.NewReader(value)).Decode(&entry)
+	if err != nil {
+		return CallResponse{}, fmt.Errorf("error decoding cached result with gob: %w", err)
+	}
+	return CallResponse{
+		Status:     entry.Status,
+		ReturnData: entry.ReturnData,
+	}, nil
+}
+
+// A codec that stores results as JSON objects with a "status" flag and the "returnData" as a hex string,
+// so services in other languages can read the cache without any special tooling
+type JsonCacheCodec struct{}
+
+// Encodes a result as JSON
+func (JsonCacheCodec) Encode(response CallResponse) ([]byte, error) {
+	value, err := json.Marshal(cacheCodecEntry{
+		Status:     response.Status,
+		ReturnData: response.ReturnData,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error encoding cached result with JSON: %w", err)
+	}
+	return value, nil
+}
+
+// Decodes a JSON-encoded result
+func (JsonCacheCodec) Decode(value []byte) (CallResponse, error) {
+	var entry cacheCodecEntry
+	err := json.Unmarshal(value, &entry)
+	if err != nil {
+		return CallResponse{}, fmt.Errorf("error decoding cached result with JSON: %w", err)
+	}
+	return CallResponse{
+		Status:     entry.Status,
+		ReturnData: entry.ReturnData,
+	}, nil
+}
@@ -0,0 +1,84 @@
+package batchquery
+
+import (
+	"fmt"
+	"math/big"
+	"sync"
+
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+)
+
+// The results of warming a cache
+type CacheWarmReport struct {
+	// The block the batches were run at
+	BlockNumber *big.Int
+
+	// The total number of calls in the batches
+	Calls int
+
+	// The number of calls that succeeded, and whose results are now cached
+	Cached int
+}
+
+// This struct pre-populates a MultiCaller's cache by running a set of registered batches, such as the queries a service
+// will serve, at startup before it starts taking traffic, so the first requests after a deploy don't pay the cost of a cold cache.
+type CacheWarmer struct {
+	// The MultiCaller whose cache is warmed
+	caller *MultiCaller
+
+	// The registered batches
+	batches []*Batch
+
+	// Lock for the registered batches
+	lock sync.Mutex
+}
+
+// Creates a new CacheWarmer instance for the provided MultiCaller, which must have a Cache
+func NewCacheWarmer(caller *MultiCaller) *CacheWarmer {
+	return &CacheWarmer{
+		caller:  caller,
+		batches: []*Batch{},
+	}
+}
+
+// Registers a batch to run whenever the cache is warmed, such as one captured from a MultiCaller with Snapshot()
+func (w *CacheWarmer) Register(batch *Batch) {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	w.batches = append(w.batches, batch)
+}
+
+// Runs every registered batch at the block in opts, so their results are stored in the MultiCaller's cache.
+// If opts doesn't specify a block, the batches are pinned to the latest one, which the report includes so the service can serve from it.
+// Calls that fail aren't cached, but don't fail the warming either; the MultiCaller's pending calls are not affected.
+func (w *CacheWarmer) Warm(opts *bind.CallOpts) (*CacheWarmReport, error) {
+	if w.caller.Cache == nil {
+		return nil, fmt.Errorf("the MultiCaller doesn't have a cache to warm")
+	}
+	pinned, err := pinCallOpts(w.caller, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	w.lock.Lock()
+	batches := make([]*Batch, len(w.batches))
+	copy(batches, w.batches)
+	w.lock.Unlock()
+
+	report := &CacheWarmReport{
+		BlockNumber: pinned.BlockNumber,
+	}
+	for i, batch := range batches {
+		responses, err := batch.ExecuteRaw(w.caller, false, pinned)
+		if err != nil {
+			return nil, fmt.Errorf("error warming cache with batch %d: %w", i, err)
+		}
+		report.Calls += len(responses)
+		for _, response := range responses {
+			if response.Status {
+				report.Cached++
+			}
+		}
+	}
+	return report, nil
+}
@@ -0,0 +1,223 @@
+package batchquery
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math/big"
+	"sync"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+// The key of a cached call result
+type CacheKey struct {
+	// The ID of the chain the call ran on
+	ChainID uint64
+
+	// The block the call ran at
+	BlockNumber uint64
+
+	// The contract address of the call's target
+	Target common.Address
+
+	// The packed call data
+	CallData []byte
+
+	// Whether the call targeted the latest block rather than an explicit one; these entries are invalidated when a new head is observed
+	Latest bool
+}
+
+// A cache of raw call results, which the MultiCaller checks before running a batch so calls it has already seen don't hit the client again.
+// Implementations must be safe to use from multiple goroutines.
+type IResultCache interface {
+	// Gets the cached response for a call, and whether there was one
+	Get(key CacheKey) (CallResponse, bool, error)
+
+	// Stores the response for a call
+	Set(key CacheKey, response CallResponse) error
+}
+
+// A result cache that can store many responses at once, which is much faster for caches that write each update to disk
+type IBatchResultCache interface {
+	// Stores the response for each call
+	SetAll(keys []CacheKey, responses []CallResponse) error
+}
+
+// A result cache that can drop the entries stored for the latest block
+type ILatestResultCache interface {
+	// Removes every entry whose key is for the latest block
+	InvalidateLatest() error
+}
+
+// A cache policy for calls against the latest block: they're run at the latest head the policy has observed and cached until it observes a new one,
+// at which point the entries are invalidated. Entries for explicit historical blocks are unaffected and kept for as long as the cache keeps them.
+// Register it with a HeadFeed by setting it as the LatestCache of the feed's MultiCaller, or call ObserveHead from another source of new heads.
+type LatestCachePolicy struct {
+	// The cache whose entries for the latest block are invalidated
+	cache IResultCache
+
+	// The latest head observed (nil = none yet, so calls against the latest block aren't cached)
+	head *big.Int
+
+	// Lock for the head
+	lock sync.RWMutex
+}
+
+// Gets the canonical encoding of the key: the chain ID and block number (big-endian, so keys sort by chain then block), the target, and the hash of the call data.
+// Keys for the latest block have an extra trailing byte, so they're distinct from the keys of the same block queried explicitly.
+func (k CacheKey) Bytes() []byte {
+	encoded := make([]byte, 0, 8+8+common.AddressLength+common.HashLength+1)
+	encoded = binary.BigEndian.AppendUint64(encoded, k.ChainID)
+	encoded = binary.BigEndian.AppendUint64(encoded, k.BlockNumber)
+	encoded = append(encoded, k.Target.Bytes()...)
+	encoded = append(encoded, crypto.Keccak256(k.CallData)...)
+	if k.Latest {
+		encoded = append(encoded, 1)
+	}
+	return encoded
+}
+
+// Creates a new LatestCachePolicy for the provided cache, which should be the Cache of the MultiCallers that use the policy
+func NewLatestCachePolicy(cache IResultCache) *LatestCachePolicy {
+	return &LatestCachePolicy{
+		cache: cache,
+	}
+}
+
+// Records a new head. If it's a different block than the previous head, the cache's entries for the latest block are invalidated
+// (if it implements ILatestResultCache; otherwise they're simply never looked up again).
+func (p *LatestCachePolicy) ObserveHead(blockNumber *big.Int) error {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	if p.head != nil && p.head.Cmp(blockNumber) == 0 {
+		return nil
+	}
+	p.head = new(big.Int).Set(blockNumber)
+	invalidator, ok := p.cache.(ILatestResultCache)
+	if !ok {
+		return nil
+	}
+	err := invalidator.InvalidateLatest()
+	if err != nil {
+		return fmt.Errorf("error invalidating cached results for the latest block: %w", err)
+	}
+	return nil
+}
+
+// Gets the latest head the policy has observed, or nil if it hasn't observed one yet
+func (p *LatestCachePolicy) Head() *big.Int {
+	p.lock.RLock()
+	defer p.lock.RUnlock()
+	if p.head == nil {
+		return nil
+	}
+	return new(big.Int).Set(p.head)
+}
+
+// Pins options that target the latest block to the head observed by the MultiCaller's LatestCache, so their results can be cached.
+// Returns the options to run the calls with, and whether they were pinned.
+func (mc *MultiCaller) pinToObservedHead(opts *callOptions) (*callOptions, bool) {
+	if mc.LatestCache == nil || opts.blockNumber != nil || opts.blockHash != nil || opts.pending || opts.from != (common.Address{}) {
+		return opts, false
+	}
+	head := mc.LatestCache.Head()
+	if head == nil {
+		return opts, false
+	}
+	pinned := *opts
+	pinned.blockNumber = head
+	return &pinned, true
+}
+
+// Gets the cache key for a call, and whether the call's result can be cached at all.
+// Only calls against an explicit block number can be cached, since the results at the latest or pending block change over time,
+// and calls with a sender are excluded since the sender can change the result.
+func (mc *MultiCaller) cacheKey(call *Call, opts *callOptions) (CacheKey, bool) {
+	callOpts := call.targetOptions(opts)
+	if callOpts.blockNumber == nil || callOpts.blockHash != nil || callOpts.pending || callOpts.from != (common.Address{}) {
+		return CacheKey{}, false
+	}
+	return CacheKey{
+		ChainID:     mc.ChainID,
+		BlockNumber: callOpts.blockNumber.Uint64(),
+		Target:      call.Target,
+		CallData:    call.CallData,
+	}, true
+}
+
+// Runs the calls like executeUncached, but serves the ones with cached results from the MultiCaller's Cache and only runs the rest.
+// If the MultiCaller has a LatestCache, batches against the latest block are run at the head it observed so they can be cached too.
+// Successful responses are stored in the cache afterwards; failures aren't, since a call reported as failed may only have run out of time.
+func (mc *MultiCaller) executeCached(calls []*Call, requireSuccess bool, opts *callOptions) ([]CallResponse, error) {
+	opts, latest := mc.pinToObservedHead(opts)
+	responses := make([]CallResponse, len(calls))
+	keys := make([]CacheKey, len(calls))
+	cacheable := make([]bool, len(calls))
+	missIndices := make([]int, 0, len(calls))
+	misses := make([]*Call, 0, len(calls))
+	for i, call := range calls {
+		keys[i], cacheable[i] = mc.cacheKey(call, opts)
+		keys[i].Latest = latest && call.BlockNumber == nil
+		if cacheable[i] {
+			response, found, err := mc.Cache.Get(keys[i])
+			if err != nil {
+				return nil, fmt.Errorf("error reading cached result of call %d: %w", i, err)
+			}
+			if found {
+				responses[i] = response
+				continue
+			}
+		}
+		missIndices = append(missIndices, i)
+		misses = append(misses, call)
+	}
+	if len(misses) == 0 {
+		return responses, nil
+	}
+
+	// Run the calls that weren't cached
+	missResponses, err := mc.executeUncached(misses, requireSuccess, opts)
+	if err != nil {
+		// Report reverts by their index within the whole batch
+		var reverted *ErrCallReverted
+		if errors.As(err, &reverted) && reverted.Index >= 0 && reverted.Index < len(missIndices) {
+			reverted.Index = missIndices[reverted.Index]
+		}
+		return nil, err
+	}
+	newKeys := make([]CacheKey, 0, len(misses))
+	newResponses := make([]CallResponse, 0, len(misses))
+	for j, i := range missIndices {
+		response := CallResponse{
+			Status:     missResponses[j].Status,
+			ReturnData: common.CopyBytes(missResponses[j].ReturnData),
+		}
+		responses[i] = response
+		if cacheable[i] && response.Status {
+			newKeys = append(newKeys, keys[i])
+			newResponses = append(newResponses, response)
+		}
+	}
+	if len(newKeys) == 0 {
+		return responses, nil
+	}
+
+	// Store the new results
+	batchCache, ok := mc.Cache.(IBatchResultCache)
+	if ok {
+		err = batchCache.SetAll(newKeys, newResponses)
+	} else {
+		for i, key := range newKeys {
+			err = mc.Cache.Set(key, newResponses[i])
+			if err != nil {
+				break
+			}
+		}
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error caching results: %w", err)
+	}
+	return responses, nil
+}
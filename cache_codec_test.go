@@ -0,0 +1,78 @@
+package batchquery
+
+import (
+	"bytes"
+	"encoding/json"
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+)
+
+func TestCacheCodecsRoundTrip(t *testing.T) {
+	codecs := map[string]ICacheCodec{
+		"raw":  RawCacheCodec{},
+		"gob":  GobCacheCodec{},
+		"json": JsonCacheCodec{},
+	}
+	responses := []CallResponse{
+		{Status: true, ReturnData: []byte{1, 2, 3}},
+		{Status: false, ReturnData: []byte{4}},
+	}
+	for name, codec := range codecs {
+		for _, response := range responses {
+			value, err := codec.Encode(response)
+			if err != nil {
+				t.Fatalf("%s: %s", name, err)
+			}
+			decoded, err := codec.Decode(value)
+			if err != nil {
+				t.Fatalf("%s: %s", name, err)
+			}
+			if decoded.Status != response.Status || !bytes.Equal(decoded.ReturnData, response.ReturnData) {
+				t.Fatalf("%s: expected %+v, got %+v", name, response, decoded)
+			}
+		}
+	}
+}
+
+func TestJsonCacheCodecIsSelfDescribing(t *testing.T) {
+	value, err := JsonCacheCodec{}.Encode(CallResponse{Status: true, ReturnData: []byte{5, 6}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Readers without the Go types see a plain map
+	var entry map[string]interface{}
+	err = json.Unmarshal(value, &entry)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if entry["status"] != true || entry["returnData"] != "0x0506" {
+		t.Fatalf("unexpected JSON entry: %v", entry)
+	}
+}
+
+func TestSharedCacheWithCodec(t *testing.T) {
+	cache := NewSharedResultCache(newMapCache())
+	cache.Codec = JsonCacheCodec{}
+	opts := &bind.CallOpts{BlockNumber: big.NewInt(50)}
+	account := common.HexToAddress("0x0102")
+	for i := 0; i < 2; i++ {
+		mc, client := newTestMultiCaller(t)
+		mc.Cache = cache
+		var balance *big.Int
+		mc.AddCall(testTokenAddress, &testTokenAbi, &balance, "balanceOf", account)
+		_, err := mc.FlexibleCall(true, opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if balance.Cmp(expectedBalance(account, 50)) != 0 {
+			t.Fatalf("expected balance %s, got %s", expectedBalance(account, 50), balance)
+		}
+		if i == 1 && client.calls != 0 {
+			t.Fatalf("expected the cached run not to query the client, got %d eth_calls", client.calls)
+		}
+	}
+}
@@ -0,0 +1,131 @@
+package batchquery
+
+import (
+	"errors"
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+)
+
+func TestLatestCachePolicy(t *testing.T) {
+	mc, client := newTestMultiCaller(t)
+	cache := NewMemoryCache(100, 0)
+	mc.Cache = cache
+	mc.LatestCache = NewLatestCachePolicy(cache)
+	account := common.HexToAddress("0x0102")
+	var balance *big.Int
+	getBalance := func(opts *bind.CallOpts) {
+		mc.AddCall(testTokenAddress, &testTokenAbi, &balance, "balanceOf", account)
+		_, err := mc.FlexibleCall(true, opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Until a head is observed, calls against the latest block aren't cached
+	getBalance(nil)
+	getBalance(nil)
+	if client.calls != 2 || cache.Stats().Entries != 0 {
+		t.Fatalf("expected latest calls not to be cached without a head, got %d eth_calls", client.calls)
+	}
+
+	// Once one is, they run at that head and are cached until the next
+	err := mc.LatestCache.ObserveHead(big.NewInt(60))
+	if err != nil {
+		t.Fatal(err)
+	}
+	getBalance(nil)
+	getBalance(nil)
+	if client.calls != 3 || balance.Cmp(expectedBalance(account, 60)) != 0 {
+		t.Fatalf("expected one eth_call at the observed head, got %d eth_calls and balance %s", client.calls-2, balance)
+	}
+
+	// A historical entry for the same block is kept separately, and survives new heads
+	getBalance(&bind.CallOpts{BlockNumber: big.NewInt(60)})
+	if client.calls != 4 || cache.Stats().Entries != 2 {
+		t.Fatalf("expected the explicit block to be cached separately, got %d eth_calls and %+v", client.calls, cache.Stats())
+	}
+	err = mc.LatestCache.ObserveHead(big.NewInt(61))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cache.Stats().Entries != 1 {
+		t.Fatalf("expected the latest entry to be invalidated, got %+v", cache.Stats())
+	}
+	getBalance(nil)
+	getBalance(&bind.CallOpts{BlockNumber: big.NewInt(60)})
+	if client.calls != 5 || balance.Cmp(expectedBalance(account, 60)) != 0 {
+		t.Fatalf("expected only the latest call to run again, got %d eth_calls", client.calls-4)
+	}
+}
+
+func TestCacheKeyBytes(t *testing.T) {
+	key := testCacheKey(1)
+	latestKey := key
+	latestKey.Latest = true
+	if len(key.Bytes()) != 68 || len(latestKey.Bytes()) != 69 {
+		t.Fatalf("unexpected key sizes %d and %d", len(key.Bytes()), len(latestKey.Bytes()))
+	}
+
+	// Keys sort by chain, then block
+	if string(testCacheKey(1).Bytes()) >= string(testCacheKey(256).Bytes()) {
+		t.Fatal("expected keys to sort by block")
+	}
+}
+
+func TestCacheSkipsLatestBlockAndFailures(t *testing.T) {
+	mc, client := newTestMultiCaller(t)
+	mc.Cache = NewMemoryCache(100, 0)
+	var balance *big.Int
+	for i := 0; i < 2; i++ {
+		mc.AddCall(testTokenAddress, &testTokenAbi, &balance, "balanceOf", common.Address{})
+		_, err := mc.FlexibleCall(true, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	if client.calls != 2 {
+		t.Fatalf("expected calls against the latest block not to be cached, got %d eth_calls", client.calls)
+	}
+
+	opts := &bind.CallOpts{BlockNumber: big.NewInt(50)}
+	for i := 0; i < 2; i++ {
+		mc.AddCall(testTokenAddress, &testTokenAbi, nil, "boom")
+		successes, err := mc.FlexibleCall(false, opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if successes[0] {
+			t.Fatal("expected the call to fail")
+		}
+	}
+	if client.calls != 4 {
+		t.Fatalf("expected failed calls not to be cached, got %d eth_calls", client.calls-2)
+	}
+}
+
+func TestCacheReportsRevertsByBatchIndex(t *testing.T) {
+	mc, client := newTestMultiCaller(t)
+	mc.Cache = NewMemoryCache(100, 0)
+	opts := &bind.CallOpts{BlockNumber: big.NewInt(50)}
+	var balance *big.Int
+	mc.AddCall(testTokenAddress, &testTokenAbi, &balance, "balanceOf", common.Address{})
+	_, err := mc.FlexibleCall(true, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Only the uncached call runs, but its revert is reported by its place in the batch
+	mc.AddCall(testTokenAddress, &testTokenAbi, &balance, "balanceOf", common.Address{})
+	mc.AddCall(testTokenAddress, &testTokenAbi, nil, "boom")
+	_, err = mc.FlexibleCall(true, opts)
+	var reverted *ErrCallReverted
+	if !errors.As(err, &reverted) || reverted.Index != 1 || reverted.Method != "boom" {
+		t.Fatalf("expected call 1 to revert, got %v", err)
+	}
+	if client.chunkSizes[len(client.chunkSizes)-1] != 1 {
+		t.Fatalf("expected only the uncached call to run, got a chunk of %d", client.chunkSizes[len(client.chunkSizes)-1])
+	}
+}
@@ -0,0 +1,57 @@
+package batchquery
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+)
+
+func TestCacheWarmer(t *testing.T) {
+	mc, client := newTestMultiCaller(t)
+	cache := NewMemoryCache(100, 0)
+	mc.Cache = cache
+	account := common.HexToAddress("0x0102")
+	var balance *big.Int
+	mc.AddCall(testTokenAddress, &testTokenAbi, &balance, "balanceOf", account)
+	mc.AddCall(testTokenAddress, &testTokenAbi, nil, "boom")
+	batch, err := mc.Snapshot()
+	if err != nil {
+		t.Fatal(err)
+	}
+	mc.calls = []*Call{}
+
+	warmer := NewCacheWarmer(mc)
+	warmer.Register(batch)
+	report, err := warmer.Warm(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.BlockNumber.Int64() != 100 || report.Calls != 2 || report.Cached != 1 {
+		t.Fatalf("unexpected report: %+v", report)
+	}
+	if len(mc.calls) != 0 {
+		t.Fatal("expected the pending calls not to be affected")
+	}
+
+	// Queries at the warmed block are served from the cache
+	calls := client.calls
+	mc.AddCall(testTokenAddress, &testTokenAbi, &balance, "balanceOf", account)
+	_, err = mc.FlexibleCall(true, &bind.CallOpts{BlockNumber: report.BlockNumber})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if client.calls != calls || balance.Cmp(expectedBalance(account, 100)) != 0 {
+		t.Fatalf("expected the balance to be served from the warmed cache, got %s after %d eth_calls", balance, client.calls-calls)
+	}
+}
+
+func TestCacheWarmerRequiresCache(t *testing.T) {
+	mc, _ := newTestMultiCaller(t)
+	mc.Cache = nil
+	_, err := NewCacheWarmer(mc).Warm(nil)
+	if err == nil {
+		t.Fatal("expected a MultiCaller without a cache to be rejected")
+	}
+}
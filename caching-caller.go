@@ -0,0 +1,93 @@
+package batchquery
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// An IContractCaller that serves eth_call responses from a result cache and only sends the calls it hasn't seen before to the client it wraps,
+// so code that calls contracts directly (such as abigen bindings) benefits from the cache without any changes.
+// Responses are cached by target, call data and block; calls that set a sender, value, gas or gas price aren't cached, since those can change the result.
+// Only CallContract is wrapped, so clients wrapped this way don't expose their other capabilities (such as calls at a block hash) to the batchers.
+type CachingContractCaller struct {
+	// The ID of the chain the client is connected to, which keeps the cache entries of different chains apart
+	ChainID uint64
+
+	// The head tracker used to cache calls against the latest block, which are run at the observed head instead (nil = don't cache calls against the latest block)
+	LatestCache *LatestCachePolicy
+
+	// The client to send uncached calls to
+	client IContractCaller
+
+	// The cache of responses
+	cache IResultCache
+}
+
+// Creates a new CachingContractCaller instance that caches the responses of the provided client
+func NewCachingContractCaller(client IContractCaller, cache IResultCache) *CachingContractCaller {
+	return &CachingContractCaller{
+		client: client,
+		cache:  cache,
+	}
+}
+
+// Calls a contract function, serving the response from the cache if it's there and storing it in the cache otherwise.
+// Reverted calls return an error and aren't cached.
+func (c *CachingContractCaller) CallContract(ctx context.Context, call ethereum.CallMsg, blockNumber *big.Int) ([]byte, error) {
+	latest := false
+	if blockNumber == nil && c.LatestCache != nil {
+		blockNumber = c.LatestCache.Head()
+		latest = blockNumber != nil
+	}
+	if !isCacheableCallMsg(call, blockNumber) {
+		return c.client.CallContract(ctx, call, blockNumber)
+	}
+
+	key := CacheKey{
+		ChainID:     c.ChainID,
+		BlockNumber: blockNumber.Uint64(),
+		Target:      *call.To,
+		CallData:    call.Data,
+		Latest:      latest,
+	}
+	response, found, err := c.cache.Get(key)
+	if err != nil {
+		return nil, fmt.Errorf("error reading cached result: %w", err)
+	}
+	if found && response.Status {
+		return common.CopyBytes(response.ReturnData), nil
+	}
+
+	data, err := c.client.CallContract(ctx, call, blockNumber)
+	if err != nil {
+		return nil, err
+	}
+	err = c.cache.Set(key, CallResponse{
+		Status:     true,
+		ReturnData: common.CopyBytes(data),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error caching result: %w", err)
+	}
+	return data, nil
+}
+
+// Checks whether the response to a call can be cached, which requires an explicit block and a call with nothing but a target and call data
+func isCacheableCallMsg(call ethereum.CallMsg, blockNumber *big.Int) bool {
+	if blockNumber == nil || blockNumber.Sign() < 0 || call.To == nil {
+		return false
+	}
+	if call.From != (common.Address{}) || call.Gas != 0 || len(call.AccessList) > 0 {
+		return false
+	}
+	for _, value := range []*big.Int{call.Value, call.GasPrice, call.GasFeeCap, call.GasTipCap} {
+		if value != nil && value.Sign() != 0 {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,97 @@
+package batchquery
+
+import (
+	"context"
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+)
+
+func TestCachingContractCaller(t *testing.T) {
+	client := &mockClient{}
+	caller := NewCachingContractCaller(client, NewMemoryCache(100, 0))
+	account := common.HexToAddress("0x0102")
+	data, err := testTokenAbi.Pack("balanceOf", account)
+	if err != nil {
+		t.Fatal(err)
+	}
+	getBalance := func(msg ethereum.CallMsg, blockNumber *big.Int) *big.Int {
+		response, err := caller.CallContract(context.Background(), msg, blockNumber)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return new(big.Int).SetBytes(response)
+	}
+	msg := ethereum.CallMsg{To: &testTokenAddress, Data: data}
+
+	for i := 0; i < 2; i++ {
+		balance := getBalance(msg, big.NewInt(50))
+		if balance.Cmp(expectedBalance(account, 50)) != 0 {
+			t.Fatalf("expected balance %s, got %s", expectedBalance(account, 50), balance)
+		}
+	}
+	if client.calls != 1 {
+		t.Fatalf("expected the second call to be served from the cache, got %d eth_calls", client.calls)
+	}
+
+	// Other blocks, the latest block and calls with a sender aren't served from the entry
+	getBalance(msg, big.NewInt(51))
+	getBalance(msg, nil)
+	getBalance(msg, nil)
+	withSender := msg
+	withSender.From = account
+	getBalance(withSender, big.NewInt(50))
+	if client.calls != 5 {
+		t.Fatalf("expected 4 more eth_calls, got %d", client.calls-1)
+	}
+}
+
+func TestCachingContractCallerLatestBlock(t *testing.T) {
+	client := &mockClient{}
+	cache := NewMemoryCache(100, 0)
+	caller := NewCachingContractCaller(client, cache)
+	caller.LatestCache = NewLatestCachePolicy(cache)
+	caller.LatestCache.ObserveHead(big.NewInt(60))
+	account := common.HexToAddress("0x0102")
+	data, _ := testTokenAbi.Pack("balanceOf", account)
+	msg := ethereum.CallMsg{To: &testTokenAddress, Data: data}
+	for i := 0; i < 2; i++ {
+		response, err := caller.CallContract(context.Background(), msg, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		balance := new(big.Int).SetBytes(response)
+		if balance.Cmp(expectedBalance(account, 60)) != 0 {
+			t.Fatalf("expected the balance at the observed head, got %s", balance)
+		}
+	}
+	if client.calls != 1 {
+		t.Fatalf("expected calls against the latest block to be cached at the observed head, got %d eth_calls", client.calls)
+	}
+}
+
+func TestCachingContractCallerWithMultiCaller(t *testing.T) {
+	client := &mockClient{}
+	mc, err := NewMultiCaller(NewCachingContractCaller(client, NewMemoryCache(100, 0)), testMulticallAddress)
+	if err != nil {
+		t.Fatal(err)
+	}
+	account := common.HexToAddress("0x0102")
+	for i := 0; i < 2; i++ {
+		var balance *big.Int
+		mc.AddCall(testTokenAddress, &testTokenAbi, &balance, "balanceOf", account)
+		_, err = mc.FlexibleCall(true, &bind.CallOpts{BlockNumber: big.NewInt(50)})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if balance.Cmp(expectedBalance(account, 50)) != 0 {
+			t.Fatalf("expected balance %s, got %s", expectedBalance(account, 50), balance)
+		}
+	}
+	if client.calls != 1 {
+		t.Fatalf("expected the repeated multicall to be served from the cache, got %d eth_calls", client.calls)
+	}
+}
@@ -0,0 +1,96 @@
+package batchquery
+
+import (
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+)
+
+// The results of the calls in a single named group of a batch
+type GroupResult struct {
+	// The index of each of the group's calls within the batch, in the order they were added
+	Indices []int
+
+	// The success flag of each of the group's calls, in the same order as Indices
+	Successes []bool
+
+	// The number of the group's calls that succeeded
+	SuccessCount int
+
+	// The failures of the group's calls whose responses couldn't be unpacked, if there were any (nil = no failures)
+	Err *MultiError
+}
+
+// Checks whether every call in the group succeeded and was unpacked
+func (r *GroupResult) AllSucceeded() bool {
+	return r.Err == nil && r.SuccessCount == len(r.Successes)
+}
+
+// Assigns the call to a named group, so its result is reported with the rest of the group by FlexibleCallByGroup.
+// Calls that aren't assigned to a group belong to the unnamed group ("").
+func (c *Call) InGroup(name string) *Call {
+	c.Group = name
+	return c
+}
+
+// Invokes all of the previously batched up contract calls like FlexibleCall, but returns the results organized by the calls' groups.
+// This lets one run serve several independent queries built by different components, each of which only reads its own group.
+// A call whose response can't be unpacked is reported through its group's Err rather than failing the whole batch,
+// so one group's failures don't affect the others. An error is only returned if the batch itself fails.
+// Upon completion, the internal list of batched up contract calls will be cleared.
+func (mc *MultiCaller) FlexibleCallByGroup(requireSuccess bool, opts *bind.CallOpts) (map[string]*GroupResult, error) {
+	return mc.flexibleCallByGroup(requireSuccess, mc.newCallOptions(opts))
+}
+
+// Implementation of FlexibleCallByGroup
+func (mc *MultiCaller) flexibleCallByGroup(requireSuccess bool, opts *callOptions) (map[string]*GroupResult, error) {
+	groups := map[string]*GroupResult{}
+	if len(mc.calls) == 0 {
+		mc.settleSubBatches(nil, nil, nil, nil)
+		return groups, nil
+	}
+
+	// Create the CallData for each call
+	calls := mc.calls
+	err := packCalls(calls)
+	if err != nil {
+		mc.settleSubBatches(nil, nil, nil, err)
+		return nil, err
+	}
+
+	// Run the calls
+	responses, err := mc.executeVerified(calls, requireSuccess, opts)
+	if err != nil {
+		mc.settleSubBatches(nil, nil, nil, err)
+		return nil, err
+	}
+	mc.calls = []*Call{}
+
+	// Unpack the responses into each call's group
+	unpackErrs := make([]error, len(calls))
+	for i, call := range calls {
+		group, exists := groups[call.Group]
+		if !exists {
+			group = &GroupResult{}
+			groups[call.Group] = group
+		}
+		group.Indices = append(group.Indices, i)
+		group.Successes = append(group.Successes, responses[i].Status)
+		if responses[i].Status {
+			group.SuccessCount++
+		}
+		err := call.unpackResponse(responses[i])
+		unpackErrs[i] = err
+		if err != nil {
+			if group.Err == nil {
+				group.Err = &MultiError{}
+			}
+			group.Err.Errors = append(group.Err.Errors, &CallError{
+				Index:  i,
+				Target: call.Target,
+				Method: call.Method,
+				Err:    err,
+			})
+		}
+	}
+	mc.settleSubBatches(calls, responses, unpackErrs, nil)
+	return groups, nil
+}
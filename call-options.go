@@ -0,0 +1,167 @@
+package batchquery
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+	"time"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// The options for running a batch, which extend bind.CallOpts with the package's own block targeting
+type callOptions struct {
+	// The context for the calls
+	ctx context.Context
+
+	// The block number to run the calls at (nil = latest)
+	blockNumber *big.Int
+
+	// The hash of the block to run the calls at, which takes precedence over the block number (nil = use the block number)
+	blockHash *common.Hash
+
+	// Whether to run the calls against the pending block, which takes precedence over the block number
+	pending bool
+
+	// The address to run the calls from (zero = the client's default)
+	from common.Address
+
+	// The gas limit for each chunk's eth_call, overriding the MultiCaller's GasLimit (0 = use the MultiCaller's)
+	gasLimit uint64
+}
+
+// The context key for the block hash stored by CallOptsAtHash
+type blockHashKey struct{}
+
+// Creates a copy of the binding options that targets the block with the provided hash (EIP-1898) in every batcher of this package.
+// bind.CallOpts in go-ethereum v1.12 has no BlockHash field, so the hash is carried in the copy's context; its block number and Pending flag are cleared.
+// Storage, code and proofs are read at the hash too, through JSON-RPC batch requests or the client's IStorageReaderAtHash, ICodeReaderAtHash and IProofGetterAtHash
+// implementations; clients that can't read them at a hash fail instead of silently reading another block.
+func CallOptsAtHash(opts *bind.CallOpts, blockHash common.Hash) *bind.CallOpts {
+	var hashOpts bind.CallOpts
+	if opts != nil {
+		hashOpts = *opts
+	}
+	ctx := hashOpts.Context
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	hashOpts.Context = context.WithValue(ctx, blockHashKey{}, blockHash)
+	hashOpts.BlockNumber = nil
+	hashOpts.Pending = false
+	return &hashOpts
+}
+
+// Creates call options from a set of binding options, which may be nil
+func newCallOptions(opts *bind.CallOpts) *callOptions {
+	return newCallOptionsWithBase(opts, nil)
+}
+
+// Creates call options from a set of binding options, which may be nil, using the base context if they don't provide one (nil = context.Background())
+func newCallOptionsWithBase(opts *bind.CallOpts, baseContext context.Context) *callOptions {
+	if baseContext == nil {
+		baseContext = context.Background()
+	}
+	options := &callOptions{
+		ctx: baseContext,
+	}
+	if opts != nil {
+		options.blockNumber = opts.BlockNumber
+		options.from = opts.From
+		options.pending = opts.Pending
+		if opts.Context != nil {
+			options.ctx = opts.Context
+		}
+	}
+	if blockHash, ok := options.ctx.Value(blockHashKey{}).(common.Hash); ok {
+		options.blockHash = &blockHash
+		options.pending = false
+	}
+	return options
+}
+
+// Runs an eth_call against the block targeted by the options
+func (o *callOptions) callContract(ctx context.Context, client IContractCaller, msg ethereum.CallMsg) ([]byte, error) {
+	if o.blockHash != nil {
+		hashCaller, ok := client.(IContractCallerAtHash)
+		if !ok {
+			return nil, fmt.Errorf("client does not support calls at a block hash")
+		}
+		return hashCaller.CallContractAtHash(ctx, msg, *o.blockHash)
+	}
+	if o.pending {
+		pendingCaller, ok := client.(IPendingContractCaller)
+		if !ok {
+			return nil, fmt.Errorf("client does not support calls against the pending block")
+		}
+		return pendingCaller.PendingCallContract(ctx, msg)
+	}
+	return client.CallContract(ctx, msg, o.blockNumber)
+}
+
+// Reads a storage slot from the block targeted by the options
+func (o *callOptions) storageAt(ctx context.Context, client IStorageReader, slot StorageSlot) ([]byte, error) {
+	if o.blockHash != nil {
+		hashReader, ok := client.(IStorageReaderAtHash)
+		if !ok {
+			return nil, fmt.Errorf("client does not support reading storage at a block hash")
+		}
+		return hashReader.StorageAtHash(ctx, slot.Address, slot.Slot, *o.blockHash)
+	}
+	if o.pending {
+		pendingReader, ok := client.(IPendingStorageReader)
+		if !ok {
+			return nil, fmt.Errorf("client does not support reading storage from the pending block")
+		}
+		return pendingReader.PendingStorageAt(ctx, slot.Address, slot.Slot)
+	}
+	return client.StorageAt(ctx, slot.Address, slot.Slot, o.blockNumber)
+}
+
+// Reads an account's code from the block targeted by the options, which can't be the pending block
+func (o *callOptions) codeAt(ctx context.Context, client ICodeReader, account common.Address) ([]byte, error) {
+	if o.blockHash != nil {
+		hashReader, ok := client.(ICodeReaderAtHash)
+		if !ok {
+			return nil, fmt.Errorf("client does not support reading code at a block hash")
+		}
+		return hashReader.CodeAtHash(ctx, account, *o.blockHash)
+	}
+	return client.CodeAt(ctx, account, o.blockNumber)
+}
+
+// Gets the proofs for an account and some of its storage slots from the block targeted by the options, which can't be the pending block
+func (o *callOptions) getProof(ctx context.Context, client IProofGetter, account common.Address, keys []string) (*AccountProof, error) {
+	if o.blockHash != nil {
+		hashGetter, ok := client.(IProofGetterAtHash)
+		if !ok {
+			return nil, fmt.Errorf("client does not support getting proofs at a block hash")
+		}
+		return hashGetter.GetProofAtHash(ctx, account, keys, *o.blockHash)
+	}
+	return client.GetProof(ctx, account, keys, o.blockNumber)
+}
+
+// Gets a description of the block targeted by the options, for error messages
+func (o *callOptions) blockDescription() string {
+	if o.blockHash != nil {
+		return o.blockHash.Hex()
+	}
+	if o.pending {
+		return "pending"
+	}
+	if o.blockNumber != nil {
+		return o.blockNumber.String()
+	}
+	return "latest"
+}
+
+// Derives the context for a single outbound request, which is cancelled after the timeout (0 = no deadline beyond the one in ctx)
+func withRequestTimeout(ctx context.Context, timeout time.Duration) (context.Context, context.CancelFunc) {
+	if timeout <= 0 {
+		return ctx, func() {}
+	}
+	return context.WithTimeout(ctx, timeout)
+}
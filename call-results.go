@@ -0,0 +1,160 @@
+package batchquery
+
+import (
+	"errors"
+
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+)
+
+// The detailed results of every call in a batch, which can be looked up by the index of a call or by its key
+type Results struct {
+	// The result of each call, in the order the calls were added
+	results []CallResult
+
+	// The index of the first call with each key
+	keys map[string]int
+}
+
+// Invokes all of the previously batched up contract calls like FlexibleCall, but returns the detailed result of every call
+// with its status, raw return data, decoded error, and the target and method it was for.
+// If some of the responses can't be unpacked, the results are still returned along with the *MultiError describing them,
+// and the affected results carry their own unpack errors.
+func (mc *MultiCaller) FlexibleCallResults(requireSuccess bool, opts *bind.CallOpts) (*Results, error) {
+	calls := mc.calls
+	_, responses, err := mc.runBatch(requireSuccess, mc.newCallOptions(opts))
+	if responses == nil {
+		return nil, err
+	}
+	unpackErrs := getUnpackErrors(len(calls), err)
+	if err != nil && unpackErrs == nil {
+		return nil, err
+	}
+
+	return newResults(calls, responses, unpackErrs), err
+}
+
+// Creates the detailed results of a batch's calls from their responses and the errors from unpacking them (nil = none failed to unpack)
+func newResults(calls []*Call, responses []CallResponse, unpackErrs []error) *Results {
+	results := &Results{
+		results: make([]CallResult, len(calls)),
+		keys:    map[string]int{},
+	}
+	for i, call := range calls {
+		var unpackErr error
+		if unpackErrs != nil {
+			unpackErr = unpackErrs[i]
+		}
+		results.results[i] = newCallResult(i, call, responses[i], unpackErr)
+		if call.Key == "" {
+			continue
+		}
+		_, exists := results.keys[call.Key]
+		if !exists {
+			results.keys[call.Key] = i
+		}
+	}
+	return results
+}
+
+// Creates the detailed result of a call from its response and the error from unpacking it, if there was one
+func newCallResult(index int, call *Call, response CallResponse, unpackErr error) CallResult {
+	result := CallResult{
+		Index:       index,
+		Key:         call.Key,
+		Target:      call.Target,
+		Method:      call.Method,
+		Success:     response.Status,
+		ReturnData:  response.ReturnData,
+		GasEstimate: call.GasEstimate,
+		Err:         unpackErr,
+		contractAbi: call.contractAbi,
+		callData:    call.CallData,
+	}
+	if !response.Status {
+		result.Err = &ErrCallReverted{
+			Index:       index,
+			Target:      call.Target,
+			Method:      call.Method,
+			Data:        response.ReturnData,
+			contractAbi: call.contractAbi,
+		}
+	}
+	return result
+}
+
+// Gets the number of results
+func (r *Results) Len() int {
+	return len(r.results)
+}
+
+// Gets the result of the call with the provided index
+func (r *Results) At(index int) CallResult {
+	return r.results[index]
+}
+
+// Gets the result of the call with the provided key, if there is one.
+// If several calls share the key, the result of the first one is returned.
+func (r *Results) Get(key string) (CallResult, bool) {
+	index, exists := r.keys[key]
+	if !exists {
+		return CallResult{}, false
+	}
+	return r.results[index], true
+}
+
+// Gets the results of the calls that have keys, keyed by them, so batches assembled across loops and helpers can be read back
+// without tracking indices. Calls without a key aren't included; if several calls share a key, the result of the first one is.
+func (r *Results) Map() map[string]CallResult {
+	results := make(map[string]CallResult, len(r.keys))
+	for key, index := range r.keys {
+		results[key] = r.results[index]
+	}
+	return results
+}
+
+// Gets the keys of the calls that have them, in the order the calls were added. Keys shared by several calls are only included once.
+func (r *Results) Keys() []string {
+	keys := make([]string, 0, len(r.keys))
+	for i, result := range r.results {
+		if result.Key != "" && r.keys[result.Key] == i {
+			keys = append(keys, result.Key)
+		}
+	}
+	return keys
+}
+
+// Gets the result of every call, in the order the calls were added
+func (r *Results) All() []CallResult {
+	return r.results
+}
+
+// Gets the success flag of every call, in the same form FlexibleCall returns them
+func (r *Results) Successes() []bool {
+	successes := make([]bool, len(r.results))
+	for i, result := range r.results {
+		successes[i] = result.Success
+	}
+	return successes
+}
+
+// Gets the results of the calls that failed, either by reverting or because their response couldn't be unpacked
+func (r *Results) Failures() []CallResult {
+	failures := []CallResult{}
+	for _, result := range r.results {
+		if result.Err != nil {
+			failures = append(failures, result)
+		}
+	}
+	return failures
+}
+
+// Gets an error describing every call that failed, or nil if they all worked
+func (r *Results) Err() error {
+	errs := []error{}
+	for _, result := range r.results {
+		if result.Err != nil {
+			errs = append(errs, result.Err)
+		}
+	}
+	return errors.Join(errs...)
+}
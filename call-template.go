@@ -0,0 +1,125 @@
+package batchquery
+
+import (
+	"fmt"
+	"math/big"
+	"reflect"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// The call data for a method whose arguments are all fixed except one, such as balanceOf over many accounts or allowance for a fixed spender.
+// The fixed arguments are packed once when the template is created, so adding a call only copies the packed call data and writes the one
+// argument that varies into it, instead of encoding every argument again. Templates are immutable and can be shared between goroutines.
+type CallTemplate struct {
+	// The ABI of the contract
+	contractAbi *abi.ABI
+
+	// The name of the method
+	method string
+
+	// The arguments of the method, with the varying one left as a placeholder
+	args []any
+
+	// The index of the argument that varies between calls
+	argIndex int
+
+	// The call data with the fixed arguments packed and the varying argument's word left as zero
+	callData []byte
+
+	// The encoder for the varying argument
+	encode wordEncoder
+}
+
+// Creates a new CallTemplate for a method, where the argument at argIndex varies between calls and the rest are fixed to the provided values.
+// args holds every argument of the method; the one at argIndex is only a placeholder and is ignored.
+// The varying argument must be a single-word type (an address, bool, unsigned integer, or bytes32), while the fixed arguments can be anything.
+func NewCallTemplate(contractAbi *abi.ABI, method string, argIndex int, args ...any) (*CallTemplate, error) {
+	abiMethod, exists := contractAbi.Methods[method]
+	if !exists {
+		return nil, fmt.Errorf("error creating call template: method '%s' not found", method)
+	}
+	if len(args) != len(abiMethod.Inputs) {
+		return nil, fmt.Errorf("error creating call template for method [%s]: received %d arguments which mismatches the %d the method takes", method, len(args), len(abiMethod.Inputs))
+	}
+	if argIndex < 0 || argIndex >= len(args) {
+		return nil, fmt.Errorf("error creating call template for method [%s]: argument index %d is out of range", method, argIndex)
+	}
+	argType := abiMethod.Inputs[argIndex].Type
+	encode := getWordEncoder(argType)
+	if encode == nil {
+		return nil, fmt.Errorf("error creating call template for method [%s]: argument %d has type %s, which isn't a single-word type", method, argIndex, argType.String())
+	}
+
+	// Pack the fixed arguments with a zero value in place of the varying one, whose word is then at a fixed position in the head
+	templateArgs := append([]any{}, args...)
+	templateArgs[argIndex] = zeroArgValue(argType)
+	arguments, err := abiMethod.Inputs.Pack(templateArgs...)
+	if err != nil {
+		return nil, fmt.Errorf("error packing fixed arguments for call template [%s]: %w", method, err)
+	}
+	callData := make([]byte, 0, 4+len(arguments))
+	callData = append(callData, abiMethod.ID...)
+	callData = append(callData, arguments...)
+
+	return &CallTemplate{
+		contractAbi: contractAbi,
+		method:      method,
+		args:        templateArgs,
+		argIndex:    argIndex,
+		callData:    callData,
+		encode:      encode,
+	}, nil
+}
+
+// Packs the call data for the template with the provided value of its varying argument
+func (t *CallTemplate) pack(arg any) ([]byte, error) {
+	callData := make([]byte, len(t.callData))
+	copy(callData, t.callData)
+
+	// The word is zero in the template, so the encoder only has to write the argument's significant bytes
+	offset := 4 + wordSize*t.argIndex
+	if t.encode(arg, callData[offset:offset+wordSize]) {
+		return callData, nil
+	}
+
+	// Fall back to the regular packer for values the encoder doesn't handle, such as a uint64 for a uint256 argument
+	args := append([]any{}, t.args...)
+	args[t.argIndex] = arg
+	return packCall(t.contractAbi, t.method, args...)
+}
+
+// Adds a call to the template's method on the provided contract, with the template's varying argument set to arg.
+// The response is unpacked into output like AddCall.
+func (mc *MultiCaller) AddTemplateCall(contractAddress common.Address, template *CallTemplate, output any, arg any) *Call {
+	contractAbi := template.contractAbi
+	method := template.method
+	call := &Call{
+		Target:      contractAddress,
+		Method:      method,
+		Output:      output,
+		contractAbi: contractAbi,
+		PackFunc: func() ([]byte, error) {
+			callData, err := template.pack(arg)
+			if err != nil {
+				return nil, fmt.Errorf("error packing data for call [%s] on contract %s: %w", method, contractAddress.Hex(), err)
+			}
+			return callData, nil
+		},
+		UnpackFunc: func(rawData []byte) error {
+			return contractAbi.UnpackIntoInterface(output, method, rawData)
+		},
+	}
+	mc.calls = append(mc.calls, call)
+	return call
+}
+
+// Gets the zero value of an argument type, which is packed in place of a template's varying argument
+func zeroArgValue(t abi.Type) any {
+	goType := t.GetType()
+	if goType == reflect.TypeOf(&big.Int{}) {
+		return new(big.Int)
+	}
+	return reflect.Zero(goType).Interface()
+}
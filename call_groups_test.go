@@ -0,0 +1,50 @@
+package batchquery
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+func TestFlexibleCallByGroup(t *testing.T) {
+	mc, _ := newTestMultiCaller(t)
+	var first, second, third, boom *big.Int
+	var wrongType bool
+	mc.AddCall(testTokenAddress, &testTokenAbi, &first, "balanceOf", common.HexToAddress("0x01")).InGroup("balances")
+	mc.AddCall(testTokenAddress, &testTokenAbi, &wrongType, "balanceOf", common.HexToAddress("0x02")).InGroup("broken")
+	mc.AddCall(testTokenAddress, &testTokenAbi, &second, "balanceOf", common.HexToAddress("0x03")).InGroup("balances")
+	mc.AddCall(testTokenAddress, &testTokenAbi, &boom, "boom").InGroup("reverts")
+	mc.AddCall(testTokenAddress, &testTokenAbi, &third, "balanceOf", common.HexToAddress("0x04"))
+	groups, err := mc.FlexibleCallByGroup(false, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(groups) != 4 {
+		t.Fatalf("expected 4 groups, got %d", len(groups))
+	}
+
+	balances := groups["balances"]
+	if !balances.AllSucceeded() || len(balances.Indices) != 2 || balances.Indices[0] != 0 || balances.Indices[1] != 2 {
+		t.Fatalf("expected both balance calls to succeed, got %+v", balances)
+	}
+	if first.Int64() != 1 || second.Int64() != 3 || third.Int64() != 4 {
+		t.Fatalf("expected the balances to be unpacked, got %s, %s, and %s", first, second, third)
+	}
+
+	// The unpack failure only affects its own group
+	broken := groups["broken"]
+	if broken.AllSucceeded() || broken.Err == nil || broken.Err.Errors[0].Index != 1 {
+		t.Fatalf("expected the broken group to report its unpack failure, got %+v", broken)
+	}
+	reverts := groups["reverts"]
+	if reverts.AllSucceeded() || reverts.SuccessCount != 0 || reverts.Err != nil {
+		t.Fatalf("expected the reverted call to be reported through its success flag, got %+v", reverts)
+	}
+	if !groups[""].AllSucceeded() {
+		t.Fatal("expected the ungrouped call to succeed")
+	}
+	if len(mc.calls) != 0 {
+		t.Fatal("expected the calls to be cleared")
+	}
+}
@@ -0,0 +1,113 @@
+package batchquery
+
+import (
+	"context"
+	"errors"
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+func TestCallOptsAtHashTargetsBlock(t *testing.T) {
+	mc, _ := newTestMultiCaller(t)
+	account := common.HexToAddress("0x05")
+	blockHash := common.BigToHash(big.NewInt(250))
+
+	var balance *big.Int
+	mc.AddCall(testTokenAddress, &testTokenAbi, &balance, "balanceOf", account)
+	_, err := mc.FlexibleCall(true, CallOptsAtHash(&bind.CallOpts{BlockNumber: big.NewInt(10), Pending: true}, blockHash))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if balance.Cmp(expectedBalance(account, 250)) != 0 {
+		t.Fatalf("expected the balance at the hashed block, got %s", balance)
+	}
+}
+
+func TestSessionFromCallOptsAtHash(t *testing.T) {
+	mc, _ := newTestMultiCaller(t)
+	blockHash := common.BigToHash(big.NewInt(250))
+	session, err := NewSession(mc, CallOptsAtHash(nil, blockHash))
+	if err != nil {
+		t.Fatal(err)
+	}
+	hash, ok := session.BlockHash()
+	if !ok || hash != blockHash {
+		t.Fatalf("expected the session to be pinned to %s, got %s", blockHash.Hex(), hash.Hex())
+	}
+	if session.BlockNumber().Int64() != 250 {
+		t.Fatalf("expected the session's block number to be resolved from the hash, got %s", session.BlockNumber())
+	}
+
+	// The session's options carry the hash back into the package's batchers
+	if newCallOptions(session.CallOpts()).blockHash == nil {
+		t.Fatal("expected the session's call options to carry the block hash")
+	}
+}
+
+// A storage reader that can also read at a block hash, where every slot holds the hash it was read at
+type mockHashStorageReader struct {
+	mockStorageReader
+}
+
+func (m *mockHashStorageReader) StorageAtHash(ctx context.Context, account common.Address, key common.Hash, blockHash common.Hash) ([]byte, error) {
+	return blockHash.Bytes(), nil
+}
+
+func TestCallOptsAtHashReadsStorage(t *testing.T) {
+	blockHash := common.HexToHash("0xabcd")
+	opts := CallOptsAtHash(nil, blockHash)
+	slots := []StorageSlot{{Address: testTokenAddress, Slot: common.BigToHash(big.NewInt(3))}}
+
+	// Single reads go through the client's StorageAtHash
+	values, err := NewStorageBatcher(&mockHashStorageReader{}, 1).GetStorage(slots, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if values[0] != blockHash {
+		t.Fatalf("expected the slot to be read at %s, got %s", blockHash.Hex(), values[0].Hex())
+	}
+
+	// Batch requests pass the hash as the EIP-1898 block parameter
+	batchClient := &mockBatchStorageReader{}
+	values, err = NewStorageBatcher(batchClient, 1).GetStorage(slots, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if values[0].Big().Int64() != 3 {
+		t.Fatalf("unexpected value %s", values[0].Hex())
+	}
+	blockArg, ok := batchClient.blockArgs[0].(rpc.BlockNumberOrHash)
+	if hash, hasHash := blockArg.Hash(); !ok || !hasHash || hash != blockHash {
+		t.Fatalf("expected the request to target block %s, got %v", blockHash.Hex(), batchClient.blockArgs[0])
+	}
+
+	// Clients that can't read at a hash fail instead of reading another block
+	_, err = NewStorageBatcher(&mockStorageReader{}, 1).GetStorage(slots, opts)
+	if err == nil {
+		t.Fatal("expected storage reads at a block hash to fail without client support")
+	}
+	err = VerifyContractCode(&mockClient{}, testTokenAddress, []common.Hash{{}}, opts)
+	if err == nil {
+		t.Fatal("expected code reads at a block hash to fail without client support")
+	}
+}
+
+func TestCancelledContextStopsBatch(t *testing.T) {
+	mc, client := newTestMultiCaller(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	var balance *big.Int
+	mc.AddCall(testTokenAddress, &testTokenAbi, &balance, "balanceOf", common.HexToAddress("0x05"))
+	_, err := mc.FlexibleCall(false, &bind.CallOpts{Context: ctx})
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected the cancelled context to stop the batch, got %v", err)
+	}
+	if client.calls != 0 {
+		t.Fatalf("expected no calls to be made, got %d", client.calls)
+	}
+}
@@ -0,0 +1,172 @@
+package batchquery
+
+import (
+	"errors"
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+)
+
+func TestFlexibleCallResults(t *testing.T) {
+	mc, _ := newTestMultiCaller(t)
+	var balance *big.Int
+	var boom *big.Int
+	mc.AddCall(testTokenAddress, &testTokenAbi, &balance, "balanceOf", common.HexToAddress("0x05")).WithKey("balance").WithGasEstimate(30000)
+	mc.AddCall(testTokenAddress, &testTokenAbi, &boom, "boom").WithKey("boom")
+	results, err := mc.FlexibleCallResults(false, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if results.Len() != 2 {
+		t.Fatalf("expected 2 results, got %d", results.Len())
+	}
+
+	result, exists := results.Get("balance")
+	if !exists || !result.Success || result.Index != 0 || result.Method != "balanceOf" || result.Target != testTokenAddress || result.Err != nil {
+		t.Fatalf("unexpected balance result %+v", result)
+	}
+	if result.GasEstimate != 30000 || len(result.ReturnData) != wordSize {
+		t.Fatalf("expected the gas estimate and raw return data of the call, got %+v", result)
+	}
+	if balance.Cmp(expectedBalance(common.HexToAddress("0x05"), 0)) != 0 {
+		t.Fatalf("expected the output to still be unpacked, got %s", balance)
+	}
+
+	result = results.At(1)
+	var reverted *ErrCallReverted
+	if result.Success || !errors.As(result.Err, &reverted) || reverted.Index != 1 || result.RevertReason() != "boom" {
+		t.Fatalf("expected the revert to be described, got %+v", result)
+	}
+	if _, exists := results.Get("missing"); exists {
+		t.Fatal("expected no result for an unknown key")
+	}
+	successes := results.Successes()
+	if len(successes) != 2 || !successes[0] || successes[1] {
+		t.Fatalf("unexpected successes %v", successes)
+	}
+	if len(results.Failures()) != 1 || !errors.As(results.Err(), &reverted) {
+		t.Fatalf("expected only the revert to be a failure, got %v", results.Err())
+	}
+}
+
+func TestFlexibleCallResultsByKey(t *testing.T) {
+	mc, _ := newTestMultiCaller(t)
+	accounts := []common.Address{common.HexToAddress("0x05"), common.HexToAddress("0x06")}
+	for _, account := range accounts {
+		mc.AddKeyedCall(account.Hex(), testTokenAddress, &testTokenAbi, new(*big.Int), "balanceOf", account)
+	}
+	mc.AddCall(testTokenAddress, &testTokenAbi, new(uint8), "decimals")
+	mc.AddKeyedCall(accounts[0].Hex(), testTokenAddress, &testTokenAbi, new(*big.Int), "balanceOf", common.HexToAddress("0x07"))
+	results, err := mc.FlexibleCallResults(true, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Unkeyed calls are left out, and duplicate keys map to the first call
+	byKey := results.Map()
+	if len(byKey) != 2 {
+		t.Fatalf("expected 2 keyed results, got %d", len(byKey))
+	}
+	for i, account := range accounts {
+		result := byKey[account.Hex()]
+		var balance *big.Int
+		err = result.Unpack(&balance)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if result.Index != i || balance.Cmp(expectedBalance(account, 0)) != 0 {
+			t.Fatalf("unexpected result for %s: %+v", account.Hex(), result)
+		}
+	}
+	keys := results.Keys()
+	if len(keys) != 2 || keys[0] != accounts[0].Hex() || keys[1] != accounts[1].Hex() {
+		t.Fatalf("expected the keys in the order they were added, got %v", keys)
+	}
+}
+
+func TestFlexibleCallResultsKeepsUnpackFailures(t *testing.T) {
+	mc, _ := newTestMultiCaller(t)
+	var wrongType string
+	var balance *big.Int
+	mc.AddCall(testTokenAddress, &testTokenAbi, &wrongType, "balanceOf", common.HexToAddress("0x05"))
+	mc.AddCall(testTokenAddress, &testTokenAbi, &balance, "balanceOf", common.HexToAddress("0x06"))
+	results, err := mc.FlexibleCallResults(false, nil)
+	var multiErr *MultiError
+	if !errors.As(err, &multiErr) {
+		t.Fatalf("expected a MultiError, got %v", err)
+	}
+	if results == nil || results.Len() != 2 {
+		t.Fatal("expected the results to be returned along with the unpack failure")
+	}
+	if !results.At(0).Success || !errors.Is(results.At(0).Err, ErrUnpackFailed) || results.At(1).Err != nil {
+		t.Fatalf("expected only the first call to carry an unpack failure, got %+v", results.All())
+	}
+}
+
+func TestFlexibleCallWithResultsMatchesFlexibleCallResults(t *testing.T) {
+	mc, _ := newTestMultiCaller(t)
+	var wrongType string
+	var balance *big.Int
+	mc.AddCall(testTokenAddress, &testTokenAbi, &wrongType, "balanceOf", common.HexToAddress("0x05"))
+	mc.AddCall(testTokenAddress, &testTokenAbi, &balance, "balanceOf", common.HexToAddress("0x06"))
+	results, err := mc.FlexibleCallWithResults(false, nil)
+	var multiErr *MultiError
+	if !errors.As(err, &multiErr) || len(multiErr.Errors) != 1 || multiErr.Errors[0].Index != 0 {
+		t.Fatalf("expected a MultiError for the first call, got %v", err)
+	}
+	if len(results) != 2 || !errors.Is(results[0].Err, ErrUnpackFailed) || results[1].Err != nil {
+		t.Fatalf("expected the results to be returned along with the unpack failure, got %+v", results)
+	}
+}
+
+func TestDecodeRevertReasonWithAbi(t *testing.T) {
+	errorAbi := mustParseAbi(`[{"inputs":[{"name":"available","type":"uint256"},{"name":"required","type":"uint256"}],"name":"InsufficientBalance","type":"error"}]`)
+	uintType, _ := abi.NewType("uint256", "", nil)
+	args, err := abi.Arguments{{Type: uintType}, {Type: uintType}}.Pack(big.NewInt(5), big.NewInt(10))
+	if err != nil {
+		t.Fatal(err)
+	}
+	selector := errorAbi.Errors["InsufficientBalance"].ID
+	data := append(selector[:4:4], args...)
+
+	reason := DecodeRevertReasonWithAbi(data, &errorAbi)
+	if reason != "InsufficientBalance(5, 10)" {
+		t.Fatalf("unexpected reason %q", reason)
+	}
+	reason = DecodeRevertReasonWithAbi(data, nil)
+	if reason != DecodeRevertReason(data) {
+		t.Fatalf("expected the selector to be described without an ABI, got %q", reason)
+	}
+	reason = DecodeRevertReasonWithAbi(boomRevertData(), &errorAbi)
+	if reason != "boom" {
+		t.Fatalf("expected standard reverts to still be decoded, got %q", reason)
+	}
+}
+
+func TestCallResultUnpack(t *testing.T) {
+	mc, _ := newTestMultiCaller(t)
+	account := common.HexToAddress("0x0207")
+	mc.AddCall(testTokenAddress, &testTokenAbi, new(*big.Int), "balanceOf", account)
+	mc.AddCallBySignature(testTokenAddress, &testTokenAbi, new(*big.Int), "balanceOf(address)", account)
+	mc.AddCall(testTokenAddress, &testTokenAbi, new(*big.Int), "boom")
+	results, err := mc.FlexibleCallResults(false, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 2; i++ {
+		var balance *big.Int
+		err := results.At(i).Unpack(&balance)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if balance.Cmp(expectedBalance(account, 0)) != 0 {
+			t.Fatalf("result %d: unexpected balance %s", i, balance)
+		}
+	}
+	var reverted *ErrCallReverted
+	if err := results.At(2).Unpack(new(*big.Int)); !errors.As(err, &reverted) {
+		t.Fatalf("expected unpacking a reverted call to fail with its revert, got %v", err)
+	}
+}
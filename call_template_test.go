@@ -0,0 +1,125 @@
+package batchquery
+
+import (
+	"bytes"
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+func TestCallTemplateMatchesAbiPack(t *testing.T) {
+	address := common.HexToAddress("0x00000000219ab540356cBB839Cbe05303d7705Fa")
+	cases := []struct {
+		method   string
+		argIndex int
+		fixed    []any
+		values   []any
+	}{
+		{"address_", 0, []any{nil}, []any{address, common.Address{}}},
+		{"uint256_", 0, []any{nil}, []any{big.NewInt(0), big.NewInt(123456789), uint64(5)}},
+		{"uint8_", 0, []any{nil}, []any{uint8(255)}},
+		{"mixed", 1, []any{address, nil, true}, []any{big.NewInt(1), big.NewInt(1 << 40)}},
+		{"mixed", 2, []any{address, big.NewInt(7), nil}, []any{true, false}},
+		{"mixedDynamic", 0, []any{nil, "a fixed string that takes more than one word"}, []any{address, common.HexToAddress("0x01")}},
+	}
+	for _, c := range cases {
+		template, err := NewCallTemplate(&packTestAbi, c.method, c.argIndex, c.fixed...)
+		if err != nil {
+			t.Fatalf("%s: %s", c.method, err.Error())
+		}
+		for _, value := range c.values {
+			args := append([]any{}, c.fixed...)
+			args[c.argIndex] = value
+			expected, expectedErr := packTestAbi.Pack(c.method, args...)
+			actual, err := template.pack(value)
+			if (err == nil) != (expectedErr == nil) {
+				t.Fatalf("%s(%v): expected error %v, got %v", c.method, args, expectedErr, err)
+			}
+			if !bytes.Equal(actual, expected) {
+				t.Fatalf("%s(%v): expected %x, got %x", c.method, args, expected, actual)
+			}
+		}
+	}
+}
+
+func TestCallTemplateRejectsInvalidTemplates(t *testing.T) {
+	cases := []struct {
+		method   string
+		argIndex int
+		args     []any
+	}{
+		{"missing", 0, []any{nil}},
+		{"address_", 0, []any{}},
+		{"address_", 1, []any{nil}},
+		{"string_", 0, []any{nil}},
+		{"int256_", 0, []any{nil}},
+		{"mixedDynamic", 0, []any{nil, 5}},
+	}
+	for _, c := range cases {
+		_, err := NewCallTemplate(&packTestAbi, c.method, c.argIndex, c.args...)
+		if err == nil {
+			t.Fatalf("expected template for %s at %d with %v to be rejected", c.method, c.argIndex, c.args)
+		}
+	}
+}
+
+func TestTemplateCalls(t *testing.T) {
+	mc, _ := newTestMultiCaller(t)
+	template, err := NewCallTemplate(&testTokenAbi, "balanceOf", 0, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	balances := make([]*big.Int, 50)
+	for i := range balances {
+		mc.AddTemplateCall(testTokenAddress, template, &balances[i], common.BigToAddress(big.NewInt(int64(i+1))))
+	}
+	badCall := mc.AddTemplateCall(testTokenAddress, template, new(*big.Int), "not an address")
+	_, err = mc.FlexibleCall(true, nil)
+	if err == nil {
+		t.Fatal("expected an invalid argument to fail packing")
+	}
+
+	mc.calls = mc.calls[:len(balances)]
+	_, err = mc.FlexibleCall(true, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, balance := range balances {
+		account := common.BigToAddress(big.NewInt(int64(i + 1)))
+		if balance.Cmp(expectedBalance(account, 0)) != 0 {
+			t.Fatalf("unexpected balance %s for %s", balance, account.Hex())
+		}
+	}
+	if badCall.Method != "balanceOf" || badCall.Target != testTokenAddress {
+		t.Fatalf("unexpected call %s on %s", badCall.Method, badCall.Target.Hex())
+	}
+}
+
+func BenchmarkTemplatePack(b *testing.B) {
+	template, err := NewCallTemplate(&packTestAbi, "mixedDynamic", 0, nil, "a fixed string")
+	if err != nil {
+		b.Fatal(err)
+	}
+	address := common.HexToAddress("0x00000000219ab540356cBB839Cbe05303d7705Fa")
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		_, err := template.pack(address)
+		if err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkPackCallWithoutTemplate(b *testing.B) {
+	address := common.HexToAddress("0x00000000219ab540356cBB839Cbe05303d7705Fa")
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		_, err := packCall(&packTestAbi, "mixedDynamic", address, "a fixed string")
+		if err != nil {
+			b.Fatal(err)
+		}
+	}
+}
@@ -0,0 +1,226 @@
+package batchquery
+
+import (
+	"fmt"
+	"math/big"
+	"sort"
+	"sync"
+
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// The client, helper contracts, and batching settings for a single chain in a ChainSet
+type ChainConfig struct {
+	// The Execution client for the chain
+	Client IContractCaller
+
+	// The address of the multicall contract on the chain
+	MulticallAddress common.Address
+
+	// The address of the balance batcher contract on the chain (zero if it isn't deployed there)
+	BalanceBatcherAddress common.Address
+
+	// The address of the chain's wrapped native token, such as WETH (zero = none)
+	WrappedNativeAddress common.Address
+
+	// Whether GetEthBalances should fold each address's wrapped native token balance into its native balance,
+	// for applications that treat the two as the same asset. This requires a WrappedNativeAddress.
+	CombineWrappedNative bool
+
+	// The number of addresses to query within a single balance batcher call
+	BalanceBatchSize int
+
+	// The number of balance batcher calls to run simultaneously
+	BalanceThreadLimit int
+
+	// The maximum number of calls to include in a single multicall (0 = no limit)
+	CallBatchSize int
+
+	// The maximum size of a single multicall's packed call data in bytes (0 = no limit)
+	CallDataSizeLimit int
+
+	// The maximum expected size of a single multicall response in bytes (0 = no limit)
+	ReturnSizeLimit int
+
+	// The number of multicall chunks to run simultaneously (0 = no limit)
+	ThreadLimit int
+
+	// The gas limit for each multicall chunk's eth_call (0 = the node's default)
+	GasLimit uint64
+
+	// The executor that runs the chain's multicall and balance batcher requests, which can be shared between chains that use the same provider
+	// (nil = each batcher is only limited by its own thread limit)
+	Executor *Executor
+}
+
+// ChainSet holds the batchers for several chains keyed by their chain IDs, so services that work with multiple networks
+// can share one set of wiring rather than maintaining a separate copy for each chain.
+type ChainSet struct {
+	// The batchers for each chain
+	chains map[uint64]*chainBatchers
+
+	// Lock for the chain map
+	lock sync.RWMutex
+}
+
+// The batchers for a single chain
+type chainBatchers struct {
+	// The MultiCaller that new ones for the chain are copied from
+	multiCaller *MultiCaller
+
+	// The chain's BalanceBatcher, or nil if it doesn't have one
+	balanceBatcher *BalanceBatcher
+
+	// The address of the chain's wrapped native token (zero = none)
+	wrappedNative common.Address
+
+	// Whether native balances include the wrapped native token
+	combineWrappedNative bool
+}
+
+// Creates a new, empty ChainSet
+func NewChainSet() *ChainSet {
+	return &ChainSet{
+		chains: map[uint64]*chainBatchers{},
+	}
+}
+
+// Adds a chain to the set, replacing the existing one with the same ID if there is one
+func (cs *ChainSet) AddChain(chainID uint64, config ChainConfig) error {
+	multiCaller, err := NewMultiCaller(config.Client, config.MulticallAddress)
+	if err != nil {
+		return fmt.Errorf("error creating multicaller for chain %d: %w", chainID, err)
+	}
+	multiCaller.CallBatchSize = config.CallBatchSize
+	multiCaller.CallDataSizeLimit = config.CallDataSizeLimit
+	multiCaller.ReturnSizeLimit = config.ReturnSizeLimit
+	multiCaller.ThreadLimit = config.ThreadLimit
+	multiCaller.GasLimit = config.GasLimit
+	multiCaller.Executor = config.Executor
+
+	if config.CombineWrappedNative && config.WrappedNativeAddress == (common.Address{}) {
+		return fmt.Errorf("chain %d can't combine wrapped native balances without a wrapped native token address", chainID)
+	}
+	var balanceBatcher *BalanceBatcher
+	if config.BalanceBatcherAddress != (common.Address{}) {
+		balanceBatcher, err = NewBalanceBatcher(config.Client, config.BalanceBatcherAddress, config.BalanceBatchSize, config.BalanceThreadLimit)
+		if err != nil {
+			return fmt.Errorf("error creating balance batcher for chain %d: %w", chainID, err)
+		}
+		balanceBatcher.Executor = config.Executor
+	}
+
+	cs.lock.Lock()
+	defer cs.lock.Unlock()
+	cs.chains[chainID] = &chainBatchers{
+		multiCaller:          multiCaller,
+		balanceBatcher:       balanceBatcher,
+		wrappedNative:        config.WrappedNativeAddress,
+		combineWrappedNative: config.CombineWrappedNative,
+	}
+	return nil
+}
+
+// Removes a chain from the set
+func (cs *ChainSet) RemoveChain(chainID uint64) {
+	cs.lock.Lock()
+	defer cs.lock.Unlock()
+	delete(cs.chains, chainID)
+}
+
+// Gets the IDs of the chains in the set, in ascending order
+func (cs *ChainSet) ChainIDs() []uint64 {
+	cs.lock.RLock()
+	defer cs.lock.RUnlock()
+	ids := make([]uint64, 0, len(cs.chains))
+	for id := range cs.chains {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool {
+		return ids[i] < ids[j]
+	})
+	return ids
+}
+
+// Creates a new MultiCaller for the chain with its configured client and settings.
+// Each MultiCaller has its own list of pending calls, so separate goroutines should each create their own.
+func (cs *ChainSet) MultiCaller(chainID uint64) (*MultiCaller, error) {
+	batchers, err := cs.getChain(chainID)
+	if err != nil {
+		return nil, err
+	}
+	return batchers.multiCaller.withCalls([]*Call{}), nil
+}
+
+// Gets the BalanceBatcher for the chain
+func (cs *ChainSet) BalanceBatcher(chainID uint64) (*BalanceBatcher, error) {
+	batchers, err := cs.getChain(chainID)
+	if err != nil {
+		return nil, err
+	}
+	if batchers.balanceBatcher == nil {
+		return nil, fmt.Errorf("chain %d does not have a balance batcher", chainID)
+	}
+	return batchers.balanceBatcher, nil
+}
+
+// Creates a new Session for the chain, with the same semantics as NewSession()
+func (cs *ChainSet) NewSession(chainID uint64, opts *bind.CallOpts) (*Session, error) {
+	batchers, err := cs.getChain(chainID)
+	if err != nil {
+		return nil, err
+	}
+	return NewSession(batchers.multiCaller, opts)
+}
+
+// Retrieves the ETH balance for a list of addresses on the chain, with the same semantics as BalanceBatcher.GetEthBalances().
+// If the chain was configured with CombineWrappedNative, each balance includes the address's wrapped native token balance.
+func (cs *ChainSet) GetEthBalances(chainID uint64, addresses []common.Address, opts *bind.CallOpts) ([]*big.Int, error) {
+	batchers, err := cs.getChain(chainID)
+	if err != nil {
+		return nil, err
+	}
+	if batchers.balanceBatcher == nil {
+		return nil, fmt.Errorf("chain %d does not have a balance batcher", chainID)
+	}
+	if !batchers.combineWrappedNative {
+		return batchers.balanceBatcher.GetEthBalances(addresses, opts)
+	}
+	nativeBalances, err := batchers.balanceBatcher.GetNativeBalances(addresses, batchers.wrappedNative, opts)
+	if err != nil {
+		return nil, err
+	}
+	balances := make([]*big.Int, len(nativeBalances))
+	for i, balance := range nativeBalances {
+		balances[i] = balance.Total
+	}
+	return balances, nil
+}
+
+// Retrieves the native balance and wrapped native token balance of each address on the chain, with the same semantics as BalanceBatcher.GetNativeBalances().
+// The chain must have been configured with a WrappedNativeAddress.
+func (cs *ChainSet) GetNativeBalances(chainID uint64, addresses []common.Address, opts *bind.CallOpts) ([]NativeBalance, error) {
+	batchers, err := cs.getChain(chainID)
+	if err != nil {
+		return nil, err
+	}
+	if batchers.balanceBatcher == nil {
+		return nil, fmt.Errorf("chain %d does not have a balance batcher", chainID)
+	}
+	if batchers.wrappedNative == (common.Address{}) {
+		return nil, fmt.Errorf("chain %d does not have a wrapped native token", chainID)
+	}
+	return batchers.balanceBatcher.GetNativeBalances(addresses, batchers.wrappedNative, opts)
+}
+
+// Gets the batchers for a chain
+func (cs *ChainSet) getChain(chainID uint64) (*chainBatchers, error) {
+	cs.lock.RLock()
+	defer cs.lock.RUnlock()
+	batchers, exists := cs.chains[chainID]
+	if !exists {
+		return nil, fmt.Errorf("chain %d has not been added", chainID)
+	}
+	return batchers, nil
+}
@@ -0,0 +1,59 @@
+package batchquery
+
+import (
+	"fmt"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// The details of the chain as of a single block, read through the multicall contract's getters
+type ChainSnapshot struct {
+	// The chain ID
+	ChainID *big.Int
+
+	// The number of the block
+	BlockNumber *big.Int
+
+	// The timestamp of the block
+	Timestamp *big.Int
+
+	// The base fee of the block
+	BaseFee *big.Int
+
+	// The coinbase (fee recipient) of the block
+	Coinbase common.Address
+}
+
+// Adds the calls for a chain snapshot to the batch, so the snapshot is read at the same block as the batch's other calls.
+// The snapshot is filled in once the batch has run. This requires Multicall3, since older contracts don't have getBasefee or getChainId.
+func (mc *MultiCaller) AddChainSnapshot(snapshot *ChainSnapshot) {
+	mc.AddChainID(&snapshot.ChainID)
+	mc.AddBlockNumber(&snapshot.BlockNumber)
+	mc.AddBlockTimestamp(&snapshot.Timestamp)
+	mc.AddBaseFee(&snapshot.BaseFee)
+	mc.AddCoinbase(&snapshot.Coinbase)
+}
+
+// Gets a consistent snapshot of the chain ID, block number, block timestamp, base fee, and coinbase in a single call,
+// for services that need a coherent "as of" header for a data pull. This requires Multicall3.
+// The sender in opts is ignored, since the getters don't depend on it and running them individually could mix blocks.
+// The MultiCaller's own list of pending calls is not affected.
+func (mc *MultiCaller) GetChainSnapshot(opts *bind.CallOpts) (*ChainSnapshot, error) {
+	// The getters have to run in a single multicall to come from the same block
+	runner := mc.withCalls([]*Call{})
+	runner.CallBatchSize = 0
+	runner.CallDataSizeLimit = 0
+	runner.ReturnSizeLimit = 0
+
+	snapshot := &ChainSnapshot{}
+	runner.AddChainSnapshot(snapshot)
+	options := mc.newCallOptions(opts)
+	options.from = common.Address{}
+	_, err := runner.flexibleCall(true, options)
+	if err != nil {
+		return nil, fmt.Errorf("error getting chain snapshot: %w", err)
+	}
+	return snapshot, nil
+}
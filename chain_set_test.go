@@ -0,0 +1,145 @@
+package batchquery
+
+import (
+	"fmt"
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// Runs a balanceOf call for the account through the chain's MultiCaller
+func getChainBalance(t *testing.T, chains *ChainSet, chainID uint64, account common.Address) *big.Int {
+	mc, err := chains.MultiCaller(chainID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var balance *big.Int
+	mc.AddCall(testTokenAddress, &testTokenAbi, &balance, "balanceOf", account)
+	_, err = mc.FlexibleCall(true, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return balance
+}
+
+func TestChainSetDispatchesByChain(t *testing.T) {
+	mainnet := &mockClient{}
+	holesky := &mockClient{}
+	chains := NewChainSet()
+	err := chains.AddChain(17000, ChainConfig{Client: holesky, MulticallAddress: testMulticallAddress, CallBatchSize: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = chains.AddChain(1, ChainConfig{Client: mainnet, MulticallAddress: testMulticallAddress, BalanceBatcherAddress: testTokenAddress})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fmt.Sprint(chains.ChainIDs()) != "[1 17000]" {
+		t.Fatalf("expected the chain IDs in order, got %v", chains.ChainIDs())
+	}
+
+	// Each chain's calls go to its own client, with its own settings
+	account := common.HexToAddress("0x0102")
+	balance := getChainBalance(t, chains, 1, account)
+	if balance.Cmp(expectedBalance(account, 0)) != 0 || mainnet.calls != 1 || holesky.calls != 0 {
+		t.Fatalf("expected the call to go to the mainnet client, got %s from %d and %d calls", balance, mainnet.calls, holesky.calls)
+	}
+	mc, err := chains.MultiCaller(17000)
+	if err != nil {
+		t.Fatal(err)
+	}
+	mc.AddCall(testTokenAddress, &testTokenAbi, new(*big.Int), "balanceOf", account)
+	mc.AddCall(testTokenAddress, &testTokenAbi, new(*big.Int), "balanceOf", account)
+	_, err = mc.FlexibleCall(true, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if mainnet.calls != 1 || fmt.Sprint(holesky.chunkSizes) != "[1 1]" {
+		t.Fatalf("expected the calls to go to the holesky client in chunks of 1, got chunks %v", holesky.chunkSizes)
+	}
+
+	// Only chains configured with a balance batcher have one
+	_, err = chains.BalanceBatcher(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = chains.BalanceBatcher(17000)
+	if err == nil {
+		t.Fatal("expected a chain without a balance batcher to be reported")
+	}
+	_, err = chains.GetEthBalances(17000, []common.Address{account}, nil)
+	if err == nil {
+		t.Fatal("expected balances on a chain without a balance batcher to fail")
+	}
+}
+
+func TestChainSetUnknownChain(t *testing.T) {
+	chains := NewChainSet()
+	err := chains.AddChain(1, ChainConfig{Client: &mockClient{}, MulticallAddress: testMulticallAddress, BalanceBatcherAddress: testTokenAddress})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = chains.MultiCaller(5)
+	if err == nil {
+		t.Fatal("expected a MultiCaller for an unknown chain to fail")
+	}
+	_, err = chains.BalanceBatcher(5)
+	if err == nil {
+		t.Fatal("expected a BalanceBatcher for an unknown chain to fail")
+	}
+	_, err = chains.NewSession(5, nil)
+	if err == nil {
+		t.Fatal("expected a session for an unknown chain to fail")
+	}
+	_, err = chains.GetEthBalances(5, []common.Address{{}}, nil)
+	if err == nil {
+		t.Fatal("expected balances on an unknown chain to fail")
+	}
+
+	// Removed chains are unknown too
+	chains.RemoveChain(1)
+	_, err = chains.MultiCaller(1)
+	if err == nil || len(chains.ChainIDs()) != 0 {
+		t.Fatalf("expected the removed chain to be unknown, got %v with chains %v", err, chains.ChainIDs())
+	}
+}
+
+func TestChainSetReplacesDuplicateChain(t *testing.T) {
+	first := &mockClient{}
+	second := &mockClient{}
+	chains := NewChainSet()
+	err := chains.AddChain(1, ChainConfig{Client: first, MulticallAddress: testMulticallAddress, BalanceBatcherAddress: testTokenAddress})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = chains.AddChain(1, ChainConfig{Client: second, MulticallAddress: testMulticallAddress})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fmt.Sprint(chains.ChainIDs()) != "[1]" {
+		t.Fatalf("expected the chain to be registered once, got %v", chains.ChainIDs())
+	}
+
+	// The second registration replaces the whole configuration, including the balance batcher
+	account := common.HexToAddress("0x0304")
+	getChainBalance(t, chains, 1, account)
+	if first.calls != 0 || second.calls != 1 {
+		t.Fatalf("expected the call to go to the replacement client, got %d and %d calls", first.calls, second.calls)
+	}
+	_, err = chains.BalanceBatcher(1)
+	if err == nil {
+		t.Fatal("expected the replaced chain's balance batcher to be gone")
+	}
+
+	// A failed registration leaves the existing chain in place
+	err = chains.AddChain(1, ChainConfig{Client: first, MulticallAddress: testMulticallAddress, CombineWrappedNative: true})
+	if err == nil {
+		t.Fatal("expected a chain that combines wrapped native balances without a token to be rejected")
+	}
+	getChainBalance(t, chains, 1, account)
+	if first.calls != 0 || second.calls != 2 {
+		t.Fatalf("expected the existing chain to be kept, got %d and %d calls", first.calls, second.calls)
+	}
+}
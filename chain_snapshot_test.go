@@ -0,0 +1,45 @@
+package batchquery
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+)
+
+func TestGetChainSnapshot(t *testing.T) {
+	mc, client := newTestMultiCaller(t)
+
+	// Even with a tiny batch size, the snapshot comes from a single multicall
+	mc.CallBatchSize = 1
+	snapshot, err := mc.GetChainSnapshot(&bind.CallOpts{BlockNumber: big.NewInt(50), From: common.HexToAddress("0x01")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sizes := client.getChunkSizes(); len(sizes) != 1 || sizes[0] != 5 {
+		t.Fatalf("expected a single multicall of 5 calls, got %v", sizes)
+	}
+	if snapshot.ChainID.Int64() != 1337 || snapshot.BlockNumber.Int64() != 50 || snapshot.Timestamp.Int64() != 600 ||
+		snapshot.BaseFee.Int64() != 7 || snapshot.Coinbase != testCoinbase {
+		t.Fatalf("unexpected snapshot %+v", snapshot)
+	}
+}
+
+func TestAddChainSnapshotSharesBatch(t *testing.T) {
+	mc, client := newTestMultiCaller(t)
+	var snapshot ChainSnapshot
+	var balance *big.Int
+	mc.AddChainSnapshot(&snapshot)
+	mc.AddCall(testTokenAddress, &testTokenAbi, &balance, "balanceOf", common.HexToAddress("0x05"))
+	_, err := mc.FlexibleCall(true, &bind.CallOpts{BlockNumber: big.NewInt(20)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sizes := client.getChunkSizes(); len(sizes) != 1 || sizes[0] != 6 {
+		t.Fatalf("expected a single multicall of 6 calls, got %v", sizes)
+	}
+	if snapshot.BlockNumber.Int64() != 20 || balance.Int64() != 25 {
+		t.Fatalf("expected the snapshot and balance to come from block 20, got %s and %s", snapshot.BlockNumber, balance)
+	}
+}
@@ -0,0 +1,184 @@
+package batchquery
+
+import (
+	"math/big"
+	"sort"
+	"time"
+)
+
+const (
+	// The size of a single ABI word, in bytes
+	wordSize = 32
+
+	// The size of the tryAggregate call data header (the function selector, the requireSuccess flag, and the offset and length of the call array), in bytes
+	callDataHeaderSize int = 4 + 3*wordSize
+
+	// The size of the fixed portion of a single call in the tryAggregate call data (the tuple offset, target, data offset, and data length), in bytes
+	callOverheadSize int = 4 * wordSize
+
+	// The size of the tryAggregate response header (the offset and length of the result array), in bytes
+	responseHeaderSize int = 2 * wordSize
+
+	// The size of the fixed portion of a single tryAggregate result (the tuple offset, success flag, data offset, and data length), in bytes
+	resultOverheadSize int = 4 * wordSize
+
+	// The gas the multicall itself uses regardless of its calls: the intrinsic transaction cost, plus some room for decoding and the return copy
+	chunkBaseGas uint64 = 21_000 + 10_000
+
+	// The gas charged per byte of call data, using the non-zero byte price so it's an upper bound
+	callDataByteGas uint64 = 16
+
+	// The gas the multicall contract spends on each call beyond the call itself: the cold account access, the CALL,
+	// the loop iteration, and copying the call data and return data in and out of memory
+	callOverheadGas uint64 = 10_000
+)
+
+// Gets the expected size of this call's entry in an aggregated response, in bytes
+func (c *Call) expectedResponseSize() int {
+	size := c.ReturnSize
+	if size <= 0 {
+		size = wordSize
+	}
+	return resultOverheadSize + padToWord(size)
+}
+
+// Gets the size of this call's entry in the aggregated call data, in bytes.
+// The call data must already be packed.
+func (c *Call) aggregatedCallDataSize() int {
+	return callOverheadSize + padToWord(len(c.CallData))
+}
+
+// Checks whether this call should be placed into an earlier chunk than the other one, based on their priorities, timeouts, and blocks
+func (c *Call) runsBefore(other *Call) bool {
+	if c.Priority != other.Priority {
+		return c.Priority > other.Priority
+	}
+	if c.Timeout != other.Timeout {
+		return c.Timeout < other.Timeout
+	}
+	return compareBlocks(c.BlockNumber, other.BlockNumber) < 0
+}
+
+// Compares the blocks of two calls, where a call without its own block (nil) comes before any call with one
+func compareBlocks(a *big.Int, b *big.Int) int {
+	if a == nil || b == nil {
+		if a == b {
+			return 0
+		}
+		if a == nil {
+			return -1
+		}
+		return 1
+	}
+	return a.Cmp(b)
+}
+
+// A group of calls to run within a single multicall
+type callChunk struct {
+	// The calls in the chunk
+	calls []*Call
+
+	// The index of each call within the batch
+	indices []int
+}
+
+// Splits the calls into chunks that respect the MultiCaller's call count, call data size, and response size limits, and the provided gas limit (0 = no limit).
+// The gas used by a chunk is estimated from the calls' gas estimates plus the multicall overhead for each call and for the chunk itself.
+// A call that exceeds one of the size limits on its own is placed into a chunk by itself.
+// Calls with a higher priority are placed into earlier chunks, and calls with different priorities never share a chunk.
+// Within a priority, calls with shorter timeouts are placed first, and calls with different timeouts never share a chunk either.
+// Calls with their own block are grouped by block, so every chunk runs at a single block.
+// Chunks are returned in the order they should be run; the second return value is true if this differs from the order of the calls.
+// The call data for each call must already be packed.
+func (mc *MultiCaller) chunkCalls(calls []*Call, gasLimit uint64) ([]callChunk, bool) {
+	// Order the calls by priority, timeout, and block, keeping the original order for calls that have all three in common
+	ordered := calls
+	indices := make([]int, len(calls))
+	for i := range indices {
+		indices[i] = i
+	}
+	reordered := !sort.SliceIsSorted(calls, func(i, j int) bool {
+		return calls[i].runsBefore(calls[j])
+	})
+	if reordered {
+		sort.SliceStable(indices, func(i, j int) bool {
+			return calls[indices[i]].runsBefore(calls[indices[j]])
+		})
+		ordered = make([]*Call, len(calls))
+		for i, index := range indices {
+			ordered[i] = calls[index]
+		}
+	}
+
+	chunks := []callChunk{}
+	start := 0
+	callDataSize := callDataHeaderSize
+	responseSize := responseHeaderSize
+	gas := chunkBaseGas + callDataByteGas*uint64(callDataHeaderSize)
+	for i, call := range ordered {
+		callSize := call.aggregatedCallDataSize()
+		returnSize := call.expectedResponseSize()
+		callGas := call.GasEstimate + callOverheadGas + callDataByteGas*uint64(callSize)
+		count := i - start
+		if count > 0 {
+			countExceeded := mc.CallBatchSize > 0 && count >= mc.CallBatchSize
+			callDataExceeded := mc.CallDataSizeLimit > 0 && callDataSize+callSize > mc.CallDataSizeLimit
+			responseExceeded := mc.ReturnSizeLimit > 0 && responseSize+returnSize > mc.ReturnSizeLimit
+			gasExceeded := gasLimit > 0 && gas+callGas > gasLimit
+			priorityChanged := call.Priority != ordered[start].Priority
+			timeoutChanged := call.Timeout != ordered[start].Timeout
+			blockChanged := compareBlocks(call.BlockNumber, ordered[start].BlockNumber) != 0
+			if countExceeded || callDataExceeded || responseExceeded || gasExceeded || priorityChanged || timeoutChanged || blockChanged {
+				chunks = append(chunks, callChunk{
+					calls:   ordered[start:i],
+					indices: indices[start:i],
+				})
+				start = i
+				callDataSize = callDataHeaderSize
+				responseSize = responseHeaderSize
+				gas = chunkBaseGas + callDataByteGas*uint64(callDataHeaderSize)
+			}
+		}
+		callDataSize += callSize
+		responseSize += returnSize
+		gas += callGas
+	}
+	if start < len(ordered) {
+		chunks = append(chunks, callChunk{
+			calls:   ordered[start:],
+			indices: indices[start:],
+		})
+	}
+	return chunks, reordered
+}
+
+// Gets the deadline for running the chunk, which is the timeout shared by its calls or the provided default if they don't have one
+func (c callChunk) timeout(defaultTimeout time.Duration) time.Duration {
+	if len(c.calls) > 0 && c.calls[0].Timeout > 0 {
+		return c.calls[0].Timeout
+	}
+	return defaultTimeout
+}
+
+// Creates the error for the chunk reverting while every call was required to succeed.
+// The reverting call can only be identified if it's the only one in the chunk.
+func (c callChunk) revertError(data []byte) error {
+	if len(c.calls) != 1 {
+		return &ErrCallReverted{
+			Index: -1,
+			Data:  data,
+		}
+	}
+	return &ErrCallReverted{
+		Index:       c.indices[0],
+		Target:      c.calls[0].Target,
+		Method:      c.calls[0].Method,
+		Data:        data,
+		contractAbi: c.calls[0].contractAbi,
+	}
+}
+
+// Rounds a size up to the next multiple of the ABI word size
+func padToWord(size int) int {
+	return (size + wordSize - 1) / wordSize * wordSize
+}
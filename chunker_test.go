@@ -0,0 +1,189 @@
+package batchquery
+
+import (
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// Creates a set of packed balanceOf calls
+func newTestCalls(t *testing.T, count int) []*Call {
+	calls := make([]*Call, count)
+	for i := range calls {
+		var balance *big.Int
+		calls[i] = newCall(testTokenAddress, &testTokenAbi, &balance, "balanceOf", common.BigToAddress(big.NewInt(int64(i))))
+	}
+	err := packCalls(calls)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return calls
+}
+
+// Gets the number of calls in each chunk
+func chunkSizes(chunks []callChunk) []int {
+	sizes := make([]int, len(chunks))
+	for i, chunk := range chunks {
+		sizes[i] = len(chunk.calls)
+	}
+	return sizes
+}
+
+// Checks that a list of chunk sizes matches the expected sizes
+func checkChunkSizes(t *testing.T, chunks []callChunk, expected ...int) {
+	t.Helper()
+	sizes := chunkSizes(chunks)
+	if len(sizes) != len(expected) {
+		t.Fatalf("expected chunk sizes %v, got %v", expected, sizes)
+	}
+	for i := range sizes {
+		if sizes[i] != expected[i] {
+			t.Fatalf("expected chunk sizes %v, got %v", expected, sizes)
+		}
+	}
+}
+
+func TestChunkCallsReservesGasOverhead(t *testing.T) {
+	mc, _ := newTestMultiCaller(t)
+	calls := newTestCalls(t, 2)
+	const gasLimit = 1_000_000
+	for _, call := range calls {
+		call.WithGasEstimate(gasLimit / 2)
+	}
+
+	// The estimates sum exactly to the limit, so the multicall overhead has to push the second call into its own chunk
+	chunks, _ := mc.chunkCalls(calls, gasLimit)
+	checkChunkSizes(t, chunks, 1, 1)
+
+	// With enough headroom for the overhead, they share a chunk
+	chunks, _ = mc.chunkCalls(calls, gasLimit+100_000)
+	checkChunkSizes(t, chunks, 2)
+
+	// Without a limit, estimates are ignored
+	chunks, _ = mc.chunkCalls(calls, 0)
+	checkChunkSizes(t, chunks, 2)
+}
+
+func TestSessionGasLimitOverride(t *testing.T) {
+	mc, client := newTestMultiCaller(t)
+	mc.GasLimit = 10_000_000
+	session, err := NewSession(mc, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	const gasLimit = 500_000
+	session.WithGasLimit(gasLimit)
+
+	balances := make([]*big.Int, 4)
+	for i := range balances {
+		session.AddCall(testTokenAddress, &testTokenAbi, &balances[i], "balanceOf", common.BigToAddress(big.NewInt(int64(i)))).WithGasEstimate(150_000)
+	}
+	client.gasLimits = nil
+	_, err = session.Execute(true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, balance := range balances {
+		if balance.Cmp(expectedBalance(common.BigToAddress(big.NewInt(int64(i))), 100)) != 0 {
+			t.Fatalf("call %d returned %s", i, balance)
+		}
+	}
+	if sizes := client.getChunkSizes(); len(sizes) != 2 || sizes[0] != 2 || sizes[1] != 2 {
+		t.Fatalf("expected the session's gas limit to split the batch in two, got %v", sizes)
+	}
+	for _, gas := range client.gasLimits {
+		if gas != gasLimit {
+			t.Fatalf("expected every chunk to run with the session's gas limit, got %d", gas)
+		}
+	}
+}
+
+func TestChunkCallsSplitsByCount(t *testing.T) {
+	mc, _ := newTestMultiCaller(t)
+	mc.CallBatchSize = 2
+	chunks, reordered := mc.chunkCalls(newTestCalls(t, 5), 0)
+	checkChunkSizes(t, chunks, 2, 2, 1)
+	if reordered {
+		t.Fatal("expected the calls to keep their order")
+	}
+}
+
+func TestChunkCallsSplitsByCallDataSize(t *testing.T) {
+	mc, _ := newTestMultiCaller(t)
+	calls := newTestCalls(t, 4)
+
+	// Each balanceOf call takes 36 bytes of call data padded to 64, plus the fixed per-call overhead
+	callSize := calls[0].aggregatedCallDataSize()
+	mc.CallDataSizeLimit = callDataHeaderSize + 2*callSize
+	chunks, _ := mc.chunkCalls(calls, 0)
+	checkChunkSizes(t, chunks, 2, 2)
+
+	// A call that exceeds the limit on its own still gets a chunk
+	mc.CallDataSizeLimit = 1
+	chunks, _ = mc.chunkCalls(calls, 0)
+	checkChunkSizes(t, chunks, 1, 1, 1, 1)
+}
+
+func TestChunkCallsSplitsByReturnSize(t *testing.T) {
+	mc, _ := newTestMultiCaller(t)
+	calls := newTestCalls(t, 4)
+	calls[1].WithReturnSize(10_000)
+	mc.ReturnSizeLimit = 4_096
+
+	// The large call can't share a chunk with anything, and the small calls fit together
+	chunks, _ := mc.chunkCalls(calls, 0)
+	checkChunkSizes(t, chunks, 1, 1, 2)
+	if chunks[1].calls[0] != calls[1] {
+		t.Fatal("expected the large call to be in its own chunk")
+	}
+}
+
+func TestChunkCallsSplitsByPriorityAndTimeout(t *testing.T) {
+	mc, _ := newTestMultiCaller(t)
+	calls := newTestCalls(t, 5)
+	calls[2].WithPriority(1)
+	calls[3].WithTimeout(time.Second)
+	calls[4].WithTimeout(time.Second)
+
+	// The high priority call goes first, then the calls with the default timeout, then the ones with their own timeout
+	chunks, reordered := mc.chunkCalls(calls, 0)
+	if !reordered {
+		t.Fatal("expected the calls to be reordered")
+	}
+	checkChunkSizes(t, chunks, 1, 2, 2)
+	expected := [][]int{{2}, {0, 1}, {3, 4}}
+	for i, chunk := range chunks {
+		for j, index := range chunk.indices {
+			if index != expected[i][j] || chunk.calls[j] != calls[index] {
+				t.Fatalf("expected chunk indices %v, got chunk %d with %v", expected, i, chunk.indices)
+			}
+		}
+	}
+}
+
+func TestReorderedResponsesKeepCallOrder(t *testing.T) {
+	mc, client := newTestMultiCaller(t)
+	mc.CallBatchSize = 2
+	balances := make([]*big.Int, 6)
+	for i := range balances {
+		call := mc.AddCall(testTokenAddress, &testTokenAbi, &balances[i], "balanceOf", common.BigToAddress(big.NewInt(int64(i))))
+		if i%2 == 1 {
+			call.WithPriority(1)
+		}
+	}
+	success, err := mc.FlexibleCall(true, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, balance := range balances {
+		if !success[i] || balance.Int64() != int64(i) {
+			t.Fatalf("call %d returned %s", i, balance)
+		}
+	}
+	// Each priority is split into a chunk of 2 and a chunk of 1
+	if sizes := client.getChunkSizes(); len(sizes) != 4 {
+		t.Fatalf("expected 4 chunks, got %v", sizes)
+	}
+}
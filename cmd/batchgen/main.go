@@ -0,0 +1,54 @@
+// Command batchgen generates typed batch bindings from a contract's ABI JSON file.
+// Use it from a go:generate directive, such as:
+//
+//	//go:generate go run github.com/rocket-pool/batch-query/cmd/batchgen -abi Token.abi.json -pkg token -type Token -out token-batch.go
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/rocket-pool/batch-query/batchgen"
+)
+
+func main() {
+	abiPath := flag.String("abi", "", "the path of the contract's ABI JSON file")
+	pkg := flag.String("pkg", "", "the name of the package to generate the bindings in (default: $GOPACKAGE)")
+	typeName := flag.String("type", "", "the name of the contract, which the generated types are named after")
+	outPath := flag.String("out", "", "the path of the file to write the bindings to (default: stdout)")
+	flag.Parse()
+
+	err := run(*abiPath, *pkg, *typeName, *outPath)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "batchgen: %s\n", err.Error())
+		os.Exit(1)
+	}
+}
+
+// Generates the bindings and writes them out
+func run(abiPath string, pkg string, typeName string, outPath string) error {
+	if abiPath == "" || typeName == "" {
+		return fmt.Errorf("both -abi and -type are required")
+	}
+	if pkg == "" {
+		pkg = os.Getenv("GOPACKAGE")
+	}
+	abiJson, err := os.ReadFile(abiPath)
+	if err != nil {
+		return fmt.Errorf("error reading ABI: %w", err)
+	}
+	source, err := batchgen.Generate(batchgen.Config{
+		Package: pkg,
+		Type:    typeName,
+		Abi:     abiJson,
+	})
+	if err != nil {
+		return err
+	}
+	if outPath == "" {
+		_, err = os.Stdout.Write(source)
+		return err
+	}
+	return os.WriteFile(outPath, source, 0644)
+}
@@ -0,0 +1,256 @@
+package batchquery
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+	"time"
+
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+const (
+	// The default number of blocks FindCreationBlock probes in each round of its search
+	defaultCreationSearchWidth int = 16
+)
+
+// This struct can read the code of many accounts, or of one account at many blocks, concurrently using eth_getCode.
+// It's useful for telling contracts apart from externally owned accounts, and for finding the block a contract was deployed in.
+type CodeBatcher struct {
+	// The number of reads to run simultaneously, or the number of batch requests to send simultaneously if the client implements IBatchCaller
+	ThreadLimit int
+
+	// The number of reads to send in a single JSON-RPC batch request, if the client implements IBatchCaller (0 = 100)
+	RpcBatchSize int
+
+	// The number of blocks FindCreationBlock probes in each round of its search, which are read together (0 = 16).
+	// Wider rounds take fewer round trips but read more blocks in total.
+	SearchWidth int
+
+	// The context that reads run in when their options don't provide one (nil = context.Background())
+	BaseContext context.Context
+
+	// The deadline for each eth_getCode or batch request, so every request has one even when the caller's context doesn't (0 = no deadline beyond the one in the context)
+	DefaultTimeout time.Duration
+
+	// The executor that runs the batcher's requests (nil = no shared executor; see Executor)
+	Executor *Executor
+
+	// The Execution client binding
+	client ICodeReader
+}
+
+// A read of an account's code at a block
+type codeQuery struct {
+	// The account to read the code of
+	address common.Address
+
+	// The block to read the code at (nil = latest, unless blockHash is set)
+	blockNumber *big.Int
+
+	// The hash of the block to read the code at, which takes priority over the number (nil = read by number)
+	blockHash *common.Hash
+}
+
+// Gets the options that target the query's block
+func (q codeQuery) options() *callOptions {
+	return &callOptions{
+		blockNumber: q.blockNumber,
+		blockHash:   q.blockHash,
+	}
+}
+
+// Creates a new CodeBatcher instance
+func NewCodeBatcher(client ICodeReader, threadLimit int) *CodeBatcher {
+	return &CodeBatcher{
+		client:      client,
+		ThreadLimit: threadLimit,
+	}
+}
+
+// Retrieves the runtime code of a list of accounts, which is empty for accounts that aren't contracts.
+// The order of the resulting array corresponds to the order of the provided addresses.
+// If the client implements IBatchCaller, the code is read with JSON-RPC batch requests instead of one request each.
+func (b *CodeBatcher) GetCode(addresses []common.Address, opts *bind.CallOpts) ([][]byte, error) {
+	options, err := b.newCodeOptions(opts)
+	if err != nil {
+		return nil, err
+	}
+	queries := make([]codeQuery, len(addresses))
+	for i, address := range addresses {
+		queries[i] = codeQuery{
+			address:     address,
+			blockNumber: options.blockNumber,
+			blockHash:   options.blockHash,
+		}
+	}
+	code, err := b.getCode(options.ctx, queries)
+	if err != nil {
+		return nil, fmt.Errorf("error getting code: %w", err)
+	}
+	return code, nil
+}
+
+// Finds the block a contract was deployed in, which is the first block its code exists at, by searching the blocks up to the one in opts
+// (or the latest block, if the client implements IBlockNumberReader). Each round of the search reads the code at SearchWidth blocks together,
+// narrowing the range to the gap between the last one without code and the first one with it, so this is useful for bounding log backfills
+// without knowing when the contract was deployed. The client must be an archive node to read the code at old blocks.
+// The search assumes the code stays in place once it's deployed, so contracts that were self-destructed and redeployed may report a later deployment.
+// Returns an error wrapping ErrNotDeployed if there's no code at the address as of the last block.
+func (b *CodeBatcher) FindCreationBlock(address common.Address, opts *bind.CallOpts) (uint64, error) {
+	options, err := b.newCodeOptions(opts)
+	if err != nil {
+		return 0, err
+	}
+	if options.blockHash != nil {
+		return 0, fmt.Errorf("the search for a creation block is by block number, so opts can't target a block hash")
+	}
+	last, err := b.getSearchEnd(options)
+	if err != nil {
+		return 0, err
+	}
+	width := b.SearchWidth
+	if width <= 0 {
+		width = defaultCreationSearchWidth
+	}
+
+	code, err := b.getCode(options.ctx, []codeQuery{{address: address, blockNumber: new(big.Int).SetUint64(last)}})
+	if err != nil {
+		return 0, fmt.Errorf("error getting code of contract %s: %w", address.Hex(), err)
+	}
+	if len(code[0]) == 0 {
+		return 0, fmt.Errorf("error finding creation block of %s as of block %d: %w", address.Hex(), last, ErrNotDeployed)
+	}
+
+	// The code exists at high, and the blocks before low are known not to have it
+	low := uint64(0)
+	high := last
+	for low < high {
+		blocks := getSearchBlocks(low, high, width)
+		queries := make([]codeQuery, len(blocks))
+		for i, block := range blocks {
+			queries[i] = codeQuery{
+				address:     address,
+				blockNumber: new(big.Int).SetUint64(block),
+			}
+		}
+		code, err := b.getCode(options.ctx, queries)
+		if err != nil {
+			return 0, fmt.Errorf("error getting code of contract %s between blocks %d and %d: %w", address.Hex(), low, high, err)
+		}
+
+		for i, block := range blocks {
+			if len(code[i]) > 0 {
+				high = block
+				break
+			}
+			low = block + 1
+		}
+	}
+	return high, nil
+}
+
+// Gets up to width evenly spaced blocks in [low, high) to probe, always including low
+func getSearchBlocks(low uint64, high uint64, width int) []uint64 {
+	count := high - low
+	if count <= uint64(width) {
+		blocks := make([]uint64, count)
+		for i := range blocks {
+			blocks[i] = low + uint64(i)
+		}
+		return blocks
+	}
+	blocks := make([]uint64, width)
+	step := count / uint64(width)
+	for i := range blocks {
+		blocks[i] = low + uint64(i)*step
+	}
+	return blocks
+}
+
+// Gets the last block to search for a contract's deployment in
+func (b *CodeBatcher) getSearchEnd(options *callOptions) (uint64, error) {
+	if options.blockNumber != nil {
+		if options.blockNumber.Sign() < 0 || !options.blockNumber.IsUint64() {
+			return 0, fmt.Errorf("the search must end at a block number, not %s", options.blockNumber.String())
+		}
+		return options.blockNumber.Uint64(), nil
+	}
+	reader, ok := b.client.(IBlockNumberReader)
+	if !ok {
+		return 0, fmt.Errorf("the client can't get the latest block number, so opts must specify the block to search up to")
+	}
+	ctx, cancel := withRequestTimeout(options.ctx, b.DefaultTimeout)
+	defer cancel()
+	blockNumber, err := reader.BlockNumber(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("error getting latest block number: %w", wrapClientError(err))
+	}
+	return blockNumber, nil
+}
+
+// Creates the options for reading code, which can't target the pending block
+func (b *CodeBatcher) newCodeOptions(opts *bind.CallOpts) (*callOptions, error) {
+	options := newCallOptionsWithBase(opts, b.BaseContext)
+	if options.from != (common.Address{}) {
+		return nil, fmt.Errorf("code reads don't have a sender, so opts can't specify a From address")
+	}
+	if options.pending {
+		return nil, fmt.Errorf("code can't be read from the pending block")
+	}
+	return options, nil
+}
+
+// Reads the code for each query, with JSON-RPC batch requests if the client supports them
+func (b *CodeBatcher) getCode(ctx context.Context, queries []codeQuery) ([][]byte, error) {
+	if batchCaller, ok := b.client.(IBatchCaller); ok {
+		return b.getCodeBatched(ctx, batchCaller, queries)
+	}
+	code := make([][]byte, len(queries))
+
+	// A failure in any read cancels the rest of them
+	err := b.Executor.run(ctx, b.ThreadLimit, "eth_getCode", len(queries), nil, func(ctx context.Context, i int) error {
+		query := queries[i]
+		readCtx, cancel := withRequestTimeout(ctx, b.DefaultTimeout)
+		defer cancel()
+		value, err := query.options().codeAt(readCtx, b.client, query.address)
+		if err != nil {
+			return fmt.Errorf("error reading code of %s: %w", query.address.Hex(), wrapClientError(err))
+		}
+		code[i] = value
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return code, nil
+}
+
+// Reads the code for each query with JSON-RPC batch requests
+func (b *CodeBatcher) getCodeBatched(ctx context.Context, caller IBatchCaller, queries []codeQuery) ([][]byte, error) {
+	results := make([]hexutil.Bytes, len(queries))
+	elems := make([]rpc.BatchElem, len(queries))
+	for i, query := range queries {
+		elems[i] = rpc.BatchElem{
+			Method: "eth_getCode",
+			Args:   []any{query.address, query.options().blockArg()},
+			Result: &results[i],
+		}
+	}
+	err := runRpcBatches(ctx, b.Executor, caller, "eth_getCode", elems, b.RpcBatchSize, b.ThreadLimit, b.DefaultTimeout)
+	if err != nil {
+		return nil, err
+	}
+
+	code := make([][]byte, len(queries))
+	for i, query := range queries {
+		if elems[i].Error != nil {
+			return nil, fmt.Errorf("error reading code of %s: %w", query.address.Hex(), wrapClientError(elems[i].Error))
+		}
+		code[i] = results[i]
+	}
+	return code, nil
+}
@@ -0,0 +1,77 @@
+package batchquery
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+var (
+	// The hash of the runtime code of the canonical Multicall3 build, as deployed to Multicall3Address: https://github.com/mds1/multicall
+	Multicall3CodeHash = common.HexToHash("0xd5c15df687b16f2ff992fc8d767b4216323184a2bbc6ee2f9c398c318e770891")
+
+	// The code hashes of the multicall builds that MultiCaller.VerifyCode accepts by default
+	KnownMulticallCodeHashes = []common.Hash{
+		Multicall3CodeHash,
+	}
+)
+
+// Checks that the code deployed at the address matches one of the expected code hashes (the keccak256 hash of the runtime code),
+// so a batcher that was configured with the wrong address fails with a clear error instead of returning garbage.
+// Returns an error wrapping ErrUnexpectedCode if there's no code at the address or if it doesn't match.
+func VerifyContractCode(client ICodeReader, address common.Address, expectedHashes []common.Hash, opts *bind.CallOpts) error {
+	if len(expectedHashes) == 0 {
+		return fmt.Errorf("no expected code hashes were provided for the contract at %s", address.Hex())
+	}
+	options := newCallOptions(opts)
+	if options.pending {
+		return fmt.Errorf("contract code can't be verified against the pending block")
+	}
+
+	code, err := options.codeAt(options.ctx, client, address)
+	if err != nil {
+		return fmt.Errorf("error getting code of contract %s: %w", address.Hex(), wrapClientError(err))
+	}
+	if len(code) == 0 {
+		return fmt.Errorf("no contract is deployed at %s: %w", address.Hex(), ErrUnexpectedCode)
+	}
+	codeHash := crypto.Keccak256Hash(code)
+	for _, expectedHash := range expectedHashes {
+		if codeHash == expectedHash {
+			return nil
+		}
+	}
+
+	expected := make([]string, len(expectedHashes))
+	for i, expectedHash := range expectedHashes {
+		expected[i] = expectedHash.Hex()
+	}
+	return fmt.Errorf("contract at %s has code hash %s, but expected one of [%s]: %w", address.Hex(), codeHash.Hex(), strings.Join(expected, ", "), ErrUnexpectedCode)
+}
+
+// Checks that the MultiCaller's multicall contract is one of the known builds, or one of the provided code hashes if there are any.
+// Call this before the MultiCaller's first use to fail early if it was pointed at the wrong contract. The client must implement ICodeReader.
+func (mc *MultiCaller) VerifyCode(expectedHashes []common.Hash, opts *bind.CallOpts) error {
+	reader, ok := mc.client.(ICodeReader)
+	if !ok {
+		return fmt.Errorf("client does not support reading contract code")
+	}
+	if len(expectedHashes) == 0 {
+		expectedHashes = KnownMulticallCodeHashes
+	}
+	return VerifyContractCode(reader, mc.contractAddress, expectedHashes, opts)
+}
+
+// Checks that the BalanceBatcher's contract matches one of the provided code hashes.
+// Call this before the BalanceBatcher's first use to fail early if it was pointed at the wrong contract. The client must implement ICodeReader.
+// There's no default, since eth-balance-checker builds differ between deployments.
+func (b *BalanceBatcher) VerifyCode(expectedHashes []common.Hash, opts *bind.CallOpts) error {
+	reader, ok := b.client.(ICodeReader)
+	if !ok {
+		return fmt.Errorf("client does not support reading contract code")
+	}
+	return VerifyContractCode(reader, b.contractAddress, expectedHashes, opts)
+}
@@ -0,0 +1,133 @@
+package batchquery
+
+import (
+	"context"
+	"errors"
+	"math/big"
+	"sync"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+// A code reader where a contract is deployed at testTokenAddress in deployBlock, and the latest block is head
+type mockDeploymentReader struct {
+	// The block the contract was deployed in
+	deployBlock uint64
+
+	// The number of the latest block
+	head uint64
+
+	// The number of code reads
+	reads int
+
+	// Lock for the read count
+	lock sync.Mutex
+}
+
+func (m *mockDeploymentReader) CodeAt(ctx context.Context, account common.Address, blockNumber *big.Int) ([]byte, error) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	m.reads++
+	block := m.head
+	if blockNumber != nil {
+		block = blockNumber.Uint64()
+	}
+	if account != testTokenAddress || block < m.deployBlock || block > m.head {
+		return []byte{}, nil
+	}
+	return []byte{0x60, 0x80}, nil
+}
+
+func (m *mockDeploymentReader) BlockNumber(ctx context.Context) (uint64, error) {
+	return m.head, nil
+}
+
+// A deployment reader that reads code with JSON-RPC batch requests
+type mockBatchDeploymentReader struct {
+	mockDeploymentReader
+
+	// The number of batch requests
+	batches int
+}
+
+func (m *mockBatchDeploymentReader) BatchCallContext(ctx context.Context, elems []rpc.BatchElem) error {
+	m.batches++
+	for i := range elems {
+		block := (*big.Int)(elems[i].Args[1].(*hexutil.Big))
+		code, err := m.CodeAt(ctx, elems[i].Args[0].(common.Address), block)
+		if err != nil {
+			return err
+		}
+		*elems[i].Result.(*hexutil.Bytes) = code
+	}
+	return nil
+}
+
+func TestFindCreationBlock(t *testing.T) {
+	for _, deployBlock := range []uint64{0, 1, 12345, 999_999, 1_000_000} {
+		reader := &mockDeploymentReader{deployBlock: deployBlock, head: 1_000_000}
+		batcher := NewCodeBatcher(reader, 0)
+		block, err := batcher.FindCreationBlock(testTokenAddress, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if block != deployBlock {
+			t.Fatalf("expected the contract to be found in block %d, got %d", deployBlock, block)
+		}
+	}
+
+	// Each round of the search narrows the range by the search width
+	reader := &mockBatchDeploymentReader{mockDeploymentReader: mockDeploymentReader{deployBlock: 54321, head: 1_000_000}}
+	batcher := NewCodeBatcher(reader, 0)
+	batcher.SearchWidth = 100
+	block, err := batcher.FindCreationBlock(testTokenAddress, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if block != 54321 || reader.batches > 4 {
+		t.Fatalf("expected block 54321 within 4 batches, got %d after %d batches", block, reader.batches)
+	}
+}
+
+func TestFindCreationBlockErrors(t *testing.T) {
+	reader := &mockDeploymentReader{deployBlock: 500, head: 1000}
+	batcher := NewCodeBatcher(reader, 0)
+
+	// There's no contract as of the last block of the search
+	_, err := batcher.FindCreationBlock(testTokenAddress, &bind.CallOpts{BlockNumber: big.NewInt(400)})
+	if !errors.Is(err, ErrNotDeployed) {
+		t.Fatalf("expected the contract not to be deployed yet, got %v", err)
+	}
+	_, err = batcher.FindCreationBlock(common.HexToAddress("0x0102"), nil)
+	if !errors.Is(err, ErrNotDeployed) {
+		t.Fatalf("expected an account without code not to be deployed, got %v", err)
+	}
+
+	// Clients that can't get the latest block need the end of the search
+	batcher = NewCodeBatcher(&mockClient{}, 0)
+	_, err = batcher.FindCreationBlock(testTokenAddress, nil)
+	if err == nil {
+		t.Fatal("expected the search to need a block number")
+	}
+	_, err = batcher.FindCreationBlock(testTokenAddress, &bind.CallOpts{Pending: true})
+	if err == nil {
+		t.Fatal("expected a search of the pending block to be rejected")
+	}
+}
+
+func TestGetCode(t *testing.T) {
+	reader := &mockBatchDeploymentReader{mockDeploymentReader: mockDeploymentReader{deployBlock: 500, head: 1000}}
+	batcher := NewCodeBatcher(reader, 0)
+	addresses := []common.Address{testTokenAddress, common.HexToAddress("0x0102")}
+	code, err := batcher.GetCode(addresses, &bind.CallOpts{BlockNumber: big.NewInt(600)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(code[0]) == 0 || len(code[1]) != 0 || reader.batches != 1 {
+		t.Fatalf("expected the contract's code in a single batch, got %v after %d batches", code, reader.batches)
+	}
+}
@@ -0,0 +1,50 @@
+package batchquery
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+func TestVerifyCode(t *testing.T) {
+	mc, client := newTestMultiCaller(t)
+	code := []byte{0x60, 0x80, 0x60, 0x40}
+	client.code = map[common.Address][]byte{testMulticallAddress: code}
+
+	// The mock code isn't a known build
+	err := mc.VerifyCode(nil, nil)
+	if !errors.Is(err, ErrUnexpectedCode) {
+		t.Fatalf("expected an unexpected code error, got %v", err)
+	}
+
+	// But it matches its own hash
+	err = mc.VerifyCode([]common.Hash{Multicall3CodeHash, crypto.Keccak256Hash(code)}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// An address without code fails
+	client.code = nil
+	err = mc.VerifyCode([]common.Hash{crypto.Keccak256Hash(code)}, nil)
+	if !errors.Is(err, ErrUnexpectedCode) {
+		t.Fatalf("expected an unexpected code error for a missing contract, got %v", err)
+	}
+}
+
+func TestBalanceBatcherVerifyCodeRequiresHashes(t *testing.T) {
+	client := &mockClient{code: map[common.Address][]byte{testTokenAddress: {0x01}}}
+	batcher, err := NewBalanceBatcher(client, testTokenAddress, 10, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = batcher.VerifyCode(nil, nil)
+	if err == nil {
+		t.Fatal("expected verification without any hashes to fail")
+	}
+	err = batcher.VerifyCode([]common.Hash{crypto.Keccak256Hash([]byte{0x01})}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
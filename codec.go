@@ -0,0 +1,161 @@
+package batchquery
+
+import (
+	"encoding/binary"
+	"fmt"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// Encodes the call data for a tryAggregate invocation without using reflection.
+// The call data for each call must already be packed.
+// The buffer is allocated at its exact final size up front, so encoding takes a single allocation.
+func encodeTryAggregate(requireSuccess bool, calls []*Call) []byte {
+	data := newAggregateCallData(multicallAbi.Methods["tryAggregate"].ID, 1, calls)
+	if requireSuccess {
+		data[4+wordSize-1] = 1
+	}
+	return data
+}
+
+// Encodes the call data for a Multicall v1 aggregate invocation without using reflection, like encodeTryAggregate
+func encodeAggregate(calls []*Call) []byte {
+	return newAggregateCallData(multicallAbi.Methods["aggregate"].ID, 0, calls)
+}
+
+// Creates the call data for a function that takes a number of static words followed by the call array, leaving the static words empty
+func newAggregateCallData(selector []byte, staticWords int, calls []*Call) []byte {
+	headerSize := 4 + (staticWords+2)*wordSize
+	size := headerSize
+	for _, call := range calls {
+		size += call.aggregatedCallDataSize()
+	}
+	data := make([]byte, size)
+
+	// Header
+	copy(data, selector)
+	putWord(data[4+staticWords*wordSize:], uint64((staticWords+1)*wordSize))
+	putWord(data[4+(staticWords+1)*wordSize:], uint64(len(calls)))
+
+	// Tuple offsets are relative to the start of the array contents, which begin after the length word
+	arrayStart := headerSize
+	tupleOffset := wordSize * len(calls)
+	for i, call := range calls {
+		putWord(data[arrayStart+wordSize*i:], uint64(tupleOffset))
+		tuple := data[arrayStart+tupleOffset:]
+		copy(tuple[wordSize-common.AddressLength:], call.Target[:])
+		putWord(tuple[wordSize:], uint64(2*wordSize))
+		putWord(tuple[2*wordSize:], uint64(len(call.CallData)))
+		copy(tuple[3*wordSize:], call.CallData)
+		tupleOffset += call.aggregatedCallDataSize() - wordSize
+	}
+	return data
+}
+
+// Decodes a tryAggregate response into the provided results slice without using reflection.
+// The return data of each result references the response buffer directly rather than being copied.
+func decodeTryAggregate(response []byte, results []CallResponse) error {
+	arrayOffset, err := readWord(response, 0)
+	if err != nil {
+		return err
+	}
+	count, err := readWord(response, arrayOffset)
+	if err != nil {
+		return err
+	}
+	if count != uint64(len(results)) {
+		return fmt.Errorf("received %d responses which mismatches chunk size %d", count, len(results))
+	}
+
+	arrayStart := arrayOffset + wordSize
+	for i := range results {
+		tupleOffset, err := readWord(response, arrayStart+uint64(i)*wordSize)
+		if err != nil {
+			return err
+		}
+		tupleStart := arrayStart + tupleOffset
+		status, err := readWord(response, tupleStart)
+		if err != nil {
+			return err
+		}
+		dataOffset, err := readWord(response, tupleStart+wordSize)
+		if err != nil {
+			return err
+		}
+		dataStart := tupleStart + dataOffset
+		dataLength, err := readWord(response, dataStart)
+		if err != nil {
+			return err
+		}
+		dataStart += wordSize
+		if dataLength > uint64(len(response)) || dataStart > uint64(len(response))-dataLength {
+			return fmt.Errorf("return data for response %d is out of bounds", i)
+		}
+
+		results[i] = CallResponse{
+			Status:     status != 0,
+			ReturnData: response[dataStart : dataStart+dataLength : dataStart+dataLength],
+		}
+	}
+	return nil
+}
+
+// Decodes a Multicall v1 aggregate response into the provided results slice without using reflection.
+// Every call in a successful aggregate succeeded, since any failure reverts the whole invocation.
+// The return data of each result references the response buffer directly rather than being copied.
+func decodeAggregate(response []byte, results []CallResponse) error {
+	// The block number comes first, followed by the offset of the return data array
+	arrayOffset, err := readWord(response, wordSize)
+	if err != nil {
+		return err
+	}
+	count, err := readWord(response, arrayOffset)
+	if err != nil {
+		return err
+	}
+	if count != uint64(len(results)) {
+		return fmt.Errorf("received %d responses which mismatches chunk size %d", count, len(results))
+	}
+
+	arrayStart := arrayOffset + wordSize
+	for i := range results {
+		dataOffset, err := readWord(response, arrayStart+uint64(i)*wordSize)
+		if err != nil {
+			return err
+		}
+		dataStart := arrayStart + dataOffset
+		dataLength, err := readWord(response, dataStart)
+		if err != nil {
+			return err
+		}
+		dataStart += wordSize
+		if dataLength > uint64(len(response)) || dataStart > uint64(len(response))-dataLength {
+			return fmt.Errorf("return data for response %d is out of bounds", i)
+		}
+
+		results[i] = CallResponse{
+			Status:     true,
+			ReturnData: response[dataStart : dataStart+dataLength : dataStart+dataLength],
+		}
+	}
+	return nil
+}
+
+// Writes a value into the last 8 bytes of an ABI word
+func putWord(word []byte, value uint64) {
+	binary.BigEndian.PutUint64(word[wordSize-8:wordSize], value)
+}
+
+// Reads the ABI word at the provided offset as an integer, making sure it fits within 64 bits
+func readWord(data []byte, offset uint64) (uint64, error) {
+	if offset > uint64(len(data)) || uint64(len(data))-offset < wordSize {
+		return 0, fmt.Errorf("word at offset %d is out of bounds", offset)
+	}
+	word := data[offset : offset+wordSize]
+	for _, b := range word[:wordSize-8] {
+		if b != 0 {
+			return 0, fmt.Errorf("word at offset %d overflows 64 bits", offset)
+		}
+	}
+	return binary.BigEndian.Uint64(word[wordSize-8:]), nil
+}
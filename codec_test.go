@@ -0,0 +1,247 @@
+package batchquery
+
+import (
+	"bytes"
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// The argument form of a call for the regular ABI packer
+type aggregateCall struct {
+	Target   common.Address
+	CallData []byte
+}
+
+// The output form of a result for the regular ABI packer
+type aggregateResult struct {
+	Success    bool
+	ReturnData []byte
+}
+
+// Creates a set of packed calls with varying call data lengths, including empty and non-word-aligned data
+func newCodecTestCalls(t testing.TB, count int) []*Call {
+	if _, err := getMulticallAbi(); err != nil {
+		t.Fatal(err)
+	}
+	calls := make([]*Call, count)
+	for i := range calls {
+		callData := make([]byte, (i*7)%100)
+		for j := range callData {
+			callData[j] = byte(i + j)
+		}
+		calls[i] = &Call{
+			Target:   common.BigToAddress(big.NewInt(int64(i + 1))),
+			CallData: callData,
+		}
+	}
+	return calls
+}
+
+// Converts calls to the regular ABI packer's form
+func toAggregateCalls(calls []*Call) []aggregateCall {
+	converted := make([]aggregateCall, len(calls))
+	for i, call := range calls {
+		converted[i] = aggregateCall{
+			Target:   call.Target,
+			CallData: call.CallData,
+		}
+	}
+	return converted
+}
+
+// Creates a tryAggregate response with varying return data lengths using the regular ABI packer
+func newCodecTestResponse(t testing.TB, count int) ([]byte, []aggregateResult) {
+	results := make([]aggregateResult, count)
+	for i := range results {
+		returnData := make([]byte, (i*13)%200)
+		for j := range returnData {
+			returnData[j] = byte(i * j)
+		}
+		results[i] = aggregateResult{
+			Success:    i%3 != 0,
+			ReturnData: returnData,
+		}
+	}
+	response, err := multicallAbi.Methods["tryAggregate"].Outputs.Pack(results)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return response, results
+}
+
+func TestEncodeTryAggregateMatchesAbiPack(t *testing.T) {
+	for _, count := range []int{0, 1, 2, 37} {
+		for _, requireSuccess := range []bool{false, true} {
+			calls := newCodecTestCalls(t, count)
+			expected, err := multicallAbi.Pack("tryAggregate", requireSuccess, toAggregateCalls(calls))
+			if err != nil {
+				t.Fatal(err)
+			}
+			actual := encodeTryAggregate(requireSuccess, calls)
+			if !bytes.Equal(expected, actual) {
+				t.Fatalf("encoding of %d calls with requireSuccess=%v differs from abi.Pack:\nexpected %x\nactual   %x", count, requireSuccess, expected, actual)
+			}
+		}
+	}
+}
+
+func TestDecodeTryAggregateMatchesAbiUnpack(t *testing.T) {
+	for _, count := range []int{0, 1, 2, 37} {
+		response, expected := newCodecTestResponse(t, count)
+		results := make([]CallResponse, count)
+		err := decodeTryAggregate(response, results)
+		if err != nil {
+			t.Fatalf("unexpected error decoding %d results: %v", count, err)
+		}
+		for i, result := range results {
+			if result.Status != expected[i].Success || !bytes.Equal(result.ReturnData, expected[i].ReturnData) {
+				t.Fatalf("result %d of %d differs: expected %v %x, got %v %x", i, count, expected[i].Success, expected[i].ReturnData, result.Status, result.ReturnData)
+			}
+		}
+	}
+}
+
+func TestEncodeAggregateMatchesAbiPack(t *testing.T) {
+	for _, count := range []int{0, 1, 2, 37} {
+		calls := newCodecTestCalls(t, count)
+		expected, err := multicallAbi.Pack("aggregate", toAggregateCalls(calls))
+		if err != nil {
+			t.Fatal(err)
+		}
+		actual := encodeAggregate(calls)
+		if !bytes.Equal(expected, actual) {
+			t.Fatalf("encoding of %d calls differs from abi.Pack:\nexpected %x\nactual   %x", count, expected, actual)
+		}
+	}
+}
+
+func TestDecodeAggregateMatchesAbiUnpack(t *testing.T) {
+	for _, count := range []int{0, 1, 2, 37} {
+		returnData := make([][]byte, count)
+		for i := range returnData {
+			returnData[i] = bytes.Repeat([]byte{byte(i)}, (i*13)%200)
+		}
+		response, err := multicallAbi.Methods["aggregate"].Outputs.Pack(big.NewInt(100), returnData)
+		if err != nil {
+			t.Fatal(err)
+		}
+		results := make([]CallResponse, count)
+		err = decodeAggregate(response, results)
+		if err != nil {
+			t.Fatalf("unexpected error decoding %d results: %v", count, err)
+		}
+		for i, result := range results {
+			if !result.Status || !bytes.Equal(result.ReturnData, returnData[i]) {
+				t.Fatalf("result %d of %d differs: expected %x, got %v %x", i, count, returnData[i], result.Status, result.ReturnData)
+			}
+		}
+		if count > 0 && decodeAggregate(response[:len(response)-wordSize], results) == nil {
+			t.Fatalf("expected a truncated response of %d results to fail", count)
+		}
+	}
+}
+
+func TestDecodeTryAggregateRejectsMalformedResponses(t *testing.T) {
+	response, _ := newCodecTestResponse(t, 3)
+
+	// Wrong number of results
+	err := decodeTryAggregate(response, make([]CallResponse, 2))
+	if err == nil {
+		t.Fatal("expected an error for a mismatched result count")
+	}
+
+	// Truncated response
+	for _, length := range []int{0, 31, wordSize * 3, len(response) / 2} {
+		err = decodeTryAggregate(response[:length], make([]CallResponse, 3))
+		if err == nil {
+			t.Fatalf("expected an error for a response truncated to %d bytes", length)
+		}
+	}
+
+	// An offset that points past the end of the response
+	corrupted := append([]byte{}, response...)
+	corrupted[wordSize-1] = 0xff
+	err = decodeTryAggregate(corrupted, make([]CallResponse, 3))
+	if err == nil {
+		t.Fatal("expected an error for an out of bounds offset")
+	}
+
+	// An offset that overflows 64 bits
+	corrupted = append([]byte{}, response...)
+	corrupted[0] = 1
+	err = decodeTryAggregate(corrupted, make([]CallResponse, 3))
+	if err == nil {
+		t.Fatal("expected an error for an overflowing offset")
+	}
+}
+
+func TestCodecRoundTripThroughMultiCaller(t *testing.T) {
+	mc, _ := newTestMultiCaller(t)
+	balances := make([]*big.Int, 50)
+	for i := range balances {
+		mc.AddCall(testTokenAddress, &testTokenAbi, &balances[i], "balanceOf", common.BigToAddress(big.NewInt(int64(i))))
+	}
+	var list []*big.Int
+	mc.AddCall(testTokenAddress, &testTokenAbi, &list, "list", big.NewInt(20))
+	successes, err := mc.FlexibleCall(true, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, balance := range balances {
+		if !successes[i] || balance.Int64() != int64(i) {
+			t.Fatalf("call %d returned %v %s", i, successes[i], balance)
+		}
+	}
+	if len(list) != 20 || list[19].Int64() != 19 {
+		t.Fatalf("list call returned %v", list)
+	}
+}
+
+func BenchmarkEncodeTryAggregate(b *testing.B) {
+	calls := newCodecTestCalls(b, 1000)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		encodeTryAggregate(false, calls)
+	}
+}
+
+func BenchmarkAbiPackTryAggregate(b *testing.B) {
+	calls := toAggregateCalls(newCodecTestCalls(b, 1000))
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		_, err := multicallAbi.Pack("tryAggregate", false, calls)
+		if err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkDecodeTryAggregate(b *testing.B) {
+	response, _ := newCodecTestResponse(b, 1000)
+	results := make([]CallResponse, 1000)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		err := decodeTryAggregate(response, results)
+		if err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkAbiUnpackTryAggregate(b *testing.B) {
+	response, _ := newCodecTestResponse(b, 1000)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		var results []aggregateResult
+		err := multicallAbi.UnpackIntoInterface(&results, "tryAggregate", response)
+		if err != nil {
+			b.Fatal(err)
+		}
+	}
+}
@@ -0,0 +1,170 @@
+package batchquery
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	// The cost of a request whose method doesn't have a cost of its own, if the budget doesn't set one
+	defaultComputeUnitCost uint64 = 1
+)
+
+// The compute unit budget of the current window was used up, and the budget doesn't throttle requests until the next one
+var ErrComputeBudgetExhausted = errors.New("compute unit budget is exhausted")
+
+// A budget of compute units for a hosted provider, which bills each request by a cost that depends on its method rather than counting requests.
+// The budget is refilled at the start of each window; once it's used up, requests either wait for the next window or fail with ErrComputeBudgetExhausted.
+// Give it to the Executor shared by the batchers that use the provider.
+type ComputeBudget struct {
+	// The cost of each JSON-RPC method in compute units, such as "eth_call" or "eth_getLogs", as listed by the provider
+	Costs map[string]uint64
+
+	// The cost of methods that aren't in Costs (0 = 1 unit)
+	DefaultCost uint64
+
+	// Whether requests wait for the next window when the budget is used up, rather than failing with ErrComputeBudgetExhausted.
+	// Budgets that are never refilled can't throttle, so they always fail.
+	Throttle bool
+
+	// The number of compute units available in each window
+	limit uint64
+
+	// The length of each window
+	window time.Duration
+
+	// The start of the current window
+	windowStart time.Time
+
+	// The compute units used in the current window
+	used uint64
+
+	// Lock for the window
+	lock sync.Mutex
+}
+
+// The compute units a budget has used in its current window
+type ComputeUsage struct {
+	// The compute units used so far
+	Used uint64
+
+	// The compute units available in each window
+	Limit uint64
+
+	// The start of the current window
+	WindowStart time.Time
+
+	// The length of each window
+	Window time.Duration
+}
+
+// Creates a new ComputeBudget with the provided number of compute units per window (a window of 0 = the budget is never refilled), and the cost of each method
+func NewComputeBudget(limit uint64, window time.Duration, costs map[string]uint64) *ComputeBudget {
+	return &ComputeBudget{
+		Costs:       costs,
+		limit:       limit,
+		window:      window,
+		windowStart: time.Now(),
+	}
+}
+
+// Gets the compute units the budget has used in its current window
+func (b *ComputeBudget) Usage() ComputeUsage {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	b.advanceWindow(time.Now())
+	return ComputeUsage{
+		Used:        b.used,
+		Limit:       b.limit,
+		WindowStart: b.windowStart,
+		Window:      b.window,
+	}
+}
+
+// Gets the cost of a number of requests with the provided method
+func (b *ComputeBudget) cost(method string, requests int) uint64 {
+	cost, exists := b.Costs[method]
+	if !exists {
+		cost = b.DefaultCost
+		if cost == 0 {
+			cost = defaultComputeUnitCost
+		}
+	}
+	return cost * uint64(requests)
+}
+
+// Takes the cost of a number of requests from the budget, waiting for the next window if it's used up and the budget throttles requests.
+// The cost is also added to the ComputeMeter in the context, if there is one.
+func (b *ComputeBudget) spend(ctx context.Context, method string, requests int) error {
+	cost := b.cost(method, requests)
+	if cost > b.limit {
+		return fmt.Errorf("%d %s requests cost %d compute units, which is more than the budget of %d per window: %w", requests, method, cost, b.limit, ErrComputeBudgetExhausted)
+	}
+	for {
+		b.lock.Lock()
+		now := time.Now()
+		b.advanceWindow(now)
+		if b.used+cost <= b.limit {
+			b.used += cost
+			b.lock.Unlock()
+			meter, ok := ctx.Value(computeMeterKey{}).(*ComputeMeter)
+			if ok {
+				meter.add(cost)
+			}
+			return nil
+		}
+		wait := b.windowStart.Add(b.window).Sub(now)
+		b.lock.Unlock()
+		if !b.Throttle || b.window <= 0 {
+			return fmt.Errorf("%d %s requests need %d compute units: %w", requests, method, cost, ErrComputeBudgetExhausted)
+		}
+
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
+
+// Starts a new window if the current one is over, while the lock is held
+func (b *ComputeBudget) advanceWindow(now time.Time) {
+	if b.window <= 0 || now.Before(b.windowStart.Add(b.window)) {
+		return
+	}
+	elapsed := now.Sub(b.windowStart) / b.window
+	b.windowStart = b.windowStart.Add(elapsed * b.window)
+	b.used = 0
+}
+
+// The context key for a ComputeMeter
+type computeMeterKey struct{}
+
+// A running total of the compute units spent by the batches run with a context, so an application can see what each of its operations costs
+type ComputeMeter struct {
+	// The compute units spent so far
+	units uint64
+}
+
+// Creates a copy of the context that adds the compute units spent by every batch run with it to a new ComputeMeter.
+// Only requests that run through an Executor with a ComputeBudget are metered.
+func ContextWithComputeMeter(ctx context.Context) (context.Context, *ComputeMeter) {
+	meter := &ComputeMeter{}
+	return context.WithValue(ctx, computeMeterKey{}, meter), meter
+}
+
+// Gets the compute units spent so far
+func (m *ComputeMeter) Units() uint64 {
+	return atomic.LoadUint64(&m.units)
+}
+
+// Adds to the compute units spent
+func (m *ComputeMeter) add(units uint64) {
+	atomic.AddUint64(&m.units, units)
+}
@@ -0,0 +1,102 @@
+package batchquery
+
+import (
+	"context"
+	"errors"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// Creates a MultiCaller that runs each call in its own chunk, billed against the provided budget
+func newBudgetedMultiCaller(t *testing.T, budget *ComputeBudget) (*MultiCaller, *mockClient) {
+	mc, client := newTestMultiCaller(t)
+	mc.CallBatchSize = 1
+	mc.Executor = NewExecutor(0)
+	mc.Executor.Budget = budget
+	return mc, client
+}
+
+// Adds balance calls for several accounts
+func addBudgetedCalls(mc *MultiCaller, count int) {
+	for i := 0; i < count; i++ {
+		var balance *big.Int
+		mc.AddCall(testTokenAddress, &testTokenAbi, &balance, "balanceOf", common.BigToAddress(big.NewInt(int64(i))))
+	}
+}
+
+func TestComputeBudgetRejectsWorkOnceExhausted(t *testing.T) {
+	budget := NewComputeBudget(30, time.Hour, map[string]uint64{"eth_call": 10})
+	mc, client := newBudgetedMultiCaller(t, budget)
+	ctx, meter := ContextWithComputeMeter(context.Background())
+
+	addBudgetedCalls(mc, 3)
+	_, err := mc.FlexibleCall(true, &bind.CallOpts{Context: ctx})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if meter.Units() != 30 || budget.Usage().Used != 30 {
+		t.Fatalf("expected 3 chunks to cost 30 units, got %d metered and %+v", meter.Units(), budget.Usage())
+	}
+
+	addBudgetedCalls(mc, 1)
+	_, err = mc.FlexibleCall(true, nil)
+	if !errors.Is(err, ErrComputeBudgetExhausted) {
+		t.Fatalf("expected the exhausted budget to reject the batch, got %v", err)
+	}
+	if client.calls != 3 {
+		t.Fatalf("expected the rejected chunk not to be sent, got %d eth_calls", client.calls)
+	}
+}
+
+func TestComputeBudgetThrottlesUntilNextWindow(t *testing.T) {
+	window := 50 * time.Millisecond
+	budget := NewComputeBudget(10, window, map[string]uint64{"eth_call": 10})
+	budget.Throttle = true
+	mc, _ := newBudgetedMultiCaller(t, budget)
+	mc.ThreadLimit = 1
+
+	start := time.Now()
+	addBudgetedCalls(mc, 2)
+	_, err := mc.FlexibleCall(true, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if time.Since(start) < window/2 {
+		t.Fatalf("expected the second chunk to wait for the next window, but the batch took %s", time.Since(start))
+	}
+}
+
+func TestComputeBudgetCostsAndWindows(t *testing.T) {
+	budget := NewComputeBudget(100, time.Hour, map[string]uint64{"eth_getLogs": 75})
+	budget.DefaultCost = 5
+	if budget.cost("eth_getLogs", 1) != 75 || budget.cost("eth_call", 4) != 20 {
+		t.Fatalf("unexpected costs %d and %d", budget.cost("eth_getLogs", 1), budget.cost("eth_call", 4))
+	}
+
+	// Requests that can never fit are rejected even when throttling
+	budget.Throttle = true
+	err := budget.spend(context.Background(), "eth_getLogs", 2)
+	if !errors.Is(err, ErrComputeBudgetExhausted) {
+		t.Fatalf("expected a request larger than the budget to be rejected, got %v", err)
+	}
+
+	// A new window refills the budget
+	err = budget.spend(context.Background(), "eth_getLogs", 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	budget.windowStart = budget.windowStart.Add(-2 * time.Hour)
+	if budget.Usage().Used != 0 {
+		t.Fatalf("expected the budget to be refilled in the new window, got %+v", budget.Usage())
+	}
+
+	// JSON-RPC batches are billed for each request in them
+	sizes := getBatchSizes(250, 100)
+	if sizes(0) != 100 || sizes(2) != 50 {
+		t.Fatalf("unexpected batch sizes %d and %d", sizes(0), sizes(2))
+	}
+}
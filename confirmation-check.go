@@ -0,0 +1,126 @@
+package batchquery
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+	"sync"
+
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// The results of running the same batch at the head block and at a block a number of confirmations behind it
+type ConfirmationReport struct {
+	// The head block the batch was run at
+	HeadBlock *big.Int
+
+	// The block behind the head the batch was also run at, which is the head block minus the number of confirmations
+	ConfirmedBlock *big.Int
+
+	// The results from the head block
+	Head *Results
+
+	// The results from the confirmed block
+	Confirmed *Results
+
+	// The indices of the calls whose status or return data differ between the blocks, in order.
+	// Their values changed within the confirmation window, so they could still be undone by a reorg.
+	Unstable []int
+
+	// Whether or not each call's results differ between the blocks
+	unstable []bool
+}
+
+// Runs the batch at the head block and at the block the provided number of confirmations behind it concurrently, and flags the calls whose
+// results differ between them. Values that are the same at both blocks have been stable across the confirmation window, so risk-sensitive
+// consumers can act on them and treat the others as provisional.
+// If opts doesn't specify a block number, the head is the latest block according to the caller. The pending block isn't supported.
+func (b *Batch) ExecuteConfirmed(caller *MultiCaller, confirmations uint64, requireSuccess bool, opts *bind.CallOpts) (*ConfirmationReport, error) {
+	headOpts, err := pinCallOpts(caller, opts)
+	if err != nil {
+		return nil, err
+	}
+	confirmedBlock := new(big.Int).Sub(headOpts.BlockNumber, new(big.Int).SetUint64(confirmations))
+	if confirmedBlock.Sign() < 0 {
+		return nil, fmt.Errorf("head block %s doesn't have %d confirmations behind it", headOpts.BlockNumber.String(), confirmations)
+	}
+	confirmedOpts := *headOpts
+	confirmedOpts.BlockNumber = confirmedBlock
+
+	// Options created by CallOptsAtHash would pin both runs to the same block, so the confirmed run masks the hash and uses the number alone
+	if confirmedOpts.Context != nil {
+		if _, exists := confirmedOpts.Context.Value(blockHashKey{}).(common.Hash); exists {
+			confirmedOpts.Context = context.WithValue(confirmedOpts.Context, blockHashKey{}, nil)
+		}
+	}
+
+	var headResponses []CallResponse
+	var confirmedResponses []CallResponse
+	var headErr error
+	var confirmedErr error
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		headResponses, headErr = b.ExecuteRaw(caller, requireSuccess, headOpts)
+	}()
+	go func() {
+		defer wg.Done()
+		confirmedResponses, confirmedErr = b.ExecuteRaw(caller, requireSuccess, &confirmedOpts)
+	}()
+	wg.Wait()
+	if headErr != nil {
+		return nil, fmt.Errorf("error running batch at head block %s: %w", headOpts.BlockNumber.String(), headErr)
+	}
+	if confirmedErr != nil {
+		return nil, fmt.Errorf("error running batch at confirmed block %s: %w", confirmedBlock.String(), confirmedErr)
+	}
+
+	report := &ConfirmationReport{
+		HeadBlock:      new(big.Int).Set(headOpts.BlockNumber),
+		ConfirmedBlock: confirmedBlock,
+		Head:           newResults(b.calls, headResponses, nil),
+		Confirmed:      newResults(b.calls, confirmedResponses, nil),
+		Unstable:       []int{},
+		unstable:       make([]bool, len(b.calls)),
+	}
+	for i := range b.calls {
+		if !responsesMatch(headResponses[i], confirmedResponses[i]) {
+			report.Unstable = append(report.Unstable, i)
+			report.unstable[i] = true
+		}
+	}
+	return report, nil
+}
+
+// Checks whether the call with the provided index had the same result at both blocks
+func (r *ConfirmationReport) IsStable(index int) bool {
+	return !r.unstable[index]
+}
+
+// Checks whether every call had the same result at both blocks
+func (r *ConfirmationReport) AllStable() bool {
+	return len(r.Unstable) == 0
+}
+
+// Gets the result of the call with the provided key from the head block, and whether it was the same at both blocks.
+// Returns false for both if no call has the key.
+func (r *ConfirmationReport) GetStable(key string) (CallResult, bool, bool) {
+	result, exists := r.Head.Get(key)
+	if !exists {
+		return CallResult{}, false, false
+	}
+	return result, r.IsStable(result.Index), true
+}
+
+// Gets the results from the head block of the calls that had the same result at both blocks, in order
+func (r *ConfirmationReport) StableResults() []CallResult {
+	results := make([]CallResult, 0, r.Head.Len()-len(r.Unstable))
+	for _, result := range r.Head.All() {
+		if r.IsStable(result.Index) {
+			results = append(results, result)
+		}
+	}
+	return results
+}
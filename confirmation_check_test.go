@@ -0,0 +1,94 @@
+package batchquery
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+)
+
+func TestExecuteConfirmedFlagsUnstableResults(t *testing.T) {
+	mc, client := newTestMultiCaller(t)
+	account := common.HexToAddress("0x0102")
+	builder := mc.Clone()
+	builder.AddCall(testTokenAddress, &testTokenAbi, new(*big.Int), "balanceOf", account).WithKey("balance")
+	builder.AddCall(testTokenAddress, &testTokenAbi, new(uint8), "decimals").WithKey("decimals")
+	batch, err := builder.Snapshot()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	report, err := batch.ExecuteConfirmed(mc, 12, true, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.HeadBlock.Int64() != 100 || report.ConfirmedBlock.Int64() != 88 {
+		t.Fatalf("expected blocks 100 and 88, got %s and %s", report.HeadBlock, report.ConfirmedBlock)
+	}
+
+	// The balance changes every block, but the decimals don't
+	if report.AllStable() || len(report.Unstable) != 1 || report.Unstable[0] != 0 || report.IsStable(0) || !report.IsStable(1) {
+		t.Fatalf("expected only the balance to be unstable, got %v", report.Unstable)
+	}
+	if stable := report.StableResults(); len(stable) != 1 || stable[0].Key != "decimals" {
+		t.Fatalf("unexpected stable results %+v", stable)
+	}
+	result, stable, exists := report.GetStable("balance")
+	if !exists || stable {
+		t.Fatal("expected the balance to be found and unstable")
+	}
+	var head *big.Int
+	err = result.Unpack(&head)
+	if err != nil {
+		t.Fatal(err)
+	}
+	confirmedResult, _ := report.Confirmed.Get("balance")
+	var confirmed *big.Int
+	err = confirmedResult.Unpack(&confirmed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if head.Cmp(expectedBalance(account, 100)) != 0 || confirmed.Cmp(expectedBalance(account, 88)) != 0 {
+		t.Fatalf("unexpected balances %s and %s", head, confirmed)
+	}
+	if _, _, exists := report.GetStable("missing"); exists {
+		t.Fatal("expected no result for a missing key")
+	}
+
+	// Both blocks are queried with a multicall each
+	if sizes := client.getChunkSizes(); len(sizes) != 2 {
+		t.Fatalf("expected 2 multicalls, got %v", sizes)
+	}
+}
+
+func TestExecuteConfirmedAtExplicitBlocks(t *testing.T) {
+	mc, _ := newTestMultiCaller(t)
+	batch := newBalanceBatch(t, mc, common.HexToAddress("0x0102"))
+
+	report, err := batch.ExecuteConfirmed(mc, 0, true, &bind.CallOpts{BlockNumber: big.NewInt(50)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.HeadBlock.Int64() != 50 || report.ConfirmedBlock.Int64() != 50 || !report.AllStable() {
+		t.Fatalf("expected a stable report at block 50, got %+v", report)
+	}
+
+	// Options pinned to a hash still query the confirmed block by number
+	report, err = batch.ExecuteConfirmed(mc, 5, true, CallOptsAtHash(nil, common.HexToHash("0x1234")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.ConfirmedBlock.Int64() != report.HeadBlock.Int64()-5 || report.AllStable() {
+		t.Fatalf("expected the confirmed run to differ from the head, got %+v", report)
+	}
+
+	_, err = batch.ExecuteConfirmed(mc, 51, true, &bind.CallOpts{BlockNumber: big.NewInt(50)})
+	if err == nil {
+		t.Fatal("expected more confirmations than blocks to fail")
+	}
+	_, err = batch.ExecuteConfirmed(mc, 1, true, &bind.CallOpts{Pending: true})
+	if err == nil {
+		t.Fatal("expected the pending block to be rejected")
+	}
+}
@@ -0,0 +1,149 @@
+package batchquery
+
+import (
+	"fmt"
+	"math/big"
+	"sync"
+
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// The results of running the same batch against several endpoints
+type ConsensusReport struct {
+	// The block the batch was run at
+	BlockNumber *big.Int
+
+	// The raw responses from each endpoint, in the same order as the provided callers (nil for endpoints that failed)
+	Responses [][]CallResponse
+
+	// The error from each endpoint, in the same order as the provided callers (nil for endpoints that succeeded)
+	Errors []error
+
+	// The calls whose responses didn't match across all of the endpoints that succeeded
+	Mismatches []ConsensusMismatch
+}
+
+// A call whose responses differed between endpoints
+type ConsensusMismatch struct {
+	// The index of the call within the batch
+	Index int
+
+	// The contract address the call was run on
+	Target common.Address
+
+	// The name of the method that was called
+	Method string
+
+	// The indices of the endpoints grouped by identical responses, with the largest group first
+	Groups [][]int
+}
+
+// Runs the batch against every one of the provided MultiCallers concurrently and compares their raw responses,
+// reporting any calls that didn't match. This is useful for operators that don't fully trust a single RPC provider.
+// If opts doesn't specify a block number, every endpoint is queried at the latest block according to the first caller.
+// The pending block isn't supported, since each endpoint has its own view of it.
+// Endpoints that fail are reported in the errors of the report rather than failing the comparison;
+// an error is only returned if none of the endpoints succeeded.
+func (b *Batch) CompareAcross(callers []*MultiCaller, requireSuccess bool, opts *bind.CallOpts) (*ConsensusReport, error) {
+	if len(callers) == 0 {
+		return nil, fmt.Errorf("no endpoints were provided")
+	}
+	runOpts, err := pinCallOpts(callers[0], opts)
+	if err != nil {
+		return nil, err
+	}
+
+	report := &ConsensusReport{
+		BlockNumber: new(big.Int).Set(runOpts.BlockNumber),
+		Responses:   make([][]CallResponse, len(callers)),
+		Errors:      make([]error, len(callers)),
+		Mismatches:  []ConsensusMismatch{},
+	}
+	var wg sync.WaitGroup
+	for i, caller := range callers {
+		wg.Add(1)
+		go func(i int, caller *MultiCaller) {
+			defer wg.Done()
+			report.Responses[i], report.Errors[i] = b.ExecuteRaw(caller, requireSuccess, runOpts)
+		}(i, caller)
+	}
+	wg.Wait()
+
+	// Only compare the endpoints that succeeded
+	succeeded := []int{}
+	for i, err := range report.Errors {
+		if err == nil {
+			succeeded = append(succeeded, i)
+		}
+	}
+	if len(succeeded) == 0 {
+		return nil, fmt.Errorf("batch failed on every endpoint: %w", report.Errors[0])
+	}
+
+	for i, call := range b.calls {
+		groups := groupResponses(report.Responses, succeeded, i)
+		if len(groups) > 1 {
+			report.Mismatches = append(report.Mismatches, ConsensusMismatch{
+				Index:  i,
+				Target: call.Target,
+				Method: call.Method,
+				Groups: groups,
+			})
+		}
+	}
+	return report, nil
+}
+
+// Groups the endpoints by identical responses for a single call, with the largest group first.
+// Groups of the same size are ordered by their first endpoint.
+func groupResponses(responses [][]CallResponse, endpoints []int, callIndex int) [][]int {
+	groups := [][]int{}
+	for _, endpoint := range endpoints {
+		response := responses[endpoint][callIndex]
+		found := false
+		for i, group := range groups {
+			if responsesMatch(response, responses[group[0]][callIndex]) {
+				groups[i] = append(group, endpoint)
+				found = true
+				break
+			}
+		}
+		if !found {
+			groups = append(groups, []int{endpoint})
+		}
+	}
+
+	// Stable insertion sort by descending size, since there are only ever a handful of groups
+	for i := 1; i < len(groups); i++ {
+		for j := i; j > 0 && len(groups[j]) > len(groups[j-1]); j-- {
+			groups[j], groups[j-1] = groups[j-1], groups[j]
+		}
+	}
+	return groups
+}
+
+// Creates a copy of the call options that's pinned to a specific block, using the caller's latest block if opts doesn't specify one.
+// Options created by CallOptsAtHash keep their hash, and get the number of that block for anything that records it.
+// The pending block can't be pinned, since its contents change between calls, so options for it are rejected.
+func pinCallOpts(caller *MultiCaller, opts *bind.CallOpts) (*bind.CallOpts, error) {
+	var pinned bind.CallOpts
+	if opts != nil {
+		pinned = *opts
+	}
+	if pinned.Pending {
+		return nil, fmt.Errorf("calls against the pending block can't be pinned to a block")
+	}
+	options := caller.newCallOptions(&pinned)
+	if pinned.BlockNumber != nil && options.blockHash == nil {
+		pinned.BlockNumber = new(big.Int).Set(pinned.BlockNumber)
+		return &pinned, nil
+	}
+
+	blockNumber, err := caller.getBlockNumber(&callOptions{ctx: options.ctx, blockHash: options.blockHash})
+	if err != nil {
+		return nil, err
+	}
+	pinned.BlockNumber = blockNumber
+	return &pinned, nil
+}
@@ -0,0 +1,117 @@
+package batchquery
+
+import (
+	"fmt"
+	"reflect"
+	"unsafe"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+)
+
+const (
+	// How deeply the fields of an abigen binding are searched for its BoundContract
+	maxBindingSearchDepth int = 4
+)
+
+var (
+	boundContractType = reflect.TypeOf(&bind.BoundContract{})
+	addressType       = reflect.TypeOf(common.Address{})
+	abiType           = reflect.TypeOf(abi.ABI{})
+)
+
+// The address and ABI of a contract, taken from an existing binding so its calls can be added to a batch without re-specifying them
+type ContractBinding struct {
+	// The address of the contract
+	Address common.Address
+
+	// The ABI of the contract
+	Abi *abi.ABI
+}
+
+// Creates a new ContractBinding from a *bind.BoundContract, or from a contract binding generated by abigen (such as its Token, TokenCaller,
+// or TokenCallerSession structs, or pointers to them) that wraps one.
+// Neither of them exposes its address or ABI, so they're read from the BoundContract's fields; create the binding once and reuse it for every call.
+func NewContractBinding(contract any) (*ContractBinding, error) {
+	value := reflect.ValueOf(contract)
+	if !value.IsValid() {
+		return nil, fmt.Errorf("error binding contract: contract is nil")
+	}
+	bound := findBoundContract(value, 0)
+	if bound == nil {
+		return nil, fmt.Errorf("error binding contract: %s is not a *bind.BoundContract and doesn't contain one", value.Type())
+	}
+
+	// The fields are checked by type, so a change to BoundContract's layout is reported rather than read incorrectly
+	fields := reflect.ValueOf(bound).Elem()
+	addressField := fields.FieldByName("address")
+	abiField := fields.FieldByName("abi")
+	if !addressField.IsValid() || addressField.Type() != addressType || !abiField.IsValid() || abiField.Type() != abiType {
+		return nil, fmt.Errorf("error binding contract: the layout of bind.BoundContract isn't supported")
+	}
+	contractAbi := readUnexportedField(abiField).(abi.ABI)
+	return &ContractBinding{
+		Address: readUnexportedField(addressField).(common.Address),
+		Abi:     &contractAbi,
+	}, nil
+}
+
+// Creates a new ContractBinding for the contract at the provided address, using the ABI in the metadata of an abigen binding (such as TokenMetaData)
+func NewContractBindingFromMetaData(address common.Address, metaData *bind.MetaData) (*ContractBinding, error) {
+	contractAbi, err := metaData.GetAbi()
+	if err != nil {
+		return nil, fmt.Errorf("error parsing ABI from contract metadata: %w", err)
+	}
+	return &ContractBinding{
+		Address: address,
+		Abi:     contractAbi,
+	}, nil
+}
+
+// Adds a call to a contract through its binding, with the same semantics as AddCall
+func (mc *MultiCaller) AddBoundCall(binding *ContractBinding, output any, method string, args ...any) *Call {
+	return mc.AddCall(binding.Address, binding.Abi, output, method, args...)
+}
+
+// Finds the first non-nil *bind.BoundContract in a value or its fields, searching embedded and nested structs up to a limited depth
+func findBoundContract(value reflect.Value, depth int) *bind.BoundContract {
+	if value.Type() == boundContractType {
+		if value.IsNil() {
+			return nil
+		}
+		if value.CanInterface() {
+			return value.Interface().(*bind.BoundContract)
+		}
+		return readUnexportedField(value).(*bind.BoundContract)
+	}
+	if depth >= maxBindingSearchDepth {
+		return nil
+	}
+	switch value.Kind() {
+	case reflect.Pointer, reflect.Interface:
+		if value.IsNil() {
+			return nil
+		}
+		return findBoundContract(value.Elem(), depth+1)
+	case reflect.Struct:
+		if !value.CanAddr() {
+			// Copy the struct so its unexported fields can be read
+			copy := reflect.New(value.Type()).Elem()
+			copy.Set(value)
+			value = copy
+		}
+		for i := 0; i < value.NumField(); i++ {
+			bound := findBoundContract(value.Field(i), depth+1)
+			if bound != nil {
+				return bound
+			}
+		}
+	}
+	return nil
+}
+
+// Reads the value of an addressable unexported field
+func readUnexportedField(field reflect.Value) any {
+	return reflect.NewAt(field.Type(), unsafe.Pointer(field.UnsafeAddr())).Elem().Interface()
+}
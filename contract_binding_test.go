@@ -0,0 +1,92 @@
+package batchquery
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// The shape of the caller struct abigen generates for a contract
+type testTokenCaller struct {
+	contract *bind.BoundContract
+}
+
+// The shape of the transactor struct abigen generates for a contract
+type testTokenTransactor struct {
+	contract *bind.BoundContract
+}
+
+// The shape of the contract struct abigen generates, which embeds its caller and transactor
+type testTokenBinding struct {
+	testTokenCaller
+	testTokenTransactor
+}
+
+// The shape of the session struct abigen generates for a caller
+type testTokenCallerSession struct {
+	Contract *testTokenCaller
+	CallOpts bind.CallOpts
+}
+
+func TestContractBindings(t *testing.T) {
+	bound := bind.NewBoundContract(testTokenAddress, testTokenAbi, nil, nil, nil)
+	caller := &testTokenCaller{contract: bound}
+	contracts := map[string]any{
+		"BoundContract": bound,
+		"abigen struct": &testTokenBinding{testTokenCaller: *caller},
+		"abigen value":  testTokenBinding{testTokenCaller: *caller},
+		"abigen caller": caller,
+		"abigen session": &testTokenCallerSession{
+			Contract: caller,
+		},
+	}
+	for name, contract := range contracts {
+		binding, err := NewContractBinding(contract)
+		if err != nil {
+			t.Fatalf("%s: %s", name, err.Error())
+		}
+		if binding.Address != testTokenAddress || binding.Abi.Methods["balanceOf"].Sig != "balanceOf(address)" {
+			t.Fatalf("%s: unexpected binding for %s", name, binding.Address.Hex())
+		}
+
+		mc, _ := newTestMultiCaller(t)
+		var balance *big.Int
+		account := common.HexToAddress("0x0207")
+		mc.AddBoundCall(binding, &balance, "balanceOf", account)
+		_, err = mc.FlexibleCall(true, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if balance.Cmp(expectedBalance(account, 0)) != 0 {
+			t.Fatalf("%s: unexpected balance %s", name, balance)
+		}
+	}
+}
+
+func TestContractBindingFromMetaData(t *testing.T) {
+	binding, err := NewContractBindingFromMetaData(testTokenAddress, &bind.MetaData{ABI: testTokenAbiString})
+	if err != nil {
+		t.Fatal(err)
+	}
+	mc, _ := newTestMultiCaller(t)
+	var symbol string
+	mc.AddBoundCall(binding, &symbol, "symbol")
+	_, err = mc.FlexibleCall(true, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if symbol != "TST" {
+		t.Fatalf("unexpected symbol %q", symbol)
+	}
+}
+
+func TestContractBindingRejectsUnboundValues(t *testing.T) {
+	for _, contract := range []any{nil, 5, &testTokenCaller{}, struct{ Name string }{"token"}} {
+		_, err := NewContractBinding(contract)
+		if err == nil {
+			t.Fatalf("expected %v to be rejected", contract)
+		}
+	}
+}
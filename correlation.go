@@ -0,0 +1,155 @@
+package batchquery
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// The context key for the ID of the batch a request belongs to
+type batchIDKey struct{}
+
+// The context key for the ID of the chunk a request belongs to
+type chunkIDKey struct{}
+
+// Callbacks that a MultiCaller invokes as it runs batches, for logging, metrics, or tracing.
+// The context passed to each callback carries the batch's ID (and the chunk's ID for the chunk callbacks), which can be read with
+// BatchIDFromContext and ChunkIDFromContext. The same IDs are in the context of every request sent to the client, so a client middleware
+// can attach them to its requests, and they're included in the errors of failed batches so operators can match them with provider logs.
+// The chunk callbacks may be invoked concurrently. Any of the callbacks can be nil.
+type Hooks struct {
+	// Called before a batch's calls are run
+	OnBatchStart func(ctx context.Context, callCount int)
+
+	// Called once a batch has finished, with its error (nil = success)
+	OnBatchEnd func(ctx context.Context, callCount int, duration time.Duration, err error)
+
+	// Called before a chunk's request is sent
+	OnChunkStart func(ctx context.Context, callCount int)
+
+	// Called once a chunk's request has returned, with its error (nil = success)
+	OnChunkEnd func(ctx context.Context, callCount int, duration time.Duration, err error)
+
+	// Called when a chunk's request is hedged to the MultiCaller's HedgeClient
+	OnChunkHedged func(ctx context.Context, callCount int)
+
+	// Called once a run of a MultiCaller's batch has finished, with what each of its stages cost (including packing and unpacking, which
+	// the batch callbacks don't cover). Its context is the one the batch was run with, so it only carries a batch ID if one was assigned
+	// with ContextWithBatchID. Stats are only collected while this is set.
+	OnBatchStats func(ctx context.Context, stats BatchStats)
+}
+
+// The failure of a batch, tagged with the IDs of the batch and the chunk that failed
+type BatchError struct {
+	// The ID of the batch
+	BatchID string
+
+	// The ID of the chunk that failed (empty if the failure wasn't caused by a specific chunk)
+	ChunkID string
+
+	// The reason the batch failed
+	Err error
+}
+
+// Gets the error message, prefixed with the batch and chunk IDs
+func (e *BatchError) Error() string {
+	if e.ChunkID == "" {
+		return fmt.Sprintf("batch %s: %s", e.BatchID, e.Err.Error())
+	}
+	return fmt.Sprintf("batch %s, chunk %s: %s", e.BatchID, e.ChunkID, e.Err.Error())
+}
+
+// Gets the underlying error
+func (e *BatchError) Unwrap() error {
+	return e.Err
+}
+
+// Creates a copy of the context that assigns the provided ID to the batches run with it, instead of a randomly generated one.
+// This lets an application reuse an ID it already logs, such as the ID of the request that triggered the batch.
+func ContextWithBatchID(ctx context.Context, batchID string) context.Context {
+	return context.WithValue(ctx, batchIDKey{}, batchID)
+}
+
+// Gets the ID of the batch that a hook or request belongs to (empty if it isn't part of a batch)
+func BatchIDFromContext(ctx context.Context) string {
+	batchID, _ := ctx.Value(batchIDKey{}).(string)
+	return batchID
+}
+
+// Gets the ID of the chunk that a hook or request belongs to (empty if it isn't part of a chunk).
+// Chunk IDs are the batch ID followed by the chunk's index within the batch.
+func ChunkIDFromContext(ctx context.Context) string {
+	chunkID, _ := ctx.Value(chunkIDKey{}).(string)
+	return chunkID
+}
+
+// Generates a random batch ID
+func newBatchID() string {
+	var id [8]byte
+	_, err := rand.Read(id[:])
+	if err != nil {
+		// The ID is only used for correlation, so a clock-based one is good enough if the system has no randomness available
+		return fmt.Sprintf("%x", time.Now().UnixNano())
+	}
+	return hex.EncodeToString(id[:])
+}
+
+// Starts a batch of calls, assigning it an ID (unless the context already has one) and reporting it to the hooks.
+// Returns the options to run the batch with and a function to call once it's finished, which tags its error with the batch's ID.
+func (mc *MultiCaller) startBatch(opts *callOptions, callCount int) (*callOptions, func(err error) error) {
+	batchOpts := *opts
+	batchID := BatchIDFromContext(opts.ctx)
+	if batchID == "" {
+		batchID = newBatchID()
+		batchOpts.ctx = ContextWithBatchID(opts.ctx, batchID)
+	}
+	hooks := mc.Hooks
+	start := time.Now()
+	if hooks != nil && hooks.OnBatchStart != nil {
+		hooks.OnBatchStart(batchOpts.ctx, callCount)
+	}
+
+	return &batchOpts, func(err error) error {
+		if err != nil {
+			var batchErr *BatchError
+			if !errors.As(err, &batchErr) {
+				err = &BatchError{
+					BatchID: batchID,
+					Err:     err,
+				}
+			}
+		}
+		if hooks != nil && hooks.OnBatchEnd != nil {
+			hooks.OnBatchEnd(batchOpts.ctx, callCount, time.Since(start), err)
+		}
+		return err
+	}
+}
+
+// Runs a single chunk of a batch with the chunk's ID in its context, reporting it to the hooks and tagging its error with the IDs
+func (mc *MultiCaller) runChunk(ctx context.Context, index int, callCount int, run func(ctx context.Context) error) error {
+	batchID := BatchIDFromContext(ctx)
+	chunkID := fmt.Sprintf("%s-%d", batchID, index)
+	ctx = context.WithValue(ctx, chunkIDKey{}, chunkID)
+	hooks := mc.Hooks
+	start := time.Now()
+	if hooks != nil && hooks.OnChunkStart != nil {
+		hooks.OnChunkStart(ctx, callCount)
+	}
+
+	err := run(ctx)
+	if hooks != nil && hooks.OnChunkEnd != nil {
+		hooks.OnChunkEnd(ctx, callCount, time.Since(start), err)
+	}
+	if err != nil {
+		return &BatchError{
+			BatchID: batchID,
+			ChunkID: chunkID,
+			Err:     err,
+		}
+	}
+	return nil
+}
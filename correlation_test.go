@@ -0,0 +1,108 @@
+package batchquery
+
+import (
+	"context"
+	"errors"
+	"math/big"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+)
+
+func TestHooksReceiveCorrelationIDs(t *testing.T) {
+	mc, client := newTestMultiCaller(t)
+	mc.CallBatchSize = 2
+
+	// Record the IDs seen by the client and the hooks
+	var lock sync.Mutex
+	requestChunks := map[string]bool{}
+	hookChunks := map[string]bool{}
+	var startBatchID, endBatchID string
+	client.hook = func(ctx context.Context, msg ethereum.CallMsg) error {
+		lock.Lock()
+		defer lock.Unlock()
+		requestChunks[ChunkIDFromContext(ctx)] = true
+		return nil
+	}
+	mc.Hooks = &Hooks{
+		OnBatchStart: func(ctx context.Context, callCount int) {
+			startBatchID = BatchIDFromContext(ctx)
+		},
+		OnBatchEnd: func(ctx context.Context, callCount int, duration time.Duration, err error) {
+			endBatchID = BatchIDFromContext(ctx)
+			if callCount != 5 || err != nil {
+				t.Errorf("expected a successful batch of 5 calls, got %d calls with %v", callCount, err)
+			}
+		},
+		OnChunkStart: func(ctx context.Context, callCount int) {
+			lock.Lock()
+			defer lock.Unlock()
+			hookChunks[ChunkIDFromContext(ctx)] = true
+		},
+	}
+
+	balances := make([]*big.Int, 5)
+	for i := range balances {
+		mc.AddCall(testTokenAddress, &testTokenAbi, &balances[i], "balanceOf", common.BigToAddress(big.NewInt(int64(i))))
+	}
+	_, err := mc.FlexibleCall(true, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if startBatchID == "" || startBatchID != endBatchID {
+		t.Fatalf("expected the batch hooks to see the same batch ID, got %q and %q", startBatchID, endBatchID)
+	}
+	if len(hookChunks) != 3 || len(requestChunks) != 3 {
+		t.Fatalf("expected 3 chunk IDs, got %v from the hooks and %v from the requests", hookChunks, requestChunks)
+	}
+	for chunkID := range hookChunks {
+		if !requestChunks[chunkID] || !strings.HasPrefix(chunkID, startBatchID+"-") {
+			t.Fatalf("chunk ID %s wasn't derived from batch ID %s or wasn't sent with a request", chunkID, startBatchID)
+		}
+	}
+}
+
+func TestBatchErrorsIncludeCorrelationIDs(t *testing.T) {
+	mc, _ := newTestMultiCaller(t)
+	var boom *big.Int
+	mc.AddCall(testTokenAddress, &testTokenAbi, &boom, "boom")
+	_, err := mc.FlexibleCall(true, nil)
+
+	var batchErr *BatchError
+	if !errors.As(err, &batchErr) {
+		t.Fatalf("expected a BatchError, got %v", err)
+	}
+	if batchErr.BatchID == "" || batchErr.ChunkID != batchErr.BatchID+"-0" {
+		t.Fatalf("expected the error to identify the batch and its first chunk, got batch %q and chunk %q", batchErr.BatchID, batchErr.ChunkID)
+	}
+	if !strings.Contains(err.Error(), batchErr.ChunkID) {
+		t.Fatalf("expected the error message to include the chunk ID, got %s", err.Error())
+	}
+	var reverted *ErrCallReverted
+	if !errors.As(err, &reverted) {
+		t.Fatalf("expected the revert to still be matchable, got %v", err)
+	}
+}
+
+func TestContextWithBatchIDOverridesGeneratedID(t *testing.T) {
+	mc, client := newTestMultiCaller(t)
+	var seen string
+	client.hook = func(ctx context.Context, msg ethereum.CallMsg) error {
+		seen = BatchIDFromContext(ctx)
+		return nil
+	}
+	var balance *big.Int
+	mc.AddCall(testTokenAddress, &testTokenAbi, &balance, "balanceOf", common.HexToAddress("0x05"))
+	mc.BaseContext = ContextWithBatchID(context.Background(), "request-42")
+	_, err := mc.FlexibleCall(true, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if seen != "request-42" {
+		t.Fatalf("expected the provided batch ID to be used, got %q", seen)
+	}
+}
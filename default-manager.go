@@ -0,0 +1,140 @@
+package batchquery
+
+import (
+	"fmt"
+	"math/big"
+	"sync"
+
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+)
+
+const (
+	// The number of addresses the default BalanceBatcher queries within a single call
+	defaultBalanceBatchSize int = 1000
+
+	// The number of calls the default BalanceBatcher runs simultaneously
+	defaultBalanceThreadLimit int = 4
+)
+
+// The batchers behind the package-level helpers, which are created on first use from the client provided to SetDefaultClient
+type defaultManager struct {
+	// The client provided to SetDefaultClient
+	client IContractCaller
+
+	// The MultiCaller that the helpers' MultiCallers are copied from (nil = not created yet)
+	multiCaller *MultiCaller
+
+	// The BalanceBatcher for the client's chain, or nil if the chain doesn't have a known balance checker contract
+	balanceBatcher *BalanceBatcher
+
+	// Lock for creating the batchers
+	lock sync.Mutex
+}
+
+// The default manager, or nil if SetDefaultClient hasn't been called
+var defaultBatchers *defaultManager
+var defaultLock sync.RWMutex
+
+// Sets the Execution client used by the package-level helpers such as Query and EthBalances, for small tools that don't want to wire up the batchers themselves.
+// The batchers are created on first use: the chain ID is read from the Multicall3 contract, and the chain's built-in profile (if it has one) provides the
+// batch sizes and the balance checker contract. Calling this again replaces the client and discards the batchers created for the old one.
+func SetDefaultClient(client IContractCaller) {
+	defaultLock.Lock()
+	defer defaultLock.Unlock()
+	defaultBatchers = &defaultManager{
+		client: client,
+	}
+}
+
+// Creates a new MultiCaller for the default client, with the settings of its chain's built-in profile.
+// Each MultiCaller has its own list of pending calls, so separate goroutines should each create their own.
+func DefaultMultiCaller(opts *bind.CallOpts) (*MultiCaller, error) {
+	manager, err := getDefaultManager()
+	if err != nil {
+		return nil, err
+	}
+	err = manager.init(opts)
+	if err != nil {
+		return nil, err
+	}
+	return manager.multiCaller.withCalls([]*Call{}), nil
+}
+
+// Runs a batch of calls with the default client: build adds the calls to a new MultiCaller, which is then run with the same semantics as FlexibleCall().
+// Returns the success flag of each call, in the order they were added.
+func Query(build func(mc *MultiCaller), requireSuccess bool, opts *bind.CallOpts) ([]bool, error) {
+	mc, err := DefaultMultiCaller(opts)
+	if err != nil {
+		return nil, err
+	}
+	build(mc)
+	return mc.FlexibleCall(requireSuccess, opts)
+}
+
+// Retrieves the ETH balance for a list of addresses with the default client. The order of the resulting array corresponds to the order of the provided addresses.
+// The chain's balance checker contract is used if its profile has one; otherwise the balances are read through Multicall3's getEthBalance function.
+func EthBalances(addresses []common.Address, opts *bind.CallOpts) ([]*big.Int, error) {
+	manager, err := getDefaultManager()
+	if err != nil {
+		return nil, err
+	}
+	err = manager.init(opts)
+	if err != nil {
+		return nil, err
+	}
+	if manager.balanceBatcher != nil {
+		return manager.balanceBatcher.GetEthBalances(addresses, opts)
+	}
+	return manager.multiCaller.GetEthBalances(addresses, opts)
+}
+
+// Gets the default manager
+func getDefaultManager() (*defaultManager, error) {
+	defaultLock.RLock()
+	defer defaultLock.RUnlock()
+	if defaultBatchers == nil {
+		return nil, fmt.Errorf("no default client has been set; call SetDefaultClient first")
+	}
+	return defaultBatchers, nil
+}
+
+// Creates the manager's batchers if they haven't been created yet, using the context in opts to look up the chain.
+// A failed lookup isn't cached, so the next use tries again.
+func (m *defaultManager) init(opts *bind.CallOpts) error {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	if m.multiCaller != nil {
+		return nil
+	}
+
+	mc, err := NewMultiCaller(m.client, Multicall3Address)
+	if err != nil {
+		return err
+	}
+	var chainID *big.Int
+	mc.AddChainID(&chainID)
+	chainOpts := &bind.CallOpts{}
+	if opts != nil {
+		chainOpts.Context = opts.Context
+	}
+	_, err = mc.FlexibleCall(true, chainOpts)
+	if err != nil {
+		return fmt.Errorf("error getting chain ID of the default client: %w", err)
+	}
+
+	var balanceBatcher *BalanceBatcher
+	profile, exists := GetChainProfile(chainID.Uint64())
+	if exists {
+		profile.Apply(mc)
+		if profile.BalanceBatcherAddress != (common.Address{}) {
+			balanceBatcher, err = NewBalanceBatcher(m.client, profile.BalanceBatcherAddress, defaultBalanceBatchSize, defaultBalanceThreadLimit)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	m.multiCaller = mc
+	m.balanceBatcher = balanceBatcher
+	return nil
+}
@@ -0,0 +1,70 @@
+package batchquery
+
+import (
+	"bytes"
+	"context"
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// A mock client whose multicall contract is deployed at the Multicall3 address
+type multicall3Client struct {
+	*mockClient
+}
+
+// Redirects calls to Multicall3, including the getter calls aggregated within them, to the mock's multicall contract
+func (c *multicall3Client) CallContract(ctx context.Context, msg ethereum.CallMsg, blockNumber *big.Int) ([]byte, error) {
+	if msg.To != nil && *msg.To == Multicall3Address {
+		msg.To = &testMulticallAddress
+		msg.Data = bytes.ReplaceAll(msg.Data, Multicall3Address.Bytes(), testMulticallAddress.Bytes())
+	}
+	return c.mockClient.CallContract(ctx, msg, blockNumber)
+}
+
+func TestPackageHelpersUseDefaultClient(t *testing.T) {
+	defer func() {
+		defaultBatchers = nil
+	}()
+	_, err := EthBalances([]common.Address{testTokenAddress}, nil)
+	if err == nil {
+		t.Fatal("expected the helpers to fail before a default client is set")
+	}
+
+	client := &mockClient{}
+	SetDefaultClient(&multicall3Client{client})
+	var balance *big.Int
+	successes, err := Query(func(mc *MultiCaller) {
+		mc.AddCall(testTokenAddress, &testTokenAbi, &balance, "balanceOf", common.HexToAddress("0x05"))
+	}, true, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !successes[0] || balance.Int64() != 5 {
+		t.Fatalf("expected a balance of 5, got %v with %s", successes[0], balance)
+	}
+
+	// The mock chain doesn't have a profile, so balances are read through the multicall contract
+	addresses := []common.Address{common.HexToAddress("0x07"), common.HexToAddress("0x0102")}
+	balances, err := EthBalances(addresses, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, address := range addresses {
+		if balances[i].Cmp(expectedBalance(address, 0)) != 0 {
+			t.Fatalf("expected balance %s for %s, got %s", expectedBalance(address, 0), address.Hex(), balances[i])
+		}
+	}
+
+	// The chain is only looked up once
+	calls := client.calls
+	_, err = EthBalances(addresses, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if client.calls != calls+1 {
+		t.Fatalf("expected a single call for the second query, got %d", client.calls-calls)
+	}
+}
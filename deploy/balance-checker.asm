@@ -0,0 +1,172 @@
+;; The runtime code of the balance checker, in the assembly language of go-ethereum's core/asm package.
+;; It implements the ABI of https://github.com/wbobeirne/eth-balance-checker:
+;;   balances(address[] users, address[] tokens) returns (uint256[])
+;;   tokenBalance(address user, address token) returns (uint256)
+;; A token of the zero address is ETH. Tokens without code, and tokens whose balanceOf call fails, have a balance of 0.
+;; Any other call reverts.
+
+;; Dispatch on the selector
+    PUSH 0
+    CALLDATALOAD
+    PUSH 0xe0
+    SHR
+    DUP1
+    PUSH 0xf0002ea9
+    EQ
+    JUMPI @balances
+    DUP1
+    PUSH 0x1049334f
+    EQ
+    JUMPI @tokenBalance
+    PUSH 0
+    DUP1
+    REVERT
+
+;; tokenBalance(address,address)
+tokenBalance:
+    POP
+    PUSH @tokenBalanceDone
+    PUSH 0x04
+    CALLDATALOAD
+    PUSH 0x24
+    CALLDATALOAD
+    JUMP @getBalance
+tokenBalanceDone:
+    PUSH 0
+    MSTORE
+    PUSH 0x20
+    PUSH 0
+    RETURN
+
+;; balances(address[],address[])
+;; The loops keep [userPtr, userEnd, tokenStart, tokenEnd, out] on the stack, with tokenPtr on top in the inner loop.
+;; The pointers are calldata offsets of the array elements, and out is the memory offset of the next balance.
+balances:
+    POP
+    PUSH 0x04
+    CALLDATALOAD
+    PUSH 0x24
+    ADD
+    DUP1
+    PUSH 0x20
+    SWAP1
+    SUB
+    CALLDATALOAD
+    PUSH 5
+    SHL
+    DUP2
+    ADD
+    PUSH 0x24
+    CALLDATALOAD
+    PUSH 0x24
+    ADD
+    DUP1
+    PUSH 0x20
+    SWAP1
+    SUB
+    CALLDATALOAD
+    PUSH 5
+    SHL
+    DUP2
+    ADD
+    PUSH 0xc0
+userLoop:
+    DUP5
+    DUP5
+    EQ
+    JUMPI @balancesDone
+    DUP3
+tokenLoop:
+    DUP3
+    DUP2
+    EQ
+    JUMPI @tokenLoopDone
+    PUSH @balanceStored
+    DUP7
+    CALLDATALOAD
+    DUP3
+    CALLDATALOAD
+    JUMP @getBalance
+balanceStored:
+    DUP3
+    MSTORE
+    PUSH 0x20
+    ADD
+    SWAP1
+    PUSH 0x20
+    ADD
+    SWAP1
+    JUMP @tokenLoop
+tokenLoopDone:
+    POP
+    SWAP4
+    PUSH 0x20
+    ADD
+    SWAP4
+    JUMP @userLoop
+balancesDone:
+    ;; The result is ABI encoded at 0x80: its offset, its length, and then the balances from 0xc0 to out
+    PUSH 0x20
+    PUSH 0x80
+    MSTORE
+    PUSH 0xc0
+    DUP2
+    SUB
+    PUSH 5
+    SHR
+    PUSH 0xa0
+    MSTORE
+    PUSH 0x80
+    SWAP1
+    SUB
+    PUSH 0x80
+    RETURN
+
+;; Gets the balance of a user, taking [return, user, token] and leaving [balance]
+getBalance:
+    DUP1
+    JUMPI @getTokenBalance
+    POP
+    BALANCE
+    SWAP1
+    JUMP
+getTokenBalance:
+    DUP1
+    EXTCODESIZE
+    ISZERO
+    JUMPI @noCode
+    ;; Call balanceOf(user) with the call data and return data in scratch memory
+    PUSH 0x70a08231
+    PUSH 0xe0
+    SHL
+    PUSH 0
+    MSTORE
+    SWAP1
+    PUSH 0x04
+    MSTORE
+    PUSH 0x20
+    PUSH 0
+    PUSH 0x24
+    PUSH 0
+    DUP5
+    GAS
+    STATICCALL
+    PUSH 0x20
+    RETURNDATASIZE
+    LT
+    ISZERO
+    AND
+    ISZERO
+    JUMPI @noBalance
+    POP
+    PUSH 0
+    MLOAD
+    SWAP1
+    JUMP
+noCode:
+    POP
+noBalance:
+    POP
+    PUSH 0
+    SWAP1
+    JUMP
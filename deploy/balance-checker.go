@@ -0,0 +1,33 @@
+package deploy
+
+import (
+	_ "embed"
+	"fmt"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+// The creation bytecode of the balance checker, hex encoded: a constructor that returns the runtime code assembled from balance-checker.asm
+//
+//go:embed balance-checker.bin
+var balanceCheckerHex string
+
+// Gets a copy of the creation bytecode of the balance checker
+func BalanceCheckerBytecode() []byte {
+	return common.FromHex(strings.TrimSpace(balanceCheckerHex))
+}
+
+// Deploys a new instance of the balance checker using the provided transactor, for use as a BalanceBatcher's contract.
+// The contract is only usable once the returned transaction has been mined, which can be awaited with bind.WaitDeployed
+// (or by committing the block, on a simulated backend).
+func DeployBalanceChecker(opts *bind.TransactOpts, backend bind.ContractBackend) (common.Address, *types.Transaction, error) {
+	address, tx, _, err := bind.DeployContract(opts, abi.ABI{}, BalanceCheckerBytecode(), backend)
+	if err != nil {
+		return common.Address{}, nil, fmt.Errorf("error deploying balance checker: %w", err)
+	}
+	return address, tx, nil
+}
@@ -0,0 +1,255 @@
+package deploy
+
+import (
+	"context"
+	"math/big"
+	"os"
+	"testing"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/asm"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/core/vm"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/params"
+	batchquery "github.com/rocket-pool/batch-query"
+)
+
+// An account in the EVM's state
+type evmAccount struct {
+	balance *big.Int
+	nonce   uint64
+	code    []byte
+	storage map[common.Hash]common.Hash
+}
+
+// An in-memory state for running contracts in go-ethereum's EVM, which only keeps what the balance checker and its tests use
+type evmState struct {
+	accounts map[common.Address]*evmAccount
+}
+
+// Gets an account, creating it if it doesn't exist
+func (s *evmState) account(address common.Address) *evmAccount {
+	account, exists := s.accounts[address]
+	if !exists {
+		account = &evmAccount{balance: new(big.Int), storage: map[common.Hash]common.Hash{}}
+		s.accounts[address] = account
+	}
+	return account
+}
+
+func (s *evmState) CreateAccount(address common.Address) { s.account(address) }
+func (s *evmState) SubBalance(address common.Address, amount *big.Int) {
+	s.account(address).balance.Sub(s.account(address).balance, amount)
+}
+func (s *evmState) AddBalance(address common.Address, amount *big.Int) {
+	s.account(address).balance.Add(s.account(address).balance, amount)
+}
+func (s *evmState) GetBalance(address common.Address) *big.Int {
+	return new(big.Int).Set(s.account(address).balance)
+}
+func (s *evmState) GetNonce(address common.Address) uint64                     { return s.account(address).nonce }
+func (s *evmState) SetNonce(address common.Address, nonce uint64)              { s.account(address).nonce = nonce }
+func (s *evmState) GetCode(address common.Address) []byte                      { return s.account(address).code }
+func (s *evmState) SetCode(address common.Address, code []byte)                { s.account(address).code = code }
+func (s *evmState) GetCodeSize(address common.Address) int                     { return len(s.account(address).code) }
+func (s *evmState) AddRefund(uint64)                                           {}
+func (s *evmState) SubRefund(uint64)                                           {}
+func (s *evmState) GetRefund() uint64                                          { return 0 }
+func (s *evmState) Suicide(common.Address) bool                                { return false }
+func (s *evmState) HasSuicided(common.Address) bool                            { return false }
+func (s *evmState) Exist(address common.Address) bool                          { return s.accounts[address] != nil }
+func (s *evmState) AddressInAccessList(common.Address) bool                    { return true }
+func (s *evmState) AddAddressToAccessList(common.Address)                      {}
+func (s *evmState) AddSlotToAccessList(common.Address, common.Hash)            {}
+func (s *evmState) RevertToSnapshot(int)                                       {}
+func (s *evmState) Snapshot() int                                              { return 0 }
+func (s *evmState) AddLog(*types.Log)                                          {}
+func (s *evmState) AddPreimage(common.Hash, []byte)                            {}
+func (s *evmState) GetTransientState(common.Address, common.Hash) common.Hash  { return common.Hash{} }
+func (s *evmState) SetTransientState(common.Address, common.Hash, common.Hash) {}
+func (s *evmState) Prepare(params.Rules, common.Address, common.Address, *common.Address, []common.Address, types.AccessList) {
+}
+
+func (s *evmState) GetCodeHash(address common.Address) common.Hash {
+	account, exists := s.accounts[address]
+	if !exists {
+		return common.Hash{}
+	}
+	return crypto.Keccak256Hash(account.code)
+}
+
+func (s *evmState) GetCommittedState(address common.Address, key common.Hash) common.Hash {
+	return s.GetState(address, key)
+}
+
+func (s *evmState) GetState(address common.Address, key common.Hash) common.Hash {
+	return s.account(address).storage[key]
+}
+
+func (s *evmState) SetState(address common.Address, key common.Hash, value common.Hash) {
+	s.account(address).storage[key] = value
+}
+
+func (s *evmState) SlotInAccessList(common.Address, common.Hash) (bool, bool) {
+	return true, true
+}
+
+func (s *evmState) Empty(address common.Address) bool {
+	account, exists := s.accounts[address]
+	return !exists || (account.balance.Sign() == 0 && account.nonce == 0 && len(account.code) == 0)
+}
+
+// A contract caller that runs calls in go-ethereum's EVM against an in-memory state
+type evmBackend struct {
+	state *evmState
+}
+
+// Creates a new EVM for running a call or a deployment
+func (b *evmBackend) newEVM() *vm.EVM {
+	blockContext := vm.BlockContext{
+		CanTransfer: func(db vm.StateDB, address common.Address, amount *big.Int) bool {
+			return db.GetBalance(address).Cmp(amount) >= 0
+		},
+		Transfer: func(db vm.StateDB, from common.Address, to common.Address, amount *big.Int) {
+			db.SubBalance(from, amount)
+			db.AddBalance(to, amount)
+		},
+		GetHash:     func(uint64) common.Hash { return common.Hash{} },
+		BlockNumber: big.NewInt(1),
+		Difficulty:  big.NewInt(0),
+		BaseFee:     big.NewInt(0),
+		GasLimit:    30_000_000,
+	}
+	return vm.NewEVM(blockContext, vm.TxContext{GasPrice: big.NewInt(0)}, b.state, params.AllEthashProtocolChanges, vm.Config{})
+}
+
+// Deploys a contract with the provided creation bytecode
+func (b *evmBackend) deploy(t *testing.T, bytecode []byte) common.Address {
+	_, address, _, err := b.newEVM().Create(vm.AccountRef(common.HexToAddress("0xde")), bytecode, 10_000_000, new(big.Int))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return address
+}
+
+func (b *evmBackend) CallContract(ctx context.Context, call ethereum.CallMsg, blockNumber *big.Int) ([]byte, error) {
+	out, _, err := b.newEVM().StaticCall(vm.AccountRef(call.From), *call.To, call.Data, 10_000_000)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// Assembles code written in the language of go-ethereum's core/asm package
+func assemble(t *testing.T, source string) []byte {
+	compiler := asm.NewCompiler(false)
+	compiler.Feed(asm.Lex([]byte(source), false))
+	bin, errs := compiler.Compile()
+	if len(errs) > 0 {
+		t.Fatal(errs)
+	}
+	return common.FromHex(bin)
+}
+
+// Wraps runtime code in a constructor that returns it, which is the same constructor the balance checker uses
+func withConstructor(runtime []byte) []byte {
+	constructor := []byte{
+		byte(vm.PUSH2), byte(len(runtime) >> 8), byte(len(runtime)),
+		byte(vm.DUP1),
+		byte(vm.PUSH1), 0x0c,
+		byte(vm.PUSH1), 0x00,
+		byte(vm.CODECOPY),
+		byte(vm.PUSH1), 0x00,
+		byte(vm.RETURN),
+	}
+	return append(constructor, runtime...)
+}
+
+func TestBalanceCheckerBytecodeMatchesSource(t *testing.T) {
+	source, err := os.ReadFile("balance-checker.asm")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := withConstructor(assemble(t, string(source)))
+	if hexutil.Encode(BalanceCheckerBytecode()) != hexutil.Encode(expected) {
+		t.Fatal("expected the embedded bytecode to be the assembled source with its constructor")
+	}
+}
+
+func TestDeployBalanceChecker(t *testing.T) {
+	opts := newTestTransactor(t)
+	backend := &mockBackend{code: map[common.Address][]byte{}}
+	address, tx, err := DeployBalanceChecker(opts, backend)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(backend.sent) != 1 || tx.To() != nil || string(tx.Data()) != string(BalanceCheckerBytecode()) {
+		t.Fatal("expected a contract creation with the balance checker bytecode")
+	}
+	if address != crypto.CreateAddress(opts.From, tx.Nonce()) {
+		t.Fatalf("unexpected deployment address %s", address.Hex())
+	}
+}
+
+func TestBalanceCheckerServesBalanceBatcher(t *testing.T) {
+	backend := &evmBackend{state: &evmState{accounts: map[common.Address]*evmAccount{}}}
+	checker := backend.deploy(t, BalanceCheckerBytecode())
+
+	// A token whose balanceOf returns twice the account, one that reverts, and an account without code
+	token := backend.deploy(t, withConstructor(assemble(t, "PUSH 0x04\nCALLDATALOAD\nPUSH 1\nSHL\nPUSH 0\nMSTORE\nPUSH 0x20\nPUSH 0\nRETURN\n")))
+	revertingToken := backend.deploy(t, withConstructor(assemble(t, "PUSH 0\nDUP1\nREVERT\n")))
+	notToken := common.HexToAddress("0x7e")
+
+	users := make([]common.Address, 5)
+	for i := range users {
+		users[i] = common.BigToAddress(big.NewInt(int64(i + 1)))
+		backend.state.account(users[i]).balance = big.NewInt(int64(1000 * (i + 1)))
+	}
+	batcher, err := batchquery.NewBalanceBatcher(backend, checker, 4, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ethBalances, err := batcher.GetEthBalances(users, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, balance := range ethBalances {
+		if balance.Int64() != int64(1000*(i+1)) {
+			t.Fatalf("expected ETH balance %d for user %d, got %s", 1000*(i+1), i, balance)
+		}
+	}
+
+	tokens := []common.Address{{}, token, revertingToken, notToken}
+	balances, err := batcher.GetTokenBalances(users, tokens, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, userBalances := range balances {
+		expected := []int64{int64(1000 * (i + 1)), int64(2 * (i + 1)), 0, 0}
+		for j, balance := range userBalances {
+			if balance.Int64() != expected[j] {
+				t.Fatalf("expected balance %d of token %d for user %d, got %s", expected[j], j, i, balance)
+			}
+		}
+	}
+	// tokenBalance reads a single balance
+	data := append(common.FromHex("0x1049334f"), common.LeftPadBytes(users[2].Bytes(), 32)...)
+	data = append(data, common.LeftPadBytes(token.Bytes(), 32)...)
+	out, err := backend.CallContract(context.Background(), ethereum.CallMsg{To: &checker, Data: data}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if new(big.Int).SetBytes(out).Int64() != 6 {
+		t.Fatalf("expected a token balance of 6, got %x", out)
+	}
+
+	// Other calls revert
+	_, err = backend.CallContract(context.Background(), ethereum.CallMsg{To: &checker, Data: common.FromHex("0x70a08231")}, nil)
+	if err == nil {
+		t.Fatal("expected an unknown function to revert")
+	}
+}
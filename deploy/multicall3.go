@@ -0,0 +1,58 @@
+// Package deploy deploys the helper contracts batch-query relies on to development chains, such as a local devnet or a simulated backend,
+// so tests and private networks can use the package without any manual setup.
+//
+// The Multicall3 bytecode is the canonical build from https://github.com/mds1/multicall, as published in the Optimism monorepo's
+// op-e2e bindings (v1.9.4). Its runtime code is identical to the code deployed at batchquery.Multicall3Address on public chains.
+//
+// The balance checker bytecode is assembled from balance-checker.asm, which implements the ABI of https://github.com/wbobeirne/eth-balance-checker
+// so a BalanceBatcher can use it. It isn't the same build as the mainnet deployment at batchquery.MainnetBalanceCheckerAddress, so it's only
+// meant for development chains.
+package deploy
+
+import (
+	"context"
+	_ "embed"
+	"fmt"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	batchquery "github.com/rocket-pool/batch-query"
+)
+
+// The creation bytecode of Multicall3, hex encoded
+//
+//go:embed multicall3.bin
+var multicall3Hex string
+
+// Gets a copy of the creation bytecode of Multicall3
+func Multicall3Bytecode() []byte {
+	return common.FromHex(strings.TrimSpace(multicall3Hex))
+}
+
+// Deploys a new instance of Multicall3 using the provided transactor.
+// The contract is only usable once the returned transaction has been mined, which can be awaited with bind.WaitDeployed
+// (or by committing the block, on a simulated backend).
+func DeployMulticall3(opts *bind.TransactOpts, backend bind.ContractBackend) (common.Address, *types.Transaction, error) {
+	address, tx, _, err := bind.DeployContract(opts, abi.ABI{}, Multicall3Bytecode(), backend)
+	if err != nil {
+		return common.Address{}, nil, fmt.Errorf("error deploying Multicall3: %w", err)
+	}
+	return address, tx, nil
+}
+
+// Gets the address of a Multicall3 instance that can be used on the backend's chain, deploying a new one if the chain doesn't have one yet.
+// If Multicall3 is already deployed to its canonical address (such as on a fork of a public chain), that address is returned with a nil transaction.
+// Otherwise a new instance is deployed with the provided transactor, which is only usable once the returned transaction has been mined.
+func EnsureMulticall3(ctx context.Context, opts *bind.TransactOpts, backend bind.ContractBackend) (common.Address, *types.Transaction, error) {
+	code, err := backend.CodeAt(ctx, batchquery.Multicall3Address, nil)
+	if err != nil {
+		return common.Address{}, nil, fmt.Errorf("error checking for Multicall3 at %s: %w", batchquery.Multicall3Address.Hex(), err)
+	}
+	if len(code) > 0 {
+		return batchquery.Multicall3Address, nil, nil
+	}
+	return DeployMulticall3(opts, backend)
+}
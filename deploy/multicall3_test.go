@@ -0,0 +1,130 @@
+package deploy
+
+import (
+	"context"
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	batchquery "github.com/rocket-pool/batch-query"
+)
+
+// A contract backend that records the transactions sent to it
+type mockBackend struct {
+	// The code of each account
+	code map[common.Address][]byte
+
+	// The transactions that were sent
+	sent []*types.Transaction
+}
+
+func (m *mockBackend) CodeAt(ctx context.Context, contract common.Address, blockNumber *big.Int) ([]byte, error) {
+	return m.code[contract], nil
+}
+
+func (m *mockBackend) CallContract(ctx context.Context, call ethereum.CallMsg, blockNumber *big.Int) ([]byte, error) {
+	return nil, nil
+}
+
+func (m *mockBackend) HeaderByNumber(ctx context.Context, number *big.Int) (*types.Header, error) {
+	return &types.Header{Number: big.NewInt(1), BaseFee: big.NewInt(1)}, nil
+}
+
+func (m *mockBackend) PendingCodeAt(ctx context.Context, account common.Address) ([]byte, error) {
+	return m.code[account], nil
+}
+
+func (m *mockBackend) PendingNonceAt(ctx context.Context, account common.Address) (uint64, error) {
+	return uint64(len(m.sent)), nil
+}
+
+func (m *mockBackend) SuggestGasPrice(ctx context.Context) (*big.Int, error) {
+	return big.NewInt(1), nil
+}
+
+func (m *mockBackend) SuggestGasTipCap(ctx context.Context) (*big.Int, error) {
+	return big.NewInt(1), nil
+}
+
+func (m *mockBackend) EstimateGas(ctx context.Context, call ethereum.CallMsg) (uint64, error) {
+	return 1_000_000, nil
+}
+
+func (m *mockBackend) SendTransaction(ctx context.Context, tx *types.Transaction) error {
+	m.sent = append(m.sent, tx)
+	return nil
+}
+
+func (m *mockBackend) FilterLogs(ctx context.Context, query ethereum.FilterQuery) ([]types.Log, error) {
+	return nil, nil
+}
+
+func (m *mockBackend) SubscribeFilterLogs(ctx context.Context, query ethereum.FilterQuery, ch chan<- types.Log) (ethereum.Subscription, error) {
+	return nil, nil
+}
+
+// Creates a transactor with a fresh key
+func newTestTransactor(t *testing.T) *bind.TransactOpts {
+	key, err := crypto.GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	opts, err := bind.NewKeyedTransactorWithChainID(key, big.NewInt(1337))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return opts
+}
+
+func TestMulticall3Bytecode(t *testing.T) {
+	// The runtime code is 3808 bytes, which the constructor copies out of the creation code
+	bytecode := Multicall3Bytecode()
+	if len(bytecode) != 0x20+0xee0 {
+		t.Fatalf("unexpected bytecode length %d", len(bytecode))
+	}
+
+	// Callers get their own copy
+	bytecode[0] = 0
+	if Multicall3Bytecode()[0] != 0x60 {
+		t.Fatal("expected the embedded bytecode to be unaffected by changes to a copy")
+	}
+}
+
+func TestEnsureMulticall3(t *testing.T) {
+	opts := newTestTransactor(t)
+
+	// A chain without Multicall3 gets a new instance
+	backend := &mockBackend{code: map[common.Address][]byte{}}
+	address, tx, err := EnsureMulticall3(context.Background(), opts, backend)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tx == nil || len(backend.sent) != 1 || tx.To() != nil || string(tx.Data()) != string(Multicall3Bytecode()) {
+		t.Fatal("expected a contract creation with the Multicall3 bytecode")
+	}
+	if address != crypto.CreateAddress(opts.From, tx.Nonce()) {
+		t.Fatalf("unexpected deployment address %s", address.Hex())
+	}
+
+	// A chain that already has it at the canonical address keeps using that
+	backend = &mockBackend{code: map[common.Address][]byte{batchquery.Multicall3Address: {0x60}}}
+	address, tx, err = EnsureMulticall3(context.Background(), opts, backend)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if address != batchquery.Multicall3Address || tx != nil || len(backend.sent) != 0 {
+		t.Fatal("expected the canonical deployment to be used")
+	}
+}
+
+func TestMulticall3BytecodeMatchesCanonicalCode(t *testing.T) {
+	// The constructor is the first 32 bytes, and the rest is the runtime code deployed to the canonical address
+	runtime := Multicall3Bytecode()[0x20:]
+	if crypto.Keccak256Hash(runtime) != batchquery.Multicall3CodeHash {
+		t.Fatal("expected the embedded runtime code to match the canonical Multicall3 code hash")
+	}
+}
@@ -0,0 +1,146 @@
+package batchquery
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+// Runs each call individually as its own eth_call rather than aggregating them through the multicall contract.
+// This is slower, but the calls see the sender from the options as msg.sender instead of the multicall contract.
+// Reverted calls are reported as failures with their revert data; if requireSuccess is true, a revert fails the whole batch instead.
+// The responses are returned in the same order as the provided calls.
+// If provided, onChunk is called with each call's response as soon as it completes, as a chunk of one.
+func (mc *MultiCaller) executeDirect(calls []*Call, requireSuccess bool, opts *callOptions, onChunk func(chunk callChunk, responses []CallResponse)) ([]CallResponse, error) {
+	responses := make([]CallResponse, len(calls))
+
+	// A failure in any call cancels the rest of them
+	err := mc.Executor.run(opts.ctx, mc.ThreadLimit, "eth_call", len(calls), nil, func(ctx context.Context, i int) error {
+		call := calls[i]
+		callCtx := ctx
+		timeout := call.Timeout
+		if timeout <= 0 {
+			timeout = mc.ChunkTimeout
+		}
+		if timeout > 0 {
+			var cancel context.CancelFunc
+			callCtx, cancel = context.WithTimeout(ctx, timeout)
+			defer cancel()
+		}
+		var response CallResponse
+		err := mc.runChunk(callCtx, i, 1, func(ctx context.Context) error {
+			var err error
+			response, err = directCall(ctx, mc.client, call, mc.gasLimit(opts), opts)
+			return err
+		})
+		if err != nil {
+			if requireSuccess || !exceededOwnDeadline(ctx, callCtx) {
+				return err
+			}
+			response = CallResponse{}
+		}
+		if requireSuccess && !response.Status {
+			return &ErrCallReverted{
+				Index:       i,
+				Target:      call.Target,
+				Method:      call.Method,
+				Data:        response.ReturnData,
+				contractAbi: call.contractAbi,
+			}
+		}
+		responses[i] = response
+		if onChunk != nil {
+			onChunk(callChunk{calls: []*Call{call}, indices: []int{i}}, responses[i:i+1])
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return responses, nil
+}
+
+// Runs a single call with its own eth_call, reporting reverts as an unsuccessful response rather than an error
+func directCall(ctx context.Context, client IContractCaller, call *Call, gasLimit uint64, opts *callOptions) (CallResponse, error) {
+	opts = call.targetOptions(opts)
+	target := call.Target
+	returnData, err := opts.callContract(ctx, client, ethereum.CallMsg{
+		From: opts.from,
+		To:   &target,
+		Gas:  gasLimit,
+		Data: call.CallData,
+	})
+	if err == nil {
+		return CallResponse{
+			Status:     true,
+			ReturnData: returnData,
+		}, nil
+	}
+
+	revertData, isRevert := getRevertData(err)
+	if !isRevert {
+		return CallResponse{}, fmt.Errorf("error calling contract %s, method %s: %w", call.Target.Hex(), call.Method, wrapClientError(err))
+	}
+	return CallResponse{
+		Status:     false,
+		ReturnData: revertData,
+	}, nil
+}
+
+// Checks whether an error from an eth_call was caused by the call reverting, and gets the revert data if the client provided it
+func getRevertData(err error) ([]byte, bool) {
+	var dataErr rpc.DataError
+	if errors.As(err, &dataErr) {
+		if hexData, ok := dataErr.ErrorData().(string); ok {
+			data, decodeErr := hexutil.Decode(hexData)
+			if decodeErr == nil {
+				return data, true
+			}
+		}
+	}
+	if strings.Contains(err.Error(), "execution reverted") {
+		return nil, true
+	}
+	return nil, false
+}
+
+// Runs the calls of a chunk as individual eth_calls from the multicall contract, so they behave the same as they would have within it,
+// storing the responses in the provided results slice
+func (mc *MultiCaller) executeChunkDirectly(ctx context.Context, chunk callChunk, requireSuccess bool, opts *callOptions, results []CallResponse) error {
+	callOpts := *opts
+	callOpts.from = mc.contractAddress
+	for i, call := range chunk.calls {
+		response, err := directCall(ctx, mc.client, call, mc.gasLimit(opts), &callOpts)
+		if err != nil {
+			return err
+		}
+		if requireSuccess && !response.Status {
+			return &ErrCallReverted{
+				Index:       chunk.indices[i],
+				Target:      call.Target,
+				Method:      call.Method,
+				Data:        response.ReturnData,
+				contractAbi: call.contractAbi,
+			}
+		}
+		results[i] = response
+	}
+	return nil
+}
+
+// Checks whether a chunk failed for a reason unrelated to any of its calls, such as a provider limit, so its calls can be retried individually
+func isChunkFailure(ctx context.Context, err error) bool {
+	if ctx.Err() != nil {
+		return false
+	}
+	var reverted *ErrCallReverted
+	if errors.As(err, &reverted) {
+		return false
+	}
+	return errors.Is(err, ErrClientFailure) || errors.Is(err, ErrUnpackFailed)
+}
@@ -0,0 +1,115 @@
+package batchquery
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// The well-known multicall deployments that Discover probes for, in order of preference.
+// Multicall3 is a superset of Multicall2, so it's preferred wherever both exist.
+var discoverableMulticalls = []common.Address{
+	Multicall3Address,
+	Multicall2Address,
+}
+
+// Probes the connected chain for a well-known multicall deployment and returns a MultiCaller that uses the first one it finds.
+// This removes the need to know which helper contracts exist on a given network ahead of time.
+// Returns ErrMulticallNotFound if none of them exist on the chain.
+func Discover(client IContractCaller, opts *bind.CallOpts) (*MultiCaller, error) {
+	address, err := DiscoverMulticallAddress(client, opts)
+	if err != nil {
+		return nil, err
+	}
+	return NewMultiCaller(client, address)
+}
+
+// Probes the connected chain for a well-known multicall deployment and returns the address of the first one it finds.
+// Returns ErrMulticallNotFound if none of them exist on the chain.
+func DiscoverMulticallAddress(client IContractCaller, opts *bind.CallOpts) (common.Address, error) {
+	options := newCallOptions(opts)
+	for _, address := range discoverableMulticalls {
+		found, err := probeMulticall(options.ctx, client, address, options)
+		if err != nil {
+			return common.Address{}, err
+		}
+		if found {
+			return address, nil
+		}
+	}
+	return common.Address{}, ErrMulticallNotFound
+}
+
+// Checks whether a multicall contract exists at the provided address by calling its getBlockNumber function,
+// which returns nothing if there's no code at the address
+func probeMulticall(ctx context.Context, client IContractCaller, address common.Address, opts *callOptions) (bool, error) {
+	mcAbi, err := getMulticallAbi()
+	if err != nil {
+		return false, err
+	}
+	callData, err := mcAbi.Pack("getBlockNumber")
+	if err != nil {
+		return false, fmt.Errorf("error packing block number call data: %w", err)
+	}
+	response, err := opts.callContract(ctx, client, ethereum.CallMsg{To: &address, Data: callData})
+	if err != nil {
+		// A revert means there's a contract there, but it isn't a multicall
+		_, isRevert := getRevertData(err)
+		if isRevert {
+			return false, nil
+		}
+		return false, fmt.Errorf("error probing for multicall contract at %s: %w", address.Hex(), wrapClientError(err))
+	}
+	if len(response) != wordSize {
+		return false, nil
+	}
+	return true, nil
+}
+
+// Checks whether the MultiCaller's multicall contract supports tryAggregate, and enables AggregateOnly if it only supports Multicall v1's aggregate.
+// Returns an error wrapping ErrMulticallNotFound if the contract supports neither.
+func (mc *MultiCaller) DetectAggregateOnly(opts *bind.CallOpts) error {
+	return mc.detectAggregateOnly(mc.newCallOptions(opts))
+}
+
+// Implementation of DetectAggregateOnly
+func (mc *MultiCaller) detectAggregateOnly(options *callOptions) error {
+	supported, err := mc.probeAggregate(options, encodeTryAggregate(false, nil), decodeTryAggregate)
+	if err != nil {
+		return err
+	}
+	if supported {
+		mc.AggregateOnly = false
+		mc.aggregateDetected = true
+		return nil
+	}
+
+	supported, err = mc.probeAggregate(options, encodeAggregate(nil), decodeAggregate)
+	if err != nil {
+		return err
+	}
+	if !supported {
+		return fmt.Errorf("contract at %s supports neither tryAggregate nor aggregate: %w", mc.contractAddress.Hex(), ErrMulticallNotFound)
+	}
+	mc.AggregateOnly = true
+	mc.aggregateDetected = true
+	return nil
+}
+
+// Checks whether the MultiCaller's multicall contract supports an aggregation function by running it with no calls
+func (mc *MultiCaller) probeAggregate(opts *callOptions, callData []byte, decode func(response []byte, results []CallResponse) error) (bool, error) {
+	ctx, cancel := withRequestTimeout(opts.ctx, mc.ChunkTimeout)
+	defer cancel()
+	response, err := opts.callContract(ctx, mc.client, ethereum.CallMsg{To: &mc.contractAddress, Data: callData})
+	if err != nil {
+		_, isRevert := getRevertData(err)
+		if isRevert {
+			return false, nil
+		}
+		return false, fmt.Errorf("error probing multicall contract at %s: %w", mc.contractAddress.Hex(), wrapClientError(err))
+	}
+	return decode(response, []CallResponse{}) == nil, nil
+}
@@ -0,0 +1,180 @@
+package batchquery
+
+import (
+	"context"
+	"errors"
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+)
+
+func TestDetectAggregateOnly(t *testing.T) {
+	mc, client := newTestMultiCaller(t)
+	err := mc.DetectAggregateOnly(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if mc.AggregateOnly {
+		t.Fatal("expected a contract with tryAggregate to be used normally")
+	}
+
+	client.aggregateOnly = true
+	err = mc.DetectAggregateOnly(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !mc.AggregateOnly {
+		t.Fatal("expected a v1 contract to be detected")
+	}
+}
+
+func TestDetectAggregateOnlyWithoutContract(t *testing.T) {
+	client := &mockClient{}
+	mc, err := NewMultiCaller(client, common.HexToAddress("0x3333"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = mc.DetectAggregateOnly(nil)
+	if !errors.Is(err, ErrMulticallNotFound) {
+		t.Fatalf("expected a missing contract to be reported, got %v", err)
+	}
+}
+
+func TestAggregateOnlyBatches(t *testing.T) {
+	mc, client := newTestMultiCaller(t)
+	client.aggregateOnly = true
+	mc.AggregateOnly = true
+
+	// A batch where every call succeeds runs as a single aggregate
+	balances := make([]*big.Int, 3)
+	for i := range balances {
+		mc.AddCall(testTokenAddress, &testTokenAbi, &balances[i], "balanceOf", common.BigToAddress(big.NewInt(int64(i+1))))
+	}
+	success, err := mc.FlexibleCall(false, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, balance := range balances {
+		if !success[i] || balance.Int64() != int64(i+1) {
+			t.Fatalf("call %d returned %s", i, balance)
+		}
+	}
+	if client.calls != 1 {
+		t.Fatalf("expected a single eth_call, got %d", client.calls)
+	}
+
+	// A failing call makes the tolerant batch fall back to individual calls from the multicall contract
+	var boom *big.Int
+	var sender common.Address
+	mc.AddCall(testTokenAddress, &testTokenAbi, &balances[0], "balanceOf", common.HexToAddress("0x07"))
+	mc.AddCall(testTokenAddress, &testTokenAbi, &boom, "boom")
+	mc.AddCall(testTokenAddress, &testTokenAbi, &sender, "whoami")
+	results, err := mc.FlexibleCallWithResults(false, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !results[0].Success || results[1].Success || !results[2].Success {
+		t.Fatalf("expected only the second call to fail, got %+v", results)
+	}
+	if results[1].RevertReason() != "boom" || balances[0].Int64() != 7 || sender != testMulticallAddress {
+		t.Fatalf("unexpected results: reason %q, balance %s, sender %s", results[1].RevertReason(), balances[0], sender.Hex())
+	}
+
+	// A batch that requires success fails outright
+	mc.AddCall(testTokenAddress, &testTokenAbi, &boom, "boom")
+	_, err = mc.FlexibleCall(true, nil)
+	var reverted *ErrCallReverted
+	if !errors.As(err, &reverted) {
+		t.Fatalf("expected a revert error, got %v", err)
+	}
+}
+
+func TestAutoDetectAggregateOnly(t *testing.T) {
+	mc, client := newTestMultiCaller(t)
+	client.aggregateOnly = true
+	mc.AutoDetectAggregateOnly = true
+
+	// The first batch detects that the contract only supports aggregate, and isolates the failing call
+	var balance *big.Int
+	var boom *big.Int
+	mc.AddCall(testTokenAddress, &testTokenAbi, &balance, "balanceOf", common.HexToAddress("0x07"))
+	mc.AddCall(testTokenAddress, &testTokenAbi, &boom, "boom")
+	success, err := mc.FlexibleCall(false, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !mc.AggregateOnly {
+		t.Fatal("expected AggregateOnly to be detected")
+	}
+	if !success[0] || success[1] || balance.Int64() != 7 {
+		t.Fatalf("expected only the second call to fail, got %v with balance %s", success, balance)
+	}
+
+	// The detection only runs once
+	calls := client.calls
+	mc.AddCall(testTokenAddress, &testTokenAbi, &balance, "balanceOf", common.HexToAddress("0x08"))
+	_, err = mc.FlexibleCall(true, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if client.calls != calls+1 {
+		t.Fatalf("expected a single eth_call for the second batch, got %d", client.calls-calls)
+	}
+}
+
+// A client for a chain with a multicall contract only at the provided addresses; calls to any other address return nothing, like calls to an account without code
+type mockDiscoveryClient struct {
+	multicalls []common.Address
+
+	err error
+}
+
+func (c *mockDiscoveryClient) CallContract(ctx context.Context, msg ethereum.CallMsg, blockNumber *big.Int) ([]byte, error) {
+	if c.err != nil {
+		return nil, c.err
+	}
+	for _, address := range c.multicalls {
+		if *msg.To == address {
+			return common.LeftPadBytes(big.NewInt(100).Bytes(), wordSize), nil
+		}
+	}
+	return []byte{}, nil
+}
+
+func TestDiscover(t *testing.T) {
+	client := &mockDiscoveryClient{multicalls: []common.Address{Multicall2Address}}
+	mc, err := Discover(client, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if mc.contractAddress != Multicall2Address {
+		t.Fatalf("expected the Multicall2 deployment, got %s", mc.contractAddress.Hex())
+	}
+
+	// Multicall3 is preferred wherever both exist
+	client.multicalls = append(client.multicalls, Multicall3Address)
+	address, err := DiscoverMulticallAddress(client, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if address != Multicall3Address {
+		t.Fatalf("expected the Multicall3 deployment, got %s", address.Hex())
+	}
+}
+
+func TestDiscoverWithoutMulticall(t *testing.T) {
+	client := &mockDiscoveryClient{}
+	_, err := Discover(client, nil)
+	if !errors.Is(err, ErrMulticallNotFound) {
+		t.Fatalf("expected a missing contract to be reported, got %v", err)
+	}
+
+	// A client failure isn't mistaken for a missing contract
+	client.err = errors.New("connection refused")
+	_, err = DiscoverMulticallAddress(client, nil)
+	if err == nil || errors.Is(err, ErrMulticallNotFound) || !errors.Is(err, ErrClientFailure) {
+		t.Fatalf("expected the client failure to be reported, got %v", err)
+	}
+}
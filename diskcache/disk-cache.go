@@ -0,0 +1,133 @@
+// Package diskcache provides a bbolt backend for batchquery's result cache, which keeps the results in a file on disk.
+package diskcache
+
+import (
+	"fmt"
+	"time"
+
+	batchquery "github.com/rocket-pool/batch-query"
+	bolt "go.etcd.io/bbolt"
+)
+
+const (
+	// How long to wait for the lock on the cache file before giving up, since only one process can open it at a time
+	lockTimeout time.Duration = 5 * time.Second
+)
+
+// The bucket that holds the cached results for explicit blocks
+var resultsBucket = []byte("results")
+
+// The bucket that holds the cached results for the latest block, which is dropped whenever they're invalidated
+var latestBucket = []byte("latest")
+
+// A persistent cache of call results stored in a bbolt database file, so backfill jobs that query historical blocks
+// never request the same call twice, even across process restarts. Entries are kept until the file is deleted.
+// Only one process can open the file at a time.
+type Cache struct {
+	// The format the results are stored in. Changing it makes the entries stored with the previous codec unreadable, so they should be cleared first.
+	Codec batchquery.ICacheCodec
+
+	// The database
+	db *bolt.DB
+}
+
+// Opens the cache stored in the file at the provided path, creating the file if it doesn't exist yet
+func NewCache(path string) (*Cache, error) {
+	db, err := bolt.Open(path, 0600, &bolt.Options{Timeout: lockTimeout})
+	if err != nil {
+		return nil, fmt.Errorf("error opening cache file %s: %w", path, err)
+	}
+	err = db.Update(func(tx *bolt.Tx) error {
+		_, err := tx.CreateBucketIfNotExists(resultsBucket)
+		if err != nil {
+			return err
+		}
+		_, err = tx.CreateBucketIfNotExists(latestBucket)
+		return err
+	})
+	if err != nil {
+		db.Close()
+		return nil, fmt.Errorf("error creating cache bucket in %s: %w", path, err)
+	}
+	return &Cache{
+		Codec: batchquery.RawCacheCodec{},
+		db:    db,
+	}, nil
+}
+
+// Ensure Cache implements the batchquery result cache interfaces
+var _ batchquery.IResultCache = (*Cache)(nil)
+var _ batchquery.IBatchResultCache = (*Cache)(nil)
+var _ batchquery.ILatestResultCache = (*Cache)(nil)
+
+// Gets the cached response for a call, and whether there was one
+func (c *Cache) Get(key batchquery.CacheKey) (batchquery.CallResponse, bool, error) {
+	var response batchquery.CallResponse
+	var found bool
+	err := c.db.View(func(tx *bolt.Tx) error {
+		value := tx.Bucket(getBucket(key)).Get(key.Bytes())
+		if value == nil {
+			return nil
+		}
+
+		// The value is only valid during the transaction, so it has to be decoded within it
+		var err error
+		response, err = c.Codec.Decode(value)
+		if err != nil {
+			return err
+		}
+		found = true
+		return nil
+	})
+	if err != nil {
+		return batchquery.CallResponse{}, false, err
+	}
+	return response, found, nil
+}
+
+// Stores the response for a call
+func (c *Cache) Set(key batchquery.CacheKey, response batchquery.CallResponse) error {
+	return c.SetAll([]batchquery.CacheKey{key}, []batchquery.CallResponse{response})
+}
+
+// Stores the response for each call in a single transaction
+func (c *Cache) SetAll(keys []batchquery.CacheKey, responses []batchquery.CallResponse) error {
+	return c.db.Update(func(tx *bolt.Tx) error {
+		for i, key := range keys {
+			value, err := c.Codec.Encode(responses[i])
+			if err != nil {
+				return err
+			}
+			err = tx.Bucket(getBucket(key)).Put(key.Bytes(), value)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// Removes every entry for the latest block
+func (c *Cache) InvalidateLatest() error {
+	return c.db.Update(func(tx *bolt.Tx) error {
+		err := tx.DeleteBucket(latestBucket)
+		if err != nil {
+			return err
+		}
+		_, err = tx.CreateBucket(latestBucket)
+		return err
+	})
+}
+
+// Closes the cache file
+func (c *Cache) Close() error {
+	return c.db.Close()
+}
+
+// Gets the bucket that holds the entry for a key
+func getBucket(key batchquery.CacheKey) []byte {
+	if key.Latest {
+		return latestBucket
+	}
+	return resultsBucket
+}
@@ -0,0 +1,106 @@
+package diskcache
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	batchquery "github.com/rocket-pool/batch-query"
+)
+
+// Opens a cache in the provided directory, closing it when the test ends
+func openTestCache(t *testing.T, dir string) *Cache {
+	cache, err := NewCache(filepath.Join(dir, "cache.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		cache.Close()
+	})
+	return cache
+}
+
+// Creates a key for a call at the provided block
+func testCacheKey(blockNumber uint64) batchquery.CacheKey {
+	return batchquery.CacheKey{
+		ChainID:     1337,
+		BlockNumber: blockNumber,
+		Target:      common.HexToAddress("0x2222222222222222222222222222222222222222"),
+		CallData:    []byte{1, 2, 3, 4},
+	}
+}
+
+func TestCachePersistsAcrossRestarts(t *testing.T) {
+	dir := t.TempDir()
+	cache := openTestCache(t, dir)
+	keys := []batchquery.CacheKey{testCacheKey(50), testCacheKey(51)}
+	responses := []batchquery.CallResponse{
+		{Status: true, ReturnData: []byte{1, 2}},
+		{Status: false, ReturnData: []byte{3}},
+	}
+	err := cache.SetAll(keys, responses)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cache.Close()
+
+	// A new process reads the same file
+	cache = openTestCache(t, dir)
+	for i, key := range keys {
+		cached, found, err := cache.Get(key)
+		if err != nil || !found || cached.Status != responses[i].Status || string(cached.ReturnData) != string(responses[i].ReturnData) {
+			t.Fatalf("expected entry %d to be kept, got %+v %t (%v)", i, cached, found, err)
+		}
+	}
+	_, found, err := cache.Get(testCacheKey(52))
+	if err != nil || found {
+		t.Fatalf("expected a miss, got %t (%v)", found, err)
+	}
+}
+
+func TestCacheWithCodec(t *testing.T) {
+	dir := t.TempDir()
+	cache := openTestCache(t, dir)
+	cache.Codec = batchquery.JsonCacheCodec{}
+	key := testCacheKey(50)
+	err := cache.Set(key, batchquery.CallResponse{Status: true, ReturnData: []byte{5, 6}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	cached, found, err := cache.Get(key)
+	if err != nil || !found || !cached.Status || len(cached.ReturnData) != 2 || cached.ReturnData[1] != 6 {
+		t.Fatalf("expected the entry to round trip, got %+v %t (%v)", cached, found, err)
+	}
+
+	// Entries stored with another codec can't be read
+	cache.Codec = batchquery.GobCacheCodec{}
+	_, _, err = cache.Get(key)
+	if err == nil {
+		t.Fatal("expected the entry not to decode with a different codec")
+	}
+}
+
+func TestCacheInvalidateLatest(t *testing.T) {
+	cache := openTestCache(t, t.TempDir())
+	key := testCacheKey(1)
+	latestKey := key
+	latestKey.Latest = true
+	response := batchquery.CallResponse{Status: true, ReturnData: []byte{1, 2}}
+	err := cache.SetAll([]batchquery.CacheKey{key, latestKey}, []batchquery.CallResponse{response, response})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = cache.InvalidateLatest()
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, found, err := cache.Get(latestKey)
+	if err != nil || found {
+		t.Fatalf("expected the latest entry to be invalidated, got %t (%v)", found, err)
+	}
+	cached, found, err := cache.Get(key)
+	if err != nil || !found || !cached.Status || len(cached.ReturnData) != 2 {
+		t.Fatalf("expected the historical entry to be kept, got %+v %t (%v)", cached, found, err)
+	}
+}
@@ -0,0 +1,273 @@
+package batchquery
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+)
+
+const (
+	// The weight of each new sample in an endpoint's rolling latency and error rate
+	endpointSmoothing float64 = 0.2
+
+	// The error rate above which an endpoint is considered degraded, if the pool doesn't set one
+	defaultMaxErrorRate float64 = 0.25
+
+	// How often endpoints that aren't the best one are re-probed, if the pool doesn't set an interval
+	defaultProbeInterval time.Duration = 30 * time.Second
+)
+
+// An IContractCaller that spreads requests across several endpoints, tracking the rolling latency and error rate of each one
+// and routing each new request (such as a MultiCaller's chunk) to the one that's currently performing best: the fastest of the endpoints
+// whose error rate is at most MaxErrorRate, or the most reliable one if they're all degraded. Endpoints that haven't served a request yet are tried first.
+// Endpoints that aren't the best, because they're slow or failing, are re-probed with a request every ProbeInterval so their stats stay current
+// and they're used again once they recover. Failed requests aren't retried on another endpoint; use an Executor with retries for that,
+// since each retry is routed to the endpoint that's best after the failure. Calls at a block hash and against the pending block are only
+// routed to the endpoints that support them.
+// Endpoints can lag each other by a block or two, so batches that must see a single block should be pinned to an explicit block or hash.
+type EndpointPool struct {
+	// The error rate (between 0 and 1) above which an endpoint is degraded, and only used if every endpoint is (0 = 0.25)
+	MaxErrorRate float64
+
+	// How long an endpoint can go without a request before it's re-probed (0 = 30 seconds)
+	ProbeInterval time.Duration
+
+	// The endpoints, in the order they were added
+	endpoints []*poolEndpoint
+
+	// Lock for the endpoints' stats
+	lock sync.Mutex
+}
+
+// An endpoint in a pool and its stats
+type poolEndpoint struct {
+	// The name of the endpoint
+	name string
+
+	// The client for the endpoint
+	client IContractCaller
+
+	// The rolling latency of the endpoint's successful requests
+	latency time.Duration
+
+	// The rolling fraction of the endpoint's requests that failed
+	errorRate float64
+
+	// The number of requests sent to the endpoint
+	requests uint64
+
+	// The number of the endpoint's requests that failed
+	failures uint64
+
+	// When the last request was sent to the endpoint
+	lastUsed time.Time
+}
+
+// The performance of one of an EndpointPool's endpoints
+type EndpointStats struct {
+	// The name the endpoint was added with
+	Name string
+
+	// The rolling latency of the endpoint's successful requests (0 = none have succeeded yet)
+	Latency time.Duration
+
+	// The rolling fraction of the endpoint's requests that failed, between 0 and 1
+	ErrorRate float64
+
+	// The number of requests sent to the endpoint
+	Requests uint64
+
+	// The number of the endpoint's requests that failed
+	Failures uint64
+
+	// Whether the endpoint's error rate is above the pool's MaxErrorRate
+	Degraded bool
+}
+
+// Creates a new EndpointPool without any endpoints
+func NewEndpointPool() *EndpointPool {
+	return &EndpointPool{}
+}
+
+// Adds an endpoint to the pool, identified in its stats by the provided name. Endpoints should be added before the pool is used.
+func (p *EndpointPool) AddEndpoint(name string, client IContractCaller) *EndpointPool {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	p.endpoints = append(p.endpoints, &poolEndpoint{
+		name:   name,
+		client: client,
+	})
+	return p
+}
+
+// Gets the stats of each endpoint, in the order they were added
+func (p *EndpointPool) Stats() []EndpointStats {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	stats := make([]EndpointStats, len(p.endpoints))
+	for i, endpoint := range p.endpoints {
+		stats[i] = EndpointStats{
+			Name:      endpoint.name,
+			Latency:   endpoint.latency,
+			ErrorRate: endpoint.errorRate,
+			Requests:  endpoint.requests,
+			Failures:  endpoint.failures,
+			Degraded:  p.isDegraded(endpoint),
+		}
+	}
+	return stats
+}
+
+// Calls a contract function on the best endpoint
+func (p *EndpointPool) CallContract(ctx context.Context, call ethereum.CallMsg, blockNumber *big.Int) ([]byte, error) {
+	endpoint, err := p.selectEndpoint(nil)
+	if err != nil {
+		return nil, err
+	}
+	return p.send(ctx, endpoint, func() ([]byte, error) {
+		return endpoint.client.CallContract(ctx, call, blockNumber)
+	})
+}
+
+// Calls a contract function at a block hash on the best of the endpoints that support it
+func (p *EndpointPool) CallContractAtHash(ctx context.Context, call ethereum.CallMsg, blockHash common.Hash) ([]byte, error) {
+	endpoint, err := p.selectEndpoint(func(client IContractCaller) bool {
+		_, ok := client.(IContractCallerAtHash)
+		return ok
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error selecting an endpoint for calls at a block hash: %w", err)
+	}
+	return p.send(ctx, endpoint, func() ([]byte, error) {
+		return endpoint.client.(IContractCallerAtHash).CallContractAtHash(ctx, call, blockHash)
+	})
+}
+
+// Calls a contract function against the pending block on the best of the endpoints that support it
+func (p *EndpointPool) PendingCallContract(ctx context.Context, call ethereum.CallMsg) ([]byte, error) {
+	endpoint, err := p.selectEndpoint(func(client IContractCaller) bool {
+		_, ok := client.(IPendingContractCaller)
+		return ok
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error selecting an endpoint for calls against the pending block: %w", err)
+	}
+	return p.send(ctx, endpoint, func() ([]byte, error) {
+		return endpoint.client.(IPendingContractCaller).PendingCallContract(ctx, call)
+	})
+}
+
+// Sends a request to an endpoint and records how it went in the endpoint's stats.
+// Reverts are the contract's answer rather than a problem with the endpoint, so they count as successes; cancelled requests aren't counted at all.
+func (p *EndpointPool) send(ctx context.Context, endpoint *poolEndpoint, request func() ([]byte, error)) ([]byte, error) {
+	start := time.Now()
+	data, err := request()
+	latency := time.Since(start)
+
+	if err != nil && ctx.Err() != nil {
+		return data, err
+	}
+	failed := 0.0
+	if err != nil {
+		if _, isRevert := getRevertData(err); !isRevert {
+			failed = 1
+		}
+	}
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	if failed > 0 {
+		endpoint.failures++
+	} else if endpoint.latency == 0 {
+		endpoint.latency = latency
+	} else {
+		endpoint.latency += time.Duration(endpointSmoothing * float64(latency-endpoint.latency))
+	}
+	if endpoint.requests == 0 {
+		endpoint.errorRate = failed
+	} else {
+		endpoint.errorRate += endpointSmoothing * (failed - endpoint.errorRate)
+	}
+	endpoint.requests++
+	return data, err
+}
+
+// Selects the endpoint for the next request from the ones that support it (nil supports = all of them), and marks it as used
+func (p *EndpointPool) selectEndpoint(supports func(client IContractCaller) bool) (*poolEndpoint, error) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	probeInterval := p.ProbeInterval
+	if probeInterval <= 0 {
+		probeInterval = defaultProbeInterval
+	}
+	now := time.Now()
+
+	var best *poolEndpoint
+	var probe *poolEndpoint
+	var fallback *poolEndpoint
+	for _, endpoint := range p.endpoints {
+		if supports != nil && !supports(endpoint.client) {
+			continue
+		}
+		if fallback == nil {
+			fallback = endpoint
+		}
+
+		// Try every endpoint once before comparing them; ones whose first request is still running have nothing to compare yet
+		if endpoint.requests == 0 {
+			if endpoint.lastUsed.IsZero() {
+				best = endpoint
+				probe = nil
+				break
+			}
+			continue
+		}
+		if probe == nil && now.Sub(endpoint.lastUsed) >= probeInterval {
+			probe = endpoint
+		}
+		if best == nil || p.isBetter(endpoint, best) {
+			best = endpoint
+		}
+	}
+	if best == nil {
+		best = fallback
+	}
+	if best == nil {
+		return nil, fmt.Errorf("no endpoint in the pool supports the request")
+	}
+	if probe != nil && probe != best {
+		best = probe
+	}
+	best.lastUsed = now
+	return best, nil
+}
+
+// Checks whether an endpoint is performing better than another one
+func (p *EndpointPool) isBetter(endpoint *poolEndpoint, other *poolEndpoint) bool {
+	degraded := p.isDegraded(endpoint)
+	otherDegraded := p.isDegraded(other)
+	if degraded != otherDegraded {
+		return !degraded
+	}
+	if degraded && endpoint.errorRate != other.errorRate {
+		return endpoint.errorRate < other.errorRate
+	}
+	if endpoint.latency == 0 || other.latency == 0 {
+		// Endpoints that have never succeeded have no latency to compare
+		return other.latency == 0 && endpoint.latency != 0
+	}
+	return endpoint.latency < other.latency
+}
+
+// Checks whether an endpoint's error rate is above the pool's limit
+func (p *EndpointPool) isDegraded(endpoint *poolEndpoint) bool {
+	maxErrorRate := p.MaxErrorRate
+	if maxErrorRate <= 0 {
+		maxErrorRate = defaultMaxErrorRate
+	}
+	return endpoint.errorRate > maxErrorRate
+}
@@ -0,0 +1,134 @@
+package batchquery
+
+import (
+	"context"
+	"errors"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// A client that only exposes CallContract, hiding the other capabilities of the client it wraps
+type plainContractCaller struct {
+	IContractCaller
+}
+
+// Runs a balance call through a MultiCaller backed by the pool
+func runPoolBatch(t *testing.T, pool *EndpointPool, opts *bind.CallOpts) error {
+	mc, err := NewMultiCaller(pool, testMulticallAddress)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var balance *big.Int
+	mc.AddCall(testTokenAddress, &testTokenAbi, &balance, "balanceOf", common.HexToAddress("0x0102"))
+	_, err = mc.FlexibleCall(true, opts)
+	return err
+}
+
+func TestEndpointPoolRoutesToFastestEndpoint(t *testing.T) {
+	slow := &mockClient{
+		hook: func(ctx context.Context, msg ethereum.CallMsg) error {
+			time.Sleep(20 * time.Millisecond)
+			return nil
+		},
+	}
+	fast := &mockClient{}
+	pool := NewEndpointPool().AddEndpoint("slow", slow).AddEndpoint("fast", fast)
+
+	for i := 0; i < 5; i++ {
+		err := runPoolBatch(t, pool, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Each endpoint is tried once, and then the fast one gets every request
+	if slow.calls != 1 || fast.calls != 4 {
+		t.Fatalf("expected 1 request to the slow endpoint and 4 to the fast one, got %d and %d", slow.calls, fast.calls)
+	}
+	stats := pool.Stats()
+	if stats[0].Name != "slow" || stats[0].Requests != 1 || stats[1].Requests != 4 || stats[0].Latency <= stats[1].Latency {
+		t.Fatalf("unexpected stats %+v", stats)
+	}
+}
+
+func TestEndpointPoolReprobesDegradedEndpoints(t *testing.T) {
+	flaky := &mockClient{
+		err: errors.New("connection refused"),
+	}
+	healthy := &mockClient{}
+	pool := NewEndpointPool().AddEndpoint("flaky", flaky).AddEndpoint("healthy", healthy)
+	pool.ProbeInterval = 50 * time.Millisecond
+
+	// The flaky endpoint fails its first request and is avoided afterwards
+	err := runPoolBatch(t, pool, nil)
+	if !errors.Is(err, ErrClientFailure) {
+		t.Fatalf("expected the first request to fail, got %v", err)
+	}
+	for i := 0; i < 3; i++ {
+		err = runPoolBatch(t, pool, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	stats := pool.Stats()
+	if flaky.calls != 1 || !stats[0].Degraded || stats[0].Failures != 1 || stats[1].Degraded {
+		t.Fatalf("expected the flaky endpoint to be degraded and avoided, got %+v", stats)
+	}
+
+	// Once the probe interval passes, the degraded endpoint gets a request again
+	flaky.err = nil
+	time.Sleep(60 * time.Millisecond)
+	err = runPoolBatch(t, pool, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	stats = pool.Stats()
+	if flaky.calls != 2 || stats[0].ErrorRate >= 1 {
+		t.Fatalf("expected the degraded endpoint to be re-probed, got %+v", stats)
+	}
+
+	// Reverts are answers from a working endpoint, so they don't count as failures
+	mc, err := NewMultiCaller(pool, testMulticallAddress)
+	if err != nil {
+		t.Fatal(err)
+	}
+	mc.AddCall(testTokenAddress, &testTokenAbi, new(*big.Int), "boom")
+	_, err = mc.FlexibleCall(true, nil)
+	var reverted *ErrCallReverted
+	if !errors.As(err, &reverted) {
+		t.Fatalf("expected the call to revert, got %v", err)
+	}
+	if stats := pool.Stats(); stats[1].Failures != 0 {
+		t.Fatalf("expected the revert not to count as a failure, got %+v", stats)
+	}
+}
+
+func TestEndpointPoolRoutesByCapability(t *testing.T) {
+	plain := &mockClient{}
+	hashCapable := &mockClient{}
+	pool := NewEndpointPool().AddEndpoint("plain", plainContractCaller{plain}).AddEndpoint("hash", hashCapable)
+
+	for i := 0; i < 3; i++ {
+		err := runPoolBatch(t, pool, CallOptsAtHash(nil, common.BigToHash(big.NewInt(50))))
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	if plain.calls != 0 || hashCapable.calls == 0 {
+		t.Fatalf("expected calls at a hash to only go to the endpoint that supports them, got %d and %d", plain.calls, hashCapable.calls)
+	}
+
+	err := runPoolBatch(t, pool, &bind.CallOpts{Pending: true})
+	if err == nil {
+		t.Fatal("expected pending calls to fail without an endpoint that supports them")
+	}
+	_, err = NewEndpointPool().CallContract(context.Background(), ethereum.CallMsg{}, nil)
+	if err == nil {
+		t.Fatal("expected an empty pool to fail")
+	}
+}
@@ -0,0 +1,300 @@
+package batchquery
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+var (
+	// The selector of Solidity's Error(string) revert
+	errorSelector = crypto.Keccak256([]byte("Error(string)"))[:4]
+
+	// The selector of Solidity's Panic(uint256) revert
+	panicSelector = crypto.Keccak256([]byte("Panic(uint256)"))[:4]
+)
+
+var (
+	// The client rejected a request because it was too large, such as a multicall whose payload, response, or gas usage exceeded the provider's limits.
+	// Lowering CallBatchSize, CallDataSizeLimit, or ReturnSizeLimit usually resolves it.
+	ErrBatchTooLarge = errors.New("batch is too large for the client")
+
+	// A response couldn't be decoded, either from the multicall contract or into a call's output
+	ErrUnpackFailed = errors.New("error unpacking response")
+
+	// None of the well-known multicall deployments exist on the chain
+	ErrMulticallNotFound = errors.New("no multicall contract was found on the chain")
+
+	// A request to the Execution client failed, such as a transport error or an error returned by the node
+	ErrClientFailure = errors.New("execution client request failed")
+
+	// A call sampled for a spot check returned a different result through a direct eth_call than it did through the multicall contract
+	ErrSpotCheckFailed = errors.New("spot check found a mismatch between the multicall and direct results")
+
+	// The code at a helper contract's address doesn't match any of the expected builds, so it may be the wrong contract (or a malicious one)
+	ErrUnexpectedCode = errors.New("contract code does not match the expected code")
+
+	// There's no contract deployed at an address as of the block that was searched
+	ErrNotDeployed = errors.New("no contract is deployed at the address")
+)
+
+// A Scheduler rejected a call because the memory used by its pending and running calls would exceed its memory limit
+type ErrMemoryLimitExceeded struct {
+	// The scheduler's memory limit, in bytes
+	Limit int
+
+	// The memory already used by the scheduler's pending and running calls, in bytes
+	Used int
+
+	// The memory the rejected call would have used, in bytes
+	Size int
+}
+
+// Gets the error message
+func (e *ErrMemoryLimitExceeded) Error() string {
+	return fmt.Sprintf("call of %d bytes exceeds the scheduler's memory limit (%d of %d bytes in use)", e.Size, e.Used, e.Limit)
+}
+
+// A quorum read couldn't accept a call's result, because no response had the agreement of a majority of the quorum
+type ErrNoQuorum struct {
+	// The index of the call within the batch
+	Index int
+
+	// The contract address the call was run on
+	Target common.Address
+
+	// The name of the method that was called
+	Method string
+
+	// The number of endpoints that had to agree on the response
+	Required int
+
+	// The indices of the endpoints grouped by identical responses, with the largest group first
+	Groups [][]int
+}
+
+// Gets the error message
+func (e *ErrNoQuorum) Error() string {
+	largest := 0
+	if len(e.Groups) > 0 {
+		largest = len(e.Groups[0])
+	}
+	return fmt.Sprintf("call %d to contract %s, method %s has no quorum: %d endpoints had to agree, but the largest group of matching responses had %d", e.Index, e.Target.Hex(), e.Method, e.Required, largest)
+}
+
+// A call reverted while the batch required every call to succeed
+type ErrCallReverted struct {
+	// The index of the call within the batch, or -1 if the multicall contract reverted without identifying the call
+	Index int
+
+	// The contract address of the call's target (zero if the call wasn't identified)
+	Target common.Address
+
+	// The name of the method that was called (empty if the call wasn't identified)
+	Method string
+
+	// The revert data, if the client provided it
+	Data []byte
+
+	// The ABI of the contract that was called, used to decode its custom errors (nil = unknown)
+	contractAbi *abi.ABI
+}
+
+// Gets the error message
+func (e *ErrCallReverted) Error() string {
+	message := "a call in the batch reverted"
+	if e.Index >= 0 {
+		message = fmt.Sprintf("call %d to contract %s, method %s reverted", e.Index, e.Target.Hex(), e.Method)
+	}
+	reason := e.Reason()
+	if reason != "" {
+		message += ": " + reason
+	}
+	return message
+}
+
+// Gets the decoded revert reason, or an empty string if the client didn't provide the revert data
+func (e *ErrCallReverted) Reason() string {
+	return DecodeRevertReasonWithAbi(e.Data, e.contractAbi)
+}
+
+// The failure of a single call within a batch
+type CallError struct {
+	// The index of the call within the batch
+	Index int
+
+	// The contract address of the call's target
+	Target common.Address
+
+	// The name of the method that was called
+	Method string
+
+	// The reason the call failed; this is an *ErrCallReverted if the call reverted, or matches ErrUnpackFailed if its response couldn't be unpacked
+	Err error
+}
+
+// Gets the error message
+func (e *CallError) Error() string {
+	return e.Err.Error()
+}
+
+// Gets the underlying error
+func (e *CallError) Unwrap() error {
+	return e.Err
+}
+
+// The failures of every call in a batch whose response couldn't be unpacked, in order of their index.
+// Reverted calls aren't included, since they're reported through the batch's success flags.
+type MultiError struct {
+	// The individual failures
+	Errors []*CallError
+}
+
+// Gets the error message, which lists every failure
+func (e *MultiError) Error() string {
+	if len(e.Errors) == 1 {
+		return e.Errors[0].Error()
+	}
+	messages := make([]string, len(e.Errors))
+	for i, err := range e.Errors {
+		messages[i] = err.Error()
+	}
+	return fmt.Sprintf("%d calls failed: %s", len(e.Errors), strings.Join(messages, "; "))
+}
+
+// Gets the individual failures, so errors.Is and errors.As match any of them
+func (e *MultiError) Unwrap() []error {
+	errs := make([]error, len(e.Errors))
+	for i, err := range e.Errors {
+		errs[i] = err
+	}
+	return errs
+}
+
+// Decodes the reason from a call's revert data.
+// Supports Error(string) and Panic(uint256); other data is assumed to be a custom error and described by its selector.
+// Returns an empty string if there's no revert data.
+func DecodeRevertReason(data []byte) string {
+	if len(data) == 0 {
+		return ""
+	}
+	if len(data) < 4 {
+		return fmt.Sprintf("invalid revert data %s", hexutil.Encode(data))
+	}
+	if bytes.Equal(data[:4], errorSelector) {
+		reason, err := abi.UnpackRevert(data)
+		if err != nil {
+			return fmt.Sprintf("malformed revert reason %s", hexutil.Encode(data))
+		}
+		return reason
+	}
+	if bytes.Equal(data[:4], panicSelector) && len(data) == 4+wordSize {
+		code := new(big.Int).SetBytes(data[4:])
+		return fmt.Sprintf("panic code 0x%x", code)
+	}
+	return fmt.Sprintf("custom error %s", hexutil.Encode(data[:4]))
+}
+
+// Decodes the reason from a call's revert data like DecodeRevertReason, but describes custom errors defined in the provided ABI
+// by their name and arguments (such as "InsufficientBalance(5, 10)") rather than only their selector.
+func DecodeRevertReasonWithAbi(data []byte, contractAbi *abi.ABI) string {
+	if contractAbi == nil || len(data) < 4 {
+		return DecodeRevertReason(data)
+	}
+	var selector [4]byte
+	copy(selector[:], data[:4])
+	abiError, err := contractAbi.ErrorByID(selector)
+	if err != nil {
+		return DecodeRevertReason(data)
+	}
+	values, err := abiError.Unpack(data)
+	if err != nil {
+		return fmt.Sprintf("malformed custom error %s: %s", abiError.Name, hexutil.Encode(data))
+	}
+	args, _ := values.([]any)
+	formatted := make([]string, len(args))
+	for i, arg := range args {
+		formatted[i] = fmt.Sprint(arg)
+	}
+	return fmt.Sprintf("%s(%s)", abiError.Name, strings.Join(formatted, ", "))
+}
+
+// An error that matches one or more of the package's sentinel errors with errors.Is, in addition to the original error
+type sentinelError struct {
+	// The original error
+	err error
+
+	// The sentinel errors this one matches
+	sentinels []error
+}
+
+// Gets the error message, which is the same as the original error's
+func (e *sentinelError) Error() string {
+	return e.err.Error()
+}
+
+// Gets the errors this one matches with errors.Is and errors.As
+func (e *sentinelError) Unwrap() []error {
+	return append(e.sentinels[:len(e.sentinels):len(e.sentinels)], e.err)
+}
+
+// Messages that providers use when rejecting a request for being too large
+var tooLargeMessages = []string{
+	"too large",
+	"exceeds the limit",
+	"exceeds limit",
+	"size limit",
+	"out of gas",
+	"gas required exceeds",
+	"response size exceeded",
+}
+
+// Wraps an error from the Execution client so it matches ErrClientFailure, and ErrBatchTooLarge if the provider rejected the request's size.
+// Context cancellation is passed through as-is, since it doesn't indicate a problem with the client.
+func wrapClientError(err error) error {
+	if err == nil || errors.Is(err, ErrClientFailure) || errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return err
+	}
+	sentinels := []error{ErrClientFailure}
+	tooLarge := false
+	var httpErr rpc.HTTPError
+	if errors.As(err, &httpErr) && httpErr.StatusCode == http.StatusRequestEntityTooLarge {
+		tooLarge = true
+	} else {
+		message := strings.ToLower(err.Error())
+		for _, tooLargeMessage := range tooLargeMessages {
+			if strings.Contains(message, tooLargeMessage) {
+				tooLarge = true
+				break
+			}
+		}
+	}
+	if tooLarge {
+		sentinels = append(sentinels, ErrBatchTooLarge)
+	}
+	return &sentinelError{
+		err:       err,
+		sentinels: sentinels,
+	}
+}
+
+// Wraps an error from decoding a response so it matches ErrUnpackFailed
+func wrapUnpackError(err error) error {
+	if err == nil || errors.Is(err, ErrUnpackFailed) {
+		return err
+	}
+	return &sentinelError{
+		err:       err,
+		sentinels: []error{ErrUnpackFailed},
+	}
+}
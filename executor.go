@@ -0,0 +1,160 @@
+package batchquery
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"golang.org/x/sync/errgroup"
+)
+
+// A shared runner for the requests of every batcher that uses it, so they're bound by one concurrency limit and one retry policy
+// instead of each batcher managing its own goroutines. Set it as the Executor of each MultiCaller and batcher that should share it;
+// each one's own ThreadLimit still applies within the executor's limit.
+// A nil *Executor runs each batcher's requests with only its own ThreadLimit and the global request limit, and without retries.
+type Executor struct {
+	// The number of times to retry a request that fails with a transient client error, such as a dropped connection or a rate limit response.
+	// Reverts, cancellations, and requests that are too large for the client aren't retried (0 = no retries).
+	Retries int
+
+	// The delay before the first retry, which doubles for each retry after it (0 = retry immediately)
+	RetryDelay time.Duration
+
+	// The compute unit budget of the provider the requests are sent to, which every attempt of every request is billed against (nil = no budget)
+	Budget *ComputeBudget
+
+	// Whether to plan the batches of every MultiCaller that uses the executor together, so a call that several independent batches run at the same time
+	// (the same target and call data, through the same client and multicall contract, with the same sender and block) is only sent once.
+	// A batch that finds one of its calls already in flight waits for that response instead of sending the call again, and sends it itself
+	// if the other batch fails. Batches with a Verifier, a SpotCheckRate, or a ShadowHandler always send their own calls.
+	MergeDuplicateCalls bool
+
+	// The calls in flight through the executor's MultiCallers, by what they read, which are shared if MergeDuplicateCalls is set
+	inflight map[sharedCallKey]*sharedCall
+
+	// Lock for the calls in flight
+	lock sync.Mutex
+
+	// The slots for running requests, one per request that can run at once (nil = no limit)
+	slots chan struct{}
+}
+
+// Creates a new Executor that runs at most threadLimit requests at once across every batcher that uses it (0 = no limit)
+func NewExecutor(threadLimit int) *Executor {
+	executor := &Executor{}
+	if threadLimit > 0 {
+		executor.slots = make(chan struct{}, threadLimit)
+	}
+	return executor
+}
+
+// Runs a task for each of count requests, with at most threadLimit of them running at once (0 = no limit) on top of the executor's own limit
+// and the global request limit. Each task sends one request with the provided JSON-RPC method, or a batch of sizes(index) of them (nil = 1 each),
+// which is what they're billed as against the executor's budget. The first task to fail cancels the rest, and its error is returned.
+// Tasks may be retried, so they must be safe to run more than once.
+func (e *Executor) run(ctx context.Context, threadLimit int, method string, count int, sizes func(index int) int, task func(ctx context.Context, index int) error) error {
+	wg, ctx := errgroup.WithContext(ctx)
+	if threadLimit > 0 {
+		wg.SetLimit(threadLimit)
+	}
+	for i := 0; i < count; i++ {
+		i := i
+		wg.Go(func() error {
+			err := ctx.Err()
+			if err != nil {
+				return err
+			}
+			requests := 1
+			if sizes != nil {
+				requests = sizes(i)
+			}
+			return e.runWithRetries(ctx, method, requests, func() error {
+				return task(ctx, i)
+			})
+		})
+	}
+	return wg.Wait()
+}
+
+// Runs a single request once it has a slot, retrying it if it fails with a transient error
+func (e *Executor) runWithRetries(ctx context.Context, method string, requests int, request func() error) error {
+	retries := 0
+	var delay time.Duration
+	if e != nil {
+		retries = e.Retries
+		delay = e.RetryDelay
+	}
+	for attempt := 0; ; attempt++ {
+		if e != nil && e.Budget != nil {
+			err := e.Budget.spend(ctx, method, requests)
+			if err != nil {
+				return err
+			}
+		}
+		release, err := e.acquire(ctx)
+		if err != nil {
+			return err
+		}
+		err = request()
+		release()
+		if err == nil || attempt >= retries || !isTransientError(ctx, err) {
+			return err
+		}
+
+		// Back off before the next attempt
+		if delay > 0 {
+			timer := time.NewTimer(delay)
+			select {
+			case <-ctx.Done():
+				timer.Stop()
+				return err
+			case <-timer.C:
+			}
+			delay *= 2
+		}
+	}
+}
+
+// Waits for a free slot in the executor, then for one within the global request limit, returning the function that frees them both.
+// The executor's slot is taken first, so requests waiting on the global limit only hold up other requests of the same executor.
+func (e *Executor) acquire(ctx context.Context) (func(), error) {
+	var slots chan struct{}
+	if e != nil {
+		slots = e.slots
+	}
+	if slots != nil {
+		select {
+		case slots <- struct{}{}:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+
+	releaseGlobal, err := acquireGlobalRequestSlot(ctx)
+	if err != nil {
+		if slots != nil {
+			<-slots
+		}
+		return nil, err
+	}
+	return func() {
+		releaseGlobal()
+		if slots != nil {
+			<-slots
+		}
+	}, nil
+}
+
+// Checks whether a request failed for a reason that may not happen again, so it's worth retrying
+func isTransientError(ctx context.Context, err error) bool {
+	if ctx.Err() != nil || !errors.Is(err, ErrClientFailure) || errors.Is(err, ErrBatchTooLarge) {
+		return false
+	}
+	var reverted *ErrCallReverted
+	if errors.As(err, &reverted) {
+		return false
+	}
+	_, isRevert := getRevertData(err)
+	return !isRevert
+}
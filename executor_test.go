@@ -0,0 +1,134 @@
+package batchquery
+
+import (
+	"context"
+	"errors"
+	"math/big"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"golang.org/x/sync/errgroup"
+)
+
+// Tracks the number of requests in flight across several clients
+type concurrencyTracker struct {
+	current int64
+	peak    int64
+}
+
+func (c *concurrencyTracker) track() {
+	current := atomic.AddInt64(&c.current, 1)
+	for {
+		peak := atomic.LoadInt64(&c.peak)
+		if current <= peak || atomic.CompareAndSwapInt64(&c.peak, peak, current) {
+			break
+		}
+	}
+	time.Sleep(2 * time.Millisecond)
+	atomic.AddInt64(&c.current, -1)
+}
+
+// A balance checker client whose requests are tracked
+type trackedBalanceCheckerClient struct {
+	mockBalanceCheckerClient
+	tracker *concurrencyTracker
+}
+
+func (c *trackedBalanceCheckerClient) CallContract(ctx context.Context, msg ethereum.CallMsg, blockNumber *big.Int) ([]byte, error) {
+	c.tracker.track()
+	return c.mockBalanceCheckerClient.CallContract(ctx, msg, blockNumber)
+}
+
+func TestExecutorLimitsRequestsAcrossBatchers(t *testing.T) {
+	tracker := &concurrencyTracker{}
+	executor := NewExecutor(2)
+
+	mc, client := newTestMultiCaller(t)
+	client.hook = func(ctx context.Context, msg ethereum.CallMsg) error {
+		tracker.track()
+		return nil
+	}
+	mc.CallBatchSize = 1
+	mc.Executor = executor
+	balances := make([]*big.Int, 10)
+	for i := range balances {
+		mc.AddCall(testTokenAddress, &testTokenAbi, &balances[i], "balanceOf", common.BigToAddress(big.NewInt(int64(i))))
+	}
+
+	batcher, err := NewBalanceBatcher(&trackedBalanceCheckerClient{tracker: tracker}, testTokenAddress, 1, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	batcher.Executor = executor
+	addresses := make([]common.Address, 10)
+	for i := range addresses {
+		addresses[i] = common.BigToAddress(big.NewInt(int64(i)))
+	}
+
+	// Neither batcher has a thread limit of its own, so only the executor bounds them
+	var wg errgroup.Group
+	wg.Go(func() error {
+		_, err := mc.FlexibleCall(true, nil)
+		return err
+	})
+	wg.Go(func() error {
+		_, err := batcher.GetEthBalances(addresses, nil)
+		return err
+	})
+	err = wg.Wait()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tracker.peak > 2 {
+		t.Fatalf("expected at most 2 requests at once, got %d", tracker.peak)
+	}
+	if balances[9].Cmp(expectedBalance(common.BigToAddress(big.NewInt(9)), 0)) != 0 {
+		t.Fatalf("unexpected balance %s", balances[9])
+	}
+}
+
+func TestExecutorRetriesTransientFailures(t *testing.T) {
+	mc, client := newTestMultiCaller(t)
+	var lock sync.Mutex
+	failures := 2
+	client.hook = func(ctx context.Context, msg ethereum.CallMsg) error {
+		lock.Lock()
+		defer lock.Unlock()
+		if failures > 0 {
+			failures--
+			return errors.New("429 Too Many Requests")
+		}
+		return nil
+	}
+	mc.Executor = NewExecutor(0)
+	mc.Executor.Retries = 2
+	mc.Executor.RetryDelay = time.Millisecond
+
+	var balance *big.Int
+	mc.AddCall(testTokenAddress, &testTokenAbi, &balance, "balanceOf", common.HexToAddress("0x0102"))
+	_, err := mc.FlexibleCall(true, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if client.calls != 3 || balance.Cmp(expectedBalance(common.HexToAddress("0x0102"), 0)) != 0 {
+		t.Fatalf("expected the chunk to succeed on its third attempt, got %d eth_calls and balance %s", client.calls, balance)
+	}
+}
+
+func TestExecutorDoesNotRetryReverts(t *testing.T) {
+	mc, client := newTestMultiCaller(t)
+	mc.Executor = NewExecutor(0)
+	mc.Executor.Retries = 3
+	mc.AddCall(testTokenAddress, &testTokenAbi, nil, "boom")
+	_, err := mc.FlexibleCall(true, nil)
+	if err == nil {
+		t.Fatal("expected the revert to fail the batch")
+	}
+	if client.calls != 1 {
+		t.Fatalf("expected the revert not to be retried, got %d eth_calls", client.calls)
+	}
+}
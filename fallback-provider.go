@@ -0,0 +1,183 @@
+package batchquery
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+)
+
+const (
+	// The maximum number of addresses Etherscan-compatible APIs accept in a single balancemulti request
+	etherscanBalanceBatchSize int = 20
+)
+
+// An IFallbackProvider that reads from an Etherscan-compatible REST API
+type EtherscanProvider struct {
+	// The base URL of the API, such as https://api.etherscan.io/api; any query parameters it has (such as a chain ID) are kept
+	baseUrl string
+
+	// The API key to send with each request (empty = none)
+	apiKey string
+
+	// The HTTP client used to send the requests
+	httpClient *http.Client
+}
+
+// The body of an Etherscan-compatible API response
+type etherscanResponse struct {
+	Status  string          `json:"status"`
+	Message string          `json:"message"`
+	Result  json.RawMessage `json:"result"`
+	Error   *struct {
+		Message string `json:"message"`
+	} `json:"error"`
+}
+
+// Creates a new EtherscanProvider instance (nil httpClient = http.DefaultClient)
+func NewEtherscanProvider(baseUrl string, apiKey string, httpClient *http.Client) *EtherscanProvider {
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+	return &EtherscanProvider{
+		baseUrl:    baseUrl,
+		apiKey:     apiKey,
+		httpClient: httpClient,
+	}
+}
+
+// Gets the ETH balances of the addresses at the latest block, in batches of up to 20 addresses per request
+func (p *EtherscanProvider) GetEthBalances(ctx context.Context, addresses []common.Address) ([]*big.Int, error) {
+	balances := make([]*big.Int, len(addresses))
+	for start := 0; start < len(addresses); start += etherscanBalanceBatchSize {
+		end := start + etherscanBalanceBatchSize
+		if end > len(addresses) {
+			end = len(addresses)
+		}
+		list := make([]string, end-start)
+		indices := map[common.Address][]int{}
+		for i, address := range addresses[start:end] {
+			list[i] = address.Hex()
+			indices[address] = append(indices[address], start+i)
+		}
+
+		var results []struct {
+			Account common.Address `json:"account"`
+			Balance string         `json:"balance"`
+		}
+		err := p.get(ctx, url.Values{
+			"module":  {"account"},
+			"action":  {"balancemulti"},
+			"address": {strings.Join(list, ",")},
+			"tag":     {"latest"},
+		}, &results)
+		if err != nil {
+			return nil, fmt.Errorf("error getting balances: %w", err)
+		}
+		for _, result := range results {
+			balance, ok := new(big.Int).SetString(result.Balance, 10)
+			if !ok {
+				return nil, fmt.Errorf("error getting balances: invalid balance %q for address %s: %w", result.Balance, result.Account.Hex(), ErrUnpackFailed)
+			}
+			for _, index := range indices[result.Account] {
+				balances[index] = balance
+			}
+		}
+	}
+	for i, balance := range balances {
+		if balance == nil {
+			return nil, fmt.Errorf("error getting balances: no balance was returned for address %s", addresses[i].Hex())
+		}
+	}
+	return balances, nil
+}
+
+// Gets the nonces of the addresses at the latest block, with one request per address since the APIs don't batch them
+func (p *EtherscanProvider) GetNonces(ctx context.Context, addresses []common.Address) ([]uint64, error) {
+	nonces := make([]uint64, len(addresses))
+	for i, address := range addresses {
+		var nonce hexutil.Uint64
+		err := p.get(ctx, url.Values{
+			"module":  {"proxy"},
+			"action":  {"eth_getTransactionCount"},
+			"address": {address.Hex()},
+			"tag":     {"latest"},
+		}, &nonce)
+		if err != nil {
+			return nil, fmt.Errorf("error getting nonce of address %s: %w", address.Hex(), err)
+		}
+		nonces[i] = uint64(nonce)
+	}
+	return nonces, nil
+}
+
+// Sends a request to the API and decodes its result
+func (p *EtherscanProvider) get(ctx context.Context, params url.Values, result any) error {
+	requestUrl, err := url.Parse(p.baseUrl)
+	if err != nil {
+		return fmt.Errorf("error parsing API URL: %w", err)
+	}
+	query := requestUrl.Query()
+	for key, values := range params {
+		query[key] = values
+	}
+	if p.apiKey != "" {
+		query.Set("apikey", p.apiKey)
+	}
+	requestUrl.RawQuery = query.Encode()
+
+	request, err := http.NewRequestWithContext(ctx, http.MethodGet, requestUrl.String(), nil)
+	if err != nil {
+		return fmt.Errorf("error creating API request: %w", err)
+	}
+	response, err := p.httpClient.Do(request)
+	if err != nil {
+		return fmt.Errorf("error sending API request: %w", wrapClientError(err))
+	}
+	defer response.Body.Close()
+	if response.StatusCode != http.StatusOK {
+		return fmt.Errorf("API returned status %s: %w", response.Status, ErrClientFailure)
+	}
+
+	var body etherscanResponse
+	err = json.NewDecoder(response.Body).Decode(&body)
+	if err != nil {
+		return fmt.Errorf("error decoding API response: %w", wrapUnpackError(err))
+	}
+	if body.Error != nil {
+		return fmt.Errorf("API request failed: %s", body.Error.Message)
+	}
+
+	// Failed requests have a status of 0 and describe the failure in the result (proxy requests don't have a status)
+	if body.Status == "0" {
+		var reason string
+		_ = json.Unmarshal(body.Result, &reason)
+		return fmt.Errorf("API request failed: %s %s", body.Message, reason)
+	}
+	err = json.Unmarshal(body.Result, result)
+	if err != nil {
+		return fmt.Errorf("error decoding API result: %w", wrapUnpackError(err))
+	}
+	return nil
+}
+
+// Checks whether a query that failed with the provided error can be retried against a fallback provider.
+// That's only the case if the client couldn't be reached (rather than the call reverting or the caller cancelling it),
+// and the query targets the latest block without a sender, since that's all fallback providers can serve.
+func canUseFallback(err error, options *callOptions) bool {
+	if options.blockNumber != nil || options.blockHash != nil || options.pending || options.from != (common.Address{}) {
+		return false
+	}
+	if options.ctx.Err() != nil || !errors.Is(err, ErrClientFailure) {
+		return false
+	}
+	_, isRevert := getRevertData(err)
+	return !isRevert
+}
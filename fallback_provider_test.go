@@ -0,0 +1,110 @@
+package batchquery
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// Creates an Etherscan-compatible API where each account's balance is its last byte times 1000, and its nonce is its last byte
+func newMockEtherscanServer(t *testing.T) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		query := r.URL.Query()
+		if query.Get("apikey") != "key" || query.Get("chainid") != "1" {
+			w.Write([]byte(`{"status":"0","message":"NOTOK","result":"Invalid API Key"}`))
+			return
+		}
+		switch query.Get("action") {
+		case "balancemulti":
+			addresses := strings.Split(query.Get("address"), ",")
+			if len(addresses) > etherscanBalanceBatchSize {
+				t.Errorf("received %d addresses in one request", len(addresses))
+			}
+			results := make([]string, len(addresses))
+			for i, address := range addresses {
+				results[i] = fmt.Sprintf(`{"account":"%s","balance":"%d"}`, address, int(common.HexToAddress(address)[19])*1000)
+			}
+			w.Write([]byte(`{"status":"1","message":"OK","result":[` + strings.Join(results, ",") + `]}`))
+		case "eth_getTransactionCount":
+			w.Write([]byte(fmt.Sprintf(`{"jsonrpc":"2.0","id":1,"result":"0x%x"}`, common.HexToAddress(query.Get("address"))[19])))
+		default:
+			http.Error(w, "unknown action", http.StatusBadRequest)
+		}
+	}))
+}
+
+func TestBalanceBatcherFallsBackWhenClientIsUnreachable(t *testing.T) {
+	server := newMockEtherscanServer(t)
+	defer server.Close()
+
+	batcher, err := NewBalanceBatcher(&mockClient{err: errors.New("connection refused")}, testTokenAddress, 10, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	batcher.Fallback = NewEtherscanProvider(server.URL+"?chainid=1", "key", nil)
+	addresses := make([]common.Address, 25)
+	for i := range addresses {
+		addresses[i] = common.BigToAddress(big.NewInt(int64(i)))
+	}
+	balances, err := batcher.GetEthBalances(addresses, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, balance := range balances {
+		if balance.Int64() != int64(i*1000) {
+			t.Fatalf("expected balance %d for address %d, got %s", i*1000, i, balance)
+		}
+	}
+
+	// The fallback can only serve the latest block
+	_, err = batcher.GetEthBalances(addresses, &bind.CallOpts{BlockNumber: big.NewInt(5)})
+	if err == nil {
+		t.Fatal("expected a historical query not to use the fallback")
+	}
+}
+
+func TestNonceBatcherFallsBackWhenClientIsUnreachable(t *testing.T) {
+	server := newMockEtherscanServer(t)
+	defer server.Close()
+
+	reader := &mockNonceReader{err: errors.New("connection refused")}
+	batcher := NewNonceBatcher(reader, 1)
+	batcher.Fallback = NewEtherscanProvider(server.URL+"?chainid=1", "key", nil)
+	nonces, err := batcher.GetNonces([]common.Address{common.HexToAddress("0x07"), common.HexToAddress("0x2a")}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if nonces[0] != 7 || nonces[1] != 42 {
+		t.Fatalf("expected nonces 7 and 42, got %v", nonces)
+	}
+
+	// The fallback can only serve the latest block
+	_, err = batcher.GetNonces([]common.Address{common.HexToAddress("0x07")}, &bind.CallOpts{BlockNumber: big.NewInt(5)})
+	if err == nil {
+		t.Fatal("expected a historical query not to use the fallback")
+	}
+
+	// The fallback is only used while the client is unreachable
+	reader.err = nil
+	batcher.Fallback = NewEtherscanProvider(server.URL+"?chainid=1", "wrong", nil)
+	nonces, err = batcher.GetNonces([]common.Address{common.HexToAddress("0x07")}, nil)
+	if err != nil || nonces[0] != 7 {
+		t.Fatalf("expected the client to be used while it's reachable, got %v (%v)", nonces, err)
+	}
+
+	provider := NewEtherscanProvider(server.URL+"?chainid=1", "key", nil)
+	// API failures are reported with their reason
+	provider = NewEtherscanProvider(server.URL+"?chainid=1", "wrong", nil)
+	_, err = provider.GetEthBalances(context.Background(), []common.Address{common.HexToAddress("0x07")})
+	if err == nil || !strings.Contains(err.Error(), "Invalid API Key") {
+		t.Fatalf("expected the API failure to be reported, got %v", err)
+	}
+}
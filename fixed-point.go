@@ -0,0 +1,82 @@
+package batchquery
+
+import (
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+)
+
+const (
+	// The number of decimals in a wad, the 18-decimal fixed-point format used by most tokens and exchange rates
+	WadDecimals uint8 = 18
+
+	// The number of decimals in a ray, the 27-decimal fixed-point format used by lending protocols for interest rates and indices
+	RayDecimals uint8 = 27
+)
+
+// Converts a fixed-point integer with the provided number of decimals to the exact value it represents, such as 1.05e18 with 18 decimals to 1.05
+func ScaleToRat(value *big.Int, decimals uint8) *big.Rat {
+	return new(big.Rat).SetFrac(value, pow10(decimals))
+}
+
+// Creates a decoder for return data that's a single unsigned fixed-point integer with the provided number of decimals,
+// such as WadDecimals or a token's decimals, which decodes the exact value it represents
+func NewScaledDecoder(decimals uint8) Decoder[*big.Rat] {
+	return func(data []byte) (*big.Rat, error) {
+		value, err := DecodeUint256(data)
+		if err != nil {
+			return nil, err
+		}
+		return ScaleToRat(value, decimals), nil
+	}
+}
+
+// Adds a call for a method that returns a fixed-point integer with the provided number of decimals, such as WadDecimals or a token's decimals,
+// unpacking it into the exact value it represents so callers can't forget (or double up) the scaling
+func (mc *MultiCaller) AddScaledCall(contractAddress common.Address, abi *abi.ABI, output **big.Rat, decimals uint8, method string, args ...any) *Call {
+	return mc.addConvertedCall(contractAddress, abi, output, method, func(value any) error {
+		converted, err := toBigInt(value)
+		if err != nil {
+			return err
+		}
+		*output = ScaleToRat(converted, decimals)
+		return nil
+	}, args...)
+}
+
+// Adds a call for a method that returns a wad (an 18-decimal fixed-point integer), unpacking it into the exact value it represents
+func (mc *MultiCaller) AddWadCall(contractAddress common.Address, abi *abi.ABI, output **big.Rat, method string, args ...any) *Call {
+	return mc.AddScaledCall(contractAddress, abi, output, WadDecimals, method, args...)
+}
+
+// Adds a call for a method that returns a ray (a 27-decimal fixed-point integer), unpacking it into the exact value it represents
+func (mc *MultiCaller) AddRayCall(contractAddress common.Address, abi *abi.ABI, output **big.Rat, method string, args ...any) *Call {
+	return mc.AddScaledCall(contractAddress, abi, output, RayDecimals, method, args...)
+}
+
+// Converts a decoded integer of any size to a big.Int
+func toBigInt(value any) (*big.Int, error) {
+	switch value := value.(type) {
+	case *big.Int:
+		return new(big.Int).Set(value), nil
+	case int8:
+		return big.NewInt(int64(value)), nil
+	case int16:
+		return big.NewInt(int64(value)), nil
+	case int32:
+		return big.NewInt(int64(value)), nil
+	case int64:
+		return big.NewInt(value), nil
+	}
+	converted, err := toUint64(value)
+	if err != nil {
+		return nil, err
+	}
+	return new(big.Int).SetUint64(converted), nil
+}
+
+// Formats a fixed-point integer with the provided number of decimals as a decimal string, such as 1.05e18 with 18 decimals as "1.050000000000000000"
+func FormatScaled(value *big.Int, decimals uint8) string {
+	return ScaleToRat(value, decimals).FloatString(int(decimals))
+}
@@ -0,0 +1,56 @@
+package batchquery
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+func TestScaledCalls(t *testing.T) {
+	mc, _ := newTestMultiCaller(t)
+	account := common.HexToAddress("0x0102")
+	var balance *big.Rat
+	mc.AddScaledCall(testTokenAddress, &testTokenAbi, &balance, 2, "balanceOf", account)
+	_, err := mc.FlexibleCall(true, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := new(big.Rat).SetFrac(expectedBalance(account, 0), big.NewInt(100))
+	if balance.Cmp(expected) != 0 {
+		t.Fatalf("expected the balance to be scaled by the token's decimals, got %s", balance.RatString())
+	}
+
+	// Signed values keep their sign
+	var delta *big.Rat
+	call := mc.AddRayCall(testTokenAddress, &typedTestAbi, &delta, "delta")
+	data, err := typedTestAbi.Methods["delta"].Outputs.Pack(int64(-5))
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = call.UnpackFunc(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if delta.Cmp(new(big.Rat).SetFrac(big.NewInt(-5), pow10(RayDecimals))) != 0 {
+		t.Fatalf("expected the value to be scaled as a ray, got %s", delta.RatString())
+	}
+}
+
+func TestScaledDecoding(t *testing.T) {
+	rate, _ := new(big.Int).SetString("1050000000000000000", 10)
+	data := packTypedValues(t, []string{"uint256"}, rate)
+	value, err := NewScaledDecoder(WadDecimals)(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if value.Cmp(big.NewRat(21, 20)) != 0 {
+		t.Fatalf("expected an exchange rate of 1.05, got %s", value.RatString())
+	}
+	if formatted := FormatScaled(rate, WadDecimals); formatted != "1.050000000000000000" {
+		t.Fatalf("unexpected formatted rate %s", formatted)
+	}
+	if formatted := FormatScaled(big.NewInt(-150), 2); formatted != "-1.50" {
+		t.Fatalf("unexpected formatted value %s", formatted)
+	}
+}
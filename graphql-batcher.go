@@ -0,0 +1,367 @@
+package batchquery
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+// This struct reads account state and logs through go-ethereum's GraphQL endpoint (usually served at /graphql when the node runs with --graphql).
+// A single GraphQL query can read the balances, code, or storage of many accounts at once without a helper contract,
+// so it needs fewer round-trips than one eth_getBalance or eth_getStorageAt request per value on nodes that expose it.
+type GraphQLBatcher struct {
+	// The maximum number of values to read within a single GraphQL query (0 = no limit)
+	QueryBatchSize int
+
+	// The number of queries to run simultaneously, if the values are split across multiple queries (0 = no limit)
+	ThreadLimit int
+
+	// The context that queries run in when their options don't provide one (nil = context.Background())
+	BaseContext context.Context
+
+	// The deadline for each GraphQL query, so every query has one even when the caller's context doesn't (0 = no deadline beyond the one in the context)
+	DefaultTimeout time.Duration
+
+	// The executor that runs the batcher's requests (nil = no shared executor; see Executor)
+	Executor *Executor
+
+	// The URL of the GraphQL endpoint
+	endpoint string
+
+	// The HTTP client used to send the queries
+	httpClient *http.Client
+}
+
+// The body of a GraphQL response
+type graphQLResponse struct {
+	Data   json.RawMessage `json:"data"`
+	Errors []struct {
+		Message string `json:"message"`
+	} `json:"errors"`
+}
+
+// A log in a GraphQL response
+type graphQLLog struct {
+	Index   graphQLLong `json:"index"`
+	Account struct {
+		Address common.Address `json:"address"`
+	} `json:"account"`
+	Topics      []common.Hash `json:"topics"`
+	Data        hexutil.Bytes `json:"data"`
+	Transaction struct {
+		Hash  common.Hash `json:"hash"`
+		Index graphQLLong `json:"index"`
+		Block struct {
+			Number graphQLLong `json:"number"`
+			Hash   common.Hash `json:"hash"`
+		} `json:"block"`
+	} `json:"transaction"`
+}
+
+// A GraphQL Long, which nodes encode either as a JSON number or as a hex string
+type graphQLLong uint64
+
+// Decodes a GraphQL Long from either of its encodings
+func (l *graphQLLong) UnmarshalJSON(data []byte) error {
+	var hexValue hexutil.Uint64
+	if len(data) > 0 && data[0] == '"' {
+		err := json.Unmarshal(data, &hexValue)
+		if err != nil {
+			return err
+		}
+		*l = graphQLLong(hexValue)
+		return nil
+	}
+	value, err := strconv.ParseUint(string(data), 10, 64)
+	if err != nil {
+		return fmt.Errorf("invalid Long %s: %w", string(data), err)
+	}
+	*l = graphQLLong(value)
+	return nil
+}
+
+// Creates a new GraphQLBatcher instance for the provided endpoint (nil httpClient = http.DefaultClient)
+func NewGraphQLBatcher(endpoint string, httpClient *http.Client, queryBatchSize int, threadLimit int) *GraphQLBatcher {
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+	return &GraphQLBatcher{
+		QueryBatchSize: queryBatchSize,
+		ThreadLimit:    threadLimit,
+		endpoint:       endpoint,
+		httpClient:     httpClient,
+	}
+}
+
+// Retrieves the ETH balances of a list of addresses. The order of the resulting array corresponds to the order of the provided addresses.
+// If opts sets Pending, the balances are read from the pending state.
+func (b *GraphQLBatcher) GetEthBalances(addresses []common.Address, opts *bind.CallOpts) ([]*big.Int, error) {
+	accounts, err := b.queryAccounts(addresses, "balance", opts)
+	if err != nil {
+		return nil, fmt.Errorf("error getting balances: %w", err)
+	}
+	balances := make([]*big.Int, len(addresses))
+	for i, account := range accounts {
+		var result struct {
+			Balance *hexutil.Big `json:"balance"`
+		}
+		err = json.Unmarshal(account, &result)
+		if err == nil && result.Balance == nil {
+			err = fmt.Errorf("balance is missing")
+		}
+		if err != nil {
+			return nil, fmt.Errorf("error getting balances: invalid balance for address %s: %w", addresses[i].Hex(), wrapUnpackError(err))
+		}
+		balances[i] = result.Balance.ToInt()
+	}
+	return balances, nil
+}
+
+// Retrieves the runtime code of a list of addresses. The order of the resulting array corresponds to the order of the provided addresses.
+// If opts sets Pending, the code is read from the pending state.
+func (b *GraphQLBatcher) GetCode(addresses []common.Address, opts *bind.CallOpts) ([][]byte, error) {
+	accounts, err := b.queryAccounts(addresses, "code", opts)
+	if err != nil {
+		return nil, fmt.Errorf("error getting code: %w", err)
+	}
+	code := make([][]byte, len(addresses))
+	for i, account := range accounts {
+		var result struct {
+			Code hexutil.Bytes `json:"code"`
+		}
+		err = json.Unmarshal(account, &result)
+		if err != nil {
+			return nil, fmt.Errorf("error getting code: invalid code for address %s: %w", addresses[i].Hex(), wrapUnpackError(err))
+		}
+		code[i] = result.Code
+	}
+	return code, nil
+}
+
+// Retrieves the raw values of a list of storage slots. The order of the resulting array corresponds to the order of the provided slots.
+// If opts sets Pending, the slots are read from the pending state.
+func (b *GraphQLBatcher) GetStorage(slots []StorageSlot, opts *bind.CallOpts) ([]common.Hash, error) {
+	fields := make([]string, len(slots))
+	for i, slot := range slots {
+		fields[i] = fmt.Sprintf(`account(address: "%s") { storage(slot: "%s") }`, slot.Address.Hex(), slot.Slot.Hex())
+	}
+	accounts, err := b.queryFields(fields, opts)
+	if err != nil {
+		return nil, fmt.Errorf("error getting storage: %w", err)
+	}
+	values := make([]common.Hash, len(slots))
+	for i, account := range accounts {
+		var result struct {
+			Storage *common.Hash `json:"storage"`
+		}
+		err = json.Unmarshal(account, &result)
+		if err == nil && result.Storage == nil {
+			err = fmt.Errorf("value is missing")
+		}
+		if err != nil {
+			return nil, fmt.Errorf("error getting storage: invalid value for slot %s of contract %s: %w", slots[i].Slot.Hex(), slots[i].Address.Hex(), wrapUnpackError(err))
+		}
+		values[i] = *result.Storage
+	}
+	return values, nil
+}
+
+// Gets the logs that match a filter with a single GraphQL query, which makes the batcher an ILogFilterer.
+// Filters by block hash aren't supported by the GraphQL schema, so they're rejected.
+func (b *GraphQLBatcher) FilterLogs(ctx context.Context, query ethereum.FilterQuery) ([]types.Log, error) {
+	if query.BlockHash != nil {
+		return nil, fmt.Errorf("error getting logs: GraphQL log filters can't target a block hash")
+	}
+	criteria := []string{}
+	if query.FromBlock != nil {
+		criteria = append(criteria, fmt.Sprintf("fromBlock: %s", query.FromBlock.String()))
+	}
+	if query.ToBlock != nil {
+		criteria = append(criteria, fmt.Sprintf("toBlock: %s", query.ToBlock.String()))
+	}
+	if len(query.Addresses) > 0 {
+		addresses := make([]string, len(query.Addresses))
+		for i, address := range query.Addresses {
+			addresses[i] = strconv.Quote(address.Hex())
+		}
+		criteria = append(criteria, fmt.Sprintf("addresses: [%s]", strings.Join(addresses, ", ")))
+	}
+	if len(query.Topics) > 0 {
+		positions := make([]string, len(query.Topics))
+		for i, alternatives := range query.Topics {
+			topics := make([]string, len(alternatives))
+			for j, topic := range alternatives {
+				topics[j] = strconv.Quote(topic.Hex())
+			}
+			positions[i] = "[" + strings.Join(topics, ", ") + "]"
+		}
+		criteria = append(criteria, fmt.Sprintf("topics: [%s]", strings.Join(positions, ", ")))
+	}
+	graphQuery := fmt.Sprintf(
+		"{ logs(filter: { %s }) { index account { address } topics data transaction { hash index block { number hash } } } }",
+		strings.Join(criteria, ", "),
+	)
+
+	var result struct {
+		Logs []graphQLLog `json:"logs"`
+	}
+	err := b.post(ctx, graphQuery, &result)
+	if err != nil {
+		return nil, fmt.Errorf("error getting logs: %w", err)
+	}
+	logs := make([]types.Log, len(result.Logs))
+	for i, log := range result.Logs {
+		logs[i] = types.Log{
+			Address:     log.Account.Address,
+			Topics:      log.Topics,
+			Data:        log.Data,
+			BlockNumber: uint64(log.Transaction.Block.Number),
+			TxHash:      log.Transaction.Hash,
+			TxIndex:     uint(log.Transaction.Index),
+			BlockHash:   log.Transaction.Block.Hash,
+			Index:       uint(log.Index),
+		}
+	}
+	return logs, nil
+}
+
+// Reads a single field of each of the provided accounts, returning each account's JSON object in the same order
+func (b *GraphQLBatcher) queryAccounts(addresses []common.Address, field string, opts *bind.CallOpts) ([]json.RawMessage, error) {
+	fields := make([]string, len(addresses))
+	for i, address := range addresses {
+		fields[i] = fmt.Sprintf(`account(address: "%s") { %s }`, address.Hex(), field)
+	}
+	return b.queryFields(fields, opts)
+}
+
+// Reads the provided fields from the block targeted by the options, splitting them across queries of up to QueryBatchSize fields.
+// The result of each field is returned in the same order as the fields.
+func (b *GraphQLBatcher) queryFields(fields []string, opts *bind.CallOpts) ([]json.RawMessage, error) {
+	options := newCallOptionsWithBase(opts, b.BaseContext)
+	if options.from != (common.Address{}) {
+		return nil, fmt.Errorf("GraphQL reads don't have a sender, so opts can't specify a From address")
+	}
+	selector, root := options.graphQLBlockSelector()
+	results := make([]json.RawMessage, len(fields))
+	if len(fields) == 0 {
+		return results, nil
+	}
+	batchSize := b.QueryBatchSize
+	if batchSize <= 0 {
+		batchSize = len(fields)
+	}
+
+	// A failure in any query cancels the rest of them
+	count := (len(fields) + batchSize - 1) / batchSize
+	err := b.Executor.run(options.ctx, b.ThreadLimit, "graphql", count, nil, func(ctx context.Context, index int) error {
+		start := index * batchSize
+		end := start + batchSize
+		if end > len(fields) {
+			end = len(fields)
+		}
+
+		// Give every field an alias, so many of them can be read from the same block in one query
+		var query strings.Builder
+		query.WriteString("{ ")
+		query.WriteString(selector)
+		query.WriteString(" { ")
+		for i := start; i < end; i++ {
+			fmt.Fprintf(&query, "f%d: %s ", i, fields[i])
+		}
+		query.WriteString("} }")
+
+		var data map[string]map[string]json.RawMessage
+		err := b.post(ctx, query.String(), &data)
+		if err != nil {
+			return err
+		}
+		block, exists := data[root]
+		if !exists || block == nil {
+			return fmt.Errorf("block %s was not found", options.blockDescription())
+		}
+		for i := start; i < end; i++ {
+			result, exists := block[fmt.Sprintf("f%d", i)]
+			if !exists {
+				return fmt.Errorf("response is missing field %d", i)
+			}
+			results[i] = result
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return results, nil
+}
+
+// Sends a GraphQL query and decodes the data of its response into the result
+func (b *GraphQLBatcher) post(ctx context.Context, query string, result any) error {
+	body, err := json.Marshal(map[string]string{"query": query})
+	if err != nil {
+		return fmt.Errorf("error encoding GraphQL query: %w", err)
+	}
+	ctx, cancel := withRequestTimeout(ctx, b.DefaultTimeout)
+	defer cancel()
+	request, err := http.NewRequestWithContext(ctx, http.MethodPost, b.endpoint, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("error creating GraphQL request: %w", err)
+	}
+	request.Header.Set("Content-Type", "application/json")
+	response, err := b.httpClient.Do(request)
+	if err != nil {
+		return fmt.Errorf("error sending GraphQL query: %w", wrapClientError(err))
+	}
+	defer response.Body.Close()
+	responseBody, err := io.ReadAll(response.Body)
+	if err != nil {
+		return fmt.Errorf("error reading GraphQL response: %w", wrapClientError(err))
+	}
+
+	var graphResponse graphQLResponse
+	err = json.Unmarshal(responseBody, &graphResponse)
+	if err != nil {
+		if response.StatusCode != http.StatusOK {
+			return fmt.Errorf("GraphQL endpoint returned status %s: %w", response.Status, ErrClientFailure)
+		}
+		return fmt.Errorf("error decoding GraphQL response: %w", wrapUnpackError(err))
+	}
+	if len(graphResponse.Errors) > 0 {
+		messages := make([]string, len(graphResponse.Errors))
+		for i, graphErr := range graphResponse.Errors {
+			messages[i] = graphErr.Message
+		}
+		return fmt.Errorf("GraphQL query failed: %s", strings.Join(messages, "; "))
+	}
+	err = json.Unmarshal(graphResponse.Data, result)
+	if err != nil {
+		return fmt.Errorf("error decoding GraphQL response data: %w", wrapUnpackError(err))
+	}
+	return nil
+}
+
+// Gets the GraphQL selector for the block targeted by the options, and the name of the field it's returned in
+func (o *callOptions) graphQLBlockSelector() (string, string) {
+	if o.blockHash != nil {
+		return fmt.Sprintf(`block(hash: "%s")`, o.blockHash.Hex()), "block"
+	}
+	if o.pending {
+		return "pending", "pending"
+	}
+	if o.blockNumber != nil && o.blockNumber.Sign() >= 0 {
+		return fmt.Sprintf("block(number: %s)", o.blockNumber.String()), "block"
+	}
+	return "block", "block"
+}
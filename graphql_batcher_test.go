@@ -0,0 +1,153 @@
+package batchquery
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+)
+
+var graphQLFieldPattern = regexp.MustCompile(`f(\d+): account\(address: "(0x[0-9a-fA-F]+)"\) \{ (balance|code|storage\(slot: "(0x[0-9a-fA-F]+)"\)) \}`)
+
+// A GraphQL endpoint where each account's balance is its last byte, its code is its address, and each storage slot holds its own index
+type mockGraphQLServer struct {
+	// The queries that were received
+	queries []string
+
+	lock sync.Mutex
+}
+
+func (m *mockGraphQLServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var request struct {
+		Query string `json:"query"`
+	}
+	err := json.NewDecoder(r.Body).Decode(&request)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	m.lock.Lock()
+	m.queries = append(m.queries, request.Query)
+	m.lock.Unlock()
+
+	w.Header().Set("Content-Type", "application/json")
+	if strings.Contains(request.Query, "logs(filter") {
+		w.Write([]byte(`{"data":{"logs":[{"index":3,"account":{"address":"0x2222222222222222222222222222222222222222"},"topics":["0x0000000000000000000000000000000000000000000000000000000000000001"],"data":"0x01","transaction":{"hash":"0x0000000000000000000000000000000000000000000000000000000000000002","index":"0x1","block":{"number":"0x10","hash":"0x0000000000000000000000000000000000000000000000000000000000000003"}}}]}}`))
+		return
+	}
+	if strings.Contains(request.Query, "block(number: 404)") {
+		w.Write([]byte(`{"data":{"block":null}}`))
+		return
+	}
+
+	root := "block"
+	if strings.HasPrefix(request.Query, "{ pending") {
+		root = "pending"
+	}
+	fields := []string{}
+	for _, match := range graphQLFieldPattern.FindAllStringSubmatch(request.Query, -1) {
+		address := common.HexToAddress(match[2])
+		var value string
+		switch {
+		case match[3] == "balance":
+			value = fmt.Sprintf(`{"balance":"0x%x"}`, address[19])
+		case match[3] == "code":
+			value = fmt.Sprintf(`{"code":"%s"}`, address.Hex())
+		default:
+			value = fmt.Sprintf(`{"storage":"%s"}`, common.HexToHash(match[4]).Hex())
+		}
+		fields = append(fields, fmt.Sprintf(`"f%s":%s`, match[1], value))
+	}
+	w.Write([]byte(fmt.Sprintf(`{"data":{"%s":{%s}}}`, root, strings.Join(fields, ","))))
+}
+
+func TestGraphQLBatcherAccounts(t *testing.T) {
+	handler := &mockGraphQLServer{}
+	server := httptest.NewServer(handler)
+	defer server.Close()
+	batcher := NewGraphQLBatcher(server.URL, nil, 2, 0)
+
+	addresses := []common.Address{common.HexToAddress("0x01"), common.HexToAddress("0x02"), common.HexToAddress("0x03")}
+	balances, err := batcher.GetEthBalances(addresses, &bind.CallOpts{BlockNumber: big.NewInt(7)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, balance := range balances {
+		if balance.Int64() != int64(i+1) {
+			t.Fatalf("expected balance %d, got %s", i+1, balance)
+		}
+	}
+	if len(handler.queries) != 2 || !strings.HasPrefix(handler.queries[0], "{ block(number: 7) {") {
+		t.Fatalf("expected 2 queries against block 7, got %v", handler.queries)
+	}
+
+	code, err := batcher.GetCode(addresses[:1], &bind.CallOpts{Pending: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if common.BytesToAddress(code[0]) != addresses[0] {
+		t.Fatalf("expected the code of %s, got %x", addresses[0].Hex(), code[0])
+	}
+
+	slots := []StorageSlot{{Address: testTokenAddress, Slot: common.BigToHash(big.NewInt(5))}}
+	values, err := batcher.GetStorage(slots, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if values[0].Big().Int64() != 5 {
+		t.Fatalf("expected slot 5 to hold 5, got %s", values[0].Hex())
+	}
+
+	_, err = batcher.GetEthBalances(addresses, &bind.CallOpts{BlockNumber: big.NewInt(404)})
+	if err == nil || !strings.Contains(err.Error(), "not found") {
+		t.Fatalf("expected a missing block to fail, got %v", err)
+	}
+}
+
+func TestGraphQLBatcherFilterLogs(t *testing.T) {
+	handler := &mockGraphQLServer{}
+	server := httptest.NewServer(handler)
+	defer server.Close()
+	batcher := NewGraphQLBatcher(server.URL, nil, 0, 0)
+
+	var filterer ILogFilterer = batcher
+	logs, err := filterer.FilterLogs(context.Background(), ethereum.FilterQuery{
+		FromBlock: big.NewInt(10),
+		ToBlock:   big.NewInt(20),
+		Addresses: []common.Address{testTokenAddress},
+		Topics:    [][]common.Hash{{common.HexToHash("0x01")}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(logs) != 1 || logs[0].Address != testTokenAddress || logs[0].BlockNumber != 16 || logs[0].TxIndex != 1 || logs[0].Index != 3 {
+		t.Fatalf("expected the decoded log, got %+v", logs)
+	}
+	expectedFilter := `fromBlock: 10, toBlock: 20, addresses: ["` + testTokenAddress.Hex() + `"], topics: [["` + common.HexToHash("0x01").Hex() + `"]]`
+	if !strings.Contains(handler.queries[0], expectedFilter) {
+		t.Fatalf("expected the filter %s, got %s", expectedFilter, handler.queries[0])
+	}
+}
+
+func TestGraphQLBatcherReportsErrors(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"errors":[{"message":"unknown field"}]}`))
+	}))
+	defer server.Close()
+	batcher := NewGraphQLBatcher(server.URL, nil, 0, 0)
+	_, err := batcher.GetEthBalances([]common.Address{testTokenAddress}, nil)
+	if err == nil || !strings.Contains(err.Error(), "unknown field") {
+		t.Fatalf("expected the GraphQL error to be reported, got %v", err)
+	}
+}
@@ -0,0 +1,287 @@
+package batchquery
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+	"sync"
+
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+// HeadFeed subscribes to new block headers and automatically re-runs a set of registered batches on every new block,
+// delivering the fresh results to each batch's handler. This turns one-off queries into a near-real-time feed of chain state.
+type HeadFeed struct {
+	// The client used to get the logs for each new block, which is required for batches registered with log triggers
+	LogFilterer ILogFilterer
+
+	// The MultiCaller whose client and settings are used to run each batch
+	caller *MultiCaller
+
+	// The client used to subscribe to new headers
+	subscriber IHeadSubscriber
+
+	// The registered batches, keyed by their registration ID
+	entries map[uint64]*feedEntry
+
+	// The ID to assign to the next registered batch
+	nextID uint64
+
+	// Lock for the registered batches
+	lock sync.Mutex
+}
+
+// A batch registered with a HeadFeed
+type feedEntry struct {
+	// The batch to run on each block
+	batch *Batch
+
+	// Whether or not every call in the batch must succeed
+	requireSuccess bool
+
+	// The function to deliver the results to
+	handler func(FeedUpdate)
+
+	// The logs that cause the batch to be re-run; if empty, the batch is re-run on every block
+	triggers []LogTrigger
+
+	// The number of the last block the batch ran at successfully (nil = it hasn't yet)
+	lastBlock *big.Int
+}
+
+// The results of running a registered batch on a new block
+type FeedUpdate struct {
+	// The header of the block the batch was run at
+	Header *types.Header
+
+	// Whether or not each call worked; successful calls have already been unpacked into their outputs
+	Successes []bool
+
+	// The raw response of each call
+	Responses []CallResponse
+
+	// The error from running the batch, if there was one
+	Err error
+}
+
+// Creates a new HeadFeed that runs batches using the settings and client of the provided MultiCaller
+func NewHeadFeed(caller *MultiCaller, subscriber IHeadSubscriber) *HeadFeed {
+	return &HeadFeed{
+		caller:     caller,
+		subscriber: subscriber,
+		entries:    map[uint64]*feedEntry{},
+	}
+}
+
+// Registers a batch to run on every new block.
+// Before the handler is called, the responses of the successful calls are unpacked into the outputs the batch's calls were created with,
+// so the handler can read them directly. The handler for a batch is never invoked concurrently with itself.
+// Returns a function that unregisters the batch.
+func (f *HeadFeed) Register(batch *Batch, requireSuccess bool, handler func(FeedUpdate)) func() {
+	return f.RegisterWithTriggers(batch, requireSuccess, nil, handler)
+}
+
+// Registers a batch that runs on the first new block, and is then only re-run once a log matching one of the triggers
+// has been emitted since its last successful run, so values that rarely change aren't re-queried needlessly.
+// This requires the feed's LogFilterer to be set. Logs from blocks the feed skipped are included, so no trigger is missed.
+// The batch is re-run on every block until its first successful run. The logs are only queried for the registered triggers;
+// if they can't be retrieved, the batch isn't run and its handler receives an update with the error, and the logs are checked again on the next block.
+// Otherwise this behaves the same as Register().
+func (f *HeadFeed) RegisterWithTriggers(batch *Batch, requireSuccess bool, triggers []LogTrigger, handler func(FeedUpdate)) func() {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	id := f.nextID
+	f.nextID++
+	f.entries[id] = &feedEntry{
+		batch:          batch,
+		requireSuccess: requireSuccess,
+		handler:        handler,
+		triggers:       triggers,
+	}
+	return func() {
+		f.lock.Lock()
+		defer f.lock.Unlock()
+		delete(f.entries, id)
+	}
+}
+
+// Subscribes to new headers and runs the registered batches on each new block until the context is cancelled, the subscription fails,
+// or the LatestCache can't be invalidated.
+// If new blocks arrive while the batches are still running for a previous one, the intermediate blocks are skipped in favor of the latest;
+// batches with log triggers still see the logs from the skipped blocks.
+// If the MultiCaller has a LatestCache, each new head is reported to it before the batches run, which invalidates the cached results for the previous head.
+func (f *HeadFeed) Run(ctx context.Context) error {
+	return followHeads(ctx, f.subscriber, f.caller.LatestCache, nil, func(header *types.Header) {
+		f.runAll(ctx, header)
+	})
+}
+
+// Subscribes to new headers and calls onHead with each new one until the context is cancelled, the subscription fails,
+// or the latest cache can't be invalidated (serving results for an old head as the latest would be wrong).
+// onSubscribed, if provided, is called once the subscription is in place. If new headers arrive while onHead is still running
+// for a previous one, the intermediate headers are skipped in favor of the latest. If latestCache is provided, each new head
+// is reported to it before onHead is called.
+func followHeads(ctx context.Context, subscriber IHeadSubscriber, latestCache *LatestCachePolicy, onSubscribed func(), onHead func(*types.Header)) error {
+	headers := make(chan *types.Header, 16)
+	sub, err := subscriber.SubscribeNewHead(ctx, headers)
+	if err != nil {
+		return fmt.Errorf("error subscribing to new headers: %w", wrapClientError(err))
+	}
+	defer sub.Unsubscribe()
+
+	if onSubscribed != nil {
+		onSubscribed()
+	}
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case err := <-sub.Err():
+			return fmt.Errorf("error in new header subscription: %w", wrapClientError(err))
+		case header := <-headers:
+			// Skip to the latest header if several arrived at once
+			for drained := false; !drained; {
+				select {
+				case next := <-headers:
+					header = next
+				default:
+					drained = true
+				}
+			}
+			if latestCache != nil {
+				err := latestCache.ObserveHead(header.Number)
+				if err != nil {
+					return err
+				}
+			}
+			onHead(header)
+		}
+	}
+}
+
+// Runs all of the registered batches at the provided block
+func (f *HeadFeed) runAll(ctx context.Context, header *types.Header) {
+	f.lock.Lock()
+	entries := make([]*feedEntry, 0, len(f.entries))
+	for _, entry := range f.entries {
+		entries = append(entries, entry)
+	}
+	f.lock.Unlock()
+
+	// Only run the batches with triggers if they haven't run yet, or if one of their triggers was hit in this block
+	entries, skipped, err := f.filterTriggered(ctx, header, entries)
+	for _, entry := range skipped {
+		entry.handler(FeedUpdate{
+			Header: header,
+			Err:    fmt.Errorf("error checking the batch's triggers: %w", err),
+		})
+	}
+
+	opts := &bind.CallOpts{
+		BlockNumber: header.Number,
+		Context:     ctx,
+	}
+	var wg sync.WaitGroup
+	for _, entry := range entries {
+		wg.Add(1)
+		go func(entry *feedEntry) {
+			defer wg.Done()
+			update := entry.run(f.caller, header, opts)
+			if update.Err == nil {
+				entry.lastBlock = header.Number
+			}
+			entry.handler(update)
+		}(entry)
+	}
+	wg.Wait()
+}
+
+// Filters a list of entries down to the ones that should run on the provided block, based on their triggers.
+// If the logs can't be retrieved, the entries that depend on them are returned separately with the error instead of being run;
+// their last run doesn't advance, so the logs they missed are checked again on the next block.
+func (f *HeadFeed) filterTriggered(ctx context.Context, header *types.Header, entries []*feedEntry) ([]*feedEntry, []*feedEntry, error) {
+	// Get the logs since the oldest successful run of any triggered batch, only for the triggers those batches use.
+	// Entries that already ran at this height or above also run, since that means the chain reorged.
+	var fromBlock *big.Int
+	triggers := []LogTrigger{}
+	for _, entry := range entries {
+		if len(entry.triggers) == 0 || entry.lastBlock == nil || entry.lastBlock.Cmp(header.Number) >= 0 {
+			continue
+		}
+		if fromBlock == nil || entry.lastBlock.Cmp(fromBlock) < 0 {
+			fromBlock = entry.lastBlock
+		}
+		triggers = append(triggers, entry.triggers...)
+	}
+	var logs []types.Log
+	var err error
+	if fromBlock != nil {
+		logs, err = f.getLogs(ctx, new(big.Int).Add(fromBlock, big.NewInt(1)), header.Number, triggers)
+	}
+
+	filtered := make([]*feedEntry, 0, len(entries))
+	skipped := []*feedEntry{}
+	for _, entry := range entries {
+		if len(entry.triggers) == 0 || entry.lastBlock == nil || entry.lastBlock.Cmp(header.Number) >= 0 {
+			filtered = append(filtered, entry)
+			continue
+		}
+		if err != nil {
+			skipped = append(skipped, entry)
+			continue
+		}
+		if logsMatchTriggersAfter(logs, entry.triggers, entry.lastBlock) {
+			filtered = append(filtered, entry)
+		}
+	}
+	return filtered, skipped, err
+}
+
+// Gets the logs emitted in the provided range of blocks, inclusive, that match any of the triggers.
+// Each distinct trigger is its own query, since merging their positional topics into one filter would match far more logs than they do.
+func (f *HeadFeed) getLogs(ctx context.Context, fromBlock *big.Int, toBlock *big.Int, triggers []LogTrigger) ([]types.Log, error) {
+	if f.LogFilterer == nil {
+		return nil, fmt.Errorf("no log filterer was provided")
+	}
+	logs := []types.Log{}
+	queried := map[string]bool{}
+	for _, trigger := range triggers {
+		key := trigger.key()
+		if queried[key] {
+			continue
+		}
+		queried[key] = true
+		triggerLogs, err := f.LogFilterer.FilterLogs(ctx, trigger.filterQuery(fromBlock, toBlock))
+		if err != nil {
+			return nil, fmt.Errorf("error getting logs for blocks %s to %s: %w", fromBlock.String(), toBlock.String(), wrapClientError(err))
+		}
+		logs = append(logs, triggerLogs...)
+	}
+	return logs, nil
+}
+
+// Runs the entry's batch and unpacks the results into its outputs
+func (e *feedEntry) run(caller *MultiCaller, header *types.Header, opts *bind.CallOpts) FeedUpdate {
+	update := FeedUpdate{
+		Header: header,
+	}
+	responses, err := e.batch.ExecuteRaw(caller, e.requireSuccess, opts)
+	if err != nil {
+		update.Err = err
+		return update
+	}
+
+	update.Responses = responses
+	update.Successes = make([]bool, len(responses))
+	for i, call := range e.batch.calls {
+		err := call.unpackResponse(responses[i])
+		if err != nil {
+			update.Err = err
+			return update
+		}
+		update.Successes[i] = responses[i].Status
+	}
+	return update
+}
@@ -0,0 +1,248 @@
+package batchquery
+
+import (
+	"context"
+	"errors"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/event"
+)
+
+// A log filterer that serves canned logs and records the queries
+type mockLogFilterer struct {
+	logs    []types.Log
+	queries []ethereum.FilterQuery
+	err     error
+}
+
+func (m *mockLogFilterer) FilterLogs(ctx context.Context, query ethereum.FilterQuery) ([]types.Log, error) {
+	m.queries = append(m.queries, query)
+	if m.err != nil {
+		return nil, m.err
+	}
+	filter := LogTrigger{Addresses: query.Addresses, Topics: query.Topics}
+	logs := []types.Log{}
+	for _, log := range m.logs {
+		if log.BlockNumber >= query.FromBlock.Uint64() && log.BlockNumber <= query.ToBlock.Uint64() && filter.Matches(&log) {
+			logs = append(logs, log)
+		}
+	}
+	return logs, nil
+}
+
+func TestHeadFeedTriggersIncludeSkippedBlocks(t *testing.T) {
+	mc, _ := newTestMultiCaller(t)
+	account := common.HexToAddress("0x0102")
+	filterer := &mockLogFilterer{}
+	feed := NewHeadFeed(mc, nil)
+	feed.LogFilterer = filterer
+
+	var balance *big.Int
+	builder := mc.Clone()
+	builder.AddCall(testTokenAddress, &testTokenAbi, &balance, "balanceOf", account)
+	batch, err := builder.Snapshot()
+	if err != nil {
+		t.Fatal(err)
+	}
+	runs := 0
+	feed.RegisterWithTriggers(batch, false, NewTransferTriggers(testTokenAddress, account), func(update FeedUpdate) {
+		if update.Err != nil {
+			t.Errorf("unexpected error: %v", update.Err)
+		}
+		runs++
+	})
+
+	// The first block always runs the batch
+	feed.runAll(context.Background(), &types.Header{Number: big.NewInt(1)})
+	if runs != 1 || balance.Cmp(expectedBalance(account, 1)) != 0 {
+		t.Fatalf("expected the first run at block 1, got %d runs and balance %s", runs, balance)
+	}
+
+	// A transfer in block 2 that the feed skipped must still trigger a run at block 3
+	filterer.logs = []types.Log{{
+		Address:     testTokenAddress,
+		Topics:      []common.Hash{transferEventTopic, common.BytesToHash(account.Bytes()), {}},
+		BlockNumber: 2,
+	}}
+	feed.runAll(context.Background(), &types.Header{Number: big.NewInt(3)})
+	if runs != 2 || balance.Cmp(expectedBalance(account, 3)) != 0 {
+		t.Fatalf("expected a triggered run at block 3, got %d runs and balance %s", runs, balance)
+	}
+	// Each of the batch's triggers is queried on its own, for just its contract and topics
+	if len(filterer.queries) != 2 {
+		t.Fatalf("expected a query for each trigger, got %d queries", len(filterer.queries))
+	}
+	accountTopic := common.BytesToHash(account.Bytes())
+	for i, query := range filterer.queries {
+		if query.FromBlock.Int64() != 2 || query.ToBlock.Int64() != 3 {
+			t.Fatalf("expected logs to be queried for blocks 2 to 3, got %s to %s", query.FromBlock, query.ToBlock)
+		}
+		if len(query.Addresses) != 1 || query.Addresses[0] != testTokenAddress || query.Topics[0][0] != transferEventTopic || query.Topics[i+1][0] != accountTopic {
+			t.Fatalf("expected the query to filter on the trigger, got %+v", query)
+		}
+	}
+
+	// Without new logs, the batch doesn't run again
+	feed.runAll(context.Background(), &types.Header{Number: big.NewInt(4)})
+	if runs != 2 {
+		t.Fatalf("expected no run at block 4, got %d runs", runs)
+	}
+}
+
+func TestHeadFeedRetriesFailedFirstRun(t *testing.T) {
+	mc, client := newTestMultiCaller(t)
+	feed := NewHeadFeed(mc, nil)
+	feed.LogFilterer = &mockLogFilterer{}
+
+	var balance *big.Int
+	builder := mc.Clone()
+	builder.AddCall(testTokenAddress, &testTokenAbi, &balance, "balanceOf", common.HexToAddress("0x01"))
+	batch, err := builder.Snapshot()
+	if err != nil {
+		t.Fatal(err)
+	}
+	errs := 0
+	successes := 0
+	feed.RegisterWithTriggers(batch, false, []LogTrigger{{Addresses: []common.Address{testTokenAddress}}}, func(update FeedUpdate) {
+		if update.Err != nil {
+			errs++
+		} else {
+			successes++
+		}
+	})
+
+	client.err = errors.New("node is down")
+	feed.runAll(context.Background(), &types.Header{Number: big.NewInt(1)})
+	client.err = nil
+	feed.runAll(context.Background(), &types.Header{Number: big.NewInt(2)})
+	if errs != 1 || successes != 1 {
+		t.Fatalf("expected the batch to be re-run after its first run failed, got %d errors and %d successes", errs, successes)
+	}
+}
+
+func TestHeadFeedSkipsTriggeredBatchesWithoutLogs(t *testing.T) {
+	mc, _ := newTestMultiCaller(t)
+	account := common.HexToAddress("0x0102")
+	filterer := &mockLogFilterer{}
+	feed := NewHeadFeed(mc, nil)
+	feed.LogFilterer = filterer
+
+	builder := mc.Clone()
+	builder.AddCall(testTokenAddress, &testTokenAbi, new(*big.Int), "balanceOf", account)
+	batch, err := builder.Snapshot()
+	if err != nil {
+		t.Fatal(err)
+	}
+	updates := []FeedUpdate{}
+	feed.RegisterWithTriggers(batch, false, NewTransferTriggers(testTokenAddress, account), func(update FeedUpdate) {
+		updates = append(updates, update)
+	})
+	feed.runAll(context.Background(), &types.Header{Number: big.NewInt(1)})
+
+	// A failed log query is reported to the handler instead of running the batch
+	filterer.err = errors.New("query returned more than 10000 results")
+	feed.runAll(context.Background(), &types.Header{Number: big.NewInt(2)})
+	if len(updates) != 2 || updates[1].Err == nil || updates[1].Responses != nil {
+		t.Fatalf("expected the log failure to be reported without running the batch, got %+v", updates)
+	}
+
+	// The blocks it missed are checked once the logs can be retrieved again
+	filterer.err = nil
+	filterer.logs = []types.Log{{
+		Address:     testTokenAddress,
+		Topics:      []common.Hash{transferEventTopic, {}, common.BytesToHash(account.Bytes())},
+		BlockNumber: 2,
+	}}
+	filterer.queries = nil
+	feed.runAll(context.Background(), &types.Header{Number: big.NewInt(3)})
+	if len(updates) != 3 || updates[2].Err != nil || updates[2].Responses == nil {
+		t.Fatalf("expected the missed transfer to trigger a run, got %+v", updates)
+	}
+	if filterer.queries[0].FromBlock.Int64() != 2 {
+		t.Fatalf("expected the logs to be queried from block 2, got %s", filterer.queries[0].FromBlock)
+	}
+}
+
+// A subscriber that delivers a fixed list of headers
+type mockHeadSubscriber struct {
+	headers []*types.Header
+}
+
+func (m *mockHeadSubscriber) SubscribeNewHead(ctx context.Context, ch chan<- *types.Header) (ethereum.Subscription, error) {
+	return event.NewSubscription(func(quit <-chan struct{}) error {
+		for _, header := range m.headers {
+			select {
+			case ch <- header:
+			case <-quit:
+				return nil
+			}
+		}
+		<-quit
+		return nil
+	}), nil
+}
+
+func TestHeadFeedInvalidatesLatestCache(t *testing.T) {
+	mc, _ := newTestMultiCaller(t)
+	cache := NewMemoryCache(100, 0)
+	mc.Cache = cache
+	mc.LatestCache = NewLatestCachePolicy(cache)
+	cache.Set(CacheKey{Target: testTokenAddress, Latest: true}, CallResponse{Status: true})
+	subscriber := &mockHeadSubscriber{headers: []*types.Header{{Number: big.NewInt(7)}}}
+	feed := NewHeadFeed(mc, subscriber)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	builder := mc.Clone()
+	builder.AddBlockNumber(new(*big.Int))
+	batch, err := builder.Snapshot()
+	if err != nil {
+		t.Fatal(err)
+	}
+	feed.Register(batch, true, func(update FeedUpdate) {
+		cancel()
+	})
+	feed.Run(ctx)
+
+	if mc.LatestCache.Head().Int64() != 7 {
+		t.Fatalf("expected the feed to report head 7, got %s", mc.LatestCache.Head())
+	}
+	_, found, _ := cache.Get(CacheKey{Target: testTokenAddress, Latest: true})
+	if found {
+		t.Fatal("expected the latest entry to be invalidated by the new head")
+	}
+}
+
+// A cache whose latest entries can't be invalidated
+type failingLatestCache struct {
+	*MemoryCache
+}
+
+func (c failingLatestCache) InvalidateLatest() error {
+	return errors.New("disk full")
+}
+
+func TestHeadRunnersStopWhenLatestCacheFails(t *testing.T) {
+	mc, _ := newTestMultiCaller(t)
+	cache := failingLatestCache{NewMemoryCache(100, 0)}
+	mc.Cache = cache
+	mc.LatestCache = NewLatestCachePolicy(cache)
+
+	// Serving the previous head's results as the latest would be wrong, so the feed and the watcher both stop
+	runners := map[string]func(ctx context.Context) error{
+		"feed":    NewHeadFeed(mc, &mockHeadSubscriber{headers: []*types.Header{{Number: big.NewInt(7)}}}).Run,
+		"watcher": NewHeadWatcher(mc, &mockHeadSubscriber{headers: []*types.Header{{Number: big.NewInt(8)}}}).Run,
+	}
+	for name, run := range runners {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		err := run(ctx)
+		cancel()
+		if err == nil || errors.Is(err, context.DeadlineExceeded) {
+			t.Fatalf("expected the %s to stop when the cache can't be invalidated, got %v", name, err)
+		}
+	}
+}
@@ -0,0 +1,88 @@
+package batchquery
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// The result of a successful health check
+type HealthReport struct {
+	// The address of the helper contract that was checked
+	ContractAddress common.Address
+
+	// Whether the helper contract's code was checked, which requires the client to implement ICodeReader
+	CodeChecked bool
+
+	// The latest block number, as reported by the trivial call
+	BlockNumber *big.Int
+
+	// How long the trivial call took
+	Latency time.Duration
+}
+
+// Checks that the MultiCaller can serve queries: the multicall contract must exist, and a trivial call to its getBlockNumber function must succeed.
+// The returned report includes the call's latency, so services can gate readiness probes on the query layer or alert when it slows down.
+// If the client implements ICodeReader, the contract's code is checked first so a missing contract is reported clearly.
+func (mc *MultiCaller) HealthCheck(ctx context.Context) (*HealthReport, error) {
+	report := &HealthReport{
+		ContractAddress: mc.contractAddress,
+	}
+	codeChecked, err := checkContractExists(ctx, mc.client, mc.contractAddress)
+	if err != nil {
+		return nil, fmt.Errorf("multicall contract health check failed: %w", err)
+	}
+	report.CodeChecked = codeChecked
+
+	start := time.Now()
+	blockNumber, err := mc.getLatestBlockNumber(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("multicall contract health check failed: %w", err)
+	}
+	report.Latency = time.Since(start)
+	report.BlockNumber = blockNumber
+	return report, nil
+}
+
+// Checks that the BalanceBatcher can serve queries: the balance checker contract must exist, and a query for a single balance must succeed.
+// The returned report includes the query's latency, so services can gate readiness probes on the query layer or alert when it slows down.
+// If the client implements ICodeReader, the contract's code is checked first so a missing contract is reported clearly.
+// The report's BlockNumber is nil, since the balance checker doesn't report one.
+func (b *BalanceBatcher) HealthCheck(ctx context.Context) (*HealthReport, error) {
+	report := &HealthReport{
+		ContractAddress: b.contractAddress,
+	}
+	codeChecked, err := checkContractExists(ctx, b.client, b.contractAddress)
+	if err != nil {
+		return nil, fmt.Errorf("balance checker contract health check failed: %w", err)
+	}
+	report.CodeChecked = codeChecked
+
+	start := time.Now()
+	_, err = b.getEthBalances([]common.Address{{}}, &callOptions{ctx: ctx})
+	if err != nil {
+		return nil, fmt.Errorf("balance checker contract health check failed: %w", err)
+	}
+	report.Latency = time.Since(start)
+	return report, nil
+}
+
+// Checks that there's code at a helper contract's address, if the client can read code.
+// Returns whether the code was checked; if it can't be, the trivial call that follows reveals a missing contract instead.
+func checkContractExists(ctx context.Context, client IContractCaller, address common.Address) (bool, error) {
+	reader, ok := client.(ICodeReader)
+	if !ok {
+		return false, nil
+	}
+	code, err := reader.CodeAt(ctx, address, nil)
+	if err != nil {
+		return false, fmt.Errorf("error getting code of contract %s: %w", address.Hex(), wrapClientError(err))
+	}
+	if len(code) == 0 {
+		return false, fmt.Errorf("no contract is deployed at %s: %w", address.Hex(), ErrUnexpectedCode)
+	}
+	return true, nil
+}
@@ -0,0 +1,53 @@
+package batchquery
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+func TestMultiCallerHealthCheck(t *testing.T) {
+	mc, client := newTestMultiCaller(t)
+	_, err := mc.HealthCheck(context.Background())
+	if !errors.Is(err, ErrUnexpectedCode) {
+		t.Fatalf("expected a missing multicall contract to fail the health check, got %v", err)
+	}
+
+	client.code = map[common.Address][]byte{testMulticallAddress: {0x60}}
+	report, err := mc.HealthCheck(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !report.CodeChecked || report.BlockNumber.Int64() != 100 || report.ContractAddress != testMulticallAddress {
+		t.Fatalf("unexpected health report %+v", report)
+	}
+
+	client.err = errors.New("connection refused")
+	_, err = mc.HealthCheck(context.Background())
+	if err == nil {
+		t.Fatal("expected a failing client to fail the health check")
+	}
+}
+
+func TestBalanceBatcherHealthCheck(t *testing.T) {
+	client := &mockBalanceCheckerClient{}
+	batcher, err := NewBalanceBatcher(client, testTokenAddress, 10, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = batcher.HealthCheck(context.Background())
+	if !errors.Is(err, ErrUnexpectedCode) {
+		t.Fatalf("expected a missing balance checker to fail the health check, got %v", err)
+	}
+
+	client.code = map[common.Address][]byte{testTokenAddress: {0x60}}
+	report, err := batcher.HealthCheck(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !report.CodeChecked || report.BlockNumber != nil {
+		t.Fatalf("unexpected health report %+v", report)
+	}
+}
@@ -0,0 +1,79 @@
+package batchquery
+
+import (
+	"context"
+	"time"
+
+	"github.com/ethereum/go-ethereum"
+)
+
+// The response to a chunk's request from one of the clients it was sent to
+type hedgedResponse struct {
+	// The raw response
+	data []byte
+
+	// The error the request failed with (nil = success)
+	err error
+}
+
+// Sends a chunk's request to the multicall contract through the client, and also through the HedgeClient if the client hasn't responded
+// within HedgeDelay or failed with anything but a revert. The first successful response is returned and the other request is cancelled;
+// if both fail, the first error is returned.
+func (mc *MultiCaller) callChunkContract(ctx context.Context, opts *callOptions, msg ethereum.CallMsg, callCount int) ([]byte, error) {
+	if mc.HedgeClient == nil {
+		return opts.callContract(ctx, mc.client, msg)
+	}
+
+	// Cancels the slower request once a response is chosen
+	hedgeCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	responses := make(chan hedgedResponse, 2)
+	send := func(client IContractCaller) {
+		data, err := opts.callContract(hedgeCtx, client, msg)
+		responses <- hedgedResponse{
+			data: data,
+			err:  err,
+		}
+	}
+	go send(mc.client)
+	pending := 1
+	hedged := false
+	hedge := func() {
+		hedged = true
+		pending++
+		if mc.Hooks != nil && mc.Hooks.OnChunkHedged != nil {
+			mc.Hooks.OnChunkHedged(ctx, callCount)
+		}
+		go send(mc.HedgeClient)
+	}
+
+	timer := time.NewTimer(mc.HedgeDelay)
+	defer timer.Stop()
+	var firstErr error
+	for {
+		select {
+		case <-timer.C:
+			if !hedged {
+				hedge()
+			}
+		case response := <-responses:
+			pending--
+			if response.err == nil {
+				return response.data, nil
+			}
+
+			// Reverts are the contract's answer rather than a problem with the endpoint, so they aren't hedged
+			if _, isRevert := getRevertData(response.err); isRevert || ctx.Err() != nil {
+				return nil, response.err
+			}
+			if firstErr == nil {
+				firstErr = response.err
+			}
+			if !hedged {
+				hedge()
+			} else if pending == 0 {
+				return nil, firstErr
+			}
+		}
+	}
+}
@@ -0,0 +1,103 @@
+package batchquery
+
+import (
+	"context"
+	"errors"
+	"math/big"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// Creates a MultiCaller whose requests are hedged to a second mock client, with a balance call added to it
+func newHedgedMultiCaller(t *testing.T, delay time.Duration) (*MultiCaller, *mockClient, *mockClient, **big.Int) {
+	mc, client := newTestMultiCaller(t)
+	hedgeClient := &mockClient{}
+	mc.HedgeClient = hedgeClient
+	mc.HedgeDelay = delay
+	var balance *big.Int
+	mc.AddCall(testTokenAddress, &testTokenAbi, &balance, "balanceOf", common.HexToAddress("0x0102"))
+	return mc, client, hedgeClient, &balance
+}
+
+func TestHedgedChunkUsesFasterClient(t *testing.T) {
+	mc, client, hedgeClient, balance := newHedgedMultiCaller(t, 10*time.Millisecond)
+	var cancelled atomic.Bool
+	client.hook = func(ctx context.Context, msg ethereum.CallMsg) error {
+		<-ctx.Done()
+		cancelled.Store(true)
+		return ctx.Err()
+	}
+	var hedges atomic.Int32
+	mc.Hooks = &Hooks{
+		OnChunkHedged: func(ctx context.Context, callCount int) {
+			hedges.Add(1)
+		},
+	}
+
+	_, err := mc.FlexibleCall(true, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if (*balance).Cmp(expectedBalance(common.HexToAddress("0x0102"), 0)) != 0 {
+		t.Fatalf("unexpected balance %s", *balance)
+	}
+	if hedges.Load() != 1 || len(hedgeClient.getChunkSizes()) != 1 {
+		t.Fatal("expected the chunk to be hedged once")
+	}
+
+	// The slow request is cancelled once the hedge wins
+	deadline := time.Now().Add(time.Second)
+	for !cancelled.Load() && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	if !cancelled.Load() {
+		t.Fatal("expected the slower request to be cancelled")
+	}
+}
+
+func TestHedgingOnlyWhenNeeded(t *testing.T) {
+	// Fast responses aren't hedged
+	mc, _, hedgeClient, _ := newHedgedMultiCaller(t, time.Hour)
+	_, err := mc.FlexibleCall(true, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if hedgeClient.calls != 0 {
+		t.Fatal("expected a fast response not to be hedged")
+	}
+
+	// Reverts are the contract's answer, so they aren't hedged either
+	mc, _, hedgeClient, _ = newHedgedMultiCaller(t, time.Hour)
+	mc.AddCall(testTokenAddress, &testTokenAbi, new(*big.Int), "boom")
+	_, err = mc.FlexibleCall(true, nil)
+	var reverted *ErrCallReverted
+	if !errors.As(err, &reverted) || hedgeClient.calls != 0 {
+		t.Fatalf("expected the revert to be returned without hedging, got %v", err)
+	}
+}
+
+func TestHedgingAfterFailure(t *testing.T) {
+	// A failed request is hedged right away instead of waiting for the delay
+	mc, client, hedgeClient, _ := newHedgedMultiCaller(t, time.Hour)
+	client.err = errors.New("connection reset")
+	_, err := mc.FlexibleCall(true, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if hedgeClient.calls != 1 {
+		t.Fatal("expected the failed request to be hedged")
+	}
+
+	// If both clients fail, the batch fails
+	mc, client, hedgeClient, _ = newHedgedMultiCaller(t, time.Hour)
+	client.err = errors.New("connection reset")
+	hedgeClient.err = errors.New("service unavailable")
+	_, err = mc.FlexibleCall(true, nil)
+	if !errors.Is(err, ErrClientFailure) {
+		t.Fatalf("expected a client failure, got %v", err)
+	}
+}
@@ -0,0 +1,141 @@
+package batchquery
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// Describes how a call's response can be re-derived from proven state
+type proofHint struct {
+	// The account whose state the response comes from
+	account common.Address
+
+	// The storage slot of the account that holds the response (nil = the response is the account's ETH balance)
+	slot *common.Hash
+}
+
+// Marks the call as returning the raw 32-byte value stored in the provided storage slot of its target contract,
+// such as a public state variable's getter. When the MultiCaller has a Verifier, the response is checked against a storage proof.
+func (c *Call) WithStorageSlot(slot common.Hash) *Call {
+	c.proofHint = &proofHint{
+		account: c.Target,
+		slot:    &slot,
+	}
+	return c
+}
+
+// Marks the call as returning the ETH balance of the provided account as a uint256, such as Multicall's getEthBalance.
+// When the MultiCaller has a Verifier, the response is checked against an account proof.
+func (c *Call) WithBalanceOf(account common.Address) *Call {
+	c.proofHint = &proofHint{
+		account: account,
+	}
+	return c
+}
+
+// LightClientVerifier cross-checks multicall responses against Merkle proofs of the state they were read from,
+// so consumers connected to third-party RPC providers can detect tampered responses.
+// Only calls marked with WithStorageSlot or WithBalanceOf are verified; the rest are trusted as-is.
+// The state root for each batch comes from a trusted header source such as a light client, so the RPC provider can't forge it.
+type LightClientVerifier struct {
+	// The batcher used to fetch and verify the proofs
+	Proofs *ProofBatcher
+
+	// The trusted source of block headers
+	headers ITrustedHeaderReader
+}
+
+// Creates a new LightClientVerifier instance
+func NewLightClientVerifier(proofs *ProofBatcher, headers ITrustedHeaderReader) *LightClientVerifier {
+	return &LightClientVerifier{
+		Proofs:  proofs,
+		headers: headers,
+	}
+}
+
+// Gets the number and state root of the block targeted by the options from the trusted header source.
+// If the options target the latest block, they are pinned to the trusted head so the batch and the proofs read the same state.
+func (v *LightClientVerifier) pinBlock(opts *callOptions) (*big.Int, common.Hash, error) {
+	if opts.pending {
+		return nil, common.Hash{}, fmt.Errorf("responses from the pending block can't be verified")
+	}
+	if opts.blockHash != nil {
+		header, err := v.headers.HeaderByHash(opts.ctx, *opts.blockHash)
+		if err != nil {
+			return nil, common.Hash{}, fmt.Errorf("error getting trusted header for block %s: %w", opts.blockHash.Hex(), wrapClientError(err))
+		}
+		return header.Number, header.Root, nil
+	}
+	header, err := v.headers.HeaderByNumber(opts.ctx, opts.blockNumber)
+	if err != nil {
+		return nil, common.Hash{}, fmt.Errorf("error getting trusted header for block %s: %w", opts.blockDescription(), wrapClientError(err))
+	}
+	opts.blockNumber = header.Number
+	return header.Number, header.Root, nil
+}
+
+// Verifies the responses of any calls with proof hints against the provided state root.
+// Returns an error describing the first mismatch if any of the responses don't match the proven state.
+func (v *LightClientVerifier) verifyResponses(ctx context.Context, calls []*Call, responses []CallResponse, blockNumber *big.Int, stateRoot common.Hash) error {
+	// Group the hinted slots by account so each account only needs one proof
+	requestIndices := map[common.Address]int{}
+	requests := []ProofRequest{}
+	for _, call := range calls {
+		hint := call.proofHint
+		if hint == nil {
+			continue
+		}
+		index, exists := requestIndices[hint.account]
+		if !exists {
+			index = len(requests)
+			requestIndices[hint.account] = index
+			requests = append(requests, ProofRequest{Address: hint.account})
+		}
+		if hint.slot != nil {
+			requests[index].Slots = append(requests[index].Slots, *hint.slot)
+		}
+	}
+	if len(requests) == 0 {
+		return nil
+	}
+
+	// Get the proven state
+	accounts, err := v.Proofs.GetVerifiedAccounts(requests, stateRoot, &bind.CallOpts{
+		Context:     ctx,
+		BlockNumber: blockNumber,
+	})
+	if err != nil {
+		return fmt.Errorf("error getting proofs for verification: %w", err)
+	}
+
+	// Compare each hinted response against its proven value
+	slotCounts := make([]int, len(requests))
+	for i, call := range calls {
+		hint := call.proofHint
+		if hint == nil {
+			continue
+		}
+		index := requestIndices[hint.account]
+		account := accounts[index]
+		var expected *big.Int
+		if hint.slot == nil {
+			expected = account.Balance
+		} else {
+			expected = account.Storage[slotCounts[index]]
+			slotCounts[index]++
+		}
+
+		response := responses[i]
+		if !response.Status {
+			return fmt.Errorf("call %d (contract %s, method %s) was reported as failed but has a verifiable value", i, call.Target.Hex(), call.Method)
+		}
+		if len(response.ReturnData) != wordSize || new(big.Int).SetBytes(response.ReturnData).Cmp(expected) != 0 {
+			return fmt.Errorf("response for call %d (contract %s, method %s) does not match the proven state at block %s", i, call.Target.Hex(), call.Method, blockNumber.String())
+		}
+	}
+	return nil
+}
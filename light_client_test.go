@@ -0,0 +1,94 @@
+package batchquery
+
+import (
+	"context"
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+// A trusted header source with a single block
+type mockHeaderReader struct {
+	header *types.Header
+}
+
+func (m *mockHeaderReader) HeaderByNumber(ctx context.Context, number *big.Int) (*types.Header, error) {
+	return m.header, nil
+}
+
+func (m *mockHeaderReader) HeaderByHash(ctx context.Context, hash common.Hash) (*types.Header, error) {
+	return m.header, nil
+}
+
+// Creates a verifier whose state has the token contract with the provided value in slot 0
+func newTestVerifier(t *testing.T, slotValue *big.Int) *LightClientVerifier {
+	slot := common.Hash{}
+	storageLeaf := leafNode(t, keyToNibbles(crypto.Keccak256(slot.Bytes())), storageValue(t, slotValue.Int64()))
+	storageRoot := crypto.Keccak256Hash(storageLeaf)
+	accountLeaf := leafNode(t, keyToNibbles(crypto.Keccak256(testTokenAddress.Bytes())), accountValue(t, 0, storageRoot))
+	stateRoot := crypto.Keccak256Hash(accountLeaf)
+
+	getter := &mockProofGetter{proofs: map[common.Address]*AccountProof{
+		testTokenAddress: {
+			Address:      testTokenAddress,
+			AccountProof: encodeProof(accountLeaf),
+			Balance:      big.NewInt(0),
+			CodeHash:     types.EmptyCodeHash,
+			Nonce:        1,
+			StorageHash:  storageRoot,
+			StorageProof: []StorageProof{{
+				Key:   "0x0",
+				Value: slotValue,
+				Proof: encodeProof(storageLeaf),
+			}},
+		},
+	}}
+	headers := &mockHeaderReader{header: &types.Header{Number: big.NewInt(5), Root: stateRoot}}
+	return NewLightClientVerifier(NewProofBatcher(getter, 1), headers)
+}
+
+func TestLightClientVerifierOnEveryPath(t *testing.T) {
+	account := common.HexToAddress("0x0102")
+	for _, tampered := range []bool{false, true} {
+		slotValue := expectedBalance(account, 5)
+		if tampered {
+			slotValue = new(big.Int).Add(slotValue, big.NewInt(1))
+		}
+		mc, _ := newTestMultiCaller(t)
+		mc.Verifier = newTestVerifier(t, slotValue)
+
+		// FlexibleCall
+		var balance *big.Int
+		mc.AddCall(testTokenAddress, &testTokenAbi, &balance, "balanceOf", account).WithStorageSlot(common.Hash{})
+		_, err := mc.FlexibleCall(true, nil)
+		if tampered != (err != nil) {
+			t.Fatalf("FlexibleCall with tampered=%v returned error %v", tampered, err)
+		}
+
+		// StreamCall
+		streamer := mc.withCalls([]*Call{})
+		streamer.AddCall(testTokenAddress, &testTokenAbi, &balance, "balanceOf", account).WithStorageSlot(common.Hash{})
+		delivered := 0
+		err = streamer.StreamCall(true, nil, func(result StreamResult) {
+			delivered++
+		})
+		if tampered != (err != nil) || tampered != (delivered == 0) {
+			t.Fatalf("StreamCall with tampered=%v returned error %v after %d results", tampered, err, delivered)
+		}
+
+		// Batch.ExecuteRaw, which HeadFeed, Record and CompareAcross are built on
+		builder := mc.Clone()
+		builder.AddCall(testTokenAddress, &testTokenAbi, &balance, "balanceOf", account).WithStorageSlot(common.Hash{})
+		batch, err := builder.Snapshot()
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, err = batch.ExecuteRaw(mc, true, nil)
+		if tampered != (err != nil) {
+			t.Fatalf("ExecuteRaw with tampered=%v returned error %v", tampered, err)
+		}
+	}
+}
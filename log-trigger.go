@@ -0,0 +1,106 @@
+package batchquery
+
+import (
+	"fmt"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+// The topic of the ERC-20 Transfer(address,address,uint256) event
+var transferEventTopic = crypto.Keccak256Hash([]byte("Transfer(address,address,uint256)"))
+
+// A description of event logs that indicate a watched value may have changed
+type LogTrigger struct {
+	// The contract addresses that emit the logs (empty = any contract)
+	Addresses []common.Address
+
+	// The topics to match, using the same positional semantics as ethereum.FilterQuery:
+	// each position lists the allowed values for that topic, and an empty position matches anything
+	Topics [][]common.Hash
+}
+
+// Creates triggers for ERC-20 Transfer events on the provided token that send tokens to or from the account,
+// which is useful for watching the account's balance of that token
+func NewTransferTriggers(token common.Address, account common.Address) []LogTrigger {
+	accountTopic := common.BytesToHash(account.Bytes())
+	return []LogTrigger{
+		{
+			Addresses: []common.Address{token},
+			Topics:    [][]common.Hash{{transferEventTopic}, {accountTopic}},
+		},
+		{
+			Addresses: []common.Address{token},
+			Topics:    [][]common.Hash{{transferEventTopic}, {}, {accountTopic}},
+		},
+	}
+}
+
+// Checks whether a log matches the trigger
+func (t LogTrigger) Matches(log *types.Log) bool {
+	if len(t.Addresses) > 0 {
+		found := false
+		for _, address := range t.Addresses {
+			if address == log.Address {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+
+	if len(t.Topics) > len(log.Topics) {
+		return false
+	}
+	for i, options := range t.Topics {
+		if len(options) == 0 {
+			continue
+		}
+		found := false
+		for _, topic := range options {
+			if topic == log.Topics[i] {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+// Creates a filter for the logs that match the trigger in the provided range of blocks, inclusive
+func (t LogTrigger) filterQuery(fromBlock *big.Int, toBlock *big.Int) ethereum.FilterQuery {
+	return ethereum.FilterQuery{
+		FromBlock: fromBlock,
+		ToBlock:   toBlock,
+		Addresses: t.Addresses,
+		Topics:    t.Topics,
+	}
+}
+
+// Gets a key that identifies the trigger's filter, so triggers shared by several batches are only queried once
+func (t LogTrigger) key() string {
+	return fmt.Sprintf("%v|%v", t.Addresses, t.Topics)
+}
+
+// Checks whether any of the logs emitted after the provided block match any of the triggers
+func logsMatchTriggersAfter(logs []types.Log, triggers []LogTrigger, block *big.Int) bool {
+	for i := range logs {
+		if logs[i].BlockNumber <= block.Uint64() {
+			continue
+		}
+		for _, trigger := range triggers {
+			if trigger.Matches(&logs[i]) {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,101 @@
+package batchquery
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+// The logs that match a filter, along with the results of a batch run at the last block the logs were read from
+type LogsWithState struct {
+	// The block the batch was run at, which is the last block of the log range
+	BlockNumber *big.Int
+
+	// The hash of that block if it's known, from the filter or from a log emitted in it (nil = unknown)
+	BlockHash *common.Hash
+
+	// The logs that match the filter, up to and including the block
+	Logs []types.Log
+
+	// The results of the batch at the block
+	Results *Results
+}
+
+// Gets the logs that match a filter and runs the batch at the last block of the filter's range in one step, so the state an indexer reads
+// reflects exactly the logs it got, including the last one, rather than whatever block the client happens to be at by the time the batch runs.
+// The range ends at the filter's ToBlock if it has one, at the block opts specifies if not, or at the latest block otherwise; filters with a
+// BlockHash read both the logs and the state at that block. If a log was emitted in the last block, the batch is pinned to that log's block hash
+// (when the caller's client supports calls at a block hash), so a reorg between getting the logs and running the batch fails the batch instead
+// of mixing forks. The pending block isn't supported.
+func (b *Batch) ExecuteWithLogs(caller *MultiCaller, filterer ILogFilterer, query ethereum.FilterQuery, requireSuccess bool, opts *bind.CallOpts) (*LogsWithState, error) {
+	stateOpts, err := getLogStateOpts(caller, query, opts)
+	if err != nil {
+		return nil, err
+	}
+	blockNumber := stateOpts.BlockNumber
+	options := caller.newCallOptions(stateOpts)
+	if query.BlockHash == nil {
+		query.ToBlock = new(big.Int).Set(blockNumber)
+	}
+
+	logs, err := filterer.FilterLogs(options.ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("error getting logs up to block %s: %w", blockNumber.String(), wrapClientError(err))
+	}
+	blockHash := options.blockHash
+	for i := range logs {
+		if logs[i].BlockNumber > blockNumber.Uint64() {
+			return nil, fmt.Errorf("log %d is from block %d, which is after block %s", i, logs[i].BlockNumber, blockNumber.String())
+		}
+		if blockHash == nil && logs[i].BlockNumber == blockNumber.Uint64() {
+			if _, ok := caller.client.(IContractCallerAtHash); ok {
+				hash := logs[i].BlockHash
+				blockHash = &hash
+				stateOpts = CallOptsAtHash(stateOpts, hash)
+			}
+		}
+	}
+
+	responses, err := b.ExecuteRaw(caller, requireSuccess, stateOpts)
+	if err != nil {
+		return nil, fmt.Errorf("error running batch at block %s: %w", blockNumber.String(), err)
+	}
+	return &LogsWithState{
+		BlockNumber: blockNumber,
+		BlockHash:   blockHash,
+		Logs:        logs,
+		Results:     newResults(b.calls, responses, nil),
+	}, nil
+}
+
+// Gets the options for running a batch at the last block of a log filter's range, with the block's number resolved
+func getLogStateOpts(caller *MultiCaller, query ethereum.FilterQuery, opts *bind.CallOpts) (*bind.CallOpts, error) {
+	if query.BlockHash != nil {
+		return pinCallOpts(caller, CallOptsAtHash(opts, *query.BlockHash))
+	}
+	if query.ToBlock == nil {
+		return pinCallOpts(caller, opts)
+	}
+	if query.ToBlock.Sign() < 0 {
+		return nil, fmt.Errorf("the filter's last block must be a block number, not %s", query.ToBlock.String())
+	}
+
+	// The filter's range takes priority over any block in the options, including a hash from CallOptsAtHash
+	var rangeOpts bind.CallOpts
+	if opts != nil {
+		rangeOpts = *opts
+	}
+	if rangeOpts.Context != nil {
+		if _, exists := rangeOpts.Context.Value(blockHashKey{}).(common.Hash); exists {
+			rangeOpts.Context = context.WithValue(rangeOpts.Context, blockHashKey{}, nil)
+		}
+	}
+	rangeOpts.BlockNumber = query.ToBlock
+	rangeOpts.Pending = false
+	return pinCallOpts(caller, &rangeOpts)
+}
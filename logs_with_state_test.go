@@ -0,0 +1,80 @@
+package batchquery
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+func TestExecuteWithLogsAtLatestBlock(t *testing.T) {
+	mc, _ := newTestMultiCaller(t)
+	account := common.HexToAddress("0x0102")
+	batch := newBalanceBatch(t, mc, account)
+
+	// The mock client treats a block hash as the number of the block it's for
+	lastHash := common.BigToHash(big.NewInt(100))
+	filterer := &mockLogFilterer{
+		logs: []types.Log{
+			{Address: testTokenAddress, BlockNumber: 90},
+			{Address: testTokenAddress, BlockNumber: 100, BlockHash: lastHash},
+		},
+	}
+	state, err := batch.ExecuteWithLogs(mc, filterer, ethereum.FilterQuery{FromBlock: big.NewInt(80)}, true, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if state.BlockNumber.Int64() != 100 || filterer.queries[0].ToBlock.Int64() != 100 || len(state.Logs) != 2 {
+		t.Fatalf("expected the logs and state to be read up to block 100, got %+v", state)
+	}
+	if state.BlockHash == nil || *state.BlockHash != lastHash {
+		t.Fatal("expected the state to be pinned to the last log's block hash")
+	}
+	result, _ := state.Results.Get(account.Hex())
+	var balance *big.Int
+	err = result.Unpack(&balance)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if balance.Cmp(expectedBalance(account, 100)) != 0 {
+		t.Fatalf("expected the balance at block 100, got %s", balance)
+	}
+}
+
+func TestExecuteWithLogsInRange(t *testing.T) {
+	mc, _ := newTestMultiCaller(t)
+	account := common.HexToAddress("0x0102")
+	batch := newBalanceBatch(t, mc, account)
+	filterer := &mockLogFilterer{
+		logs: []types.Log{
+			{Address: testTokenAddress, BlockNumber: 40},
+			{Address: testTokenAddress, BlockNumber: 60},
+		},
+	}
+
+	// The filter's range decides the block, and the state is read at its end even if the last log is earlier
+	query := ethereum.FilterQuery{FromBlock: big.NewInt(30), ToBlock: big.NewInt(50)}
+	state, err := batch.ExecuteWithLogs(mc, filterer, query, true, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if state.BlockNumber.Int64() != 50 || state.BlockHash != nil || len(state.Logs) != 1 {
+		t.Fatalf("expected one log and the state at block 50, got %+v", state)
+	}
+	result, _ := state.Results.Get(account.Hex())
+	var balance *big.Int
+	err = result.Unpack(&balance)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if balance.Cmp(expectedBalance(account, 50)) != 0 {
+		t.Fatalf("expected the balance at block 50, got %s", balance)
+	}
+
+	_, err = batch.ExecuteWithLogs(mc, filterer, ethereum.FilterQuery{FromBlock: big.NewInt(30), ToBlock: big.NewInt(-1)}, true, nil)
+	if err == nil {
+		t.Fatal("expected a block tag instead of a number to be rejected")
+	}
+}
@@ -0,0 +1,190 @@
+package batchquery
+
+import (
+	"container/list"
+	"sync"
+)
+
+const (
+	// The approximate memory used by each cache entry on top of its key and return data
+	memoryCacheEntryOverhead int = 128
+
+	// The maximum number of entries in the cache NewMultiCaller creates
+	defaultMemoryCacheEntries int = 10000
+
+	// The maximum approximate memory used by the cache NewMultiCaller creates, in bytes
+	defaultMemoryCacheBytes int = 64 * 1024 * 1024
+)
+
+// The hit and miss counts of a cache, along with its current size
+type CacheStats struct {
+	// The number of lookups that found a cached result
+	Hits uint64
+
+	// The number of lookups that didn't find a cached result
+	Misses uint64
+
+	// The number of entries that were evicted to stay within the cache's limits
+	Evictions uint64
+
+	// The number of entries in the cache
+	Entries int
+
+	// The approximate memory used by the entries, in bytes
+	Bytes int
+}
+
+// A single entry in the memory cache
+type memoryCacheEntry struct {
+	// The encoded cache key
+	key string
+
+	// The cached response
+	response CallResponse
+
+	// The approximate memory used by the entry, in bytes
+	size int
+
+	// Whether the entry is for the latest block
+	latest bool
+}
+
+// An in-memory cache of call results that evicts the least recently used entries once it reaches its limits,
+// so long-running services can cache results without their memory growing unboundedly.
+type MemoryCache struct {
+	// The maximum number of entries to keep (0 = no limit)
+	maxEntries int
+
+	// The maximum approximate memory to use for the entries, in bytes (0 = no limit)
+	maxBytes int
+
+	// The entries, from the most recently used to the least
+	order *list.List
+
+	// The list element of each entry, by encoded key
+	entries map[string]*list.Element
+
+	// The list elements of the entries for the latest block, by encoded key
+	latestEntries map[string]*list.Element
+
+	// The cache's statistics
+	stats CacheStats
+
+	// Lock for the entries and statistics
+	lock sync.Mutex
+}
+
+// Creates a new MemoryCache instance with the provided limits on the number of entries and their approximate memory usage in bytes (0 = no limit)
+func NewMemoryCache(maxEntries int, maxBytes int) *MemoryCache {
+	return &MemoryCache{
+		maxEntries:    maxEntries,
+		maxBytes:      maxBytes,
+		order:         list.New(),
+		entries:       map[string]*list.Element{},
+		latestEntries: map[string]*list.Element{},
+	}
+}
+
+// Gets the cached response for a call, and whether there was one
+func (c *MemoryCache) Get(key CacheKey) (CallResponse, bool, error) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	element, exists := c.entries[string(key.Bytes())]
+	if !exists {
+		c.stats.Misses++
+		return CallResponse{}, false, nil
+	}
+	c.stats.Hits++
+	c.order.MoveToFront(element)
+	return element.Value.(*memoryCacheEntry).response, true, nil
+}
+
+// Stores the response for a call, evicting the least recently used entries if the cache is over its limits afterwards.
+// A response that's too large for the cache on its own isn't stored.
+func (c *MemoryCache) Set(key CacheKey, response CallResponse) error {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.set(key, response)
+	return nil
+}
+
+// Stores the response for each call
+func (c *MemoryCache) SetAll(keys []CacheKey, responses []CallResponse) error {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	for i, key := range keys {
+		c.set(key, responses[i])
+	}
+	return nil
+}
+
+// Gets the cache's hit and miss counts, along with its current size
+func (c *MemoryCache) Stats() CacheStats {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return c.stats
+}
+
+// Removes every entry from the cache, keeping its hit and miss counts
+func (c *MemoryCache) Clear() {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.order.Init()
+	c.entries = map[string]*list.Element{}
+	c.latestEntries = map[string]*list.Element{}
+	c.stats.Entries = 0
+	c.stats.Bytes = 0
+}
+
+// Removes every entry for the latest block
+func (c *MemoryCache) InvalidateLatest() error {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	for _, element := range c.latestEntries {
+		c.remove(element)
+	}
+	return nil
+}
+
+// Stores the response for a call while the lock is held
+func (c *MemoryCache) set(key CacheKey, response CallResponse) {
+	entry := &memoryCacheEntry{
+		key:      string(key.Bytes()),
+		response: response,
+		latest:   key.Latest,
+	}
+	entry.size = len(entry.key) + len(response.ReturnData) + memoryCacheEntryOverhead
+	if c.maxBytes > 0 && entry.size > c.maxBytes {
+		return
+	}
+
+	element, exists := c.entries[entry.key]
+	if exists {
+		c.stats.Bytes -= element.Value.(*memoryCacheEntry).size
+		element.Value = entry
+		c.order.MoveToFront(element)
+	} else {
+		element = c.order.PushFront(entry)
+		c.entries[entry.key] = element
+		c.stats.Entries++
+	}
+	if entry.latest {
+		c.latestEntries[entry.key] = element
+	}
+	c.stats.Bytes += entry.size
+
+	// Evict the least recently used entries until the cache is within its limits
+	for (c.maxEntries > 0 && c.stats.Entries > c.maxEntries) || (c.maxBytes > 0 && c.stats.Bytes > c.maxBytes) {
+		c.remove(c.order.Back())
+		c.stats.Evictions++
+	}
+}
+
+// Removes an entry from the cache while the lock is held
+func (c *MemoryCache) remove(element *list.Element) {
+	entry := c.order.Remove(element).(*memoryCacheEntry)
+	delete(c.entries, entry.key)
+	delete(c.latestEntries, entry.key)
+	c.stats.Entries--
+	c.stats.Bytes -= entry.size
+}
@@ -0,0 +1,115 @@
+package batchquery
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// Creates a cache key for a block of the test token
+func testCacheKey(blockNumber uint64) CacheKey {
+	return CacheKey{
+		ChainID:     1337,
+		BlockNumber: blockNumber,
+		Target:      testTokenAddress,
+		CallData:    []byte{1, 2, 3, 4},
+	}
+}
+
+func TestMemoryCacheEvictsLeastRecentlyUsed(t *testing.T) {
+	cache := NewMemoryCache(2, 0)
+	response := CallResponse{Status: true, ReturnData: []byte{1}}
+	cache.Set(testCacheKey(1), response)
+	cache.Set(testCacheKey(2), response)
+
+	// Using the first entry makes the second one the least recently used
+	_, found, _ := cache.Get(testCacheKey(1))
+	if !found {
+		t.Fatal("expected the first entry to be cached")
+	}
+	cache.Set(testCacheKey(3), response)
+	for block, expected := range map[uint64]bool{1: true, 2: false, 3: true} {
+		_, found, _ = cache.Get(testCacheKey(block))
+		if found != expected {
+			t.Fatalf("expected block %d to be cached: %t, got %t", block, expected, found)
+		}
+	}
+
+	stats := cache.Stats()
+	if stats.Hits != 3 || stats.Misses != 1 || stats.Evictions != 1 || stats.Entries != 2 {
+		t.Fatalf("unexpected stats: %+v", stats)
+	}
+}
+
+func TestMemoryCacheByteLimit(t *testing.T) {
+	entrySize := len(testCacheKey(0).Bytes()) + 100 + memoryCacheEntryOverhead
+	cache := NewMemoryCache(0, entrySize*2)
+	for block := uint64(0); block < 5; block++ {
+		cache.Set(testCacheKey(block), CallResponse{Status: true, ReturnData: make([]byte, 100)})
+	}
+	stats := cache.Stats()
+	if stats.Entries != 2 || stats.Bytes != entrySize*2 || stats.Evictions != 3 {
+		t.Fatalf("unexpected stats: %+v", stats)
+	}
+
+	// Responses that don't fit at all aren't stored
+	cache.Set(testCacheKey(10), CallResponse{Status: true, ReturnData: make([]byte, entrySize*2)})
+	_, found, _ := cache.Get(testCacheKey(10))
+	if found || cache.Stats().Entries != 2 {
+		t.Fatal("expected the oversized response not to be cached")
+	}
+}
+
+func TestMemoryCacheServesBatches(t *testing.T) {
+	mc, client := newTestMultiCaller(t)
+	cache := NewMemoryCache(100, 0)
+	mc.Cache = cache
+	opts := &bind.CallOpts{BlockNumber: big.NewInt(50)}
+	account := common.HexToAddress("0x0102")
+	for i := 0; i < 3; i++ {
+		var balance *big.Int
+		mc.AddCall(testTokenAddress, &testTokenAbi, &balance, "balanceOf", account)
+		_, err := mc.FlexibleCall(true, opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if balance.Cmp(expectedBalance(account, 50)) != 0 {
+			t.Fatalf("expected balance %s, got %s", expectedBalance(account, 50), balance)
+		}
+	}
+	stats := cache.Stats()
+	if client.calls != 1 || stats.Hits != 2 || stats.Misses != 1 {
+		t.Fatalf("expected one eth_call and two cache hits, got %d eth_calls and %+v", client.calls, stats)
+	}
+}
+
+func TestMultiCallerCachesByDefault(t *testing.T) {
+	mc, client := newTestMultiCaller(t)
+	opts := &bind.CallOpts{BlockNumber: big.NewInt(50)}
+	account := common.HexToAddress("0x0102")
+	getBalance := func() {
+		var balance *big.Int
+		mc.AddCall(testTokenAddress, &testTokenAbi, &balance, "balanceOf", account)
+		_, err := mc.FlexibleCall(true, opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if balance.Cmp(expectedBalance(account, 50)) != 0 {
+			t.Fatalf("expected balance %s, got %s", expectedBalance(account, 50), balance)
+		}
+	}
+	getBalance()
+	getBalance()
+	if client.calls != 1 {
+		t.Fatalf("expected the repeated call to be served from the default cache, got %d eth_calls", client.calls)
+	}
+
+	// Caching can be disabled by removing the cache
+	mc.Cache = nil
+	getBalance()
+	if client.calls != 2 {
+		t.Fatalf("expected the call to run without a cache, got %d eth_calls", client.calls)
+	}
+}
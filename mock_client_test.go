@@ -0,0 +1,376 @@
+package batchquery
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+)
+
+const testTokenAbiString = `[
+	{"inputs":[{"name":"account","type":"address"}],"name":"balanceOf","outputs":[{"name":"","type":"uint256"}],"stateMutability":"view","type":"function"},
+	{"inputs":[{"name":"count","type":"uint256"}],"name":"list","outputs":[{"name":"","type":"uint256[]"}],"stateMutability":"view","type":"function"},
+	{"inputs":[],"name":"boom","outputs":[{"name":"","type":"uint256"}],"stateMutability":"view","type":"function"},
+	{"inputs":[],"name":"whoami","outputs":[{"name":"","type":"address"}],"stateMutability":"view","type":"function"},
+	{"inputs":[],"name":"decimals","outputs":[{"name":"","type":"uint8"}],"stateMutability":"view","type":"function"},
+	{"inputs":[],"name":"symbol","outputs":[{"name":"","type":"string"}],"stateMutability":"view","type":"function"}
+]`
+
+var (
+	testMulticallAddress = common.HexToAddress("0x1111111111111111111111111111111111111111")
+	testTokenAddress     = common.HexToAddress("0x2222222222222222222222222222222222222222")
+	testTokenAbi         = mustParseAbi(testTokenAbiString)
+	testCoinbase         = common.HexToAddress("0xc0c0c0c0c0c0c0c0c0c0c0c0c0c0c0c0c0c0c0c0")
+	testFeedAddress      = common.HexToAddress("0x3333333333333333333333333333333333333333")
+)
+
+// Parses an ABI, panicking on failure
+func mustParseAbi(abiString string) abi.ABI {
+	parsed, err := abi.JSON(strings.NewReader(abiString))
+	if err != nil {
+		panic(err)
+	}
+	return parsed
+}
+
+// The revert data for Error("boom")
+func boomRevertData() []byte {
+	typ, _ := abi.NewType("string", "", nil)
+	data, _ := abi.Arguments{{Type: typ}}.Pack("boom")
+	return append(append([]byte{}, errorSelector...), data...)
+}
+
+// A revert error in the form returned by geth's RPC client
+type mockRevertError struct {
+	data []byte
+}
+
+func (e *mockRevertError) Error() string          { return "execution reverted" }
+func (e *mockRevertError) ErrorCode() int         { return 3 }
+func (e *mockRevertError) ErrorData() interface{} { return fmt.Sprintf("0x%x", e.data) }
+
+// A client that emulates a multicall contract and a simple token contract.
+// A token balance is the last two bytes of the account's address plus the block number (0 if the latest block is used).
+type mockClient struct {
+	// The number of eth_calls made
+	calls int
+
+	// The number of calls within each eth_call to the multicall contract
+	chunkSizes []int
+
+	// The gas limit of each eth_call
+	gasLimits []uint64
+
+	// If set, eth_calls with more call data than this fail as too large
+	maxCallData int
+
+	// If set, this error is returned by every eth_call
+	err error
+
+	// If set, this is called before each eth_call and can block or fail it
+	hook func(ctx context.Context, msg ethereum.CallMsg) error
+
+	// The code of each account
+	code map[common.Address][]byte
+
+	// If set, the multicall contract only supports Multicall v1's aggregate function
+	aggregateOnly bool
+
+	// If set, the multicall contract corrupts the last byte of every successful result
+	corrupt bool
+
+	lock sync.Mutex
+}
+
+// Runs a single call against the token contract, or against the multicall contract's getters
+func (m *mockClient) runTokenCall(from common.Address, target common.Address, data []byte, blockNumber *big.Int) ([]byte, bool) {
+	if target == testMulticallAddress && len(data) >= 4 {
+		return m.runGetterCall(data, blockNumber)
+	}
+	if target == testFeedAddress && len(data) >= 4 {
+		return m.runFeedCall(data, blockNumber)
+	}
+	if target != testTokenAddress || len(data) < 4 {
+		return nil, true
+	}
+	method, err := testTokenAbi.MethodById(data[:4])
+	if err != nil {
+		return nil, false
+	}
+	args, err := method.Inputs.Unpack(data[4:])
+	if err != nil {
+		return nil, false
+	}
+	var out []byte
+	switch method.Name {
+	case "balanceOf":
+		account := args[0].(common.Address)
+		balance := new(big.Int).SetBytes(account[18:])
+		if blockNumber != nil {
+			balance.Add(balance, blockNumber)
+		}
+		out, err = method.Outputs.Pack(balance)
+	case "list":
+		list := make([]*big.Int, args[0].(*big.Int).Int64())
+		for i := range list {
+			list[i] = big.NewInt(int64(i))
+		}
+		out, err = method.Outputs.Pack(list)
+	case "whoami":
+		out, err = method.Outputs.Pack(from)
+	case "boom":
+		return boomRevertData(), false
+	case "decimals":
+		out, err = method.Outputs.Pack(uint8(2))
+	case "symbol":
+		out, err = method.Outputs.Pack("TST")
+	}
+	if err != nil {
+		panic(err)
+	}
+	return out, true
+}
+
+// Runs a call against the price feed, which prices the token at 3 with 8 decimals and updates every block
+func (m *mockClient) runFeedCall(data []byte, blockNumber *big.Int) ([]byte, bool) {
+	feedAbi, err := getPriceFeedAbi()
+	if err != nil {
+		panic(err)
+	}
+	method, err := feedAbi.MethodById(data[:4])
+	if err != nil {
+		return nil, false
+	}
+	number := big.NewInt(100)
+	if blockNumber != nil {
+		number = blockNumber
+	}
+	var out []byte
+	switch method.Name {
+	case "decimals":
+		out, err = method.Outputs.Pack(uint8(8))
+	case "latestRoundData":
+		timestamp := new(big.Int).Mul(number, big.NewInt(12))
+		out, err = method.Outputs.Pack(number, big.NewInt(3e8), timestamp, timestamp, number)
+	}
+	if err != nil {
+		panic(err)
+	}
+	return out, true
+}
+
+// Runs a call against one of the multicall contract's getters.
+// The chain ID is 1337, the base fee is 7, timestamps are 12 seconds per block, and ETH balances match token balances.
+func (m *mockClient) runGetterCall(data []byte, blockNumber *big.Int) ([]byte, bool) {
+	gettersAbi, err := getMulticall3GettersAbi()
+	if err != nil {
+		panic(err)
+	}
+	method, err := gettersAbi.MethodById(data[:4])
+	if err != nil {
+		return nil, false
+	}
+	args, err := method.Inputs.Unpack(data[4:])
+	if err != nil {
+		return nil, false
+	}
+	number := big.NewInt(100)
+	if blockNumber != nil {
+		number = blockNumber
+	}
+	var out []byte
+	switch method.Name {
+	case "getBlockNumber":
+		out, err = method.Outputs.Pack(number)
+	case "getCurrentBlockTimestamp":
+		out, err = method.Outputs.Pack(new(big.Int).Mul(number, big.NewInt(12)))
+	case "getBasefee":
+		out, err = method.Outputs.Pack(big.NewInt(7))
+	case "getChainId":
+		out, err = method.Outputs.Pack(big.NewInt(1337))
+	case "getCurrentBlockCoinbase":
+		out, err = method.Outputs.Pack(testCoinbase)
+	case "getEthBalance":
+		balance := expectedBalance(args[0].(common.Address), 0)
+		if blockNumber != nil {
+			balance.Add(balance, blockNumber)
+		}
+		out, err = method.Outputs.Pack(balance)
+	case "getBlockHash":
+		out, err = method.Outputs.Pack(common.BigToHash(args[0].(*big.Int)))
+	case "getLastBlockHash":
+		out, err = method.Outputs.Pack(common.BigToHash(new(big.Int).Sub(number, common.Big1)))
+	default:
+		return nil, false
+	}
+	if err != nil {
+		panic(err)
+	}
+	return out, true
+}
+
+func (m *mockClient) CallContract(ctx context.Context, msg ethereum.CallMsg, blockNumber *big.Int) ([]byte, error) {
+	m.lock.Lock()
+	m.calls++
+	m.gasLimits = append(m.gasLimits, msg.Gas)
+	hook := m.hook
+	m.lock.Unlock()
+	if hook != nil {
+		err := hook(ctx, msg)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if m.err != nil {
+		return nil, m.err
+	}
+	if m.maxCallData > 0 && len(msg.Data) > m.maxCallData {
+		return nil, fmt.Errorf("request entity too large")
+	}
+
+	// Run the call directly against the token
+	if *msg.To != testMulticallAddress {
+		out, ok := m.runTokenCall(msg.From, *msg.To, msg.Data, blockNumber)
+		if !ok {
+			return nil, &mockRevertError{data: out}
+		}
+		return out, nil
+	}
+
+	// Emulate the multicall contract
+	method, err := multicallAbi.MethodById(msg.Data[:4])
+	if err != nil {
+		return nil, err
+	}
+	if method.Name == "getBlockNumber" {
+		number := big.NewInt(100)
+		if blockNumber != nil {
+			number = blockNumber
+		}
+		return method.Outputs.Pack(number)
+	}
+	if method.Name == "tryAggregate" && m.aggregateOnly {
+		return nil, &mockRevertError{}
+	}
+	args, err := method.Inputs.Unpack(msg.Data[4:])
+	if err != nil {
+		return nil, err
+	}
+	var calls []struct {
+		Target   common.Address
+		CallData []byte
+	}
+	if method.Name == "aggregate" {
+		abi.ConvertType(args[0], &calls)
+	} else {
+		abi.ConvertType(args[1], &calls)
+	}
+	m.lock.Lock()
+	m.chunkSizes = append(m.chunkSizes, len(calls))
+	m.lock.Unlock()
+
+	// Aggregate reverts if any of the calls fail
+	if method.Name == "aggregate" {
+		number := big.NewInt(100)
+		if blockNumber != nil {
+			number = blockNumber
+		}
+		returnData := make([][]byte, len(calls))
+		for i, call := range calls {
+			out, ok := m.runTokenCall(testMulticallAddress, call.Target, call.CallData, blockNumber)
+			if !ok {
+				return nil, &mockRevertError{data: boomRevertData()}
+			}
+			returnData[i] = out
+		}
+		return method.Outputs.Pack(number, returnData)
+	}
+	requireSuccess := args[0].(bool)
+
+	type result struct {
+		Success    bool
+		ReturnData []byte
+	}
+	results := make([]result, len(calls))
+	for i, call := range calls {
+		out, ok := m.runTokenCall(testMulticallAddress, call.Target, call.CallData, blockNumber)
+		if !ok && requireSuccess {
+			return nil, &mockRevertError{data: boomRevertData()}
+		}
+		if ok && m.corrupt && len(out) > 0 {
+			out[len(out)-1]++
+		}
+		results[i] = result{ok, out}
+	}
+	return method.Outputs.Pack(results)
+}
+
+// Runs a call at a block hash, which the mock treats as the big-endian block number
+func (m *mockClient) CallContractAtHash(ctx context.Context, msg ethereum.CallMsg, blockHash common.Hash) ([]byte, error) {
+	return m.CallContract(ctx, msg, blockHash.Big())
+}
+
+func (m *mockClient) CodeAt(ctx context.Context, account common.Address, blockNumber *big.Int) ([]byte, error) {
+	return m.code[account], nil
+}
+
+// Gets the number of calls in each multicall chunk that was run
+func (m *mockClient) getChunkSizes() []int {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	return append([]int{}, m.chunkSizes...)
+}
+
+// A client that emulates a balance checker contract.
+// An account's ETH balance is the last byte of its address, and its balance of a token is that plus 1000 times the last byte of the token's address.
+type mockBalanceCheckerClient struct {
+	// The code of each account
+	code map[common.Address][]byte
+}
+
+func (c *mockBalanceCheckerClient) CallContract(ctx context.Context, msg ethereum.CallMsg, blockNumber *big.Int) ([]byte, error) {
+	args, err := balanceBatcherAbi.Methods["balances"].Inputs.Unpack(msg.Data[4:])
+	if err != nil {
+		return nil, err
+	}
+	users := args[0].([]common.Address)
+	tokens := args[1].([]common.Address)
+	balances := make([]*big.Int, 0, len(users)*len(tokens))
+	for _, user := range users {
+		for _, token := range tokens {
+			balances = append(balances, mockBalance(user, token))
+		}
+	}
+	return balanceBatcherAbi.Methods["balances"].Outputs.Pack(balances)
+}
+
+func (c *mockBalanceCheckerClient) CodeAt(ctx context.Context, account common.Address, blockNumber *big.Int) ([]byte, error) {
+	return c.code[account], nil
+}
+
+// Gets the balance of a token (or ETH, for the zero address) that the mock balance checker reports for an account
+func mockBalance(account common.Address, token common.Address) *big.Int {
+	return big.NewInt(int64(account[19]) + int64(token[19])*1000)
+}
+
+// Creates a MultiCaller backed by a mock client
+func newTestMultiCaller(t testing.TB) (*MultiCaller, *mockClient) {
+	client := &mockClient{}
+	mc, err := NewMultiCaller(client, testMulticallAddress)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return mc, client
+}
+
+// Gets the mock token balance of an account at a block
+func expectedBalance(account common.Address, blockNumber int64) *big.Int {
+	balance := new(big.Int).SetBytes(account[18:])
+	return balance.Add(balance, big.NewInt(blockNumber))
+}
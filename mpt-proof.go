@@ -0,0 +1,162 @@
+package batchquery
+
+import (
+	"bytes"
+	"fmt"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/rlp"
+)
+
+// A single item of a decoded trie node
+type trieNodeItem struct {
+	// The RLP kind of the item
+	kind rlp.Kind
+
+	// The content of the item if it's a string
+	content []byte
+
+	// The full RLP encoding of the item, used for child nodes that are embedded rather than referenced by hash
+	raw []byte
+}
+
+// Verifies a Merkle proof for a key (which is already hashed for the state and storage tries) against a trie root, returning the proven value (or nil if the key isn't in the trie).
+// This walks the Merkle Patricia trie nodes directly rather than using geth's trie package, which would pull its database
+// backends into the dependency tree.
+func verifyProof(root common.Hash, key []byte, proof []string) ([]byte, error) {
+	// An empty trie has no nodes, so clients return an empty proof for it
+	if root == types.EmptyRootHash && len(proof) == 0 {
+		return nil, nil
+	}
+
+	nodes := make(map[common.Hash][]byte, len(proof))
+	for _, encodedNode := range proof {
+		node, err := hexutil.Decode(encodedNode)
+		if err != nil {
+			return nil, fmt.Errorf("error decoding proof node: %w", err)
+		}
+		nodes[crypto.Keccak256Hash(node)] = node
+	}
+
+	path := keyToNibbles(key)
+	node, exists := nodes[root]
+	if !exists {
+		return nil, fmt.Errorf("proof is missing the root node %s", root.Hex())
+	}
+	for depth := 0; ; depth++ {
+		items, err := decodeTrieNode(node)
+		if err != nil {
+			return nil, fmt.Errorf("error decoding proof node %d: %w", depth, err)
+		}
+
+		var child trieNodeItem
+		switch len(items) {
+		case 17:
+			// Branch node
+			if len(path) == 0 {
+				return nonEmpty(items[16].content), nil
+			}
+			child = items[path[0]]
+			path = path[1:]
+
+		case 2:
+			// Leaf or extension node
+			nodePath, isLeaf, err := compactToNibbles(items[0].content)
+			if err != nil {
+				return nil, fmt.Errorf("error decoding path of proof node %d: %w", depth, err)
+			}
+			if isLeaf {
+				if bytes.Equal(nodePath, path) {
+					return nonEmpty(items[1].content), nil
+				}
+				// The key diverges from the only leaf on its path, so it isn't in the trie
+				return nil, nil
+			}
+			if !bytes.HasPrefix(path, nodePath) {
+				return nil, nil
+			}
+			child = items[1]
+			path = path[len(nodePath):]
+
+		default:
+			return nil, fmt.Errorf("proof node %d has %d items, which is not a valid trie node", depth, len(items))
+		}
+
+		// Resolve the child, which is either embedded in its parent, referenced by hash, or empty
+		switch {
+		case child.kind == rlp.List:
+			node = child.raw
+		case len(child.content) == 0:
+			return nil, nil
+		case len(child.content) == common.HashLength:
+			node, exists = nodes[common.BytesToHash(child.content)]
+			if !exists {
+				return nil, fmt.Errorf("proof node %d is missing", depth+1)
+			}
+		default:
+			return nil, fmt.Errorf("proof node %d has an invalid child reference", depth)
+		}
+	}
+}
+
+// Decodes a trie node into its items
+func decodeTrieNode(node []byte) ([]trieNodeItem, error) {
+	content, _, err := rlp.SplitList(node)
+	if err != nil {
+		return nil, err
+	}
+	items := []trieNodeItem{}
+	for len(content) > 0 {
+		kind, itemContent, rest, err := rlp.Split(content)
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, trieNodeItem{
+			kind:    kind,
+			content: itemContent,
+			raw:     content[:len(content)-len(rest)],
+		})
+		content = rest
+	}
+	return items, nil
+}
+
+// Splits a key into its nibbles
+func keyToNibbles(key []byte) []byte {
+	nibbles := make([]byte, len(key)*2)
+	for i, b := range key {
+		nibbles[i*2] = b >> 4
+		nibbles[i*2+1] = b & 0x0f
+	}
+	return nibbles
+}
+
+// Decodes a hex-prefix encoded node path into its nibbles, and whether it belongs to a leaf node
+func compactToNibbles(compact []byte) ([]byte, bool, error) {
+	if len(compact) == 0 {
+		return nil, false, fmt.Errorf("empty path")
+	}
+	flag := compact[0] >> 4
+	if flag > 3 {
+		return nil, false, fmt.Errorf("invalid path flag %d", flag)
+	}
+	nibbles := keyToNibbles(compact)
+	if flag&1 == 1 {
+		// Odd length, so the first nibble is part of the path
+		nibbles = nibbles[1:]
+	} else {
+		nibbles = nibbles[2:]
+	}
+	return nibbles, flag >= 2, nil
+}
+
+// Returns nil for empty values, since the trie doesn't store them
+func nonEmpty(value []byte) []byte {
+	if len(value) == 0 {
+		return nil
+	}
+	return value
+}
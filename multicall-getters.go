@@ -0,0 +1,125 @@
+package batchquery
+
+import (
+	"fmt"
+	"math/big"
+	"strings"
+	"sync"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+)
+
+const (
+	// The ABI for the utility getters of Multicall3: https://github.com/mds1/multicall
+	// Multicall2 has all of them except getBasefee and getChainId.
+	multicall3GettersAbiString string = "[{\"inputs\":[],\"name\":\"getBasefee\",\"outputs\":[{\"internalType\":\"uint256\",\"name\":\"basefee\",\"type\":\"uint256\"}],\"stateMutability\":\"view\",\"type\":\"function\"},{\"inputs\":[{\"internalType\":\"uint256\",\"name\":\"blockNumber\",\"type\":\"uint256\"}],\"name\":\"getBlockHash\",\"outputs\":[{\"internalType\":\"bytes32\",\"name\":\"blockHash\",\"type\":\"bytes32\"}],\"stateMutability\":\"view\",\"type\":\"function\"},{\"inputs\":[],\"name\":\"getBlockNumber\",\"outputs\":[{\"internalType\":\"uint256\",\"name\":\"blockNumber\",\"type\":\"uint256\"}],\"stateMutability\":\"view\",\"type\":\"function\"},{\"inputs\":[],\"name\":\"getChainId\",\"outputs\":[{\"internalType\":\"uint256\",\"name\":\"chainid\",\"type\":\"uint256\"}],\"stateMutability\":\"view\",\"type\":\"function\"},{\"inputs\":[],\"name\":\"getCurrentBlockCoinbase\",\"outputs\":[{\"internalType\":\"address\",\"name\":\"coinbase\",\"type\":\"address\"}],\"stateMutability\":\"view\",\"type\":\"function\"},{\"inputs\":[],\"name\":\"getCurrentBlockDifficulty\",\"outputs\":[{\"internalType\":\"uint256\",\"name\":\"difficulty\",\"type\":\"uint256\"}],\"stateMutability\":\"view\",\"type\":\"function\"},{\"inputs\":[],\"name\":\"getCurrentBlockGasLimit\",\"outputs\":[{\"internalType\":\"uint256\",\"name\":\"gaslimit\",\"type\":\"uint256\"}],\"stateMutability\":\"view\",\"type\":\"function\"},{\"inputs\":[],\"name\":\"getCurrentBlockTimestamp\",\"outputs\":[{\"internalType\":\"uint256\",\"name\":\"timestamp\",\"type\":\"uint256\"}],\"stateMutability\":\"view\",\"type\":\"function\"},{\"inputs\":[{\"internalType\":\"address\",\"name\":\"addr\",\"type\":\"address\"}],\"name\":\"getEthBalance\",\"outputs\":[{\"internalType\":\"uint256\",\"name\":\"balance\",\"type\":\"uint256\"}],\"stateMutability\":\"view\",\"type\":\"function\"},{\"inputs\":[],\"name\":\"getLastBlockHash\",\"outputs\":[{\"internalType\":\"bytes32\",\"name\":\"blockHash\",\"type\":\"bytes32\"}],\"stateMutability\":\"view\",\"type\":\"function\"}]"
+)
+
+// ABI cache
+var multicall3GettersAbi abi.ABI
+var mc3gOnce sync.Once
+
+// Get the ABI for the Multicall3 utility getters
+func getMulticall3GettersAbi() (*abi.ABI, error) {
+	var err error
+	mc3gOnce.Do(func() {
+		var parsedAbi abi.ABI
+		parsedAbi, err = abi.JSON(strings.NewReader(multicall3GettersAbiString))
+		if err == nil {
+			multicall3GettersAbi = parsedAbi
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &multicall3GettersAbi, nil
+}
+
+// Adds a call to one of the multicall contract's own utility getters to the batch
+func (mc *MultiCaller) addGetterCall(output any, method string, args ...any) *Call {
+	gettersAbi, err := getMulticall3GettersAbi()
+	if err != nil {
+		// Report the error when the batch is run, like any other packing error
+		call := &Call{
+			Target: mc.contractAddress,
+			Method: method,
+			PackFunc: func() ([]byte, error) {
+				return nil, err
+			},
+		}
+		mc.calls = append(mc.calls, call)
+		return call
+	}
+	return mc.AddCall(mc.contractAddress, gettersAbi, output, method, args...)
+}
+
+// Adds a call to the batch that gets the number of the block the batch runs at
+func (mc *MultiCaller) AddBlockNumber(output **big.Int) *Call {
+	return mc.addGetterCall(output, "getBlockNumber")
+}
+
+// Adds a call to the batch that gets the timestamp of the block the batch runs at
+func (mc *MultiCaller) AddBlockTimestamp(output **big.Int) *Call {
+	return mc.addGetterCall(output, "getCurrentBlockTimestamp")
+}
+
+// Adds a call to the batch that gets the base fee of the block the batch runs at.
+// This is only supported by Multicall3; on older contracts the call fails.
+func (mc *MultiCaller) AddBaseFee(output **big.Int) *Call {
+	return mc.addGetterCall(output, "getBasefee")
+}
+
+// Adds a call to the batch that gets the chain ID.
+// This is only supported by Multicall3; on older contracts the call fails.
+func (mc *MultiCaller) AddChainID(output **big.Int) *Call {
+	return mc.addGetterCall(output, "getChainId")
+}
+
+// Adds a call to the batch that gets the gas limit of the block the batch runs at
+func (mc *MultiCaller) AddBlockGasLimit(output **big.Int) *Call {
+	return mc.addGetterCall(output, "getCurrentBlockGasLimit")
+}
+
+// Adds a call to the batch that gets the difficulty of the block the batch runs at, which is the RANDAO mix on proof-of-stake chains
+func (mc *MultiCaller) AddBlockDifficulty(output **big.Int) *Call {
+	return mc.addGetterCall(output, "getCurrentBlockDifficulty")
+}
+
+// Adds a call to the batch that gets the coinbase (fee recipient) of the block the batch runs at
+func (mc *MultiCaller) AddCoinbase(output *common.Address) *Call {
+	return mc.addGetterCall(output, "getCurrentBlockCoinbase")
+}
+
+// Adds a call to the batch that gets the hash of a recent block.
+// The EVM only exposes the hashes of the 256 most recent blocks; older (or future) blocks return an empty hash.
+func (mc *MultiCaller) AddBlockHash(blockNumber *big.Int, output *common.Hash) *Call {
+	return mc.addGetterCall(output, "getBlockHash", blockNumber)
+}
+
+// Adds a call to the batch that gets the hash of the block before the one the batch runs at
+func (mc *MultiCaller) AddLastBlockHash(output *common.Hash) *Call {
+	return mc.addGetterCall(output, "getLastBlockHash")
+}
+
+// Adds a call to the batch that gets the ETH balance of an address
+func (mc *MultiCaller) AddEthBalance(address common.Address, output **big.Int) *Call {
+	return mc.addGetterCall(output, "getEthBalance", address)
+}
+
+// Retrieves the ETH balance for a list of addresses through the multicall contract's getEthBalance function,
+// so small balance queries don't need a separate balance checker contract. The order of the resulting array corresponds to the order of the provided addresses.
+// The batch is chunked using the MultiCaller's settings, and its own list of pending calls is not affected.
+func (mc *MultiCaller) GetEthBalances(addresses []common.Address, opts *bind.CallOpts) ([]*big.Int, error) {
+	runner := mc.withCalls([]*Call{})
+	balances := make([]*big.Int, len(addresses))
+	for i, address := range addresses {
+		runner.AddEthBalance(address, &balances[i])
+	}
+	_, err := runner.FlexibleCall(true, opts)
+	if err != nil {
+		return nil, fmt.Errorf("error getting ETH balances: %w", err)
+	}
+	return balances, nil
+}
@@ -0,0 +1,75 @@
+package batchquery
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+)
+
+func TestMulticallGetters(t *testing.T) {
+	mc, client := newTestMultiCaller(t)
+	var blockNumber, timestamp, baseFee, chainID, balance *big.Int
+	var coinbase common.Address
+	var blockHash, lastBlockHash common.Hash
+	account := common.HexToAddress("0x0102")
+	mc.AddBlockNumber(&blockNumber)
+	mc.AddBlockTimestamp(&timestamp)
+	mc.AddBaseFee(&baseFee)
+	mc.AddChainID(&chainID)
+	mc.AddCoinbase(&coinbase)
+	mc.AddBlockHash(big.NewInt(42), &blockHash)
+	mc.AddLastBlockHash(&lastBlockHash)
+	mc.AddEthBalance(account, &balance)
+
+	// The getters share the batch with regular contract calls
+	var tokenBalance *big.Int
+	mc.AddCall(testTokenAddress, &testTokenAbi, &tokenBalance, "balanceOf", account)
+
+	_, err := mc.FlexibleCall(true, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sizes := client.getChunkSizes(); len(sizes) != 1 {
+		t.Fatalf("expected a single multicall, got %v", sizes)
+	}
+	if blockNumber.Int64() != 100 || timestamp.Int64() != 1200 || baseFee.Int64() != 7 || chainID.Int64() != 1337 {
+		t.Fatalf("unexpected block details: number %s, timestamp %s, base fee %s, chain ID %s", blockNumber, timestamp, baseFee, chainID)
+	}
+	if coinbase != testCoinbase || blockHash != common.BigToHash(big.NewInt(42)) || lastBlockHash != common.BigToHash(big.NewInt(99)) {
+		t.Fatalf("unexpected coinbase %s, block hash %s, or last block hash %s", coinbase.Hex(), blockHash.Hex(), lastBlockHash.Hex())
+	}
+	if balance.Cmp(tokenBalance) != 0 || balance.Cmp(expectedBalance(account, 0)) != 0 {
+		t.Fatalf("expected a balance of %s, got %s", expectedBalance(account, 0), balance)
+	}
+}
+
+func TestGetEthBalances(t *testing.T) {
+	mc, client := newTestMultiCaller(t)
+	mc.CallBatchSize = 2
+
+	// Pending calls on the MultiCaller aren't part of the balance query
+	var pending *big.Int
+	mc.AddBlockNumber(&pending)
+
+	addresses := make([]common.Address, 5)
+	for i := range addresses {
+		addresses[i] = common.BigToAddress(big.NewInt(int64(i + 1)))
+	}
+	balances, err := mc.GetEthBalances(addresses, &bind.CallOpts{BlockNumber: big.NewInt(50)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, balance := range balances {
+		if balance.Cmp(expectedBalance(addresses[i], 50)) != 0 {
+			t.Fatalf("expected balance %s for address %d, got %s", expectedBalance(addresses[i], 50), i, balance)
+		}
+	}
+	if sizes := client.getChunkSizes(); len(sizes) != 3 {
+		t.Fatalf("expected the query to be chunked with the MultiCaller's settings, got %v", sizes)
+	}
+	if len(mc.calls) != 1 {
+		t.Fatalf("expected the pending call to be left alone, got %d pending calls", len(mc.calls))
+	}
+}
@@ -10,6 +10,8 @@ import (
 	"math/big"
 	"strings"
 	"sync"
+	"sync/atomic"
+	"time"
 
 	"github.com/ethereum/go-ethereum"
 	"github.com/ethereum/go-ethereum/accounts/abi"
@@ -37,11 +39,110 @@ type Call struct {
 	// The name of the method being called (for debugging only)
 	Method string `json:"-"`
 
-	// Function to generate the call data
+	// Function to generate the call data (if nil, CallData is used as-is)
 	PackFunc func() ([]byte, error) `json:"-"`
 
-	// Function to generate the output from the response
+	// Function to generate the output from the response (if nil, the response is discarded)
 	UnpackFunc func([]byte) error `json:"-"`
+
+	// The output the response is unpacked into, if the call was added with one
+	Output any `json:"-"`
+
+	// The expected size of the ABI-encoded return data in bytes, used when splitting the batch into chunks (0 = a single word)
+	ReturnSize int `json:"-"`
+
+	// The priority of the call; calls with a higher priority are placed into earlier chunks than those with a lower one (default 0)
+	Priority int `json:"-"`
+
+	// How long the chunk containing the call may take before it's cancelled; calls with different timeouts are run in separate chunks (0 = the MultiCaller's ChunkTimeout).
+	// If the batch doesn't require success, the calls in a chunk that runs out of time are reported as failed rather than failing the batch.
+	Timeout time.Duration `json:"-"`
+
+	// The expected gas usage of the call, used to keep each chunk within the MultiCaller's GasLimit (0 = unknown)
+	GasEstimate uint64 `json:"-"`
+
+	// The name of the group the call belongs to, which FlexibleCallByGroup organizes the results by ("" = the unnamed group)
+	Group string `json:"-"`
+
+	// The block to run the call at, overriding the block the batch runs at; calls at different blocks are run in separate chunks (nil = the batch's block)
+	BlockNumber *big.Int `json:"-"`
+
+	// The key the call's result can be looked up by in the batch's Results ("" = only by index)
+	Key string `json:"-"`
+
+	// The ABI of the contract being called, used to decode its custom errors (nil = unknown)
+	contractAbi *abi.ABI
+
+	// Describes how the call's response can be verified against proven state, if it can be
+	proofHint *proofHint
+}
+
+// Sets the key the call's result can be looked up by in the batch's Results
+func (c *Call) WithKey(key string) *Call {
+	c.Key = key
+	return c
+}
+
+// Sets the priority of the call.
+// Calls with a higher priority are run in earlier chunks than lower priority ones, and never share a chunk with them,
+// so latency-sensitive reads can complete before bulk reads that are part of the same batch.
+func (c *Call) WithPriority(priority int) *Call {
+	c.Priority = priority
+	return c
+}
+
+// Sets how long the call may take before it's cancelled.
+// Calls with different timeouts never share a chunk, so slow, expensive view functions can be given a longer deadline
+// in their own chunks without holding up or timing out the fast calls in the same batch.
+func (c *Call) WithTimeout(timeout time.Duration) *Call {
+	c.Timeout = timeout
+	return c
+}
+
+// Sets the expected gas usage of the call.
+// If the batch has a gas limit, calls are split into chunks so the estimates within each chunk (plus the multicall overhead) don't exceed it.
+func (c *Call) WithGasEstimate(gas uint64) *Call {
+	c.GasEstimate = gas
+	return c
+}
+
+// Sets the block to run the call at, overriding the block the batch runs at.
+// Calls at different blocks never share a chunk, so a single batch can compare values across blocks (such as a balance now and 1000 blocks ago)
+// while still returning its results in the order the calls were added.
+func (c *Call) AtBlock(blockNumber *big.Int) *Call {
+	c.BlockNumber = blockNumber
+	return c
+}
+
+// Gets the options to run the call with, which target the call's own block if it has one
+func (c *Call) targetOptions(opts *callOptions) *callOptions {
+	if c.BlockNumber == nil {
+		return opts
+	}
+	callOpts := *opts
+	callOpts.blockNumber = c.BlockNumber
+	callOpts.blockHash = nil
+	callOpts.pending = false
+	return &callOpts
+}
+
+// Unpacks a response into the call's output if the call succeeded
+func (c *Call) unpackResponse(response CallResponse) error {
+	if !response.Status || c.UnpackFunc == nil {
+		return nil
+	}
+	err := c.UnpackFunc(response.ReturnData)
+	if err != nil {
+		return fmt.Errorf("error unpacking response for contract %s, method %s: %w", c.Target.Hex(), c.Method, wrapUnpackError(err))
+	}
+	return nil
+}
+
+// Sets the expected size of the call's ABI-encoded return data, in bytes.
+// Use this for calls that return large dynamic values (such as long arrays) so they can be split into separate chunks.
+func (c *Call) WithReturnSize(size int) *Call {
+	c.ReturnSize = size
+	return c
 }
 
 // The response from a contract call invocation
@@ -53,9 +154,220 @@ type CallResponse struct {
 	ReturnData []byte `json:"returnData"`
 }
 
+// The detailed result of a single call
+type CallResult struct {
+	// The index of the call within the batch
+	Index int
+
+	// The key the call was given, if any
+	Key string
+
+	// The contract address of the call's target
+	Target common.Address
+
+	// The name of the method that was called
+	Method string
+
+	// Whether or not the call worked
+	Success bool
+
+	// The raw return data of the call if it worked, or its revert data if it didn't (which may be empty if the target provided none)
+	ReturnData []byte
+
+	// The gas estimate the call was given (0 = unknown); the multicall contract doesn't report the gas each call actually used
+	GasEstimate uint64
+
+	// Why the call failed: an *ErrCallReverted if it reverted, or an error matching ErrUnpackFailed if its response couldn't be unpacked (nil = it worked)
+	Err error
+
+	// The ABI of the contract that was called, used to decode its custom errors (nil = unknown)
+	contractAbi *abi.ABI
+
+	// The call data that was sent, which identifies the call when a Watcher's snapshot is restored
+	callData []byte
+}
+
+// Gets the decoded revert reason if the call failed, or an empty string if it worked or didn't provide revert data.
+// Custom errors are decoded with the contract's ABI if the call was added with one.
+func (r CallResult) RevertReason() string {
+	if r.Success {
+		return ""
+	}
+	return DecodeRevertReasonWithAbi(r.ReturnData, r.contractAbi)
+}
+
+// Unpacks the call's return data into the provided output, the same way a call's output is unpacked when it's added with AddCall.
+// This lets results that are shared between readers, such as a Watcher's, be decoded into values that each reader owns.
+// The call must have succeeded, and must have been added with its contract's ABI.
+func (r CallResult) Unpack(output any) error {
+	if !r.Success {
+		return r.Err
+	}
+	name, err := r.abiMethodName()
+	if err != nil {
+		return err
+	}
+	err = r.contractAbi.UnpackIntoInterface(output, name, r.ReturnData)
+	if err != nil {
+		return fmt.Errorf("error unpacking response for contract %s, method %s: %w", r.Target.Hex(), r.Method, wrapUnpackError(err))
+	}
+	return nil
+}
+
+// Unpacks the call's return data into the values of the method's outputs, in order
+func (r CallResult) unpackValues() ([]any, error) {
+	name, err := r.abiMethodName()
+	if err != nil {
+		return nil, err
+	}
+	values, err := r.contractAbi.Unpack(name, r.ReturnData)
+	if err != nil {
+		return nil, fmt.Errorf("error unpacking response for contract %s, method %s: %w", r.Target.Hex(), r.Method, wrapUnpackError(err))
+	}
+	return values, nil
+}
+
+// Gets the name of the called method in the contract's ABI
+func (r CallResult) abiMethodName() (string, error) {
+	if r.contractAbi == nil {
+		return "", fmt.Errorf("error unpacking response for contract %s, method %s: the call wasn't added with an ABI", r.Target.Hex(), r.Method)
+	}
+
+	// Calls added by signature are labelled with it rather than the method's name
+	if _, exists := r.contractAbi.Methods[r.Method]; exists {
+		return r.Method, nil
+	}
+	for _, method := range r.contractAbi.Methods {
+		if method.Sig == r.Method {
+			return method.Name, nil
+		}
+	}
+	return "", fmt.Errorf("error unpacking response for contract %s: method %s not found in its ABI", r.Target.Hex(), r.Method)
+}
+
+// The result of a single call, delivered while a batch is still being executed
+type StreamResult struct {
+	// The index of the call within the batch
+	Index int
+
+	// The call that was run
+	Call *Call
+
+	// Whether or not the call worked
+	Success bool
+
+	// The raw return data of the call if it worked, or its revert data if it didn't
+	ReturnData []byte
+
+	// The error from unpacking the call's response into its output, if there was one
+	Err error
+}
+
 // MultiCaller is capable of batching multiple arbitrary contract calls into one and executing them at the same time within a single `eth_call` to the client.
 // It uses MakerDAO's Multicall v2 contract under the hood.
+// If the batch is too large for a single call, it will be split into multiple chunks that are executed separately.
 type MultiCaller struct {
+	// The maximum number of calls to include in a single multicall (0 = no limit)
+	CallBatchSize int
+
+	// The maximum size of a single multicall's packed call data in bytes, for providers that reject large payloads (0 = no limit)
+	CallDataSizeLimit int
+
+	// The maximum expected size of a single multicall response in bytes, based on the return sizes of its calls (0 = no limit)
+	ReturnSizeLimit int
+
+	// The number of chunks to run simultaneously, if the batch is split into multiple chunks (0 = no limit)
+	ThreadLimit int
+
+	// The number of goroutines to unpack responses with (0 or 1 = unpack sequentially).
+	// Only enable this if the unpack function of every call is safe to run concurrently with the others.
+	UnpackThreadLimit int
+
+	// The gas limit for each chunk's eth_call, for nodes whose default cap is too restrictive for large multicalls
+	// or that need an explicit limit. Chunks are also split so the gas estimates of their calls, plus the multicall contract's own overhead,
+	// stay within it (0 = the node's default). Sessions can override it for their own batches with Session.WithGasLimit().
+	GasLimit uint64
+
+	// The default deadline for running each chunk, for calls that don't have their own Timeout (0 = no deadline beyond the one in the context).
+	// If the batch doesn't require success, the calls in a chunk that runs out of time are reported as failed rather than failing the batch.
+	ChunkTimeout time.Duration
+
+	// The context that batches run in when their options don't provide one, such as a context that's cancelled when the application shuts down
+	// (nil = context.Background()). Combined with ChunkTimeout, this gives every request a deadline even when callers don't supply one.
+	BaseContext context.Context
+
+	// Whether the multicall contract only supports Multicall v1's aggregate function rather than tryAggregate, as on some older chains.
+	// Aggregate fails entirely if any of its calls fail, so when a chunk doesn't require success and reverts, its calls are re-run individually
+	// (as the multicall contract, so they see the same msg.sender) to find out which ones failed and why. See DetectAggregateOnly().
+	AggregateOnly bool
+
+	// Whether to detect AggregateOnly automatically before the MultiCaller's first batch through the multicall contract, so the same code works
+	// on old sidechains whose multicall deployments lack tryAggregate. The detection costs one or two extra eth_calls, and only runs once.
+	AutoDetectAggregateOnly bool
+
+	// Whether to retry the calls of a chunk that fails for a reason unrelated to any specific call, such as the provider's gas cap for eth_call
+	// or a response that's too large for it, as individual eth_calls from the multicall contract so the batch still completes, just slower.
+	// Reverts, cancellations, and chunks that run out of time aren't retried, and the retries share the chunk's ChunkTimeout.
+	DirectFallback bool
+
+	// How the calls of each batch are run: aggregated through the multicall contract (the default), as separate eth_calls within JSON-RPC batch requests,
+	// or whichever of the two suits the batch. See ExecutionStrategy.
+	Strategy ExecutionStrategy
+
+	// The fraction of calls (between 0 and 1) to re-run individually with a direct eth_call after each batch, comparing the results against the
+	// multicall's to catch corruption in the aggregation path. A mismatch fails the batch with ErrSpotCheckFailed.
+	// Batches are pinned to a single block so the results are comparable; batches against the pending block or with a sender aren't checked (0 = disabled).
+	SpotCheckRate float64
+
+	// A debug mode for validating the packing and unpacking of new contracts' calls: if set, every batch is also run as individual eth_calls
+	// at the same block, and the differences between the decoded multicall and direct results are passed to the handler (which is called even if there
+	// are none; see ResultDiff.IsEmpty). Unlike SpotCheckRate, mismatches don't fail the batch. This doubles the number of requests,
+	// so it shouldn't be used in production. Batches against the pending block or with a sender aren't shadowed (nil = disabled).
+	ShadowHandler func(diff *ResultDiff)
+
+	// If set, FlexibleCall verifies the responses of calls marked with WithStorageSlot or WithBalanceOf against Merkle proofs
+	// before unpacking them, failing the batch if any were tampered with (nil = responses are trusted as-is)
+	Verifier *LightClientVerifier
+
+	// The executor that runs the batch's requests (nil = no shared executor; see Executor)
+	Executor *Executor
+
+	// Callbacks for logging, metrics, or tracing that are invoked around each batch and chunk (nil = none).
+	// Every batch is given an ID, and each of its chunks an ID derived from it; see Hooks.
+	Hooks *Hooks
+
+	// A name for the endpoint behind the Execution client, such as its provider and region, which is recorded in attestations so audits can tell
+	// which source served the data. It shouldn't include credentials such as API keys embedded in the URL ("" = unnamed).
+	EndpointName string
+
+	// A cache of raw call results, so calls against historical blocks that have already been run are served from it instead of the client (nil = no caching).
+	// NewMultiCaller sets it to a MemoryCache that keeps up to 10,000 entries or 64 MiB; set it to nil to disable caching, or to another cache to replace it.
+	// Only calls against an explicit block number are cached, and only if they succeeded. Blocks near the head can still be replaced by a reorg,
+	// so batches should only target blocks that are final (or otherwise safe from reorgs) while the cache is enabled.
+	Cache IResultCache
+
+	// The policy for caching calls against the latest block, which are otherwise never cached (nil = only calls against explicit blocks are cached).
+	// With a policy, they're run at the latest head it has observed and cached until it observes a new one; see LatestCachePolicy.
+	LatestCache *LatestCachePolicy
+
+	// Whether to plan each batch before running it by merging the calls that read the same thing (the same target and call data at the same block),
+	// so a read that several parts of an application add to one batch, such as a balance requested directly and inside a portfolio query,
+	// is only run once and its response is shared by all of them. The calls that were merged still unpack into their own outputs.
+	// To merge calls across the batches of independent MultiCallers, share an Executor with MergeDuplicateCalls set between them.
+	MergeDuplicateCalls bool
+
+	// The ID of the chain the client is connected to, which is part of every cache key so a single cache can serve several chains
+	// (0 = unspecified, which is fine if the cache only serves one chain)
+	ChainID uint64
+
+	// A second client to send a chunk's request to if the client hasn't responded within HedgeDelay, which cuts the tail latency caused by
+	// slow providers in latency-sensitive batches. The first successful response is used and the other request is cancelled.
+	// Requests that fail with anything but a revert are hedged right away (nil = don't hedge).
+	HedgeClient IContractCaller
+
+	// How long to wait for the client's response before hedging a chunk's request to HedgeClient (0 = send it to both at once)
+	HedgeDelay time.Duration
+
 	// The execution client
 	client IContractCaller
 
@@ -63,12 +375,59 @@ type MultiCaller struct {
 	contractAddress common.Address
 
 	// The collection of calls to batch and execute during the next FlexibleCall()
-	calls []Call
+	calls []*Call
+
+	// The sub-batches waiting for the next run to settle them
+	subBatches []*SubBatch
+
+	// Whether AggregateOnly has been detected from the multicall contract
+	aggregateDetected bool
+
+	// Response buffer that's reused between runs to reduce allocations
+	responses []CallResponse
 }
 
-// Creates a new MultiCaller instance with the provided execution client and address of the multicaller contract
+// Creates a new MultiCaller instance with the provided execution client and address of the multicaller contract.
+// Its Cache is a new MemoryCache with default limits.
 func NewMultiCaller(client IContractCaller, multicallerAddress common.Address) (*MultiCaller, error) {
+	_, err := getMulticallAbi()
+	if err != nil {
+		return nil, err
+	}
 
+	return &MultiCaller{
+		Cache:           NewMemoryCache(defaultMemoryCacheEntries, defaultMemoryCacheBytes),
+		client:          client,
+		contractAddress: multicallerAddress,
+		calls:           []*Call{},
+	}, nil
+}
+
+// Adds a contract call to the batch of calls to query during the next run.
+// The returned call can be used to provide additional hints about it, such as its expected return size.
+func (mc *MultiCaller) AddCall(contractAddress common.Address, abi *abi.ABI, output any, method string, args ...any) *Call {
+	call := newCall(contractAddress, abi, output, method, args...)
+	mc.calls = append(mc.calls, call)
+	return call
+}
+
+// Adds a contract call like AddCall, with a key its result can be looked up by in the batch's Results (see Results.Get and Results.Map).
+// This is the same as calling WithKey on the call AddCall returns.
+func (mc *MultiCaller) AddKeyedCall(key string, contractAddress common.Address, abi *abi.ABI, output any, method string, args ...any) *Call {
+	return mc.AddCall(contractAddress, abi, output, method, args...).WithKey(key)
+}
+
+// Adds a contract call like AddCall, but identifies the method by its full canonical signature (such as "safeTransferFrom(address,address,uint256)")
+// rather than its name, so overloaded methods can be targeted unambiguously.
+// If the ABI has no method with the signature, the error is returned when the batch is run.
+func (mc *MultiCaller) AddCallBySignature(contractAddress common.Address, abi *abi.ABI, output any, signature string, args ...any) *Call {
+	call := newCallBySignature(contractAddress, abi, output, signature, args...)
+	mc.calls = append(mc.calls, call)
+	return call
+}
+
+// Gets the parsed multicall ABI
+func getMulticallAbi() (*abi.ABI, error) {
 	var err error
 	mcOnce.Do(func() {
 		var parsedAbi abi.ABI
@@ -80,21 +439,72 @@ func NewMultiCaller(client IContractCaller, multicallerAddress common.Address) (
 	if err != nil {
 		return nil, err
 	}
+	return &multicallAbi, nil
+}
 
-	return &MultiCaller{
-		client:          client,
-		contractAddress: multicallerAddress,
-		calls:           []Call{},
-	}, nil
+// Creates a copy of the MultiCaller with the same client and settings, but with its own list of pending calls
+func (mc *MultiCaller) withCalls(calls []*Call) *MultiCaller {
+	copy := *mc
+	copy.calls = calls
+	copy.subBatches = nil
+	copy.responses = nil
+	return &copy
 }
 
-// Adds a contract call to the batch of calls to query during the next run
-func (mc *MultiCaller) AddCall(contractAddress common.Address, abi *abi.ABI, output any, method string, args ...any) {
-	call := Call{
-		Target: contractAddress,
-		Method: method,
+// Creates call options from a set of binding options, which may be nil, using the MultiCaller's BaseContext if they don't provide a context
+func (mc *MultiCaller) newCallOptions(opts *bind.CallOpts) *callOptions {
+	return newCallOptionsWithBase(opts, mc.BaseContext)
+}
+
+// Gets the latest block number from the multicall contract
+func (mc *MultiCaller) getLatestBlockNumber(ctx context.Context) (*big.Int, error) {
+	return mc.getBlockNumber(&callOptions{ctx: ctx})
+}
+
+// Gets the number of the block targeted by the options from the multicall contract, which resolves block hashes to numbers
+func (mc *MultiCaller) getBlockNumber(opts *callOptions) (*big.Int, error) {
+	callData, err := multicallAbi.Pack("getBlockNumber")
+	if err != nil {
+		return nil, fmt.Errorf("error packing block number call data: %w", err)
+	}
+	ctx, cancel := withRequestTimeout(opts.ctx, mc.ChunkTimeout)
+	defer cancel()
+	response, err := opts.callContract(ctx, mc.client, ethereum.CallMsg{To: &mc.contractAddress, Data: callData})
+	if err != nil {
+		return nil, fmt.Errorf("error getting number of block %s: %w", opts.blockDescription(), wrapClientError(err))
+	}
+	var blockNumber *big.Int
+	err = multicallAbi.UnpackIntoInterface(&blockNumber, "getBlockNumber", response)
+	if err != nil {
+		return nil, fmt.Errorf("error unpacking latest block number: %w", wrapUnpackError(err))
+	}
+	return blockNumber, nil
+}
+
+// Creates the call data for each call that has a pack function
+func packCalls(calls []*Call) error {
+	for _, call := range calls {
+		if call.PackFunc == nil {
+			continue
+		}
+		callData, err := call.PackFunc()
+		if err != nil {
+			return err
+		}
+		call.CallData = callData
+	}
+	return nil
+}
+
+// Creates a new contract call wrapper
+func newCall(contractAddress common.Address, abi *abi.ABI, output any, method string, args ...any) *Call {
+	return &Call{
+		Target:      contractAddress,
+		Method:      method,
+		Output:      output,
+		contractAbi: abi,
 		PackFunc: func() ([]byte, error) {
-			callData, err := abi.Pack(method, args...)
+			callData, err := packCall(abi, method, args...)
 			if err != nil {
 				return nil, fmt.Errorf("error packing data for call [%s] on contract %s: %w", method, contractAddress.Hex(), err)
 			}
@@ -104,65 +514,503 @@ func (mc *MultiCaller) AddCall(contractAddress common.Address, abi *abi.ABI, out
 			return abi.UnpackIntoInterface(output, method, rawData)
 		},
 	}
-	mc.calls = append(mc.calls, call)
+}
+
+// Creates a new contract call wrapper for the method with the provided canonical signature
+func newCallBySignature(contractAddress common.Address, abi *abi.ABI, output any, signature string, args ...any) *Call {
+	signature = strings.ReplaceAll(signature, " ", "")
+	for name, method := range abi.Methods {
+		if method.Sig == signature {
+			call := newCall(contractAddress, abi, output, name, args...)
+			call.Method = signature
+			return call
+		}
+	}
+	return &Call{
+		Target:      contractAddress,
+		Method:      signature,
+		Output:      output,
+		contractAbi: abi,
+		PackFunc: func() ([]byte, error) {
+			return nil, fmt.Errorf("error packing data for call [%s] on contract %s: method with signature '%s' not found", signature, contractAddress.Hex(), signature)
+		},
+	}
 }
 
 // Invokes all of the previously batched up contract calls in a single call.
 // If requireSuccess is true, a single error will cause all of the calls to fail.
 // If false, the calls can run independently and you will be given a list of resulting success or fail flags for each call.
+// Reverted calls are never reported as an error in that case; use FlexibleCallWithResults to get their revert reasons.
+// An error is only returned if the batch itself fails, or if a successful call's response can't be unpacked (as a *MultiError).
+// If the batch exceeds the MultiCaller's limits, it will be split into multiple chunks; requireSuccess then applies to each chunk individually.
+// If any chunk fails, the outstanding chunks are cancelled through the context in opts (if provided), which also supports deadlines.
+// If opts specifies a From address, each call is run individually with that sender instead of through the multicall contract,
+// since contracts called via multicall would otherwise see the multicall contract as msg.sender.
+// If opts sets Pending, the calls are run against the pending block; the client must implement IPendingContractCaller.
+// If opts was created by CallOptsAtHash, the calls are run against that block; the client must implement IContractCallerAtHash.
+// If the MultiCaller has a Verifier, the batch is pinned to a trusted block and any verifiable responses are checked against proofs.
 // Upon completion, the internal list of batched up contract calls will be cleared.
 func (mc *MultiCaller) FlexibleCall(requireSuccess bool, opts *bind.CallOpts) ([]bool, error) {
+	return mc.flexibleCall(requireSuccess, mc.newCallOptions(opts))
+}
+
+// Invokes all of the previously batched up contract calls like FlexibleCall, but against the block with the provided hash (EIP-1898)
+// rather than a block number, so the results remain pinned to a known block even across shallow reorgs.
+// The client must implement IContractCallerAtHash. The block number in opts, if provided, is ignored.
+func (mc *MultiCaller) FlexibleCallAtHash(requireSuccess bool, blockHash common.Hash, opts *bind.CallOpts) ([]bool, error) {
+	options := mc.newCallOptions(opts)
+	options.blockHash = &blockHash
+	return mc.flexibleCall(requireSuccess, options)
+}
+
+// Implementation of FlexibleCall
+func (mc *MultiCaller) flexibleCall(requireSuccess bool, opts *callOptions) ([]bool, error) {
+	successes, _, err := mc.flexibleCallWithResponses(requireSuccess, opts)
+	return successes, err
+}
+
+// Invokes all of the previously batched up contract calls like FlexibleCall, but returns the detailed result of each call instead of only its success flag.
+// Unlike FlexibleCall, this includes the revert data of calls that failed when requireSuccess is false,
+// so callers can decode custom errors themselves or log the exact revert payload.
+// The results are returned in the same order as the calls were added, and are the same as the ones FlexibleCallResults returns;
+// if some of the responses can't be unpacked, they're still returned along with the *MultiError describing them.
+func (mc *MultiCaller) FlexibleCallWithResults(requireSuccess bool, opts *bind.CallOpts) ([]CallResult, error) {
+	results, err := mc.FlexibleCallResults(requireSuccess, opts)
+	if results == nil {
+		return nil, err
+	}
+	return results.All(), err
+}
+
+// Implementation of FlexibleCall that also returns the raw responses.
+// The responses may be stored in the MultiCaller's reusable buffer, so they're only valid until the next run.
+func (mc *MultiCaller) flexibleCallWithResponses(requireSuccess bool, opts *callOptions) ([]bool, []CallResponse, error) {
+	successes, responses, err := mc.runBatch(requireSuccess, opts)
+	if err != nil {
+		return nil, nil, err
+	}
+	return successes, responses, nil
+}
+
+// Runs the batched up calls and unpacks their responses.
+// If only some of the responses couldn't be unpacked, the responses are still returned along with the *MultiError describing them.
+func (mc *MultiCaller) runBatch(requireSuccess bool, opts *callOptions) ([]bool, []CallResponse, error) {
 	if len(mc.calls) == 0 {
-		return []bool{}, nil
+		mc.settleSubBatches(nil, nil, nil, nil)
+		return []bool{}, []CallResponse{}, nil
 	}
-	res := make([]bool, len(mc.calls))
 
 	// Create the CallData for each call
-	for i, call := range mc.calls {
-		callData, err := call.PackFunc()
+	stats := mc.startBatchStats(len(mc.calls))
+	err := packCalls(mc.calls)
+	stats.endStage(packStage)
+	if err != nil {
+		stats.finish(opts.ctx, mc.calls, nil, err)
+		mc.settleSubBatches(nil, nil, nil, err)
+		return nil, nil, err
+	}
+
+	// Run the calls
+	results, err := mc.executeVerified(mc.calls, requireSuccess, opts)
+	stats.endStage(executeStage)
+	if err != nil {
+		stats.finish(opts.ctx, mc.calls, nil, err)
+		mc.settleSubBatches(nil, nil, nil, err)
+		return nil, nil, err
+	}
+
+	// Unpack the individual call results per function
+	res, err := mc.unpackResponses(mc.calls, results)
+	stats.endStage(unpackStage)
+	stats.finish(opts.ctx, mc.calls, results, err)
+	mc.settleSubBatches(mc.calls, results, getUnpackErrors(len(mc.calls), err), nil)
+
+	// Reset the call list
+	mc.calls = []*Call{}
+	if err != nil {
+		return nil, results, err
+	}
+	return res, results, nil
+}
+
+// Unpacks the responses of successful calls into their outputs, returning the success flag of each call.
+// If UnpackThreadLimit is set, the responses are unpacked concurrently.
+// If any of the calls fail to unpack, a *MultiError is returned that describes every call that failed to unpack.
+// Reverted calls are only reported through their success flags, so whether or not the batch fails never depends on them.
+func (mc *MultiCaller) unpackResponses(calls []*Call, responses []CallResponse) ([]bool, error) {
+	successes := make([]bool, len(calls))
+	errs := make([]error, len(calls))
+	workers := mc.UnpackThreadLimit
+	if workers > len(calls) {
+		workers = len(calls)
+	}
+
+	if workers <= 1 {
+		// Unpack sequentially
+		for i, call := range calls {
+			errs[i] = call.unpackResponse(responses[i])
+			successes[i] = responses[i].Status
+		}
+	} else {
+		// Unpack with a pool of workers that each grab the next unhandled response
+		var next int64 = -1
+		var wg sync.WaitGroup
+		wg.Add(workers)
+		for w := 0; w < workers; w++ {
+			go func() {
+				defer wg.Done()
+				for {
+					i := int(atomic.AddInt64(&next, 1))
+					if i >= len(calls) {
+						return
+					}
+					errs[i] = calls[i].unpackResponse(responses[i])
+					successes[i] = responses[i].Status
+				}
+			}()
+		}
+		wg.Wait()
+	}
+
+	for _, err := range errs {
 		if err != nil {
-			return nil, err
+			return nil, newMultiError(calls, errs)
 		}
-		mc.calls[i].CallData = callData
 	}
+	return successes, nil
+}
 
-	// Prep the multicall args
-	callData, err := multicallAbi.Pack("tryAggregate", requireSuccess, mc.calls)
+// Creates a MultiError describing every call that failed to unpack
+func newMultiError(calls []*Call, unpackErrs []error) *MultiError {
+	multiErr := &MultiError{}
+	for i, call := range calls {
+		err := unpackErrs[i]
+		if err != nil {
+			multiErr.Errors = append(multiErr.Errors, &CallError{
+				Index:  i,
+				Target: call.Target,
+				Method: call.Method,
+				Err:    err,
+			})
+		}
+	}
+	return multiErr
+}
+
+// Invokes all of the previously batched up contract calls like FlexibleCall, but rather than waiting for the entire batch to finish,
+// the result of each call is delivered to the handler as soon as the chunk containing it returns.
+// Results within a chunk are delivered in order, but chunks may complete in any order.
+// The handler is never invoked concurrently, so it doesn't need to be thread-safe.
+// Errors unpacking an individual call's response are delivered to the handler rather than stopping the batch.
+// If the MultiCaller has a Verifier, a SpotCheckRate, or a ShadowHandler, or merges duplicate calls (on its own or through its Executor),
+// the results are only delivered once the whole batch has been run and verified.
+// Upon completion, the internal list of batched up contract calls will be cleared.
+func (mc *MultiCaller) StreamCall(requireSuccess bool, opts *bind.CallOpts, handler func(StreamResult)) error {
+	return mc.streamCall(requireSuccess, mc.newCallOptions(opts), handler)
+}
+
+// Implementation of StreamCall
+func (mc *MultiCaller) streamCall(requireSuccess bool, opts *callOptions, handler func(StreamResult)) error {
+	if len(mc.calls) == 0 {
+		mc.settleSubBatches(nil, nil, nil, nil)
+		return nil
+	}
+
+	// Create the CallData for each call
+	err := packCalls(mc.calls)
 	if err != nil {
-		return nil, fmt.Errorf("error packing aggregated call data: %w", err)
+		mc.settleSubBatches(nil, nil, nil, err)
+		return err
 	}
 
-	// Invoke the multicall function
+	// Responses can only be verified (or planned with other calls) once the whole batch is done, so they're delivered together afterwards
+	unpackErrs := make([]error, len(mc.calls))
+	if mc.Verifier != nil || mc.SpotCheckRate > 0 || mc.ShadowHandler != nil || mc.MergeDuplicateCalls || mc.Executor.sharesCalls() {
+		responses, err := mc.executeVerified(mc.calls, requireSuccess, opts)
+		if err != nil {
+			mc.settleSubBatches(nil, nil, nil, err)
+			return err
+		}
+		for i, call := range mc.calls {
+			unpackErrs[i] = call.unpackResponse(responses[i])
+			handler(StreamResult{
+				Index:      i,
+				Call:       call,
+				Success:    responses[i].Status,
+				ReturnData: responses[i].ReturnData,
+				Err:        unpackErrs[i],
+			})
+		}
+		mc.settleSubBatches(mc.calls, responses, unpackErrs, nil)
+		mc.calls = []*Call{}
+		return nil
+	}
+
+	// Run the calls, unpacking each chunk as soon as it's done
+	var handlerLock sync.Mutex
+	responses, err := mc.executeChunks(mc.calls, requireSuccess, opts, func(chunk callChunk, responses []CallResponse) {
+		handlerLock.Lock()
+		defer handlerLock.Unlock()
+		for i, call := range chunk.calls {
+			index := chunk.indices[i]
+			unpackErrs[index] = call.unpackResponse(responses[i])
+			handler(StreamResult{
+				Index:      index,
+				Call:       call,
+				Success:    responses[i].Status,
+				ReturnData: responses[i].ReturnData,
+				Err:        unpackErrs[index],
+			})
+		}
+	})
+	if err != nil {
+		mc.settleSubBatches(nil, nil, nil, err)
+		return err
+	}
+	mc.settleSubBatches(mc.calls, responses, unpackErrs, nil)
+
+	// Reset the call list
+	mc.calls = []*Call{}
+	return nil
+}
+
+// Runs the calls like executeChunks, but if the MultiCaller has a Verifier, the batch is pinned to a trusted block
+// and the responses are checked against proofs before they're returned. If the MultiCaller has a Cache, the calls with cached results are served from it.
+// Duplicate calls are merged first if MergeDuplicateCalls is set, and shared with other batches if the Executor merges them.
+func (mc *MultiCaller) executeVerified(calls []*Call, requireSuccess bool, opts *callOptions) ([]CallResponse, error) {
+	if mc.MergeDuplicateCalls {
+		distinct, sources := mergeDuplicateCalls(calls)
+		if len(distinct) < len(calls) {
+			return mc.executeMerged(calls, distinct, sources, requireSuccess, opts)
+		}
+	}
+	keys := mc.getSharedCallKeys(calls, opts)
+	if keys != nil {
+		return mc.executeShared(calls, keys, requireSuccess, opts)
+	}
+	return mc.executeUnshared(calls, requireSuccess, opts)
+}
+
+// Runs the calls like executeVerified, without sharing them with other batches
+func (mc *MultiCaller) executeUnshared(calls []*Call, requireSuccess bool, opts *callOptions) ([]CallResponse, error) {
+	if mc.Cache != nil {
+		return mc.executeCached(calls, requireSuccess, opts)
+	}
+	return mc.executeUncached(calls, requireSuccess, opts)
+}
+
+// Runs the calls like executeVerified, without checking the cache
+func (mc *MultiCaller) executeUncached(calls []*Call, requireSuccess bool, opts *callOptions) ([]CallResponse, error) {
+	if mc.Verifier == nil && mc.SpotCheckRate <= 0 && mc.ShadowHandler == nil {
+		return mc.executeChunks(calls, requireSuccess, opts, nil)
+	}
+
+	// Pin the batch to a single block, which has to be a trusted one if the responses are verified against proofs
+	if mc.Verifier != nil {
+		for _, call := range calls {
+			if call.BlockNumber != nil {
+				return nil, fmt.Errorf("contract %s, method %s has its own block, so it can't be verified against the batch's trusted block", call.Target.Hex(), call.Method)
+			}
+		}
+	}
+	pinned := *opts
 	var blockNumber *big.Int
-	if opts != nil {
-		blockNumber = opts.BlockNumber
+	var stateRoot common.Hash
+	var err error
+	if mc.Verifier != nil {
+		blockNumber, stateRoot, err = mc.Verifier.pinBlock(&pinned)
+	} else {
+		err = mc.pinSpotCheck(&pinned)
 	}
-	resp, err := mc.client.CallContract(context.Background(), ethereum.CallMsg{To: &mc.contractAddress, Data: callData}, blockNumber)
 	if err != nil {
-		return nil, fmt.Errorf("error calling multicall contract: %w", err)
+		return nil, err
 	}
 
-	// Unpack the multicall output
-	results := make([]CallResponse, len(mc.calls))
-	err = multicallAbi.UnpackIntoInterface(&results, "tryAggregate", resp)
+	// Run the calls and make sure the responses match the proven state and the sampled direct calls
+	responses, err := mc.executeChunks(calls, requireSuccess, &pinned, nil)
 	if err != nil {
-		return nil, fmt.Errorf("error unpacking aggregated response data: %w", err)
+		return nil, err
+	}
+	if mc.Verifier != nil {
+		err = mc.Verifier.verifyResponses(pinned.ctx, calls, responses, blockNumber, stateRoot)
+		if err != nil {
+			return nil, err
+		}
 	}
+	if mc.SpotCheckRate > 0 {
+		err = mc.spotCheck(calls, responses, &pinned)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if mc.ShadowHandler != nil {
+		err = mc.shadowValidate(calls, responses, &pinned)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return responses, nil
+}
 
-	// Unpack the individual call results per function
-	for i, c := range mc.calls {
-		callSuccess := results[i].Status
-		if callSuccess {
-			err := c.UnpackFunc(results[i].ReturnData)
-			if err != nil {
-				mc.calls = []Call{}
-				return nil, fmt.Errorf("error unpacking response for contract %s, method %s: %w", c.Target.Hex(), c.Method, err)
+// Splits the calls into chunks and runs each one against the multicall contract, as a batch with its own ID that's reported to the hooks.
+// The responses are returned in the same order as the provided calls.
+// If provided, onChunk is called with the responses of each chunk as soon as it completes.
+func (mc *MultiCaller) executeChunks(calls []*Call, requireSuccess bool, opts *callOptions, onChunk func(chunk callChunk, responses []CallResponse)) ([]CallResponse, error) {
+	opts, endBatch := mc.startBatch(opts, len(calls))
+	responses, err := mc.runChunks(calls, requireSuccess, opts, onChunk)
+	return responses, endBatch(err)
+}
+
+// Implementation of executeChunks
+func (mc *MultiCaller) runChunks(calls []*Call, requireSuccess bool, opts *callOptions, onChunk func(chunk callChunk, responses []CallResponse)) ([]CallResponse, error) {
+	if mc.selectStrategy(calls, opts) == StrategyRpcBatch {
+		return mc.executeRpcBatches(calls, requireSuccess, opts, onChunk)
+	}
+
+	// Calls run through the multicall contract see it as msg.sender, so they have to run individually to honor a specific sender
+	if opts.from != (common.Address{}) {
+		return mc.executeDirect(calls, requireSuccess, opts, onChunk)
+	}
+
+	// Find out which aggregation function the contract supports before it's first used
+	if mc.AutoDetectAggregateOnly && !mc.aggregateDetected {
+		err := mc.detectAggregateOnly(&callOptions{ctx: opts.ctx})
+		if err != nil {
+			return nil, fmt.Errorf("error detecting the multicall contract's aggregation function: %w", err)
+		}
+	}
+
+	// Reuse the response buffer from the previous run if it's big enough.
+	// Responses are stored in chunk order, which matches the call order unless some calls have a higher priority.
+	if cap(mc.responses) < len(calls) {
+		mc.responses = make([]CallResponse, len(calls))
+	}
+	responses := mc.responses[:len(calls)]
+	chunks, reordered := mc.chunkCalls(calls, mc.gasLimit(opts))
+
+	offsets := make([]int, len(chunks))
+	offset := 0
+	for i, chunk := range chunks {
+		offsets[i] = offset
+		offset += len(chunk.calls)
+	}
+
+	// A failure in any chunk cancels the rest of them
+	err := mc.Executor.run(opts.ctx, mc.ThreadLimit, "eth_call", len(chunks), nil, func(ctx context.Context, i int) error {
+		chunk := chunks[i]
+		chunkResponses := responses[offsets[i] : offsets[i]+len(chunk.calls)]
+		chunkCtx := ctx
+		timeout := chunk.timeout(mc.ChunkTimeout)
+		if timeout > 0 {
+			var cancel context.CancelFunc
+			chunkCtx, cancel = context.WithTimeout(ctx, timeout)
+			defer cancel()
+		}
+		err := mc.runChunk(chunkCtx, i, len(chunk.calls), func(ctx context.Context) error {
+			chunkOpts := chunk.calls[0].targetOptions(opts)
+			err := mc.executeChunk(ctx, chunk, requireSuccess, chunkOpts, chunkResponses)
+			if err != nil && mc.DirectFallback && isChunkFailure(ctx, err) {
+				return mc.executeChunkDirectly(ctx, chunk, requireSuccess, chunkOpts, chunkResponses)
 			}
+			return err
+		})
+		if err != nil {
+			if requireSuccess || !exceededOwnDeadline(ctx, chunkCtx) {
+				return err
+			}
+
+			// A tolerant batch reports the calls in a chunk that ran out of time as failures instead of giving up on the rest
+			for i := range chunkResponses {
+				chunkResponses[i] = CallResponse{}
+			}
+		}
+		if onChunk != nil {
+			onChunk(chunk, chunkResponses)
 		}
-		res[i] = callSuccess
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if !reordered {
+		return responses, nil
 	}
 
-	// Reset the call list
-	mc.calls = []Call{}
-	return res, err
+	// Put the responses back into the original call order
+	results := make([]CallResponse, len(calls))
+	offset = 0
+	for _, chunk := range chunks {
+		for i, index := range chunk.indices {
+			results[index] = responses[offset+i]
+		}
+		offset += len(chunk.calls)
+	}
+	return results, nil
+}
+
+// Checks if a chunk or call's context was cancelled by its own deadline, rather than by the batch's context it was derived from
+func exceededOwnDeadline(parent context.Context, ctx context.Context) bool {
+	return ctx.Err() == context.DeadlineExceeded && parent.Err() == nil
+}
+
+// Gets the gas limit to run each chunk with, preferring the override in the call options over the MultiCaller's own limit
+func (mc *MultiCaller) gasLimit(opts *callOptions) uint64 {
+	if opts.gasLimit > 0 {
+		return opts.gasLimit
+	}
+	return mc.GasLimit
+}
+
+// Runs a single chunk of calls against the multicall contract, storing the responses in the provided results slice
+func (mc *MultiCaller) executeChunk(ctx context.Context, chunk callChunk, requireSuccess bool, opts *callOptions, results []CallResponse) error {
+	if mc.AggregateOnly {
+		return mc.executeAggregateChunk(ctx, chunk, requireSuccess, opts, results)
+	}
+
+	// Prep the multicall args
+	callData := encodeTryAggregate(requireSuccess, chunk.calls)
+
+	// Invoke the multicall function
+	resp, err := mc.callChunkContract(ctx, opts, ethereum.CallMsg{To: &mc.contractAddress, Gas: mc.gasLimit(opts), Data: callData}, len(chunk.calls))
+	if err != nil {
+		if requireSuccess {
+			revertData, isRevert := getRevertData(err)
+			if isRevert {
+				return chunk.revertError(revertData)
+			}
+		}
+		return fmt.Errorf("error calling multicall contract at block %s: %w", opts.blockDescription(), wrapClientError(err))
+	}
+
+	// Unpack the multicall output
+	err = decodeTryAggregate(resp, results)
+	if err != nil {
+		return fmt.Errorf("error unpacking aggregated response data: %w", wrapUnpackError(err))
+	}
+	return nil
+}
+
+// Runs a single chunk of calls against a Multicall v1 contract's aggregate function, storing the responses in the provided results slice.
+// If the chunk reverts and doesn't require success, each call is re-run on its own to get its individual status and revert data.
+func (mc *MultiCaller) executeAggregateChunk(ctx context.Context, chunk callChunk, requireSuccess bool, opts *callOptions, results []CallResponse) error {
+	callData := encodeAggregate(chunk.calls)
+	resp, err := mc.callChunkContract(ctx, opts, ethereum.CallMsg{To: &mc.contractAddress, Gas: mc.gasLimit(opts), Data: callData}, len(chunk.calls))
+	if err != nil {
+		revertData, isRevert := getRevertData(err)
+		if !isRevert {
+			return fmt.Errorf("error calling multicall contract at block %s: %w", opts.blockDescription(), wrapClientError(err))
+		}
+		if requireSuccess {
+			return chunk.revertError(revertData)
+		}
+
+		// Run the calls on their own to find out which ones failed
+		return mc.executeChunkDirectly(ctx, chunk, false, opts, results)
+	}
+
+	err = decodeAggregate(resp, results)
+	if err != nil {
+		return fmt.Errorf("error unpacking aggregated response data: %w", wrapUnpackError(err))
+	}
+	return nil
 }
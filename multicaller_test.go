@@ -0,0 +1,321 @@
+package batchquery
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+// Creates a hook that blocks calls to the list method until their context is cancelled
+func blockListCalls() func(ctx context.Context, msg ethereum.CallMsg) error {
+	selector := testTokenAbi.Methods["list"].ID
+	return func(ctx context.Context, msg ethereum.CallMsg) error {
+		if !bytes.Contains(msg.Data, selector) {
+			return nil
+		}
+		<-ctx.Done()
+		return ctx.Err()
+	}
+}
+
+func TestTolerantBatchReportsTimedOutChunksAsFailed(t *testing.T) {
+	mc, client := newTestMultiCaller(t)
+	client.hook = blockListCalls()
+
+	var balance *big.Int
+	var list []*big.Int
+	mc.AddCall(testTokenAddress, &testTokenAbi, &balance, "balanceOf", common.HexToAddress("0x05"))
+	mc.AddCall(testTokenAddress, &testTokenAbi, &list, "list", big.NewInt(2)).WithTimeout(10 * time.Millisecond)
+	success, err := mc.FlexibleCall(false, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !success[0] || balance.Int64() != 5 {
+		t.Fatalf("expected the fast call to succeed, got %v with %s", success[0], balance)
+	}
+	if success[1] {
+		t.Fatal("expected the timed-out call to be reported as failed")
+	}
+
+	// A batch that requires success still fails
+	mc.AddCall(testTokenAddress, &testTokenAbi, &list, "list", big.NewInt(2)).WithTimeout(10 * time.Millisecond)
+	_, err = mc.FlexibleCall(true, nil)
+	if err == nil {
+		t.Fatal("expected a timed-out chunk to fail a batch that requires success")
+	}
+}
+
+func TestTolerantBatchFailsWhenBatchContextExpires(t *testing.T) {
+	mc, client := newTestMultiCaller(t)
+	client.hook = blockListCalls()
+
+	var list []*big.Int
+	mc.AddCall(testTokenAddress, &testTokenAbi, &list, "list", big.NewInt(2)).WithTimeout(time.Hour)
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	_, err := mc.FlexibleCall(false, &bind.CallOpts{Context: ctx})
+	if err == nil {
+		t.Fatal("expected the batch's own deadline to fail the batch")
+	}
+}
+
+func TestBaseContextAppliesToBatchesWithoutContext(t *testing.T) {
+	mc, _ := newTestMultiCaller(t)
+	baseContext, cancel := context.WithCancel(context.Background())
+	cancel()
+	mc.BaseContext = baseContext
+
+	var balance *big.Int
+	mc.AddCall(testTokenAddress, &testTokenAbi, &balance, "balanceOf", common.HexToAddress("0x05"))
+	_, err := mc.FlexibleCall(true, nil)
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected the cancelled base context to stop the batch, got %v", err)
+	}
+
+	// A context in the options takes precedence over the base context
+	mc.AddCall(testTokenAddress, &testTokenAbi, &balance, "balanceOf", common.HexToAddress("0x05"))
+	_, err = mc.FlexibleCall(true, &bind.CallOpts{Context: context.Background()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if balance.Int64() != 5 {
+		t.Fatalf("expected a balance of 5, got %s", balance)
+	}
+}
+
+func TestTolerantBatchOnlyReportsUnpackFailures(t *testing.T) {
+	mc, _ := newTestMultiCaller(t)
+	var balance *big.Int
+	var wrongType string
+	var boom *big.Int
+	mc.AddCall(testTokenAddress, &testTokenAbi, &balance, "balanceOf", common.HexToAddress("0x05"))
+	mc.AddCall(testTokenAddress, &testTokenAbi, &boom, "boom")
+	_, err := mc.FlexibleCall(false, nil)
+	if err != nil {
+		t.Fatalf("expected a revert not to fail a tolerant batch, got %v", err)
+	}
+
+	mc.AddCall(testTokenAddress, &testTokenAbi, &wrongType, "balanceOf", common.HexToAddress("0x05"))
+	mc.AddCall(testTokenAddress, &testTokenAbi, &boom, "boom")
+	mc.AddCall(testTokenAddress, &testTokenAbi, &balance, "balanceOf", common.HexToAddress("0x06"))
+	_, err = mc.FlexibleCall(false, nil)
+	var multiErr *MultiError
+	if !errors.As(err, &multiErr) {
+		t.Fatalf("expected a MultiError, got %v", err)
+	}
+	if len(multiErr.Errors) != 1 || multiErr.Errors[0].Index != 0 || multiErr.Errors[0].Method != "balanceOf" {
+		t.Fatalf("expected only the unpack failure of call 0 to be reported, got %v", multiErr)
+	}
+	var reverted *ErrCallReverted
+	if errors.As(err, &reverted) {
+		t.Fatal("expected the reverted call not to be reported")
+	}
+}
+
+func TestConcurrentUnpackReportsFailuresInOrder(t *testing.T) {
+	mc, client := newTestMultiCaller(t)
+	mc.CallBatchSize = 4
+	mc.ThreadLimit = 3
+	mc.UnpackThreadLimit = 4
+
+	// Spread unpack failures over several chunks, so the workers and chunks finish in any order
+	failing := map[int]bool{1: true, 5: true, 6: true, 11: true, 14: true}
+	balances := make([]*big.Int, 16)
+	wrongTypes := make([]string, 16)
+	for i := range balances {
+		account := common.BigToAddress(big.NewInt(int64(i + 1)))
+		if failing[i] {
+			mc.AddCall(testTokenAddress, &testTokenAbi, &wrongTypes[i], "balanceOf", account)
+		} else {
+			mc.AddCall(testTokenAddress, &testTokenAbi, &balances[i], "balanceOf", account)
+		}
+	}
+	_, err := mc.FlexibleCall(false, nil)
+	if len(client.chunkSizes) != 4 {
+		t.Fatalf("expected the batch to run in 4 chunks, got %v", client.chunkSizes)
+	}
+	var multiErr *MultiError
+	if !errors.As(err, &multiErr) {
+		t.Fatalf("expected a MultiError, got %v", err)
+	}
+	indices := []int{}
+	for _, callErr := range multiErr.Errors {
+		indices = append(indices, callErr.Index)
+	}
+	if fmt.Sprint(indices) != "[1 5 6 11 14]" {
+		t.Fatalf("expected the failures in call order, got %v", indices)
+	}
+	for i, balance := range balances {
+		account := common.BigToAddress(big.NewInt(int64(i + 1)))
+		if !failing[i] && balance.Cmp(expectedBalance(account, 0)) != 0 {
+			t.Fatalf("expected call %d to be unpacked, got %s", i, balance)
+		}
+	}
+}
+
+func TestFlexibleCallWithResultsReportsRevertReasons(t *testing.T) {
+	mc, _ := newTestMultiCaller(t)
+	var balance *big.Int
+	var boom *big.Int
+	mc.AddCall(testTokenAddress, &testTokenAbi, &balance, "balanceOf", common.HexToAddress("0x05"))
+	mc.AddCall(testTokenAddress, &testTokenAbi, &boom, "boom")
+	results, err := mc.FlexibleCallWithResults(false, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !results[0].Success || results[1].Success {
+		t.Fatalf("expected only the first call to succeed, got %+v", results)
+	}
+	if reason := results[1].RevertReason(); reason != "boom" {
+		t.Fatalf("expected the revert reason to be boom, got %q", reason)
+	}
+
+	// A batch that requires success reports the revert as an error
+	mc.AddCall(testTokenAddress, &testTokenAbi, &boom, "boom")
+	_, err = mc.FlexibleCall(true, nil)
+	var reverted *ErrCallReverted
+	if !errors.As(err, &reverted) || reverted.Reason() != "boom" {
+		t.Fatalf("expected a revert error with reason boom, got %v", err)
+	}
+}
+
+func TestMixedBlockBatch(t *testing.T) {
+	mc, client := newTestMultiCaller(t)
+	account := common.HexToAddress("0x05")
+
+	balances := make([]*big.Int, 4)
+	mc.AddCall(testTokenAddress, &testTokenAbi, &balances[0], "balanceOf", account)
+	mc.AddCall(testTokenAddress, &testTokenAbi, &balances[1], "balanceOf", account).AtBlock(big.NewInt(50))
+	mc.AddCall(testTokenAddress, &testTokenAbi, &balances[2], "balanceOf", account).AtBlock(big.NewInt(30))
+	mc.AddCall(testTokenAddress, &testTokenAbi, &balances[3], "balanceOf", account).AtBlock(big.NewInt(50))
+	_, err := mc.FlexibleCall(true, &bind.CallOpts{BlockNumber: big.NewInt(1000)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, block := range []int64{1000, 50, 30, 50} {
+		if balances[i].Cmp(expectedBalance(account, block)) != 0 {
+			t.Fatalf("expected call %d to run at block %d, got %s", i, block, balances[i])
+		}
+	}
+
+	// One multicall is run for each block
+	sizes := client.getChunkSizes()
+	if len(sizes) != 3 {
+		t.Fatalf("expected 3 chunks, got %v", sizes)
+	}
+}
+
+func TestMixedBlockBatchWithSender(t *testing.T) {
+	mc, _ := newTestMultiCaller(t)
+	account := common.HexToAddress("0x05")
+
+	var now, before *big.Int
+	mc.AddCall(testTokenAddress, &testTokenAbi, &now, "balanceOf", account)
+	mc.AddCall(testTokenAddress, &testTokenAbi, &before, "balanceOf", account).AtBlock(big.NewInt(20))
+	_, err := mc.FlexibleCall(true, &bind.CallOpts{From: common.HexToAddress("0x09")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if now.Cmp(expectedBalance(account, 0)) != 0 || before.Cmp(expectedBalance(account, 20)) != 0 {
+		t.Fatalf("expected the balances at the latest block and block 20, got %s and %s", now, before)
+	}
+}
+
+func TestAddCallBySignatureTargetsOverload(t *testing.T) {
+	overloaded := mustParseAbi(`[
+		{"inputs":[{"name":"account","type":"address"}],"name":"balanceOf","outputs":[{"name":"","type":"uint256"}],"stateMutability":"view","type":"function"},
+		{"inputs":[{"name":"account","type":"address"},{"name":"id","type":"uint256"}],"name":"balanceOf","outputs":[{"name":"","type":"uint256"}],"stateMutability":"view","type":"function"}
+	]`)
+	mc, _ := newTestMultiCaller(t)
+	var first, second *big.Int
+	account := common.HexToAddress("0x05")
+	mc.AddCallBySignature(testTokenAddress, &overloaded, &first, "balanceOf(address)", account)
+	mc.AddCallBySignature(testTokenAddress, &overloaded, &second, "balanceOf(address, uint256)", account, big.NewInt(7))
+	err := packCalls(mc.calls)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(mc.calls[0].CallData[:4], crypto.Keccak256([]byte("balanceOf(address)"))[:4]) {
+		t.Fatalf("expected the single-argument overload, got selector %x", mc.calls[0].CallData[:4])
+	}
+	if !bytes.Equal(mc.calls[1].CallData[:4], crypto.Keccak256([]byte("balanceOf(address,uint256)"))[:4]) {
+		t.Fatalf("expected the two-argument overload, got selector %x", mc.calls[1].CallData[:4])
+	}
+	if mc.calls[1].Method != "balanceOf(address,uint256)" {
+		t.Fatalf("expected the call to be described by its signature, got %s", mc.calls[1].Method)
+	}
+
+	// The mock token only implements the single-argument overload
+	success, err := mc.FlexibleCall(false, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !success[0] || success[1] {
+		t.Fatalf("expected only the single-argument overload to succeed, got %v", success)
+	}
+	if first.Cmp(expectedBalance(account, 0)) != 0 {
+		t.Fatalf("expected balance %s, got %s", expectedBalance(account, 0), first)
+	}
+}
+
+func TestAddCallBySignatureUnknownMethod(t *testing.T) {
+	mc, _ := newTestMultiCaller(t)
+	var balance *big.Int
+	mc.AddCallBySignature(testTokenAddress, &testTokenAbi, &balance, "balanceOf(uint256)", big.NewInt(1))
+	_, err := mc.FlexibleCall(false, nil)
+	if err == nil {
+		t.Fatal("expected an unknown signature to fail the batch")
+	}
+}
+
+func TestDirectFallbackRetriesFailedChunks(t *testing.T) {
+	// The provider rejects the aggregated call, but accepts each call on its own
+	mc, client := newTestMultiCaller(t)
+	client.maxCallData = 100
+	balances := make([]*big.Int, 3)
+	for i := range balances {
+		mc.AddCall(testTokenAddress, &testTokenAbi, &balances[i], "balanceOf", common.BigToAddress(big.NewInt(int64(i+1))))
+	}
+	_, err := mc.FlexibleCall(true, nil)
+	if !errors.Is(err, ErrBatchTooLarge) {
+		t.Fatalf("expected the chunk to be too large without the fallback, got %v", err)
+	}
+
+	mc.DirectFallback = true
+	for i := range balances {
+		mc.AddCall(testTokenAddress, &testTokenAbi, &balances[i], "balanceOf", common.BigToAddress(big.NewInt(int64(i+1))))
+	}
+	var sender common.Address
+	mc.AddCall(testTokenAddress, &testTokenAbi, &sender, "whoami")
+	_, err = mc.FlexibleCall(true, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, balance := range balances {
+		if balance.Int64() != int64(i+1) {
+			t.Fatalf("expected balance %d for call %d, got %s", i+1, i, balance)
+		}
+	}
+	if sender != testMulticallAddress {
+		t.Fatalf("expected the retried calls to run from the multicall contract, got %s", sender.Hex())
+	}
+
+	// Reverts of individual calls are still reported with their index in the batch
+	var boom *big.Int
+	mc.AddCall(testTokenAddress, &testTokenAbi, &balances[0], "balanceOf", common.HexToAddress("0x07"))
+	mc.AddCall(testTokenAddress, &testTokenAbi, &boom, "boom")
+	_, err = mc.FlexibleCall(true, nil)
+	var reverted *ErrCallReverted
+	if !errors.As(err, &reverted) || reverted.Index != 1 {
+		t.Fatalf("expected the second call's revert to be reported, got %v", err)
+	}
+}
@@ -0,0 +1,123 @@
+package batchquery
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+// This struct can read the nonces (transaction counts) of many accounts concurrently using eth_getTransactionCount.
+// It's useful for preparing transactions from many accounts at once, since nonces can't be read through the multicall contract.
+type NonceBatcher struct {
+	// The number of reads to run simultaneously, or the number of batch requests to send simultaneously if the client implements IBatchCaller
+	ThreadLimit int
+
+	// The number of reads to send in a single JSON-RPC batch request, if the client implements IBatchCaller (0 = 100)
+	RpcBatchSize int
+
+	// The context that reads run in when their options don't provide one (nil = context.Background())
+	BaseContext context.Context
+
+	// The deadline for each eth_getTransactionCount or batch request, so every request has one even when the caller's context doesn't (0 = no deadline beyond the one in the context)
+	DefaultTimeout time.Duration
+
+	// The executor that runs the batcher's requests (nil = no shared executor; see Executor)
+	Executor *Executor
+
+	// An optional non-RPC source of nonces, such as an Etherscan-compatible API, used if the client can't be reached.
+	// It's only used for reads of the latest block, since that's all such sources can serve (nil = no fallback).
+	Fallback IFallbackProvider
+
+	// The Execution client binding
+	client INonceReader
+}
+
+// Creates a new NonceBatcher instance
+func NewNonceBatcher(client INonceReader, threadLimit int) *NonceBatcher {
+	return &NonceBatcher{
+		client:      client,
+		ThreadLimit: threadLimit,
+	}
+}
+
+// Retrieves the nonces of a list of accounts. The order of the resulting array corresponds to the order of the provided addresses.
+// If the client implements IBatchCaller, the nonces are read with JSON-RPC batch requests instead of one request each, which also allows reading them
+// at a block hash or from the pending block.
+// If the client can't be reached and the batcher has a Fallback, the nonces of the latest block are read from it instead.
+func (b *NonceBatcher) GetNonces(addresses []common.Address, opts *bind.CallOpts) ([]uint64, error) {
+	options := newCallOptionsWithBase(opts, b.BaseContext)
+	if options.from != (common.Address{}) {
+		return nil, fmt.Errorf("nonce reads don't have a sender, so opts can't specify a From address")
+	}
+	nonces, err := b.getNonces(addresses, options)
+	if err == nil || b.Fallback == nil || !canUseFallback(err, options) {
+		return nonces, err
+	}
+	nonces, fallbackErr := b.Fallback.GetNonces(options.ctx, addresses)
+	if fallbackErr != nil {
+		return nil, errors.Join(err, fmt.Errorf("error getting nonces from fallback provider: %w", fallbackErr))
+	}
+	if len(nonces) != len(addresses) {
+		return nil, fmt.Errorf("fallback provider returned %d nonces which mismatches the %d addresses", len(nonces), len(addresses))
+	}
+	return nonces, nil
+}
+
+// Implementation of GetNonces that only uses the client, with JSON-RPC batch requests if it supports them
+func (b *NonceBatcher) getNonces(addresses []common.Address, options *callOptions) ([]uint64, error) {
+	if batchCaller, ok := b.client.(IBatchCaller); ok {
+		return b.getNoncesBatched(batchCaller, addresses, options)
+	}
+	if options.blockHash != nil || options.pending {
+		return nil, fmt.Errorf("client can only read nonces by block number, since it doesn't support JSON-RPC batch requests")
+	}
+	nonces := make([]uint64, len(addresses))
+
+	// A failure in any read cancels the rest of them
+	err := b.Executor.run(options.ctx, b.ThreadLimit, "eth_getTransactionCount", len(addresses), nil, func(ctx context.Context, i int) error {
+		readCtx, cancel := withRequestTimeout(ctx, b.DefaultTimeout)
+		defer cancel()
+		nonce, err := b.client.NonceAt(readCtx, addresses[i], options.blockNumber)
+		if err != nil {
+			return fmt.Errorf("error reading nonce of %s: %w", addresses[i].Hex(), wrapClientError(err))
+		}
+		nonces[i] = nonce
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return nonces, nil
+}
+
+// Reads the nonce of each address with JSON-RPC batch requests
+func (b *NonceBatcher) getNoncesBatched(caller IBatchCaller, addresses []common.Address, options *callOptions) ([]uint64, error) {
+	results := make([]hexutil.Uint64, len(addresses))
+	elems := make([]rpc.BatchElem, len(addresses))
+	for i, address := range addresses {
+		elems[i] = rpc.BatchElem{
+			Method: "eth_getTransactionCount",
+			Args:   []any{address, options.blockArg()},
+			Result: &results[i],
+		}
+	}
+	err := runRpcBatches(options.ctx, b.Executor, caller, "eth_getTransactionCount", elems, b.RpcBatchSize, b.ThreadLimit, b.DefaultTimeout)
+	if err != nil {
+		return nil, err
+	}
+
+	nonces := make([]uint64, len(addresses))
+	for i, address := range addresses {
+		if elems[i].Error != nil {
+			return nil, fmt.Errorf("error reading nonce of %s: %w", address.Hex(), wrapClientError(elems[i].Error))
+		}
+		nonces[i] = uint64(results[i])
+	}
+	return nonces, nil
+}
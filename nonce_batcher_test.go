@@ -0,0 +1,86 @@
+package batchquery
+
+import (
+	"context"
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+// A nonce reader where each account's nonce is its last byte plus the block number (0 for the latest block)
+type mockNonceReader struct {
+	// The error every read fails with (nil = reads work)
+	err error
+}
+
+func (m *mockNonceReader) NonceAt(ctx context.Context, account common.Address, blockNumber *big.Int) (uint64, error) {
+	if m.err != nil {
+		return 0, m.err
+	}
+	nonce := uint64(account[19])
+	if blockNumber != nil {
+		nonce += blockNumber.Uint64()
+	}
+	return nonce, nil
+}
+
+// A nonce reader that reads nonces with JSON-RPC batch requests, recording the block argument of each one
+type mockBatchNonceReader struct {
+	mockNonceReader
+
+	// The block argument of each request
+	blockArgs []any
+}
+
+func (m *mockBatchNonceReader) BatchCallContext(ctx context.Context, elems []rpc.BatchElem) error {
+	for i := range elems {
+		m.blockArgs = append(m.blockArgs, elems[i].Args[1])
+		var block *big.Int
+		if number, ok := elems[i].Args[1].(*hexutil.Big); ok {
+			block = (*big.Int)(number)
+		}
+		nonce, err := m.NonceAt(ctx, elems[i].Args[0].(common.Address), block)
+		if err != nil {
+			elems[i].Error = err
+			continue
+		}
+		*elems[i].Result.(*hexutil.Uint64) = hexutil.Uint64(nonce)
+	}
+	return nil
+}
+
+func TestNonceBatcher(t *testing.T) {
+	addresses := []common.Address{common.HexToAddress("0x01"), common.HexToAddress("0x02"), common.HexToAddress("0x03")}
+	batcher := NewNonceBatcher(&mockNonceReader{}, 2)
+	nonces, err := batcher.GetNonces(addresses, &bind.CallOpts{BlockNumber: big.NewInt(10)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, nonce := range nonces {
+		if nonce != uint64(i+11) {
+			t.Fatalf("expected nonce %d for address %d, got %d", i+11, i, nonce)
+		}
+	}
+
+	// Clients without batch requests can't read the pending block
+	_, err = batcher.GetNonces(addresses, &bind.CallOpts{Pending: true})
+	if err == nil {
+		t.Fatal("expected a pending read to fail without batch requests")
+	}
+
+	// Batch requests can
+	reader := &mockBatchNonceReader{}
+	batcher = NewNonceBatcher(reader, 1)
+	batcher.RpcBatchSize = 2
+	nonces, err = batcher.GetNonces(addresses, &bind.CallOpts{Pending: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(nonces) != 3 || nonces[2] != 3 || len(reader.blockArgs) != 3 || reader.blockArgs[0] != "pending" {
+		t.Fatalf("expected pending nonces from batch requests, got %v with block args %v", nonces, reader.blockArgs)
+	}
+}
@@ -0,0 +1,173 @@
+package batchquery
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math/big"
+	"sync"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// Packer cache, keyed by method signature (such as "transfer(address,uint256)").
+// The signature determines both the selector and the argument encoding, so packers are shared between every ABI that has the method,
+// and the cache only grows with the number of distinct methods rather than the number of times an ABI is parsed.
+var packerCache sync.Map
+
+// Writes a single argument into a 32-byte ABI word.
+// Returns false if the argument's type isn't supported, in which case the regular ABI packer should be used instead.
+type wordEncoder func(arg any, word []byte) bool
+
+// Packs call data for a single method, reusing its precomputed selector and argument encoders
+type methodPacker struct {
+	// The method being packed
+	method abi.Method
+
+	// Encoders for each of the method's arguments, or nil if the method has an argument that can't be encoded by the fast path
+	encoders []wordEncoder
+}
+
+// Packs the call data for a method with the provided arguments.
+// The selector and argument encoders for each method signature are cached, so packing many calls with the same shape is cheap.
+func packCall(contractAbi *abi.ABI, method string, args ...any) ([]byte, error) {
+	packer, err := getMethodPacker(contractAbi, method)
+	if err != nil {
+		return nil, err
+	}
+	return packer.pack(args...)
+}
+
+// Gets the cached packer for a method, creating it if it doesn't exist yet
+func getMethodPacker(contractAbi *abi.ABI, method string) (*methodPacker, error) {
+	abiMethod, exists := contractAbi.Methods[method]
+	if !exists {
+		return nil, fmt.Errorf("method '%s' not found", method)
+	}
+	if cached, exists := packerCache.Load(abiMethod.Sig); exists {
+		return cached.(*methodPacker), nil
+	}
+
+	packer := &methodPacker{
+		method:   abiMethod,
+		encoders: make([]wordEncoder, len(abiMethod.Inputs)),
+	}
+	for i, input := range abiMethod.Inputs {
+		encoder := getWordEncoder(input.Type)
+		if encoder == nil {
+			packer.encoders = nil
+			break
+		}
+		packer.encoders[i] = encoder
+	}
+
+	cached, _ := packerCache.LoadOrStore(abiMethod.Sig, packer)
+	return cached.(*methodPacker), nil
+}
+
+// Packs the call data for the method with the provided arguments
+func (p *methodPacker) pack(args ...any) ([]byte, error) {
+	if p.encoders != nil && len(args) == len(p.encoders) {
+		callData := make([]byte, 4+wordSize*len(args))
+		copy(callData, p.method.ID)
+		success := true
+		for i, arg := range args {
+			if !p.encoders[i](arg, callData[4+wordSize*i:4+wordSize*(i+1)]) {
+				success = false
+				break
+			}
+		}
+		if success {
+			return callData, nil
+		}
+	}
+
+	// Fall back to the regular packer for anything the fast path can't handle
+	arguments, err := p.method.Inputs.Pack(args...)
+	if err != nil {
+		return nil, err
+	}
+	callData := make([]byte, 0, 4+len(arguments))
+	callData = append(callData, p.method.ID...)
+	return append(callData, arguments...), nil
+}
+
+// Gets the fast path encoder for an ABI type, or nil if the type isn't supported
+func getWordEncoder(t abi.Type) wordEncoder {
+	switch t.T {
+	case abi.AddressTy:
+		return func(arg any, word []byte) bool {
+			address, ok := arg.(common.Address)
+			if !ok {
+				return false
+			}
+			copy(word[wordSize-common.AddressLength:], address[:])
+			return true
+		}
+
+	case abi.BoolTy:
+		return func(arg any, word []byte) bool {
+			value, ok := arg.(bool)
+			if !ok {
+				return false
+			}
+			if value {
+				word[wordSize-1] = 1
+			}
+			return true
+		}
+
+	case abi.FixedBytesTy:
+		if t.Size != wordSize {
+			return nil
+		}
+		return func(arg any, word []byte) bool {
+			switch value := arg.(type) {
+			case [32]byte:
+				copy(word, value[:])
+			case common.Hash:
+				copy(word, value[:])
+			default:
+				return false
+			}
+			return true
+		}
+
+	case abi.UintTy:
+		size := t.Size
+		return func(arg any, word []byte) bool {
+			switch value := arg.(type) {
+			case *big.Int:
+				if size <= 64 || value == nil || value.Sign() < 0 || value.BitLen() > size {
+					return false
+				}
+				value.FillBytes(word)
+			case uint8:
+				if size != 8 {
+					return false
+				}
+				word[wordSize-1] = value
+			case uint16:
+				if size != 16 {
+					return false
+				}
+				binary.BigEndian.PutUint64(word[wordSize-8:], uint64(value))
+			case uint32:
+				if size != 32 {
+					return false
+				}
+				binary.BigEndian.PutUint64(word[wordSize-8:], uint64(value))
+			case uint64:
+				if size != 64 {
+					return false
+				}
+				binary.BigEndian.PutUint64(word[wordSize-8:], value)
+			default:
+				return false
+			}
+			return true
+		}
+	}
+
+	return nil
+}
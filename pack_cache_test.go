@@ -0,0 +1,104 @@
+package batchquery
+
+import (
+	"bytes"
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+const packTestAbiString = `[
+	{"inputs":[{"name":"a","type":"address"}],"name":"address_","outputs":[],"stateMutability":"view","type":"function"},
+	{"inputs":[{"name":"a","type":"bool"}],"name":"bool_","outputs":[],"stateMutability":"view","type":"function"},
+	{"inputs":[{"name":"a","type":"bytes32"}],"name":"bytes32_","outputs":[],"stateMutability":"view","type":"function"},
+	{"inputs":[{"name":"a","type":"bytes4"}],"name":"bytes4_","outputs":[],"stateMutability":"view","type":"function"},
+	{"inputs":[{"name":"a","type":"uint256"}],"name":"uint256_","outputs":[],"stateMutability":"view","type":"function"},
+	{"inputs":[{"name":"a","type":"uint128"}],"name":"uint128_","outputs":[],"stateMutability":"view","type":"function"},
+	{"inputs":[{"name":"a","type":"uint64"}],"name":"uint64_","outputs":[],"stateMutability":"view","type":"function"},
+	{"inputs":[{"name":"a","type":"uint32"}],"name":"uint32_","outputs":[],"stateMutability":"view","type":"function"},
+	{"inputs":[{"name":"a","type":"uint16"}],"name":"uint16_","outputs":[],"stateMutability":"view","type":"function"},
+	{"inputs":[{"name":"a","type":"uint8"}],"name":"uint8_","outputs":[],"stateMutability":"view","type":"function"},
+	{"inputs":[{"name":"a","type":"int256"}],"name":"int256_","outputs":[],"stateMutability":"view","type":"function"},
+	{"inputs":[{"name":"a","type":"string"}],"name":"string_","outputs":[],"stateMutability":"view","type":"function"},
+	{"inputs":[{"name":"a","type":"address"},{"name":"b","type":"uint256"},{"name":"c","type":"bool"}],"name":"mixed","outputs":[],"stateMutability":"view","type":"function"},
+	{"inputs":[{"name":"a","type":"address"},{"name":"b","type":"string"}],"name":"mixedDynamic","outputs":[],"stateMutability":"view","type":"function"}
+]`
+
+var packTestAbi = mustParseAbi(packTestAbiString)
+
+func TestPackCallMatchesAbiPack(t *testing.T) {
+	maxUint256 := new(big.Int).Sub(new(big.Int).Lsh(big.NewInt(1), 256), big.NewInt(1))
+	oversized := new(big.Int).Lsh(big.NewInt(1), 256)
+	address := common.HexToAddress("0x00000000219ab540356cBB839Cbe05303d7705Fa")
+
+	cases := []struct {
+		method string
+		args   []any
+	}{
+		{"address_", []any{address}},
+		{"address_", []any{common.Address{}}},
+		{"address_", []any{"not an address"}},
+		{"bool_", []any{true}},
+		{"bool_", []any{false}},
+		{"bool_", []any{1}},
+		{"bytes32_", []any{common.HexToHash("0xabcdef")}},
+		{"bytes32_", []any{[32]byte{1, 2, 3}}},
+		{"bytes32_", []any{[]byte{1, 2, 3}}},
+		{"bytes4_", []any{[4]byte{1, 2, 3, 4}}},
+		{"uint256_", []any{big.NewInt(0)}},
+		{"uint256_", []any{big.NewInt(123456789)}},
+		{"uint256_", []any{maxUint256}},
+		{"uint256_", []any{big.NewInt(-1)}},
+		{"uint256_", []any{oversized}},
+		{"uint256_", []any{uint64(5)}},
+		{"uint128_", []any{big.NewInt(42)}},
+		{"uint128_", []any{maxUint256}},
+		{"uint64_", []any{uint64(1<<64 - 1)}},
+		{"uint64_", []any{big.NewInt(5)}},
+		{"uint64_", []any{uint32(5)}},
+		{"uint32_", []any{uint32(1<<32 - 1)}},
+		{"uint16_", []any{uint16(1<<16 - 1)}},
+		{"uint8_", []any{uint8(255)}},
+		{"uint8_", []any{uint64(255)}},
+		{"int256_", []any{big.NewInt(-5)}},
+		{"string_", []any{"hello"}},
+		{"mixed", []any{address, big.NewInt(7), true}},
+		{"mixed", []any{address, big.NewInt(-7), true}},
+		{"mixed", []any{address, big.NewInt(7)}},
+		{"mixedDynamic", []any{address, "hello"}},
+	}
+
+	for _, c := range cases {
+		expected, expectedErr := packTestAbi.Pack(c.method, c.args...)
+		actual, actualErr := packCall(&packTestAbi, c.method, c.args...)
+		if (expectedErr != nil) != (actualErr != nil) {
+			t.Errorf("%s%v: abi.Pack returned error %v but packCall returned %v", c.method, c.args, expectedErr, actualErr)
+			continue
+		}
+		if !bytes.Equal(expected, actual) {
+			t.Errorf("%s%v: abi.Pack returned %x but packCall returned %x", c.method, c.args, expected, actual)
+		}
+	}
+}
+
+func TestPackCallSharesPackersBetweenParsedAbis(t *testing.T) {
+	first := mustParseAbi(packTestAbiString)
+	second := mustParseAbi(packTestAbiString)
+	firstPacker, err := getMethodPacker(&first, "mixed")
+	if err != nil {
+		t.Fatal(err)
+	}
+	secondPacker, err := getMethodPacker(&second, "mixed")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if firstPacker != secondPacker {
+		t.Fatal("expected separately parsed ABIs to share the same packer")
+	}
+
+	_, err = packCall(&first, "missing")
+	if err == nil {
+		t.Fatal("expected an error for a missing method")
+	}
+}
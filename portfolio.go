@@ -0,0 +1,324 @@
+package batchquery
+
+import (
+	"fmt"
+	"math/big"
+	"strings"
+	"sync"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+)
+
+const (
+	// The ABI for the getters of a Chainlink price feed: https://docs.chain.link/data-feeds/api-reference
+	priceFeedAbiString string = "[{\"inputs\":[],\"name\":\"decimals\",\"outputs\":[{\"internalType\":\"uint8\",\"name\":\"\",\"type\":\"uint8\"}],\"stateMutability\":\"view\",\"type\":\"function\"},{\"inputs\":[],\"name\":\"latestRoundData\",\"outputs\":[{\"internalType\":\"uint80\",\"name\":\"roundId\",\"type\":\"uint80\"},{\"internalType\":\"int256\",\"name\":\"answer\",\"type\":\"int256\"},{\"internalType\":\"uint256\",\"name\":\"startedAt\",\"type\":\"uint256\"},{\"internalType\":\"uint256\",\"name\":\"updatedAt\",\"type\":\"uint256\"},{\"internalType\":\"uint80\",\"name\":\"answeredInRound\",\"type\":\"uint80\"}],\"stateMutability\":\"view\",\"type\":\"function\"}]"
+
+	// The number of decimals of the native currency
+	nativeDecimals uint8 = 18
+)
+
+// ABI cache
+var priceFeedAbi abi.ABI
+var priceFeedOnce sync.Once
+
+// An asset to include in a portfolio
+type PortfolioAsset struct {
+	// The address of the token contract, or the zero address for the native currency
+	Token common.Address
+
+	// The address of the Chainlink feed that prices the asset (zero address = the asset isn't priced)
+	PriceFeed common.Address
+}
+
+// The metadata of a token
+type TokenMetadata struct {
+	// The address of the token contract, or the zero address for the native currency
+	Address common.Address
+
+	// The token's name, or an empty string if the token doesn't provide one
+	Name string
+
+	// The token's symbol, or an empty string if the token doesn't provide one
+	Symbol string
+
+	// The number of decimals in the token's balances
+	Decimals uint8
+}
+
+// The latest price reported by a Chainlink feed
+type FeedPrice struct {
+	// The address of the feed
+	Feed common.Address
+
+	// The ID of the round the price was reported in
+	RoundID *big.Int
+
+	// The price, scaled by the feed's decimals
+	Answer *big.Int
+
+	// The number of decimals in the price
+	Decimals uint8
+
+	// The timestamp of the round's last update
+	UpdatedAt *big.Int
+}
+
+// A single asset held by an address along with its value
+type ValuedHolding struct {
+	// The metadata of the asset
+	Token TokenMetadata
+
+	// The address's balance of the asset
+	Balance *big.Int
+
+	// The price of the asset, or nil if it isn't priced
+	Price *FeedPrice
+
+	// The value of the balance, scaled by the PortfolioQuerier's ValueDecimals, or nil if the asset isn't priced
+	Value *big.Int
+}
+
+// The valued holdings of a single address
+type AccountPortfolio struct {
+	// The address that holds the assets
+	Address common.Address
+
+	// The address's holdings, in the same order as the provided assets
+	Holdings []ValuedHolding
+
+	// The sum of the values of the priced holdings, scaled by the PortfolioQuerier's ValueDecimals
+	TotalValue *big.Int
+}
+
+// The portfolios of a set of addresses, all read at the same block
+type Portfolio struct {
+	// The block the portfolio was read at
+	BlockNumber *big.Int
+
+	// The portfolio of each address, in the same order as the provided addresses
+	Accounts []AccountPortfolio
+}
+
+// This struct reads the balances, token metadata, and Chainlink prices of a set of assets for a set of addresses,
+// all at a single pinned block so the values are consistent with each other.
+// Values are summed across assets, so every price feed should be quoted in the same currency (such as USD).
+type PortfolioQuerier struct {
+	// The number of decimals the values are scaled by
+	ValueDecimals uint8
+
+	// The symbol reported for the native currency
+	NativeSymbol string
+
+	// The MultiCaller used to run the queries
+	caller *MultiCaller
+}
+
+// Creates a new PortfolioQuerier instance
+func NewPortfolioQuerier(caller *MultiCaller, valueDecimals uint8) *PortfolioQuerier {
+	return &PortfolioQuerier{
+		ValueDecimals: valueDecimals,
+		NativeSymbol:  "ETH",
+		caller:        caller,
+	}
+}
+
+// Gets the portfolio of each address: its balance of each asset, valued with the asset's price feed.
+// The token metadata and prices are read in one batch of multicalls, followed by the balances in a second, both at the block in opts;
+// if opts doesn't specify a block, the latest one is pinned first.
+// A token's name and symbol are optional (and may be encoded as bytes32 strings), but its decimals, balances, and price must be readable.
+func (q *PortfolioQuerier) GetPortfolio(addresses []common.Address, assets []PortfolioAsset, opts *bind.CallOpts) (*Portfolio, error) {
+	pinned, err := pinCallOpts(q.caller, opts)
+	if err != nil {
+		return nil, err
+	}
+	metadata, prices, err := q.getMetadataAndPrices(assets, pinned)
+	if err != nil {
+		return nil, err
+	}
+	balances, err := q.getBalances(addresses, assets, pinned)
+	if err != nil {
+		return nil, err
+	}
+
+	portfolio := &Portfolio{
+		BlockNumber: pinned.BlockNumber,
+		Accounts:    make([]AccountPortfolio, len(addresses)),
+	}
+	for i, address := range addresses {
+		account := AccountPortfolio{
+			Address:    address,
+			Holdings:   make([]ValuedHolding, len(assets)),
+			TotalValue: big.NewInt(0),
+		}
+		for j := range assets {
+			holding := ValuedHolding{
+				Token:   metadata[j],
+				Balance: balances[i][j],
+				Price:   prices[j],
+			}
+			if holding.Price != nil {
+				holding.Value = q.getValue(holding.Balance, metadata[j].Decimals, holding.Price)
+				account.TotalValue.Add(account.TotalValue, holding.Value)
+			}
+			account.Holdings[j] = holding
+		}
+		portfolio.Accounts[i] = account
+	}
+	return portfolio, nil
+}
+
+// Reads the metadata of each asset and the latest price of each priced asset in a single batch
+func (q *PortfolioQuerier) getMetadataAndPrices(assets []PortfolioAsset, opts *bind.CallOpts) ([]TokenMetadata, []*FeedPrice, error) {
+	erc20, err := getErc20Abi()
+	if err != nil {
+		return nil, nil, err
+	}
+	feedAbi, err := getPriceFeedAbi()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	type roundData struct {
+		RoundId         *big.Int
+		Answer          *big.Int
+		StartedAt       *big.Int
+		UpdatedAt       *big.Int
+		AnsweredInRound *big.Int
+	}
+	metadata := make([]TokenMetadata, len(assets))
+	prices := make([]*FeedPrice, len(assets))
+	rounds := make([]roundData, len(assets))
+	runner := q.caller.withCalls([]*Call{})
+	requiredCalls := map[*Call]bool{}
+	stringCalls := map[*Call]*string{}
+	for i, asset := range assets {
+		metadata[i].Address = asset.Token
+		if asset.Token == (common.Address{}) {
+			metadata[i].Name = q.NativeSymbol
+			metadata[i].Symbol = q.NativeSymbol
+			metadata[i].Decimals = nativeDecimals
+		} else {
+			// Some older tokens return their name and symbol as bytes32, so they're decoded after the batch
+			requiredCalls[runner.AddCall(asset.Token, erc20, &metadata[i].Decimals, "decimals")] = true
+			for _, field := range []struct {
+				method string
+				output *string
+			}{
+				{"name", &metadata[i].Name},
+				{"symbol", &metadata[i].Symbol},
+			} {
+				call := runner.AddCall(asset.Token, erc20, nil, field.method)
+				call.UnpackFunc = nil
+				stringCalls[call] = field.output
+			}
+		}
+		if asset.PriceFeed != (common.Address{}) {
+			prices[i] = &FeedPrice{Feed: asset.PriceFeed}
+			requiredCalls[runner.AddCall(asset.PriceFeed, feedAbi, &prices[i].Decimals, "decimals")] = true
+			requiredCalls[runner.AddCall(asset.PriceFeed, feedAbi, &rounds[i], "latestRoundData")] = true
+		}
+	}
+
+	calls := runner.calls
+	results, err := runner.FlexibleCallWithResults(false, opts)
+	if err != nil {
+		return nil, nil, fmt.Errorf("error getting token metadata and prices: %w", err)
+	}
+	for i, call := range calls {
+		if requiredCalls[call] && !results[i].Success {
+			return nil, nil, fmt.Errorf("error getting token metadata and prices: %w", &ErrCallReverted{
+				Index:  i,
+				Target: call.Target,
+				Method: call.Method,
+				Data:   results[i].ReturnData,
+			})
+		}
+		output, isString := stringCalls[call]
+		if isString && results[i].Success {
+			*output = decodeTokenString(results[i].ReturnData)
+		}
+	}
+	for i, price := range prices {
+		if price == nil {
+			continue
+		}
+		if rounds[i].Answer.Sign() <= 0 {
+			return nil, nil, fmt.Errorf("price feed %s reported an invalid price of %s", price.Feed.Hex(), rounds[i].Answer.String())
+		}
+		price.RoundID = rounds[i].RoundId
+		price.Answer = rounds[i].Answer
+		price.UpdatedAt = rounds[i].UpdatedAt
+	}
+	return metadata, prices, nil
+}
+
+// Reads the balance of each asset for each address in a single batch
+func (q *PortfolioQuerier) getBalances(addresses []common.Address, assets []PortfolioAsset, opts *bind.CallOpts) ([][]*big.Int, error) {
+	erc20, err := getErc20Abi()
+	if err != nil {
+		return nil, err
+	}
+	balances := make([][]*big.Int, len(addresses))
+	runner := q.caller.withCalls([]*Call{})
+	for i, address := range addresses {
+		balances[i] = make([]*big.Int, len(assets))
+		for j, asset := range assets {
+			if asset.Token == (common.Address{}) {
+				runner.AddEthBalance(address, &balances[i][j])
+			} else {
+				runner.AddCall(asset.Token, erc20, &balances[i][j], "balanceOf", address)
+			}
+		}
+	}
+	_, err = runner.FlexibleCall(true, opts)
+	if err != nil {
+		return nil, fmt.Errorf("error getting balances: %w", err)
+	}
+	return balances, nil
+}
+
+// Gets the value of a balance with the provided price, scaled by the querier's ValueDecimals
+func (q *PortfolioQuerier) getValue(balance *big.Int, decimals uint8, price *FeedPrice) *big.Int {
+	value := new(big.Int).Mul(balance, price.Answer)
+	value.Mul(value, pow10(q.ValueDecimals))
+	divisor := new(big.Int).Mul(pow10(decimals), pow10(price.Decimals))
+	return value.Quo(value, divisor)
+}
+
+// Gets 10 raised to the provided power
+func pow10(exponent uint8) *big.Int {
+	return new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(exponent)), nil)
+}
+
+// Decodes a token name or symbol, which is usually an ABI-encoded string but is a bytes32 for some older tokens such as MKR.
+// Returns an empty string if the data is neither.
+func decodeTokenString(data []byte) string {
+	stringType, _ := abi.NewType("string", "", nil)
+	values, err := abi.Arguments{{Type: stringType}}.Unpack(data)
+	if err == nil {
+		return values[0].(string)
+	}
+	if len(data) == wordSize {
+		value, _ := DecodeBytes32String(data)
+		return value
+	}
+	return ""
+}
+
+// Gets the parsed ABI for a Chainlink price feed
+func getPriceFeedAbi() (*abi.ABI, error) {
+	var err error
+	priceFeedOnce.Do(func() {
+		var parsedAbi abi.ABI
+		parsedAbi, err = abi.JSON(strings.NewReader(priceFeedAbiString))
+		if err == nil {
+			priceFeedAbi = parsedAbi
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &priceFeedAbi, nil
+}
@@ -0,0 +1,83 @@
+package batchquery
+
+import (
+	"errors"
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+func TestGetPortfolio(t *testing.T) {
+	mc, _ := newTestMultiCaller(t)
+	querier := NewPortfolioQuerier(mc, 6)
+	addresses := []common.Address{
+		common.HexToAddress("0x0102"),
+		common.HexToAddress("0x0203"),
+	}
+	assets := []PortfolioAsset{
+		{Token: testTokenAddress, PriceFeed: testFeedAddress},
+		{}, // Unpriced ETH
+	}
+
+	portfolio, err := querier.GetPortfolio(addresses, assets, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if portfolio.BlockNumber.Int64() != 100 {
+		t.Fatalf("expected the portfolio to be pinned to block 100, got %s", portfolio.BlockNumber)
+	}
+	for i, address := range addresses {
+		account := portfolio.Accounts[i]
+		if account.Address != address || len(account.Holdings) != 2 {
+			t.Fatalf("unexpected portfolio for address %d: %+v", i, account)
+		}
+
+		token := account.Holdings[0]
+		if token.Token.Symbol != "TST" || token.Token.Name != "" || token.Token.Decimals != 2 {
+			t.Fatalf("unexpected token metadata: %+v", token.Token)
+		}
+		if token.Price == nil || token.Price.Answer.Int64() != 3e8 || token.Price.Decimals != 8 || token.Price.UpdatedAt.Int64() != 1200 {
+			t.Fatalf("unexpected token price: %+v", token.Price)
+		}
+
+		// The balance has 2 decimals and the value has 6, so the value is the balance times 3 times 10^4
+		balance := expectedBalance(address, 100)
+		value := new(big.Int).Mul(balance, big.NewInt(3e4))
+		if token.Balance.Cmp(balance) != 0 || token.Value.Cmp(value) != 0 {
+			t.Fatalf("expected a balance of %s worth %s, got %s worth %s", balance, value, token.Balance, token.Value)
+		}
+
+		eth := account.Holdings[1]
+		if eth.Token.Symbol != "ETH" || eth.Token.Decimals != 18 || eth.Balance.Cmp(balance) != 0 || eth.Price != nil || eth.Value != nil {
+			t.Fatalf("unexpected ETH holding: %+v", eth)
+		}
+		if account.TotalValue.Cmp(value) != 0 {
+			t.Fatalf("expected a total value of %s, got %s", value, account.TotalValue)
+		}
+	}
+}
+
+func TestGetPortfolioRequiresPrices(t *testing.T) {
+	mc, _ := newTestMultiCaller(t)
+	querier := NewPortfolioQuerier(mc, 18)
+	assets := []PortfolioAsset{
+		{Token: testTokenAddress, PriceFeed: testTokenAddress},
+	}
+
+	_, err := querier.GetPortfolio([]common.Address{{}}, assets, nil)
+	var reverted *ErrCallReverted
+	if !errors.As(err, &reverted) || reverted.Target != testTokenAddress || reverted.Method != "latestRoundData" {
+		t.Fatalf("expected the missing price to fail the query, got %v", err)
+	}
+}
+
+func TestDecodeTokenString(t *testing.T) {
+	symbol := common.RightPadBytes([]byte("MKR"), 32)
+	if decodeTokenString(symbol) != "MKR" {
+		t.Fatalf("expected a bytes32 symbol to be decoded, got %q", decodeTokenString(symbol))
+	}
+	if decodeTokenString([]byte{1, 2}) != "" {
+		t.Fatal("expected invalid data to decode to an empty string")
+	}
+}
@@ -0,0 +1,256 @@
+package batchquery
+
+import (
+	"fmt"
+	"math/big"
+	"strings"
+	"sync"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+)
+
+const (
+	// The ABI for the L1 fee getters of the OP Stack GasPriceOracle predeploy: https://github.com/ethereum-optimism/optimism
+	gasPriceOracleAbiString string = "[{\"inputs\":[{\"internalType\":\"bytes\",\"name\":\"_data\",\"type\":\"bytes\"}],\"name\":\"getL1Fee\",\"outputs\":[{\"internalType\":\"uint256\",\"name\":\"\",\"type\":\"uint256\"}],\"stateMutability\":\"view\",\"type\":\"function\"},{\"inputs\":[{\"internalType\":\"bytes\",\"name\":\"_data\",\"type\":\"bytes\"}],\"name\":\"getL1GasUsed\",\"outputs\":[{\"internalType\":\"uint256\",\"name\":\"\",\"type\":\"uint256\"}],\"stateMutability\":\"view\",\"type\":\"function\"},{\"inputs\":[],\"name\":\"l1BaseFee\",\"outputs\":[{\"internalType\":\"uint256\",\"name\":\"\",\"type\":\"uint256\"}],\"stateMutability\":\"view\",\"type\":\"function\"}]"
+)
+
+var (
+	// The address Multicall3 is deployed to on nearly every EVM chain: https://github.com/mds1/multicall
+	Multicall3Address = common.HexToAddress("0xcA11bde05977b3631167028862bE2a173976CA11")
+
+	// The address MakerDAO's Multicall2 is deployed to on Ethereum mainnet and several of the legacy testnets: https://github.com/makerdao/multicall
+	Multicall2Address = common.HexToAddress("0x5BA1e12693Dc8F9c48aAD8770482f4739bEeD696")
+
+	// The address of the eth-balance-checker contract on Ethereum mainnet: https://github.com/wbobeirne/eth-balance-checker
+	MainnetBalanceCheckerAddress = common.HexToAddress("0xb1F8e55c7f64D203C1400B9D8555d050F94aDF39")
+
+	// The address of the GasPriceOracle predeploy on OP Stack chains
+	OpStackGasPriceOracleAddress = common.HexToAddress("0x420000000000000000000000000000000000000F")
+
+	// The address of WETH on Ethereum mainnet
+	MainnetWethAddress = common.HexToAddress("0xC02aaA39b223FE8D0A0e5C4F27eAD9083C756Cc2")
+
+	// The address of the WETH predeploy on OP Stack chains
+	OpStackWethAddress = common.HexToAddress("0x4200000000000000000000000000000000000006")
+)
+
+// ABI cache
+var gasPriceOracleAbi abi.ABI
+var gpoOnce sync.Once
+
+// The helper contract addresses and batching settings that work well for a particular chain.
+// Rollups differ from Ethereum in several ways that affect batching: their gas accounting (and thus how much fits in one eth_call)
+// is different, and some of them charge an L1 data fee that has to be queried from a separate contract.
+type ChainProfile struct {
+	// The name of the chain
+	Name string
+
+	// The ID of the chain
+	ChainID uint64
+
+	// The address of the multicall contract on the chain
+	MulticallAddress common.Address
+
+	// The address of the balance batcher contract on the chain (zero if it isn't deployed there)
+	BalanceBatcherAddress common.Address
+
+	// The address of the contract that reports the L1 data fee, for OP Stack chains (zero for other chains)
+	GasPriceOracleAddress common.Address
+
+	// The address of the chain's wrapped native token, such as WETH (zero if it doesn't have a well-known one)
+	WrappedNativeAddress common.Address
+
+	// The recommended maximum number of calls in a single multicall
+	CallBatchSize int
+
+	// The recommended maximum size of a single multicall's packed call data in bytes (0 = no limit)
+	CallDataSizeLimit int
+
+	// The recommended maximum expected size of a single multicall response in bytes (0 = no limit)
+	ReturnSizeLimit int
+
+	// The recommended gas limit for each multicall's eth_call (0 = the node's default).
+	// Nodes clamp requests above their own RPC gas cap, so this is an upper bound rather than a guarantee.
+	GasLimit uint64
+}
+
+// Built-in profiles for well-known chains.
+// The chunk sizes are conservative starting points; Arbitrum's gas accounting allows far larger aggregated calls than Ethereum's,
+// so it gets a much higher gas limit than the 50M that geth-based nodes allow for eth_call by default.
+var knownProfiles = []ChainProfile{
+	{
+		Name:                  "Ethereum",
+		ChainID:               1,
+		MulticallAddress:      Multicall3Address,
+		BalanceBatcherAddress: MainnetBalanceCheckerAddress,
+		WrappedNativeAddress:  MainnetWethAddress,
+		CallBatchSize:         500,
+		ReturnSizeLimit:       4 * 1024 * 1024,
+		GasLimit:              50_000_000,
+	},
+	{
+		Name:             "Holesky",
+		ChainID:          17000,
+		MulticallAddress: Multicall3Address,
+		CallBatchSize:    500,
+		ReturnSizeLimit:  4 * 1024 * 1024,
+		GasLimit:         50_000_000,
+	},
+	{
+		Name:                 "Sepolia",
+		ChainID:              11155111,
+		MulticallAddress:     Multicall3Address,
+		WrappedNativeAddress: common.HexToAddress("0xfFf9976782d46CC05630D1f6eBAb18b2324d6B14"),
+		CallBatchSize:        500,
+		ReturnSizeLimit:      4 * 1024 * 1024,
+		GasLimit:             50_000_000,
+	},
+	{
+		Name:                 "Gnosis",
+		ChainID:              100,
+		MulticallAddress:     Multicall3Address,
+		WrappedNativeAddress: common.HexToAddress("0xe91D153E0b41518A2Ce8Dd3D7944Fa863463a97d"),
+		CallBatchSize:        500,
+		ReturnSizeLimit:      4 * 1024 * 1024,
+		GasLimit:             50_000_000,
+	},
+	{
+		Name:                 "Polygon",
+		ChainID:              137,
+		MulticallAddress:     Multicall3Address,
+		WrappedNativeAddress: common.HexToAddress("0x0d500B1d8E8eF31E21C99d1Db9A6444d3ADf1270"),
+		CallBatchSize:        500,
+		ReturnSizeLimit:      4 * 1024 * 1024,
+		GasLimit:             50_000_000,
+	},
+	{
+		Name:                 "Arbitrum One",
+		ChainID:              42161,
+		MulticallAddress:     Multicall3Address,
+		WrappedNativeAddress: common.HexToAddress("0x82aF49447D8a07e3bd95BD0d56f35241523fBab1"),
+		CallBatchSize:        2000,
+		ReturnSizeLimit:      8 * 1024 * 1024,
+		GasLimit:             250_000_000,
+	},
+	{
+		Name:                  "OP Mainnet",
+		ChainID:               10,
+		MulticallAddress:      Multicall3Address,
+		GasPriceOracleAddress: OpStackGasPriceOracleAddress,
+		WrappedNativeAddress:  OpStackWethAddress,
+		CallBatchSize:         1000,
+		ReturnSizeLimit:       4 * 1024 * 1024,
+		GasLimit:              50_000_000,
+	},
+	{
+		Name:                  "Base",
+		ChainID:               8453,
+		MulticallAddress:      Multicall3Address,
+		GasPriceOracleAddress: OpStackGasPriceOracleAddress,
+		WrappedNativeAddress:  OpStackWethAddress,
+		CallBatchSize:         1000,
+		ReturnSizeLimit:       4 * 1024 * 1024,
+		GasLimit:              50_000_000,
+	},
+}
+
+// Gets the built-in profile for a chain, if there is one
+func GetChainProfile(chainID uint64) (ChainProfile, bool) {
+	for _, profile := range knownProfiles {
+		if profile.ChainID == chainID {
+			return profile, true
+		}
+	}
+	return ChainProfile{}, false
+}
+
+// Gets all of the built-in chain profiles
+func GetChainProfiles() []ChainProfile {
+	return append([]ChainProfile{}, knownProfiles...)
+}
+
+// Creates a ChainConfig for a ChainSet from the profile, using the provided client
+func (p ChainProfile) Config(client IContractCaller, balanceBatchSize int, balanceThreadLimit int) ChainConfig {
+	return ChainConfig{
+		Client:                client,
+		MulticallAddress:      p.MulticallAddress,
+		BalanceBatcherAddress: p.BalanceBatcherAddress,
+		WrappedNativeAddress:  p.WrappedNativeAddress,
+		BalanceBatchSize:      balanceBatchSize,
+		BalanceThreadLimit:    balanceThreadLimit,
+		CallBatchSize:         p.CallBatchSize,
+		CallDataSizeLimit:     p.CallDataSizeLimit,
+		ReturnSizeLimit:       p.ReturnSizeLimit,
+		GasLimit:              p.GasLimit,
+	}
+}
+
+// Creates a new MultiCaller for the profile's chain, using its multicall contract, chunk sizes, and gas limit
+func (p ChainProfile) NewMultiCaller(client IContractCaller) (*MultiCaller, error) {
+	mc, err := NewMultiCaller(client, p.MulticallAddress)
+	if err != nil {
+		return nil, err
+	}
+	p.Apply(mc)
+	return mc, nil
+}
+
+// Applies the profile's chunk sizes and gas limit to an existing MultiCaller.
+// Only the settings that haven't been configured yet (which are still 0) are changed, so explicit settings are kept.
+func (p ChainProfile) Apply(mc *MultiCaller) {
+	if mc.CallBatchSize == 0 {
+		mc.CallBatchSize = p.CallBatchSize
+	}
+	if mc.CallDataSizeLimit == 0 {
+		mc.CallDataSizeLimit = p.CallDataSizeLimit
+	}
+	if mc.ReturnSizeLimit == 0 {
+		mc.ReturnSizeLimit = p.ReturnSizeLimit
+	}
+	if mc.GasLimit == 0 {
+		mc.GasLimit = p.GasLimit
+	}
+}
+
+// Adds a call for the L1 data fee that an OP Stack chain would charge for a transaction with the provided serialized data.
+// The profile must have a GasPriceOracleAddress.
+func (p ChainProfile) AddL1FeeCall(mc *MultiCaller, txData []byte, fee **big.Int) (*Call, error) {
+	if p.GasPriceOracleAddress == (common.Address{}) {
+		return nil, fmt.Errorf("chain %d does not have a gas price oracle", p.ChainID)
+	}
+	oracleAbi, err := getGasPriceOracleAbi()
+	if err != nil {
+		return nil, err
+	}
+	return mc.AddCall(p.GasPriceOracleAddress, oracleAbi, fee, "getL1Fee", txData), nil
+}
+
+// Adds a call for the current L1 base fee as reported by an OP Stack chain.
+// The profile must have a GasPriceOracleAddress.
+func (p ChainProfile) AddL1BaseFeeCall(mc *MultiCaller, baseFee **big.Int) (*Call, error) {
+	if p.GasPriceOracleAddress == (common.Address{}) {
+		return nil, fmt.Errorf("chain %d does not have a gas price oracle", p.ChainID)
+	}
+	oracleAbi, err := getGasPriceOracleAbi()
+	if err != nil {
+		return nil, err
+	}
+	return mc.AddCall(p.GasPriceOracleAddress, oracleAbi, baseFee, "l1BaseFee"), nil
+}
+
+// Gets the parsed ABI for the OP Stack GasPriceOracle
+func getGasPriceOracleAbi() (*abi.ABI, error) {
+	var err error
+	gpoOnce.Do(func() {
+		var parsedAbi abi.ABI
+		parsedAbi, err = abi.JSON(strings.NewReader(gasPriceOracleAbiString))
+		if err == nil {
+			gasPriceOracleAbi = parsedAbi
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &gasPriceOracleAbi, nil
+}
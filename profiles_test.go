@@ -0,0 +1,30 @@
+package batchquery
+
+import "testing"
+
+func TestChainProfileApplyKeepsExplicitSettings(t *testing.T) {
+	profile, exists := GetChainProfile(42161)
+	if !exists {
+		t.Fatal("expected a profile for Arbitrum One")
+	}
+	ethereum, _ := GetChainProfile(1)
+	if profile.GasLimit <= ethereum.GasLimit {
+		t.Fatalf("expected Arbitrum's gas limit (%d) to be higher than Ethereum's (%d)", profile.GasLimit, ethereum.GasLimit)
+	}
+
+	mc, _ := newTestMultiCaller(t)
+	mc.CallBatchSize = 10
+	mc.CallDataSizeLimit = 1000
+	profile.Apply(mc)
+	if mc.CallBatchSize != 10 || mc.CallDataSizeLimit != 1000 {
+		t.Fatalf("expected explicit settings to be kept, got batch size %d and call data limit %d", mc.CallBatchSize, mc.CallDataSizeLimit)
+	}
+	if mc.ReturnSizeLimit != profile.ReturnSizeLimit || mc.GasLimit != profile.GasLimit {
+		t.Fatalf("expected unset settings to come from the profile, got return limit %d and gas limit %d", mc.ReturnSizeLimit, mc.GasLimit)
+	}
+
+	config := profile.Config(nil, 100, 1)
+	if config.GasLimit != profile.GasLimit {
+		t.Fatalf("expected the chain config to use the profile's gas limit, got %d", config.GasLimit)
+	}
+}
@@ -0,0 +1,229 @@
+package batchquery
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"math/big"
+	"time"
+
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/rlp"
+)
+
+// A request for the proofs of an account and some of its storage slots
+type ProofRequest struct {
+	// The address of the account
+	Address common.Address
+
+	// The storage slots to prove
+	Slots []common.Hash
+}
+
+// The proofs of an account and some of its storage slots, as returned by eth_getProof
+type AccountProof struct {
+	// The address of the account
+	Address common.Address `json:"address"`
+
+	// The hex-encoded trie nodes proving the account against the state root
+	AccountProof []string `json:"accountProof"`
+
+	// The account's reported ETH balance
+	Balance *big.Int `json:"balance"`
+
+	// The account's reported code hash
+	CodeHash common.Hash `json:"codeHash"`
+
+	// The account's reported nonce
+	Nonce uint64 `json:"nonce"`
+
+	// The reported root hash of the account's storage trie
+	StorageHash common.Hash `json:"storageHash"`
+
+	// The proofs of the requested storage slots, in the same order as they were requested
+	StorageProof []StorageProof `json:"storageProof"`
+}
+
+// The proof of a single storage slot, as returned by eth_getProof
+type StorageProof struct {
+	// The slot, which some clients return as a compact quantity (such as "0x0") rather than a full 32-byte hash
+	Key string `json:"key"`
+
+	// The slot's reported value
+	Value *big.Int `json:"value"`
+
+	// The hex-encoded trie nodes proving the slot against the account's storage root
+	Proof []string `json:"proof"`
+}
+
+// The values of an account and some of its storage slots, verified against a state root
+type VerifiedAccount struct {
+	// The address of the account
+	Address common.Address
+
+	// The account's nonce
+	Nonce uint64
+
+	// The account's ETH balance
+	Balance *big.Int
+
+	// The root hash of the account's storage trie
+	StorageHash common.Hash
+
+	// The hash of the account's code
+	CodeHash common.Hash
+
+	// The values of the requested storage slots, in the same order as they were requested
+	Storage []*big.Int
+}
+
+// This struct can fetch Merkle proofs for many accounts and storage slots concurrently using eth_getProof,
+// and verify them against a state root. This provides trust-minimized reads of critical values from an untrusted RPC provider,
+// as long as the state root itself comes from a trusted source (such as a light client).
+type ProofBatcher struct {
+	// The number of proofs to fetch simultaneously
+	ThreadLimit int
+
+	// The context that proofs are fetched in when their options don't provide one (nil = context.Background())
+	BaseContext context.Context
+
+	// The deadline for each eth_getProof request, so every request has one even when the caller's context doesn't (0 = no deadline beyond the one in the context)
+	DefaultTimeout time.Duration
+
+	// The executor that runs the batcher's requests (nil = no shared executor; see Executor)
+	Executor *Executor
+
+	// The Execution client binding
+	client IProofGetter
+}
+
+// Creates a new ProofBatcher instance
+func NewProofBatcher(client IProofGetter, threadLimit int) *ProofBatcher {
+	return &ProofBatcher{
+		client:      client,
+		ThreadLimit: threadLimit,
+	}
+}
+
+// Fetches the proofs for each of the requests and verifies them against the provided state root, which must be the state root of the block in opts.
+// The order of the resulting array corresponds to the order of the provided requests.
+// If any proof fails verification, an error is returned. The pending block isn't supported, since it doesn't have a state root.
+func (b *ProofBatcher) GetVerifiedAccounts(requests []ProofRequest, stateRoot common.Hash, opts *bind.CallOpts) ([]VerifiedAccount, error) {
+	options := newCallOptionsWithBase(opts, b.BaseContext)
+	if options.pending {
+		return nil, fmt.Errorf("proofs can't be verified against the pending block, since it doesn't have a state root")
+	}
+	if options.from != (common.Address{}) {
+		return nil, fmt.Errorf("proofs don't have a sender, so opts can't specify a From address")
+	}
+	accounts := make([]VerifiedAccount, len(requests))
+
+	// A failure in any proof cancels the rest of them
+	err := b.Executor.run(options.ctx, b.ThreadLimit, "eth_getProof", len(requests), nil, func(ctx context.Context, i int) error {
+		request := requests[i]
+		account, err := b.getVerifiedAccount(ctx, request, stateRoot, options)
+		if err != nil {
+			return err
+		}
+		accounts[i] = *account
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error getting verified accounts: %w", err)
+	}
+	return accounts, nil
+}
+
+// Fetches and verifies the proofs for a single request
+func (b *ProofBatcher) getVerifiedAccount(ctx context.Context, request ProofRequest, stateRoot common.Hash, options *callOptions) (*VerifiedAccount, error) {
+	keys := make([]string, len(request.Slots))
+	for i, slot := range request.Slots {
+		keys[i] = slot.Hex()
+	}
+	ctx, cancel := withRequestTimeout(ctx, b.DefaultTimeout)
+	defer cancel()
+	result, err := options.getProof(ctx, b.client, request.Address, keys)
+	if err != nil {
+		return nil, fmt.Errorf("error getting proof for account %s: %w", request.Address.Hex(), wrapClientError(err))
+	}
+	if result.Address != request.Address {
+		return nil, fmt.Errorf("received proof for account %s instead of %s", result.Address.Hex(), request.Address.Hex())
+	}
+	if len(result.StorageProof) != len(request.Slots) {
+		return nil, fmt.Errorf("received %d storage proofs for account %s which mismatches the %d requested slots", len(result.StorageProof), request.Address.Hex(), len(request.Slots))
+	}
+	for i, storageProof := range result.StorageProof {
+		if common.HexToHash(storageProof.Key) != request.Slots[i] {
+			return nil, fmt.Errorf("received storage proof for key %s instead of %s on account %s", storageProof.Key, request.Slots[i].Hex(), request.Address.Hex())
+		}
+	}
+	return VerifyAccountProof(result, stateRoot)
+}
+
+// Verifies an account proof from eth_getProof and all of its storage proofs against a state root, returning the verified values
+func VerifyAccountProof(result *AccountProof, stateRoot common.Hash) (*VerifiedAccount, error) {
+	// Verify the account against the state root
+	accountRlp, err := verifyProof(stateRoot, crypto.Keccak256(result.Address.Bytes()), result.AccountProof)
+	if err != nil {
+		return nil, fmt.Errorf("error verifying proof for account %s: %w", result.Address.Hex(), err)
+	}
+	account := types.StateAccount{
+		Balance:  big.NewInt(0),
+		Root:     types.EmptyRootHash,
+		CodeHash: types.EmptyCodeHash.Bytes(),
+	}
+	if len(accountRlp) > 0 {
+		err = rlp.DecodeBytes(accountRlp, &account)
+		if err != nil {
+			return nil, fmt.Errorf("error decoding account %s: %w", result.Address.Hex(), err)
+		}
+	}
+
+	// Make sure the reported values match the proven ones
+	balance := result.Balance
+	if balance == nil {
+		balance = big.NewInt(0)
+	}
+	if account.Nonce != result.Nonce || account.Balance.Cmp(balance) != 0 || account.Root != result.StorageHash || !bytes.Equal(account.CodeHash, result.CodeHash.Bytes()) {
+		return nil, fmt.Errorf("reported values for account %s do not match its proof", result.Address.Hex())
+	}
+
+	// Verify each storage slot against the storage root
+	storage := make([]*big.Int, len(result.StorageProof))
+	for i, storageProof := range result.StorageProof {
+		slot := common.HexToHash(storageProof.Key)
+		valueRlp, err := verifyProof(account.Root, crypto.Keccak256(slot.Bytes()), storageProof.Proof)
+		if err != nil {
+			return nil, fmt.Errorf("error verifying proof for slot %s of account %s: %w", slot.Hex(), result.Address.Hex(), err)
+		}
+		value := big.NewInt(0)
+		if len(valueRlp) > 0 {
+			var valueBytes []byte
+			err = rlp.DecodeBytes(valueRlp, &valueBytes)
+			if err != nil {
+				return nil, fmt.Errorf("error decoding slot %s of account %s: %w", slot.Hex(), result.Address.Hex(), err)
+			}
+			value.SetBytes(valueBytes)
+		}
+		reported := storageProof.Value
+		if reported == nil {
+			reported = big.NewInt(0)
+		}
+		if value.Cmp(reported) != 0 {
+			return nil, fmt.Errorf("reported value for slot %s of account %s does not match its proof", slot.Hex(), result.Address.Hex())
+		}
+		storage[i] = value
+	}
+
+	return &VerifiedAccount{
+		Address:     result.Address,
+		Nonce:       account.Nonce,
+		Balance:     account.Balance,
+		StorageHash: account.Root,
+		CodeHash:    common.BytesToHash(account.CodeHash),
+		Storage:     storage,
+	}, nil
+}
@@ -0,0 +1,256 @@
+package batchquery
+
+import (
+	"context"
+	"math/big"
+	"strings"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/rlp"
+)
+
+// Hex-prefix encodes a leaf node's path
+func compactLeafPath(nibbles []byte) []byte {
+	var compact []byte
+	if len(nibbles)%2 == 1 {
+		compact = append(compact, 0x30|nibbles[0])
+		nibbles = nibbles[1:]
+	} else {
+		compact = append(compact, 0x20)
+	}
+	for i := 0; i < len(nibbles); i += 2 {
+		compact = append(compact, nibbles[i]<<4|nibbles[i+1])
+	}
+	return compact
+}
+
+// Encodes a leaf node
+func leafNode(t *testing.T, nibbles []byte, value []byte) []byte {
+	node, err := rlp.EncodeToBytes([][]byte{compactLeafPath(nibbles), value})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return node
+}
+
+// Encodes a branch node whose children are referenced by hash
+func branchNode(t *testing.T, children map[byte][]byte) []byte {
+	items := make([][]byte, 17)
+	for i := range items {
+		items[i] = []byte{}
+	}
+	for nibble, child := range children {
+		items[nibble] = crypto.Keccak256(child)
+	}
+	node, err := rlp.EncodeToBytes(items)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return node
+}
+
+// Encodes an account for the state trie
+func accountValue(t *testing.T, balance int64, storageRoot common.Hash) []byte {
+	value, err := rlp.EncodeToBytes(&types.StateAccount{
+		Nonce:    1,
+		Balance:  big.NewInt(balance),
+		Root:     storageRoot,
+		CodeHash: types.EmptyCodeHash.Bytes(),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return value
+}
+
+// Encodes a storage value for the storage trie
+func storageValue(t *testing.T, value int64) []byte {
+	encoded, err := rlp.EncodeToBytes(big.NewInt(value).Bytes())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return encoded
+}
+
+// Hex encodes a list of proof nodes
+func encodeProof(nodes ...[]byte) []string {
+	proof := make([]string, len(nodes))
+	for i, node := range nodes {
+		proof[i] = hexutil.Encode(node)
+	}
+	return proof
+}
+
+// A proof getter that serves canned proofs
+type mockProofGetter struct {
+	proofs map[common.Address]*AccountProof
+}
+
+func (m *mockProofGetter) GetProof(ctx context.Context, account common.Address, keys []string, blockNumber *big.Int) (*AccountProof, error) {
+	return m.proofs[account], nil
+}
+
+func TestVerifyAccountProofWithStorage(t *testing.T) {
+	address := common.HexToAddress("0x1234")
+	slot := common.Hash{}
+
+	// A storage trie with a single slot, and a state trie with a single account
+	storageLeaf := leafNode(t, keyToNibbles(crypto.Keccak256(slot.Bytes())), storageValue(t, 42))
+	storageRoot := crypto.Keccak256Hash(storageLeaf)
+	accountLeaf := leafNode(t, keyToNibbles(crypto.Keccak256(address.Bytes())), accountValue(t, 100, storageRoot))
+	stateRoot := crypto.Keccak256Hash(accountLeaf)
+
+	proof := &AccountProof{
+		Address:      address,
+		AccountProof: encodeProof(accountLeaf),
+		Balance:      big.NewInt(100),
+		CodeHash:     types.EmptyCodeHash,
+		Nonce:        1,
+		StorageHash:  storageRoot,
+		StorageProof: []StorageProof{{
+			// Some clients return keys as compact quantities
+			Key:   "0x0",
+			Value: big.NewInt(42),
+			Proof: encodeProof(storageLeaf),
+		}},
+	}
+	account, err := VerifyAccountProof(proof, stateRoot)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if account.Balance.Int64() != 100 || account.Storage[0].Int64() != 42 {
+		t.Fatalf("got balance %s and slot value %s", account.Balance, account.Storage[0])
+	}
+
+	// A tampered slot value must be rejected
+	proof.StorageProof[0].Value = big.NewInt(43)
+	_, err = VerifyAccountProof(proof, stateRoot)
+	if err == nil {
+		t.Fatal("expected an error for a tampered slot value")
+	}
+
+	// So must a tampered balance
+	proof.StorageProof[0].Value = big.NewInt(42)
+	proof.Balance = big.NewInt(101)
+	_, err = VerifyAccountProof(proof, stateRoot)
+	if err == nil {
+		t.Fatal("expected an error for a tampered balance")
+	}
+}
+
+func TestVerifyAccountProofWithEmptyStorage(t *testing.T) {
+	address := common.HexToAddress("0x1234")
+	accountLeaf := leafNode(t, keyToNibbles(crypto.Keccak256(address.Bytes())), accountValue(t, 5, types.EmptyRootHash))
+	stateRoot := crypto.Keccak256Hash(accountLeaf)
+
+	// Clients return an empty storage proof for accounts without storage
+	account, err := VerifyAccountProof(&AccountProof{
+		Address:      address,
+		AccountProof: encodeProof(accountLeaf),
+		Balance:      big.NewInt(5),
+		CodeHash:     types.EmptyCodeHash,
+		Nonce:        1,
+		StorageHash:  types.EmptyRootHash,
+		StorageProof: []StorageProof{{
+			Key:   common.Hash{}.Hex(),
+			Value: big.NewInt(0),
+			Proof: []string{},
+		}},
+	}, stateRoot)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if account.Storage[0].Sign() != 0 {
+		t.Fatalf("expected a zero slot value, got %s", account.Storage[0])
+	}
+}
+
+func TestGetVerifiedAccountsWithBranch(t *testing.T) {
+	// Find two addresses whose hashed keys diverge at the first nibble
+	first := common.HexToAddress("0x01")
+	second := common.HexToAddress("0x02")
+	for i := int64(3); keyToNibbles(crypto.Keccak256(first.Bytes()))[0] == keyToNibbles(crypto.Keccak256(second.Bytes()))[0]; i++ {
+		second = common.BigToAddress(big.NewInt(i))
+	}
+
+	firstPath := keyToNibbles(crypto.Keccak256(first.Bytes()))
+	secondPath := keyToNibbles(crypto.Keccak256(second.Bytes()))
+	firstLeaf := leafNode(t, firstPath[1:], accountValue(t, 10, types.EmptyRootHash))
+	secondLeaf := leafNode(t, secondPath[1:], accountValue(t, 20, types.EmptyRootHash))
+	branch := branchNode(t, map[byte][]byte{
+		firstPath[0]:  firstLeaf,
+		secondPath[0]: secondLeaf,
+	})
+	stateRoot := crypto.Keccak256Hash(branch)
+
+	// A third address that isn't in the trie is proven absent through a branch slot or a diverging leaf
+	absent := common.HexToAddress("0x03")
+	absentPath := keyToNibbles(crypto.Keccak256(absent.Bytes()))
+	absentProof := encodeProof(branch)
+	if absentPath[0] == firstPath[0] {
+		absentProof = encodeProof(branch, firstLeaf)
+	} else if absentPath[0] == secondPath[0] {
+		absentProof = encodeProof(branch, secondLeaf)
+	}
+
+	getter := &mockProofGetter{proofs: map[common.Address]*AccountProof{}}
+	for _, account := range []struct {
+		address common.Address
+		leaf    []byte
+		balance int64
+	}{{first, firstLeaf, 10}, {second, secondLeaf, 20}} {
+		getter.proofs[account.address] = &AccountProof{
+			Address:      account.address,
+			AccountProof: encodeProof(branch, account.leaf),
+			Balance:      big.NewInt(account.balance),
+			CodeHash:     types.EmptyCodeHash,
+			Nonce:        1,
+			StorageHash:  types.EmptyRootHash,
+		}
+	}
+	getter.proofs[absent] = &AccountProof{
+		Address:      absent,
+		AccountProof: absentProof,
+		Balance:      big.NewInt(0),
+		CodeHash:     types.EmptyCodeHash,
+		StorageHash:  types.EmptyRootHash,
+	}
+
+	batcher := NewProofBatcher(getter, 2)
+	accounts, err := batcher.GetVerifiedAccounts([]ProofRequest{{Address: first}, {Address: second}, {Address: absent}}, stateRoot, nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if accounts[0].Balance.Int64() != 10 || accounts[1].Balance.Int64() != 20 || accounts[2].Balance.Sign() != 0 {
+		t.Fatalf("got balances %s, %s, %s", accounts[0].Balance, accounts[1].Balance, accounts[2].Balance)
+	}
+
+	// A proof with a missing node must be rejected
+	getter.proofs[first].AccountProof = encodeProof(branch)
+	_, err = batcher.GetVerifiedAccounts([]ProofRequest{{Address: first}}, stateRoot, nil)
+	if err == nil || !strings.Contains(err.Error(), "missing") {
+		t.Fatalf("expected a missing node error, got %v", err)
+	}
+}
+
+func TestGetVerifiedAccountsRejectsPendingAndSender(t *testing.T) {
+	batcher := NewProofBatcher(&mockProofGetter{}, 0)
+	requests := []ProofRequest{{Address: common.HexToAddress("0x1234")}}
+	_, err := batcher.GetVerifiedAccounts(requests, common.Hash{}, &bind.CallOpts{Pending: true})
+	if err == nil {
+		t.Fatal("expected proofs against the pending block to be rejected")
+	}
+	_, err = batcher.GetVerifiedAccounts(requests, common.Hash{}, &bind.CallOpts{From: common.HexToAddress("0x01")})
+	if err == nil {
+		t.Fatal("expected proofs with a sender to be rejected")
+	}
+	_, err = batcher.GetVerifiedAccounts(requests, common.Hash{}, CallOptsAtHash(nil, common.HexToHash("0x01")))
+	if err == nil {
+		t.Fatal("expected proofs at a block hash to fail without client support")
+	}
+}
@@ -0,0 +1,20 @@
+package batchquery
+
+import (
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+)
+
+// Runs a single call on its own with the client and settings of the provided MultiCaller, and returns its result.
+// The call is added by add, which receives an empty MultiCaller and the output to unpack into, so typed helpers that add a call to a batch
+// (such as the ones generated by batchgen) can also be used to run it directly. The MultiCaller's own list of pending calls is not affected.
+func QueryCall[T any](mc *MultiCaller, opts *bind.CallOpts, add func(mc *MultiCaller, output *T) *Call) (T, error) {
+	var output T
+	runner := mc.withCalls([]*Call{})
+	add(runner, &output)
+	_, err := runner.FlexibleCall(true, opts)
+	if err != nil {
+		var empty T
+		return empty, err
+	}
+	return output, nil
+}
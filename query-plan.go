@@ -0,0 +1,234 @@
+package batchquery
+
+import (
+	"errors"
+	"reflect"
+)
+
+// What a call reads through a client, which identifies the same call across independent batches
+type sharedCallKey struct {
+	// The client the call is sent through
+	client IContractCaller
+
+	// The multicall contract, sender, block, target, and call data of the call
+	read string
+}
+
+// A call in flight through an executor, whose response is shared with the other batches that run the same call at the same time
+type sharedCall struct {
+	// Closed once the batch running the call has finished
+	done chan struct{}
+
+	// The call's response, if the batch running it succeeded
+	response CallResponse
+
+	// Whether the batch running the call succeeded, so the response can be used
+	ok bool
+}
+
+// Merges the calls of a batch that read the same thing (the same target and call data at the same block) into a single call,
+// such as a balance that's requested directly and again as part of a portfolio query built into the same batch.
+// Returns the distinct calls, and the index of the distinct call that serves each of the original ones.
+// A merged call takes the highest priority of the calls it replaces, so it's never run later than any of them would have been.
+func mergeDuplicateCalls(calls []*Call) ([]*Call, []int) {
+	distinct := make([]*Call, 0, len(calls))
+	sources := make([]int, len(calls))
+	indices := make(map[string]int, len(calls))
+	for i, call := range calls {
+		key := getCallPlanKey(call)
+		index, exists := indices[key]
+		if !exists {
+			indices[key] = len(distinct)
+			sources[i] = len(distinct)
+			distinct = append(distinct, call)
+			continue
+		}
+
+		sources[i] = index
+		if call.Priority > distinct[index].Priority {
+			// Copy the call so the original keeps its own priority
+			merged := *distinct[index]
+			merged.Priority = call.Priority
+			distinct[index] = &merged
+		}
+	}
+	return distinct, sources
+}
+
+// Runs a batch with duplicate calls by running each distinct call once, and sharing its response between the calls it replaced
+func (mc *MultiCaller) executeMerged(calls []*Call, distinct []*Call, sources []int, requireSuccess bool, opts *callOptions) ([]CallResponse, error) {
+	// The distinct calls have no duplicates, so this doesn't merge them again
+	distinctResponses, err := mc.executeVerified(distinct, requireSuccess, opts)
+	if err != nil {
+		// Report reverts by the index of the first call they replaced
+		var reverted *ErrCallReverted
+		if errors.As(err, &reverted) && reverted.Index >= 0 {
+			for i, source := range sources {
+				if source == reverted.Index {
+					reverted.Index = i
+					break
+				}
+			}
+		}
+		return nil, err
+	}
+
+	responses := make([]CallResponse, len(calls))
+	for i, source := range sources {
+		responses[i] = distinctResponses[source]
+	}
+	return responses, nil
+}
+
+// Runs a batch through an executor that shares calls between batches: the calls another batch is already running are served by that batch's responses,
+// and the rest are run by this batch and shared with the batches that run them in the meantime
+func (mc *MultiCaller) executeShared(calls []*Call, keys []sharedCallKey, requireSuccess bool, opts *callOptions) ([]CallResponse, error) {
+	shared, leads := mc.Executor.claimCalls(keys)
+	responses := make([]CallResponse, len(calls))
+
+	// Run the calls this batch leads, sharing their responses (or their failure, so the other batches run them on their own)
+	led := []*Call{}
+	ledIndices := []int{}
+	for i, call := range calls {
+		if leads[i] {
+			led = append(led, call)
+			ledIndices = append(ledIndices, i)
+		}
+	}
+	if len(led) > 0 {
+		ledResponses, err := mc.executeUnshared(led, requireSuccess, opts)
+		for j, index := range ledIndices {
+			if err == nil {
+				responses[index] = ledResponses[j]
+				mc.Executor.settleCall(keys[index], shared[index], &ledResponses[j])
+			} else {
+				mc.Executor.settleCall(keys[index], shared[index], nil)
+			}
+		}
+		if err != nil {
+			return nil, remapRevertIndex(err, ledIndices)
+		}
+	}
+
+	// Wait for the calls other batches are running, and run the ones that failed there
+	retried := []*Call{}
+	retriedIndices := []int{}
+	for i, call := range calls {
+		if leads[i] {
+			continue
+		}
+		select {
+		case <-shared[i].done:
+		case <-opts.ctx.Done():
+			return nil, opts.ctx.Err()
+		}
+		if !shared[i].ok {
+			retried = append(retried, call)
+			retriedIndices = append(retriedIndices, i)
+			continue
+		}
+		responses[i] = shared[i].response
+		if requireSuccess && !responses[i].Status {
+			return nil, &ErrCallReverted{
+				Index:       i,
+				Target:      call.Target,
+				Method:      call.Method,
+				Data:        responses[i].ReturnData,
+				contractAbi: call.contractAbi,
+			}
+		}
+	}
+	if len(retried) > 0 {
+		retriedResponses, err := mc.executeUnshared(retried, requireSuccess, opts)
+		if err != nil {
+			return nil, remapRevertIndex(err, retriedIndices)
+		}
+		for j, index := range retriedIndices {
+			responses[index] = retriedResponses[j]
+		}
+	}
+	return responses, nil
+}
+
+// Gets the keys that identify the calls of a batch across the batches that share the MultiCaller's executor,
+// or nil if the batch's calls can't be shared
+func (mc *MultiCaller) getSharedCallKeys(calls []*Call, opts *callOptions) []sharedCallKey {
+	if !mc.Executor.sharesCalls() || mc.Verifier != nil || mc.SpotCheckRate > 0 || mc.ShadowHandler != nil {
+		return nil
+	}
+
+	// Clients are told apart by their identity, which only works for comparable ones such as pointers
+	if !reflect.TypeOf(mc.client).Comparable() {
+		return nil
+	}
+	prefix := string(mc.contractAddress[:]) + string(opts.from[:])
+	keys := make([]sharedCallKey, len(calls))
+	for i, call := range calls {
+		keys[i] = sharedCallKey{
+			client: mc.client,
+			read:   prefix + call.targetOptions(opts).blockDescription() + ":" + string(call.Target[:]) + string(call.CallData),
+		}
+	}
+	return keys
+}
+
+// Reports a revert in a subset of a batch's calls by the call's index within the whole batch
+func remapRevertIndex(err error, indices []int) error {
+	var reverted *ErrCallReverted
+	if errors.As(err, &reverted) && reverted.Index >= 0 && reverted.Index < len(indices) {
+		reverted.Index = indices[reverted.Index]
+	}
+	return err
+}
+
+// Whether the executor shares calls between the batches that use it
+func (e *Executor) sharesCalls() bool {
+	return e != nil && e.MergeDuplicateCalls
+}
+
+// Gets the call in flight for each key, and whether the batch claiming them leads it, which means it has to run the call and settle it.
+// Keys that aren't in flight yet are led by the claiming batch.
+func (e *Executor) claimCalls(keys []sharedCallKey) ([]*sharedCall, []bool) {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+	if e.inflight == nil {
+		e.inflight = map[sharedCallKey]*sharedCall{}
+	}
+	calls := make([]*sharedCall, len(keys))
+	leads := make([]bool, len(keys))
+	for i, key := range keys {
+		call, exists := e.inflight[key]
+		if !exists {
+			call = &sharedCall{
+				done: make(chan struct{}),
+			}
+			e.inflight[key] = call
+			leads[i] = true
+		}
+		calls[i] = call
+	}
+	return calls, leads
+}
+
+// Settles a call the batch led with its response (nil = the batch failed), so the next batch to run it sends it again
+func (e *Executor) settleCall(key sharedCallKey, call *sharedCall, response *CallResponse) {
+	e.lock.Lock()
+	if e.inflight[key] == call {
+		delete(e.inflight, key)
+	}
+	e.lock.Unlock()
+	if response != nil {
+		call.response = *response
+		call.ok = true
+	}
+	close(call.done)
+}
+
+// Gets the key that identifies what a call reads. Block numbers only contain digits and signs, so the separator keeps the call data apart from them.
+func getCallPlanKey(call *Call) string {
+	block := ""
+	if call.BlockNumber != nil {
+		block = call.BlockNumber.String()
+	}
+	return string(call.Target[:]) + block + ":" + string(call.CallData)
+}
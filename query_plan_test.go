@@ -0,0 +1,198 @@
+package batchquery
+
+import (
+	"context"
+	"errors"
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+)
+
+func TestMergeDuplicateCalls(t *testing.T) {
+	mc, client := newTestMultiCaller(t)
+	mc.MergeDuplicateCalls = true
+	first := common.HexToAddress("0x0102")
+	second := common.HexToAddress("0x0203")
+
+	// The same reads requested by two parts of an application
+	var direct, fromPortfolio, other *big.Int
+	var ethDirect, ethFromPortfolio *big.Int
+	mc.AddCall(testTokenAddress, &testTokenAbi, &direct, "balanceOf", first)
+	mc.AddEthBalance(first, &ethDirect)
+	mc.AddCall(testTokenAddress, &testTokenAbi, &fromPortfolio, "balanceOf", first)
+	mc.AddEthBalance(first, &ethFromPortfolio)
+	mc.AddCall(testTokenAddress, &testTokenAbi, &other, "balanceOf", second)
+
+	// The same read at another block is a different read
+	var atBlock *big.Int
+	mc.AddCall(testTokenAddress, &testTokenAbi, &atBlock, "balanceOf", first).BlockNumber = big.NewInt(50)
+
+	successes, err := mc.FlexibleCall(true, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(successes) != 6 {
+		t.Fatalf("expected a success flag for every call, got %d", len(successes))
+	}
+	runCalls := 0
+	for _, size := range client.chunkSizes {
+		runCalls += size
+	}
+	if runCalls != 4 {
+		t.Fatalf("expected 4 distinct calls to run, got %d", runCalls)
+	}
+	if direct.Cmp(expectedBalance(first, 0)) != 0 || fromPortfolio.Cmp(direct) != 0 || ethFromPortfolio.Cmp(ethDirect) != 0 {
+		t.Fatalf("expected the merged calls to share their results, got %s, %s, %s, %s", direct, fromPortfolio, ethDirect, ethFromPortfolio)
+	}
+	if other.Cmp(expectedBalance(second, 0)) != 0 || atBlock.Cmp(expectedBalance(first, 50)) != 0 {
+		t.Fatalf("unexpected balances %s and %s", other, atBlock)
+	}
+}
+
+func TestMergeDuplicateCallsReportsRevertsByBatchIndex(t *testing.T) {
+	mc, _ := newTestMultiCaller(t)
+	mc.MergeDuplicateCalls = true
+	mc.CallBatchSize = 1
+	var balance, otherBalance, boom *big.Int
+	mc.AddCall(testTokenAddress, &testTokenAbi, &balance, "balanceOf", common.HexToAddress("0x07"))
+	mc.AddCall(testTokenAddress, &testTokenAbi, &otherBalance, "balanceOf", common.HexToAddress("0x07"))
+	mc.AddCall(testTokenAddress, &testTokenAbi, &boom, "boom")
+	_, err := mc.FlexibleCall(true, nil)
+	var reverted *ErrCallReverted
+	if !errors.As(err, &reverted) || reverted.Index != 2 || reverted.Method != "boom" {
+		t.Fatalf("expected the third call's revert to be reported, got %v", err)
+	}
+}
+
+func TestMergeDuplicateCallsKeepsHighestPriority(t *testing.T) {
+	low := &Call{Target: testTokenAddress, CallData: []byte{1}}
+	high := &Call{Target: testTokenAddress, CallData: []byte{1}, Priority: 5}
+	other := &Call{Target: testTokenAddress, CallData: []byte{2}}
+	distinct, sources := mergeDuplicateCalls([]*Call{low, other, high})
+	if len(distinct) != 2 || sources[0] != 0 || sources[1] != 1 || sources[2] != 0 {
+		t.Fatalf("unexpected plan: %d distinct calls, sources %v", len(distinct), sources)
+	}
+	if distinct[0].Priority != 5 || low.Priority != 0 {
+		t.Fatalf("expected the merged call to take the highest priority without changing the originals, got %d and %d", distinct[0].Priority, low.Priority)
+	}
+}
+
+func TestExecutorMergesCallsAcrossBatches(t *testing.T) {
+	client := &mockClient{}
+	executor := NewExecutor(0)
+	executor.MergeDuplicateCalls = true
+	newCaller := func() *MultiCaller {
+		mc, err := NewMultiCaller(client, testMulticallAddress)
+		if err != nil {
+			t.Fatal(err)
+		}
+		mc.Executor = executor
+		return mc
+	}
+	first := newCaller()
+	second := newCaller()
+	shared := common.HexToAddress("0x0102")
+
+	// The first batch's multicall is held until the second batch has sent its own, so the shared call is in flight while the second batch plans
+	started := make(chan struct{})
+	release := make(chan struct{})
+	calls := 0
+	client.hook = func(ctx context.Context, msg ethereum.CallMsg) error {
+		client.lock.Lock()
+		calls++
+		call := calls
+		client.lock.Unlock()
+		switch call {
+		case 1:
+			close(started)
+			<-release
+		case 2:
+			close(release)
+		}
+		return nil
+	}
+
+	var sharedFirst, sharedSecond, own, other *big.Int
+	first.AddCall(testTokenAddress, &testTokenAbi, &sharedFirst, "balanceOf", shared)
+	first.AddCall(testTokenAddress, &testTokenAbi, &own, "balanceOf", common.HexToAddress("0x0203"))
+	second.AddCall(testTokenAddress, &testTokenAbi, &sharedSecond, "balanceOf", shared)
+	second.AddCall(testTokenAddress, &testTokenAbi, &other, "balanceOf", common.HexToAddress("0x0304"))
+
+	errs := make(chan error, 2)
+	go func() {
+		_, err := first.FlexibleCall(true, nil)
+		errs <- err
+	}()
+	<-started
+	go func() {
+		_, err := second.FlexibleCall(true, nil)
+		errs <- err
+	}()
+	for i := 0; i < 2; i++ {
+		err := <-errs
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	runCalls := 0
+	for _, size := range client.chunkSizes {
+		runCalls += size
+	}
+	if runCalls != 3 {
+		t.Fatalf("expected the shared call to be sent once, got %d calls in chunks %v", runCalls, client.chunkSizes)
+	}
+	if sharedFirst.Cmp(expectedBalance(shared, 0)) != 0 || sharedSecond.Cmp(sharedFirst) != 0 {
+		t.Fatalf("expected both batches to get the shared balance, got %s and %s", sharedFirst, sharedSecond)
+	}
+	if own.Cmp(expectedBalance(common.HexToAddress("0x0203"), 0)) != 0 || other.Cmp(expectedBalance(common.HexToAddress("0x0304"), 0)) != 0 {
+		t.Fatalf("unexpected balances %s and %s", own, other)
+	}
+
+	// Once the batches are done, the call is sent again
+	client.hook = nil
+	second.AddCall(testTokenAddress, &testTokenAbi, &sharedSecond, "balanceOf", shared)
+	_, err := second.FlexibleCall(true, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(client.chunkSizes) != 3 {
+		t.Fatalf("expected a new multicall for the later batch, got chunks %v", client.chunkSizes)
+	}
+}
+
+func TestExecutorRetriesSharedCallsThatFailed(t *testing.T) {
+	client := &mockClient{}
+	executor := NewExecutor(0)
+	executor.MergeDuplicateCalls = true
+	mc, err := NewMultiCaller(client, testMulticallAddress)
+	if err != nil {
+		t.Fatal(err)
+	}
+	mc.Executor = executor
+
+	// A call that's in flight for a batch that then fails is sent by the waiting batch itself
+	shared := common.HexToAddress("0x0102")
+	data, err := testTokenAbi.Pack("balanceOf", shared)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keys := mc.getSharedCallKeys([]*Call{{Target: testTokenAddress, CallData: data}}, mc.newCallOptions(nil))
+	calls, leads := executor.claimCalls(keys)
+	if !leads[0] {
+		t.Fatal("expected the first claim to lead the call")
+	}
+	go executor.settleCall(keys[0], calls[0], nil)
+
+	var balance *big.Int
+	mc.AddCall(testTokenAddress, &testTokenAbi, &balance, "balanceOf", shared)
+	_, err = mc.FlexibleCall(true, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if balance.Cmp(expectedBalance(shared, 0)) != 0 || len(client.chunkSizes) != 1 {
+		t.Fatalf("expected the call to be sent by the waiting batch, got %s after chunks %v", balance, client.chunkSizes)
+	}
+}
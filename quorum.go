@@ -0,0 +1,170 @@
+package batchquery
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+	"sort"
+	"sync"
+
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// The results of running a batch on a quorum of endpoints
+type QuorumReport struct {
+	// The block the batch was run at
+	BlockNumber *big.Int
+
+	// The number of endpoints that had to succeed, and whose majority had to agree on each call's response
+	Quorum int
+
+	// The accepted result of each call, which is the response a majority of the quorum agreed on.
+	// Calls without a majority have an *ErrNoQuorum as their error instead, and no return data.
+	Results *Results
+
+	// The indices of the endpoints that were queried and succeeded, in order
+	Queried []int
+
+	// The error from each endpoint, in the same order as the provided callers (nil for endpoints that succeeded or weren't queried)
+	Errors []error
+
+	// The calls whose accepted result some of the endpoints disagreed with, in order
+	Dissents []QuorumDissent
+}
+
+// A call whose accepted result some of the queried endpoints disagreed with
+type QuorumDissent struct {
+	// The index of the call within the batch
+	Index int
+
+	// The contract address the call was run on
+	Target common.Address
+
+	// The name of the method that was called
+	Method string
+
+	// The indices of the endpoints whose responses differed from the accepted one, in order
+	Endpoints []int
+}
+
+// Runs the batch on a quorum of the provided MultiCallers concurrently, and only accepts each call's response if a majority of the quorum agree on it,
+// protecting against a single compromised or buggy RPC provider. The first quorum endpoints are queried; if any of them fail,
+// the next ones are queried in their place until a quorum has succeeded. The endpoints that disagreed with an accepted response are reported.
+// If opts doesn't specify a block number, every endpoint is queried at the latest block according to the first caller.
+// The pending block isn't supported, since each endpoint has its own view of it.
+// If a call has no majority, the report is returned along with an error matching *ErrNoQuorum for each such call.
+func (b *Batch) ExecuteQuorum(callers []*MultiCaller, quorum int, requireSuccess bool, opts *bind.CallOpts) (*QuorumReport, error) {
+	if quorum < 1 || quorum > len(callers) {
+		return nil, fmt.Errorf("quorum of %d is invalid for %d endpoints", quorum, len(callers))
+	}
+	runOpts, err := pinCallOpts(callers[0], opts)
+	if err != nil {
+		return nil, err
+	}
+
+	// Query endpoints in rounds, replacing the ones that failed with the next unqueried ones
+	responses := make([][]CallResponse, len(callers))
+	errs := make([]error, len(callers))
+	succeeded := []int{}
+	next := 0
+	for len(succeeded) < quorum && next < len(callers) {
+		count := quorum - len(succeeded)
+		if count > len(callers)-next {
+			count = len(callers) - next
+		}
+		var wg sync.WaitGroup
+		for i := next; i < next+count; i++ {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				responses[i], errs[i] = b.ExecuteRaw(callers[i], requireSuccess, runOpts)
+			}(i)
+		}
+		wg.Wait()
+		for i := next; i < next+count; i++ {
+			if errs[i] == nil {
+				succeeded = append(succeeded, i)
+			}
+		}
+		next += count
+	}
+	if len(succeeded) < quorum {
+		return nil, fmt.Errorf("only %d of %d endpoints succeeded, short of the quorum of %d: %w", len(succeeded), len(callers), quorum, firstError(errs))
+	}
+
+	report := &QuorumReport{
+		BlockNumber: new(big.Int).Set(runOpts.BlockNumber),
+		Quorum:      quorum,
+		Queried:     succeeded,
+		Errors:      errs,
+		Dissents:    []QuorumDissent{},
+	}
+	required := quorum/2 + 1
+	accepted := make([]CallResponse, len(b.calls))
+	noQuorum := []error{}
+	for i, call := range b.calls {
+		groups := groupResponses(responses, succeeded, i)
+		if len(groups[0]) < required {
+			noQuorum = append(noQuorum, &ErrNoQuorum{
+				Index:    i,
+				Target:   call.Target,
+				Method:   call.Method,
+				Required: required,
+				Groups:   groups,
+			})
+			continue
+		}
+		accepted[i] = responses[groups[0][0]][i]
+		if len(groups) > 1 {
+			dissenters := []int{}
+			for _, group := range groups[1:] {
+				dissenters = append(dissenters, group...)
+			}
+			sort.Ints(dissenters)
+			report.Dissents = append(report.Dissents, QuorumDissent{
+				Index:     i,
+				Target:    call.Target,
+				Method:    call.Method,
+				Endpoints: dissenters,
+			})
+		}
+	}
+
+	report.Results = newResults(b.calls, accepted, nil)
+	for _, err := range noQuorum {
+		index := err.(*ErrNoQuorum).Index
+		report.Results.results[index].Success = false
+		report.Results.results[index].Err = err
+	}
+	if len(noQuorum) > 0 {
+		return report, errors.Join(noQuorum...)
+	}
+	return report, nil
+}
+
+// Gets the indices of the endpoints that disagreed with the accepted result of at least one call, in order
+func (r *QuorumReport) Dissenters() []int {
+	seen := map[int]bool{}
+	dissenters := []int{}
+	for _, dissent := range r.Dissents {
+		for _, endpoint := range dissent.Endpoints {
+			if !seen[endpoint] {
+				seen[endpoint] = true
+				dissenters = append(dissenters, endpoint)
+			}
+		}
+	}
+	sort.Ints(dissenters)
+	return dissenters
+}
+
+// Gets the first non-nil error in a list
+func firstError(errs []error) error {
+	for _, err := range errs {
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,110 @@
+package batchquery
+
+import (
+	"errors"
+	"math/big"
+	"strings"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+)
+
+func TestQuorumReportsDissenters(t *testing.T) {
+	account := common.HexToAddress("0x0102")
+	honest, _ := newTestMultiCaller(t)
+	corrupt, corruptClient := newTestMultiCaller(t)
+	corruptClient.corrupt = true
+	other, _ := newTestMultiCaller(t)
+	batch := newBalanceBatch(t, honest, account)
+
+	report, err := batch.ExecuteQuorum([]*MultiCaller{honest, corrupt, other}, 3, true, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.BlockNumber.Int64() != 100 || report.Quorum != 3 || len(report.Queried) != 3 {
+		t.Fatalf("unexpected report %+v", report)
+	}
+	if len(report.Dissents) != 1 || report.Dissents[0].Index != 0 || len(report.Dissents[0].Endpoints) != 1 || report.Dissents[0].Endpoints[0] != 1 {
+		t.Fatalf("expected the corrupt endpoint to dissent, got %+v", report.Dissents)
+	}
+	if dissenters := report.Dissenters(); len(dissenters) != 1 || dissenters[0] != 1 {
+		t.Fatalf("unexpected dissenters %v", dissenters)
+	}
+	result, _ := report.Results.Get(account.Hex())
+	var balance *big.Int
+	err = result.Unpack(&balance)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if balance.Cmp(expectedBalance(account, 100)) != 0 {
+		t.Fatalf("expected the majority's balance, got %s", balance)
+	}
+}
+
+func TestQuorumReplacesFailedEndpoints(t *testing.T) {
+	account := common.HexToAddress("0x0102")
+	failing, failingClient := newTestMultiCaller(t)
+	failingClient.err = errors.New("endpoint down")
+	first, _ := newTestMultiCaller(t)
+	second, _ := newTestMultiCaller(t)
+	unused, unusedClient := newTestMultiCaller(t)
+	batch := newBalanceBatch(t, first, account)
+
+	callers := []*MultiCaller{failing, first, second, unused}
+	report, err := batch.ExecuteQuorum(callers, 2, true, &bind.CallOpts{BlockNumber: big.NewInt(50)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(report.Queried) != 2 || report.Queried[0] != 1 || report.Queried[1] != 2 {
+		t.Fatalf("expected the failed endpoint to be replaced by the next one, got %v", report.Queried)
+	}
+	if report.Errors[0] == nil || report.Errors[3] != nil || unusedClient.calls != 0 {
+		t.Fatal("expected only the failed endpoint to report an error, and the spare endpoint to go unused")
+	}
+	if len(report.Dissents) != 0 {
+		t.Fatalf("expected no dissents, got %+v", report.Dissents)
+	}
+
+	// Without enough working endpoints, there's no quorum at all
+	_, err = batch.ExecuteQuorum([]*MultiCaller{first, failing}, 2, true, nil)
+	if err == nil {
+		t.Fatal("expected the quorum to fail without enough working endpoints")
+	}
+	for _, quorum := range []int{0, 3} {
+		_, err = batch.ExecuteQuorum([]*MultiCaller{first, second}, quorum, true, nil)
+		if err == nil {
+			t.Fatalf("expected a quorum of %d to be rejected", quorum)
+		}
+	}
+}
+
+func TestQuorumWithoutMajority(t *testing.T) {
+	account := common.HexToAddress("0x0102")
+	honest, _ := newTestMultiCaller(t)
+	corrupt, corruptClient := newTestMultiCaller(t)
+	corruptClient.corrupt = true
+	batch := newBalanceBatch(t, honest, account)
+
+	report, err := batch.ExecuteQuorum([]*MultiCaller{honest, corrupt}, 2, true, nil)
+	var noQuorum *ErrNoQuorum
+	if !errors.As(err, &noQuorum) || noQuorum.Index != 0 || noQuorum.Required != 2 || len(noQuorum.Groups) != 2 {
+		t.Fatalf("expected the balance to have no quorum, got %v", err)
+	}
+	if report == nil {
+		t.Fatal("expected the report to be returned with the error")
+	}
+	result, _ := report.Results.Get(account.Hex())
+	var balance *big.Int
+	err = result.Unpack(&balance)
+	if result.Success || !errors.As(err, &noQuorum) {
+		t.Fatalf("expected the result to carry the quorum error, got %v", err)
+	}
+}
+
+func TestErrNoQuorumWithoutGroups(t *testing.T) {
+	err := &ErrNoQuorum{Index: 3, Target: testTokenAddress, Method: "balanceOf", Required: 2}
+	if !strings.HasSuffix(err.Error(), "the largest group of matching responses had 0") {
+		t.Fatalf("unexpected message %q", err.Error())
+	}
+}
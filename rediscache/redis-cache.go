@@ -0,0 +1,111 @@
+// Package rediscache provides a Redis backend for batchquery's shared result cache,
+// so every instance of a horizontally scaled service can reuse the chain reads of the others.
+package rediscache
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	batchquery "github.com/rocket-pool/batch-query"
+)
+
+const (
+	// The default prefix for the sets that track the keys of each tag
+	defaultTagPrefix string = "batchquery:tag:"
+)
+
+// A cache stored in Redis. Each tag is tracked as a set of the keys that carry it, which is removed along with those keys when the tag is invalidated.
+// The tag sets use hash tags, so a Redis Cluster can invalidate them atomically.
+type Cache struct {
+	// The prefix for the sets that track the keys of each tag
+	TagPrefix string
+
+	// The Redis client, which can be a single node, a Sentinel failover client, or a cluster client
+	client redis.UniversalClient
+}
+
+// Creates a new Cache instance that stores its entries through the provided Redis client
+func NewCache(client redis.UniversalClient) *Cache {
+	return &Cache{
+		TagPrefix: defaultTagPrefix,
+		client:    client,
+	}
+}
+
+// Ensure Cache implements the batchquery cache interface
+var _ batchquery.ICache = (*Cache)(nil)
+
+// Gets the value stored under a key, and whether there was one
+func (c *Cache) Get(ctx context.Context, key string) ([]byte, bool, error) {
+	value, err := c.client.Get(ctx, key).Bytes()
+	if errors.Is(err, redis.Nil) {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, fmt.Errorf("error getting key %s from Redis: %w", key, err)
+	}
+	return value, true, nil
+}
+
+// Stores a value under a key. The entry expires after the TTL (0 = never), and is removed when any of its tags are invalidated.
+func (c *Cache) Set(ctx context.Context, key string, value []byte, ttl time.Duration, tags []string) error {
+	_, err := c.client.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.Set(ctx, key, value, ttl)
+		for _, tag := range tags {
+			pipe.SAdd(ctx, c.tagKey(tag), key)
+		}
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("error setting key %s in Redis: %w", key, err)
+	}
+	return nil
+}
+
+// Removes every entry with the tag.
+// The tag's set is renamed first so keys tagged while the invalidation runs go into a fresh set and aren't missed.
+func (c *Cache) Invalidate(ctx context.Context, tag string) error {
+	nonce := make([]byte, 8)
+	_, err := rand.Read(nonce)
+	if err != nil {
+		return fmt.Errorf("error generating invalidation nonce: %w", err)
+	}
+	tagKey := c.tagKey(tag)
+	pendingKey := tagKey + ":" + hex.EncodeToString(nonce)
+	err = c.client.Rename(ctx, tagKey, pendingKey).Err()
+	if err != nil {
+		if err.Error() == "ERR no such key" {
+			// Nothing has the tag
+			return nil
+		}
+		return fmt.Errorf("error renaming tag %s in Redis: %w", tag, err)
+	}
+
+	keys, err := c.client.SMembers(ctx, pendingKey).Result()
+	if err != nil {
+		return fmt.Errorf("error getting keys for tag %s from Redis: %w", tag, err)
+	}
+
+	// The keys are deleted one at a time since they may be in different cluster slots
+	_, err = c.client.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		for _, key := range keys {
+			pipe.Del(ctx, key)
+		}
+		pipe.Del(ctx, pendingKey)
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("error deleting keys for tag %s from Redis: %w", tag, err)
+	}
+	return nil
+}
+
+// Gets the key of the set that tracks the keys with a tag
+func (c *Cache) tagKey(tag string) string {
+	return c.TagPrefix + "{" + tag + "}"
+}
@@ -0,0 +1,97 @@
+package rediscache
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+	batchquery "github.com/rocket-pool/batch-query"
+)
+
+func newTestCache(t *testing.T) (*Cache, *miniredis.Miniredis) {
+	server := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: server.Addr()})
+	t.Cleanup(func() {
+		client.Close()
+	})
+	return NewCache(client), server
+}
+
+func TestCacheGetSet(t *testing.T) {
+	cache, server := newTestCache(t)
+	ctx := context.Background()
+
+	_, found, err := cache.Get(ctx, "missing")
+	if err != nil || found {
+		t.Fatalf("expected a miss, got %t and %v", found, err)
+	}
+
+	err = cache.Set(ctx, "key", []byte{1, 2, 3}, time.Minute, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	value, found, err := cache.Get(ctx, "key")
+	if err != nil || !found || len(value) != 3 || value[2] != 3 {
+		t.Fatalf("unexpected value: %v, %t, %v", value, found, err)
+	}
+
+	// The entry expires after its TTL
+	server.FastForward(2 * time.Minute)
+	_, found, _ = cache.Get(ctx, "key")
+	if found {
+		t.Fatal("expected the entry to expire")
+	}
+}
+
+func TestCacheInvalidate(t *testing.T) {
+	cache, server := newTestCache(t)
+	ctx := context.Background()
+
+	cache.Set(ctx, "a", []byte{1}, 0, []string{"latest"})
+	cache.Set(ctx, "b", []byte{2}, 0, []string{"latest", "other"})
+	cache.Set(ctx, "c", []byte{3}, 0, nil)
+	err := cache.Invalidate(ctx, "latest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for key, expected := range map[string]bool{"a": false, "b": false, "c": true} {
+		_, found, _ := cache.Get(ctx, key)
+		if found != expected {
+			t.Fatalf("expected key %s to be cached: %t, got %t", key, expected, found)
+		}
+	}
+	if server.Exists(cache.tagKey("latest")) {
+		t.Fatal("expected the tag's set to be removed")
+	}
+
+	// Invalidating a tag nothing has is a no-op
+	err = cache.Invalidate(ctx, "unused")
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestSharedResultCache(t *testing.T) {
+	cache, _ := newTestCache(t)
+	results := batchquery.NewSharedResultCache(cache)
+	key := batchquery.CacheKey{ChainID: 1, BlockNumber: 100, CallData: []byte{1}, Latest: true}
+	err := results.Set(key, batchquery.CallResponse{Status: true, ReturnData: []byte{4}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	response, found, err := results.Get(key)
+	if err != nil || !found || !response.Status || response.ReturnData[0] != 4 {
+		t.Fatalf("unexpected response: %+v, %t, %v", response, found, err)
+	}
+
+	err = results.InvalidateLatest()
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, found, _ = results.Get(key)
+	if found {
+		t.Fatal("expected the latest entry to be invalidated")
+	}
+}
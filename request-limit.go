@@ -0,0 +1,52 @@
+package batchquery
+
+import (
+	"context"
+	"sync"
+)
+
+// The slots for the requests that can run at once across every batcher in the process (nil = no limit)
+var globalRequestSlots chan struct{}
+
+// Lock for the global request slots
+var globalRequestLock sync.RWMutex
+
+// Limits the total number of requests that every batcher in the process can have in flight at once (0 = no limit).
+// Each batcher's ThreadLimit, and the limit of each Executor, only bounds its own requests, so several components with their own batchers
+// can still overwhelm a shared endpoint together; this bounds all of them at once. Requests already in flight aren't affected by a change.
+func SetGlobalRequestLimit(limit int) {
+	globalRequestLock.Lock()
+	defer globalRequestLock.Unlock()
+	if limit <= 0 {
+		globalRequestSlots = nil
+		return
+	}
+	globalRequestSlots = make(chan struct{}, limit)
+}
+
+// Gets the limit on the number of requests every batcher in the process can have in flight at once (0 = no limit)
+func GlobalRequestLimit() int {
+	globalRequestLock.RLock()
+	defer globalRequestLock.RUnlock()
+	return cap(globalRequestSlots)
+}
+
+// Waits for a slot within the global request limit, returning the function that frees it
+func acquireGlobalRequestSlot(ctx context.Context) (func(), error) {
+	globalRequestLock.RLock()
+	slots := globalRequestSlots
+	globalRequestLock.RUnlock()
+	if slots == nil {
+		return func() {}, nil
+	}
+
+	// The slot is freed in the channel it was taken from, even if the limit has changed since
+	select {
+	case slots <- struct{}{}:
+		return func() {
+			<-slots
+		}, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
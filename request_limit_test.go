@@ -0,0 +1,73 @@
+package batchquery
+
+import (
+	"context"
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"golang.org/x/sync/errgroup"
+)
+
+func TestGlobalRequestLimit(t *testing.T) {
+	SetGlobalRequestLimit(2)
+	t.Cleanup(func() {
+		SetGlobalRequestLimit(0)
+	})
+	if GlobalRequestLimit() != 2 {
+		t.Fatalf("expected a global limit of 2, got %d", GlobalRequestLimit())
+	}
+
+	// Separate batchers without executors or thread limits of their own are still bound together
+	tracker := &concurrencyTracker{}
+	var wg errgroup.Group
+	for i := 0; i < 3; i++ {
+		mc, client := newTestMultiCaller(t)
+		client.hook = func(ctx context.Context, msg ethereum.CallMsg) error {
+			tracker.track()
+			return nil
+		}
+		mc.CallBatchSize = 1
+		for j := 0; j < 5; j++ {
+			var balance *big.Int
+			mc.AddCall(testTokenAddress, &testTokenAbi, &balance, "balanceOf", common.BigToAddress(big.NewInt(int64(j))))
+		}
+		wg.Go(func() error {
+			_, err := mc.FlexibleCall(true, nil)
+			return err
+		})
+	}
+	err := wg.Wait()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tracker.peak > 2 {
+		t.Fatalf("expected at most 2 requests at once, got %d", tracker.peak)
+	}
+
+	SetGlobalRequestLimit(0)
+	if GlobalRequestLimit() != 0 {
+		t.Fatalf("expected the global limit to be removed, got %d", GlobalRequestLimit())
+	}
+}
+
+func TestGlobalRequestLimitHonorsCancellation(t *testing.T) {
+	SetGlobalRequestLimit(1)
+	t.Cleanup(func() {
+		SetGlobalRequestLimit(0)
+	})
+	release, err := acquireGlobalRequestSlot(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer release()
+
+	// The only slot is taken, so a request can only give up
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err = acquireGlobalRequestSlot(ctx)
+	if err != context.Canceled {
+		t.Fatalf("expected the cancelled request to give up, got %v", err)
+	}
+}
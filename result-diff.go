@@ -0,0 +1,199 @@
+package batchquery
+
+import (
+	"bytes"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+)
+
+// The differences between two result sets from the same batch definition, such as runs at different blocks or against different endpoints
+type ResultDiff struct {
+	// The results that are only in the second set, in its order
+	Added []ResultRecord
+
+	// The results that are only in the first set, in its order
+	Removed []ResultRecord
+
+	// The results whose status, value, or error differ between the sets, in the order of the first set
+	Changed []ResultChange
+}
+
+// The differences between two of a Watcher's snapshots, such as consecutive refreshes, for audit logs and alerting
+type SnapshotDiff struct {
+	// The block of the earlier snapshot
+	FromBlock *big.Int
+
+	// The block of the later snapshot
+	ToBlock *big.Int
+
+	// The differences in each batch's results, keyed by the name the batch was registered with.
+	// Only batches with differences are included; a batch that's only in one of the snapshots has all of its results added or removed.
+	Batches map[string]*ResultDiff
+}
+
+// A result that differs between two result sets
+type ResultChange struct {
+	// The result from the first set
+	Before ResultRecord
+
+	// The result from the second set
+	After ResultRecord
+}
+
+// Identifies a result within a result set
+type resultKey struct {
+	index   int
+	address common.Address
+	method  string
+}
+
+// Gets the key identifying the record within its result set
+func (r ResultRecord) key() resultKey {
+	return resultKey{
+		index:   r.Index,
+		address: r.Address,
+		method:  r.Method,
+	}
+}
+
+// Compares two result sets and reports the results that were added, removed, or changed between them.
+// Results are matched by their index, address, and method; block numbers are ignored, since result sets from different blocks are expected to differ there.
+func DiffResults(before []ResultRecord, after []ResultRecord) *ResultDiff {
+	diff := &ResultDiff{
+		Added:   []ResultRecord{},
+		Removed: []ResultRecord{},
+		Changed: []ResultChange{},
+	}
+	afterByKey := make(map[resultKey]ResultRecord, len(after))
+	for _, record := range after {
+		afterByKey[record.key()] = record
+	}
+
+	beforeKeys := make(map[resultKey]bool, len(before))
+	for _, record := range before {
+		key := record.key()
+		beforeKeys[key] = true
+		other, exists := afterByKey[key]
+		if !exists {
+			diff.Removed = append(diff.Removed, record)
+			continue
+		}
+		if record.Success != other.Success || record.Value != other.Value || record.Error != other.Error {
+			diff.Changed = append(diff.Changed, ResultChange{
+				Before: record,
+				After:  other,
+			})
+		}
+	}
+	for _, record := range after {
+		if !beforeKeys[record.key()] {
+			diff.Added = append(diff.Added, record)
+		}
+	}
+	return diff
+}
+
+// Checks whether the result sets were identical
+func (d *ResultDiff) IsEmpty() bool {
+	return len(d.Added) == 0 && len(d.Removed) == 0 && len(d.Changed) == 0
+}
+
+// Creates a record of each result for exporting or comparing them with DiffResults, with the provided block.
+// Results only carry their raw return data, so each value is decoded with the ABI its call was added with;
+// values with a single output are formatted like the records from FlexibleCallWithRecords, and values with several are formatted as a JSON array.
+// Calls without an ABI, or whose responses can't be decoded, have their raw return data as their value instead.
+func (r *Results) Records(blockNumber *big.Int) []ResultRecord {
+	records := make([]ResultRecord, len(r.results))
+	for i, result := range r.results {
+		record := ResultRecord{
+			Index:       result.Index,
+			Address:     result.Target,
+			Method:      result.Method,
+			Key:         result.Key,
+			BlockNumber: blockNumber,
+			Success:     result.Success,
+		}
+		if !result.Success {
+			record.Error = result.RevertReason()
+			records[i] = record
+			continue
+		}
+		values, err := result.unpackValues()
+		switch {
+		case err != nil:
+			record.Value = hexutil.Encode(result.ReturnData)
+		case len(values) == 1:
+			record.Value = formatResultValue(values[0])
+		default:
+			record.Value = formatResultValue(values)
+		}
+		records[i] = record
+	}
+	return records
+}
+
+// Compares two of a Watcher's snapshots and reports the results that changed between them in each batch.
+// Either snapshot can be nil, which is treated as a snapshot without any batches.
+func DiffSnapshots(before *WatchSnapshot, after *WatchSnapshot) *SnapshotDiff {
+	diff := &SnapshotDiff{
+		Batches: map[string]*ResultDiff{},
+	}
+	names := map[string]bool{}
+	if before != nil {
+		diff.FromBlock = before.BlockNumber
+		for name := range before.results {
+			names[name] = true
+		}
+	}
+	if after != nil {
+		diff.ToBlock = after.BlockNumber
+		for name := range after.results {
+			names[name] = true
+		}
+	}
+
+	for name := range names {
+		// Skip decoding batches whose raw results are identical, which is the common case between consecutive blocks
+		if before != nil && after != nil && sameResults(before.results[name], after.results[name]) {
+			continue
+		}
+		batchDiff := DiffResults(snapshotRecords(before, name), snapshotRecords(after, name))
+		if !batchDiff.IsEmpty() {
+			diff.Batches[name] = batchDiff
+		}
+	}
+	return diff
+}
+
+// Gets the records of a batch's results in a snapshot, or none if the snapshot is nil or doesn't have the batch
+func snapshotRecords(snapshot *WatchSnapshot, name string) []ResultRecord {
+	if snapshot == nil {
+		return nil
+	}
+	results, exists := snapshot.results[name]
+	if !exists {
+		return nil
+	}
+	return results.Records(snapshot.BlockNumber)
+}
+
+// Checks whether two result sets have the same calls with the same statuses and return data
+func sameResults(before *Results, after *Results) bool {
+	if before == nil || after == nil || len(before.results) != len(after.results) {
+		return false
+	}
+	for i, result := range before.results {
+		other := after.results[i]
+		if result.Target != other.Target || result.Method != other.Method || result.Success != other.Success || !bytes.Equal(result.ReturnData, other.ReturnData) {
+			return false
+		}
+	}
+	return true
+}
+
+// Checks whether the snapshots were identical
+func (d *SnapshotDiff) IsEmpty() bool {
+	return len(d.Batches) == 0
+}
@@ -0,0 +1,206 @@
+package batchquery
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/big"
+	"reflect"
+	"strconv"
+
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+)
+
+// The columns of a CSV result export, in order
+var resultCsvHeader = []string{"index", "address", "method", "block", "success", "value", "error"}
+
+// A single call or balance result in a form that can be exported for analytics and reporting
+type ResultRecord struct {
+	// The index of the call within the batch, or of the address within a balance query
+	Index int `json:"index"`
+
+	// The contract the call was run on, or the account whose balance was queried
+	Address common.Address `json:"address"`
+
+	// The name of the method that was called
+	Method string `json:"method"`
+
+	// The key the call was given, if any (see Call.WithKey)
+	Key string `json:"key,omitempty"`
+
+	// The block the result is from (nil = unknown, such as for the pending block)
+	BlockNumber *big.Int `json:"block"`
+
+	// Whether or not the call succeeded
+	Success bool `json:"success"`
+
+	// The decoded value of the call, formatted as text; integers are in decimal and byte values are hex encoded.
+	// Calls without a decoded output have their raw return data here instead.
+	Value string `json:"value"`
+
+	// The revert reason of the call, if it failed
+	Error string `json:"error,omitempty"`
+}
+
+// Invokes all of the previously batched up contract calls like FlexibleCall, and returns a record of each result that can be exported.
+// If opts doesn't specify a block number (and isn't for the pending block), the batch is pinned to the latest block so the records have one.
+// The records are returned in the same order as the calls were added.
+func (mc *MultiCaller) FlexibleCallWithRecords(requireSuccess bool, opts *bind.CallOpts) ([]ResultRecord, error) {
+	var blockNumber *big.Int
+	if opts == nil || !opts.Pending {
+		pinned, err := pinCallOpts(mc, opts)
+		if err != nil {
+			return nil, err
+		}
+		opts = pinned
+		blockNumber = pinned.BlockNumber
+	}
+
+	calls := mc.calls
+	_, responses, err := mc.flexibleCallWithResponses(requireSuccess, mc.newCallOptions(opts))
+	if err != nil {
+		return nil, err
+	}
+	records := make([]ResultRecord, len(calls))
+	for i, call := range calls {
+		records[i] = newResultRecord(i, call, responses[i], blockNumber)
+	}
+	return records, nil
+}
+
+// Creates the record of a call's result
+func newResultRecord(index int, call *Call, response CallResponse, blockNumber *big.Int) ResultRecord {
+	record := ResultRecord{
+		Index:       index,
+		Address:     call.Target,
+		Method:      call.Method,
+		Key:         call.Key,
+		BlockNumber: blockNumber,
+		Success:     response.Status,
+	}
+	if call.BlockNumber != nil {
+		record.BlockNumber = call.BlockNumber
+	}
+	if !response.Status {
+		record.Error = DecodeRevertReason(response.ReturnData)
+		return record
+	}
+	if call.Output == nil || call.UnpackFunc == nil {
+		record.Value = hexutil.Encode(response.ReturnData)
+		return record
+	}
+	record.Value = formatResultValue(call.Output)
+	return record
+}
+
+// Creates records for the results of a balance query, such as from BalanceBatcher.GetEthBalances or MultiCaller.GetEthBalances
+func NewBalanceRecords(addresses []common.Address, balances []*big.Int, blockNumber *big.Int) ([]ResultRecord, error) {
+	if len(addresses) != len(balances) {
+		return nil, fmt.Errorf("received %d balances which mismatches the %d addresses", len(balances), len(addresses))
+	}
+	records := make([]ResultRecord, len(addresses))
+	for i, address := range addresses {
+		records[i] = ResultRecord{
+			Index:       i,
+			Address:     address,
+			Method:      "getEthBalance",
+			BlockNumber: blockNumber,
+			Success:     balances[i] != nil,
+			Value:       formatResultValue(balances[i]),
+		}
+	}
+	return records, nil
+}
+
+// Formats a decoded output as text, following pointers to the value they reference
+func formatResultValue(value any) string {
+	v := reflect.ValueOf(value)
+	for v.Kind() == reflect.Pointer && !v.IsNil() && v.Type() != reflect.TypeOf(&big.Int{}) {
+		v = v.Elem()
+	}
+	if !v.IsValid() || (v.Kind() == reflect.Pointer && v.IsNil()) {
+		return ""
+	}
+
+	switch typed := v.Interface().(type) {
+	case *big.Int:
+		return typed.String()
+	case common.Address:
+		return typed.Hex()
+	case common.Hash:
+		return typed.Hex()
+	case []byte:
+		return hexutil.Encode(typed)
+	case string:
+		return typed
+	case bool:
+		return strconv.FormatBool(typed)
+	case fmt.Stringer:
+		return typed.String()
+	}
+
+	// Fixed-size byte arrays (such as bytes32) are hex encoded, and anything else is formatted as JSON
+	if v.Kind() == reflect.Array && v.Type().Elem().Kind() == reflect.Uint8 {
+		bytes := make([]byte, v.Len())
+		reflect.Copy(reflect.ValueOf(bytes), v)
+		return hexutil.Encode(bytes)
+	}
+	data, err := json.Marshal(v.Interface())
+	if err != nil {
+		return fmt.Sprint(v.Interface())
+	}
+	return string(data)
+}
+
+// Gets the CSV row for a record
+func (r ResultRecord) csvRow() []string {
+	block := ""
+	if r.BlockNumber != nil {
+		block = r.BlockNumber.String()
+	}
+	return []string{
+		strconv.Itoa(r.Index),
+		r.Address.Hex(),
+		r.Method,
+		block,
+		strconv.FormatBool(r.Success),
+		r.Value,
+		r.Error,
+	}
+}
+
+// Writes the records to the writer as CSV, with a header row naming the columns
+func WriteResultsCSV(writer io.Writer, records []ResultRecord) error {
+	csvWriter := csv.NewWriter(writer)
+	err := csvWriter.Write(resultCsvHeader)
+	if err != nil {
+		return fmt.Errorf("error writing CSV header: %w", err)
+	}
+	for _, record := range records {
+		err = csvWriter.Write(record.csvRow())
+		if err != nil {
+			return fmt.Errorf("error writing CSV row for result %d: %w", record.Index, err)
+		}
+	}
+	csvWriter.Flush()
+	err = csvWriter.Error()
+	if err != nil {
+		return fmt.Errorf("error writing CSV: %w", err)
+	}
+	return nil
+}
+
+// Writes the records to the writer as a JSON array
+func WriteResultsJSON(writer io.Writer, records []ResultRecord) error {
+	if records == nil {
+		records = []ResultRecord{}
+	}
+	err := json.NewEncoder(writer).Encode(records)
+	if err != nil {
+		return fmt.Errorf("error writing JSON: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,57 @@
+package batchquery
+
+import (
+	"fmt"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+// Computes a deterministic hash of a batch's calls and their raw results, so two runs of the same batch
+// (such as on different machines, or against different endpoints) can be compared with a single hash comparison.
+// The hash is keccak256(requestHash || responseHash), using the same canonical encodings as an Attestation,
+// so it matches the ResultHash() of an attestation of the same calls and responses.
+func HashResults(calls []Call, responses []CallResponse) (common.Hash, error) {
+	if len(calls) != len(responses) {
+		return common.Hash{}, fmt.Errorf("batch has %d calls but %d responses", len(calls), len(responses))
+	}
+	requests := make([]AttestedRequest, len(calls))
+	for i, call := range calls {
+		requests[i] = AttestedRequest{
+			Target:   call.Target,
+			CallData: call.CallData,
+		}
+	}
+	results := make([]AttestedResponse, len(responses))
+	for i, response := range responses {
+		results[i] = AttestedResponse{
+			Success:    response.Status,
+			ReturnData: response.ReturnData,
+		}
+	}
+
+	requestHash, err := hashCanonicalRequests(requests)
+	if err != nil {
+		return common.Hash{}, err
+	}
+	responseHash, err := hashCanonicalResponses(results)
+	if err != nil {
+		return common.Hash{}, err
+	}
+	return combineResultHashes(requestHash, responseHash), nil
+}
+
+// Computes the canonical hash of the batch's calls and the provided raw results, such as the ones returned by ExecuteRaw()
+func (b *Batch) HashResults(responses []CallResponse) (common.Hash, error) {
+	return HashResults(b.Calls(), responses)
+}
+
+// Computes the canonical hash of the attestation's requests and responses, which matches HashResults() for the same calls and responses
+func (a *Attestation) ResultHash() common.Hash {
+	return combineResultHashes(a.RequestHash, a.ResponseHash)
+}
+
+// Combines the hashes of a batch's requests and responses into a single hash
+func combineResultHashes(requestHash common.Hash, responseHash common.Hash) common.Hash {
+	return crypto.Keccak256Hash(requestHash[:], responseHash[:])
+}
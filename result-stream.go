@@ -0,0 +1,111 @@
+package batchquery
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// The number of addresses StreamEthBalances queries at a time when the MultiCaller doesn't have a batch size
+const defaultBalanceStreamWindow int = 10_000
+
+// Writes results as line-delimited JSON (one record per line) as soon as they're available, rather than collecting them first,
+// so exporting millions of results keeps memory use flat. It isn't safe for concurrent use, but StreamCall never invokes its handler concurrently.
+type ResultStreamWriter struct {
+	// The encoder for the underlying writer
+	encoder *json.Encoder
+
+	// The number of records written so far
+	count int
+
+	// The first error that occurred while writing, after which nothing else is written
+	err error
+}
+
+// Creates a new ResultStreamWriter that writes to the provided writer
+func NewResultStreamWriter(writer io.Writer) *ResultStreamWriter {
+	return &ResultStreamWriter{
+		encoder: json.NewEncoder(writer),
+	}
+}
+
+// Writes a single record as a line of JSON.
+// Once a write fails, every later write returns the same error.
+func (w *ResultStreamWriter) Write(record ResultRecord) error {
+	if w.err != nil {
+		return w.err
+	}
+	err := w.encoder.Encode(record)
+	if err != nil {
+		w.err = fmt.Errorf("error writing result %d: %w", record.Index, err)
+		return w.err
+	}
+	w.count++
+	return nil
+}
+
+// Gets a handler for StreamCall that writes each result as soon as its chunk returns.
+// The block number is recorded with each result, so the batch should be run at that block.
+// Since handlers can't return errors, check Err() once the batch is done.
+func (w *ResultStreamWriter) Handler(blockNumber *big.Int) func(StreamResult) {
+	return func(result StreamResult) {
+		record := newResultRecord(result.Index, result.Call, CallResponse{Status: result.Success, ReturnData: result.ReturnData}, blockNumber)
+		if result.Err != nil {
+			record.Error = result.Err.Error()
+		}
+		_ = w.Write(record)
+	}
+}
+
+// Gets the number of records written so far
+func (w *ResultStreamWriter) Count() int {
+	return w.count
+}
+
+// Gets the first error that occurred while writing, if there was one
+func (w *ResultStreamWriter) Err() error {
+	return w.err
+}
+
+// Queries the ETH balances of the addresses through the multicall contract and writes them to the stream writer as they arrive.
+// The addresses are queried a window at a time, so only one window's balances are held in memory no matter how many addresses there are.
+// Every window runs at the same block; if opts doesn't specify one, the latest block is used. The block number that was used is returned.
+func (mc *MultiCaller) StreamEthBalances(addresses []common.Address, writer *ResultStreamWriter, opts *bind.CallOpts) (*big.Int, error) {
+	runOpts, err := pinCallOpts(mc, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	window := defaultBalanceStreamWindow
+	if mc.CallBatchSize > 0 && mc.ThreadLimit > 0 {
+		window = mc.CallBatchSize * mc.ThreadLimit
+	}
+	for start := 0; start < len(addresses); start += window {
+		end := start + window
+		if end > len(addresses) {
+			end = len(addresses)
+		}
+		balances, err := mc.GetEthBalances(addresses[start:end], runOpts)
+		if err != nil {
+			return nil, err
+		}
+		for i, balance := range balances {
+			err = writer.Write(ResultRecord{
+				Index:       start + i,
+				Address:     addresses[start+i],
+				Method:      "getEthBalance",
+				BlockNumber: runOpts.BlockNumber,
+				Success:     true,
+				Value:       balance.String(),
+			})
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+	return runOpts.BlockNumber, nil
+}
@@ -0,0 +1,128 @@
+package batchquery
+
+import (
+	"context"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+)
+
+func TestDiffResults(t *testing.T) {
+	// The same balance queries at two blocks, where the second run has an extra address
+	mc, _ := newTestMultiCaller(t)
+	addresses := []common.Address{common.HexToAddress("0x01"), common.HexToAddress("0x02")}
+	balances, err := mc.GetEthBalances(addresses, &bind.CallOpts{BlockNumber: big.NewInt(10)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	before, err := NewBalanceRecords(addresses, balances, big.NewInt(10))
+	if err != nil {
+		t.Fatal(err)
+	}
+	after, err := NewBalanceRecords(append(addresses, common.HexToAddress("0x03")), []*big.Int{balances[0], big.NewInt(99), big.NewInt(3)}, big.NewInt(20))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	diff := DiffResults(before, after)
+	if diff.IsEmpty() || len(diff.Removed) != 0 {
+		t.Fatalf("unexpected diff %+v", diff)
+	}
+	if len(diff.Changed) != 1 || diff.Changed[0].Before.Index != 1 || diff.Changed[0].After.Value != "99" {
+		t.Fatalf("expected only the second balance to change, got %+v", diff.Changed)
+	}
+	if len(diff.Added) != 1 || diff.Added[0].Address != common.HexToAddress("0x03") {
+		t.Fatalf("expected the third address to be added, got %+v", diff.Added)
+	}
+
+	// The reverse diff removes it again
+	diff = DiffResults(after, before)
+	if len(diff.Removed) != 1 || len(diff.Added) != 0 || len(diff.Changed) != 1 {
+		t.Fatalf("unexpected reverse diff %+v", diff)
+	}
+
+	// Identical results differ only by block, which is ignored
+	if !DiffResults(before, before).IsEmpty() {
+		t.Fatal("expected identical results to have no differences")
+	}
+}
+
+func TestResultsRecords(t *testing.T) {
+	mc, _ := newTestMultiCaller(t)
+	account := common.HexToAddress("0x0102")
+	mc.AddCall(testTokenAddress, &testTokenAbi, new(*big.Int), "balanceOf", account).WithKey("balance")
+	mc.AddCall(testTokenAddress, &testTokenAbi, new(*big.Int), "boom")
+	mc.calls = append(mc.calls, &Call{
+		Target:   testTokenAddress,
+		CallData: testTokenAbi.Methods["decimals"].ID,
+	})
+	results, err := mc.FlexibleCallResults(false, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	records := results.Records(big.NewInt(5))
+	if len(records) != 3 {
+		t.Fatalf("expected 3 records, got %d", len(records))
+	}
+	if records[0].Value != expectedBalance(account, 0).String() || records[0].Key != "balance" || records[0].BlockNumber.Int64() != 5 {
+		t.Fatalf("unexpected balance record %+v", records[0])
+	}
+	if records[1].Success || records[1].Error == "" {
+		t.Fatalf("expected the reverted call's reason, got %+v", records[1])
+	}
+	if records[2].Value != "0x0000000000000000000000000000000000000000000000000000000000000002" {
+		t.Fatalf("expected the raw return data for a call without an ABI, got %+v", records[2])
+	}
+}
+
+func TestWatcherDiffs(t *testing.T) {
+	mc, _ := newTestMultiCaller(t)
+	account := common.HexToAddress("0x0102")
+	watcher := NewWatcher(mc, time.Hour)
+	watcher.Watch("balances", newBalanceBatch(t, mc, account))
+	watcher.Watch("static", newBalanceBatch(t, mc))
+	var diffs []*SnapshotDiff
+	watcher.OnDiff = func(diff *SnapshotDiff) {
+		diffs = append(diffs, diff)
+	}
+
+	ctx := context.Background()
+	for _, block := range []int64{10, 10, 11} {
+		_, err := watcher.refresh(ctx, big.NewInt(block))
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	if len(diffs) != 1 {
+		t.Fatalf("expected a single diff, got %d", len(diffs))
+	}
+	diff := diffs[0]
+	if diff.FromBlock.Int64() != 10 || diff.ToBlock.Int64() != 11 || len(diff.Batches) != 1 {
+		t.Fatalf("unexpected diff %+v", diff)
+	}
+	changes := diff.Batches["balances"].Changed
+	if len(changes) != 1 || changes[0].Before.Value != expectedBalance(account, 10).String() || changes[0].After.Value != expectedBalance(account, 11).String() {
+		t.Fatalf("unexpected changes %+v", changes)
+	}
+	if changes[0].After.Key != account.Hex() {
+		t.Fatalf("expected the change to carry the call's key, got %q", changes[0].After.Key)
+	}
+
+	// Snapshots can also be compared directly, including batches that were removed
+	before := watcher.Snapshot()
+	watcher.batches = map[string]*Batch{}
+	after, err := watcher.refresh(ctx, big.NewInt(11))
+	if err != nil {
+		t.Fatal(err)
+	}
+	diff = DiffSnapshots(before, after)
+	if len(diff.Batches) != 1 || len(diff.Batches["balances"].Removed) != 1 {
+		t.Fatalf("expected the balance batch to be removed, got %+v", diff.Batches)
+	}
+	if !DiffSnapshots(before, before).IsEmpty() {
+		t.Fatal("expected identical snapshots to have no differences")
+	}
+}
@@ -0,0 +1,105 @@
+package batchquery
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// Runs a small batch with a balance, a list, an address, and a revert, and returns its records
+func newTestRecords(t *testing.T) []ResultRecord {
+	mc, _ := newTestMultiCaller(t)
+	var balance *big.Int
+	var list []*big.Int
+	var sender common.Address
+	var boom *big.Int
+	mc.AddCall(testTokenAddress, &testTokenAbi, &balance, "balanceOf", common.HexToAddress("0x0102"))
+	mc.AddCall(testTokenAddress, &testTokenAbi, &list, "list", big.NewInt(3))
+	mc.AddCall(testTokenAddress, &testTokenAbi, &sender, "whoami")
+	mc.AddCall(testTokenAddress, &testTokenAbi, &boom, "boom")
+	records, err := mc.FlexibleCallWithRecords(false, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return records
+}
+
+func TestFlexibleCallWithRecords(t *testing.T) {
+	records := newTestRecords(t)
+	expected := []ResultRecord{
+		{Index: 0, Method: "balanceOf", Success: true, Value: "358"},
+		{Index: 1, Method: "list", Success: true, Value: "[0,1,2]"},
+		{Index: 2, Method: "whoami", Success: true, Value: testMulticallAddress.Hex()},
+		{Index: 3, Method: "boom", Success: false, Error: "boom"},
+	}
+	for i, record := range records {
+		if record.Index != expected[i].Index || record.Method != expected[i].Method || record.Success != expected[i].Success ||
+			record.Value != expected[i].Value || record.Error != expected[i].Error {
+			t.Fatalf("expected record %d to be %+v, got %+v", i, expected[i], record)
+		}
+
+		// The batch was pinned to the latest block
+		if record.Address != testTokenAddress || record.BlockNumber.Int64() != 100 {
+			t.Fatalf("unexpected address %s or block %s for record %d", record.Address.Hex(), record.BlockNumber, i)
+		}
+	}
+}
+
+func TestWriteResultsCSV(t *testing.T) {
+	var buffer bytes.Buffer
+	err := WriteResultsCSV(&buffer, newTestRecords(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	rows, err := csv.NewReader(&buffer).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rows) != 5 || rows[0][0] != "index" || len(rows[0]) != len(resultCsvHeader) {
+		t.Fatalf("expected a header and 4 rows, got %v", rows)
+	}
+	if rows[1][1] != testTokenAddress.Hex() || rows[1][3] != "100" || rows[1][5] != "358" || rows[4][4] != "false" || rows[4][6] != "boom" {
+		t.Fatalf("unexpected rows %v", rows)
+	}
+}
+
+func TestWriteResultsJSON(t *testing.T) {
+	records := newTestRecords(t)
+	var buffer bytes.Buffer
+	err := WriteResultsJSON(&buffer, records)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var decoded []ResultRecord
+	err = json.Unmarshal(buffer.Bytes(), &decoded)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(decoded) != len(records) {
+		t.Fatalf("expected %d records, got %d", len(records), len(decoded))
+	}
+	for i := range decoded {
+		if decoded[i].Value != records[i].Value || decoded[i].BlockNumber.Cmp(records[i].BlockNumber) != 0 || decoded[i].Address != records[i].Address {
+			t.Fatalf("record %d changed in the round trip: expected %+v, got %+v", i, records[i], decoded[i])
+		}
+	}
+}
+
+func TestNewBalanceRecords(t *testing.T) {
+	addresses := []common.Address{common.HexToAddress("0x01"), common.HexToAddress("0x02")}
+	records, err := NewBalanceRecords(addresses, []*big.Int{big.NewInt(10), big.NewInt(20)}, big.NewInt(5))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if records[1].Address != addresses[1] || records[1].Value != "20" || records[1].BlockNumber.Int64() != 5 {
+		t.Fatalf("unexpected record %+v", records[1])
+	}
+	_, err = NewBalanceRecords(addresses, []*big.Int{big.NewInt(10)}, nil)
+	if err == nil {
+		t.Fatal("expected mismatched balances to be rejected")
+	}
+}
@@ -0,0 +1,78 @@
+package batchquery
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// Adds balance calls for two accounts, plus a call that fails
+func addHashedCalls(mc *MultiCaller) {
+	for _, account := range []common.Address{common.HexToAddress("0x0102"), common.HexToAddress("0x0203")} {
+		var balance *big.Int
+		mc.AddCall(testTokenAddress, &testTokenAbi, &balance, "balanceOf", account)
+	}
+	mc.AddCall(testTokenAddress, &testTokenAbi, nil, "boom")
+}
+
+func TestHashResultsMatchesAcrossRuns(t *testing.T) {
+	hashAt := func(blockNumber int64) common.Hash {
+		// Each run uses a separate MultiCaller and client, like a run on another machine
+		mc, _ := newTestMultiCaller(t)
+		addHashedCalls(mc)
+		batch, err := mc.Snapshot()
+		if err != nil {
+			t.Fatal(err)
+		}
+		responses, err := batch.ExecuteRaw(mc, false, &bind.CallOpts{BlockNumber: big.NewInt(blockNumber)})
+		if err != nil {
+			t.Fatal(err)
+		}
+		hash, err := batch.HashResults(responses)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return hash
+	}
+
+	if hashAt(50) != hashAt(50) {
+		t.Fatal("expected identical runs to have the same hash")
+	}
+	if hashAt(50) == hashAt(51) {
+		t.Fatal("expected runs with different results to have different hashes")
+	}
+}
+
+func TestHashResultsMatchesAttestation(t *testing.T) {
+	mc, _ := newTestAttestingMultiCaller(t)
+	addHashedCalls(mc)
+	batch, err := mc.Snapshot()
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, attestation, err := mc.FlexibleCallWithAttestation(false, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	responses, err := batch.ExecuteRaw(mc, false, &bind.CallOpts{BlockNumber: attestation.BlockNumber})
+	if err != nil {
+		t.Fatal(err)
+	}
+	hash, err := batch.HashResults(responses)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if hash != attestation.ResultHash() {
+		t.Fatalf("expected the batch hash %s to match the attestation's %s", hash.Hex(), attestation.ResultHash().Hex())
+	}
+}
+
+func TestHashResultsRejectsMismatchedResponses(t *testing.T) {
+	_, err := HashResults([]Call{{Target: testTokenAddress}}, nil)
+	if err == nil {
+		t.Fatal("expected a response count mismatch to be rejected")
+	}
+}
@@ -0,0 +1,113 @@
+package batchquery
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// Reads line-delimited JSON records
+func readRecordLines(t *testing.T, data []byte) []ResultRecord {
+	records := []ResultRecord{}
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		var record ResultRecord
+		err := json.Unmarshal(scanner.Bytes(), &record)
+		if err != nil {
+			t.Fatalf("error decoding line %q: %v", scanner.Text(), err)
+		}
+		records = append(records, record)
+	}
+	return records
+}
+
+// A writer that always fails
+type failingWriter struct{}
+
+func (failingWriter) Write(p []byte) (int, error) {
+	return 0, errors.New("disk full")
+}
+
+func TestResultStreamWriterHandler(t *testing.T) {
+	mc, _ := newTestMultiCaller(t)
+	mc.CallBatchSize = 1
+	var balance *big.Int
+	var boom *big.Int
+	mc.AddCall(testTokenAddress, &testTokenAbi, &balance, "balanceOf", common.HexToAddress("0x05"))
+	mc.AddCall(testTokenAddress, &testTokenAbi, &boom, "boom")
+
+	var buffer bytes.Buffer
+	writer := NewResultStreamWriter(&buffer)
+	blockNumber := big.NewInt(10)
+	err := mc.StreamCall(false, &bind.CallOpts{BlockNumber: blockNumber}, writer.Handler(blockNumber))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if writer.Err() != nil || writer.Count() != 2 {
+		t.Fatalf("expected 2 records without errors, got %d and %v", writer.Count(), writer.Err())
+	}
+
+	// Chunks may complete in any order
+	records := readRecordLines(t, buffer.Bytes())
+	byIndex := map[int]ResultRecord{}
+	for _, record := range records {
+		byIndex[record.Index] = record
+	}
+	if byIndex[0].Value != "15" || !byIndex[0].Success || byIndex[0].BlockNumber.Int64() != 10 {
+		t.Fatalf("unexpected balance record %+v", byIndex[0])
+	}
+	if byIndex[1].Success || byIndex[1].Error != "boom" {
+		t.Fatalf("unexpected revert record %+v", byIndex[1])
+	}
+}
+
+func TestStreamEthBalances(t *testing.T) {
+	mc, client := newTestMultiCaller(t)
+	mc.CallBatchSize = 2
+	mc.ThreadLimit = 1
+	addresses := make([]common.Address, 5)
+	for i := range addresses {
+		addresses[i] = common.BigToAddress(big.NewInt(int64(i + 1)))
+	}
+
+	var buffer bytes.Buffer
+	writer := NewResultStreamWriter(&buffer)
+	blockNumber, err := mc.StreamEthBalances(addresses, writer, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if blockNumber.Int64() != 100 {
+		t.Fatalf("expected the latest block to be used, got %s", blockNumber)
+	}
+
+	// Each window of 2 addresses is its own batch
+	if sizes := client.getChunkSizes(); len(sizes) != 3 {
+		t.Fatalf("expected 3 multicalls, got %v", sizes)
+	}
+	records := readRecordLines(t, buffer.Bytes())
+	if len(records) != len(addresses) {
+		t.Fatalf("expected %d records, got %d", len(addresses), len(records))
+	}
+	for i, record := range records {
+		if record.Index != i || record.Address != addresses[i] || record.Value != expectedBalance(addresses[i], 100).String() {
+			t.Fatalf("unexpected record %+v", record)
+		}
+	}
+}
+
+func TestResultStreamWriterStopsAfterError(t *testing.T) {
+	writer := NewResultStreamWriter(failingWriter{})
+	err := writer.Write(ResultRecord{Index: 3})
+	if err == nil {
+		t.Fatal("expected the write to fail")
+	}
+	if writer.Write(ResultRecord{Index: 4}) != err || writer.Err() != err || writer.Count() != 0 {
+		t.Fatal("expected later writes to return the first error")
+	}
+}
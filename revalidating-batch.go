@@ -0,0 +1,156 @@
+package batchquery
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+)
+
+// The results of one run of a RevalidatingBatch
+type RevalidationResult struct {
+	// The block the batch ran at
+	BlockNumber *big.Int
+
+	// The raw response of each call
+	Responses []CallResponse
+
+	// The time the run finished
+	FetchedAt time.Time
+
+	// The error from the run, if it failed
+	Err error
+}
+
+// A batch that serves its latest results immediately, even once they're stale, while a refresh runs in the background;
+// this trades strict freshness for latency where the application can tolerate slightly old data.
+// Results are stale once they're older than MaxAge, or once the MultiCaller's LatestCache has observed a newer head than the block they came from.
+type RevalidatingBatch struct {
+	// How long results stay fresh (0 = until a newer head is observed, which requires the MultiCaller to have a LatestCache)
+	MaxAge time.Duration
+
+	// Called with the results of each background refresh once they land (nil = none). It's called without holding the batch's lock, so it can call Get.
+	OnRefresh func(result RevalidationResult)
+
+	// The batch to run
+	batch *Batch
+
+	// The MultiCaller whose client and settings are used to run the batch
+	caller *MultiCaller
+
+	// Whether or not every call in the batch must succeed
+	requireSuccess bool
+
+	// The latest successful results (nil = none yet)
+	latest *RevalidationResult
+
+	// Whether a background refresh is running
+	refreshing bool
+
+	// Lock for the results, which also serializes unpacking them into the batch's outputs
+	lock sync.Mutex
+}
+
+// Creates a new RevalidatingBatch that runs the batch using the settings and client of the provided MultiCaller
+func NewRevalidatingBatch(caller *MultiCaller, batch *Batch, requireSuccess bool, maxAge time.Duration) *RevalidatingBatch {
+	return &RevalidatingBatch{
+		MaxAge:         maxAge,
+		batch:          batch,
+		caller:         caller,
+		requireSuccess: requireSuccess,
+	}
+}
+
+// Gets the batch's results, unpacking the successful responses into the outputs its calls were created with.
+// If there are no results yet, the batch is run at the latest block before returning. Otherwise the latest results are returned immediately,
+// and if they're stale, a refresh is started in the background (unless one is already running) that replaces them for later calls once it lands.
+// Returns the success flag of each call, and the results they were unpacked from.
+func (r *RevalidatingBatch) Get(ctx context.Context) ([]bool, *RevalidationResult, error) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	if r.latest == nil {
+		result := r.run(ctx)
+		if result.Err != nil {
+			return nil, nil, result.Err
+		}
+		r.latest = &result
+	} else if r.isStale(r.latest) && !r.refreshing {
+		r.refreshing = true
+		go r.refresh()
+	}
+
+	result := *r.latest
+	successes := make([]bool, len(result.Responses))
+	for i, call := range r.batch.calls {
+		err := call.unpackResponse(result.Responses[i])
+		if err != nil {
+			return nil, nil, err
+		}
+		successes[i] = result.Responses[i].Status
+	}
+	return successes, &result, nil
+}
+
+// Runs the batch at the latest block
+func (r *RevalidatingBatch) run(ctx context.Context) RevalidationResult {
+	var result RevalidationResult
+	opts, err := pinCallOpts(r.caller, &bind.CallOpts{Context: ctx})
+	if err != nil {
+		result.Err = fmt.Errorf("error pinning batch to the latest block: %w", err)
+		return result
+	}
+	responses, err := r.batch.ExecuteRaw(r.caller, r.requireSuccess, opts)
+	if err != nil {
+		result.Err = err
+		return result
+	}
+
+	// The responses may be stored in the MultiCaller's reusable buffer
+	result.BlockNumber = opts.BlockNumber
+	result.Responses = make([]CallResponse, len(responses))
+	for i, response := range responses {
+		result.Responses[i] = CallResponse{
+			Status:     response.Status,
+			ReturnData: append([]byte{}, response.ReturnData...),
+		}
+	}
+	result.FetchedAt = time.Now()
+	return result
+}
+
+// Runs the batch in the background and replaces the latest results with the new ones if it succeeds.
+// The refresh runs in the MultiCaller's BaseContext, since the request that started it may finish first.
+func (r *RevalidatingBatch) refresh() {
+	ctx := r.caller.BaseContext
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	result := r.run(ctx)
+
+	r.lock.Lock()
+	r.refreshing = false
+	if result.Err == nil {
+		r.latest = &result
+	}
+	r.lock.Unlock()
+	if r.OnRefresh != nil {
+		r.OnRefresh(result)
+	}
+}
+
+// Checks whether a set of results is stale
+func (r *RevalidatingBatch) isStale(result *RevalidationResult) bool {
+	if r.MaxAge > 0 && time.Since(result.FetchedAt) > r.MaxAge {
+		return true
+	}
+	if r.caller.LatestCache != nil {
+		head := r.caller.LatestCache.Head()
+		if head != nil && head.Cmp(result.BlockNumber) > 0 {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,101 @@
+package batchquery
+
+import (
+	"context"
+	"math/big"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+)
+
+func TestRevalidatingBatchServesStaleResults(t *testing.T) {
+	// The mock chain never advances past block 100, so the cache would serve every refresh without running the batch
+	mc, client := newTestMultiCaller(t)
+	mc.Cache = nil
+	account := common.HexToAddress("0x0102")
+	var balance *big.Int
+	builder := mc.Clone()
+	builder.AddCall(testTokenAddress, &testTokenAbi, &balance, "balanceOf", account)
+	batch, err := builder.Snapshot()
+	if err != nil {
+		t.Fatal(err)
+	}
+	revalidating := NewRevalidatingBatch(mc, batch, true, time.Hour)
+	refreshed := make(chan RevalidationResult, 1)
+	revalidating.OnRefresh = func(result RevalidationResult) {
+		refreshed <- result
+	}
+
+	// The first request runs the batch
+	successes, first, err := revalidating.Get(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !successes[0] || first.BlockNumber.Int64() != 100 || balance.Cmp(expectedBalance(account, 100)) != 0 {
+		t.Fatalf("unexpected first result: %+v with balance %s", first, balance)
+	}
+
+	// Fresh results are served without a refresh
+	_, second, err := revalidating.Get(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if second.FetchedAt != first.FetchedAt || client.calls != 2 {
+		t.Fatalf("expected the fresh results to be served as-is, got %d eth_calls", client.calls)
+	}
+
+	// Stale results are still served immediately while a single refresh runs in the background
+	release := make(chan struct{})
+	var blocked int32
+	client.hook = func(ctx context.Context, msg ethereum.CallMsg) error {
+		atomic.AddInt32(&blocked, 1)
+		<-release
+		return nil
+	}
+	revalidating.MaxAge = time.Nanosecond
+	for i := 0; i < 3; i++ {
+		_, stale, err := revalidating.Get(context.Background())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if stale.FetchedAt != first.FetchedAt {
+			t.Fatal("expected the stale results to be served while the refresh runs")
+		}
+	}
+	close(release)
+	result := <-refreshed
+	if result.Err != nil {
+		t.Fatal(result.Err)
+	}
+	if atomic.LoadInt32(&blocked) != 2 {
+		t.Fatalf("expected a single refresh of 2 eth_calls, got %d", atomic.LoadInt32(&blocked))
+	}
+
+	// Later requests get the refreshed results
+	client.hook = nil
+	revalidating.MaxAge = time.Hour
+	_, latest, err := revalidating.Get(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !latest.FetchedAt.After(first.FetchedAt) {
+		t.Fatal("expected the refreshed results to be served")
+	}
+}
+
+func TestRevalidatingBatchStaleAfterNewHead(t *testing.T) {
+	mc, _ := newTestMultiCaller(t)
+	mc.LatestCache = NewLatestCachePolicy(NewMemoryCache(10, 0))
+	revalidating := NewRevalidatingBatch(mc, &Batch{}, true, 0)
+	result := &RevalidationResult{BlockNumber: big.NewInt(100), FetchedAt: time.Now()}
+	if revalidating.isStale(result) {
+		t.Fatal("expected the results to be fresh without a newer head")
+	}
+	mc.LatestCache.ObserveHead(big.NewInt(101))
+	if !revalidating.isStale(result) {
+		t.Fatal("expected the results to be stale after a newer head")
+	}
+}
@@ -0,0 +1,119 @@
+package rocketpool
+
+import (
+	"fmt"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	batchquery "github.com/rocket-pool/batch-query"
+)
+
+// The details of a single node operator
+type NodeDetails struct {
+	// The node's address
+	Address common.Address
+
+	// The address the node's rewards and withdrawals are sent to
+	WithdrawalAddress common.Address
+
+	// The timezone location the node registered with
+	TimezoneLocation string
+
+	// The time the node registered, in seconds since the Unix epoch
+	RegistrationTime uint64
+
+	// The number of minipools the node has created
+	MinipoolCount uint64
+
+	// The amount of RPL the node has staked
+	RplStake *big.Int
+
+	// The node's ETH balance
+	EthBalance *big.Int
+}
+
+// Gets the addresses of every registered node at the block in opts, or at the latest block if opts doesn't specify one.
+// The addresses are read with one call per node, which the MultiCaller splits into chunks according to its limits.
+func (q *Querier) GetNodeAddresses(opts *bind.CallOpts) ([]common.Address, error) {
+	session, contracts, err := q.newSession(opts, nodeManagerContractName)
+	if err != nil {
+		return nil, err
+	}
+	return getNodeAddresses(session, contracts[nodeManagerContractName])
+}
+
+// Gets the details of each of the provided nodes at the block in opts, or at the latest block if opts doesn't specify one.
+// The order of the resulting array corresponds to the order of the provided addresses.
+func (q *Querier) GetNodeDetails(nodes []common.Address, opts *bind.CallOpts) ([]NodeDetails, error) {
+	session, contracts, err := q.newSession(opts, nodeManagerContractName, minipoolManagerContractName, nodeStakingContractName)
+	if err != nil {
+		return nil, err
+	}
+	return q.getNodeDetails(session, contracts, nodes)
+}
+
+// Gets the details of every registered node at the block in opts, or at the latest block if opts doesn't specify one.
+// The node set and the details are read at the same block, so a node registering in the meantime can't be missed or half-read.
+func (q *Querier) GetAllNodeDetails(opts *bind.CallOpts) ([]NodeDetails, error) {
+	session, contracts, err := q.newSession(opts, nodeManagerContractName, minipoolManagerContractName, nodeStakingContractName)
+	if err != nil {
+		return nil, err
+	}
+	nodes, err := getNodeAddresses(session, contracts[nodeManagerContractName])
+	if err != nil {
+		return nil, err
+	}
+	return q.getNodeDetails(session, contracts, nodes)
+}
+
+// Gets the addresses of every registered node in the session
+func getNodeAddresses(session *batchquery.Session, nodeManager common.Address) ([]common.Address, error) {
+	var count *big.Int
+	session.AddCall(nodeManager, &abis.nodeManager, &count, "getNodeCount")
+	_, err := session.Execute(true)
+	if err != nil {
+		return nil, fmt.Errorf("error getting node count: %w", err)
+	}
+
+	nodes := make([]common.Address, count.Uint64())
+	for i := range nodes {
+		session.AddCall(nodeManager, &abis.nodeManager, &nodes[i], "getNodeAt", big.NewInt(int64(i)))
+	}
+	_, err = session.Execute(true)
+	if err != nil {
+		return nil, fmt.Errorf("error getting node addresses: %w", err)
+	}
+	return nodes, nil
+}
+
+// Gets the details of each of the provided nodes in the session
+func (q *Querier) getNodeDetails(session *batchquery.Session, contracts map[string]common.Address, nodes []common.Address) ([]NodeDetails, error) {
+	details := make([]NodeDetails, len(nodes))
+	registrationTimes := make([]*big.Int, len(nodes))
+	minipoolCounts := make([]*big.Int, len(nodes))
+	for i, node := range nodes {
+		details[i].Address = node
+		session.AddCall(q.storageAddress, &abis.rocketStorage, &details[i].WithdrawalAddress, "getNodeWithdrawalAddress", node)
+		session.AddCall(contracts[nodeManagerContractName], &abis.nodeManager, &details[i].TimezoneLocation, "getNodeTimezoneLocation", node)
+		session.AddCall(contracts[nodeManagerContractName], &abis.nodeManager, &registrationTimes[i], "getNodeRegistrationTime", node)
+		session.AddCall(contracts[minipoolManagerContractName], &abis.minipoolManager, &minipoolCounts[i], "getNodeMinipoolCount", node)
+		session.AddCall(contracts[nodeStakingContractName], &abis.nodeStaking, &details[i].RplStake, "getNodeRPLStake", node)
+	}
+	_, err := session.Execute(true)
+	if err != nil {
+		return nil, fmt.Errorf("error getting node details: %w", err)
+	}
+
+	// ETH balances come from the multicall contract itself, so they're read with the MultiCaller at the session's block
+	ethBalances, err := q.caller.GetEthBalances(nodes, session.CallOpts())
+	if err != nil {
+		return nil, fmt.Errorf("error getting node balances: %w", err)
+	}
+	for i := range details {
+		details[i].RegistrationTime = registrationTimes[i].Uint64()
+		details[i].MinipoolCount = minipoolCounts[i].Uint64()
+		details[i].EthBalance = ethBalances[i]
+	}
+	return details, nil
+}
@@ -0,0 +1,175 @@
+// Package rocketpool provides prebuilt batched queries for common reads of the Rocket Pool protocol, such as the rETH exchange rate
+// and the details of every node operator. It's built entirely on the public batch-query API, so it doubles as an example of using
+// sessions and large batches against a real protocol.
+//
+// Every query runs against a single block: the protocol's contract addresses are looked up in RocketStorage at that block,
+// so queries of historical blocks use the contracts that were live at the time, even across protocol upgrades.
+package rocketpool
+
+import (
+	"fmt"
+	"math/big"
+	"strings"
+	"sync"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+	batchquery "github.com/rocket-pool/batch-query"
+)
+
+const (
+	// The ABI for the RocketStorage getters used by the queries
+	rocketStorageAbiString string = "[{\"inputs\":[{\"internalType\":\"bytes32\",\"name\":\"_key\",\"type\":\"bytes32\"}],\"name\":\"getAddress\",\"outputs\":[{\"internalType\":\"address\",\"name\":\"r\",\"type\":\"address\"}],\"stateMutability\":\"view\",\"type\":\"function\"},{\"inputs\":[{\"internalType\":\"address\",\"name\":\"_nodeAddress\",\"type\":\"address\"}],\"name\":\"getNodeWithdrawalAddress\",\"outputs\":[{\"internalType\":\"address\",\"name\":\"\",\"type\":\"address\"}],\"stateMutability\":\"view\",\"type\":\"function\"}]"
+
+	// The ABI for the rocketTokenRETH getters used by the queries
+	rethAbiString string = "[{\"inputs\":[],\"name\":\"getExchangeRate\",\"outputs\":[{\"internalType\":\"uint256\",\"name\":\"\",\"type\":\"uint256\"}],\"stateMutability\":\"view\",\"type\":\"function\"},{\"inputs\":[],\"name\":\"totalSupply\",\"outputs\":[{\"internalType\":\"uint256\",\"name\":\"\",\"type\":\"uint256\"}],\"stateMutability\":\"view\",\"type\":\"function\"}]"
+
+	// The ABI for the rocketNodeManager getters used by the queries
+	nodeManagerAbiString string = "[{\"inputs\":[],\"name\":\"getNodeCount\",\"outputs\":[{\"internalType\":\"uint256\",\"name\":\"\",\"type\":\"uint256\"}],\"stateMutability\":\"view\",\"type\":\"function\"},{\"inputs\":[{\"internalType\":\"uint256\",\"name\":\"_index\",\"type\":\"uint256\"}],\"name\":\"getNodeAt\",\"outputs\":[{\"internalType\":\"address\",\"name\":\"\",\"type\":\"address\"}],\"stateMutability\":\"view\",\"type\":\"function\"},{\"inputs\":[{\"internalType\":\"address\",\"name\":\"_nodeAddress\",\"type\":\"address\"}],\"name\":\"getNodeTimezoneLocation\",\"outputs\":[{\"internalType\":\"string\",\"name\":\"\",\"type\":\"string\"}],\"stateMutability\":\"view\",\"type\":\"function\"},{\"inputs\":[{\"internalType\":\"address\",\"name\":\"_nodeAddress\",\"type\":\"address\"}],\"name\":\"getNodeRegistrationTime\",\"outputs\":[{\"internalType\":\"uint256\",\"name\":\"\",\"type\":\"uint256\"}],\"stateMutability\":\"view\",\"type\":\"function\"}]"
+
+	// The ABI for the rocketMinipoolManager getters used by the queries
+	minipoolManagerAbiString string = "[{\"inputs\":[],\"name\":\"getMinipoolCount\",\"outputs\":[{\"internalType\":\"uint256\",\"name\":\"\",\"type\":\"uint256\"}],\"stateMutability\":\"view\",\"type\":\"function\"},{\"inputs\":[{\"internalType\":\"address\",\"name\":\"_nodeAddress\",\"type\":\"address\"}],\"name\":\"getNodeMinipoolCount\",\"outputs\":[{\"internalType\":\"uint256\",\"name\":\"\",\"type\":\"uint256\"}],\"stateMutability\":\"view\",\"type\":\"function\"}]"
+
+	// The ABI for the rocketNodeStaking getters used by the queries
+	nodeStakingAbiString string = "[{\"inputs\":[{\"internalType\":\"address\",\"name\":\"_nodeAddress\",\"type\":\"address\"}],\"name\":\"getNodeRPLStake\",\"outputs\":[{\"internalType\":\"uint256\",\"name\":\"\",\"type\":\"uint256\"}],\"stateMutability\":\"view\",\"type\":\"function\"}]"
+)
+
+const (
+	// The names of the protocol contracts in RocketStorage
+	rethContractName            string = "rocketTokenRETH"
+	nodeManagerContractName     string = "rocketNodeManager"
+	minipoolManagerContractName string = "rocketMinipoolManager"
+	nodeStakingContractName     string = "rocketNodeStaking"
+)
+
+// The address of RocketStorage on Ethereum mainnet, which every other protocol contract is registered in
+var MainnetRocketStorageAddress = common.HexToAddress("0x1d8f8f00cfa6758d7bE78336684788Fb0ee0Fa46")
+
+// The parsed ABIs of the protocol contracts
+type protocolAbis struct {
+	rocketStorage   abi.ABI
+	reth            abi.ABI
+	nodeManager     abi.ABI
+	minipoolManager abi.ABI
+	nodeStaking     abi.ABI
+}
+
+// ABI cache
+var abis protocolAbis
+var abisErr error
+var abisOnce sync.Once
+
+// Network-wide statistics of the protocol
+type NetworkStats struct {
+	// The block the statistics were read at
+	BlockNumber *big.Int
+
+	// The amount of ETH one rETH is worth, with 18 decimals
+	RethExchangeRate *big.Int
+
+	// The total supply of rETH
+	RethSupply *big.Int
+
+	// The number of registered nodes
+	NodeCount uint64
+
+	// The number of minipools that have been created
+	MinipoolCount uint64
+}
+
+// This struct runs batched queries against a Rocket Pool deployment
+type Querier struct {
+	// The address of the deployment's RocketStorage contract
+	storageAddress common.Address
+
+	// The MultiCaller used to run the queries
+	caller *batchquery.MultiCaller
+}
+
+// Creates a new Querier instance for the deployment with the provided RocketStorage address, such as MainnetRocketStorageAddress
+func NewQuerier(caller *batchquery.MultiCaller, rocketStorageAddress common.Address) *Querier {
+	return &Querier{
+		storageAddress: rocketStorageAddress,
+		caller:         caller,
+	}
+}
+
+// Gets the network-wide statistics of the protocol at the block in opts, or at the latest block if opts doesn't specify one
+func (q *Querier) GetNetworkStats(opts *bind.CallOpts) (*NetworkStats, error) {
+	session, contracts, err := q.newSession(opts, rethContractName, nodeManagerContractName, minipoolManagerContractName)
+	if err != nil {
+		return nil, err
+	}
+
+	stats := &NetworkStats{
+		BlockNumber: session.BlockNumber(),
+	}
+	var nodeCount *big.Int
+	var minipoolCount *big.Int
+	session.AddCall(contracts[rethContractName], &abis.reth, &stats.RethExchangeRate, "getExchangeRate")
+	session.AddCall(contracts[rethContractName], &abis.reth, &stats.RethSupply, "totalSupply")
+	session.AddCall(contracts[nodeManagerContractName], &abis.nodeManager, &nodeCount, "getNodeCount")
+	session.AddCall(contracts[minipoolManagerContractName], &abis.minipoolManager, &minipoolCount, "getMinipoolCount")
+	_, err = session.Execute(true)
+	if err != nil {
+		return nil, fmt.Errorf("error getting network stats: %w", err)
+	}
+	stats.NodeCount = nodeCount.Uint64()
+	stats.MinipoolCount = minipoolCount.Uint64()
+	return stats, nil
+}
+
+// Creates a session for a query and looks up the addresses of the contracts it uses at the session's block
+func (q *Querier) newSession(opts *bind.CallOpts, names ...string) (*batchquery.Session, map[string]common.Address, error) {
+	err := loadAbis()
+	if err != nil {
+		return nil, nil, err
+	}
+	session, err := batchquery.NewSession(q.caller, opts)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	addresses := make([]common.Address, len(names))
+	for i, name := range names {
+		key := crypto.Keccak256Hash([]byte("contract.address" + name))
+		session.AddCall(q.storageAddress, &abis.rocketStorage, &addresses[i], "getAddress", key)
+	}
+	_, err = session.Execute(true)
+	if err != nil {
+		return nil, nil, fmt.Errorf("error getting protocol contract addresses: %w", err)
+	}
+
+	contracts := make(map[string]common.Address, len(names))
+	for i, name := range names {
+		if addresses[i] == (common.Address{}) {
+			return nil, nil, fmt.Errorf("contract %s is not registered in RocketStorage at %s", name, q.storageAddress.Hex())
+		}
+		contracts[name] = addresses[i]
+	}
+	return session, contracts, nil
+}
+
+// Parses the ABIs of the protocol contracts
+func loadAbis() error {
+	abisOnce.Do(func() {
+		for _, parse := range []struct {
+			abi    *abi.ABI
+			source string
+		}{
+			{&abis.rocketStorage, rocketStorageAbiString},
+			{&abis.reth, rethAbiString},
+			{&abis.nodeManager, nodeManagerAbiString},
+			{&abis.minipoolManager, minipoolManagerAbiString},
+			{&abis.nodeStaking, nodeStakingAbiString},
+		} {
+			*parse.abi, abisErr = abi.JSON(strings.NewReader(parse.source))
+			if abisErr != nil {
+				return
+			}
+		}
+	})
+	return abisErr
+}
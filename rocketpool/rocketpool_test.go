@@ -0,0 +1,232 @@
+package rocketpool
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+	batchquery "github.com/rocket-pool/batch-query"
+)
+
+// The multicall functions the mock emulates
+const testMulticallAbiString = `[
+	{"inputs":[],"name":"getBlockNumber","outputs":[{"name":"blockNumber","type":"uint256"}],"stateMutability":"view","type":"function"},
+	{"inputs":[{"name":"addr","type":"address"}],"name":"getEthBalance","outputs":[{"name":"balance","type":"uint256"}],"stateMutability":"view","type":"function"},
+	{"inputs":[{"name":"requireSuccess","type":"bool"},{"components":[{"name":"target","type":"address"},{"name":"callData","type":"bytes"}],"name":"calls","type":"tuple[]"}],"name":"tryAggregate","outputs":[{"components":[{"name":"success","type":"bool"},{"name":"returnData","type":"bytes"}],"name":"returnData","type":"tuple[]"}],"stateMutability":"nonpayable","type":"function"}
+]`
+
+var (
+	testMulticallAddress = common.HexToAddress("0x1111111111111111111111111111111111111111")
+	testStorageAddress   = common.HexToAddress("0x5555555555555555555555555555555555555555")
+	testContracts        = map[string]common.Address{
+		rethContractName:            common.HexToAddress("0xa1"),
+		nodeManagerContractName:     common.HexToAddress("0xa2"),
+		minipoolManagerContractName: common.HexToAddress("0xa3"),
+		nodeStakingContractName:     common.HexToAddress("0xa4"),
+	}
+	testMulticallAbi = mustParseAbi(testMulticallAbiString)
+)
+
+// Parses an ABI, panicking on failure
+func mustParseAbi(abiString string) abi.ABI {
+	parsed, err := abi.JSON(strings.NewReader(abiString))
+	if err != nil {
+		panic(err)
+	}
+	return parsed
+}
+
+// A client that emulates a multicall contract and a Rocket Pool deployment with the provided number of nodes.
+// Node i has address 0x1000 + i, i + 1 minipools, i + 1 RPL staked, and a balance of i ETH.
+type mockProtocolClient struct {
+	// The number of registered nodes
+	nodeCount int
+
+	// The blocks the eth_calls were run at
+	blocks []*big.Int
+
+	lock sync.Mutex
+}
+
+// Gets the address of a mock node
+func testNodeAddress(index int) common.Address {
+	return common.BigToAddress(big.NewInt(int64(0x1000 + index)))
+}
+
+// Gets the index of a mock node from its address
+func testNodeIndex(address common.Address) int64 {
+	return address.Big().Int64() - 0x1000
+}
+
+// Runs a single call against one of the mock contracts
+func (m *mockProtocolClient) runCall(target common.Address, data []byte) ([]byte, error) {
+	contracts := map[common.Address]*abi.ABI{
+		testMulticallAddress:                       &testMulticallAbi,
+		testStorageAddress:                         &abis.rocketStorage,
+		testContracts[rethContractName]:            &abis.reth,
+		testContracts[nodeManagerContractName]:     &abis.nodeManager,
+		testContracts[minipoolManagerContractName]: &abis.minipoolManager,
+		testContracts[nodeStakingContractName]:     &abis.nodeStaking,
+	}
+	contractAbi, exists := contracts[target]
+	if !exists {
+		return nil, fmt.Errorf("no contract at %s", target.Hex())
+	}
+	method, err := contractAbi.MethodById(data[:4])
+	if err != nil {
+		return nil, err
+	}
+	args, err := method.Inputs.Unpack(data[4:])
+	if err != nil {
+		return nil, err
+	}
+
+	eth := big.NewInt(1e18)
+	switch method.Name {
+	case "getBlockNumber":
+		return method.Outputs.Pack(big.NewInt(100))
+	case "getEthBalance":
+		return method.Outputs.Pack(new(big.Int).Mul(big.NewInt(testNodeIndex(args[0].(common.Address))), eth))
+	case "getAddress":
+		for name, address := range testContracts {
+			if crypto.Keccak256Hash([]byte("contract.address"+name)) == common.Hash(args[0].([32]byte)) {
+				return method.Outputs.Pack(address)
+			}
+		}
+		return method.Outputs.Pack(common.Address{})
+	case "getNodeWithdrawalAddress":
+		return method.Outputs.Pack(args[0].(common.Address))
+	case "getExchangeRate":
+		return method.Outputs.Pack(big.NewInt(1.1e18))
+	case "totalSupply":
+		return method.Outputs.Pack(new(big.Int).Mul(big.NewInt(500), eth))
+	case "getNodeCount":
+		return method.Outputs.Pack(big.NewInt(int64(m.nodeCount)))
+	case "getNodeAt":
+		return method.Outputs.Pack(testNodeAddress(int(args[0].(*big.Int).Int64())))
+	case "getNodeTimezoneLocation":
+		return method.Outputs.Pack("Etc/UTC")
+	case "getNodeRegistrationTime":
+		return method.Outputs.Pack(big.NewInt(1000 + testNodeIndex(args[0].(common.Address))))
+	case "getMinipoolCount":
+		return method.Outputs.Pack(big.NewInt(int64(m.nodeCount * 2)))
+	case "getNodeMinipoolCount":
+		return method.Outputs.Pack(big.NewInt(testNodeIndex(args[0].(common.Address)) + 1))
+	case "getNodeRPLStake":
+		return method.Outputs.Pack(new(big.Int).Mul(big.NewInt(testNodeIndex(args[0].(common.Address))+1), eth))
+	}
+	return nil, fmt.Errorf("unexpected method %s", method.Name)
+}
+
+func (m *mockProtocolClient) CallContract(ctx context.Context, msg ethereum.CallMsg, blockNumber *big.Int) ([]byte, error) {
+	m.lock.Lock()
+	m.blocks = append(m.blocks, blockNumber)
+	m.lock.Unlock()
+	method, err := testMulticallAbi.MethodById(msg.Data[:4])
+	if *msg.To != testMulticallAddress || err != nil || method.Name != "tryAggregate" {
+		return m.runCall(*msg.To, msg.Data)
+	}
+
+	args, err := method.Inputs.Unpack(msg.Data[4:])
+	if err != nil {
+		return nil, err
+	}
+	var calls []struct {
+		Target   common.Address
+		CallData []byte
+	}
+	abi.ConvertType(args[1], &calls)
+	type result struct {
+		Success    bool
+		ReturnData []byte
+	}
+	results := make([]result, len(calls))
+	for i, call := range calls {
+		out, err := m.runCall(call.Target, call.CallData)
+		if err != nil {
+			return nil, err
+		}
+		results[i] = result{true, out}
+	}
+	return method.Outputs.Pack(results)
+}
+
+// Creates a Querier backed by a mock deployment
+func newTestQuerier(t *testing.T, nodeCount int) (*Querier, *mockProtocolClient) {
+	err := loadAbis()
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := &mockProtocolClient{nodeCount: nodeCount}
+	mc, err := batchquery.NewMultiCaller(client, testMulticallAddress)
+	if err != nil {
+		t.Fatal(err)
+	}
+	mc.CallBatchSize = 50
+	return NewQuerier(mc, testStorageAddress), client
+}
+
+func TestGetNetworkStats(t *testing.T) {
+	querier, client := newTestQuerier(t, 4)
+
+	stats, err := querier.GetNetworkStats(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats.BlockNumber.Int64() != 100 || stats.RethExchangeRate.Int64() != 1.1e18 || stats.NodeCount != 4 || stats.MinipoolCount != 8 {
+		t.Fatalf("unexpected network stats: %+v", stats)
+	}
+
+	// Every query after pinning the block runs at it
+	for _, block := range client.blocks[1:] {
+		if block == nil || block.Int64() != 100 {
+			t.Fatalf("expected every query to run at block 100, got %v", client.blocks)
+		}
+	}
+}
+
+func TestGetAllNodeDetails(t *testing.T) {
+	querier, client := newTestQuerier(t, 120)
+
+	details, err := querier.GetAllNodeDetails(&bind.CallOpts{BlockNumber: big.NewInt(100)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(details) != 120 {
+		t.Fatalf("expected 120 nodes, got %d", len(details))
+	}
+	for i, node := range details {
+		eth := big.NewInt(1e18)
+		if node.Address != testNodeAddress(i) || node.WithdrawalAddress != node.Address || node.TimezoneLocation != "Etc/UTC" {
+			t.Fatalf("unexpected details for node %d: %+v", i, node)
+		}
+		if node.RegistrationTime != uint64(1000+i) || node.MinipoolCount != uint64(i+1) {
+			t.Fatalf("unexpected details for node %d: %+v", i, node)
+		}
+		if node.RplStake.Cmp(new(big.Int).Mul(big.NewInt(int64(i+1)), eth)) != 0 || node.EthBalance.Cmp(new(big.Int).Mul(big.NewInt(int64(i)), eth)) != 0 {
+			t.Fatalf("unexpected balances for node %d: %+v", i, node)
+		}
+	}
+
+	// The large node set is split into several chunks
+	if len(client.blocks) < 10 {
+		t.Fatalf("expected the node details to be split into chunks, got %d eth_calls", len(client.blocks))
+	}
+}
+
+func TestUnregisteredContract(t *testing.T) {
+	querier, _ := newTestQuerier(t, 1)
+
+	_, _, err := querier.newSession(nil, nodeManagerContractName, "rocketDAOProtocol")
+	if err == nil || !strings.Contains(err.Error(), "rocketDAOProtocol is not registered") {
+		t.Fatalf("expected the unregistered contract to be reported, got %v", err)
+	}
+}
@@ -0,0 +1,222 @@
+package batchquery
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+const (
+	// The default number of requests to send in a single JSON-RPC batch request
+	defaultRpcBatchSize int = 100
+)
+
+// BatchClient wraps an ethclient.Client so it also implements IBatchCaller, using the RPC client underneath it for batch requests
+type BatchClient struct {
+	*ethclient.Client
+}
+
+// Creates a new BatchClient from an ethclient.Client, such as one created by DialTunedClient
+func NewBatchClient(client *ethclient.Client) *BatchClient {
+	return &BatchClient{
+		Client: client,
+	}
+}
+
+// Sends all of the requests in a single JSON-RPC batch, storing each one's result or error in its element
+func (c *BatchClient) BatchCallContext(ctx context.Context, b []rpc.BatchElem) error {
+	return c.Client.Client().BatchCallContext(ctx, b)
+}
+
+// Gets the value of a storage slot of an account at the block with the provided hash, using eth_getStorageAt
+func (c *BatchClient) StorageAtHash(ctx context.Context, account common.Address, key common.Hash, blockHash common.Hash) ([]byte, error) {
+	var result hexutil.Bytes
+	err := c.Client.Client().CallContext(ctx, &result, "eth_getStorageAt", account, key, rpc.BlockNumberOrHashWithHash(blockHash, false))
+	return result, err
+}
+
+// Gets the runtime code of an account at the block with the provided hash, using eth_getCode
+func (c *BatchClient) CodeAtHash(ctx context.Context, account common.Address, blockHash common.Hash) ([]byte, error) {
+	var result hexutil.Bytes
+	err := c.Client.Client().CallContext(ctx, &result, "eth_getCode", account, rpc.BlockNumberOrHashWithHash(blockHash, false))
+	return result, err
+}
+
+// Sends the requests, which all use the provided method, in JSON-RPC batches of up to batchSize requests (0 = defaultRpcBatchSize) through the executor,
+// running up to threadLimit batches at once (0 = no limit).
+// Each batch request is cancelled if it takes longer than the timeout (0 = no deadline beyond the one in ctx). Only failures of the batch requests themselves are returned; the error of each individual request is stored in its element.
+func runRpcBatches(ctx context.Context, executor *Executor, caller IBatchCaller, method string, elems []rpc.BatchElem, batchSize int, threadLimit int, timeout time.Duration) error {
+	if batchSize <= 0 {
+		batchSize = defaultRpcBatchSize
+	}
+
+	// A failure in any batch cancels the rest of them
+	count := (len(elems) + batchSize - 1) / batchSize
+	return executor.run(ctx, threadLimit, method, count, getBatchSizes(len(elems), batchSize), func(ctx context.Context, index int) error {
+		start := index * batchSize
+		end := start + batchSize
+		if end > len(elems) {
+			end = len(elems)
+		}
+		batch := elems[start:end]
+		batchCtx, cancel := withRequestTimeout(ctx, timeout)
+		defer cancel()
+		err := caller.BatchCallContext(batchCtx, batch)
+		if err != nil {
+			return fmt.Errorf("error sending batch of %d requests: %w", len(batch), wrapClientError(err))
+		}
+		return nil
+	})
+}
+
+// Gets the JSON-RPC block parameter for the block targeted by the options
+func (o *callOptions) blockArg() any {
+	if o.blockHash != nil {
+		return rpc.BlockNumberOrHashWithHash(*o.blockHash, false)
+	}
+	if o.pending {
+		return "pending"
+	}
+	if o.blockNumber == nil {
+		return "latest"
+	}
+	if o.blockNumber.Sign() < 0 {
+		// Negative numbers are the special block tags, such as rpc.FinalizedBlockNumber
+		return rpc.BlockNumber(o.blockNumber.Int64()).String()
+	}
+	return (*hexutil.Big)(o.blockNumber)
+}
+
+// Runs each call as its own eth_call within JSON-RPC batch requests, rather than aggregating them through the multicall contract.
+// Reverted calls are reported as failures with their revert data; if requireSuccess is true, a revert fails the whole batch instead.
+// The responses are returned in the same order as the provided calls.
+// If provided, onChunk is called with the responses of each batch request as soon as it completes.
+func (mc *MultiCaller) executeRpcBatches(calls []*Call, requireSuccess bool, opts *callOptions, onChunk func(chunk callChunk, responses []CallResponse)) ([]CallResponse, error) {
+	caller, ok := mc.client.(IBatchCaller)
+	if !ok {
+		return nil, fmt.Errorf("client does not support JSON-RPC batch requests")
+	}
+	batchSize := mc.CallBatchSize
+	if batchSize <= 0 {
+		batchSize = defaultRpcBatchSize
+	}
+	responses := make([]CallResponse, len(calls))
+
+	// A failure in any batch cancels the rest of them
+	count := (len(calls) + batchSize - 1) / batchSize
+	err := mc.Executor.run(opts.ctx, mc.ThreadLimit, "eth_call", count, getBatchSizes(len(calls), batchSize), func(ctx context.Context, index int) error {
+		start := index * batchSize
+		end := start + batchSize
+		if end > len(calls) {
+			end = len(calls)
+		}
+		chunk := callChunk{
+			calls:   calls[start:end],
+			indices: make([]int, end-start),
+		}
+		for i := range chunk.indices {
+			chunk.indices[i] = start + i
+		}
+		chunkResponses := responses[start:end]
+		batchCtx := ctx
+		if mc.ChunkTimeout > 0 {
+			var cancel context.CancelFunc
+			batchCtx, cancel = context.WithTimeout(ctx, mc.ChunkTimeout)
+			defer cancel()
+		}
+		err := mc.runChunk(batchCtx, index, len(chunk.calls), func(ctx context.Context) error {
+			return mc.executeRpcBatch(ctx, caller, chunk, requireSuccess, opts, chunkResponses)
+		})
+		if err != nil {
+			if requireSuccess || !exceededOwnDeadline(ctx, batchCtx) {
+				return err
+			}
+
+			// A tolerant batch reports the calls in a batch request that ran out of time as failures instead of giving up on the rest
+			for i := range chunkResponses {
+				chunkResponses[i] = CallResponse{}
+			}
+		}
+		if onChunk != nil {
+			onChunk(chunk, chunkResponses)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return responses, nil
+}
+
+// Runs the calls of a single chunk as eth_calls within one JSON-RPC batch request, storing the responses in the provided results slice
+func (mc *MultiCaller) executeRpcBatch(ctx context.Context, caller IBatchCaller, chunk callChunk, requireSuccess bool, opts *callOptions, results []CallResponse) error {
+	gasLimit := mc.gasLimit(opts)
+	returnData := make([]hexutil.Bytes, len(chunk.calls))
+	elems := make([]rpc.BatchElem, len(chunk.calls))
+	for i, call := range chunk.calls {
+		callOpts := call.targetOptions(opts)
+		msg := map[string]any{
+			"to":   call.Target,
+			"data": hexutil.Bytes(call.CallData),
+		}
+		if callOpts.from != (common.Address{}) {
+			msg["from"] = callOpts.from
+		}
+		if gasLimit > 0 {
+			msg["gas"] = hexutil.Uint64(gasLimit)
+		}
+		elems[i] = rpc.BatchElem{
+			Method: "eth_call",
+			Args:   []any{msg, callOpts.blockArg()},
+			Result: &returnData[i],
+		}
+	}
+
+	err := caller.BatchCallContext(ctx, elems)
+	if err != nil {
+		return fmt.Errorf("error sending batch of %d calls at block %s: %w", len(elems), opts.blockDescription(), wrapClientError(err))
+	}
+	for i, call := range chunk.calls {
+		if elems[i].Error == nil {
+			results[i] = CallResponse{
+				Status:     true,
+				ReturnData: returnData[i],
+			}
+			continue
+		}
+		revertData, isRevert := getRevertData(elems[i].Error)
+		if !isRevert {
+			return fmt.Errorf("error calling contract %s, method %s: %w", call.Target.Hex(), call.Method, wrapClientError(elems[i].Error))
+		}
+		if requireSuccess {
+			return &ErrCallReverted{
+				Index:       chunk.indices[i],
+				Target:      call.Target,
+				Method:      call.Method,
+				Data:        revertData,
+				contractAbi: call.contractAbi,
+			}
+		}
+		results[i] = CallResponse{
+			Status:     false,
+			ReturnData: revertData,
+		}
+	}
+	return nil
+}
+
+// Gets the function that returns the size of each batch, when a number of requests are split into batches of up to batchSize requests
+func getBatchSizes(total int, batchSize int) func(index int) int {
+	return func(index int) int {
+		end := (index + 1) * batchSize
+		if end > total {
+			end = total
+		}
+		return end - index*batchSize
+	}
+}
@@ -0,0 +1,213 @@
+package batchquery
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+// A storage reader that supports JSON-RPC batching, where every slot holds its own index
+type mockBatchStorageReader struct {
+	mockStorageReader
+
+	// The size of each batch request
+	batchSizes []int
+
+	// The block parameter of each request
+	blockArgs []any
+
+	lock sync.Mutex
+}
+
+func (m *mockBatchStorageReader) BatchCallContext(ctx context.Context, b []rpc.BatchElem) error {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	m.batchSizes = append(m.batchSizes, len(b))
+	for i := range b {
+		if b[i].Method != "eth_getStorageAt" {
+			b[i].Error = fmt.Errorf("unsupported method %s", b[i].Method)
+			continue
+		}
+		slot := b[i].Args[1].(common.Hash)
+		m.blockArgs = append(m.blockArgs, b[i].Args[2])
+		*b[i].Result.(*hexutil.Bytes) = slot.Bytes()
+	}
+	return nil
+}
+
+func TestGetStorageUsesBatchRequests(t *testing.T) {
+	client := &mockBatchStorageReader{}
+	batcher := NewStorageBatcher(client, 2)
+	batcher.RpcBatchSize = 2
+	slots := make([]StorageSlot, 5)
+	for i := range slots {
+		slots[i] = StorageSlot{Address: testTokenAddress, Slot: common.BigToHash(big.NewInt(int64(i)))}
+	}
+
+	values, err := batcher.GetStorage(slots, &bind.CallOpts{BlockNumber: big.NewInt(12)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, value := range values {
+		if value.Big().Int64() != int64(i) {
+			t.Fatalf("expected slot %d to hold %d, got %s", i, i, value.Hex())
+		}
+	}
+	if len(client.batchSizes) != 3 {
+		t.Fatalf("expected 3 batch requests, got %v", client.batchSizes)
+	}
+	if block, ok := client.blockArgs[0].(*hexutil.Big); !ok || block.ToInt().Int64() != 12 {
+		t.Fatalf("expected the requests to target block 12, got %v", client.blockArgs[0])
+	}
+}
+
+func TestBlockArg(t *testing.T) {
+	hash := common.HexToHash("0x01")
+	tests := []struct {
+		opts     *bind.CallOpts
+		expected string
+	}{
+		{nil, `"latest"`},
+		{&bind.CallOpts{Pending: true}, `"pending"`},
+		{&bind.CallOpts{BlockNumber: big.NewInt(16)}, `"0x10"`},
+		{&bind.CallOpts{BlockNumber: big.NewInt(int64(rpc.FinalizedBlockNumber))}, `"finalized"`},
+		{CallOptsAtHash(nil, hash), `{"blockHash":"` + hash.Hex() + `"}`},
+	}
+	for _, test := range tests {
+		data, err := json.Marshal(newCallOptions(test.opts).blockArg())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(data) != test.expected {
+			t.Fatalf("expected %s, got %s", test.expected, data)
+		}
+	}
+}
+
+func TestBatchClientSendsBatchRequests(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var requests []struct {
+			ID json.RawMessage `json:"id"`
+		}
+		err := json.NewDecoder(r.Body).Decode(&requests)
+		if err != nil {
+			http.Error(w, "expected a batch request", http.StatusBadRequest)
+			return
+		}
+		responses := make([]string, len(requests))
+		for i, request := range requests {
+			responses[i] = `{"jsonrpc":"2.0","id":` + string(request.ID) + `,"result":"0x01"}`
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte("[" + strings.Join(responses, ",") + "]"))
+	}))
+	defer server.Close()
+
+	ethClient, err := DialTunedClient(context.Background(), server.URL, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ethClient.Close()
+	batcher := NewStorageBatcher(NewBatchClient(ethClient), 0)
+	values, err := batcher.GetStorage([]StorageSlot{{Address: testTokenAddress}, {Address: testTokenAddress}}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if values[0].Big().Int64() != 1 || values[1].Big().Int64() != 1 {
+		t.Fatalf("expected both slots to hold 1, got %v", values)
+	}
+}
+
+// A mock client that also supports JSON-RPC batches of eth_calls
+type mockRpcBatchClient struct {
+	*mockClient
+
+	// The number of batch requests sent
+	batches int
+}
+
+func (m *mockRpcBatchClient) BatchCallContext(ctx context.Context, b []rpc.BatchElem) error {
+	m.lock.Lock()
+	m.batches++
+	m.lock.Unlock()
+	for i := range b {
+		msg := b[i].Args[0].(map[string]any)
+		to := msg["to"].(common.Address)
+		callMsg := ethereum.CallMsg{To: &to, Data: msg["data"].(hexutil.Bytes)}
+		if from, ok := msg["from"].(common.Address); ok {
+			callMsg.From = from
+		}
+		var blockNumber *big.Int
+		if block, ok := b[i].Args[1].(*hexutil.Big); ok {
+			blockNumber = block.ToInt()
+		}
+		out, err := m.CallContract(ctx, callMsg, blockNumber)
+		if err != nil {
+			b[i].Error = err
+			continue
+		}
+		*b[i].Result.(*hexutil.Bytes) = out
+	}
+	return nil
+}
+
+func TestRpcBatchMode(t *testing.T) {
+	client := &mockRpcBatchClient{mockClient: &mockClient{}}
+	mc, err := NewMultiCaller(client, testMulticallAddress)
+	if err != nil {
+		t.Fatal(err)
+	}
+	mc.Strategy = StrategyRpcBatch
+	mc.CallBatchSize = 2
+
+	var now, before, boom *big.Int
+	account := common.HexToAddress("0x05")
+	mc.AddCall(testTokenAddress, &testTokenAbi, &now, "balanceOf", account)
+	mc.AddCall(testTokenAddress, &testTokenAbi, &before, "balanceOf", account).AtBlock(big.NewInt(20))
+	mc.AddCall(testTokenAddress, &testTokenAbi, &boom, "boom")
+	results, err := mc.FlexibleCallWithResults(false, &bind.CallOpts{BlockNumber: big.NewInt(100)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if now.Cmp(expectedBalance(account, 100)) != 0 || before.Cmp(expectedBalance(account, 20)) != 0 {
+		t.Fatalf("expected the balances at blocks 100 and 20, got %s and %s", now, before)
+	}
+	if results[2].Success || results[2].RevertReason() != "boom" {
+		t.Fatalf("expected the third call to revert with boom, got %+v", results[2])
+	}
+	if client.batches != 2 || len(client.getChunkSizes()) != 0 {
+		t.Fatalf("expected 2 batch requests and no multicalls, got %d and %v", client.batches, client.getChunkSizes())
+	}
+
+	// A revert fails a batch that requires success
+	mc.AddCall(testTokenAddress, &testTokenAbi, &boom, "boom")
+	_, err = mc.FlexibleCall(true, nil)
+	var reverted *ErrCallReverted
+	if !errors.As(err, &reverted) || reverted.Index != 0 {
+		t.Fatalf("expected the revert to fail the batch, got %v", err)
+	}
+}
+
+func TestRpcBatchModeRequiresBatchCaller(t *testing.T) {
+	mc, _ := newTestMultiCaller(t)
+	mc.Strategy = StrategyRpcBatch
+	var balance *big.Int
+	mc.AddCall(testTokenAddress, &testTokenAbi, &balance, "balanceOf", common.HexToAddress("0x05"))
+	_, err := mc.FlexibleCall(false, nil)
+	if err == nil {
+		t.Fatal("expected a client without batch support to be rejected")
+	}
+}
@@ -0,0 +1,254 @@
+package batchquery
+
+import (
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// What a Scheduler's AddCall does when a call would exceed its memory limit
+type MemoryLimitPolicy int
+
+const (
+	// Flush the pending calls into a new batch before adding the call, so the pending calls stay within the limit.
+	// This bounds the memory of the calls waiting to run, but not of the batches that are already running.
+	MemoryLimitFlush MemoryLimitPolicy = iota
+
+	// Flush the pending calls, then block until enough of the running batches have finished for the call to fit.
+	// This bounds the memory of both the pending and running calls, slowing producers down to the rate the batches are run at.
+	MemoryLimitBlock
+
+	// Reject the call, resolving its Future right away with an *ErrMemoryLimitExceeded.
+	// Like MemoryLimitBlock, this counts both the pending and running calls; the pending calls are flushed so their memory is freed sooner.
+	MemoryLimitReject
+)
+
+// Scheduler collects contract calls from many goroutines and automatically runs them through a MultiCaller in batches.
+// A batch is flushed once it reaches the size threshold, or once the time window since its first call has passed,
+// whichever comes first. Each caller gets a Future that resolves when its call's batch has been run.
+// Batches are run with requireSuccess set to false, so a failure in one call doesn't affect the others.
+type Scheduler struct {
+	// The MultiCaller whose client and settings are used to run each batch
+	caller *MultiCaller
+
+	// The number of pending calls that triggers a flush
+	batchSize int
+
+	// The maximum amount of time to wait for more calls before flushing
+	window time.Duration
+
+	// The call options to run each batch with
+	opts *bind.CallOpts
+
+	// The calls waiting for the next flush
+	pending []*Call
+
+	// The futures for the pending calls
+	futures []*Future
+
+	// The timer for the current time window
+	timer *time.Timer
+
+	// The maximum memory used by the scheduler's calls, measured as their packed call data plus their expected return data (0 = no limit)
+	memoryLimit int
+
+	// What AddCall does when a call would exceed the memory limit
+	memoryPolicy MemoryLimitPolicy
+
+	// The memory used by the pending calls
+	pendingBytes int
+
+	// The memory used by the calls of the batches that are running
+	runningBytes int
+
+	// Signalled whenever a running batch finishes and frees its memory
+	released *sync.Cond
+
+	// Lock for the pending calls
+	lock sync.Mutex
+}
+
+// The eventual result of a call added to a Scheduler
+type Future struct {
+	// Closed once the call has been run
+	done chan struct{}
+
+	// Whether or not the call worked
+	success bool
+
+	// The error from running the call's batch or unpacking its response, if there was one
+	err error
+}
+
+// Creates a new Scheduler that runs batches using the settings and client of the provided MultiCaller.
+// The MultiCaller's own list of pending calls is not used, and it can still be used separately.
+func NewScheduler(caller *MultiCaller, batchSize int, window time.Duration, opts *bind.CallOpts) *Scheduler {
+	scheduler := &Scheduler{
+		caller:    caller,
+		batchSize: batchSize,
+		window:    window,
+		opts:      opts,
+		pending:   []*Call{},
+		futures:   []*Future{},
+	}
+	scheduler.released = sync.NewCond(&scheduler.lock)
+	return scheduler
+}
+
+// Limits the memory used by the scheduler's calls, measured as their packed call data plus their expected return data (see Call.WithReturnSize),
+// to protect services whose producers add calls faster than the batches are run. The policy determines what AddCall does when a call
+// would exceed the limit. A call that exceeds the limit on its own is still accepted once nothing else is pending or running (0 = no limit).
+func (s *Scheduler) WithMemoryLimit(limit int, policy MemoryLimitPolicy) *Scheduler {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.memoryLimit = limit
+	s.memoryPolicy = policy
+	return s
+}
+
+// Adds a contract call to the next batch. This is safe to call from multiple goroutines.
+// The output will be populated once the returned Future resolves successfully.
+// The call data is packed right away, so if the arguments are invalid, the returned Future is already resolved with the error
+// and the call never joins a batch, where it would fail the other callers' calls.
+// If the scheduler has a memory limit, this may flush the pending calls, block, or reject the call, depending on the limit's policy.
+func (s *Scheduler) AddCall(contractAddress common.Address, abi *abi.ABI, output any, method string, args ...any) *Future {
+	call := newCall(contractAddress, abi, output, method, args...)
+	future := &Future{
+		done: make(chan struct{}),
+	}
+
+	err := packCalls([]*Call{call})
+	if err != nil {
+		future.err = err
+		close(future.done)
+		return future
+	}
+	call.PackFunc = nil
+	size := call.aggregatedCallDataSize() + call.expectedResponseSize()
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	err = s.reserveLocked(size)
+	if err != nil {
+		future.err = err
+		close(future.done)
+		return future
+	}
+	s.pending = append(s.pending, call)
+	s.pendingBytes += size
+	s.futures = append(s.futures, future)
+
+	if s.batchSize > 0 && len(s.pending) >= s.batchSize {
+		s.flushLocked()
+	} else if s.timer == nil {
+		s.timer = time.AfterFunc(s.window, s.Flush)
+	}
+	return future
+}
+
+// Immediately runs all of the pending calls in a new batch, without waiting for the size threshold or time window
+func (s *Scheduler) Flush() {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.flushLocked()
+}
+
+// Makes room for a call of the provided size within the memory limit, according to the limit's policy.
+// Returns an error if the call is rejected. The lock must be held by the caller.
+func (s *Scheduler) reserveLocked(size int) error {
+	if s.memoryLimit <= 0 || s.pendingBytes+s.runningBytes+size <= s.memoryLimit {
+		return nil
+	}
+
+	switch s.memoryPolicy {
+	case MemoryLimitBlock:
+		for s.pendingBytes+s.runningBytes > 0 && s.pendingBytes+s.runningBytes+size > s.memoryLimit {
+			if s.pendingBytes > 0 {
+				s.flushLocked()
+				continue
+			}
+			s.released.Wait()
+		}
+		return nil
+
+	case MemoryLimitReject:
+		used := s.pendingBytes + s.runningBytes
+		if used == 0 {
+			return nil
+		}
+		s.flushLocked()
+		return &ErrMemoryLimitExceeded{
+			Limit: s.memoryLimit,
+			Used:  used,
+			Size:  size,
+		}
+
+	default:
+		if s.pendingBytes+size > s.memoryLimit {
+			s.flushLocked()
+		}
+		return nil
+	}
+}
+
+// Runs all of the pending calls in the background. The lock must be held by the caller.
+func (s *Scheduler) flushLocked() {
+	if s.timer != nil {
+		s.timer.Stop()
+		s.timer = nil
+	}
+	if len(s.pending) == 0 {
+		return
+	}
+
+	calls := s.pending
+	futures := s.futures
+	size := s.pendingBytes
+	s.pending = []*Call{}
+	s.futures = []*Future{}
+	s.pendingBytes = 0
+	s.runningBytes += size
+
+	go func() {
+		defer s.release(size)
+		resolved := make([]bool, len(futures))
+		batch := s.caller.withCalls(calls)
+		err := batch.StreamCall(false, s.opts, func(result StreamResult) {
+			future := futures[result.Index]
+			future.success = result.Success
+			future.err = result.Err
+			resolved[result.Index] = true
+			close(future.done)
+		})
+		if err != nil {
+			for i, future := range futures {
+				if !resolved[i] {
+					future.err = err
+					close(future.done)
+				}
+			}
+		}
+	}()
+}
+
+// Frees the memory of a batch that has finished running, waking any callers that are waiting for room
+func (s *Scheduler) release(size int) {
+	s.lock.Lock()
+	s.runningBytes -= size
+	s.lock.Unlock()
+	s.released.Broadcast()
+}
+
+// Blocks until the call has been run, returning whether or not it worked
+func (f *Future) Wait() (bool, error) {
+	<-f.done
+	return f.success, f.err
+}
+
+// Returns a channel that's closed once the call has been run
+func (f *Future) Done() <-chan struct{} {
+	return f.done
+}
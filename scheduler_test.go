@@ -0,0 +1,205 @@
+package batchquery
+
+import (
+	"context"
+	"errors"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+)
+
+func TestSchedulerBatchesCalls(t *testing.T) {
+	mc, client := newTestMultiCaller(t)
+	scheduler := NewScheduler(mc, 3, time.Hour, nil)
+
+	balances := make([]*big.Int, 3)
+	futures := make([]*Future, 3)
+	for i := range futures {
+		futures[i] = scheduler.AddCall(testTokenAddress, &testTokenAbi, &balances[i], "balanceOf", common.BigToAddress(big.NewInt(int64(i))))
+	}
+	for i, future := range futures {
+		success, err := future.Wait()
+		if err != nil || !success {
+			t.Fatalf("call %d failed: %v", i, err)
+		}
+		if balances[i].Int64() != int64(i) {
+			t.Fatalf("call %d returned %s", i, balances[i])
+		}
+	}
+	if sizes := client.getChunkSizes(); len(sizes) != 1 || sizes[0] != 3 {
+		t.Fatalf("expected a single multicall of 3 calls, got %v", sizes)
+	}
+}
+
+func TestSchedulerIsolatesPackErrors(t *testing.T) {
+	mc, _ := newTestMultiCaller(t)
+	scheduler := NewScheduler(mc, 0, time.Hour, nil)
+
+	var good *big.Int
+	var bad *big.Int
+	goodFuture := scheduler.AddCall(testTokenAddress, &testTokenAbi, &good, "balanceOf", common.HexToAddress("0x05"))
+	badFuture := scheduler.AddCall(testTokenAddress, &testTokenAbi, &bad, "balanceOf", "not an address")
+	revertFuture := scheduler.AddCall(testTokenAddress, &testTokenAbi, &bad, "boom")
+
+	// The bad call fails immediately, without waiting for the batch
+	select {
+	case <-badFuture.Done():
+	default:
+		t.Fatal("expected the call with invalid arguments to resolve immediately")
+	}
+	_, err := badFuture.Wait()
+	if err == nil {
+		t.Fatal("expected a pack error")
+	}
+
+	scheduler.Flush()
+	success, err := goodFuture.Wait()
+	if err != nil || !success || good.Int64() != 5 {
+		t.Fatalf("expected the good call to succeed, got %v, %v, %s", success, err, good)
+	}
+	success, err = revertFuture.Wait()
+	if err != nil || success {
+		t.Fatalf("expected the reverting call to fail without an error, got %v, %v", success, err)
+	}
+}
+
+// Gets the memory a balanceOf call takes up in a scheduler
+func balanceOfCallSize(t *testing.T) int {
+	call := newCall(testTokenAddress, &testTokenAbi, nil, "balanceOf", common.Address{})
+	err := packCalls([]*Call{call})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return call.aggregatedCallDataSize() + call.expectedResponseSize()
+}
+
+// Waits for a scheduler's running batches to free their memory
+func waitForRelease(t *testing.T, scheduler *Scheduler) {
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		scheduler.lock.Lock()
+		runningBytes := scheduler.runningBytes
+		scheduler.lock.Unlock()
+		if runningBytes == 0 {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("expected the running batches to be released, but %d bytes are still in use", runningBytes)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestSchedulerMemoryLimitFlushes(t *testing.T) {
+	mc, client := newTestMultiCaller(t)
+	scheduler := NewScheduler(mc, 0, time.Hour, nil).WithMemoryLimit(2*balanceOfCallSize(t), MemoryLimitFlush)
+
+	balances := make([]*big.Int, 5)
+	futures := make([]*Future, 5)
+	for i := range futures {
+		futures[i] = scheduler.AddCall(testTokenAddress, &testTokenAbi, &balances[i], "balanceOf", common.BigToAddress(big.NewInt(int64(i))))
+	}
+
+	// The first 4 calls are flushed automatically, while the last one waits for the window
+	for i, future := range futures[:4] {
+		success, err := future.Wait()
+		if err != nil || !success || balances[i].Int64() != int64(i) {
+			t.Fatalf("call %d failed: %v, %v, %s", i, success, err, balances[i])
+		}
+	}
+	select {
+	case <-futures[4].Done():
+		t.Fatal("expected the last call to still be pending")
+	default:
+	}
+	scheduler.Flush()
+	_, err := futures[4].Wait()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sizes := client.getChunkSizes(); len(sizes) != 3 {
+		t.Fatalf("expected 3 multicalls, got %v", sizes)
+	}
+}
+
+func TestSchedulerMemoryLimitRejects(t *testing.T) {
+	mc, client := newTestMultiCaller(t)
+	unblock := make(chan struct{})
+	client.hook = func(ctx context.Context, msg ethereum.CallMsg) error {
+		<-unblock
+		return nil
+	}
+	size := balanceOfCallSize(t)
+	scheduler := NewScheduler(mc, 0, time.Hour, nil).WithMemoryLimit(2*size, MemoryLimitReject)
+
+	var balance *big.Int
+	accepted := []*Future{
+		scheduler.AddCall(testTokenAddress, &testTokenAbi, &balance, "balanceOf", common.HexToAddress("0x01")),
+		scheduler.AddCall(testTokenAddress, &testTokenAbi, &balance, "balanceOf", common.HexToAddress("0x02")),
+	}
+	rejected := scheduler.AddCall(testTokenAddress, &testTokenAbi, &balance, "balanceOf", common.HexToAddress("0x03"))
+	_, err := rejected.Wait()
+	var limitErr *ErrMemoryLimitExceeded
+	if !errors.As(err, &limitErr) || limitErr.Limit != 2*size || limitErr.Used != 2*size || limitErr.Size != size {
+		t.Fatalf("expected the call to be rejected, got %v", err)
+	}
+
+	// The pending calls were flushed when the call was rejected, so their memory is freed once their batch finishes
+	close(unblock)
+	for _, future := range accepted {
+		_, err := future.Wait()
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	waitForRelease(t, scheduler)
+
+	// A call larger than the limit is still accepted when nothing else is using memory
+	scheduler.WithMemoryLimit(1, MemoryLimitReject)
+	future := scheduler.AddCall(testTokenAddress, &testTokenAbi, &balance, "balanceOf", common.HexToAddress("0x04"))
+	scheduler.Flush()
+	success, err := future.Wait()
+	if err != nil || !success {
+		t.Fatalf("expected the oversized call to run, got %v, %v", success, err)
+	}
+}
+
+func TestSchedulerMemoryLimitBlocks(t *testing.T) {
+	mc, client := newTestMultiCaller(t)
+	unblock := make(chan struct{})
+	client.hook = func(ctx context.Context, msg ethereum.CallMsg) error {
+		<-unblock
+		return nil
+	}
+	scheduler := NewScheduler(mc, 0, time.Hour, nil).WithMemoryLimit(balanceOfCallSize(t), MemoryLimitBlock)
+
+	var first *big.Int
+	var second *big.Int
+	firstFuture := scheduler.AddCall(testTokenAddress, &testTokenAbi, &first, "balanceOf", common.HexToAddress("0x01"))
+	added := make(chan *Future)
+	go func() {
+		added <- scheduler.AddCall(testTokenAddress, &testTokenAbi, &second, "balanceOf", common.HexToAddress("0x02"))
+	}()
+
+	// The second call flushes the first one, then waits for its batch to finish
+	select {
+	case <-added:
+		t.Fatal("expected the second call to block while the first one's batch is running")
+	case <-time.After(50 * time.Millisecond):
+	}
+	close(unblock)
+	secondFuture := <-added
+	scheduler.Flush()
+	for _, future := range []*Future{firstFuture, secondFuture} {
+		success, err := future.Wait()
+		if err != nil || !success {
+			t.Fatalf("expected the calls to succeed, got %v, %v", success, err)
+		}
+	}
+	if first.Int64() != 1 || second.Int64() != 2 {
+		t.Fatalf("unexpected balances %s and %s", first, second)
+	}
+}
@@ -0,0 +1,128 @@
+package batchquery
+
+import (
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// Session binds a set of call options to a MultiCaller, so a logical unit of work can run several batches without threading the options through every helper.
+// All of the batches within a session run against the same block, so together they form a consistent snapshot of the chain.
+type Session struct {
+	// The MultiCaller used to run the session's batches
+	caller *MultiCaller
+
+	// The call options every batch runs with
+	opts bind.CallOpts
+
+	// The hash of the block the session is pinned to, if it was pinned by hash rather than by number
+	blockHash *common.Hash
+
+	// The gas limit for each chunk of the session's batches (0 = use the MultiCaller's)
+	gasLimit uint64
+}
+
+// Creates a new Session that runs batches using the settings and client of the provided MultiCaller.
+// If opts doesn't specify a block number (and isn't for the pending block), the session is pinned to the latest block at the time of creation.
+// If opts was created by CallOptsAtHash, the session is pinned to that block like one created by NewSessionAtHash.
+// The MultiCaller's own list of pending calls is not used, and it can still be used separately.
+func NewSession(caller *MultiCaller, opts *bind.CallOpts) (*Session, error) {
+	session := &Session{
+		caller: caller.withCalls([]*Call{}),
+	}
+	if opts != nil && opts.Pending {
+		session.opts = *opts
+		return session, nil
+	}
+
+	// Pin the session to the latest block if it doesn't have one
+	pinned, err := pinCallOpts(caller, opts)
+	if err != nil {
+		return nil, err
+	}
+	session.opts = *pinned
+	session.blockHash = newCallOptions(pinned).blockHash
+	return session, nil
+}
+
+// Creates a new Session like NewSession, but pinned to the block with the provided hash (EIP-1898) rather than a block number,
+// so every batch in the session runs against exactly that block even if a shallow reorg replaces it.
+// The client must implement IContractCallerAtHash. The block number in opts, if provided, is ignored.
+func NewSessionAtHash(caller *MultiCaller, blockHash common.Hash, opts *bind.CallOpts) (*Session, error) {
+	session := &Session{
+		caller:    caller.withCalls([]*Call{}),
+		blockHash: &blockHash,
+	}
+	session.opts = *CallOptsAtHash(opts, blockHash)
+	return session, nil
+}
+
+// Adds a contract call to the batch of calls to query during the next run of the session.
+// The returned call can be used to provide additional hints about it, such as its expected return size.
+func (s *Session) AddCall(contractAddress common.Address, abi *abi.ABI, output any, method string, args ...any) *Call {
+	return s.caller.AddCall(contractAddress, abi, output, method, args...)
+}
+
+// Adds a contract call to the session's batch like AddCall, but identifies the method by its full canonical signature so overloaded methods can be targeted
+func (s *Session) AddCallBySignature(contractAddress common.Address, abi *abi.ABI, output any, signature string, args ...any) *Call {
+	return s.caller.AddCallBySignature(contractAddress, abi, output, signature, args...)
+}
+
+// Sets the gas limit for each chunk of the session's batches, overriding the MultiCaller's GasLimit (0 = use the MultiCaller's).
+// Chunks are split so the gas estimates of their calls, plus the multicall overhead, stay within it.
+func (s *Session) WithGasLimit(gasLimit uint64) *Session {
+	s.gasLimit = gasLimit
+	return s
+}
+
+// Invokes all of the session's pending calls using its call options, with the same semantics as MultiCaller.FlexibleCall()
+func (s *Session) Execute(requireSuccess bool) ([]bool, error) {
+	return s.caller.flexibleCall(requireSuccess, s.callOptions())
+}
+
+// Invokes all of the session's pending calls using its call options, with the same semantics as MultiCaller.FlexibleCallByGroup()
+func (s *Session) ExecuteByGroup(requireSuccess bool) (map[string]*GroupResult, error) {
+	return s.caller.flexibleCallByGroup(requireSuccess, s.callOptions())
+}
+
+// Invokes all of the session's pending calls using its call options, with the same semantics as MultiCaller.StreamCall()
+func (s *Session) Stream(requireSuccess bool, handler func(StreamResult)) error {
+	return s.caller.streamCall(requireSuccess, s.callOptions(), handler)
+}
+
+// Gets the hash of the block the session is pinned to, if it was created with NewSessionAtHash
+func (s *Session) BlockHash() (common.Hash, bool) {
+	if s.blockHash == nil {
+		return common.Hash{}, false
+	}
+	return *s.blockHash, true
+}
+
+// Gets the block number the session is pinned to, or nil if it runs against the pending block or was created with NewSessionAtHash
+func (s *Session) BlockNumber() *big.Int {
+	if s.opts.BlockNumber == nil {
+		return nil
+	}
+	return new(big.Int).Set(s.opts.BlockNumber)
+}
+
+// Gets a copy of the session's call options, which can be passed to other bindings to query the same block.
+// For sessions pinned by hash, the options carry the hash like those created by CallOptsAtHash; the batchers in this package honor it,
+// but other bindings don't know about it and will target the options' block number (or the latest block, if there isn't one).
+func (s *Session) CallOpts() *bind.CallOpts {
+	opts := s.opts
+	if opts.BlockNumber != nil {
+		opts.BlockNumber = new(big.Int).Set(opts.BlockNumber)
+	}
+	return &opts
+}
+
+// Gets the options to run the session's batches with
+func (s *Session) callOptions() *callOptions {
+	options := s.caller.newCallOptions(&s.opts)
+	options.blockHash = s.blockHash
+	options.gasLimit = s.gasLimit
+	return options
+}
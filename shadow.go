@@ -0,0 +1,59 @@
+package batchquery
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// Re-runs every call individually at the batch's block and passes the differences between the decoded multicall and direct results to the ShadowHandler.
+// Each call's output is unpacked from the direct response and then from the multicall response, so the outputs hold the multicall results afterwards.
+func (mc *MultiCaller) shadowValidate(calls []*Call, responses []CallResponse, opts *callOptions) error {
+	// Calls against the pending block may legitimately differ, and calls with a sender already ran individually
+	if opts.pending || opts.from != (common.Address{}) {
+		return nil
+	}
+
+	direct := make([]CallResponse, len(calls))
+	directOpts := *opts
+	directOpts.from = mc.contractAddress
+	err := mc.Executor.run(opts.ctx, mc.ThreadLimit, "eth_call", len(calls), nil, func(ctx context.Context, i int) error {
+		call := calls[i]
+		var err error
+		direct[i], err = directCall(ctx, mc.client, call, mc.gasLimit(opts), &directOpts)
+		if err != nil {
+			return fmt.Errorf("error shadowing call %d: %w", i, err)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	multicallRecords := make([]ResultRecord, len(calls))
+	directRecords := make([]ResultRecord, len(calls))
+	for i, call := range calls {
+		directRecords[i] = newShadowRecord(i, call, direct[i], opts)
+		multicallRecords[i] = newShadowRecord(i, call, responses[i], opts)
+	}
+	mc.ShadowHandler(DiffResults(multicallRecords, directRecords))
+	return nil
+}
+
+// Unpacks a response into its call's output and creates the record of the decoded result, including the unpack failure if there was one
+func newShadowRecord(index int, call *Call, response CallResponse, opts *callOptions) ResultRecord {
+	blockNumber := opts.blockNumber
+	err := call.unpackResponse(response)
+	if err != nil {
+		return ResultRecord{
+			Index:       index,
+			Address:     call.Target,
+			Method:      call.Method,
+			BlockNumber: blockNumber,
+			Success:     response.Status,
+			Error:       err.Error(),
+		}
+	}
+	return newResultRecord(index, call, response, blockNumber)
+}
@@ -0,0 +1,49 @@
+package batchquery
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+func TestShadowValidation(t *testing.T) {
+	mc, client := newTestMultiCaller(t)
+	var diffs []*ResultDiff
+	mc.ShadowHandler = func(diff *ResultDiff) {
+		diffs = append(diffs, diff)
+	}
+	var balance *big.Int
+	var sender common.Address
+	var boom *big.Int
+	mc.AddCall(testTokenAddress, &testTokenAbi, &balance, "balanceOf", common.HexToAddress("0x05"))
+	mc.AddCall(testTokenAddress, &testTokenAbi, &sender, "whoami")
+	mc.AddCall(testTokenAddress, &testTokenAbi, &boom, "boom")
+
+	// Identical results, including calls that depend on the sender and calls that revert, produce an empty diff
+	_, err := mc.FlexibleCall(false, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(diffs) != 1 || !diffs[0].IsEmpty() {
+		t.Fatalf("expected a single empty diff, got %+v", diffs)
+	}
+
+	// A mismatch is reported with both decoded values, without failing the batch
+	client.corrupt = true
+	mc.AddCall(testTokenAddress, &testTokenAbi, &balance, "balanceOf", common.HexToAddress("0x05"))
+	_, err = mc.FlexibleCall(false, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(diffs) != 2 || len(diffs[1].Changed) != 1 {
+		t.Fatalf("expected the corrupted result to be reported, got %+v", diffs[1])
+	}
+	change := diffs[1].Changed[0]
+	if change.Before.Value != "106" || change.After.Value != "105" {
+		t.Fatalf("expected the multicall value 106 and direct value 105 at the pinned block, got %s and %s", change.Before.Value, change.After.Value)
+	}
+	if balance.Int64() != 106 {
+		t.Fatalf("expected the output to hold the multicall result, got %s", balance)
+	}
+}
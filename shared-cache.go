@@ -0,0 +1,101 @@
+package batchquery
+
+import (
+	"context"
+	"encoding/hex"
+	"time"
+)
+
+const (
+	// The default prefix for the keys a SharedResultCache stores
+	defaultSharedCachePrefix string = "batchquery:"
+
+	// The tag of the entries for the latest block
+	latestCacheTag string = "latest"
+)
+
+// A key-value cache with expiring and tagged entries, such as a Redis server shared by every instance of a horizontally scaled service.
+// Implementations must be safe to use from multiple goroutines.
+type ICache interface {
+	// Gets the value stored under a key, and whether there was one
+	Get(ctx context.Context, key string) ([]byte, bool, error)
+
+	// Stores a value under a key. The entry expires after the TTL (0 = never), and is removed when any of its tags are invalidated.
+	Set(ctx context.Context, key string, value []byte, ttl time.Duration, tags []string) error
+
+	// Removes every entry with the tag
+	Invalidate(ctx context.Context, tag string) error
+}
+
+// A result cache that stores its entries in an ICache, so several processes can share one cache of chain reads
+// instead of each of them querying the client for the same data. Entries for the latest block are tagged so a LatestCachePolicy can invalidate them.
+type SharedResultCache struct {
+	// The prefix for the keys of the entries, so other applications can use the same cache without colliding with them
+	Prefix string
+
+	// How long entries for the latest block are kept, as a safety net if an invalidation is missed (0 = until they're invalidated)
+	LatestTTL time.Duration
+
+	// How long each request to the cache may take (0 = no limit)
+	Timeout time.Duration
+
+	// The format the results are stored in
+	Codec ICacheCodec
+
+	// The underlying cache
+	cache ICache
+}
+
+// Creates a new SharedResultCache instance that stores its entries in the provided cache
+func NewSharedResultCache(cache ICache) *SharedResultCache {
+	return &SharedResultCache{
+		Prefix: defaultSharedCachePrefix,
+		Codec:  RawCacheCodec{},
+		cache:  cache,
+	}
+}
+
+// Gets the cached response for a call, and whether there was one
+func (c *SharedResultCache) Get(key CacheKey) (CallResponse, bool, error) {
+	ctx, cancel := withRequestTimeout(context.Background(), c.Timeout)
+	defer cancel()
+	value, found, err := c.cache.Get(ctx, c.entryKey(key))
+	if err != nil || !found {
+		return CallResponse{}, false, err
+	}
+	response, err := c.Codec.Decode(value)
+	if err != nil {
+		return CallResponse{}, false, err
+	}
+	return response, true, nil
+}
+
+// Stores the response for a call
+func (c *SharedResultCache) Set(key CacheKey, response CallResponse) error {
+	ctx, cancel := withRequestTimeout(context.Background(), c.Timeout)
+	defer cancel()
+	value, err := c.Codec.Encode(response)
+	if err != nil {
+		return err
+	}
+
+	var ttl time.Duration
+	var tags []string
+	if key.Latest {
+		ttl = c.LatestTTL
+		tags = []string{c.Prefix + latestCacheTag}
+	}
+	return c.cache.Set(ctx, c.entryKey(key), value, ttl, tags)
+}
+
+// Removes every entry for the latest block
+func (c *SharedResultCache) InvalidateLatest() error {
+	ctx, cancel := withRequestTimeout(context.Background(), c.Timeout)
+	defer cancel()
+	return c.cache.Invalidate(ctx, c.Prefix+latestCacheTag)
+}
+
+// Gets the key of the entry for a call
+func (c *SharedResultCache) entryKey(key CacheKey) string {
+	return c.Prefix + hex.EncodeToString(key.Bytes())
+}
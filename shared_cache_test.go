@@ -0,0 +1,116 @@
+package batchquery
+
+import (
+	"context"
+	"math/big"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// An in-memory ICache that records the TTL and tags of each entry
+type mapCache struct {
+	values map[string][]byte
+	ttls   map[string]time.Duration
+	tags   map[string][]string
+	lock   sync.Mutex
+}
+
+func newMapCache() *mapCache {
+	return &mapCache{
+		values: map[string][]byte{},
+		ttls:   map[string]time.Duration{},
+		tags:   map[string][]string{},
+	}
+}
+
+func (c *mapCache) Get(ctx context.Context, key string) ([]byte, bool, error) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	value, exists := c.values[key]
+	return value, exists, nil
+}
+
+func (c *mapCache) Set(ctx context.Context, key string, value []byte, ttl time.Duration, tags []string) error {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.values[key] = value
+	c.ttls[key] = ttl
+	for _, tag := range tags {
+		c.tags[tag] = append(c.tags[tag], key)
+	}
+	return nil
+}
+
+func (c *mapCache) Invalidate(ctx context.Context, tag string) error {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	for _, key := range c.tags[tag] {
+		delete(c.values, key)
+	}
+	delete(c.tags, tag)
+	return nil
+}
+
+func TestSharedResultCache(t *testing.T) {
+	backend := newMapCache()
+	cache := NewSharedResultCache(backend)
+	cache.LatestTTL = time.Minute
+
+	historical := testCacheKey(90)
+	latest := testCacheKey(100)
+	latest.Latest = true
+	cache.Set(historical, CallResponse{Status: true, ReturnData: []byte{1, 2}})
+	cache.Set(latest, CallResponse{Status: false, ReturnData: []byte{3}})
+
+	response, found, err := cache.Get(historical)
+	if err != nil || !found || !response.Status || len(response.ReturnData) != 2 || response.ReturnData[1] != 2 {
+		t.Fatalf("unexpected historical entry: %+v, %t, %v", response, found, err)
+	}
+	response, found, err = cache.Get(latest)
+	if err != nil || !found || response.Status || len(response.ReturnData) != 1 {
+		t.Fatalf("unexpected latest entry: %+v, %t, %v", response, found, err)
+	}
+
+	// Only the latest entry expires
+	if backend.ttls[cache.entryKey(historical)] != 0 || backend.ttls[cache.entryKey(latest)] != time.Minute {
+		t.Fatalf("unexpected TTLs: %v", backend.ttls)
+	}
+
+	err = cache.InvalidateLatest()
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, found, _ = cache.Get(latest)
+	if found {
+		t.Fatal("expected the latest entry to be invalidated")
+	}
+	_, found, _ = cache.Get(historical)
+	if !found {
+		t.Fatal("expected the historical entry to survive the invalidation")
+	}
+}
+
+func TestSharedResultCacheServesBatches(t *testing.T) {
+	mc, client := newTestMultiCaller(t)
+	mc.Cache = NewSharedResultCache(newMapCache())
+	opts := &bind.CallOpts{BlockNumber: big.NewInt(50)}
+	account := common.HexToAddress("0x0102")
+	for i := 0; i < 2; i++ {
+		var balance *big.Int
+		mc.AddCall(testTokenAddress, &testTokenAbi, &balance, "balanceOf", account)
+		_, err := mc.FlexibleCall(true, opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if balance.Cmp(expectedBalance(account, 50)) != 0 {
+			t.Fatalf("expected balance %s, got %s", expectedBalance(account, 50), balance)
+		}
+	}
+	if client.calls != 1 {
+		t.Fatalf("expected the second batch to be served from the cache, got %d eth_calls", client.calls)
+	}
+}
@@ -0,0 +1,59 @@
+package batchquery
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// Pins the options for a spot-checked batch to the latest block if they don't already target a specific one,
+// so the batch and its direct re-runs see the same state
+func (mc *MultiCaller) pinSpotCheck(opts *callOptions) error {
+	if opts.blockNumber != nil || opts.blockHash != nil || opts.pending {
+		return nil
+	}
+	blockNumber, err := mc.getLatestBlockNumber(opts.ctx)
+	if err != nil {
+		return err
+	}
+	opts.blockNumber = blockNumber
+	return nil
+}
+
+// Re-runs a random sample of the calls individually and compares their results against the batch's responses.
+// The direct calls are run from the multicall contract's address, so they see the same msg.sender as the calls within the multicall.
+// Returns an error wrapping ErrSpotCheckFailed describing the first mismatch, if there is one.
+func (mc *MultiCaller) spotCheck(calls []*Call, responses []CallResponse, opts *callOptions) error {
+	// Calls against the pending block may legitimately differ, and calls with a sender already ran individually
+	if opts.pending || opts.from != (common.Address{}) {
+		return nil
+	}
+	sample := []int{}
+	for i := range calls {
+		if mc.SpotCheckRate >= 1 || rand.Float64() < mc.SpotCheckRate {
+			sample = append(sample, i)
+		}
+	}
+	if len(sample) == 0 {
+		return nil
+	}
+
+	directOpts := *opts
+	directOpts.from = mc.contractAddress
+	return mc.Executor.run(opts.ctx, mc.ThreadLimit, "eth_call", len(sample), nil, func(ctx context.Context, i int) error {
+		index := sample[i]
+		call := calls[index]
+		direct, err := directCall(ctx, mc.client, call, mc.gasLimit(opts), &directOpts)
+		if err != nil {
+			return fmt.Errorf("error spot checking call %d: %w", index, err)
+		}
+		response := responses[index]
+		if !responsesMatch(direct, response) {
+			return fmt.Errorf("call %d [%s] on contract %s returned %v %x through the multicall contract but %v %x directly at block %s: %w",
+				index, call.Method, call.Target.Hex(), response.Status, response.ReturnData, direct.Status, direct.ReturnData, opts.blockDescription(), ErrSpotCheckFailed)
+		}
+		return nil
+	})
+}
@@ -0,0 +1,55 @@
+package batchquery
+
+import (
+	"errors"
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+func TestSpotCheck(t *testing.T) {
+	mc, client := newTestMultiCaller(t)
+	mc.SpotCheckRate = 1
+	balances := make([]*big.Int, 3)
+	var sender common.Address
+	var boom *big.Int
+	for i := range balances {
+		mc.AddCall(testTokenAddress, &testTokenAbi, &balances[i], "balanceOf", common.BigToAddress(big.NewInt(int64(i))))
+	}
+	mc.AddCall(testTokenAddress, &testTokenAbi, &sender, "whoami")
+	mc.AddCall(testTokenAddress, &testTokenAbi, &boom, "boom")
+
+	// Honest results pass, including calls that depend on the sender and calls that revert
+	_, err := mc.FlexibleCall(false, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The batch plus a direct call for each of the 5 calls, plus pinning the block
+	if client.calls != 7 {
+		t.Fatalf("expected every call to be checked, got %d eth_calls", client.calls)
+	}
+
+	client.corrupt = true
+	mc.AddCall(testTokenAddress, &testTokenAbi, &balances[0], "balanceOf", common.HexToAddress("0x05"))
+	_, err = mc.FlexibleCall(false, nil)
+	if !errors.Is(err, ErrSpotCheckFailed) {
+		t.Fatalf("expected the corrupted result to be caught, got %v", err)
+	}
+}
+
+func TestSpotCheckOnStream(t *testing.T) {
+	mc, client := newTestMultiCaller(t)
+	mc.SpotCheckRate = 1
+	client.corrupt = true
+	var balance *big.Int
+	mc.AddCall(testTokenAddress, &testTokenAbi, &balance, "balanceOf", common.HexToAddress("0x05"))
+	delivered := 0
+	err := mc.StreamCall(false, nil, func(StreamResult) {
+		delivered++
+	})
+	if !errors.Is(err, ErrSpotCheckFailed) || delivered != 0 {
+		t.Fatalf("expected the stream to fail before delivering results, got %v after %d results", err, delivered)
+	}
+}
@@ -0,0 +1,140 @@
+package batchquery
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+	"time"
+
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+// This struct can read many raw storage slots concurrently using eth_getStorageAt.
+// It's useful for reading values that don't have a public getter, with the slots computed by the storage slot helpers.
+// Use a ProofBatcher instead if the values need to be verified against a trusted state root.
+type StorageBatcher struct {
+	// The number of slots to read simultaneously, or the number of batch requests to send simultaneously if the client implements IBatchCaller
+	ThreadLimit int
+
+	// The number of slots to read in a single JSON-RPC batch request, if the client implements IBatchCaller (0 = 100)
+	RpcBatchSize int
+
+	// The context that reads run in when their options don't provide one (nil = context.Background())
+	BaseContext context.Context
+
+	// The deadline for each eth_getStorageAt or batch request, so every request has one even when the caller's context doesn't (0 = no deadline beyond the one in the context)
+	DefaultTimeout time.Duration
+
+	// The executor that runs the batcher's requests (nil = no shared executor; see Executor)
+	Executor *Executor
+
+	// The Execution client binding
+	client IStorageReader
+}
+
+// Creates a new StorageBatcher instance
+func NewStorageBatcher(client IStorageReader, threadLimit int) *StorageBatcher {
+	return &StorageBatcher{
+		client:      client,
+		ThreadLimit: threadLimit,
+	}
+}
+
+// Retrieves the raw values of a list of storage slots. The order of the resulting array corresponds to the order of the provided slots.
+// If opts sets Pending, the slots are read from the pending block; the client must implement IPendingStorageReader.
+// If the client implements IBatchCaller, the slots are read with JSON-RPC batch requests instead of one request each.
+func (b *StorageBatcher) GetStorage(slots []StorageSlot, opts *bind.CallOpts) ([]common.Hash, error) {
+	options := newCallOptionsWithBase(opts, b.BaseContext)
+	if options.from != (common.Address{}) {
+		return nil, fmt.Errorf("storage reads don't have a sender, so opts can't specify a From address")
+	}
+	if batchCaller, ok := b.client.(IBatchCaller); ok {
+		return b.getStorageBatched(batchCaller, slots, options)
+	}
+	values := make([]common.Hash, len(slots))
+
+	// A failure in any read cancels the rest of them
+	err := b.Executor.run(options.ctx, b.ThreadLimit, "eth_getStorageAt", len(slots), nil, func(ctx context.Context, i int) error {
+		slot := slots[i]
+		readCtx, cancel := withRequestTimeout(ctx, b.DefaultTimeout)
+		defer cancel()
+		value, err := options.storageAt(readCtx, b.client, slot)
+		if err != nil {
+			return fmt.Errorf("error reading slot %s of contract %s: %w", slot.Slot.Hex(), slot.Address.Hex(), wrapClientError(err))
+		}
+		if len(value) > common.HashLength {
+			return fmt.Errorf("received %d bytes for slot %s of contract %s which is larger than a storage word", len(value), slot.Slot.Hex(), slot.Address.Hex())
+		}
+		values[i] = common.BytesToHash(value)
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error getting storage: %w", err)
+	}
+	return values, nil
+}
+
+// Reads the storage slots with JSON-RPC batch requests
+func (b *StorageBatcher) getStorageBatched(caller IBatchCaller, slots []StorageSlot, options *callOptions) ([]common.Hash, error) {
+	results := make([]hexutil.Bytes, len(slots))
+	elems := make([]rpc.BatchElem, len(slots))
+	for i, slot := range slots {
+		elems[i] = rpc.BatchElem{
+			Method: "eth_getStorageAt",
+			Args:   []any{slot.Address, slot.Slot, options.blockArg()},
+			Result: &results[i],
+		}
+	}
+	err := runRpcBatches(options.ctx, b.Executor, caller, "eth_getStorageAt", elems, b.RpcBatchSize, b.ThreadLimit, b.DefaultTimeout)
+	if err != nil {
+		return nil, fmt.Errorf("error getting storage: %w", err)
+	}
+
+	values := make([]common.Hash, len(slots))
+	for i, slot := range slots {
+		if elems[i].Error != nil {
+			return nil, fmt.Errorf("error getting storage: error reading slot %s of contract %s: %w", slot.Slot.Hex(), slot.Address.Hex(), wrapClientError(elems[i].Error))
+		}
+		if len(results[i]) > common.HashLength {
+			return nil, fmt.Errorf("error getting storage: received %d bytes for slot %s of contract %s which is larger than a storage word", len(results[i]), slot.Slot.Hex(), slot.Address.Hex())
+		}
+		values[i] = common.BytesToHash(results[i])
+	}
+	return values, nil
+}
+
+// Retrieves the values of a list of packed storage values from the provided contract, reading each distinct slot only once.
+// The order of the resulting array corresponds to the order of the provided values.
+func (b *StorageBatcher) GetPackedValues(address common.Address, values []PackedValue, opts *bind.CallOpts) ([]*big.Int, error) {
+	// Deduplicate the slots, since packed values often share them
+	indices := map[common.Hash]int{}
+	slots := []StorageSlot{}
+	for i, value := range values {
+		err := value.validate()
+		if err != nil {
+			return nil, fmt.Errorf("error reading packed value %d: %w", i, err)
+		}
+		_, exists := indices[value.Slot]
+		if !exists {
+			indices[value.Slot] = len(slots)
+			slots = append(slots, StorageSlot{Address: address, Slot: value.Slot})
+		}
+	}
+
+	words, err := b.GetStorage(slots, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	results := make([]*big.Int, len(values))
+	for i, value := range values {
+		results[i], err = value.Extract(words[indices[value.Slot]])
+		if err != nil {
+			return nil, fmt.Errorf("error reading packed value %d: %w", i, err)
+		}
+	}
+	return results, nil
+}
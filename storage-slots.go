@@ -0,0 +1,175 @@
+package batchquery
+
+import (
+	"fmt"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+// A storage slot of a specific contract
+type StorageSlot struct {
+	// The address of the contract
+	Address common.Address
+
+	// The slot within the contract's storage
+	Slot common.Hash
+}
+
+// Creates a storage slot from a slot index, such as the position of a state variable in a contract's layout
+func SlotIndex(index uint64) common.Hash {
+	return common.BigToHash(new(big.Int).SetUint64(index))
+}
+
+// Gets the slot that's the provided number of slots after the base slot, such as a later member of a struct
+func SlotOffset(base common.Hash, offset uint64) common.Hash {
+	slot := new(big.Int).SetBytes(base.Bytes())
+	slot.Add(slot, new(big.Int).SetUint64(offset))
+	return common.BigToHash(slot)
+}
+
+// Gets the slot of a mapping's value for the provided key, following Solidity's layout: keccak256(key . baseSlot).
+// The key must already be padded to 32 bytes; use AddressKey, UintKey, or BoolKey for value types.
+func MappingSlot(base common.Hash, key common.Hash) common.Hash {
+	return crypto.Keccak256Hash(key.Bytes(), base.Bytes())
+}
+
+// Gets the slot of a mapping's value for a dynamic key such as a string or bytes, which is hashed unpadded: keccak256(key . baseSlot)
+func MappingSlotForBytes(base common.Hash, key []byte) common.Hash {
+	return crypto.Keccak256Hash(key, base.Bytes())
+}
+
+// Gets the slot of a nested mapping's value, such as an ERC-20 allowance, by applying MappingSlot for each key in order
+func NestedMappingSlot(base common.Hash, keys ...common.Hash) common.Hash {
+	slot := base
+	for _, key := range keys {
+		slot = MappingSlot(slot, key)
+	}
+	return slot
+}
+
+// Gets the slot of an element in a dynamic array, following Solidity's layout: keccak256(baseSlot) + index * elementSlots.
+// elementSlots is the number of slots each element occupies (1 for value types, or the size of a struct).
+// Elements of 16 bytes or less share slots with their neighbours; use PackedArrayElement for those.
+// The array's length is stored in the base slot itself.
+func ArrayElementSlot(base common.Hash, index uint64, elementSlots uint64) common.Hash {
+	start := new(big.Int).SetBytes(crypto.Keccak256(base.Bytes()))
+	offset := new(big.Int).SetUint64(index)
+	offset.Mul(offset, new(big.Int).SetUint64(elementSlots))
+	return common.BigToHash(start.Add(start, offset))
+}
+
+// Gets the location of an element in a dynamic array of small value types (such as uint64[] or bool[]),
+// which Solidity packs into as few slots as possible. elementSize is the size of each element in bytes, between 1 and 32.
+func PackedArrayElement(base common.Hash, index uint64, elementSize int) (PackedValue, error) {
+	if elementSize <= 0 || elementSize > wordSize {
+		return PackedValue{}, fmt.Errorf("element size %d is invalid; it must be between 1 and %d bytes", elementSize, wordSize)
+	}
+	perSlot := uint64(wordSize / elementSize)
+	return PackedValue{
+		Slot:   ArrayElementSlot(base, index/perSlot, 1),
+		Offset: int(index%perSlot) * elementSize,
+		Size:   elementSize,
+	}, nil
+}
+
+// Gets the base slot of an ERC-7201 namespaced storage layout: keccak256(keccak256(namespace) - 1) & ~0xff
+func ERC7201Slot(namespace string) common.Hash {
+	id := new(big.Int).SetBytes(crypto.Keccak256([]byte(namespace)))
+	id.Sub(id, big.NewInt(1))
+	slot := crypto.Keccak256Hash(common.BigToHash(id).Bytes())
+	slot[len(slot)-1] = 0
+	return slot
+}
+
+// Pads an address to a 32-byte mapping key
+func AddressKey(address common.Address) common.Hash {
+	return common.BytesToHash(address.Bytes())
+}
+
+// Pads an unsigned integer to a 32-byte mapping key
+func UintKey(value *big.Int) common.Hash {
+	return common.BigToHash(value)
+}
+
+// Pads a boolean to a 32-byte mapping key
+func BoolKey(value bool) common.Hash {
+	if value {
+		return common.BigToHash(big.NewInt(1))
+	}
+	return common.Hash{}
+}
+
+// A value that shares a storage slot with other values, as Solidity does with consecutive members smaller than 32 bytes
+type PackedValue struct {
+	// The slot holding the value
+	Slot common.Hash
+
+	// The offset of the value within the slot in bytes, counting from the lowest-order (rightmost) byte
+	Offset int
+
+	// The size of the value in bytes
+	Size int
+}
+
+// Checks that the value fits within a single slot
+func (v PackedValue) validate() error {
+	if v.Size <= 0 || v.Size > wordSize {
+		return fmt.Errorf("packed value size %d is invalid; it must be between 1 and %d bytes", v.Size, wordSize)
+	}
+	if v.Offset < 0 || v.Offset+v.Size > wordSize {
+		return fmt.Errorf("packed value with offset %d and size %d doesn't fit within a slot", v.Offset, v.Size)
+	}
+	return nil
+}
+
+// Extracts the value from the raw contents of its slot
+func (v PackedValue) Extract(word common.Hash) (*big.Int, error) {
+	err := v.validate()
+	if err != nil {
+		return nil, err
+	}
+	end := wordSize - v.Offset
+	return new(big.Int).SetBytes(word[end-v.Size : end]), nil
+}
+
+// Extracts the value from the raw contents of its slot as an address, for values with a size of 20 bytes
+func (v PackedValue) ExtractAddress(word common.Hash) (common.Address, error) {
+	if v.Size != common.AddressLength {
+		return common.Address{}, fmt.Errorf("packed value size %d is invalid for an address, which is %d bytes", v.Size, common.AddressLength)
+	}
+	value, err := v.Extract(word)
+	if err != nil {
+		return common.Address{}, err
+	}
+	return common.BigToAddress(value), nil
+}
+
+// Extracts the value from the raw contents of its slot as a boolean, for values with a size of 1 byte
+func (v PackedValue) ExtractBool(word common.Hash) (bool, error) {
+	if v.Size != 1 {
+		return false, fmt.Errorf("packed value size %d is invalid for a boolean, which is 1 byte", v.Size)
+	}
+	value, err := v.Extract(word)
+	if err != nil {
+		return false, err
+	}
+	return value.Sign() != 0, nil
+}
+
+// Groups storage slots by contract into requests for the ProofBatcher, preserving the order of the slots within each contract
+func NewProofRequests(slots []StorageSlot) []ProofRequest {
+	indices := map[common.Address]int{}
+	requests := []ProofRequest{}
+	for _, slot := range slots {
+		index, exists := indices[slot.Address]
+		if !exists {
+			index = len(requests)
+			indices[slot.Address] = index
+			requests = append(requests, ProofRequest{Address: slot.Address})
+		}
+		requests[index].Slots = append(requests[index].Slots, slot.Slot)
+	}
+	return requests
+}
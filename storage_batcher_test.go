@@ -0,0 +1,126 @@
+package batchquery
+
+import (
+	"context"
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// A storage reader where every slot holds its own index, plus one in the pending block
+type mockStorageReader struct{}
+
+func (m *mockStorageReader) StorageAt(ctx context.Context, account common.Address, key common.Hash, blockNumber *big.Int) ([]byte, error) {
+	return key.Bytes(), nil
+}
+
+func (m *mockStorageReader) PendingStorageAt(ctx context.Context, account common.Address, key common.Hash) ([]byte, error) {
+	value := new(big.Int).Add(key.Big(), common.Big1)
+	return common.BigToHash(value).Bytes(), nil
+}
+
+func TestGetStorageFromPendingBlock(t *testing.T) {
+	batcher := NewStorageBatcher(&mockStorageReader{}, 0)
+	slots := []StorageSlot{
+		{Address: testTokenAddress, Slot: common.BigToHash(big.NewInt(1))},
+		{Address: testTokenAddress, Slot: common.BigToHash(big.NewInt(2))},
+	}
+
+	values, err := batcher.GetStorage(slots, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if values[0].Big().Int64() != 1 || values[1].Big().Int64() != 2 {
+		t.Fatalf("expected the latest values, got %v", values)
+	}
+
+	values, err = batcher.GetStorage(slots, &bind.CallOpts{Pending: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if values[0].Big().Int64() != 2 || values[1].Big().Int64() != 3 {
+		t.Fatalf("expected the pending values, got %v", values)
+	}
+
+	_, err = batcher.GetStorage(slots, &bind.CallOpts{From: common.HexToAddress("0x01")})
+	if err == nil {
+		t.Fatal("expected storage reads with a sender to be rejected")
+	}
+}
+
+func TestPackedArrayElement(t *testing.T) {
+	base := common.BigToHash(big.NewInt(3))
+
+	// uint64[] packs four elements into each slot
+	value, err := PackedArrayElement(base, 5, 8)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if value.Slot != ArrayElementSlot(base, 1, 1) || value.Offset != 8 || value.Size != 8 {
+		t.Fatalf("unexpected location %+v", value)
+	}
+
+	for _, size := range []int{0, -1, 33} {
+		_, err = PackedArrayElement(base, 0, size)
+		if err == nil {
+			t.Fatalf("expected element size %d to be rejected", size)
+		}
+	}
+}
+
+func TestPackedValueExtract(t *testing.T) {
+	// An address in the low 20 bytes, followed by a bool
+	var word common.Hash
+	address := common.HexToAddress("0x00000000000000000000000000000000000000aa")
+	copy(word[12:], address.Bytes())
+	word[11] = 1
+
+	extracted, err := PackedValue{Offset: 0, Size: 20}.ExtractAddress(word)
+	if err != nil || extracted != address {
+		t.Fatalf("expected %s, got %s (%v)", address.Hex(), extracted.Hex(), err)
+	}
+	flag, err := PackedValue{Offset: 20, Size: 1}.ExtractBool(word)
+	if err != nil || !flag {
+		t.Fatalf("expected the flag to be set, got %v (%v)", flag, err)
+	}
+
+	invalid := []PackedValue{
+		{Offset: 0, Size: 0},
+		{Offset: -1, Size: 1},
+		{Offset: 31, Size: 2},
+		{Offset: 0, Size: 33},
+	}
+	for _, value := range invalid {
+		_, err = value.Extract(word)
+		if err == nil {
+			t.Fatalf("expected %+v to be rejected", value)
+		}
+	}
+	_, err = PackedValue{Offset: 0, Size: 1}.ExtractAddress(word)
+	if err == nil {
+		t.Fatal("expected an address with the wrong size to be rejected")
+	}
+	_, err = PackedValue{Offset: 0, Size: 2}.ExtractBool(word)
+	if err == nil {
+		t.Fatal("expected a bool with the wrong size to be rejected")
+	}
+}
+
+func TestGetPackedValuesRejectsInvalidValues(t *testing.T) {
+	batcher := NewStorageBatcher(&mockStorageReader{}, 0)
+	slot := common.BigToHash(big.NewInt(0x0102))
+	values, err := batcher.GetPackedValues(testTokenAddress, []PackedValue{{Slot: slot, Offset: 0, Size: 1}, {Slot: slot, Offset: 1, Size: 1}}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if values[0].Int64() != 2 || values[1].Int64() != 1 {
+		t.Fatalf("expected 2 and 1, got %s and %s", values[0], values[1])
+	}
+
+	_, err = batcher.GetPackedValues(testTokenAddress, []PackedValue{{Slot: slot, Offset: 30, Size: 4}}, nil)
+	if err == nil {
+		t.Fatal("expected a value that doesn't fit in its slot to be rejected")
+	}
+}
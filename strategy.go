@@ -0,0 +1,47 @@
+package batchquery
+
+import (
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// The way a MultiCaller runs the calls of a batch
+type ExecutionStrategy int
+
+const (
+	// Aggregate the calls through the multicall contract, splitting them into chunks that respect the MultiCaller's limits
+	StrategyMulticall ExecutionStrategy = iota
+
+	// Send each call as its own eth_call within JSON-RPC batch requests instead of aggregating them through the multicall contract.
+	// This works on chains without a multicall contract and isn't subject to the gas cap of a single eth_call; the client must implement IBatchCaller.
+	// Each batch request holds up to CallBatchSize calls (0 = 100) and is subject to ChunkTimeout; the other chunk limits don't apply.
+	StrategyRpcBatch
+
+	// Pick one of the other strategies for each batch, based on the client's capabilities and on how well the batch's calls aggregate.
+	// JSON-RPC batching is used if the client supports it and either the batch has a sender (which multicall can't honor without running every call separately),
+	// or the calls are too large to share chunks, so aggregating them would only add the multicall contract's overhead.
+	// Otherwise, the calls are aggregated through the multicall contract.
+	StrategyAuto
+)
+
+// The minimum average number of calls per chunk for StrategyAuto to aggregate a batch through the multicall contract
+const autoMinCallsPerChunk int = 2
+
+// Gets the strategy to run the calls with, resolving StrategyAuto for the batch
+func (mc *MultiCaller) selectStrategy(calls []*Call, opts *callOptions) ExecutionStrategy {
+	if mc.Strategy != StrategyAuto {
+		return mc.Strategy
+	}
+	if _, ok := mc.client.(IBatchCaller); !ok {
+		return StrategyMulticall
+	}
+	if opts.from != (common.Address{}) {
+		return StrategyRpcBatch
+	}
+
+	// Calls that don't fit together in a chunk gain nothing from being aggregated
+	chunks, _ := mc.chunkCalls(calls, mc.gasLimit(opts))
+	if len(calls) < autoMinCallsPerChunk*len(chunks) {
+		return StrategyRpcBatch
+	}
+	return StrategyMulticall
+}
@@ -0,0 +1,84 @@
+package batchquery
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// Creates a MultiCaller with the auto strategy, backed by a mock client that supports JSON-RPC batches
+func newAutoMultiCaller(t *testing.T) (*MultiCaller, *mockRpcBatchClient) {
+	client := &mockRpcBatchClient{mockClient: &mockClient{}}
+	mc, err := NewMultiCaller(client, testMulticallAddress)
+	if err != nil {
+		t.Fatal(err)
+	}
+	mc.Strategy = StrategyAuto
+	return mc, client
+}
+
+func TestAutoStrategyAggregatesSmallCalls(t *testing.T) {
+	mc, client := newAutoMultiCaller(t)
+	balances := make([]*big.Int, 10)
+	for i := range balances {
+		mc.AddCall(testTokenAddress, &testTokenAbi, &balances[i], "balanceOf", common.BigToAddress(big.NewInt(int64(i))))
+	}
+	_, err := mc.FlexibleCall(true, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if client.batches != 0 || len(client.getChunkSizes()) != 1 {
+		t.Fatalf("expected a single multicall, got %d batch requests and chunks %v", client.batches, client.getChunkSizes())
+	}
+}
+
+func TestAutoStrategyBatchesLargeCalls(t *testing.T) {
+	mc, client := newAutoMultiCaller(t)
+	mc.ReturnSizeLimit = 1024
+	lists := make([][]*big.Int, 3)
+	for i := range lists {
+		mc.AddCall(testTokenAddress, &testTokenAbi, &lists[i], "list", big.NewInt(2)).WithReturnSize(1024)
+	}
+	_, err := mc.FlexibleCall(true, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if client.batches != 1 || len(client.getChunkSizes()) != 0 {
+		t.Fatalf("expected a single batch request, got %d batch requests and chunks %v", client.batches, client.getChunkSizes())
+	}
+	if len(lists[2]) != 2 {
+		t.Fatalf("expected the lists to be unpacked, got %v", lists[2])
+	}
+}
+
+func TestAutoStrategyBatchesCallsWithSender(t *testing.T) {
+	mc, client := newAutoMultiCaller(t)
+	from := common.HexToAddress("0x3333333333333333333333333333333333333333")
+	senders := make([]common.Address, 3)
+	for i := range senders {
+		mc.AddCall(testTokenAddress, &testTokenAbi, &senders[i], "whoami")
+	}
+	_, err := mc.FlexibleCall(true, &bind.CallOpts{From: from})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if client.batches != 1 || senders[2] != from {
+		t.Fatalf("expected the calls to run from %s in a single batch request, got %d batch requests and sender %s", from.Hex(), client.batches, senders[2].Hex())
+	}
+}
+
+func TestAutoStrategyWithoutBatchSupport(t *testing.T) {
+	mc, client := newTestMultiCaller(t)
+	mc.Strategy = StrategyAuto
+	var balance *big.Int
+	mc.AddCall(testTokenAddress, &testTokenAbi, &balance, "balanceOf", common.HexToAddress("0x05"))
+	_, err := mc.FlexibleCall(true, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(client.getChunkSizes()) != 1 {
+		t.Fatalf("expected the call to be aggregated, got chunks %v", client.getChunkSizes())
+	}
+}
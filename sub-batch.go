@@ -0,0 +1,126 @@
+package batchquery
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// SubBatch is a child of a MultiCaller whose calls are run as part of the parent's next batch, but whose results are settled on their own.
+// Library code can add its calls to a sub-batch and wait for them without knowing who runs the top-level batch or what else is in it.
+// A sub-batch settles once with the parent's next run; create a new one for each run.
+type SubBatch struct {
+	// The MultiCaller whose batch the calls are added to
+	parent *MultiCaller
+
+	// The calls added through the sub-batch
+	calls []*Call
+
+	// Closed once the sub-batch has been settled
+	done chan struct{}
+
+	// The success flag of each call
+	successes []bool
+
+	// The error from running the parent's batch or unpacking the sub-batch's responses, if there was one
+	err error
+}
+
+// Creates a new sub-batch whose calls are run with the MultiCaller's next batch
+func (mc *MultiCaller) NewSubBatch() *SubBatch {
+	sub := &SubBatch{
+		parent: mc,
+		calls:  []*Call{},
+		done:   make(chan struct{}),
+	}
+	mc.subBatches = append(mc.subBatches, sub)
+	return sub
+}
+
+// Adds a contract call to the parent's batch, tracking its result as part of the sub-batch.
+// The returned call can be used to provide additional hints about it, such as its expected return size.
+func (s *SubBatch) AddCall(contractAddress common.Address, abi *abi.ABI, output any, method string, args ...any) *Call {
+	call := s.parent.AddCall(contractAddress, abi, output, method, args...)
+	s.calls = append(s.calls, call)
+	return call
+}
+
+// Blocks until the parent's batch has been run, returning the success flag of each of the sub-batch's calls in the order they were added.
+// If any of the sub-batch's responses couldn't be unpacked, a *MultiError indexed by the sub-batch's own calls is returned with the flags.
+// If the parent's batch failed, its error is returned instead.
+func (s *SubBatch) Wait() ([]bool, error) {
+	<-s.done
+	return s.successes, s.err
+}
+
+// Returns a channel that's closed once the parent's batch has been run
+func (s *SubBatch) Done() <-chan struct{} {
+	return s.done
+}
+
+// Settles the sub-batch with the results of the parent's run, where unpackErrs holds the unpack failure of each call (if there was one)
+func (s *SubBatch) settle(indices map[*Call]int, responses []CallResponse, unpackErrs []error, err error) {
+	defer close(s.done)
+	if err != nil {
+		s.err = err
+		return
+	}
+
+	s.successes = make([]bool, len(s.calls))
+	var multiErr *MultiError
+	for i, call := range s.calls {
+		index, exists := indices[call]
+		if !exists {
+			s.err = fmt.Errorf("call %d of the sub-batch (contract %s, method %s) wasn't part of the parent's batch", i, call.Target.Hex(), call.Method)
+			return
+		}
+		s.successes[i] = responses[index].Status
+		if unpackErrs != nil && unpackErrs[index] != nil {
+			if multiErr == nil {
+				multiErr = &MultiError{}
+			}
+			multiErr.Errors = append(multiErr.Errors, &CallError{
+				Index:  i,
+				Target: call.Target,
+				Method: call.Method,
+				Err:    unpackErrs[index],
+			})
+		}
+	}
+	if multiErr != nil {
+		s.err = multiErr
+	}
+}
+
+// Settles all of the sub-batches waiting on the MultiCaller's run of the provided calls.
+// If the run failed, err is its error; otherwise responses and unpackErrs (which may be nil) correspond to the calls.
+func (mc *MultiCaller) settleSubBatches(calls []*Call, responses []CallResponse, unpackErrs []error, err error) {
+	if len(mc.subBatches) == 0 {
+		return
+	}
+	subBatches := mc.subBatches
+	mc.subBatches = nil
+
+	indices := make(map[*Call]int, len(calls))
+	for i, call := range calls {
+		indices[call] = i
+	}
+	for _, sub := range subBatches {
+		sub.settle(indices, responses, unpackErrs, err)
+	}
+}
+
+// Gets the unpack failure of each call from the error returned by unpackResponses (nil = no failures)
+func getUnpackErrors(count int, err error) []error {
+	var multiErr *MultiError
+	if !errors.As(err, &multiErr) {
+		return nil
+	}
+	unpackErrs := make([]error, count)
+	for _, callErr := range multiErr.Errors {
+		unpackErrs[callErr.Index] = callErr.Err
+	}
+	return unpackErrs
+}
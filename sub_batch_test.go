@@ -0,0 +1,69 @@
+package batchquery
+
+import (
+	"errors"
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+func TestSubBatchSettlesWithParent(t *testing.T) {
+	mc, client := newTestMultiCaller(t)
+	var parentBalance, childBalance *big.Int
+	var wrongType bool
+	mc.AddCall(testTokenAddress, &testTokenAbi, &parentBalance, "balanceOf", common.HexToAddress("0x01"))
+	sub := mc.NewSubBatch()
+	sub.AddCall(testTokenAddress, &testTokenAbi, &childBalance, "balanceOf", common.HexToAddress("0x02"))
+	sub.AddCall(testTokenAddress, &testTokenAbi, &wrongType, "balanceOf", common.HexToAddress("0x03"))
+	other := mc.NewSubBatch()
+
+	select {
+	case <-sub.Done():
+		t.Fatal("expected the sub-batch to wait for the parent's run")
+	default:
+	}
+
+	// The parent still reports the unpack failure, since it's part of its batch
+	_, err := mc.FlexibleCall(false, nil)
+	var multiErr *MultiError
+	if !errors.As(err, &multiErr) {
+		t.Fatalf("expected a MultiError from the parent, got %v", err)
+	}
+	if len(client.getChunkSizes()) != 1 {
+		t.Fatalf("expected the sub-batch's calls to run in the parent's multicall, got %v", client.getChunkSizes())
+	}
+
+	successes, err := sub.Wait()
+	if len(successes) != 2 || !successes[0] || !successes[1] {
+		t.Fatalf("expected both of the sub-batch's calls to succeed, got %v", successes)
+	}
+	if !errors.As(err, &multiErr) || len(multiErr.Errors) != 1 || multiErr.Errors[0].Index != 1 {
+		t.Fatalf("expected the unpack failure to be indexed by the sub-batch's own calls, got %v", err)
+	}
+	if childBalance.Int64() != 2 {
+		t.Fatalf("expected the child's balance to be unpacked, got %s", childBalance)
+	}
+
+	// A sub-batch without calls still settles
+	successes, err = other.Wait()
+	if err != nil || len(successes) != 0 {
+		t.Fatalf("expected an empty sub-batch to settle cleanly, got %v and %v", successes, err)
+	}
+}
+
+func TestSubBatchSettlesWithParentFailure(t *testing.T) {
+	mc, client := newTestMultiCaller(t)
+	client.err = errors.New("node is down")
+	var balance *big.Int
+	sub := mc.NewSubBatch()
+	sub.AddCall(testTokenAddress, &testTokenAbi, &balance, "balanceOf", common.HexToAddress("0x01"))
+	err := mc.StreamCall(false, nil, func(StreamResult) {})
+	if err == nil {
+		t.Fatal("expected the parent's run to fail")
+	}
+	_, err = sub.Wait()
+	if err == nil {
+		t.Fatal("expected the sub-batch to settle with the parent's error")
+	}
+}
@@ -0,0 +1,242 @@
+package batchquery
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"math/big"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+const (
+	// The ABI for the ERC20 getters used by the token helpers
+	erc20AbiString string = "[{\"inputs\":[{\"internalType\":\"address\",\"name\":\"account\",\"type\":\"address\"}],\"name\":\"balanceOf\",\"outputs\":[{\"internalType\":\"uint256\",\"name\":\"\",\"type\":\"uint256\"}],\"stateMutability\":\"view\",\"type\":\"function\"},{\"inputs\":[],\"name\":\"decimals\",\"outputs\":[{\"internalType\":\"uint8\",\"name\":\"\",\"type\":\"uint8\"}],\"stateMutability\":\"view\",\"type\":\"function\"},{\"inputs\":[],\"name\":\"name\",\"outputs\":[{\"internalType\":\"string\",\"name\":\"\",\"type\":\"string\"}],\"stateMutability\":\"view\",\"type\":\"function\"},{\"inputs\":[],\"name\":\"symbol\",\"outputs\":[{\"internalType\":\"string\",\"name\":\"\",\"type\":\"string\"}],\"stateMutability\":\"view\",\"type\":\"function\"}]"
+)
+
+// ABI cache
+var erc20Abi abi.ABI
+var erc20Once sync.Once
+
+// A token held by an address
+type TokenHolding struct {
+	// The address of the token contract
+	Token common.Address
+
+	// The address's balance of the token
+	Balance *big.Int
+}
+
+// The tokens held by a single address
+type TokenInventory struct {
+	// The address that holds the tokens
+	Address common.Address
+
+	// The tokens the address holds, in ascending order of their addresses
+	Holdings []TokenHolding
+}
+
+// This struct discovers which ERC20 tokens a set of addresses hold by scanning the Transfer logs involving them,
+// then reads their balances of those tokens with a single batch of multicalls.
+type TokenDiscoverer struct {
+	// The number of blocks to scan within a single eth_getLogs request, since providers limit the range of a log query (0 = the whole range at once)
+	LogRangeSize uint64
+
+	// The number of log queries to run simultaneously (0 = no limit), within the limit of the MultiCaller's Executor if it has one
+	ThreadLimit int
+
+	// Whether to include tokens the addresses have interacted with but no longer hold
+	IncludeZeroBalances bool
+
+	// The client used to query the logs
+	logFilterer ILogFilterer
+
+	// The MultiCaller used to read the balances
+	caller *MultiCaller
+}
+
+// Creates a new TokenDiscoverer instance
+func NewTokenDiscoverer(logFilterer ILogFilterer, caller *MultiCaller, logRangeSize uint64, threadLimit int) *TokenDiscoverer {
+	return &TokenDiscoverer{
+		LogRangeSize: logRangeSize,
+		ThreadLimit:  threadLimit,
+		logFilterer:  logFilterer,
+		caller:       caller,
+	}
+}
+
+// Discovers the tokens held by each of the addresses from the ERC20 Transfer logs that sent tokens to or from them between fromBlock and toBlock,
+// and reads their balances of those tokens. The inventories are returned in the same order as the provided addresses.
+// The balances are read at the block in opts, which also serves as toBlock if it's nil; if opts doesn't specify a block, the latest one is used.
+// Tokens whose balanceOf function reverts or doesn't return a single word are skipped, since they aren't standard ERC20 tokens. ERC721 transfers are ignored.
+func (d *TokenDiscoverer) DiscoverHoldings(addresses []common.Address, fromBlock *big.Int, toBlock *big.Int, opts *bind.CallOpts) ([]TokenInventory, error) {
+	pinned, err := pinCallOpts(d.caller, opts)
+	if err != nil {
+		return nil, err
+	}
+	if fromBlock == nil {
+		fromBlock = big.NewInt(0)
+	}
+	if toBlock == nil {
+		toBlock = pinned.BlockNumber
+	}
+	if fromBlock.Cmp(toBlock) > 0 {
+		return nil, fmt.Errorf("the scan starts at block %s, which is after its last block %s", fromBlock.String(), toBlock.String())
+	}
+
+	candidates, err := d.findCandidateTokens(addresses, fromBlock.Uint64(), toBlock.Uint64(), d.caller.newCallOptions(pinned))
+	if err != nil {
+		return nil, fmt.Errorf("error scanning transfer logs: %w", err)
+	}
+	erc20, err := getErc20Abi()
+	if err != nil {
+		return nil, err
+	}
+
+	// Read the balance of every candidate token for every address in one batch.
+	// Candidates may not be tokens at all, so the responses are decoded here rather than failing the batch if they can't be unpacked.
+	runner := d.caller.withCalls([]*Call{})
+	for i, tokens := range candidates {
+		for _, token := range tokens {
+			call := runner.AddCall(token, erc20, nil, "balanceOf", addresses[i])
+			call.UnpackFunc = nil
+		}
+	}
+	results, err := runner.FlexibleCallWithResults(false, pinned)
+	if err != nil {
+		return nil, fmt.Errorf("error getting token balances: %w", err)
+	}
+
+	inventories := make([]TokenInventory, len(addresses))
+	index := 0
+	for i, tokens := range candidates {
+		inventories[i] = TokenInventory{
+			Address:  addresses[i],
+			Holdings: []TokenHolding{},
+		}
+		for _, token := range tokens {
+			result := results[index]
+			index++
+			if !result.Success || len(result.ReturnData) != wordSize {
+				continue
+			}
+			balance := new(big.Int).SetBytes(result.ReturnData)
+			if !d.IncludeZeroBalances && balance.Sign() == 0 {
+				continue
+			}
+			inventories[i].Holdings = append(inventories[i].Holdings, TokenHolding{
+				Token:   token,
+				Balance: balance,
+			})
+		}
+	}
+	return inventories, nil
+}
+
+// Scans the Transfer logs sent to or from the addresses, returning the tokens each address has interacted with in ascending order
+func (d *TokenDiscoverer) findCandidateTokens(addresses []common.Address, fromBlock uint64, toBlock uint64, options *callOptions) ([][]common.Address, error) {
+	indices := make(map[common.Address]int, len(addresses))
+	addressTopics := make([]common.Hash, len(addresses))
+	for i, address := range addresses {
+		indices[address] = i
+		addressTopics[i] = common.BytesToHash(address.Bytes())
+	}
+	rangeSize := d.LogRangeSize
+	if rangeSize == 0 || rangeSize > toBlock-fromBlock+1 {
+		rangeSize = toBlock - fromBlock + 1
+	}
+
+	tokenSets := make([]map[common.Address]bool, len(addresses))
+	for i := range tokenSets {
+		tokenSets[i] = map[common.Address]bool{}
+	}
+	var lock sync.Mutex
+	addLogs := func(logs []types.Log) {
+		lock.Lock()
+		defer lock.Unlock()
+		for _, log := range logs {
+			// ERC721 transfers index the token ID as well, so they have a fourth topic
+			if len(log.Topics) != 3 || log.Topics[0] != transferEventTopic {
+				continue
+			}
+			for _, topic := range log.Topics[1:] {
+				index, exists := indices[common.BytesToAddress(topic.Bytes())]
+				if exists {
+					tokenSets[index][log.Address] = true
+				}
+			}
+		}
+	}
+
+	// Tokens sent from the addresses and tokens sent to them need separate queries, since topic filters are ANDed together
+	queries := []ethereum.FilterQuery{}
+	for start := fromBlock; start <= toBlock; start += rangeSize {
+		end := start + rangeSize - 1
+		if end > toBlock {
+			end = toBlock
+		}
+		for _, topics := range [][][]common.Hash{
+			{{transferEventTopic}, addressTopics},
+			{{transferEventTopic}, nil, addressTopics},
+		} {
+			queries = append(queries, ethereum.FilterQuery{
+				FromBlock: new(big.Int).SetUint64(start),
+				ToBlock:   new(big.Int).SetUint64(end),
+				Topics:    topics,
+			})
+		}
+		if end == toBlock {
+			break
+		}
+	}
+
+	// A failure in any query cancels the rest of them
+	err := d.caller.Executor.run(options.ctx, d.ThreadLimit, "eth_getLogs", len(queries), nil, func(ctx context.Context, i int) error {
+		query := queries[i]
+		logs, err := d.logFilterer.FilterLogs(ctx, query)
+		if err != nil {
+			return fmt.Errorf("error getting logs for blocks %d to %d: %w", query.FromBlock.Uint64(), query.ToBlock.Uint64(), wrapClientError(err))
+		}
+		addLogs(logs)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	candidates := make([][]common.Address, len(addresses))
+	for i, tokenSet := range tokenSets {
+		tokens := make([]common.Address, 0, len(tokenSet))
+		for token := range tokenSet {
+			tokens = append(tokens, token)
+		}
+		sort.Slice(tokens, func(a, b int) bool {
+			return bytes.Compare(tokens[a].Bytes(), tokens[b].Bytes()) < 0
+		})
+		candidates[i] = tokens
+	}
+	return candidates, nil
+}
+
+// Gets the parsed ABI for the ERC20 getters
+func getErc20Abi() (*abi.ABI, error) {
+	var err error
+	erc20Once.Do(func() {
+		var parsedAbi abi.ABI
+		parsedAbi, err = abi.JSON(strings.NewReader(erc20AbiString))
+		if err == nil {
+			erc20Abi = parsedAbi
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &erc20Abi, nil
+}
@@ -0,0 +1,63 @@
+package batchquery
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+// Creates a Transfer log for a token
+func newTransferLog(token common.Address, from common.Address, to common.Address, blockNumber uint64) types.Log {
+	return types.Log{
+		Address:     token,
+		Topics:      []common.Hash{transferEventTopic, common.BytesToHash(from.Bytes()), common.BytesToHash(to.Bytes())},
+		BlockNumber: blockNumber,
+	}
+}
+
+func TestDiscoverHoldings(t *testing.T) {
+	mc, _ := newTestMultiCaller(t)
+	holder := common.HexToAddress("0x0102")
+	other := common.HexToAddress("0x0203")
+	idle := common.HexToAddress("0x0304")
+	notAToken := common.HexToAddress("0x9999")
+	nft := newTransferLog(common.HexToAddress("0x7777"), common.Address{}, holder, 20)
+	nft.Topics = append(nft.Topics, common.BigToHash(big.NewInt(1)))
+	filterer := &mockLogFilterer{
+		logs: []types.Log{
+			newTransferLog(testTokenAddress, other, holder, 10),
+			newTransferLog(notAToken, holder, other, 30),
+			nft,
+		},
+	}
+	discoverer := NewTokenDiscoverer(filterer, mc, 25, 1)
+
+	inventories, err := discoverer.DiscoverHoldings([]common.Address{holder, other, idle}, big.NewInt(0), nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The scan covers blocks 0 to 100 in ranges of 25, with a query for each direction
+	if len(filterer.queries) != 10 {
+		t.Fatalf("expected 10 log queries, got %d", len(filterer.queries))
+	}
+	if filterer.queries[len(filterer.queries)-1].ToBlock.Uint64() != 100 {
+		t.Fatalf("expected the scan to end at the latest block, got %s", filterer.queries[len(filterer.queries)-1].ToBlock)
+	}
+
+	// Only the real token is reported, with balances read at the latest block
+	for i, address := range []common.Address{holder, other} {
+		holdings := inventories[i].Holdings
+		if inventories[i].Address != address || len(holdings) != 1 || holdings[0].Token != testTokenAddress {
+			t.Fatalf("expected address %d to hold only the test token, got %+v", i, inventories[i])
+		}
+		if holdings[0].Balance.Cmp(expectedBalance(address, 100)) != 0 {
+			t.Fatalf("expected a balance of %s for address %d, got %s", expectedBalance(address, 100), i, holdings[0].Balance)
+		}
+	}
+	if len(inventories[2].Holdings) != 0 {
+		t.Fatalf("expected the idle address to hold nothing, got %+v", inventories[2].Holdings)
+	}
+}
@@ -0,0 +1,102 @@
+package batchquery
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+// The HTTP settings for a client created by DialTunedClient.
+// The defaults of net/http only keep 2 idle connections per host, so a batch that runs many chunks at once keeps opening and closing connections;
+// these settings keep a larger pool of connections alive to the endpoint instead.
+type ClientConfig struct {
+	// The maximum number of idle connections to keep open to the endpoint, which should be at least the ThreadLimit of the batchers using it
+	MaxIdleConnsPerHost int
+
+	// The maximum number of connections to the endpoint, including ones in use (0 = no limit)
+	MaxConnsPerHost int
+
+	// How long an idle connection is kept open before it's closed (0 = forever)
+	IdleConnTimeout time.Duration
+
+	// How long to wait for a connection to be established (0 = no limit beyond the one in the context)
+	DialTimeout time.Duration
+
+	// The interval between TCP keep-alive probes on open connections (0 = the system default)
+	KeepAlive time.Duration
+
+	// How long to wait for the TLS handshake of a new connection (0 = no limit)
+	TLSHandshakeTimeout time.Duration
+
+	// How long to wait for the endpoint to start responding after a request is sent.
+	// Large multicalls can take a while to execute, so this should leave room for the slowest expected chunk (0 = no limit).
+	ResponseHeaderTimeout time.Duration
+
+	// The overall limit for a single request, including reading the response (0 = no limit beyond the one in the context)
+	RequestTimeout time.Duration
+
+	// Whether to negotiate HTTP/2 with endpoints that support it, which multiplexes concurrent requests over a single connection
+	EnableHTTP2 bool
+
+	// Extra headers to send with every request, such as API keys for hosted endpoints
+	Headers http.Header
+}
+
+// Gets the recommended HTTP settings for heavy batch workloads
+func DefaultClientConfig() ClientConfig {
+	return ClientConfig{
+		MaxIdleConnsPerHost:   64,
+		IdleConnTimeout:       90 * time.Second,
+		DialTimeout:           10 * time.Second,
+		KeepAlive:             30 * time.Second,
+		TLSHandshakeTimeout:   10 * time.Second,
+		ResponseHeaderTimeout: 60 * time.Second,
+	}
+}
+
+// Connects to an Execution client endpoint with HTTP settings tuned for batch workloads, returning a client that can be used with every batcher in this package.
+// If config is nil, DefaultClientConfig() is used. The HTTP settings only apply to HTTP(S) endpoints; websocket and IPC endpoints only use the headers.
+func DialTunedClient(ctx context.Context, url string, config *ClientConfig) (*ethclient.Client, error) {
+	if config == nil {
+		defaultConfig := DefaultClientConfig()
+		config = &defaultConfig
+	}
+	options := []rpc.ClientOption{
+		rpc.WithHTTPClient(newTunedHTTPClient(config)),
+	}
+	if len(config.Headers) > 0 {
+		options = append(options, rpc.WithHeaders(config.Headers))
+	}
+	client, err := rpc.DialOptions(ctx, url, options...)
+	if err != nil {
+		return nil, fmt.Errorf("error connecting to %s: %w", url, err)
+	}
+	return ethclient.NewClient(client), nil
+}
+
+// Creates an HTTP client with the provided settings
+func newTunedHTTPClient(config *ClientConfig) *http.Client {
+	dialer := &net.Dialer{
+		Timeout:   config.DialTimeout,
+		KeepAlive: config.KeepAlive,
+	}
+	transport := &http.Transport{
+		Proxy:                 http.ProxyFromEnvironment,
+		DialContext:           dialer.DialContext,
+		MaxIdleConnsPerHost:   config.MaxIdleConnsPerHost,
+		MaxConnsPerHost:       config.MaxConnsPerHost,
+		IdleConnTimeout:       config.IdleConnTimeout,
+		TLSHandshakeTimeout:   config.TLSHandshakeTimeout,
+		ResponseHeaderTimeout: config.ResponseHeaderTimeout,
+		ForceAttemptHTTP2:     config.EnableHTTP2,
+	}
+	return &http.Client{
+		Transport: transport,
+		Timeout:   config.RequestTimeout,
+	}
+}
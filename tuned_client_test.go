@@ -0,0 +1,60 @@
+package batchquery
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/ethereum/go-ethereum"
+)
+
+func TestDialTunedClient(t *testing.T) {
+	var apiKey string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		apiKey = r.Header.Get("X-Api-Key")
+		var request struct {
+			ID     json.RawMessage `json:"id"`
+			Method string          `json:"method"`
+		}
+		err := json.NewDecoder(r.Body).Decode(&request)
+		if err != nil || request.Method != "eth_call" {
+			http.Error(w, "unexpected request", http.StatusBadRequest)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"jsonrpc":"2.0","id":` + string(request.ID) + `,"result":"0x2a"}`))
+	}))
+	defer server.Close()
+
+	config := DefaultClientConfig()
+	config.Headers = http.Header{"X-Api-Key": []string{"secret"}}
+	client, err := DialTunedClient(context.Background(), server.URL, &config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+
+	result, err := client.CallContract(context.Background(), ethereum.CallMsg{To: &testTokenAddress}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(result) != 1 || result[0] != 0x2a {
+		t.Fatalf("expected 0x2a, got %x", result)
+	}
+	if apiKey != "secret" {
+		t.Fatalf("expected the configured header to be sent, got %q", apiKey)
+	}
+}
+
+func TestTunedHTTPClientSettings(t *testing.T) {
+	config := DefaultClientConfig()
+	config.MaxConnsPerHost = 16
+	config.EnableHTTP2 = true
+	client := newTunedHTTPClient(&config)
+	transport := client.Transport.(*http.Transport)
+	if transport.MaxIdleConnsPerHost != 64 || transport.MaxConnsPerHost != 16 || !transport.ForceAttemptHTTP2 {
+		t.Fatalf("expected the transport to use the configured settings, got %+v", transport)
+	}
+}
@@ -0,0 +1,133 @@
+package batchquery
+
+import (
+	"fmt"
+	"math"
+	"math/big"
+	"time"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// Adds a call for a method that returns an unsigned integer of up to 256 bits (or a signed one that's never negative), unpacking it into a uint64.
+// The response fails to unpack if the value doesn't fit.
+func (mc *MultiCaller) AddUint64Call(contractAddress common.Address, abi *abi.ABI, output *uint64, method string, args ...any) *Call {
+	return mc.addConvertedCall(contractAddress, abi, output, method, func(value any) error {
+		converted, err := toUint64(value)
+		if err != nil {
+			return err
+		}
+		*output = converted
+		return nil
+	}, args...)
+}
+
+// Adds a call for a method that returns a Unix timestamp in seconds as an unsigned integer, such as a uint256, unpacking it into a time.Time in UTC.
+// The response fails to unpack if the timestamp is too large to represent.
+func (mc *MultiCaller) AddTimeCall(contractAddress common.Address, abi *abi.ABI, output *time.Time, method string, args ...any) *Call {
+	return mc.addConvertedCall(contractAddress, abi, output, method, func(value any) error {
+		seconds, err := toUint64(value)
+		if err != nil {
+			return err
+		}
+		if seconds > math.MaxInt64 {
+			return fmt.Errorf("timestamp %d is out of range", seconds)
+		}
+		*output = time.Unix(int64(seconds), 0).UTC()
+		return nil
+	}, args...)
+}
+
+// Adds a call for a method that returns a bool, unpacking it into a bool
+func (mc *MultiCaller) AddBoolCall(contractAddress common.Address, abi *abi.ABI, output *bool, method string, args ...any) *Call {
+	return mc.addConvertedCall(contractAddress, abi, output, method, func(value any) error {
+		converted, ok := value.(bool)
+		if !ok {
+			return fmt.Errorf("value of type %T is not a bool", value)
+		}
+		*output = converted
+		return nil
+	}, args...)
+}
+
+// Adds a call for a method that returns an address, unpacking it into a common.Address
+func (mc *MultiCaller) AddAddressCall(contractAddress common.Address, abi *abi.ABI, output *common.Address, method string, args ...any) *Call {
+	return mc.addConvertedCall(contractAddress, abi, output, method, func(value any) error {
+		converted, ok := value.(common.Address)
+		if !ok {
+			return fmt.Errorf("value of type %T is not an address", value)
+		}
+		*output = converted
+		return nil
+	}, args...)
+}
+
+// Adds a call for a method that returns a string, unpacking it into a string
+func (mc *MultiCaller) AddStringCall(contractAddress common.Address, abi *abi.ABI, output *string, method string, args ...any) *Call {
+	return mc.addConvertedCall(contractAddress, abi, output, method, func(value any) error {
+		converted, ok := value.(string)
+		if !ok {
+			return fmt.Errorf("value of type %T is not a string", value)
+		}
+		*output = converted
+		return nil
+	}, args...)
+}
+
+// Adds a call for a method that returns a string as a bytes32, as some older tokens and registries do, unpacking it into a string without the zeros that pad it
+func (mc *MultiCaller) AddBytes32StringCall(contractAddress common.Address, abi *abi.ABI, output *string, method string, args ...any) *Call {
+	return mc.addConvertedCall(contractAddress, abi, output, method, func(value any) error {
+		converted, ok := value.([32]byte)
+		if !ok {
+			return fmt.Errorf("value of type %T is not a bytes32", value)
+		}
+		*output = Bytes32ToString(converted)
+		return nil
+	}, args...)
+}
+
+// Adds a call whose response is unpacked by decoding the method's first output and passing it to a conversion function that stores it in the output
+func (mc *MultiCaller) addConvertedCall(contractAddress common.Address, contractAbi *abi.ABI, output any, method string, convert func(value any) error, args ...any) *Call {
+	call := mc.AddCall(contractAddress, contractAbi, output, method, args...)
+	call.UnpackFunc = func(rawData []byte) error {
+		values, err := contractAbi.Unpack(method, rawData)
+		if err != nil {
+			return err
+		}
+		if len(values) == 0 {
+			return fmt.Errorf("method %s has no outputs", method)
+		}
+		return convert(values[0])
+	}
+	return call
+}
+
+// Converts a decoded integer of any size to a uint64, failing if it's negative or too large
+func toUint64(value any) (uint64, error) {
+	switch value := value.(type) {
+	case *big.Int:
+		if value.Sign() < 0 || !value.IsUint64() {
+			return 0, fmt.Errorf("value %s is out of range for a uint64", value.String())
+		}
+		return value.Uint64(), nil
+	case uint8:
+		return uint64(value), nil
+	case uint16:
+		return uint64(value), nil
+	case uint32:
+		return uint64(value), nil
+	case uint64:
+		return value, nil
+	case int8:
+		return toUint64(big.NewInt(int64(value)))
+	case int16:
+		return toUint64(big.NewInt(int64(value)))
+	case int32:
+		return toUint64(big.NewInt(int64(value)))
+	case int64:
+		return toUint64(big.NewInt(value))
+	default:
+		return 0, fmt.Errorf("value of type %T is not an integer", value)
+	}
+}
@@ -0,0 +1,324 @@
+package batchquery
+
+import (
+	"bytes"
+	"fmt"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// Decodes a call's ABI-encoded return data directly into a value of a concrete type
+type Decoder[T any] func(data []byte) (T, error)
+
+// Adds a contract call like AddCall, but decodes its response into output with the provided decoder
+// instead of the ABI's reflection-based unpacking, which is much cheaper for batches with many results.
+// The decoder is usually one of the Decode functions, or NewDecoder for structs and multiple return values.
+func AddDecodedCall[T any](mc *MultiCaller, contractAddress common.Address, abi *abi.ABI, output *T, decode Decoder[T], method string, args ...any) *Call {
+	call := newCall(contractAddress, abi, output, method, args...)
+	call.UnpackFunc = func(data []byte) error {
+		value, err := decode(data)
+		if err != nil {
+			return err
+		}
+		*output = value
+		return nil
+	}
+	mc.calls = append(mc.calls, call)
+	return call
+}
+
+// Creates a decoder that reads the return values with the provided function, such as one that reads each field of a struct in order
+func NewDecoder[T any](read func(r *AbiReader) T) Decoder[T] {
+	return func(data []byte) (T, error) {
+		return DecodeWith(data, read)
+	}
+}
+
+// Creates a decoder for a function that returns a dynamic array, reading each element with the provided function
+func NewArrayDecoder[T any](read func(r *AbiReader) T) Decoder[[]T] {
+	return func(data []byte) ([]T, error) {
+		return DecodeWith(data, func(r *AbiReader) []T {
+			return ReadArray(r, read)
+		})
+	}
+}
+
+// Decodes return data by reading the return values with the provided function
+func DecodeWith[T any](data []byte, read func(r *AbiReader) T) (T, error) {
+	r := NewAbiReader(data)
+	value := read(r)
+	if r.err != nil {
+		var empty T
+		return empty, r.err
+	}
+	return value, nil
+}
+
+// Decodes return data that's a single uint256
+func DecodeUint256(data []byte) (*big.Int, error) {
+	return DecodeWith(data, (*AbiReader).Uint256)
+}
+
+// Decodes return data that's a single int256
+func DecodeInt256(data []byte) (*big.Int, error) {
+	return DecodeWith(data, (*AbiReader).Int256)
+}
+
+// Decodes return data that's a single unsigned integer of up to 64 bits
+func DecodeUint64(data []byte) (uint64, error) {
+	return DecodeWith(data, (*AbiReader).Uint64)
+}
+
+// Decodes return data that's a single uint8, such as a token's decimals
+func DecodeUint8(data []byte) (uint8, error) {
+	return DecodeWith(data, (*AbiReader).Uint8)
+}
+
+// Decodes return data that's a single address
+func DecodeAddress(data []byte) (common.Address, error) {
+	return DecodeWith(data, (*AbiReader).Address)
+}
+
+// Decodes return data that's a single bool
+func DecodeBool(data []byte) (bool, error) {
+	return DecodeWith(data, (*AbiReader).Bool)
+}
+
+// Decodes return data that's a single bytes32
+func DecodeBytes32(data []byte) (common.Hash, error) {
+	return DecodeWith(data, (*AbiReader).Bytes32)
+}
+
+// Decodes return data that's a single bytes32 holding a string, such as an older token's symbol, trimming the zeros that pad it
+func DecodeBytes32String(data []byte) (string, error) {
+	return DecodeWith(data, (*AbiReader).Bytes32String)
+}
+
+// Decodes return data that's a single string
+func DecodeString(data []byte) (string, error) {
+	return DecodeWith(data, (*AbiReader).String)
+}
+
+// Decodes return data that's a single dynamic bytes value
+func DecodeBytes(data []byte) ([]byte, error) {
+	return DecodeWith(data, (*AbiReader).Bytes)
+}
+
+// Converts a bytes32 holding a string, which older contracts such as some tokens and registries return instead of a string, to a Go string by trimming the zeros that pad it
+func Bytes32ToString(value [32]byte) string {
+	return string(bytes.TrimRight(value[:], "\x00"))
+}
+
+// Reads ABI-encoded values in order without reflection.
+// Static values are read from consecutive words; dynamic values (strings, bytes, arrays, and dynamic tuples) are read through the offset in their word.
+// The first error is kept and every read after it returns a zero value, so a struct can be read field by field and the error checked once at the end.
+type AbiReader struct {
+	// The encoding that offsets are relative to
+	data []byte
+
+	// The offset of the next word to read
+	offset uint64
+
+	// The first error encountered
+	err error
+}
+
+// Creates a new AbiReader for the provided encoding
+func NewAbiReader(data []byte) *AbiReader {
+	return &AbiReader{
+		data: data,
+	}
+}
+
+// Gets the first error the reader encountered, or nil if every read worked
+func (r *AbiReader) Err() error {
+	return r.err
+}
+
+// Sets the reader's error if it doesn't have one yet
+func (r *AbiReader) fail(err error) {
+	if r.err == nil {
+		r.err = err
+	}
+}
+
+// Reads the next word, or returns nil if it's out of bounds or the reader has already failed
+func (r *AbiReader) next() []byte {
+	if r.err != nil {
+		return nil
+	}
+	if r.offset > uint64(len(r.data)) || uint64(len(r.data))-r.offset < wordSize {
+		r.fail(fmt.Errorf("word at offset %d is out of bounds", r.offset))
+		return nil
+	}
+	word := r.data[r.offset : r.offset+wordSize]
+	r.offset += wordSize
+	return word
+}
+
+// Reads a uint256 (or any smaller unsigned integer)
+func (r *AbiReader) Uint256() *big.Int {
+	word := r.next()
+	if word == nil {
+		return nil
+	}
+	return new(big.Int).SetBytes(word)
+}
+
+// Reads an int256 (or any smaller signed integer)
+func (r *AbiReader) Int256() *big.Int {
+	value := r.Uint256()
+	if value == nil || value.Bit(255) == 0 {
+		return value
+	}
+	return value.Sub(value, new(big.Int).Lsh(big.NewInt(1), 256))
+}
+
+// Reads an unsigned integer that must fit within 64 bits
+func (r *AbiReader) Uint64() uint64 {
+	if r.err != nil {
+		return 0
+	}
+	value, err := readWord(r.data, r.offset)
+	if err != nil {
+		r.fail(err)
+		return 0
+	}
+	r.offset += wordSize
+	return value
+}
+
+// Reads a uint8
+func (r *AbiReader) Uint8() uint8 {
+	offset := r.offset
+	value := r.Uint64()
+	if value > 0xff {
+		r.fail(fmt.Errorf("word at offset %d overflows 8 bits", offset))
+		return 0
+	}
+	return uint8(value)
+}
+
+// Reads an address
+func (r *AbiReader) Address() common.Address {
+	offset := r.offset
+	word := r.next()
+	if word == nil {
+		return common.Address{}
+	}
+	for _, b := range word[:wordSize-common.AddressLength] {
+		if b != 0 {
+			r.fail(fmt.Errorf("word at offset %d is not a valid address", offset))
+			return common.Address{}
+		}
+	}
+	return common.BytesToAddress(word)
+}
+
+// Reads a bool
+func (r *AbiReader) Bool() bool {
+	offset := r.offset
+	value := r.Uint64()
+	if value > 1 {
+		r.fail(fmt.Errorf("word at offset %d is not a valid bool", offset))
+		return false
+	}
+	return value == 1
+}
+
+// Reads a bytes32 (or any smaller fixed-size byte array, which is left-aligned within it)
+func (r *AbiReader) Bytes32() common.Hash {
+	word := r.next()
+	if word == nil {
+		return common.Hash{}
+	}
+	return common.BytesToHash(word)
+}
+
+// Reads a bytes32 holding a string, trimming the zeros that pad it
+func (r *AbiReader) Bytes32String() string {
+	value := r.Bytes32()
+	return Bytes32ToString(value)
+}
+
+// Reads a dynamic bytes value; the result references the reader's encoding rather than being copied
+func (r *AbiReader) Bytes() []byte {
+	tail := r.tail()
+	if tail == nil {
+		return nil
+	}
+	length := tail.Uint64()
+	if tail.err != nil {
+		r.fail(tail.err)
+		return nil
+	}
+	start := tail.offset
+	if length > uint64(len(tail.data))-start {
+		r.fail(fmt.Errorf("%d bytes at offset %d are out of bounds", length, start))
+		return nil
+	}
+	return tail.data[start : start+length : start+length]
+}
+
+// Reads a string
+func (r *AbiReader) String() string {
+	return string(r.Bytes())
+}
+
+// Reads the offset of a dynamic tuple, returning a reader over its fields.
+// Static tuples are encoded in place, so their fields are read directly from this reader instead.
+func (r *AbiReader) Tuple() *AbiReader {
+	tail := r.tail()
+	if tail == nil {
+		return &AbiReader{err: r.err}
+	}
+	return tail
+}
+
+// Reads the offset of a dynamic value, returning a reader that starts at it (nil if the offset is invalid)
+func (r *AbiReader) tail() *AbiReader {
+	offset := r.Uint64()
+	if r.err != nil {
+		return nil
+	}
+	if offset > uint64(len(r.data)) {
+		r.fail(fmt.Errorf("offset %d is out of bounds", offset))
+		return nil
+	}
+	return &AbiReader{
+		data: r.data[offset:],
+	}
+}
+
+// Reads a dynamic array, reading each element with the provided function
+func ReadArray[T any](r *AbiReader, read func(r *AbiReader) T) []T {
+	tail := r.tail()
+	if tail == nil {
+		return nil
+	}
+	length := tail.Uint64()
+	if tail.err != nil {
+		r.fail(tail.err)
+		return nil
+	}
+
+	// Every element takes at least one word, which bounds the length before allocating
+	elements := &AbiReader{
+		data: tail.data[wordSize:],
+	}
+	if length > uint64(len(elements.data))/wordSize {
+		r.fail(fmt.Errorf("array of %d elements is out of bounds", length))
+		return nil
+	}
+	values := make([]T, length)
+	for i := range values {
+		values[i] = read(elements)
+	}
+	if elements.err != nil {
+		r.fail(elements.err)
+		return nil
+	}
+	return values
+}
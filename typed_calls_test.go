@@ -0,0 +1,129 @@
+package batchquery
+
+import (
+	"errors"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// An ABI with outputs the mock token doesn't have, for testing the conversions directly
+var typedTestAbi = mustParseAbi(`[
+	{"inputs":[],"name":"flag","outputs":[{"name":"","type":"bool"}],"stateMutability":"view","type":"function"},
+	{"inputs":[],"name":"amount","outputs":[{"name":"","type":"uint256"}],"stateMutability":"view","type":"function"},
+	{"inputs":[],"name":"delta","outputs":[{"name":"","type":"int64"}],"stateMutability":"view","type":"function"},
+	{"inputs":[],"name":"label","outputs":[{"name":"","type":"bytes32"}],"stateMutability":"view","type":"function"}
+]`)
+
+func TestTypedCalls(t *testing.T) {
+	mc, _ := newTestMultiCaller(t)
+	gettersAbi, err := getMulticall3GettersAbi()
+	if err != nil {
+		t.Fatal(err)
+	}
+	account := common.HexToAddress("0x0102")
+	var balance uint64
+	var decimals uint64
+	var timestamp time.Time
+	var sender common.Address
+	var symbol string
+	mc.AddUint64Call(testTokenAddress, &testTokenAbi, &balance, "balanceOf", account)
+	mc.AddUint64Call(testTokenAddress, &testTokenAbi, &decimals, "decimals")
+	mc.AddTimeCall(testMulticallAddress, gettersAbi, &timestamp, "getCurrentBlockTimestamp")
+	mc.AddAddressCall(testTokenAddress, &testTokenAbi, &sender, "whoami")
+	mc.AddStringCall(testTokenAddress, &testTokenAbi, &symbol, "symbol")
+	_, err = mc.FlexibleCall(true, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if balance != expectedBalance(account, 0).Uint64() || decimals != 2 {
+		t.Fatalf("unexpected integers %d and %d", balance, decimals)
+	}
+	if !timestamp.Equal(time.Unix(1200, 0)) || timestamp.Location() != time.UTC {
+		t.Fatalf("expected the timestamp of block 100 in UTC, got %s", timestamp)
+	}
+	if sender != testMulticallAddress || symbol != "TST" {
+		t.Fatalf("unexpected sender %s and symbol %s", sender.Hex(), symbol)
+	}
+}
+
+func TestTypedCallConversions(t *testing.T) {
+	mc, _ := newTestMultiCaller(t)
+	var flag bool
+	call := mc.AddBoolCall(testTokenAddress, &typedTestAbi, &flag, "flag")
+	data, err := typedTestAbi.Methods["flag"].Outputs.Pack(true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = call.UnpackFunc(data)
+	if err != nil || !flag {
+		t.Fatalf("expected the bool to be unpacked, got %t and %v", flag, err)
+	}
+
+	// Values that don't fit in a uint64 fail to unpack
+	var amount uint64
+	call = mc.AddUint64Call(testTokenAddress, &typedTestAbi, &amount, "amount")
+	tooLarge := new(big.Int).Lsh(big.NewInt(1), 64)
+	data, err = typedTestAbi.Methods["amount"].Outputs.Pack(tooLarge)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = call.unpackResponse(CallResponse{Status: true, ReturnData: data})
+	if !errors.Is(err, ErrUnpackFailed) {
+		t.Fatalf("expected an out of range value to fail to unpack, got %v", err)
+	}
+	var timestamp time.Time
+	call = mc.AddTimeCall(testTokenAddress, &typedTestAbi, &timestamp, "amount")
+	if call.UnpackFunc(data) == nil {
+		t.Fatal("expected an out of range timestamp to fail to unpack")
+	}
+
+	// Signed values are accepted as long as they aren't negative
+	call = mc.AddUint64Call(testTokenAddress, &typedTestAbi, &amount, "delta")
+	data, err = typedTestAbi.Methods["delta"].Outputs.Pack(int64(5))
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = call.UnpackFunc(data)
+	if err != nil || amount != 5 {
+		t.Fatalf("expected a positive signed value to be converted, got %d and %v", amount, err)
+	}
+	data, err = typedTestAbi.Methods["delta"].Outputs.Pack(int64(-5))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if call.UnpackFunc(data) == nil {
+		t.Fatal("expected a negative value to fail to unpack")
+	}
+
+	// Bytes32 strings have their padding trimmed
+	var text string
+	call = mc.AddBytes32StringCall(testTokenAddress, &typedTestAbi, &text, "label")
+	var label [32]byte
+	copy(label[:], "MKR")
+	data, err = typedTestAbi.Methods["label"].Outputs.Pack(label)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = call.UnpackFunc(data)
+	if err != nil || text != "MKR" {
+		t.Fatalf("expected the bytes32 to be unpacked into a string, got %q and %v", text, err)
+	}
+
+	// Outputs of the wrong type fail to unpack instead of panicking
+	call = mc.AddStringCall(testTokenAddress, &typedTestAbi, &text, "flag")
+	data, err = typedTestAbi.Methods["flag"].Outputs.Pack(true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if call.UnpackFunc(data) == nil {
+		t.Fatal("expected a bool to fail to unpack into a string")
+	}
+	call = mc.AddBytes32StringCall(testTokenAddress, &typedTestAbi, &text, "flag")
+	if call.UnpackFunc(data) == nil {
+		t.Fatal("expected a bool to fail to unpack into a bytes32 string")
+	}
+}
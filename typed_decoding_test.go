@@ -0,0 +1,235 @@
+package batchquery
+
+import (
+	"errors"
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// A struct decoded without reflection
+type typedPosition struct {
+	Owner  common.Address
+	Amount *big.Int
+	Name   string
+	Active bool
+	Tags   []string
+}
+
+// Reads a position's fields in order
+func readTypedPosition(r *AbiReader) typedPosition {
+	return typedPosition{
+		Owner:  r.Address(),
+		Amount: r.Uint256(),
+		Name:   r.String(),
+		Active: r.Bool(),
+		Tags:   ReadArray(r, (*AbiReader).String),
+	}
+}
+
+// Packs values with the provided ABI types
+func packTypedValues(t testing.TB, types []string, values ...any) []byte {
+	args := make(abi.Arguments, len(types))
+	for i, name := range types {
+		typ, err := abi.NewType(name, "", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		args[i] = abi.Argument{Type: typ}
+	}
+	data, err := args.Pack(values...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+func TestDecodeStaticValues(t *testing.T) {
+	data := packTypedValues(t, []string{"uint256", "int256", "uint8", "address", "bool", "bytes32"},
+		big.NewInt(1234), big.NewInt(-5), uint8(18), testTokenAddress, true, [32]byte{1, 2, 3})
+	r := NewAbiReader(data)
+	if value := r.Uint256(); value.Int64() != 1234 {
+		t.Fatalf("unexpected uint256 %s", value)
+	}
+	if value := r.Int256(); value.Int64() != -5 {
+		t.Fatalf("unexpected int256 %s", value)
+	}
+	if value := r.Uint8(); value != 18 {
+		t.Fatalf("unexpected uint8 %d", value)
+	}
+	if value := r.Address(); value != testTokenAddress {
+		t.Fatalf("unexpected address %s", value.Hex())
+	}
+	if value := r.Bool(); !value {
+		t.Fatal("unexpected bool")
+	}
+	if value := r.Bytes32(); value != (common.Hash{1, 2, 3}) {
+		t.Fatalf("unexpected bytes32 %s", value.Hex())
+	}
+	if r.Err() != nil {
+		t.Fatal(r.Err())
+	}
+
+	// Reading past the end fails, and the error sticks
+	r.Uint256()
+	r.Uint8()
+	if r.Err() == nil {
+		t.Fatal("expected reading past the end to fail")
+	}
+}
+
+func TestDecodeBytes32String(t *testing.T) {
+	var registryName [32]byte
+	copy(registryName[:], "rocketNodeManager")
+	data := packTypedValues(t, []string{"bytes32"}, registryName)
+	value, err := DecodeBytes32String(data)
+	if err != nil || value != "rocketNodeManager" {
+		t.Fatalf("expected the padding to be trimmed, got %q and %v", value, err)
+	}
+	if value := Bytes32ToString([32]byte{}); value != "" {
+		t.Fatalf("expected an empty bytes32 to be an empty string, got %q", value)
+	}
+	_, err = DecodeBytes32String(data[:16])
+	if err == nil {
+		t.Fatal("expected short data to fail to decode")
+	}
+}
+
+func TestDecodeStructs(t *testing.T) {
+	data := packTypedValues(t, []string{"address", "uint256", "string", "bool", "string[]"},
+		testTokenAddress, big.NewInt(77), "stake", true, []string{"a", "bc"})
+	position, err := NewDecoder(readTypedPosition)(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if position.Owner != testTokenAddress || position.Amount.Int64() != 77 || position.Name != "stake" || !position.Active {
+		t.Fatalf("unexpected position %+v", position)
+	}
+	if len(position.Tags) != 2 || position.Tags[0] != "a" || position.Tags[1] != "bc" {
+		t.Fatalf("unexpected tags %v", position.Tags)
+	}
+
+	// Truncated data is rejected rather than read out of bounds
+	_, err = NewDecoder(readTypedPosition)(data[:len(data)-wordSize])
+	if err == nil {
+		t.Fatal("expected truncated data to fail")
+	}
+}
+
+func TestDecodeInvalidValues(t *testing.T) {
+	_, err := DecodeAddress(packTypedValues(t, []string{"uint256"}, new(big.Int).Lsh(big.NewInt(1), 200)))
+	if err == nil {
+		t.Fatal("expected an oversized address to fail")
+	}
+	_, err = DecodeBool(packTypedValues(t, []string{"uint256"}, big.NewInt(2)))
+	if err == nil {
+		t.Fatal("expected an invalid bool to fail")
+	}
+	_, err = DecodeUint8(packTypedValues(t, []string{"uint256"}, big.NewInt(256)))
+	if err == nil {
+		t.Fatal("expected an oversized uint8 to fail")
+	}
+
+	// An array claiming more elements than the data holds is rejected before allocating
+	data := packTypedValues(t, []string{"uint256", "uint256"}, big.NewInt(wordSize), big.NewInt(1<<40))
+	_, err = NewArrayDecoder((*AbiReader).Uint256)(data)
+	if err == nil {
+		t.Fatal("expected an oversized array to fail")
+	}
+}
+
+func TestAddDecodedCall(t *testing.T) {
+	mc, _ := newTestMultiCaller(t)
+	account := common.HexToAddress("0x0105")
+	var balance *big.Int
+	var list []*big.Int
+	var symbol string
+	var decimals uint8
+	AddDecodedCall(mc, testTokenAddress, &testTokenAbi, &balance, DecodeUint256, "balanceOf", account)
+	AddDecodedCall(mc, testTokenAddress, &testTokenAbi, &list, NewArrayDecoder((*AbiReader).Uint256), "list", big.NewInt(5))
+	AddDecodedCall(mc, testTokenAddress, &testTokenAbi, &symbol, DecodeString, "symbol")
+	AddDecodedCall(mc, testTokenAddress, &testTokenAbi, &decimals, DecodeUint8, "decimals")
+	_, err := mc.FlexibleCall(true, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if balance.Cmp(expectedBalance(account, 0)) != 0 {
+		t.Fatalf("unexpected balance %s", balance)
+	}
+	if len(list) != 5 || list[4].Int64() != 4 {
+		t.Fatalf("unexpected list %v", list)
+	}
+	if symbol != "TST" || decimals != 2 {
+		t.Fatalf("unexpected symbol %q and decimals %d", symbol, decimals)
+	}
+
+	// Decoding failures are reported like any other unpack failure
+	var wrong bool
+	AddDecodedCall(mc, testTokenAddress, &testTokenAbi, &wrong, DecodeBool, "symbol")
+	_, err = mc.FlexibleCall(true, nil)
+	if !errors.Is(err, ErrUnpackFailed) {
+		t.Fatalf("expected the string to fail to decode as a bool, got %v", err)
+	}
+}
+
+// Creates the return data of a function that returns a uint256 array
+func newTypedBenchmarkData(b *testing.B, count int) []byte {
+	list := make([]*big.Int, count)
+	for i := range list {
+		list[i] = new(big.Int).Lsh(big.NewInt(int64(i)), 100)
+	}
+	return packTypedValues(b, []string{"uint256[]"}, list)
+}
+
+func BenchmarkDecodeUint256Array(b *testing.B) {
+	data := newTypedBenchmarkData(b, 1000)
+	decode := NewArrayDecoder((*AbiReader).Uint256)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		_, err := decode(data)
+		if err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkAbiUnpackUint256Array(b *testing.B) {
+	data := newTypedBenchmarkData(b, 1000)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		var list []*big.Int
+		err := testTokenAbi.UnpackIntoInterface(&list, "list", data)
+		if err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkDecodeUint256(b *testing.B) {
+	data := packTypedValues(b, []string{"uint256"}, big.NewInt(1234))
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		_, err := DecodeUint256(data)
+		if err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkAbiUnpackUint256(b *testing.B) {
+	data := packTypedValues(b, []string{"uint256"}, big.NewInt(1234))
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		var balance *big.Int
+		err := testTokenAbi.UnpackIntoInterface(&balance, "balanceOf", data)
+		if err != nil {
+			b.Fatal(err)
+		}
+	}
+}
@@ -5,6 +5,9 @@ import (
 	"math/big"
 
 	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/rpc"
 )
 
 // This is an Execution client binding that can call a contract function
@@ -12,3 +15,110 @@ type IContractCaller interface {
 	// Calls a contract function, typically using eth_call
 	CallContract(ctx context.Context, call ethereum.CallMsg, blockNumber *big.Int) ([]byte, error)
 }
+
+// This is an Execution client binding that can call a contract function at a specific block hash (EIP-1898).
+// Clients that implement this can run batches pinned to a known block, even across shallow reorgs.
+type IContractCallerAtHash interface {
+	// Calls a contract function at the block with the provided hash, typically using eth_call
+	CallContractAtHash(ctx context.Context, call ethereum.CallMsg, blockHash common.Hash) ([]byte, error)
+}
+
+// This is an Execution client binding that can call a contract function against the pending block.
+// Clients that implement this can run batches that see the state after the node's currently pending transactions.
+type IPendingContractCaller interface {
+	// Calls a contract function against the pending block, typically using eth_call
+	PendingCallContract(ctx context.Context, call ethereum.CallMsg) ([]byte, error)
+}
+
+// This is an Execution client binding that can subscribe to new block headers
+type IHeadSubscriber interface {
+	// Subscribes to notifications about new block headers, typically using eth_subscribe
+	SubscribeNewHead(ctx context.Context, ch chan<- *types.Header) (ethereum.Subscription, error)
+}
+
+// This is an Execution client binding that can query event logs
+type ILogFilterer interface {
+	// Gets the logs that match a filter, typically using eth_getLogs
+	FilterLogs(ctx context.Context, query ethereum.FilterQuery) ([]types.Log, error)
+}
+
+// This is an Execution client binding that can get Merkle proofs of account and storage values
+type IProofGetter interface {
+	// Gets the proofs for an account and some of its storage slots, typically using eth_getProof
+	GetProof(ctx context.Context, account common.Address, keys []string, blockNumber *big.Int) (*AccountProof, error)
+}
+
+// This is an Execution client binding that can get Merkle proofs at a specific block hash (EIP-1898)
+type IProofGetterAtHash interface {
+	// Gets the proofs for an account and some of its storage slots at the block with the provided hash, typically using eth_getProof
+	GetProofAtHash(ctx context.Context, account common.Address, keys []string, blockHash common.Hash) (*AccountProof, error)
+}
+
+// This is a trusted source of block headers, such as a light client, whose state roots can be used to verify responses from an untrusted client
+type ITrustedHeaderReader interface {
+	// Gets the header of the block with the provided number (nil = latest)
+	HeaderByNumber(ctx context.Context, number *big.Int) (*types.Header, error)
+
+	// Gets the header of the block with the provided hash
+	HeaderByHash(ctx context.Context, hash common.Hash) (*types.Header, error)
+}
+
+// This is an Execution client binding that can read the nonce of an account
+type INonceReader interface {
+	// Gets the nonce (transaction count) of an account, typically using eth_getTransactionCount
+	NonceAt(ctx context.Context, account common.Address, blockNumber *big.Int) (uint64, error)
+}
+
+// This is an Execution client binding that can read the code of an account
+type ICodeReader interface {
+	// Gets the runtime code of an account, typically using eth_getCode
+	CodeAt(ctx context.Context, account common.Address, blockNumber *big.Int) ([]byte, error)
+}
+
+// This is an Execution client binding that can read the code of an account at a specific block hash (EIP-1898)
+type ICodeReaderAtHash interface {
+	// Gets the runtime code of an account at the block with the provided hash, typically using eth_getCode
+	CodeAtHash(ctx context.Context, account common.Address, blockHash common.Hash) ([]byte, error)
+}
+
+// This is an Execution client binding that can get the number of the latest block
+type IBlockNumberReader interface {
+	// Gets the number of the latest block, typically using eth_blockNumber
+	BlockNumber(ctx context.Context) (uint64, error)
+}
+
+// This is an Execution client binding that can read raw contract storage
+type IStorageReader interface {
+	// Gets the value of a storage slot of an account, typically using eth_getStorageAt
+	StorageAt(ctx context.Context, account common.Address, key common.Hash, blockNumber *big.Int) ([]byte, error)
+}
+
+// This is an Execution client binding that can read raw contract storage at a specific block hash (EIP-1898)
+type IStorageReaderAtHash interface {
+	// Gets the value of a storage slot of an account at the block with the provided hash, typically using eth_getStorageAt
+	StorageAtHash(ctx context.Context, account common.Address, key common.Hash, blockHash common.Hash) ([]byte, error)
+}
+
+// This is an Execution client binding that can read raw contract storage from the pending block.
+// Storage readers that implement this can read the state after the node's currently pending transactions.
+type IPendingStorageReader interface {
+	// Gets the value of a storage slot of an account in the pending block, typically using eth_getStorageAt
+	PendingStorageAt(ctx context.Context, account common.Address, key common.Hash) ([]byte, error)
+}
+
+// This is an Execution client binding that can send several JSON-RPC requests in a single batch request.
+// Batchers that need many separate RPC calls use this when the client supports it, instead of sending each request on its own.
+type IBatchCaller interface {
+	// Sends all of the requests in a single JSON-RPC batch, storing each one's result or error in its element
+	BatchCallContext(ctx context.Context, b []rpc.BatchElem) error
+}
+
+// This is a non-RPC source of account data, such as an Etherscan-compatible REST API.
+// It's only used as a fallback when no Execution client can be reached, so it only needs to serve the latest block.
+type IFallbackProvider interface {
+	// Gets the ETH balances of the addresses at the latest block, in the same order as the addresses
+	GetEthBalances(ctx context.Context, addresses []common.Address) ([]*big.Int, error)
+
+	// Gets the nonces (transaction counts) of the addresses at the latest block, in the same order as the addresses
+	GetNonces(ctx context.Context, addresses []common.Address) ([]uint64, error)
+}
@@ -0,0 +1,91 @@
+package batchquery
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// A single contract call in a batch
+type Call struct {
+	// The contract address of the target to run the call on
+	Target common.Address
+
+	// The name of the method being called, or its signature if it was added with AddBySignature
+	Method string
+
+	// The key the call's result can be looked up by in the batch's Results ("" = only by index)
+	Key string
+
+	// The packed call data, which is nil if packing failed
+	callData []byte
+
+	// The error from packing the call data, which fails the batch when it's run (nil = packed successfully)
+	packErr error
+
+	// The output the response is unpacked into (nil = the response is only kept in the call's result)
+	output any
+
+	// The name of the method in the ABI, which differs from Method for calls added by signature
+	abiMethod string
+
+	// The ABI of the contract being called, used to unpack its response and decode its custom errors
+	contractAbi *abi.ABI
+}
+
+// Sets the key the call's result can be looked up by in the batch's Results, returning the call so it can be chained after Add
+func (c *Call) WithKey(key string) *Call {
+	c.Key = key
+	return c
+}
+
+// Creates a new contract call
+func newCall(contractAddress common.Address, contractAbi *abi.ABI, output any, method string, args ...any) *Call {
+	call := &Call{
+		Target:      contractAddress,
+		Method:      method,
+		output:      output,
+		abiMethod:   method,
+		contractAbi: contractAbi,
+	}
+	callData, err := contractAbi.Pack(method, args...)
+	if err != nil {
+		call.packErr = fmt.Errorf("error packing data for call [%s] on contract %s: %w", method, contractAddress.Hex(), err)
+		return call
+	}
+	call.callData = callData
+	return call
+}
+
+// Creates a new contract call for the method with the provided canonical signature
+func newCallBySignature(contractAddress common.Address, contractAbi *abi.ABI, output any, signature string, args ...any) *Call {
+	signature = strings.ReplaceAll(signature, " ", "")
+	for name, method := range contractAbi.Methods {
+		if method.Sig == signature {
+			call := newCall(contractAddress, contractAbi, output, name, args...)
+			call.Method = signature
+			return call
+		}
+	}
+	return &Call{
+		Target:      contractAddress,
+		Method:      signature,
+		output:      output,
+		contractAbi: contractAbi,
+		packErr:     fmt.Errorf("error packing data for call [%s] on contract %s: method with signature '%s' not found", signature, contractAddress.Hex(), signature),
+	}
+}
+
+// Unpacks the call's response into its output, if it has one
+func (c *Call) unpack(data []byte) error {
+	if c.output == nil {
+		return nil
+	}
+	err := c.contractAbi.UnpackIntoInterface(c.output, c.abiMethod, data)
+	if err != nil {
+		return fmt.Errorf("error unpacking response for contract %s, method %s: %w", c.Target.Hex(), c.Method, wrapUnpackError(err))
+	}
+	return nil
+}
@@ -0,0 +1,439 @@
+// Package batchquery is version 2 of the batch-query API.
+// Every method takes a context first, settings are provided as options, batches return detailed Results instead of success flags,
+// failures are reported with typed errors, and the canonical Multicall3 deployment is used by default.
+package batchquery
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// Caller batches multiple arbitrary contract calls into as few multicalls as possible and runs them together.
+// It collects the calls of one batch at a time, so separate goroutines should each use their own Caller.
+type Caller struct {
+	// The Execution client binding
+	client IContractCaller
+
+	// The settings the Caller was created with
+	settings settings
+
+	// The calls added since the last run
+	calls []*Call
+}
+
+// A call in the form Multicall3's tryAggregate takes it
+type multicall3Call struct {
+	// The contract address of the call's target
+	Target common.Address
+
+	// The packed call data
+	CallData []byte
+}
+
+// A call's response in the form Multicall3's tryAggregate returns it
+type multicall3Result struct {
+	// Whether or not the call worked
+	Success bool
+
+	// The return data of the call if it worked, or its revert data if it didn't
+	ReturnData []byte
+}
+
+// The response of a single call
+type callResponse struct {
+	// Whether or not the call worked
+	success bool
+
+	// The return data of the call if it worked, or its revert data if it didn't
+	returnData []byte
+}
+
+// Creates a new Caller for the provided client.
+// It uses the canonical Multicall3 deployment unless the options provide another multicall contract.
+func New(client IContractCaller, options ...Option) (*Caller, error) {
+	if client == nil {
+		return nil, fmt.Errorf("a client is required")
+	}
+	s := settings{
+		multicallAddress: Multicall3Address,
+	}
+	for _, option := range options {
+		option(&s)
+	}
+	if s.batchSize < 0 || s.threadLimit < 0 || s.chunkTimeout < 0 {
+		return nil, fmt.Errorf("the batch size, thread limit, and chunk timeout can't be negative")
+	}
+	if s.hedgeClient != nil && s.hedgeDelay < 0 {
+		return nil, fmt.Errorf("the hedging delay can't be negative")
+	}
+	return &Caller{
+		client:   client,
+		settings: s,
+	}, nil
+}
+
+// Adds a contract call to the batch, which will unpack its response into output if it succeeds (nil = only keep the response in its result)
+func (c *Caller) Add(contractAddress common.Address, contractAbi *abi.ABI, output any, method string, args ...any) *Call {
+	call := newCall(contractAddress, contractAbi, output, method, args...)
+	c.calls = append(c.calls, call)
+	return call
+}
+
+// Adds a contract call to the batch like Add, but identifies the method by its full canonical signature
+// (such as "safeTransferFrom(address,address,uint256)") so overloaded methods can be targeted unambiguously
+func (c *Caller) AddBySignature(contractAddress common.Address, contractAbi *abi.ABI, output any, signature string, args ...any) *Call {
+	call := newCallBySignature(contractAddress, contractAbi, output, signature, args...)
+	c.calls = append(c.calls, call)
+	return call
+}
+
+// Runs every call added since the last run and returns their results, in the order the calls were added.
+// Reverted calls are reported through their results unless the RequireSuccess option is provided, in which case the batch fails with an *ErrCallReverted.
+// If some responses can't be unpacked, the results are still returned along with a *MultiError describing them.
+func (c *Caller) Run(ctx context.Context, options ...CallOption) (*Results, error) {
+	calls := c.calls
+	c.calls = nil
+	responses, err := c.execute(ctx, calls, newCallSettings(options))
+	if err != nil {
+		return nil, err
+	}
+	return unpackResults(calls, responses)
+}
+
+// Gets the ETH balance of each of the provided addresses, in the same order, using the multicall contract's getEthBalance function.
+// The balances are read in their own batch, so this doesn't affect the calls added to the Caller.
+func (c *Caller) EthBalances(ctx context.Context, addresses []common.Address, options ...CallOption) ([]*big.Int, error) {
+	balances := make([]*big.Int, len(addresses))
+	calls := make([]*Call, len(addresses))
+	for i, address := range addresses {
+		calls[i] = newCall(c.settings.multicallAddress, &multicall3Abi, &balances[i], "getEthBalance", address)
+	}
+	s := newCallSettings(options)
+	s.requireSuccess = true
+	responses, err := c.execute(ctx, calls, s)
+	if err != nil {
+		return nil, fmt.Errorf("error getting ETH balances: %w", err)
+	}
+	_, err = unpackResults(calls, responses)
+	if err != nil {
+		return nil, fmt.Errorf("error getting ETH balances: %w", err)
+	}
+	return balances, nil
+}
+
+// Unpacks the response of each call that worked into its output, returning the results along with a *MultiError if any of them couldn't be unpacked
+func unpackResults(calls []*Call, responses []callResponse) (*Results, error) {
+	var unpackErrs []error
+	multiErr := &MultiError{}
+	for i, call := range calls {
+		if !responses[i].success {
+			continue
+		}
+		err := call.unpack(responses[i].returnData)
+		if err == nil {
+			continue
+		}
+		if unpackErrs == nil {
+			unpackErrs = make([]error, len(calls))
+		}
+		unpackErrs[i] = err
+		multiErr.Errors = append(multiErr.Errors, &CallError{
+			Index:  i,
+			Target: call.Target,
+			Method: call.Method,
+			Err:    err,
+		})
+	}
+	results := newResults(calls, responses, unpackErrs)
+	if len(multiErr.Errors) > 0 {
+		return results, multiErr
+	}
+	return results, nil
+}
+
+// Runs the calls and gets their responses, in the same order.
+// Calls with a sender run individually; the rest are aggregated into multicalls of up to the batch size each.
+func (c *Caller) execute(ctx context.Context, calls []*Call, s callSettings) ([]callResponse, error) {
+	for _, call := range calls {
+		if call.packErr != nil {
+			return nil, call.packErr
+		}
+	}
+
+	// Identical calls only run once if duplicates are merged, and share the response
+	unique := calls
+	var indices []int
+	if c.settings.mergeDuplicates {
+		unique, indices = mergeDuplicates(calls)
+	}
+
+	var responses []callResponse
+	var err error
+	if s.from != (common.Address{}) {
+		responses, err = c.runDirectly(ctx, unique, s)
+	} else {
+		responses, err = c.runMulticalls(ctx, unique, s)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if indices != nil {
+		merged := responses
+		responses = make([]callResponse, len(calls))
+		for i, index := range indices {
+			responses[i] = merged[index]
+		}
+	}
+
+	if s.requireSuccess {
+		for i, response := range responses {
+			if !response.success {
+				return nil, &ErrCallReverted{
+					Index:       i,
+					Target:      calls[i].Target,
+					Method:      calls[i].Method,
+					Data:        response.returnData,
+					contractAbi: calls[i].contractAbi,
+				}
+			}
+		}
+	}
+	return responses, nil
+}
+
+// Gets the distinct calls in a list, along with the index of each call's distinct counterpart
+func mergeDuplicates(calls []*Call) ([]*Call, []int) {
+	unique := []*Call{}
+	indices := make([]int, len(calls))
+	seen := map[string]int{}
+	for i, call := range calls {
+		key := string(call.Target.Bytes()) + string(call.callData)
+		index, exists := seen[key]
+		if !exists {
+			index = len(unique)
+			seen[key] = index
+			unique = append(unique, call)
+		}
+		indices[i] = index
+	}
+	return unique, indices
+}
+
+// Runs the calls in multicalls of up to the batch size each, using tryAggregate so a revert doesn't fail the rest of its multicall
+func (c *Caller) runMulticalls(ctx context.Context, calls []*Call, s callSettings) ([]callResponse, error) {
+	responses := make([]callResponse, len(calls))
+	batchSize := c.settings.batchSize
+	if batchSize == 0 || batchSize > len(calls) {
+		batchSize = len(calls)
+	}
+	if batchSize == 0 {
+		return responses, nil
+	}
+
+	count := (len(calls) + batchSize - 1) / batchSize
+	err := c.runConcurrently(ctx, count, func(ctx context.Context, index int) error {
+		start := index * batchSize
+		end := start + batchSize
+		if end > len(calls) {
+			end = len(calls)
+		}
+		return c.runMulticall(ctx, calls[start:end], s, responses[start:end])
+	})
+	if err != nil {
+		return nil, err
+	}
+	return responses, nil
+}
+
+// Runs a chunk of calls in a single multicall, storing their responses in the provided slice
+func (c *Caller) runMulticall(ctx context.Context, calls []*Call, s callSettings, responses []callResponse) error {
+	aggregated := make([]multicall3Call, len(calls))
+	for i, call := range calls {
+		aggregated[i] = multicall3Call{
+			Target:   call.Target,
+			CallData: call.callData,
+		}
+	}
+	callData, err := multicall3Abi.Pack("tryAggregate", false, aggregated)
+	if err != nil {
+		return fmt.Errorf("error packing multicall data: %w", err)
+	}
+
+	msg := ethereum.CallMsg{
+		To:   &c.settings.multicallAddress,
+		Data: callData,
+	}
+	out, err := c.call(ctx, msg, s)
+	if err != nil {
+		return fmt.Errorf("error running multicall of %d calls at %s: %w", len(calls), s.blockDescription(), wrapClientError(err))
+	}
+	if len(out) == 0 {
+		return fmt.Errorf("error running multicall at %s: %w", c.settings.multicallAddress.Hex(), ErrMulticallNotFound)
+	}
+
+	values, err := multicall3Abi.Unpack("tryAggregate", out)
+	if err != nil {
+		return fmt.Errorf("error unpacking multicall response: %w", wrapUnpackError(err))
+	}
+	results := *abi.ConvertType(values[0], new([]multicall3Result)).(*[]multicall3Result)
+	if len(results) != len(calls) {
+		return fmt.Errorf("error unpacking multicall response: %w", wrapUnpackError(fmt.Errorf("expected %d results, got %d", len(calls), len(results))))
+	}
+	for i, result := range results {
+		responses[i] = callResponse{
+			success:    result.Success,
+			returnData: result.ReturnData,
+		}
+	}
+	return nil
+}
+
+// Runs each call as its own eth_call with the batch's sender, since the multicall contract would be the sender of the calls it aggregates
+func (c *Caller) runDirectly(ctx context.Context, calls []*Call, s callSettings) ([]callResponse, error) {
+	responses := make([]callResponse, len(calls))
+	err := c.runConcurrently(ctx, len(calls), func(ctx context.Context, index int) error {
+		call := calls[index]
+		msg := ethereum.CallMsg{
+			From: s.from,
+			To:   &call.Target,
+			Data: call.callData,
+		}
+		out, err := c.call(ctx, msg, s)
+		if err == nil {
+			responses[index] = callResponse{
+				success:    true,
+				returnData: out,
+			}
+			return nil
+		}
+		revertData, isRevert := getRevertData(err)
+		if !isRevert {
+			return fmt.Errorf("error calling contract %s, method %s: %w", call.Target.Hex(), call.Method, wrapClientError(err))
+		}
+		responses[index] = callResponse{
+			returnData: revertData,
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return responses, nil
+}
+
+// Runs count tasks with up to the thread limit running at once, each within the chunk timeout.
+// A failure in any task cancels the rest of them, and the first failure is returned.
+func (c *Caller) runConcurrently(ctx context.Context, count int, task func(ctx context.Context, index int) error) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	threadLimit := c.settings.threadLimit
+	if threadLimit == 0 || threadLimit > count {
+		threadLimit = count
+	}
+
+	var wg sync.WaitGroup
+	var once sync.Once
+	var firstErr error
+	semaphore := make(chan struct{}, threadLimit)
+	for i := 0; i < count; i++ {
+		select {
+		case semaphore <- struct{}{}:
+		case <-ctx.Done():
+		}
+		if ctx.Err() != nil {
+			break
+		}
+		wg.Add(1)
+		go func(index int) {
+			defer wg.Done()
+			defer func() { <-semaphore }()
+			taskCtx := ctx
+			if c.settings.chunkTimeout > 0 {
+				var cancelTask context.CancelFunc
+				taskCtx, cancelTask = context.WithTimeout(ctx, c.settings.chunkTimeout)
+				defer cancelTask()
+			}
+			err := task(taskCtx, index)
+			if err != nil {
+				once.Do(func() {
+					firstErr = err
+					cancel()
+				})
+			}
+		}(i)
+	}
+	wg.Wait()
+	if firstErr != nil {
+		return firstErr
+	}
+	return ctx.Err()
+}
+
+// Sends an eth_call at the batch's block. If hedging is enabled, it's also sent to the hedge client when the Caller's client hasn't responded
+// within the delay or fails with something other than a revert, and whichever response succeeds first is used.
+func (c *Caller) call(ctx context.Context, msg ethereum.CallMsg, s callSettings) ([]byte, error) {
+	if c.settings.hedgeClient == nil {
+		return s.callContract(ctx, c.client, msg)
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	// A hedged call's result
+	type response struct {
+		out []byte
+		err error
+	}
+	responses := make(chan response, 2)
+	send := func(client IContractCaller) {
+		go func() {
+			out, err := s.callContract(ctx, client, msg)
+			responses <- response{out, err}
+		}()
+	}
+	send(c.client)
+	timer := time.NewTimer(c.settings.hedgeDelay)
+	defer timer.Stop()
+
+	pending := 1
+	hedged := false
+	var firstErr error
+	for {
+		select {
+		case <-timer.C:
+			if !hedged {
+				hedged = true
+				pending++
+				send(c.settings.hedgeClient)
+			}
+		case r := <-responses:
+			pending--
+			if r.err == nil {
+				return r.out, nil
+			}
+
+			// Reverts are the same on every client, so there's no point waiting for the other one
+			if _, isRevert := getRevertData(r.err); isRevert {
+				return nil, r.err
+			}
+			if firstErr == nil {
+				firstErr = r.err
+			}
+			if !hedged {
+				hedged = true
+				pending++
+				send(c.settings.hedgeClient)
+				continue
+			}
+			if pending == 0 {
+				return nil, firstErr
+			}
+		}
+	}
+}
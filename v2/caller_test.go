@@ -0,0 +1,162 @@
+package batchquery
+
+import (
+	"context"
+	"errors"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// Creates a Caller backed by a mock client
+func newTestCaller(t *testing.T, options ...Option) (*Caller, *mockClient) {
+	client := &mockClient{
+		multicallAddress: Multicall3Address,
+	}
+	caller, err := New(client, options...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return caller, client
+}
+
+func TestCallerRun(t *testing.T) {
+	caller, client := newTestCaller(t)
+	account := common.HexToAddress("0x05")
+	var balance *big.Int
+	var boom *big.Int
+	caller.Add(testTokenAddress, &testTokenAbi, &balance, "balanceOf", account).WithKey("balance")
+	caller.Add(testTokenAddress, &testTokenAbi, &boom, "boom")
+	results, err := caller.Run(context.Background(), AtBlock(big.NewInt(20)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if client.calls != 1 || client.lastBlock.Int64() != 20 {
+		t.Fatalf("expected a single multicall at block 20, got %d calls at %v", client.calls, client.lastBlock)
+	}
+
+	result, exists := results.Get("balance")
+	if !exists || !result.Success || balance.Cmp(account.Big()) != 0 {
+		t.Fatalf("unexpected balance result %+v (balance %v)", result, balance)
+	}
+	result = results.At(1)
+	if result.Success || result.RevertReason() != "InsufficientBalance(1)" {
+		t.Fatalf("expected the custom error to be decoded, got %+v", result)
+	}
+	if len(results.Failures()) != 1 || !errors.Is(results.Err(), result.Err) {
+		t.Fatalf("expected the revert to be the only failure, got %v", results.Err())
+	}
+
+	// The batch was cleared, so only the new call runs
+	caller.Add(testTokenAddress, &testTokenAbi, &boom, "boom")
+	_, err = caller.Run(context.Background(), RequireSuccess())
+	var reverted *ErrCallReverted
+	if !errors.As(err, &reverted) || reverted.Index != 0 || reverted.Reason() != "InsufficientBalance(1)" {
+		t.Fatalf("expected the batch to fail with the decoded revert, got %v", err)
+	}
+}
+
+func TestCallerErrors(t *testing.T) {
+	caller, client := newTestCaller(t)
+
+	// Responses that can't be unpacked are reported together, without failing the other calls
+	var wrong string
+	var balance *big.Int
+	caller.Add(testTokenAddress, &testTokenAbi, &wrong, "balanceOf", common.HexToAddress("0x01"))
+	caller.Add(testTokenAddress, &testTokenAbi, &balance, "balanceOf", common.HexToAddress("0x02"))
+	results, err := caller.Run(context.Background())
+	var multiErr *MultiError
+	if !errors.As(err, &multiErr) || len(multiErr.Errors) != 1 || multiErr.Errors[0].Index != 0 || !errors.Is(err, ErrUnpackFailed) {
+		t.Fatalf("expected a MultiError for the first call, got %v", err)
+	}
+	if results == nil || results.At(0).Err == nil || balance.Cmp(big.NewInt(2)) != 0 {
+		t.Fatalf("expected the results to be returned with the failure, got %+v", results)
+	}
+
+	// A call that can't be packed fails the batch before anything is sent
+	caller.Add(testTokenAddress, &testTokenAbi, nil, "balanceOf")
+	_, err = caller.Run(context.Background())
+	if err == nil || client.calls != 1 {
+		t.Fatalf("expected the batch to fail without a request, got %v after %d calls", err, client.calls)
+	}
+	caller.AddBySignature(testTokenAddress, &testTokenAbi, nil, "balanceOf(uint256)", big.NewInt(1))
+	_, err = caller.Run(context.Background())
+	if err == nil {
+		t.Fatal("expected an unknown signature to fail the batch")
+	}
+
+	// A missing multicall contract returns nothing
+	client.noMulticall = true
+	caller.Add(testTokenAddress, &testTokenAbi, nil, "boom")
+	_, err = caller.Run(context.Background())
+	if !errors.Is(err, ErrMulticallNotFound) {
+		t.Fatalf("expected ErrMulticallNotFound, got %v", err)
+	}
+
+	// Blocks the client can't target are rejected
+	caller.Add(testTokenAddress, &testTokenAbi, nil, "boom")
+	_, err = caller.Run(context.Background(), AtBlockHash(common.HexToHash("0x01")))
+	if err == nil {
+		t.Fatal("expected a call at a block hash to fail on a client without support for it")
+	}
+}
+
+func TestCallerOptions(t *testing.T) {
+	multicallAddress := common.HexToAddress("0x1111111111111111111111111111111111111111")
+	client := &mockClient{
+		multicallAddress: multicallAddress,
+	}
+	caller, err := New(client, WithMulticallAddress(multicallAddress), WithBatchSize(2), WithThreadLimit(1), WithMergedDuplicates(), WithHedging(client, time.Second))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	addresses := []common.Address{common.HexToAddress("0x01"), common.HexToAddress("0x02"), common.HexToAddress("0x03")}
+	balances, err := caller.EthBalances(context.Background(), addresses)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, address := range addresses {
+		expected := new(big.Int).Add(address.Big(), testEthBalanceGap)
+		if balances[i].Cmp(expected) != 0 {
+			t.Fatalf("expected balance %s for %s, got %s", expected, address.Hex(), balances[i])
+		}
+	}
+	if client.calls != 2 {
+		t.Fatalf("expected the balances to be split into 2 chunks at the custom multicall address, got %d calls", client.calls)
+	}
+
+	// Identical calls only run once
+	client.calls = 0
+	client.aggregatedCalls = 0
+	var first, second *big.Int
+	account := common.HexToAddress("0x07")
+	caller.Add(testTokenAddress, &testTokenAbi, &first, "balanceOf", account)
+	caller.Add(testTokenAddress, &testTokenAbi, &second, "balanceOf", account)
+	_, err = caller.Run(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if client.aggregatedCalls != 1 || first.Cmp(account.Big()) != 0 || second.Cmp(account.Big()) != 0 {
+		t.Fatalf("expected the duplicate to share one call, got %d aggregated calls", client.aggregatedCalls)
+	}
+
+	// A sender runs each call directly
+	client.calls = 0
+	caller.Add(testTokenAddress, &testTokenAbi, &first, "balanceOf", account)
+	caller.Add(testTokenAddress, &testTokenAbi, nil, "boom")
+	results, err := caller.Run(context.Background(), From(common.HexToAddress("0x09")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if client.calls != 2 || !results.At(0).Success || results.At(1).RevertReason() != "InsufficientBalance(1)" {
+		t.Fatalf("expected 2 direct calls with the revert decoded, got %d calls", client.calls)
+	}
+
+	_, err = New(client, WithBatchSize(-1))
+	if err == nil {
+		t.Fatal("expected a negative batch size to be rejected")
+	}
+}
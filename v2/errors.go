@@ -0,0 +1,256 @@
+package batchquery
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+var (
+	// The selector of Solidity's Error(string) revert
+	errorSelector = crypto.Keccak256([]byte("Error(string)"))[:4]
+
+	// The selector of Solidity's Panic(uint256) revert
+	panicSelector = crypto.Keccak256([]byte("Panic(uint256)"))[:4]
+)
+
+// The typed errors that batches fail with, which can be matched with errors.Is and errors.As
+var (
+	// The client rejected a request because it was too large, such as a multicall whose payload, response, or gas usage exceeded the provider's limits.
+	// A smaller WithBatchSize usually resolves it.
+	ErrBatchTooLarge = errors.New("batch is too large for the client")
+
+	// A response couldn't be decoded, either from the multicall contract or into a call's output
+	ErrUnpackFailed = errors.New("error unpacking response")
+
+	// A request to the Execution client failed, such as a transport error or an error returned by the node
+	ErrClientFailure = errors.New("execution client request failed")
+
+	// The multicall contract returned nothing, which means there's no contract deployed at its address on the chain (or at the block)
+	ErrMulticallNotFound = errors.New("no multicall contract was found on the chain")
+)
+
+// A call reverted while the batch required every call to succeed
+type ErrCallReverted struct {
+	// The index of the call within the batch
+	Index int
+
+	// The contract address of the call's target
+	Target common.Address
+
+	// The name of the method that was called
+	Method string
+
+	// The revert data, if the client provided it
+	Data []byte
+
+	// The ABI of the contract that was called, used to decode its custom errors (nil = unknown)
+	contractAbi *abi.ABI
+}
+
+// Gets the error message
+func (e *ErrCallReverted) Error() string {
+	message := fmt.Sprintf("call %d to contract %s, method %s reverted", e.Index, e.Target.Hex(), e.Method)
+	reason := e.Reason()
+	if reason != "" {
+		message += ": " + reason
+	}
+	return message
+}
+
+// Gets the decoded revert reason, or an empty string if the client didn't provide the revert data
+func (e *ErrCallReverted) Reason() string {
+	return DecodeRevertReason(e.Data, e.contractAbi)
+}
+
+// The failure of a single call within a batch
+type CallError struct {
+	// The index of the call within the batch
+	Index int
+
+	// The contract address of the call's target
+	Target common.Address
+
+	// The name of the method that was called
+	Method string
+
+	// The reason the call failed, which matches ErrUnpackFailed if its response couldn't be unpacked
+	Err error
+}
+
+// Gets the error message
+func (e *CallError) Error() string {
+	return e.Err.Error()
+}
+
+// Gets the underlying error
+func (e *CallError) Unwrap() error {
+	return e.Err
+}
+
+// The failures of every call in a batch whose response couldn't be unpacked, in order of their index.
+// Reverted calls aren't included, since they're reported through their results.
+type MultiError struct {
+	// The individual failures
+	Errors []*CallError
+}
+
+// Gets the error message, which lists every failure
+func (e *MultiError) Error() string {
+	if len(e.Errors) == 1 {
+		return e.Errors[0].Error()
+	}
+	messages := make([]string, len(e.Errors))
+	for i, err := range e.Errors {
+		messages[i] = err.Error()
+	}
+	return fmt.Sprintf("%d calls failed: %s", len(e.Errors), strings.Join(messages, "; "))
+}
+
+// Gets the individual failures, so errors.Is and errors.As match any of them
+func (e *MultiError) Unwrap() []error {
+	errs := make([]error, len(e.Errors))
+	for i, err := range e.Errors {
+		errs[i] = err
+	}
+	return errs
+}
+
+// Decodes the reason from a call's revert data.
+// Supports Error(string) and Panic(uint256), and custom errors defined in the provided ABI, which are described by their name and arguments
+// (such as "InsufficientBalance(5, 10)"). Other custom errors are described by their selector.
+// Returns an empty string if there's no revert data.
+func DecodeRevertReason(data []byte, contractAbi *abi.ABI) string {
+	if len(data) == 0 {
+		return ""
+	}
+	if len(data) < 4 {
+		return fmt.Sprintf("invalid revert data %s", hexutil.Encode(data))
+	}
+	if bytes.Equal(data[:4], errorSelector) {
+		reason, err := abi.UnpackRevert(data)
+		if err != nil {
+			return fmt.Sprintf("malformed revert reason %s", hexutil.Encode(data))
+		}
+		return reason
+	}
+	if bytes.Equal(data[:4], panicSelector) && len(data) == 4+common.HashLength {
+		code := new(big.Int).SetBytes(data[4:])
+		return fmt.Sprintf("panic code 0x%x", code)
+	}
+	if contractAbi != nil {
+		var selector [4]byte
+		copy(selector[:], data[:4])
+		abiError, err := contractAbi.ErrorByID(selector)
+		if err == nil {
+			values, err := abiError.Unpack(data)
+			if err != nil {
+				return fmt.Sprintf("malformed custom error %s: %s", abiError.Name, hexutil.Encode(data))
+			}
+			args, _ := values.([]any)
+			formatted := make([]string, len(args))
+			for i, arg := range args {
+				formatted[i] = fmt.Sprint(arg)
+			}
+			return fmt.Sprintf("%s(%s)", abiError.Name, strings.Join(formatted, ", "))
+		}
+	}
+	return fmt.Sprintf("custom error %s", hexutil.Encode(data[:4]))
+}
+
+// An error that matches one or more of the package's sentinel errors with errors.Is, in addition to the original error
+type sentinelError struct {
+	// The original error
+	err error
+
+	// The sentinel errors this one matches
+	sentinels []error
+}
+
+// Gets the error message, which is the same as the original error's
+func (e *sentinelError) Error() string {
+	return e.err.Error()
+}
+
+// Gets the errors this one matches with errors.Is and errors.As
+func (e *sentinelError) Unwrap() []error {
+	return append(e.sentinels[:len(e.sentinels):len(e.sentinels)], e.err)
+}
+
+// Messages that providers use when rejecting a request for being too large
+var tooLargeMessages = []string{
+	"too large",
+	"exceeds the limit",
+	"exceeds limit",
+	"size limit",
+	"out of gas",
+	"gas required exceeds",
+	"response size exceeded",
+}
+
+// Wraps an error from the Execution client so it matches ErrClientFailure, and ErrBatchTooLarge if the provider rejected the request's size.
+// Context cancellation is passed through as-is, since it doesn't indicate a problem with the client.
+func wrapClientError(err error) error {
+	if err == nil || errors.Is(err, ErrClientFailure) || errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return err
+	}
+	sentinels := []error{ErrClientFailure}
+	tooLarge := false
+	var httpErr rpc.HTTPError
+	if errors.As(err, &httpErr) && httpErr.StatusCode == http.StatusRequestEntityTooLarge {
+		tooLarge = true
+	} else {
+		message := strings.ToLower(err.Error())
+		for _, tooLargeMessage := range tooLargeMessages {
+			if strings.Contains(message, tooLargeMessage) {
+				tooLarge = true
+				break
+			}
+		}
+	}
+	if tooLarge {
+		sentinels = append(sentinels, ErrBatchTooLarge)
+	}
+	return &sentinelError{
+		err:       err,
+		sentinels: sentinels,
+	}
+}
+
+// Wraps an error from decoding a response so it matches ErrUnpackFailed
+func wrapUnpackError(err error) error {
+	if err == nil || errors.Is(err, ErrUnpackFailed) {
+		return err
+	}
+	return &sentinelError{
+		err:       err,
+		sentinels: []error{ErrUnpackFailed},
+	}
+}
+
+// Checks whether an error from an eth_call was caused by the call reverting, and gets the revert data if the client provided it
+func getRevertData(err error) ([]byte, bool) {
+	var dataErr rpc.DataError
+	if errors.As(err, &dataErr) {
+		if hexData, ok := dataErr.ErrorData().(string); ok {
+			data, decodeErr := hexutil.Decode(hexData)
+			if decodeErr == nil {
+				return data, true
+			}
+		}
+	}
+	if strings.Contains(err.Error(), "execution reverted") {
+		return nil, true
+	}
+	return nil, false
+}
@@ -0,0 +1,145 @@
+package batchquery
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"math/big"
+	"sync"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+)
+
+const testMulticallAbiString = `[
+	{"inputs":[{"name":"requireSuccess","type":"bool"},{"components":[{"name":"target","type":"address"},{"name":"callData","type":"bytes"}],"name":"calls","type":"tuple[]"}],"name":"tryAggregate","outputs":[{"components":[{"name":"success","type":"bool"},{"name":"returnData","type":"bytes"}],"name":"returnData","type":"tuple[]"}],"stateMutability":"nonpayable","type":"function"},
+	{"inputs":[{"name":"addr","type":"address"}],"name":"getEthBalance","outputs":[{"name":"balance","type":"uint256"}],"stateMutability":"view","type":"function"}
+]`
+
+const testTokenAbiString = `[
+	{"inputs":[{"name":"account","type":"address"}],"name":"balanceOf","outputs":[{"name":"","type":"uint256"}],"stateMutability":"view","type":"function"},
+	{"inputs":[],"name":"boom","outputs":[{"name":"","type":"uint256"}],"stateMutability":"view","type":"function"},
+	{"inputs":[{"name":"available","type":"uint256"}],"name":"InsufficientBalance","type":"error"}
+]`
+
+var (
+	testTokenAddress  = common.HexToAddress("0x2222222222222222222222222222222222222222")
+	testMulticallAbi  = mustParseAbi(testMulticallAbiString)
+	testTokenAbi      = mustParseAbi(testTokenAbiString)
+	testEthBalanceGap = big.NewInt(1000)
+)
+
+// A revert error in the form returned by geth's RPC client
+type mockRevertError struct {
+	data []byte
+}
+
+func (e *mockRevertError) Error() string          { return "execution reverted" }
+func (e *mockRevertError) ErrorCode() int         { return 3 }
+func (e *mockRevertError) ErrorData() interface{} { return fmt.Sprintf("0x%x", e.data) }
+
+// A client that emulates Multicall3's tryAggregate and getEthBalance functions, and a token contract.
+// A token balance is the account's address as a number, and an ETH balance is that plus testEthBalanceGap.
+// The token's boom function reverts with InsufficientBalance(1).
+type mockClient struct {
+	// The address of the multicall contract
+	multicallAddress common.Address
+
+	// Whether the multicall contract is missing, so calls to it return nothing
+	noMulticall bool
+
+	// Guards the counters, since chunks can run concurrently
+	lock sync.Mutex
+
+	// The number of eth_calls made
+	calls int
+
+	// The number of calls aggregated by multicalls
+	aggregatedCalls int
+
+	// The block number of the last eth_call
+	lastBlock *big.Int
+}
+
+// Runs a single call against the emulated contracts
+func (m *mockClient) runCall(target common.Address, data []byte) (bool, []byte) {
+	if len(data) < 4 {
+		return false, nil
+	}
+	if target == m.multicallAddress {
+		method, err := testMulticallAbi.MethodById(data[:4])
+		if err != nil || method.Name != "getEthBalance" {
+			return false, nil
+		}
+		args, _ := method.Inputs.Unpack(data[4:])
+		account := args[0].(common.Address)
+		balance := new(big.Int).Add(account.Big(), testEthBalanceGap)
+		out, _ := method.Outputs.Pack(balance)
+		return true, out
+	}
+	if target != testTokenAddress {
+		return false, nil
+	}
+	method, err := testTokenAbi.MethodById(data[:4])
+	if err != nil {
+		return false, nil
+	}
+	switch method.Name {
+	case "balanceOf":
+		args, _ := method.Inputs.Unpack(data[4:])
+		out, _ := method.Outputs.Pack(args[0].(common.Address).Big())
+		return true, out
+	case "boom":
+		abiError := testTokenAbi.Errors["InsufficientBalance"]
+		args, _ := abiError.Inputs.Pack(big.NewInt(1))
+		return false, append(append([]byte{}, abiError.ID[:4]...), args...)
+	}
+	return false, nil
+}
+
+func (m *mockClient) CallContract(ctx context.Context, msg ethereum.CallMsg, blockNumber *big.Int) ([]byte, error) {
+	m.lock.Lock()
+	m.calls++
+	m.lastBlock = blockNumber
+	m.lock.Unlock()
+	if m.noMulticall && msg.To != nil && *msg.To == m.multicallAddress {
+		return nil, nil
+	}
+	if msg.To == nil || *msg.To != m.multicallAddress || !bytes.Equal(msg.Data[:4], testMulticallAbi.Methods["tryAggregate"].ID) {
+		success, out := m.runCall(*msg.To, msg.Data)
+		if !success {
+			return nil, &mockRevertError{data: out}
+		}
+		return out, nil
+	}
+
+	method := testMulticallAbi.Methods["tryAggregate"]
+	args, err := method.Inputs.Unpack(msg.Data[4:])
+	if err != nil {
+		return nil, err
+	}
+	requireSuccess := args[0].(bool)
+	var calls []struct {
+		Target   common.Address
+		CallData []byte
+	}
+	abi.ConvertType(args[1], &calls)
+	m.lock.Lock()
+	m.aggregatedCalls += len(calls)
+	m.lock.Unlock()
+
+	type result struct {
+		Success    bool
+		ReturnData []byte
+	}
+	results := make([]result, len(calls))
+	for i, call := range calls {
+		success, out := m.runCall(call.Target, call.CallData)
+		if !success && requireSuccess {
+			return nil, &mockRevertError{data: out}
+		}
+		results[i] = result{Success: success, ReturnData: out}
+	}
+	return method.Outputs.Pack(results)
+}
@@ -0,0 +1,188 @@
+package batchquery
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+	"time"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// The settings a Caller is created with
+type settings struct {
+	// The address of the multicall contract
+	multicallAddress common.Address
+
+	// The maximum number of calls to include in a single multicall (0 = no limit)
+	batchSize int
+
+	// The maximum number of multicalls to run at once (0 = no limit)
+	threadLimit int
+
+	// How long each multicall may take before it's cancelled (0 = no limit beyond the context's deadline)
+	chunkTimeout time.Duration
+
+	// Whether identical calls within a batch only run once
+	mergeDuplicates bool
+
+	// The client each multicall is also sent to if the Caller's client is slow or fails (nil = no hedging)
+	hedgeClient IContractCaller
+
+	// How long to wait for the Caller's client before also sending a multicall to the hedge client
+	hedgeDelay time.Duration
+}
+
+// An option for creating a Caller
+type Option func(s *settings)
+
+// Uses the multicall contract at the provided address instead of the canonical Multicall3 deployment.
+// The contract must implement Multicall3's tryAggregate and getEthBalance functions.
+func WithMulticallAddress(address common.Address) Option {
+	return func(s *settings) {
+		s.multicallAddress = address
+	}
+}
+
+// Sets the maximum number of calls to include in a single multicall (0 = no limit)
+func WithBatchSize(batchSize int) Option {
+	return func(s *settings) {
+		s.batchSize = batchSize
+	}
+}
+
+// Sets the maximum number of multicalls to run at once when a batch is split into chunks (0 = no limit)
+func WithThreadLimit(threadLimit int) Option {
+	return func(s *settings) {
+		s.threadLimit = threadLimit
+	}
+}
+
+// Sets how long each multicall may take before it's cancelled (0 = no limit beyond the context's deadline)
+func WithChunkTimeout(timeout time.Duration) Option {
+	return func(s *settings) {
+		s.chunkTimeout = timeout
+	}
+}
+
+// Runs identical calls within a batch only once, sharing the response between them
+func WithMergedDuplicates() Option {
+	return func(s *settings) {
+		s.mergeDuplicates = true
+	}
+}
+
+// Sends each multicall to a second client as well if the Caller's client hasn't responded within the delay (or fails), using whichever responds first,
+// to cut the tail latency caused by slow providers
+func WithHedging(client IContractCaller, delay time.Duration) Option {
+	return func(s *settings) {
+		s.hedgeClient = client
+		s.hedgeDelay = delay
+	}
+}
+
+// The settings a batch is run with
+type callSettings struct {
+	// Whether every call must succeed, failing the whole batch if one reverts
+	requireSuccess bool
+
+	// The sender of the calls (zero = none)
+	from common.Address
+
+	// The block to run the calls at (nil = latest)
+	blockNumber *big.Int
+
+	// The hash of the block to run the calls at, which takes precedence over the block number (nil = none)
+	blockHash *common.Hash
+
+	// Whether to run the calls against the pending block
+	pending bool
+}
+
+// An option for running a batch
+type CallOption func(s *callSettings)
+
+// Fails the whole batch with an *ErrCallReverted if any call reverts.
+// Without it, reverted calls are reported through their results instead.
+func RequireSuccess() CallOption {
+	return func(s *callSettings) {
+		s.requireSuccess = true
+	}
+}
+
+// Runs the calls at the block with the provided number
+func AtBlock(blockNumber *big.Int) CallOption {
+	return func(s *callSettings) {
+		s.blockNumber = blockNumber
+		s.blockHash = nil
+		s.pending = false
+	}
+}
+
+// Runs the calls at the block with the provided hash (EIP-1898); the client must implement IContractCallerAtHash
+func AtBlockHash(blockHash common.Hash) CallOption {
+	return func(s *callSettings) {
+		s.blockHash = &blockHash
+		s.blockNumber = nil
+		s.pending = false
+	}
+}
+
+// Runs the calls against the pending block; the client must implement IPendingContractCaller
+func Pending() CallOption {
+	return func(s *callSettings) {
+		s.pending = true
+		s.blockNumber = nil
+		s.blockHash = nil
+	}
+}
+
+// Runs each call individually with the provided sender, instead of through the multicall contract
+func From(sender common.Address) CallOption {
+	return func(s *callSettings) {
+		s.from = sender
+	}
+}
+
+// Applies the options for running a batch
+func newCallSettings(options []CallOption) callSettings {
+	s := callSettings{}
+	for _, option := range options {
+		option(&s)
+	}
+	return s
+}
+
+// Sends an eth_call to the provided client at the block the settings target
+func (s callSettings) callContract(ctx context.Context, client IContractCaller, msg ethereum.CallMsg) ([]byte, error) {
+	if s.blockHash != nil {
+		hashCaller, ok := client.(IContractCallerAtHash)
+		if !ok {
+			return nil, fmt.Errorf("client does not support calls at a block hash")
+		}
+		return hashCaller.CallContractAtHash(ctx, msg, *s.blockHash)
+	}
+	if s.pending {
+		pendingCaller, ok := client.(IPendingContractCaller)
+		if !ok {
+			return nil, fmt.Errorf("client does not support calls against the pending block")
+		}
+		return pendingCaller.PendingCallContract(ctx, msg)
+	}
+	return client.CallContract(ctx, msg, s.blockNumber)
+}
+
+// Describes the block the settings target, for error messages
+func (s callSettings) blockDescription() string {
+	if s.blockHash != nil {
+		return "block " + s.blockHash.Hex()
+	}
+	if s.pending {
+		return "the pending block"
+	}
+	if s.blockNumber == nil {
+		return "the latest block"
+	}
+	return "block " + s.blockNumber.String()
+}
@@ -0,0 +1,161 @@
+package batchquery
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// The detailed result of a single call in a batch
+type CallResult struct {
+	// The index of the call within the batch
+	Index int
+
+	// The key the call was given, if any
+	Key string
+
+	// The contract address of the call's target
+	Target common.Address
+
+	// The name of the method that was called
+	Method string
+
+	// Whether or not the call worked
+	Success bool
+
+	// The raw return data of the call if it worked, or its revert data if it didn't (which may be empty if the target provided none)
+	ReturnData []byte
+
+	// Why the call failed: an *ErrCallReverted if it reverted, or an error matching ErrUnpackFailed if its response couldn't be unpacked (nil = it worked)
+	Err error
+
+	// The ABI of the contract that was called, used to unpack its response and decode its custom errors
+	contractAbi *abi.ABI
+
+	// The name of the method in the ABI
+	abiMethod string
+}
+
+// Gets the decoded revert reason if the call failed, or an empty string if it worked or didn't provide revert data.
+// Custom errors are decoded with the contract's ABI.
+func (r CallResult) RevertReason() string {
+	if r.Success {
+		return ""
+	}
+	return DecodeRevertReason(r.ReturnData, r.contractAbi)
+}
+
+// Unpacks the call's return data into the provided output, for calls that were added without one or that need to be read into another type.
+// The call must have succeeded.
+func (r CallResult) Unpack(output any) error {
+	if !r.Success {
+		return r.Err
+	}
+	if r.contractAbi == nil || r.abiMethod == "" {
+		return fmt.Errorf("error unpacking response for contract %s: method %s isn't in the call's ABI", r.Target.Hex(), r.Method)
+	}
+	err := r.contractAbi.UnpackIntoInterface(output, r.abiMethod, r.ReturnData)
+	if err != nil {
+		return fmt.Errorf("error unpacking response for contract %s, method %s: %w", r.Target.Hex(), r.Method, wrapUnpackError(err))
+	}
+	return nil
+}
+
+// The detailed results of every call in a batch, which can be looked up by the index of a call or by its key
+type Results struct {
+	// The result of each call, in the order the calls were added
+	results []CallResult
+
+	// The index of the first call with each key
+	keys map[string]int
+}
+
+// Creates the detailed results of a batch's calls from their responses and the errors from unpacking them (nil = none failed to unpack)
+func newResults(calls []*Call, responses []callResponse, unpackErrs []error) *Results {
+	results := &Results{
+		results: make([]CallResult, len(calls)),
+		keys:    map[string]int{},
+	}
+	for i, call := range calls {
+		result := CallResult{
+			Index:       i,
+			Key:         call.Key,
+			Target:      call.Target,
+			Method:      call.Method,
+			Success:     responses[i].success,
+			ReturnData:  responses[i].returnData,
+			contractAbi: call.contractAbi,
+			abiMethod:   call.abiMethod,
+		}
+		if unpackErrs != nil {
+			result.Err = unpackErrs[i]
+		}
+		if !result.Success {
+			result.Err = &ErrCallReverted{
+				Index:       i,
+				Target:      call.Target,
+				Method:      call.Method,
+				Data:        result.ReturnData,
+				contractAbi: call.contractAbi,
+			}
+		}
+		results.results[i] = result
+		if call.Key == "" {
+			continue
+		}
+		_, exists := results.keys[call.Key]
+		if !exists {
+			results.keys[call.Key] = i
+		}
+	}
+	return results
+}
+
+// Gets the number of results
+func (r *Results) Len() int {
+	return len(r.results)
+}
+
+// Gets the result of the call with the provided index
+func (r *Results) At(index int) CallResult {
+	return r.results[index]
+}
+
+// Gets the result of the call with the provided key, if there is one.
+// If several calls share the key, the result of the first one is returned.
+func (r *Results) Get(key string) (CallResult, bool) {
+	index, exists := r.keys[key]
+	if !exists {
+		return CallResult{}, false
+	}
+	return r.results[index], true
+}
+
+// Gets the result of every call, in the order the calls were added
+func (r *Results) All() []CallResult {
+	return r.results
+}
+
+// Gets the results of the calls that failed, either by reverting or because their response couldn't be unpacked
+func (r *Results) Failures() []CallResult {
+	failures := []CallResult{}
+	for _, result := range r.results {
+		if result.Err != nil {
+			failures = append(failures, result)
+		}
+	}
+	return failures
+}
+
+// Gets an error describing every call that failed, or nil if they all worked
+func (r *Results) Err() error {
+	errs := []error{}
+	for _, result := range r.results {
+		if result.Err != nil {
+			errs = append(errs, result.Err)
+		}
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,53 @@
+package batchquery
+
+import (
+	"context"
+	"math/big"
+	"strings"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// The ABI of the Multicall3 functions the Caller uses: https://github.com/mds1/multicall
+const multicall3AbiString = `[
+	{"inputs":[{"name":"requireSuccess","type":"bool"},{"components":[{"name":"target","type":"address"},{"name":"callData","type":"bytes"}],"name":"calls","type":"tuple[]"}],"name":"tryAggregate","outputs":[{"components":[{"name":"success","type":"bool"},{"name":"returnData","type":"bytes"}],"name":"returnData","type":"tuple[]"}],"stateMutability":"payable","type":"function"},
+	{"inputs":[{"name":"addr","type":"address"}],"name":"getEthBalance","outputs":[{"name":"balance","type":"uint256"}],"stateMutability":"view","type":"function"}
+]`
+
+var (
+	// The address of the canonical Multicall3 deployment, which exists at the same address on most EVM chains
+	Multicall3Address = common.HexToAddress("0xcA11bde05977b3631167028862bE2a173976CA11")
+
+	// The parsed Multicall3 ABI
+	multicall3Abi = mustParseAbi(multicall3AbiString)
+)
+
+// An Execution client binding that can call a contract function
+type IContractCaller interface {
+	// Calls a contract function, typically using eth_call
+	CallContract(ctx context.Context, call ethereum.CallMsg, blockNumber *big.Int) ([]byte, error)
+}
+
+// An Execution client binding that can call a contract function at a block identified by its hash (EIP-1898),
+// which is needed to run a batch with the AtBlockHash option
+type IContractCallerAtHash interface {
+	// Calls a contract function at the block with the provided hash, typically using eth_call
+	CallContractAtHash(ctx context.Context, call ethereum.CallMsg, blockHash common.Hash) ([]byte, error)
+}
+
+// An Execution client binding that can call a contract function against the pending block, which is needed to run a batch with the Pending option
+type IPendingContractCaller interface {
+	// Calls a contract function against the pending block, typically using eth_call
+	PendingCallContract(ctx context.Context, call ethereum.CallMsg) ([]byte, error)
+}
+
+// Parses an ABI, panicking on failure; only used for the ABIs built into the package
+func mustParseAbi(abiString string) abi.ABI {
+	parsed, err := abi.JSON(strings.NewReader(abiString))
+	if err != nil {
+		panic(err)
+	}
+	return parsed
+}
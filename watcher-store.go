@@ -0,0 +1,281 @@
+package batchquery
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+)
+
+// A store for a Watcher's latest snapshot, so the watcher can be restored with its last-known values when the application restarts
+type IWatchStore interface {
+	// Stores the serialized snapshot, replacing the previous one
+	SaveSnapshot(ctx context.Context, data []byte) error
+
+	// Gets the serialized snapshot, and whether there was one
+	LoadSnapshot(ctx context.Context) ([]byte, bool, error)
+}
+
+// A watch store that keeps the snapshot in a single file, which is replaced atomically on every save
+type FileWatchStore struct {
+	// The path of the file
+	path string
+}
+
+// A watch store that keeps the snapshot under a single key of an ICache, such as a shared Redis cache,
+// so it can be restored by another instance of the application
+type CacheWatchStore struct {
+	// The cache to store the snapshot in
+	cache ICache
+
+	// The key to store the snapshot under
+	key string
+}
+
+// The JSON representation of a watcher snapshot
+type watchSnapshotJson struct {
+	BlockNumber *hexutil.Big                `json:"blockNumber"`
+	FetchedAt   time.Time                   `json:"fetchedAt"`
+	Batches     map[string]watchResultsJson `json:"batches"`
+}
+
+// The JSON representation of the results of a watched batch
+type watchResultsJson struct {
+	Calls     []watchCallJson    `json:"calls"`
+	Responses []callResponseJson `json:"responses"`
+}
+
+// The JSON representation of a watched call, which identifies it when the snapshot is restored
+type watchCallJson struct {
+	Target   common.Address `json:"target"`
+	CallData hexutil.Bytes  `json:"callData"`
+	Method   string         `json:"method,omitempty"`
+	Key      string         `json:"key,omitempty"`
+}
+
+// Creates a new FileWatchStore that keeps the snapshot in the file at the provided path
+func NewFileWatchStore(path string) *FileWatchStore {
+	return &FileWatchStore{
+		path: path,
+	}
+}
+
+// Stores the serialized snapshot, writing it to a temporary file first so a crash never leaves a partial snapshot behind
+func (s *FileWatchStore) SaveSnapshot(ctx context.Context, data []byte) error {
+	file, err := os.CreateTemp(filepath.Dir(s.path), filepath.Base(s.path)+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("error creating temporary snapshot file for %s: %w", s.path, err)
+	}
+	_, err = file.Write(data)
+	if err == nil {
+		err = file.Sync()
+	}
+	closeErr := file.Close()
+	if err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(file.Name())
+		return fmt.Errorf("error writing snapshot file for %s: %w", s.path, err)
+	}
+	err = os.Rename(file.Name(), s.path)
+	if err != nil {
+		os.Remove(file.Name())
+		return fmt.Errorf("error replacing snapshot file %s: %w", s.path, err)
+	}
+	return nil
+}
+
+// Gets the serialized snapshot, and whether there was one
+func (s *FileWatchStore) LoadSnapshot(ctx context.Context) ([]byte, bool, error) {
+	data, err := os.ReadFile(s.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, fmt.Errorf("error reading snapshot file %s: %w", s.path, err)
+	}
+	return data, true, nil
+}
+
+// Creates a new CacheWatchStore that keeps the snapshot in the cache under the provided key
+func NewCacheWatchStore(cache ICache, key string) *CacheWatchStore {
+	return &CacheWatchStore{
+		cache: cache,
+		key:   key,
+	}
+}
+
+// Stores the serialized snapshot under the store's key, without an expiration or tags
+func (s *CacheWatchStore) SaveSnapshot(ctx context.Context, data []byte) error {
+	return s.cache.Set(ctx, s.key, data, 0, nil)
+}
+
+// Gets the serialized snapshot stored under the store's key, and whether there was one
+func (s *CacheWatchStore) LoadSnapshot(ctx context.Context) ([]byte, bool, error) {
+	return s.cache.Get(ctx, s.key)
+}
+
+// Serializes the snapshot, including the calls of each batch so it can be matched with the registered batches when it's restored
+func (s *WatchSnapshot) MarshalJSON() ([]byte, error) {
+	snapshot := watchSnapshotJson{
+		BlockNumber: (*hexutil.Big)(s.BlockNumber),
+		FetchedAt:   s.FetchedAt,
+		Batches:     make(map[string]watchResultsJson, len(s.results)),
+	}
+	for name, results := range s.results {
+		batch := watchResultsJson{
+			Calls:     make([]watchCallJson, len(results.results)),
+			Responses: make([]callResponseJson, len(results.results)),
+		}
+		for i, result := range results.results {
+			batch.Calls[i] = watchCallJson{
+				Target:   result.Target,
+				CallData: result.callData,
+				Method:   result.Method,
+				Key:      result.Key,
+			}
+			batch.Responses[i] = callResponseJson{
+				Status:     result.Success,
+				ReturnData: result.ReturnData,
+			}
+		}
+		snapshot.Batches[name] = batch
+	}
+	return json.Marshal(snapshot)
+}
+
+// Deserializes a snapshot that was previously serialized with MarshalJSON.
+// The ABIs of the original calls can't be serialized, so the results can't be unpacked until the snapshot is restored into a Watcher
+// with the same batches registered (see Watcher.Restore); until then, their records only have the raw return data.
+func (s *WatchSnapshot) UnmarshalJSON(data []byte) error {
+	var snapshot watchSnapshotJson
+	err := json.Unmarshal(data, &snapshot)
+	if err != nil {
+		return err
+	}
+	if snapshot.BlockNumber == nil {
+		return fmt.Errorf("snapshot is missing its block number")
+	}
+
+	s.BlockNumber = snapshot.BlockNumber.ToInt()
+	s.FetchedAt = snapshot.FetchedAt
+	s.results = make(map[string]*Results, len(snapshot.Batches))
+	for name, batch := range snapshot.Batches {
+		if len(batch.Responses) != len(batch.Calls) {
+			return fmt.Errorf("batch %s in snapshot has %d responses which mismatches its %d calls", name, len(batch.Responses), len(batch.Calls))
+		}
+		calls := make([]*Call, len(batch.Calls))
+		responses := make([]CallResponse, len(batch.Calls))
+		for i, call := range batch.Calls {
+			calls[i] = &Call{
+				Target:   call.Target,
+				CallData: call.CallData,
+				Method:   call.Method,
+				Key:      call.Key,
+			}
+			responses[i] = CallResponse{
+				Status:     batch.Responses[i].Status,
+				ReturnData: batch.Responses[i].ReturnData,
+			}
+		}
+		s.results[name] = newResults(calls, responses, nil)
+	}
+	return nil
+}
+
+// Restores the snapshot saved in the watcher's store, so the application has its last-known values immediately after a restart.
+// Batches are only restored if a batch with the same calls is registered under the same name, since their results are meaningless otherwise;
+// the restored results are unpacked with the ABIs of the registered batches. The restored snapshot is published (unless the watcher already
+// has a newer one) and delivered to the subscriptions, so the first refresh afterwards reports what changed while the application was down,
+// both to the subscriptions and to OnDiff. Register the batches and subscriptions before restoring.
+// Returns the restored snapshot, or nil if the store didn't have one.
+func (w *Watcher) Restore(ctx context.Context) (*WatchSnapshot, error) {
+	if w.Store == nil {
+		return nil, fmt.Errorf("error restoring watcher snapshot: the watcher has no store")
+	}
+	data, exists, err := w.Store.LoadSnapshot(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("error loading watcher snapshot: %w", err)
+	}
+	if !exists {
+		return nil, nil
+	}
+	var stored WatchSnapshot
+	err = json.Unmarshal(data, &stored)
+	if err != nil {
+		return nil, fmt.Errorf("error decoding watcher snapshot: %w", err)
+	}
+
+	w.refreshLock.Lock()
+	defer w.refreshLock.Unlock()
+
+	// Rebind the stored results to the registered batches
+	snapshot := &WatchSnapshot{
+		BlockNumber: stored.BlockNumber,
+		FetchedAt:   stored.FetchedAt,
+		results:     map[string]*Results{},
+	}
+	w.lock.Lock()
+	for name, results := range stored.results {
+		batch, exists := w.batches[name]
+		if !exists || !batchMatchesResults(batch, results) {
+			continue
+		}
+		responses := make([]CallResponse, len(results.results))
+		for i, result := range results.results {
+			responses[i] = CallResponse{
+				Status:     result.Success,
+				ReturnData: result.ReturnData,
+			}
+		}
+		snapshot.results[name] = newResults(batch.calls, responses, nil)
+	}
+	published := w.snapshot == nil || snapshot.BlockNumber.Cmp(w.snapshot.BlockNumber) > 0
+	if published {
+		w.snapshot = snapshot
+	}
+	w.lock.Unlock()
+
+	if published {
+		w.notifySubscriptions(ctx, snapshot)
+	}
+	return snapshot, nil
+}
+
+// Saves a snapshot to the watcher's store, if it has one
+func (w *Watcher) saveSnapshot(ctx context.Context, snapshot *WatchSnapshot) error {
+	if w.Store == nil {
+		return nil
+	}
+	data, err := json.Marshal(snapshot)
+	if err != nil {
+		return fmt.Errorf("error encoding watcher snapshot: %w", err)
+	}
+	err = w.Store.SaveSnapshot(ctx, data)
+	if err != nil {
+		return fmt.Errorf("error saving watcher snapshot at block %s: %w", snapshot.BlockNumber.String(), err)
+	}
+	return nil
+}
+
+// Checks whether a batch has the same calls as a set of stored results, in the same order
+func batchMatchesResults(batch *Batch, results *Results) bool {
+	if len(batch.calls) != len(results.results) {
+		return false
+	}
+	for i, call := range batch.calls {
+		result := results.results[i]
+		if call.Target != result.Target || !bytes.Equal(call.CallData, result.callData) {
+			return false
+		}
+	}
+	return true
+}
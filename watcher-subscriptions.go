@@ -0,0 +1,186 @@
+package batchquery
+
+import (
+	"bytes"
+	"context"
+	"math/big"
+	"sort"
+	"strconv"
+	"sync"
+)
+
+// A change in the result of a watched call between two of a Watcher's refreshes
+type WatchChange struct {
+	// The name of the batch the call belongs to
+	Name string
+
+	// The block of the refresh that observed the change
+	BlockNumber *big.Int
+
+	// The call's previous result (nil = this is the first result the subscription has seen for the call)
+	Before *CallResult
+
+	// The call's new result
+	After CallResult
+}
+
+// A subscription to changes in a Watcher's results
+type watchSubscription struct {
+	// The registration ID of the subscription, which orders the deliveries
+	id uint64
+
+	// The name of the batch to watch
+	name string
+
+	// The key of the call to watch ("" = every call in the batch)
+	key string
+
+	// The function to deliver changes to
+	handler func(ctx context.Context, change WatchChange)
+
+	// The last result delivered for each watched call, keyed by the call's key or index
+	last map[string]CallResult
+}
+
+// Subscribes to changes in the result of a watched call, identified by the name of its batch and its key (see Call.WithKey),
+// or to every call in the batch if the key is empty. The handler is called after a refresh only if a call's status or return data changed
+// (the ABI encoding is canonical, so this is the same as its decoded value changing), and once with the first result the subscription sees.
+// Handlers are called one at a time during the refresh, in the order they subscribed, after its snapshot is published,
+// so a slow handler delays the next refresh.
+// Returns a function that cancels the subscription.
+func (w *Watcher) Subscribe(name string, key string, handler func(change WatchChange)) func() {
+	return w.subscribe(name, key, func(ctx context.Context, change WatchChange) {
+		handler(change)
+	})
+}
+
+// Subscribes to changes like Subscribe, but delivers them on a channel with the provided buffer size.
+// Refreshes wait for the channel to have room, so the receiver must keep up; the wait is abandoned if the refresh's context is cancelled,
+// in which case the change is dropped. Returns the channel and a function that cancels the subscription and closes the channel.
+func (w *Watcher) Changes(name string, key string, buffer int) (<-chan WatchChange, func()) {
+	changes := make(chan WatchChange, buffer)
+	done := make(chan struct{})
+	var once sync.Once
+
+	// Held while sending, so the channel isn't closed during a send
+	var lock sync.Mutex
+	unsubscribe := w.subscribe(name, key, func(ctx context.Context, change WatchChange) {
+		lock.Lock()
+		defer lock.Unlock()
+		select {
+		case <-done:
+			return
+		default:
+		}
+		select {
+		case changes <- change:
+		case <-done:
+		case <-ctx.Done():
+		}
+	})
+	return changes, func() {
+		once.Do(func() {
+			unsubscribe()
+			close(done)
+			lock.Lock()
+			defer lock.Unlock()
+			close(changes)
+		})
+	}
+}
+
+// Subscribes to changes in the decoded value of a watched call like Subscribe, unpacking the previous and new results into values of type T.
+// A value is nil if there was no previous result, or if the call failed or its response couldn't be unpacked into T.
+// Returns a function that cancels the subscription.
+func SubscribeValue[T any](w *Watcher, name string, key string, handler func(before *T, after *T)) func() {
+	return w.Subscribe(name, key, func(change WatchChange) {
+		var before *T
+		if change.Before != nil {
+			before = unpackWatchedValue[T](*change.Before)
+		}
+		handler(before, unpackWatchedValue[T](change.After))
+	})
+}
+
+// Unpacks a watched call's result into a new value of type T, or returns nil if the call failed or couldn't be unpacked
+func unpackWatchedValue[T any](result CallResult) *T {
+	var value T
+	err := result.Unpack(&value)
+	if err != nil {
+		return nil
+	}
+	return &value
+}
+
+// Registers a subscription, returning a function that cancels it
+func (w *Watcher) subscribe(name string, key string, handler func(ctx context.Context, change WatchChange)) func() {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	if w.subscriptions == nil {
+		w.subscriptions = map[uint64]*watchSubscription{}
+	}
+	id := w.nextSubscriptionID
+	w.nextSubscriptionID++
+	w.subscriptions[id] = &watchSubscription{
+		id:      id,
+		name:    name,
+		key:     key,
+		handler: handler,
+		last:    map[string]CallResult{},
+	}
+	return func() {
+		w.lock.Lock()
+		defer w.lock.Unlock()
+		delete(w.subscriptions, id)
+	}
+}
+
+// Delivers the changes in a newly published snapshot to the subscriptions.
+// This must only be called while holding the refresh lock, which serializes access to each subscription's last results.
+func (w *Watcher) notifySubscriptions(ctx context.Context, snapshot *WatchSnapshot) {
+	w.lock.RLock()
+	subscriptions := make([]*watchSubscription, 0, len(w.subscriptions))
+	for _, subscription := range w.subscriptions {
+		subscriptions = append(subscriptions, subscription)
+	}
+	w.lock.RUnlock()
+	sort.Slice(subscriptions, func(i, j int) bool {
+		return subscriptions[i].id < subscriptions[j].id
+	})
+
+	for _, subscription := range subscriptions {
+		results, exists := snapshot.Results(subscription.name)
+		if !exists {
+			continue
+		}
+		if subscription.key != "" {
+			result, exists := results.Get(subscription.key)
+			if exists {
+				subscription.observe(ctx, snapshot, subscription.key, result)
+			}
+			continue
+		}
+		for _, result := range results.All() {
+			subscription.observe(ctx, snapshot, strconv.Itoa(result.Index), result)
+		}
+	}
+}
+
+// Records a call's latest result, delivering it to the handler if it changed since the last one
+func (s *watchSubscription) observe(ctx context.Context, snapshot *WatchSnapshot, id string, result CallResult) {
+	last, exists := s.last[id]
+	if exists && last.Success == result.Success && bytes.Equal(last.ReturnData, result.ReturnData) {
+		return
+	}
+	s.last[id] = result
+
+	change := WatchChange{
+		Name:        s.name,
+		BlockNumber: snapshot.BlockNumber,
+		After:       result,
+	}
+	if exists {
+		change.Before = &last
+	}
+	s.handler(ctx, change)
+}
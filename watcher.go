@@ -0,0 +1,252 @@
+package batchquery
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+// Watcher keeps the results of a set of registered batches continuously refreshed, either on every new block or on an interval,
+// turning them into a live mirror of the chain state they read. Each refresh runs all of the registered batches together at the same block,
+// and publishes their results as an immutable WatchSnapshot, so readers on any goroutine always see a consistent view of a single block.
+// The outputs the batches' calls were created with aren't written to, since they couldn't be read safely while a refresh runs;
+// values are read from the snapshot's results instead (see CallResult.Unpack).
+type Watcher struct {
+	// Whether or not every call must succeed for a refresh to be published; if a call fails, the previous snapshot is kept
+	// (false = failed calls are published with their errors in their results)
+	RequireSuccess bool
+
+	// Called after every refresh with the snapshot it published, or the error it failed with (nil = none).
+	// It's called without holding the watcher's lock, so it can call the watcher's other methods, but refreshes wait for it to return.
+	OnRefresh func(snapshot *WatchSnapshot, err error)
+
+	// Called after every published refresh with the differences from the previous snapshot, if there were any (nil = none).
+	// Like OnRefresh, it's called without holding the watcher's lock, and refreshes wait for it to return.
+	OnDiff func(diff *SnapshotDiff)
+
+	// Where every published snapshot is saved, so it can be restored with Restore when the application restarts (nil = don't save them).
+	// If saving fails, the snapshot is still published, and OnRefresh is called with both the snapshot and the error.
+	Store IWatchStore
+
+	// The MultiCaller whose client and settings are used to run the batches
+	caller *MultiCaller
+
+	// The client used to subscribe to new headers (nil = refresh on the interval instead)
+	subscriber IHeadSubscriber
+
+	// How often to refresh when there's no subscriber
+	interval time.Duration
+
+	// The registered batches, keyed by name
+	batches map[string]*Batch
+
+	// The latest published snapshot (nil = none yet)
+	snapshot *WatchSnapshot
+
+	// The subscriptions to changes in the results, keyed by their registration ID
+	subscriptions map[uint64]*watchSubscription
+
+	// The ID to assign to the next subscription
+	nextSubscriptionID uint64
+
+	// Lock for the registered batches, the subscriptions, and the latest snapshot
+	lock sync.RWMutex
+
+	// Serializes refreshes, so snapshots are always published in the order their blocks were run
+	refreshLock sync.Mutex
+}
+
+// The results of the registered batches from a single refresh of a Watcher, all from the same block
+type WatchSnapshot struct {
+	// The block the batches were run at
+	BlockNumber *big.Int
+
+	// The time the refresh finished
+	FetchedAt time.Time
+
+	// The results of each batch, keyed by the name it was registered with
+	results map[string]*Results
+}
+
+// Creates a new Watcher that refreshes its batches on the provided interval, at the latest block, using the settings and client of the MultiCaller
+func NewWatcher(caller *MultiCaller, interval time.Duration) *Watcher {
+	return &Watcher{
+		caller:   caller,
+		interval: interval,
+		batches:  map[string]*Batch{},
+	}
+}
+
+// Creates a new Watcher that refreshes its batches at every new block, using the settings and client of the MultiCaller.
+// If new blocks arrive while a refresh is still running, the intermediate blocks are skipped in favor of the latest.
+func NewHeadWatcher(caller *MultiCaller, subscriber IHeadSubscriber) *Watcher {
+	return &Watcher{
+		caller:     caller,
+		subscriber: subscriber,
+		batches:    map[string]*Batch{},
+	}
+}
+
+// Registers a batch to keep refreshed under the provided name, replacing any batch already registered with it.
+// Its results are included in the snapshots starting with the next refresh.
+// Returns a function that unregisters the batch.
+func (w *Watcher) Watch(name string, batch *Batch) func() {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	w.batches[name] = batch
+	return func() {
+		w.lock.Lock()
+		defer w.lock.Unlock()
+		if w.batches[name] == batch {
+			delete(w.batches, name)
+		}
+	}
+}
+
+// Gets the latest snapshot, or nil if there hasn't been a successful refresh yet. This is safe to call from multiple goroutines.
+func (w *Watcher) Snapshot() *WatchSnapshot {
+	w.lock.RLock()
+	defer w.lock.RUnlock()
+	return w.snapshot
+}
+
+// Refreshes the registered batches at the latest block right away, and publishes the new snapshot if it worked
+func (w *Watcher) Refresh(ctx context.Context) (*WatchSnapshot, error) {
+	return w.refresh(ctx, nil)
+}
+
+// Refreshes the registered batches until the context is cancelled (or, for a Watcher created with NewHeadWatcher, the subscription fails
+// or the LatestCache can't be invalidated), starting with an immediate refresh. Failed refreshes are reported to OnRefresh and don't stop the watcher; the previous snapshot is kept.
+// If the MultiCaller has a LatestCache, each new head is reported to it before the batches are refreshed.
+func (w *Watcher) Run(ctx context.Context) error {
+	if w.subscriber != nil {
+		return w.runOnHeads(ctx)
+	}
+	if w.interval <= 0 {
+		return fmt.Errorf("error running watcher: the refresh interval must be positive")
+	}
+
+	ticker := time.NewTicker(w.interval)
+	defer ticker.Stop()
+	for {
+		_, _ = w.refresh(ctx, nil)
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// Refreshes the registered batches on every new block, in the same way a HeadFeed runs its batches
+func (w *Watcher) runOnHeads(ctx context.Context) error {
+	return followHeads(ctx, w.subscriber, w.caller.LatestCache, func() {
+		_, _ = w.refresh(ctx, nil)
+	}, func(header *types.Header) {
+		_, _ = w.refresh(ctx, header.Number)
+	})
+}
+
+// Runs all of the registered batches together at the provided block (nil = the latest block), publishes the results as the new snapshot
+// if they're from a block at least as new as the current one, notifies the subscriptions and OnDiff of any changes, saves them to the store,
+// and reports them to OnRefresh
+func (w *Watcher) refresh(ctx context.Context, blockNumber *big.Int) (*WatchSnapshot, error) {
+	w.refreshLock.Lock()
+	defer w.refreshLock.Unlock()
+
+	snapshot, err := w.run(ctx, blockNumber)
+	if err == nil {
+		w.lock.Lock()
+		previous := w.snapshot
+		published := previous == nil || snapshot.BlockNumber.Cmp(previous.BlockNumber) >= 0
+		if published {
+			w.snapshot = snapshot
+		}
+		w.lock.Unlock()
+		if published {
+			w.notifySubscriptions(ctx, snapshot)
+			if w.OnDiff != nil && previous != nil {
+				diff := DiffSnapshots(previous, snapshot)
+				if !diff.IsEmpty() {
+					w.OnDiff(diff)
+				}
+			}
+			err = w.saveSnapshot(ctx, snapshot)
+		}
+	}
+	if w.OnRefresh != nil {
+		w.OnRefresh(snapshot, err)
+	}
+	return snapshot, err
+}
+
+// Runs all of the registered batches together at the provided block (nil = the latest block)
+func (w *Watcher) run(ctx context.Context, blockNumber *big.Int) (*WatchSnapshot, error) {
+	opts, err := pinCallOpts(w.caller, &bind.CallOpts{
+		Context:     ctx,
+		BlockNumber: blockNumber,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error pinning watcher refresh to the latest block: %w", err)
+	}
+
+	// Combine the batches into one, in a fixed order so the results can be split back up
+	w.lock.RLock()
+	names := make([]string, 0, len(w.batches))
+	for name := range w.batches {
+		names = append(names, name)
+	}
+	batches := make([]*Batch, len(names))
+	sort.Strings(names)
+	for i, name := range names {
+		batches[i] = w.batches[name]
+	}
+	w.lock.RUnlock()
+
+	calls := []*Call{}
+	for _, batch := range batches {
+		calls = append(calls, batch.calls...)
+	}
+	responses := []CallResponse{}
+	if len(calls) > 0 {
+		responses, err = w.caller.withCalls(calls).executeVerified(calls, w.RequireSuccess, w.caller.newCallOptions(opts))
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	snapshot := &WatchSnapshot{
+		BlockNumber: opts.BlockNumber,
+		FetchedAt:   time.Now(),
+		results:     make(map[string]*Results, len(names)),
+	}
+	offset := 0
+	for i, batch := range batches {
+		count := len(batch.calls)
+		snapshot.results[names[i]] = newResults(batch.calls, responses[offset:offset+count], nil)
+		offset += count
+	}
+	return snapshot, nil
+}
+
+// Gets the results of the batch registered with the provided name, or false if it wasn't part of the snapshot
+func (s *WatchSnapshot) Results(name string) (*Results, bool) {
+	results, exists := s.results[name]
+	return results, exists
+}
+
+// Gets the names of the batches in the snapshot, in sorted order
+func (s *WatchSnapshot) Names() []string {
+	names := make([]string, 0, len(s.results))
+	for name := range s.results {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
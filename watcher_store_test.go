@@ -0,0 +1,166 @@
+package batchquery
+
+import (
+	"context"
+	"errors"
+	"math/big"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// A watch store that fails every save
+type failingWatchStore struct {
+	err error
+}
+
+func (s *failingWatchStore) SaveSnapshot(ctx context.Context, data []byte) error {
+	return s.err
+}
+
+func (s *failingWatchStore) LoadSnapshot(ctx context.Context) ([]byte, bool, error) {
+	return nil, false, s.err
+}
+
+func TestWatcherRestoresSnapshot(t *testing.T) {
+	ctx := context.Background()
+	store := NewFileWatchStore(filepath.Join(t.TempDir(), "snapshot.json"))
+	account := common.HexToAddress("0x0102")
+	other := common.HexToAddress("0x0304")
+
+	mc, _ := newTestMultiCaller(t)
+	watcher := NewWatcher(mc, time.Hour)
+	watcher.Store = store
+	watcher.Watch("balances", newBalanceBatch(t, mc, account))
+	watcher.Watch("other", newBalanceBatch(t, mc, other))
+	_, err := watcher.refresh(ctx, big.NewInt(10))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Restart with the same balance batch, but a different "other" batch whose stored results no longer apply
+	mc, _ = newTestMultiCaller(t)
+	restarted := NewWatcher(mc, time.Hour)
+	restarted.Store = store
+	restarted.Watch("balances", newBalanceBatch(t, mc, account))
+	restarted.Watch("other", newBalanceBatch(t, mc, account, other))
+	var changes []WatchChange
+	restarted.Subscribe("balances", account.Hex(), func(change WatchChange) {
+		changes = append(changes, change)
+	})
+	var diffs []*SnapshotDiff
+	restarted.OnDiff = func(diff *SnapshotDiff) {
+		diffs = append(diffs, diff)
+	}
+
+	snapshot, err := restarted.Restore(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if restarted.Snapshot() != snapshot || snapshot.BlockNumber.Int64() != 10 {
+		t.Fatalf("expected the snapshot from block 10 to be published, got %v", snapshot)
+	}
+	if names := snapshot.Names(); len(names) != 1 || names[0] != "balances" {
+		t.Fatalf("expected only the unchanged batch to be restored, got %v", names)
+	}
+	if getWatchedBalance(t, snapshot, "balances", account).Cmp(expectedBalance(account, 10)) != 0 {
+		t.Fatal("unexpected restored balance")
+	}
+	if len(changes) != 1 || changes[0].Before != nil || changes[0].BlockNumber.Int64() != 10 {
+		t.Fatalf("expected the restored value to be delivered to the subscription, got %+v", changes)
+	}
+
+	// The first refresh reports what changed since the stored snapshot
+	_, err = restarted.refresh(ctx, big.NewInt(12))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(changes) != 2 || changes[1].Before == nil {
+		t.Fatalf("expected a change from the restored value, got %+v", changes)
+	}
+	var before *big.Int
+	err = changes[1].Before.Unpack(&before)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if before.Cmp(expectedBalance(account, 10)) != 0 {
+		t.Fatalf("expected the previous value to be the restored one, got %s", before)
+	}
+	if len(diffs) != 1 || diffs[0].FromBlock.Int64() != 10 || diffs[0].ToBlock.Int64() != 12 {
+		t.Fatalf("expected a diff from the restored snapshot, got %+v", diffs)
+	}
+
+	// The refresh replaced the stored snapshot
+	fresh := NewWatcher(mc, time.Hour)
+	fresh.Store = store
+	snapshot, err = fresh.Restore(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if snapshot.BlockNumber.Int64() != 12 {
+		t.Fatalf("expected the latest snapshot to be stored, got block %s", snapshot.BlockNumber)
+	}
+}
+
+func TestWatcherRestoreWithoutSnapshot(t *testing.T) {
+	ctx := context.Background()
+	mc, _ := newTestMultiCaller(t)
+	watcher := NewWatcher(mc, time.Hour)
+	_, err := watcher.Restore(ctx)
+	if err == nil {
+		t.Fatal("expected restoring without a store to fail")
+	}
+
+	watcher.Store = NewCacheWatchStore(newMapCache(), "watcher")
+	snapshot, err := watcher.Restore(ctx)
+	if err != nil || snapshot != nil || watcher.Snapshot() != nil {
+		t.Fatalf("expected nothing to be restored from an empty store, got %v, %v", snapshot, err)
+	}
+
+	// A newer snapshot isn't replaced by the stored one
+	account := common.HexToAddress("0x0102")
+	saver := NewWatcher(mc, time.Hour)
+	saver.Store = watcher.Store
+	saver.Watch("balances", newBalanceBatch(t, mc, account))
+	_, err = saver.refresh(ctx, big.NewInt(10))
+	if err != nil {
+		t.Fatal(err)
+	}
+	watcher.Watch("balances", newBalanceBatch(t, mc, account))
+	store := watcher.Store
+	watcher.Store = nil
+	latest, err := watcher.refresh(ctx, big.NewInt(20))
+	if err != nil {
+		t.Fatal(err)
+	}
+	watcher.Store = store
+	snapshot, err = watcher.Restore(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if snapshot.BlockNumber.Int64() != 10 || watcher.Snapshot() != latest {
+		t.Fatalf("expected the stored snapshot to be loaded without replacing the newer one, got block %s", watcher.Snapshot().BlockNumber)
+	}
+}
+
+func TestWatcherReportsStoreErrors(t *testing.T) {
+	mc, _ := newTestMultiCaller(t)
+	storeErr := errors.New("disk full")
+	watcher := NewWatcher(mc, time.Hour)
+	watcher.Store = &failingWatchStore{err: storeErr}
+	watcher.Watch("balances", newBalanceBatch(t, mc, common.HexToAddress("0x0102")))
+	var reported error
+	watcher.OnRefresh = func(snapshot *WatchSnapshot, err error) {
+		reported = err
+	}
+
+	snapshot, err := watcher.Refresh(context.Background())
+	if !errors.Is(err, storeErr) || !errors.Is(reported, storeErr) {
+		t.Fatalf("expected the store error to be reported, got %v and %v", err, reported)
+	}
+	if snapshot == nil || watcher.Snapshot() != snapshot {
+		t.Fatal("expected the snapshot to be published even though it couldn't be saved")
+	}
+}
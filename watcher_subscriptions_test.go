@@ -0,0 +1,109 @@
+package batchquery
+
+import (
+	"context"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+func TestWatcherSubscriptionsOnlyReportChanges(t *testing.T) {
+	mc, _ := newTestMultiCaller(t)
+	account := common.HexToAddress("0x0102")
+	watcher := NewWatcher(mc, time.Hour)
+	builder := mc.Clone()
+	builder.AddCall(testTokenAddress, &testTokenAbi, new(*big.Int), "balanceOf", account).WithKey("balance")
+	builder.AddCall(testTokenAddress, &testTokenAbi, new(uint8), "decimals").WithKey("decimals")
+	batch, err := builder.Snapshot()
+	if err != nil {
+		t.Fatal(err)
+	}
+	watcher.Watch("token", batch)
+
+	var balanceChanges []WatchChange
+	var allChanges []WatchChange
+	var values [][2]*big.Int
+	watcher.Subscribe("token", "balance", func(change WatchChange) {
+		balanceChanges = append(balanceChanges, change)
+	})
+	watcher.Subscribe("token", "", func(change WatchChange) {
+		allChanges = append(allChanges, change)
+	})
+	SubscribeValue(watcher, "token", "balance", func(before **big.Int, after **big.Int) {
+		var pair [2]*big.Int
+		if before != nil {
+			pair[0] = *before
+		}
+		pair[1] = *after
+		values = append(values, pair)
+	})
+
+	// The mock balance changes with the block, while the decimals don't
+	ctx := context.Background()
+	for _, block := range []int64{10, 10, 11} {
+		_, err := watcher.refresh(ctx, big.NewInt(block))
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	if len(balanceChanges) != 2 || balanceChanges[0].Before != nil || balanceChanges[1].Before == nil {
+		t.Fatalf("expected the first balance and one change, got %d changes", len(balanceChanges))
+	}
+	change := balanceChanges[1]
+	if change.Name != "token" || change.BlockNumber.Int64() != 11 || change.After.Key != "balance" {
+		t.Fatalf("unexpected change %+v", change)
+	}
+	if len(allChanges) != 3 {
+		t.Fatalf("expected the first balance and decimals and one balance change, got %d changes", len(allChanges))
+	}
+	if len(values) != 2 || values[0][0] != nil || values[1][0].Cmp(expectedBalance(account, 10)) != 0 || values[1][1].Cmp(expectedBalance(account, 11)) != 0 {
+		t.Fatalf("unexpected decoded values %v", values)
+	}
+}
+
+func TestWatcherChangesChannel(t *testing.T) {
+	mc, _ := newTestMultiCaller(t)
+	account := common.HexToAddress("0x0102")
+	watcher := NewWatcher(mc, time.Hour)
+	watcher.Watch("balances", newBalanceBatch(t, mc, account))
+	changes, unsubscribe := watcher.Changes("balances", account.Hex(), 1)
+
+	ctx := context.Background()
+	_, err := watcher.refresh(ctx, big.NewInt(10))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The second change waits for the receiver, since the buffer is full
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		_, err := watcher.refresh(ctx, big.NewInt(11))
+		if err != nil {
+			t.Error(err)
+		}
+	}()
+	for _, block := range []int64{10, 11} {
+		change := <-changes
+		if change.BlockNumber.Int64() != block {
+			t.Fatalf("expected a change at block %d, got %s", block, change.BlockNumber)
+		}
+	}
+	<-done
+
+	// Unsubscribing closes the channel, even while a refresh is waiting to send on it
+	changes, unsubscribe2 := watcher.Changes("balances", account.Hex(), 0)
+	done = make(chan struct{})
+	go func() {
+		defer close(done)
+		watcher.refresh(ctx, big.NewInt(12))
+	}()
+	time.Sleep(10 * time.Millisecond)
+	unsubscribe()
+	unsubscribe2()
+	<-done
+	for range changes {
+	}
+}
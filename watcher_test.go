@@ -0,0 +1,194 @@
+package batchquery
+
+import (
+	"context"
+	"errors"
+	"math/big"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+// Creates a batch that gets the balances of the provided accounts, keyed by their addresses
+func newBalanceBatch(t *testing.T, mc *MultiCaller, accounts ...common.Address) *Batch {
+	builder := mc.Clone()
+	for _, account := range accounts {
+		builder.AddCall(testTokenAddress, &testTokenAbi, new(*big.Int), "balanceOf", account).WithKey(account.Hex())
+	}
+	batch, err := builder.Snapshot()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return batch
+}
+
+// Gets a balance from a watcher snapshot
+func getWatchedBalance(t *testing.T, snapshot *WatchSnapshot, name string, account common.Address) *big.Int {
+	results, exists := snapshot.Results(name)
+	if !exists {
+		t.Fatalf("expected batch %s in the snapshot", name)
+	}
+	result, exists := results.Get(account.Hex())
+	if !exists {
+		t.Fatalf("expected a result for %s in batch %s", account.Hex(), name)
+	}
+	var balance *big.Int
+	err := result.Unpack(&balance)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return balance
+}
+
+func TestWatcherRefreshesBatchesTogether(t *testing.T) {
+	mc, client := newTestMultiCaller(t)
+	first := common.HexToAddress("0x0101")
+	second := common.HexToAddress("0x0202")
+	watcher := NewWatcher(mc, time.Hour)
+	watcher.Watch("first", newBalanceBatch(t, mc, first))
+	unwatch := watcher.Watch("second", newBalanceBatch(t, mc, second))
+	if watcher.Snapshot() != nil {
+		t.Fatal("expected no snapshot before the first refresh")
+	}
+
+	snapshot, err := watcher.Refresh(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if watcher.Snapshot() != snapshot || snapshot.BlockNumber.Int64() != 100 {
+		t.Fatalf("expected the refresh to be published at the latest block, got %s", snapshot.BlockNumber)
+	}
+	if names := snapshot.Names(); len(names) != 2 || names[0] != "first" || names[1] != "second" {
+		t.Fatalf("unexpected batches %v", names)
+	}
+	if getWatchedBalance(t, snapshot, "first", first).Cmp(expectedBalance(first, 100)) != 0 ||
+		getWatchedBalance(t, snapshot, "second", second).Cmp(expectedBalance(second, 100)) != 0 {
+		t.Fatal("unexpected balances")
+	}
+
+	// Both batches run in one multicall, after pinning the block
+	if sizes := client.getChunkSizes(); len(sizes) != 1 || sizes[0] != 2 {
+		t.Fatalf("expected a single multicall of 2 calls, got %v", sizes)
+	}
+
+	// Unregistered batches are dropped from the next snapshot
+	unwatch()
+	snapshot, err = watcher.Refresh(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, exists := snapshot.Results("second"); exists {
+		t.Fatal("expected the unregistered batch to be dropped")
+	}
+}
+
+func TestWatcherKeepsSnapshotOnFailure(t *testing.T) {
+	mc, client := newTestMultiCaller(t)
+	watcher := NewWatcher(mc, time.Hour)
+	watcher.Watch("balances", newBalanceBatch(t, mc, common.HexToAddress("0x01")))
+	var refreshErrs []error
+	watcher.OnRefresh = func(snapshot *WatchSnapshot, err error) {
+		refreshErrs = append(refreshErrs, err)
+	}
+	snapshot, err := watcher.Refresh(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	client.err = errors.New("node is down")
+	_, err = watcher.Refresh(context.Background())
+	if err == nil {
+		t.Fatal("expected the refresh to fail")
+	}
+	if watcher.Snapshot() != snapshot {
+		t.Fatal("expected the previous snapshot to be kept")
+	}
+	if len(refreshErrs) != 2 || refreshErrs[0] != nil || refreshErrs[1] == nil {
+		t.Fatalf("unexpected refresh reports %v", refreshErrs)
+	}
+
+	// Failed calls are published with their errors unless every call must succeed
+	client.err = nil
+	builder := mc.Clone()
+	builder.AddCall(testTokenAddress, &testTokenAbi, nil, "boom")
+	reverting, err := builder.Snapshot()
+	if err != nil {
+		t.Fatal(err)
+	}
+	watcher.Watch("reverting", reverting)
+	snapshot, err = watcher.Refresh(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	results, _ := snapshot.Results("reverting")
+	var reverted *ErrCallReverted
+	if !errors.As(results.At(0).Err, &reverted) {
+		t.Fatalf("expected the reverted call to be published with its error, got %v", results.At(0).Err)
+	}
+	watcher.RequireSuccess = true
+	_, err = watcher.Refresh(context.Background())
+	if err == nil || watcher.Snapshot() != snapshot {
+		t.Fatalf("expected the refresh to fail and keep the previous snapshot, got %v", err)
+	}
+}
+
+func TestHeadWatcherRefreshesOnNewBlocks(t *testing.T) {
+	mc, _ := newTestMultiCaller(t)
+	account := common.HexToAddress("0x0303")
+	subscriber := &mockHeadSubscriber{headers: []*types.Header{{Number: big.NewInt(120)}}}
+	watcher := NewHeadWatcher(mc, subscriber)
+	watcher.Watch("balances", newBalanceBatch(t, mc, account))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	watcher.OnRefresh = func(snapshot *WatchSnapshot, err error) {
+		if err == nil && snapshot.BlockNumber.Int64() == 120 {
+			cancel()
+		}
+	}
+	err := watcher.Run(ctx)
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected the watcher to stop when cancelled, got %v", err)
+	}
+	snapshot := watcher.Snapshot()
+	if snapshot.BlockNumber.Int64() != 120 || getWatchedBalance(t, snapshot, "balances", account).Cmp(expectedBalance(account, 120)) != 0 {
+		t.Fatalf("expected the snapshot from block 120, got block %s", snapshot.BlockNumber)
+	}
+}
+
+func TestWatcherSnapshotsCanBeReadConcurrently(t *testing.T) {
+	mc, _ := newTestMultiCaller(t)
+	account := common.HexToAddress("0x0404")
+	watcher := NewWatcher(mc, time.Millisecond)
+	watcher.Watch("balances", newBalanceBatch(t, mc, account))
+	_, err := watcher.Refresh(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for ctx.Err() == nil {
+				results, _ := watcher.Snapshot().Results("balances")
+				var balance *big.Int
+				if err := results.At(0).Unpack(&balance); err != nil || balance.Cmp(expectedBalance(account, 100)) != 0 {
+					t.Errorf("unexpected balance %s: %v", balance, err)
+					return
+				}
+			}
+		}()
+	}
+	err = watcher.Run(ctx)
+	wg.Wait()
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected the watcher to stop at the deadline, got %v", err)
+	}
+}
@@ -0,0 +1,56 @@
+package batchquery
+
+import (
+	"fmt"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// The native currency balance of an address along with its balance of the chain's wrapped native token (such as WETH),
+// for applications that treat the two as the same asset
+type NativeBalance struct {
+	// The balance of the native currency, such as ETH
+	Native *big.Int
+
+	// The balance of the wrapped native token
+	Wrapped *big.Int
+
+	// The sum of the native and wrapped balances
+	Total *big.Int
+}
+
+// Gets the address of a chain's wrapped native token from its built-in profile, if it has one
+func GetWrappedNativeAddress(chainID uint64) (common.Address, bool) {
+	profile, exists := GetChainProfile(chainID)
+	if !exists || profile.WrappedNativeAddress == (common.Address{}) {
+		return common.Address{}, false
+	}
+	return profile.WrappedNativeAddress, true
+}
+
+// Retrieves the native balance and wrapped native token balance of each address with the same calls, so both come from the same block.
+// The order of the resulting array corresponds to the order of the provided addresses. Use GetWrappedNativeAddress to look up the token for a known chain.
+func (b *BalanceBatcher) GetNativeBalances(addresses []common.Address, wrappedNative common.Address, opts *bind.CallOpts) ([]NativeBalance, error) {
+	if wrappedNative == (common.Address{}) {
+		return nil, fmt.Errorf("no wrapped native token address was provided")
+	}
+	tokens := []common.Address{
+		{}, // Empty token for ETH balance
+		wrappedNative,
+	}
+	balances, err := b.GetTokenBalances(addresses, tokens, opts)
+	if err != nil {
+		return nil, err
+	}
+	nativeBalances := make([]NativeBalance, len(addresses))
+	for i, addressBalances := range balances {
+		nativeBalances[i] = NativeBalance{
+			Native:  addressBalances[0],
+			Wrapped: addressBalances[1],
+			Total:   new(big.Int).Add(addressBalances[0], addressBalances[1]),
+		}
+	}
+	return nativeBalances, nil
+}
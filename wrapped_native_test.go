@@ -0,0 +1,93 @@
+package batchquery
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+var testWrappedNativeAddress = common.HexToAddress("0x0000000000000000000000000000000000000003")
+
+func TestGetTokenBalances(t *testing.T) {
+	batcher, err := NewBalanceBatcher(&mockBalanceCheckerClient{}, testTokenAddress, 4, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Three tokens leave room for a single address in each call
+	addresses := []common.Address{common.HexToAddress("0x01"), common.HexToAddress("0x02"), common.HexToAddress("0x03")}
+	tokens := []common.Address{{}, common.HexToAddress("0x05"), common.HexToAddress("0x07")}
+	balances, err := batcher.GetTokenBalances(addresses, tokens, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, address := range addresses {
+		for j, token := range tokens {
+			if balances[i][j].Cmp(mockBalance(address, token)) != 0 {
+				t.Fatalf("expected balance %s of token %d for address %d, got %s", mockBalance(address, token), j, i, balances[i][j])
+			}
+		}
+	}
+}
+
+func TestChainSetCombinesWrappedNative(t *testing.T) {
+	address := common.HexToAddress("0x02")
+	config := ChainConfig{
+		Client:                &mockBalanceCheckerClient{},
+		MulticallAddress:      testMulticallAddress,
+		BalanceBatcherAddress: testTokenAddress,
+		WrappedNativeAddress:  testWrappedNativeAddress,
+		BalanceBatchSize:      10,
+		BalanceThreadLimit:    1,
+	}
+	chains := NewChainSet()
+	err := chains.AddChain(1, config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	config.CombineWrappedNative = true
+	err = chains.AddChain(2, config)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Only the chain configured to combine them folds the wrapped balance in
+	separate, err := chains.GetEthBalances(1, []common.Address{address}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	combined, err := chains.GetEthBalances(2, []common.Address{address}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if separate[0].Int64() != 2 || combined[0].Int64() != 2+3002 {
+		t.Fatalf("expected balances of 2 and 3004, got %s and %s", separate[0], combined[0])
+	}
+
+	nativeBalances, err := chains.GetNativeBalances(1, []common.Address{address}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if nativeBalances[0].Native.Int64() != 2 || nativeBalances[0].Wrapped.Int64() != 3002 || nativeBalances[0].Total.Cmp(big.NewInt(3004)) != 0 {
+		t.Fatalf("unexpected native balance %+v", nativeBalances[0])
+	}
+
+	// Combining requires the token's address
+	config.WrappedNativeAddress = common.Address{}
+	err = chains.AddChain(3, config)
+	if err == nil {
+		t.Fatal("expected a chain without a wrapped native token to be rejected")
+	}
+}
+
+func TestKnownWrappedNativeAddresses(t *testing.T) {
+	address, exists := GetWrappedNativeAddress(1)
+	if !exists || address != MainnetWethAddress {
+		t.Fatalf("expected WETH for Ethereum, got %s", address.Hex())
+	}
+	_, exists = GetWrappedNativeAddress(1337)
+	if exists {
+		t.Fatal("expected no wrapped native token for an unknown chain")
+	}
+}